@@ -1,17 +1,55 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/aldehir/ue2-docs/internal/converter"
+	"github.com/aldehir/ue2-docs/internal/search"
 )
 
-func runConvert(args []string) {
+func runConvert(args []string) int {
 	fs := flag.NewFlagSet("convert", flag.ExitOnError)
 
 	inputDir := fs.String("input", "./output", "Input directory containing scraped HTML")
 	outputDir := fs.String("output", "./markdown", "Output directory for markdown files")
+	format := fs.String("format", "markdown", "Comma-separated output formats written from one parse of each page: markdown (in the output directory), mkdocs (an MkDocs project in "+converter.MkDocsDir+"/) and ndjson (one JSON page per line in "+converter.NDJSONFile+")")
 	preserveStructure := fs.Bool("preserve-structure", true, "Keep original directory structure")
+	rawHTML := fs.Bool("raw-html", false, "Keep elements with no Markdown equivalent (applets, objects, forms, sub/superscripts, ...) as delimited raw HTML instead of reducing them to text")
+	toc := fs.Bool("toc", false, "Add a table of contents to the top of each page, replacing TWiki's %TOC% boxes")
+	tocMinLevel := fs.Int("toc-min-level", 2, "Shallowest heading level listed in tables of contents")
+	tocMaxLevel := fs.Int("toc-max-level", 3, "Deepest heading level listed in tables of contents")
+	reflowName := fs.String("reflow", "none", "How to break paragraph text into lines: none (one line per paragraph), width (wrap at --wrap-width) or sentence (one sentence per line, for smaller diffs)")
+	wrapWidth := fs.Int("wrap-width", converter.DefaultWrapWidth, "Line width for --reflow width")
+	trimTrailing := fs.Bool("trim-trailing-space", false, "Strip trailing whitespace from lines outside code blocks")
+	collapseBlank := fs.Bool("collapse-blank-lines", false, "Allow at most one blank line in a row outside code blocks, and none at the start or end of a page")
+	entityMapFile := fs.String("entity-map", "", "File of extra text replacements (two Go-quoted strings per line)")
+	icons := fs.Bool("icons", false, "Replace TWiki's small icon images (new.gif, updated.gif, arrow bullets, ...) with text or emoji, and drop spacer images")
+	iconMapFile := fs.String("icon-map", "", "File of extra icon substitutions for --icons: an image file name and its replacement text (\"\" to drop it), as two Go-quoted strings per line")
+	searchIndex := fs.String("search-index", "search-index.json", "Full-text search index of the converted pages, relative to the output directory (empty = disabled)")
+	analyzerName := fs.String("analyzer", search.DefaultAnalyzer().Name, "Search index analyzer: unrealscript (splits identifiers like PostNetBeginPlay), standard, or simple")
+	repairReport := fs.String("repair-report", "", "Report of the broken markup repaired in each page before conversion, relative to the output directory (empty = disabled)")
+	frontMatter := fs.Bool("front-matter", false, "Start each page with YAML front matter holding its title, TWiki author, revision and parent topic, and, as ISO-8601 last_modified, the date found in its footer")
+	datePatternsFile := fs.String("date-patterns", "", "File of regular expressions (one per line, first group capturing the date) replacing the default footer date patterns for --front-matter")
+	backlinks := fs.Bool("backlinks", false, "End every page other pages link to with a \"Referenced by\" section listing them, like TWiki's backlinks (also listed as referenced_by with --front-matter)")
+	headingIDs := fs.Bool("heading-ids", false, "Give every heading an explicit {#id} anchor, kept from the original HTML's name= and id= anchors where present so external deep links still resolve (Pandoc, Hugo, MkDocs and the export command honor these)")
+	prettyTitles := fs.Bool("pretty-titles", false, "Show links whose text is a CamelCase topic name, such as ActorVariables, with the linked page's <title>, or else the name split into words (\"Actor Variables\")")
+	update := fs.Bool("update", false, "Convert over an earlier conversion, keeping the previous version of each changed page under "+converter.PreviousDir+" in the output directory")
+	changes := fs.String("changes", "", "Changelog of dated pages with diffs of the pages changed by --update, relative to the output directory (empty = disabled)")
+	streamThreshold := fs.Int64("stream-threshold", converter.DefaultStreamThreshold, "Convert pages stored larger than this many bytes by streaming, one block at a time, to bound memory on huge generated pages; these get no table of contents and no footer date in their front matter (negative = never)")
+	stampName := fs.String("stamp", "none", "Record on each page where it came from: none, header (a quoted line after any front matter) or footer (a line below a rule at the end)")
+	stampText := fs.String("stamp-text", converter.DefaultStampText, "Provenance line written by --stamp, with {url}, {date} and {attribution} replaced by the page's source URL, the date it was scraped and --attribution")
+	attribution := fs.String("attribution", "", "Copyright attribution for --stamp and --notice, e.g. \"Documentation (c) Epic Games, Inc.\"")
+	notice := fs.String("notice", "", "NOTICE file naming the sites scraped, when, and --attribution, relative to the output directory (empty = disabled)")
+	gitCommit := fs.Bool("git", false, gitUsage)
+	quickReference := fs.String("quick-reference", "QuickReference.md", "Appendix of console commands, INI settings and exec functions, relative to the output directory (empty = disabled)")
 
 	fs.Usage = func() {
 		fmt.Println("Usage: ue2-docs convert [flags]")
@@ -23,19 +61,234 @@ func runConvert(args []string) {
 		fmt.Println()
 		fmt.Println("Example:")
 		fmt.Println("  ue2-docs convert --input ./scraped --output ./docs")
+		fmt.Println("  ue2-docs convert --input ./scraped --output ./docs --format markdown,mkdocs,ndjson")
 	}
 
 	fs.Parse(args)
 
+	var entityMap map[string]string
+	if *entityMapFile != "" {
+		var err error
+		entityMap, err = converter.LoadEntityMap(*entityMapFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitConfigError
+		}
+	}
+
+	var iconMap map[string]string
+	if *iconMapFile != "" {
+		if !*icons {
+			fmt.Fprintf(os.Stderr, "Error: --icon-map requires --icons\n")
+			return exitConfigError
+		}
+		var err error
+		iconMap, err = converter.LoadIconMap(*iconMapFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitConfigError
+		}
+	}
+
+	var datePatterns []*regexp.Regexp
+	if *datePatternsFile != "" {
+		var err error
+		datePatterns, err = converter.LoadDatePatterns(*datePatternsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitConfigError
+		}
+	}
+
+	if *tocMinLevel < 1 || *tocMaxLevel > 6 || *tocMinLevel > *tocMaxLevel {
+		fmt.Fprintf(os.Stderr, "Error: --toc-min-level and --toc-max-level must satisfy 1 <= min <= max <= 6\n")
+		return exitConfigError
+	}
+
+	if *changes != "" && !*update {
+		fmt.Fprintf(os.Stderr, "Error: --changes requires --update\n")
+		return exitConfigError
+	}
+
+	formats, err := converter.ParseFormats(*format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --format: %v\n", err)
+		return exitConfigError
+	}
+	if *update && !slices.Contains(formats, converter.FormatMarkdown) {
+		fmt.Fprintf(os.Stderr, "Error: --update requires the markdown format\n")
+		return exitConfigError
+	}
+
+	stamp, err := converter.ParseStamp(*stampName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --stamp: %v\n", err)
+		return exitConfigError
+	}
+
+	reflow, err := converter.ParseReflow(*reflowName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --reflow: %v\n", err)
+		return exitConfigError
+	}
+	if *wrapWidth < 1 {
+		fmt.Fprintf(os.Stderr, "Error: --wrap-width must be at least 1\n")
+		return exitConfigError
+	}
+
+	analyzer, err := search.ParseAnalyzer(*analyzerName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --analyzer: %v\n", err)
+		return exitConfigError
+	}
+
+	repo := openGitOutput(context.Background(), *gitCommit, *outputDir)
+
 	fmt.Println("UE2 Docs - Convert to Markdown")
 	fmt.Println("===============================")
 	fmt.Println()
 	fmt.Printf("Input Dir:           %s\n", *inputDir)
 	fmt.Printf("Output Dir:          %s\n", *outputDir)
+	if *format != "markdown" {
+		fmt.Printf("Formats:             %s\n", formatList(formats))
+	}
 	fmt.Printf("Preserve Structure:  %t\n", *preserveStructure)
+	fmt.Printf("Raw HTML:            %t\n", *rawHTML)
+	switch reflow {
+	case converter.ReflowWidth:
+		fmt.Printf("Reflow:              wrap at %d columns\n", *wrapWidth)
+	case converter.ReflowSentence:
+		fmt.Printf("Reflow:              one sentence per line\n")
+	}
+	if *toc {
+		fmt.Printf("Table of Contents:   h%d-h%d\n", *tocMinLevel, *tocMaxLevel)
+	}
+	if *iconMapFile != "" {
+		fmt.Printf("Icons:               defaults and %s\n", *iconMapFile)
+	} else if *icons {
+		fmt.Printf("Icons:               defaults\n")
+	}
+	if *frontMatter {
+		fmt.Printf("Front Matter:        %t\n", *frontMatter)
+	}
+	if *backlinks {
+		fmt.Printf("Backlinks:           %t\n", *backlinks)
+	}
+	if *headingIDs {
+		fmt.Printf("Heading IDs:         %t\n", *headingIDs)
+	}
+	if *prettyTitles {
+		fmt.Printf("Pretty Titles:       %t\n", *prettyTitles)
+	}
+	if *update {
+		fmt.Printf("Update:              previous versions in %s\n", converter.PreviousDir)
+	}
+	if *changes != "" {
+		fmt.Printf("Changelog:           %s\n", *changes)
+	}
+	switch {
+	case *streamThreshold < 0:
+		fmt.Printf("Streaming:           never\n")
+	case *streamThreshold != converter.DefaultStreamThreshold:
+		fmt.Printf("Streaming:           pages over %d bytes\n", *streamThreshold)
+	}
+	if stamp != converter.StampNone {
+		fmt.Printf("Stamp:               %s\n", stamp)
+	}
+	if *notice != "" {
+		fmt.Printf("Notice:              %s\n", *notice)
+	}
+	if repo != nil {
+		fmt.Printf("Git:                 one commit per run in %s\n", repo.Dir())
+	}
 	fmt.Println()
 
-	// TODO: Initialize and start converter
-	fmt.Println("Converter not yet implemented. See plan.md for implementation roadmap.")
-	os.Exit(0)
+	c := converter.New(converter.Config{
+		InputDir:           *inputDir,
+		OutputDir:          *outputDir,
+		PreserveStructure:  *preserveStructure,
+		RawHTML:            *rawHTML,
+		TOC:                *toc,
+		TOCMinLevel:        *tocMinLevel,
+		TOCMaxLevel:        *tocMaxLevel,
+		Reflow:             reflow,
+		WrapWidth:          *wrapWidth,
+		TrimTrailingSpace:  *trimTrailing,
+		CollapseBlankLines: *collapseBlank,
+		EntityMap:          entityMap,
+		Icons:              *icons,
+		IconMap:            iconMap,
+		QuickReference:     *quickReference,
+		SearchIndex:        *searchIndex,
+		Analyzer:           analyzer,
+		RepairReport:       *repairReport,
+		FrontMatter:        *frontMatter,
+		DatePatterns:       datePatterns,
+		Backlinks:          *backlinks,
+		HeadingIDs:         *headingIDs,
+		PrettyTitles:       *prettyTitles,
+		Update:             *update,
+		Changes:            *changes,
+		Formats:            formats,
+		StreamThreshold:    *streamThreshold,
+		Stamp:              stamp,
+		StampText:          *stampText,
+		Attribution:        *attribution,
+		Notice:             *notice,
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := c.Run(ctx)
+	if ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "Conversion interrupted: %v\n", err)
+		return exitInterrupted
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	fmt.Printf("Converted:    %d\n", result.Converted)
+	fmt.Printf("Copied:       %d\n", result.Copied)
+	fmt.Printf("Failed:       %d\n", result.Failed)
+	if *repairReport != "" {
+		fmt.Printf("Repaired:     %d (%s)\n", result.Repaired, *repairReport)
+	} else {
+		fmt.Printf("Repaired:     %d\n", result.Repaired)
+	}
+	if *backlinks {
+		fmt.Printf("Linked:       %d (pages with backlinks)\n", result.Linked)
+	}
+	if *update {
+		fmt.Printf("Changed:      %d\n", result.Changed)
+	}
+	if *searchIndex != "" {
+		fmt.Printf("Indexed:      %d (%s, %s analyzer)\n", result.Indexed, *searchIndex, analyzer.Name)
+	}
+	if *quickReference != "" {
+		fmt.Printf("References:   %d (%s)\n", result.References, *quickReference)
+	}
+
+	commitOutput(ctx, repo, fmt.Sprintf("Convert %s: %d converted, %d failed", *inputDir, result.Converted, result.Failed), []string{
+		fmt.Sprintf("Converted: %d", result.Converted),
+		fmt.Sprintf("Copied:    %d", result.Copied),
+		fmt.Sprintf("Failed:    %d", result.Failed),
+		fmt.Sprintf("Repaired:  %d", result.Repaired),
+	})
+
+	if result.Failed > 0 {
+		return exitPartialFailure
+	}
+	return exitOK
+}
+
+// formatList joins the names of formats with commas
+func formatList(formats []converter.Format) string {
+	names := make([]string, len(formats))
+	for i, f := range formats {
+		names[i] = f.String()
+	}
+	return strings.Join(names, ", ")
 }
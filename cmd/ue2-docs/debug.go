@@ -0,0 +1,39 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+
+	"github.com/aldehir/ue2-docs/internal/metrics"
+)
+
+// startDebugServer serves net/http/pprof profiles under /debug/pprof/,
+// expvar counters, including memstats, under /debug/vars and the crawl's
+// Prometheus metrics under /metrics on addr, exiting if the address cannot
+// be listened on
+func startDebugServer(addr string, prometheus *metrics.Prometheus) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.Handle("/metrics", prometheus)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --debug-addr: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			fmt.Fprintf(os.Stderr, "Debug server: %v\n", err)
+		}
+	}()
+}
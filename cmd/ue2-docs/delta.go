@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/aldehir/ue2-docs/internal/pack"
+)
+
+func runDelta(args []string) int {
+	fs := flag.NewFlagSet("delta", flag.ExitOnError)
+
+	base := fs.String("base", "", "Archive of the previous release, as written by 'ue2-docs package'")
+	target := fs.String("target", "", "Archive of the new release")
+	output := fs.String("output", "ue2-docs-delta.tar.gz", "Delta archive to write")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: ue2-docs delta --base <archive> --target <archive> [flags]")
+		fmt.Println()
+		fmt.Println("Write a delta archive taking a packaged mirror from one release to the next:")
+		fmt.Println("the files whose content is new or changed, compared by SHA-256, and a list")
+		fmt.Println("of deleted files. Holders of the base release update their mirror with")
+		fmt.Println("'ue2-docs apply' instead of downloading the whole new archive.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Example:")
+		fmt.Println("  ue2-docs delta --base ue2-docs-2026-09.tar.gz --target ue2-docs-2026-10.tar.gz --output ue2-docs-2026-10-delta.tar.gz")
+	}
+
+	fs.Parse(args)
+
+	if *base == "" || *target == "" {
+		fmt.Fprintf(os.Stderr, "Error: --base and --target are required\n")
+		return exitConfigError
+	}
+
+	fmt.Println("UE2 Docs - Delta")
+	fmt.Println("================")
+	fmt.Println()
+	fmt.Printf("Base:         %s\n", *base)
+	fmt.Printf("Target:       %s\n", *target)
+	fmt.Printf("Output:       %s\n", *output)
+	fmt.Println()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := pack.Delta(ctx, *base, *target, *output)
+	if ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "Delta interrupted: %v\n", err)
+		return exitInterrupted
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	fmt.Printf("Files:        %d new or changed\n", result.Files)
+	fmt.Printf("Deleted:      %d\n", result.Deleted)
+	fmt.Printf("Bytes:        %d\n", result.Bytes)
+	fmt.Printf("Archive:      %d bytes\n", result.Size)
+	fmt.Printf("SHA-256:      %s\n", result.SHA256)
+	return exitOK
+}
+
+func runApply(args []string) int {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+
+	delta := fs.String("delta", "", "Delta archive written by 'ue2-docs delta'")
+	dir := fs.String("dir", "./output", "Mirror to update, unpacked from the delta's base release")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: ue2-docs apply --delta <archive> [flags]")
+		fmt.Println()
+		fmt.Println("Update a mirror unpacked from one release to the next with a delta archive:")
+		fmt.Println("new and changed files are written, each checked against its SHA-256, and")
+		fmt.Println("deleted files removed.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Example:")
+		fmt.Println("  ue2-docs apply --delta ue2-docs-2026-10-delta.tar.gz --dir ./ue2-docs-2026-09")
+	}
+
+	fs.Parse(args)
+
+	if *delta == "" {
+		fmt.Fprintf(os.Stderr, "Error: --delta is required\n")
+		return exitConfigError
+	}
+
+	fmt.Println("UE2 Docs - Apply")
+	fmt.Println("================")
+	fmt.Println()
+	fmt.Printf("Delta:        %s\n", *delta)
+	fmt.Printf("Mirror Dir:   %s\n", *dir)
+	fmt.Println()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := pack.Apply(ctx, *delta, *dir)
+	if ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "Apply interrupted: %v\n", err)
+		return exitInterrupted
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	fmt.Printf("Written:      %d\n", result.Written)
+	fmt.Printf("Deleted:      %d\n", result.Deleted)
+	return exitOK
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aldehir/ue2-docs/internal/scraper"
+)
+
+// Exit codes returned by ue2-docs so scripted re-crawls can branch on the outcome
+const (
+	exitOK             = 0 // Every resource was fetched and saved
+	exitError          = 1 // Unexpected runtime error
+	exitConfigError    = 2 // Invalid flags or configuration (matches the flag package)
+	exitPartialFailure = 3 // Crawl finished but some resources failed
+	exitAllFailed      = 4 // Crawl finished without saving anything
+	exitInterrupted    = 5 // Crawl was interrupted before the queue was exhausted
+)
+
+// crawlStatus classifies a crawl result, returning a short status name and
+// the matching exit code
+func crawlStatus(result *scraper.Result, err error) (string, int) {
+	switch {
+	case err != nil:
+		return "interrupted", exitInterrupted
+	case result.Saved == 0 && result.Failed > 0:
+		return "failed", exitAllFailed
+	case result.Failed > 0:
+		return "partial", exitPartialFailure
+	default:
+		return "ok", exitOK
+	}
+}
+
+// summaryLine formats a crawl result as a single line of key=value pairs
+// that is stable for scripts to parse
+func summaryLine(status string, code int, result *scraper.Result) string {
+	return fmt.Sprintf("RESULT status=%s exit=%d visited=%d saved=%d failed=%d bytes=%d duration=%s",
+		status, code, result.Visited, result.Saved, result.Failed, result.Bytes,
+		result.Duration.Round(time.Millisecond))
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/aldehir/ue2-docs/internal/export"
+)
+
+// exportFormats are the formats 'ue2-docs export' writes
+var exportFormats = map[string]func(args []string) int{
+	"html":     runExportHTML,
+	"obsidian": runExportObsidian,
+}
+
+func runExport(args []string) int {
+	if len(args) == 0 || exportFormats[args[0]] == nil {
+		if len(args) > 0 && args[0] != "--help" && args[0] != "-h" {
+			fmt.Fprintf(os.Stderr, "Unknown export format: %s\n\n", args[0])
+		}
+		fmt.Println("Usage: ue2-docs export <format> [flags]")
+		fmt.Println()
+		fmt.Println("Formats:")
+		fmt.Println("  html      Browsable HTML site with a built-in theme")
+		fmt.Println("  obsidian  Obsidian vault with [[wiki links]], an attachments folder and folder index notes")
+		return exitConfigError
+	}
+	return exportFormats[args[0]](args[1:])
+}
+
+func runExportHTML(args []string) int {
+	fs := flag.NewFlagSet("export html", flag.ExitOnError)
+
+	inputDir := fs.String("input", "./markdown", "Directory of Markdown converted by 'ue2-docs convert'")
+	outputDir := fs.String("output", "./site", "Output directory for the HTML site")
+	title := fs.String("title", export.DefaultTitle, "Site title shown in the header of every page")
+	home := fs.String("home", "", "Page the header links to, relative to the input directory, e.g. udn.epicgames.com/Two/WebHome.md (default: a generated index of every page)")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: ue2-docs export html [flags]")
+		fmt.Println()
+		fmt.Println("Render converted Markdown as a static HTML site styled by a built-in")
+		fmt.Println("theme, readable in a browser without MkDocs or Hugo. Images and other")
+		fmt.Println("files are copied alongside the pages.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Example:")
+		fmt.Println("  ue2-docs export html --input ./docs --output ./site --home udn.epicgames.com/Two/WebHome.md")
+	}
+
+	fs.Parse(args)
+
+	fmt.Println("UE2 Docs - Export HTML")
+	fmt.Println("======================")
+	fmt.Println()
+	fmt.Printf("Input Dir:    %s\n", *inputDir)
+	fmt.Printf("Output Dir:   %s\n", *outputDir)
+	fmt.Println()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := export.New(export.Config{
+		InputDir:  *inputDir,
+		OutputDir: *outputDir,
+		Title:     *title,
+		Home:      *home,
+	}).Run(ctx)
+	if ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "Export interrupted: %v\n", err)
+		return exitInterrupted
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	fmt.Printf("Pages:        %d\n", result.Pages)
+	fmt.Printf("Assets:       %d\n", result.Assets)
+	return exitOK
+}
+
+func runExportObsidian(args []string) int {
+	fs := flag.NewFlagSet("export obsidian", flag.ExitOnError)
+
+	inputDir := fs.String("input", "./markdown", "Directory of Markdown converted by 'ue2-docs convert'")
+	outputDir := fs.String("output", "./vault", "Vault directory to write, e.g. a folder inside an existing vault")
+	attachments := fs.String("attachments", export.DefaultAttachments, "Vault folder for images and other files")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: ue2-docs export obsidian [flags]")
+		fmt.Println()
+		fmt.Println("Write converted Markdown as an Obsidian vault. Links between pages become")
+		fmt.Println("[[wiki links]], images move to an attachments folder and are embedded,")
+		fmt.Println("and every folder gets an index note listing its pages and subfolders.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Example:")
+		fmt.Println("  ue2-docs export obsidian --input ./docs --output ~/Notes/UE2")
+	}
+
+	fs.Parse(args)
+
+	fmt.Println("UE2 Docs - Export Obsidian Vault")
+	fmt.Println("================================")
+	fmt.Println()
+	fmt.Printf("Input Dir:    %s\n", *inputDir)
+	fmt.Printf("Vault:        %s\n", *outputDir)
+	fmt.Println()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := export.NewVault(export.VaultConfig{
+		InputDir:    *inputDir,
+		OutputDir:   *outputDir,
+		Attachments: *attachments,
+	}).Run(ctx)
+	if ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "Export interrupted: %v\n", err)
+		return exitInterrupted
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	fmt.Printf("Notes:        %d\n", result.Pages)
+	fmt.Printf("Attachments:  %d\n", result.Assets)
+	fmt.Printf("Index Notes:  %d\n", result.Sections)
+	return exitOK
+}
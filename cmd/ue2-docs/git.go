@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aldehir/ue2-docs/internal/gitrepo"
+)
+
+// gitUsage describes the --git flag shared by commands that write an
+// output directory
+const gitUsage = "Commit the output directory to a git repository after each run, one commit per run with a summary message (initialized in the output directory if needed)"
+
+// openGitOutput opens the --git repository of the output directory,
+// exiting on failure. Returns nil if --git was not given
+func openGitOutput(ctx context.Context, enabled bool, dir string) *gitrepo.Repo {
+	if !enabled {
+		return nil
+	}
+	repo, err := gitrepo.Open(ctx, dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --git: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+	return repo
+}
+
+// commitOutput commits a run's output with a message made of a subject
+// and the run's summary lines
+func commitOutput(ctx context.Context, repo *gitrepo.Repo, subject string, summary []string) {
+	if repo == nil {
+		return
+	}
+	message := subject + "\n\n" + strings.Join(summary, "\n") + "\n"
+	hash, err := repo.Commit(ctx, message)
+	switch {
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Error: committing output: %v\n", err)
+	case hash == "":
+		fmt.Printf("Git:          no changes to commit\n")
+	default:
+		fmt.Printf("Git:          committed %.12s to %s\n", hash, repo.Dir())
+	}
+}
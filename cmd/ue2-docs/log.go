@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// newLogger builds the crawl logger from the --log-format and --log-level
+// flags, exiting on invalid values. It also becomes the default logger, so
+// plain log output shares its format
+func newLogger(format, level string) *slog.Logger {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --log-level: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+	options := &slog.HandlerOptions{Level: lvl}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, options)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	default:
+		fmt.Fprintf(os.Stderr, "Error: --log-format: unknown format %q (want text or json)\n", format)
+		os.Exit(exitConfigError)
+	}
+
+	logger := slog.New(handler)
+	slog.SetDefault(logger)
+	return logger
+}
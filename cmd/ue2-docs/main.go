@@ -8,23 +8,49 @@ import (
 func main() {
 	if len(os.Args) < 2 {
 		printUsage()
-		os.Exit(1)
+		os.Exit(exitConfigError)
 	}
 
 	command := os.Args[1]
 
 	switch command {
 	case "scrape":
-		runScrape(os.Args[2:])
+		os.Exit(runScrape(os.Args[2:]))
 	case "convert":
-		runConvert(os.Args[2:])
+		os.Exit(runConvert(os.Args[2:]))
+	case "stats":
+		os.Exit(runStats(os.Args[2:]))
+	case "optimize":
+		os.Exit(runOptimize(os.Args[2:]))
+	case "repair":
+		os.Exit(runRepair(os.Args[2:]))
+	case "verify":
+		os.Exit(runVerify(os.Args[2:]))
+	case "rewrite":
+		os.Exit(runRewrite(os.Args[2:]))
+	case "export":
+		os.Exit(runExport(os.Args[2:]))
+	case "merge":
+		os.Exit(runMerge(os.Args[2:]))
+	case "search":
+		os.Exit(runSearch(os.Args[2:]))
+	case "open":
+		os.Exit(runOpen(os.Args[2:]))
+	case "probe":
+		os.Exit(runProbe(os.Args[2:]))
+	case "package":
+		os.Exit(runPackage(os.Args[2:]))
+	case "delta":
+		os.Exit(runDelta(os.Args[2:]))
+	case "apply":
+		os.Exit(runApply(os.Args[2:]))
 	case "help", "--help", "-h":
 		printUsage()
-		os.Exit(0)
+		os.Exit(exitOK)
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", command)
 		printUsage()
-		os.Exit(1)
+		os.Exit(exitConfigError)
 	}
 }
 
@@ -37,7 +63,28 @@ func printUsage() {
 	fmt.Println("Commands:")
 	fmt.Println("  scrape    Scrape documentation from a website")
 	fmt.Println("  convert   Convert scraped HTML to Markdown")
+	fmt.Println("  stats     Print statistics from a crawl state file")
+	fmt.Println("  optimize  Losslessly shrink images in a scraped mirror")
+	fmt.Println("  repair    Re-download missing or damaged assets of a scraped mirror")
+	fmt.Println("  verify    Check a scraped mirror against its manifest")
+	fmt.Println("  rewrite   Rewrite the links of saved pages again, or preview with --diff")
+	fmt.Println("  export    Export converted Markdown, as a browsable HTML site or an Obsidian vault")
+	fmt.Println("  merge     Merge partial mirrors into one, checking that the files they share agree")
+	fmt.Println("  search    Search the converted docs for pages matching a query")
+	fmt.Println("  open      Open the mirror's copy of a page, by URL or topic, in the browser")
+	fmt.Println("  probe     Print the status, type and size of each of a list of URLs")
+	fmt.Println("  package   Package a mirror as a reproducible archive, optionally publishing it to IPFS")
+	fmt.Println("  delta     Write an archive of the changes between two packaged releases")
+	fmt.Println("  apply     Update a mirror to a newer release with a delta archive")
 	fmt.Println("  help      Show this help message")
 	fmt.Println()
 	fmt.Println("Run 'ue2-docs <command> --help' for command-specific options.")
+	fmt.Println()
+	fmt.Println("Exit codes:")
+	fmt.Println("  0  success")
+	fmt.Println("  1  unexpected error")
+	fmt.Println("  2  invalid flags or configuration")
+	fmt.Println("  3  partial failure (some resources failed)")
+	fmt.Println("  4  all resources failed")
+	fmt.Println("  5  interrupted")
 }
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/aldehir/ue2-docs/internal/merge"
+)
+
+func runMerge(args []string) int {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+
+	into := fs.String("into", "", "Mirror to merge into, created if missing")
+	newest := fs.Bool("newest", false, "Settle files the mirrors hold different copies of by keeping the copy saved last, rather than failing")
+	signKeyFile := fs.String("sign-key", "", signKeyUsage)
+
+	fs.Usage = func() {
+		fmt.Println("Usage: ue2-docs merge --into <dir> [flags] <mirror>...")
+		fmt.Println()
+		fmt.Println("Merge partial mirrors, such as those scraped with --sections or by")
+		fmt.Println("partial re-crawls, into one, copying their files and writing the union")
+		fmt.Println("of their manifests. Files held by several mirrors must be identical by")
+		fmt.Println("SHA-256 unless --newest is given; nothing is written otherwise.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Example:")
+		fmt.Println("  ue2-docs merge --into ./full ./part-api ./part-editor")
+	}
+
+	mirrors := parseInterspersed(fs, args)
+	if *into == "" || len(mirrors) == 0 {
+		fs.Usage()
+		return exitConfigError
+	}
+
+	signKey := loadSignKey(*signKeyFile)
+
+	fmt.Println("UE2 Docs - Merge Mirrors")
+	fmt.Println("========================")
+	fmt.Println()
+	fmt.Printf("Into:         %s\n", *into)
+	fmt.Printf("Mirrors:      %s\n", strings.Join(mirrors, ", "))
+	if *newest {
+		fmt.Printf("On Conflict:  newest copy kept\n")
+	}
+	printSignKey(signKey)
+	fmt.Println()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := merge.Merge(ctx, merge.Config{
+		Into:    *into,
+		Mirrors: mirrors,
+		Newest:  *newest,
+		SignKey: signKey,
+	})
+	if result == nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	for _, c := range result.Conflicts {
+		if *newest {
+			fmt.Printf("Conflict:     %s (kept %s, not %s)\n", c.Path, c.Mirrors[0], c.Mirrors[1])
+		} else {
+			fmt.Printf("Conflict:     %s (%s and %s differ)\n", c.Path, c.Mirrors[0], c.Mirrors[1])
+		}
+	}
+	fmt.Printf("Copied:       %d\n", result.Copied)
+	fmt.Printf("Identical:    %d\n", result.Identical)
+	fmt.Printf("Conflicts:    %d\n", len(result.Conflicts))
+	fmt.Printf("Bytes:        %d\n", result.Bytes)
+
+	switch {
+	case ctx.Err() != nil:
+		fmt.Fprintf(os.Stderr, "Merge interrupted: %v\n", err)
+		return exitInterrupted
+	case errors.Is(err, merge.ErrConflicts):
+		fmt.Fprintf(os.Stderr, "Error: %v; nothing was merged (see --newest)\n", err)
+		return exitError
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/aldehir/ue2-docs/internal/manifest"
+	"github.com/aldehir/ue2-docs/internal/storage"
+	"github.com/aldehir/ue2-docs/internal/urlutil"
+)
+
+func runOpen(args []string) int {
+	fs := flag.NewFlagSet("open", flag.ExitOnError)
+
+	inputDir := fs.String("input", "./output", "Mirror directory containing scraped content and manifest.json")
+	version := fs.String("version", "", "Documentation version to pick the page from, when the topic exists in several")
+	printOnly := fs.Bool("print", false, "Print the page's local path instead of opening it")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: ue2-docs open [flags] <topic-or-url>")
+		fmt.Println()
+		fmt.Println("Open the mirror's copy of a page in the default browser. The page is given")
+		fmt.Println("by its original URL (or a Wayback Machine snapshot of it), its path in the")
+		fmt.Println("mirror, or its TWiki topic name as Topic or Web.Topic, in any case.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Example:")
+		fmt.Println("  ue2-docs open PhysicsVolume --input ./scraped")
+		fmt.Println("  ue2-docs open http://udn.epicgames.com/Two/UnrealScriptReference.html")
+	}
+
+	refs := parseInterspersed(fs, args)
+	if len(refs) != 1 {
+		fs.Usage()
+		return exitConfigError
+	}
+
+	m, err := manifest.LoadDir(*inputDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitConfigError
+	}
+
+	entries := lookupPage(m, *inputDir, refs[0])
+	if *version != "" {
+		var inVersion []manifest.Entry
+		for _, e := range entries {
+			if e.Version == *version {
+				inVersion = append(inVersion, e)
+			}
+		}
+		entries = inVersion
+	}
+	switch len(entries) {
+	case 0:
+		fmt.Fprintf(os.Stderr, "Error: no page of %s matches %q\n", *inputDir, refs[0])
+		return exitError
+	case 1:
+	default:
+		fmt.Fprintf(os.Stderr, "Error: %d pages match %q; give a Web.Topic, URL or path:\n", len(entries), refs[0])
+		for _, e := range entries {
+			fmt.Fprintf(os.Stderr, "  %s\n", e.Path)
+		}
+		return exitConfigError
+	}
+
+	entry := entries[0]
+	file, err := filepath.Abs(filepath.Join(*inputDir, filepath.FromSlash(entry.File())))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	if *printOnly {
+		fmt.Println(file)
+		return exitOK
+	}
+	if entry.Encoding != "" {
+		fmt.Fprintf(os.Stderr, "Warning: %s is stored compressed; browsers may download it rather than show it\n", entry.Path)
+	}
+	if err := openBrowser((&url.URL{Scheme: "file", Path: filepath.ToSlash(file)}).String()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: opening %s: %v\n", file, err)
+		return exitError
+	}
+	fmt.Println(file)
+	return exitOK
+}
+
+// lookupPage returns the manifest entries a page reference names, trying
+// a URL as given and then normalized as the crawl saves URLs, and a file
+// within the mirror at root by its path in the mirror
+func lookupPage(m *manifest.Manifest, root, ref string) []manifest.Entry {
+	if !strings.Contains(ref, "://") {
+		return m.Lookup(mirrorPath(root, ref))
+	}
+
+	ref = urlutil.UnwrapWayback(ref)
+	if entries := m.Lookup(ref); len(entries) > 0 {
+		return entries
+	}
+	normalized, err := urlutil.Normalize(ref, "")
+	if err != nil {
+		return nil
+	}
+	if entries := m.Lookup(normalized); len(entries) > 0 {
+		return entries
+	}
+	// A mirror saved under an index name, such as "Two/" as Two/index.html
+	if rel, err := storage.PathFor(normalized); err == nil {
+		return m.Lookup(rel)
+	}
+	return nil
+}
+
+// openBrowser opens target in the desktop's default browser
+func openBrowser(target string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", target)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		cmd = exec.Command("xdg-open", target)
+	}
+	return cmd.Start()
+}
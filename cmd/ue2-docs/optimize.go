@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/aldehir/ue2-docs/internal/optimize"
+)
+
+func runOptimize(args []string) int {
+	fs := flag.NewFlagSet("optimize", flag.ExitOnError)
+
+	inputDir := fs.String("input", "./output", "Mirror directory containing scraped content and manifest.json")
+	convertBMP := fs.Bool("convert-bmp", false, "Convert BMP images to PNG and rewrite references to them")
+	signKeyFile := fs.String("sign-key", "", signKeyUsage)
+	thumbnailWidth := fs.Int("thumbnail-width", 0, "Replace inline images wider than this many pixels with linked thumbnails (0 = disabled)")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: ue2-docs optimize [flags]")
+		fmt.Println()
+		fmt.Println("Losslessly shrink the images in a scraped mirror, optionally generating")
+		fmt.Println("thumbnails for oversized inline images, and update its manifest.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Example:")
+		fmt.Println("  ue2-docs optimize --input ./scraped --convert-bmp")
+	}
+
+	fs.Parse(args)
+
+	signKey := loadSignKey(*signKeyFile)
+
+	fmt.Println("UE2 Docs - Optimize Images")
+	fmt.Println("==========================")
+	fmt.Println()
+	fmt.Printf("Input Dir:    %s\n", *inputDir)
+	fmt.Printf("Convert BMP:  %t\n", *convertBMP)
+	if *thumbnailWidth > 0 {
+		fmt.Printf("Thumbnails:   wider than %dpx\n", *thumbnailWidth)
+	}
+	printSignKey(signKey)
+	fmt.Println()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := optimize.New(optimize.Config{
+		Root:           *inputDir,
+		ConvertBMP:     *convertBMP,
+		ThumbnailWidth: *thumbnailWidth,
+		SignKey:        signKey,
+	}).Run(ctx)
+	if ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "Optimization interrupted: %v\n", err)
+		return exitInterrupted
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	fmt.Printf("Optimized:    %d\n", result.Optimized)
+	fmt.Printf("Converted:    %d\n", result.Converted)
+	fmt.Printf("Unchanged:    %d\n", result.Unchanged)
+	fmt.Printf("Thumbnails:   %d\n", result.Thumbnails)
+	fmt.Printf("Rewritten:    %d pages\n", result.Rewritten)
+	fmt.Printf("Failed:       %d\n", result.Failed)
+	fmt.Printf("Bytes Saved:  %d\n", result.BytesSaved)
+
+	if result.Failed > 0 {
+		return exitPartialFailure
+	}
+	return exitOK
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/aldehir/ue2-docs/internal/ipfs"
+	"github.com/aldehir/ue2-docs/internal/pack"
+	"github.com/aldehir/ue2-docs/internal/torrent"
+)
+
+func runPackage(args []string) int {
+	fs := flag.NewFlagSet("package", flag.ExitOnError)
+
+	inputDir := fs.String("input", "./output", "Mirror or converted docs directory to package")
+	output := fs.String("output", "ue2-docs.tar.gz", "Archive to write")
+	prefix := fs.String("prefix", "", "Directory to place the files under inside the archive (default: the archive's name)")
+	addIPFS := fs.Bool("ipfs", false, "Add the archive to a local IPFS node and print its CID")
+	ipfsAPI := fs.String("ipfs-api", ipfs.DefaultAPI, "RPC API address of the IPFS node used by --ipfs")
+	carFile := fs.String("car", "", "Also write the archive as a CAR file, for importing into any IPFS node or pinning service")
+	torrentFile := fs.String("torrent", "", "Also write a .torrent file for distributing the archive peer to peer")
+	trackers := fs.String("tracker", "", "Comma-separated list of tracker announce URLs for --torrent")
+	webSeeds := fs.String("webseed", "", "Comma-separated list of URLs the archive is served from, added to --torrent as web seeds")
+	pieceLength := fs.Int64("piece-length", 0, "Torrent piece length in bytes (0 = chosen from the archive size)")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: ue2-docs package [flags]")
+		fmt.Println()
+		fmt.Println("Package a mirror as a reproducible .tar.gz archive. Hidden files, such as")
+		fmt.Println("kept originals, are left out. With --ipfs or --car, the archive is also")
+		fmt.Println("published to IPFS for decentralized preservation and its CID printed.")
+		fmt.Println("With --torrent, a .torrent file is written for peer to peer distribution.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Example:")
+		fmt.Println("  ue2-docs package --input ./output --output ue2-docs-2026.tar.gz --ipfs")
+		fmt.Println("  ue2-docs package --output ue2-docs.tar.gz --torrent ue2-docs.torrent \\")
+		fmt.Println("    --tracker udp://tracker.opentrackr.org:1337/announce --webseed https://example.com/ue2-docs.tar.gz")
+	}
+
+	fs.Parse(args)
+
+	if *torrentFile == "" && (*trackers != "" || *webSeeds != "" || *pieceLength != 0) {
+		fmt.Fprintf(os.Stderr, "Error: --tracker, --webseed and --piece-length require --torrent\n")
+		return exitConfigError
+	}
+
+	fmt.Println("UE2 Docs - Package")
+	fmt.Println("==================")
+	fmt.Println()
+	fmt.Printf("Input Dir:    %s\n", *inputDir)
+	fmt.Printf("Output:       %s\n", *output)
+	if *addIPFS {
+		fmt.Printf("IPFS:         %s\n", *ipfsAPI)
+	}
+	if *carFile != "" {
+		fmt.Printf("CAR:          %s\n", *carFile)
+	}
+	if *torrentFile != "" {
+		fmt.Printf("Torrent:      %s\n", *torrentFile)
+	}
+	fmt.Println()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := pack.New(pack.Config{
+		InputDir: *inputDir,
+		Output:   *output,
+		Prefix:   *prefix,
+	}).Run(ctx)
+	if ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "Packaging interrupted: %v\n", err)
+		return exitInterrupted
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	fmt.Printf("Files:        %d\n", result.Files)
+	fmt.Printf("Bytes:        %d\n", result.Bytes)
+	fmt.Printf("Archive:      %d bytes\n", result.Size)
+	fmt.Printf("SHA-256:      %s\n", result.SHA256)
+
+	if *carFile != "" {
+		cid, err := writeCAR(*output, *carFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitError
+		}
+		fmt.Printf("CID:          %s (%s)\n", cid, *carFile)
+	}
+	if *torrentFile != "" {
+		tor, err := torrent.Create(*output, torrent.Config{
+			Trackers:    splitList(*trackers),
+			WebSeeds:    splitList(*webSeeds),
+			PieceLength: *pieceLength,
+		})
+		if err == nil {
+			err = tor.WriteFile(*torrentFile)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitError
+		}
+		fmt.Printf("Info Hash:    %s\n", tor.InfoHash())
+		fmt.Printf("Magnet:       %s\n", tor.Magnet())
+	}
+	if *addIPFS {
+		cid, err := ipfs.NewNode(*ipfsAPI).AddFile(ctx, *output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitError
+		}
+		fmt.Printf("CID:          %s (pinned)\n", cid)
+	}
+	return exitOK
+}
+
+// writeCAR writes the archive to a CAR file, returning its CID
+func writeCAR(archive, car string) (ipfs.CID, error) {
+	in, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	out, err := os.Create(car)
+	if err != nil {
+		return nil, fmt.Errorf("creating %s: %w", car, err)
+	}
+	cid, err := ipfs.WriteCAR(out, in)
+	if err != nil {
+		out.Close()
+		os.Remove(car)
+		return nil, fmt.Errorf("writing %s: %w", car, err)
+	}
+	if err := out.Close(); err != nil {
+		return nil, fmt.Errorf("writing %s: %w", car, err)
+	}
+	return cid, nil
+}
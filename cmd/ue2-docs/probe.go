@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aldehir/ue2-docs/internal/fetcher"
+	"github.com/aldehir/ue2-docs/internal/probe"
+)
+
+func runProbe(args []string) int {
+	fs := flag.NewFlagSet("probe", flag.ExitOnError)
+
+	urlsFile := fs.String("urls", "", "File listing the URLs to probe, one per line, # starting a comment (- = standard input)")
+	workers := fs.Int("workers", 8, "Number of concurrent requests")
+	rate := fs.Int("rate", 2, "Maximum requests per second (0 = unlimited)")
+	maxRetries := fs.Int("max-retries", fetcher.DefaultConfig().MaxRetries, "Times to retry a failed request")
+	timeout := fs.Duration("timeout", 15*time.Second, "Timeout of each request")
+	jsonOutput := fs.Bool("json", false, "Print the results as a JSON array instead of a table")
+	lenientHTTP := fs.Bool("lenient-http", false, lenientHTTPUsage)
+	userAgent := fs.String("user-agent", "", userAgentUsage)
+	contact := fs.String("contact", "", contactUsage)
+	userAgentRotation := fs.String("user-agent-rotation", "none", userAgentRotationUsage)
+
+	fs.Usage = func() {
+		fmt.Println("Usage: ue2-docs probe --urls <file> [flags]")
+		fmt.Println()
+		fmt.Println("Ask for the status of a list of URLs with HEAD requests, or GETs where")
+		fmt.Println("HEAD is refused, printing the status, content type and size of each,")
+		fmt.Println("e.g. to check which old links in a forum thread still resolve.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Example:")
+		fmt.Println("  ue2-docs probe --urls thread-links.txt --rate 1")
+	}
+
+	fs.Parse(args)
+
+	if *urlsFile == "" {
+		fs.Usage()
+		return exitConfigError
+	}
+	urls, err := readURLs(*urlsFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitConfigError
+	}
+	if len(urls) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: no URLs in %s\n", *urlsFile)
+		return exitConfigError
+	}
+
+	agents, rotation := parseUserAgents(*userAgent, *userAgentRotation)
+
+	// The table alone goes to standard output with --json, so it parses
+	if !*jsonOutput {
+		fmt.Println("UE2 Docs - Probe URLs")
+		fmt.Println("=====================")
+		fmt.Println()
+		fmt.Printf("URLs:         %d (from %s)\n", len(urls), *urlsFile)
+		fmt.Printf("Workers:      %d\n", *workers)
+		if *rate > 0 {
+			fmt.Printf("Rate:         %d requests/s\n", *rate)
+		}
+		if *lenientHTTP {
+			fmt.Printf("HTTP:         lenient (malformed responses rescued)\n")
+		}
+		printUserAgents(agents, rotation, *contact)
+		fmt.Println()
+	}
+
+	config := fetcher.DefaultConfig()
+	config.Timeout = *timeout
+	config.MaxRetries = *maxRetries
+	config.Lenient = *lenientHTTP
+	setUserAgents(&config, agents, rotation, *contact)
+	if *rate > 0 {
+		limiter := fetcher.NewSimpleRateLimiter(*rate, time.Second)
+		defer limiter.Stop()
+		config.RateLimiter = limiter
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	results := probe.New(probe.Config{Workers: *workers, Fetcher: config}).ProbeAll(ctx, urls)
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if results == nil {
+			results = []probe.Result{}
+		}
+		if err := enc.Encode(results); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitError
+		}
+	} else {
+		printProbeResults(results)
+	}
+
+	summary := probe.Summarize(results)
+	if !*jsonOutput {
+		fmt.Println()
+		fmt.Printf("OK:           %d\n", summary.OK)
+		fmt.Printf("Missing:      %d (404 or 410)\n", summary.Missing)
+		fmt.Printf("Other:        %d\n", summary.Other)
+		fmt.Printf("Failed:       %d (no response)\n", summary.Failed)
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		fmt.Fprintf(os.Stderr, "Probe interrupted: %d of %d URLs probed\n", len(results), len(urls))
+		return exitInterrupted
+	case summary.OK == 0:
+		return exitAllFailed
+	case summary.OK < len(results):
+		return exitPartialFailure
+	}
+	return exitOK
+}
+
+// readURLs reads the URL list at path, or standard input for "-"
+func readURLs(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	urls, err := probe.ReadURLs(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return urls, nil
+}
+
+// printProbeResults prints a table of the status, content type and size
+// of each URL, followed by where it redirected to or why it failed
+func printProbeResults(results []probe.Result) {
+	typeWidth := len("TYPE")
+	for _, r := range results {
+		typeWidth = max(typeWidth, len(mediaType(r.ContentType)))
+	}
+	fmt.Printf("%-6s  %-*s  %10s  %s\n", "STATUS", typeWidth, "TYPE", "SIZE", "URL")
+	for _, r := range results {
+		status := "-"
+		if r.StatusCode != 0 {
+			status = strconv.Itoa(r.StatusCode)
+		}
+		contentType := mediaType(r.ContentType)
+		if contentType == "" {
+			contentType = "-"
+		}
+		size := "-"
+		if r.Size >= 0 {
+			size = strconv.FormatInt(r.Size, 10)
+		}
+		fmt.Printf("%-6s  %-*s  %10s  %s\n", status, typeWidth, contentType, size, r.URL)
+		switch {
+		case r.Error != "":
+			fmt.Printf("%6s  error: %s\n", "", r.Error)
+		case r.FinalURL != "":
+			fmt.Printf("%6s  -> %s\n", "", r.FinalURL)
+		}
+	}
+}
+
+// mediaType returns a Content-Type without its parameters
+func mediaType(contentType string) string {
+	mt, _, _ := strings.Cut(contentType, ";")
+	return strings.TrimSpace(mt)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/aldehir/ue2-docs/internal/fetcher"
+	"github.com/aldehir/ue2-docs/internal/repair"
+)
+
+func runRepair(args []string) int {
+	fs := flag.NewFlagSet("repair", flag.ExitOnError)
+
+	inputDir := fs.String("input", "./output", "Mirror directory containing scraped content and manifest.json")
+	workers := fs.Int("workers", 4, "Number of concurrent downloads")
+	rate := fs.Int("rate", 2, "Maximum requests per second (0 = unlimited)")
+	lenientHTTP := fs.Bool("lenient-http", false, lenientHTTPUsage)
+	userAgent := fs.String("user-agent", "", userAgentUsage)
+	contact := fs.String("contact", "", contactUsage)
+	userAgentRotation := fs.String("user-agent-rotation", "none", userAgentRotationUsage)
+	signKeyFile := fs.String("sign-key", "", signKeyUsage)
+
+	fs.Usage = func() {
+		fmt.Println("Usage: ue2-docs repair [flags]")
+		fmt.Println()
+		fmt.Println("Check a mirror against its manifest and re-download assets that are")
+		fmt.Println("missing or damaged, e.g. after an interrupted copy, without crawling")
+		fmt.Println("its pages again. Broken HTML pages are reported but not fetched.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Example:")
+		fmt.Println("  ue2-docs repair --input ./scraped --rate 1")
+	}
+
+	fs.Parse(args)
+
+	signKey := loadSignKey(*signKeyFile)
+	agents, rotation := parseUserAgents(*userAgent, *userAgentRotation)
+
+	fmt.Println("UE2 Docs - Repair Mirror")
+	fmt.Println("========================")
+	fmt.Println()
+	fmt.Printf("Input Dir:    %s\n", *inputDir)
+	fmt.Printf("Workers:      %d\n", *workers)
+	if *rate > 0 {
+		fmt.Printf("Rate:         %d requests/s\n", *rate)
+	}
+	if *lenientHTTP {
+		fmt.Printf("HTTP:         lenient (malformed responses rescued)\n")
+	}
+	printUserAgents(agents, rotation, *contact)
+	printSignKey(signKey)
+	fmt.Println()
+
+	config := fetcher.DefaultConfig()
+	config.Lenient = *lenientHTTP
+	setUserAgents(&config, agents, rotation, *contact)
+	if *rate > 0 {
+		limiter := fetcher.NewSimpleRateLimiter(*rate, time.Second)
+		defer limiter.Stop()
+		config.RateLimiter = limiter
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := repair.New(repair.Config{
+		Root:    *inputDir,
+		Workers: *workers,
+		Fetcher: config,
+		SignKey: signKey,
+	}).Run(ctx)
+	if result == nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	fmt.Printf("Checked:      %d\n", result.Checked)
+	fmt.Printf("Missing:      %d\n", result.Missing)
+	fmt.Printf("Damaged:      %d\n", result.Damaged)
+	fmt.Printf("Repaired:     %d\n", result.Repaired)
+	fmt.Printf("Skipped:      %d (pages and generated files; re-scrape to restore)\n", result.Skipped)
+	fmt.Printf("Failed:       %d\n", result.Failed)
+	fmt.Printf("Bytes:        %d\n", result.Bytes)
+
+	switch {
+	case ctx.Err() != nil:
+		fmt.Fprintf(os.Stderr, "Repair interrupted: %v\n", err)
+		return exitInterrupted
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	case result.Failed > 0 || result.Skipped > 0:
+		return exitPartialFailure
+	}
+	return exitOK
+}
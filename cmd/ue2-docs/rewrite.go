@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/aldehir/ue2-docs/internal/rewrite"
+)
+
+func runRewrite(args []string) int {
+	fs := flag.NewFlagSet("rewrite", flag.ExitOnError)
+
+	inputDir := fs.String("input", "./output", "Mirror directory containing scraped content and manifest.json")
+	diff := fs.Bool("diff", false, "Print a unified diff of the changes to each page instead of writing them")
+	forms := fs.String("forms", "disable", formsUsage)
+	recordOriginalURLs := fs.Bool("record-original-urls", false, "Keep the URL of every rewritten link in a data-original-href (or -src) attribute")
+	clean := fs.Bool("clean", false, "Remove the data-original- attributes recorded by --record-original-urls")
+	signKeyFile := fs.String("sign-key", "", signKeyUsage)
+
+	fs.Usage = func() {
+		fmt.Println("Usage: ue2-docs rewrite [flags] [page...]")
+		fmt.Println()
+		fmt.Println("Rewrite the links of saved pages again, from the unmodified copies kept")
+		fmt.Println("by 'scrape --keep-original' where available. Pages are paths in the")
+		fmt.Println("mirror; without any, every page is rewritten. Thumbnails added by")
+		fmt.Println("'optimize' are lost on pages rewritten from originals; run it again.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Example:")
+		fmt.Println("  ue2-docs rewrite --input ./scraped --diff ./scraped/udn.epicgames.com/Two/WebHome.html")
+	}
+
+	fs.Parse(args)
+
+	var pages []string
+	for _, arg := range fs.Args() {
+		pages = append(pages, mirrorPath(*inputDir, arg))
+	}
+
+	formPolicy := parseFormPolicy(*forms)
+	originals := rewrite.OriginalsKeep
+	switch {
+	case *recordOriginalURLs && *clean:
+		fmt.Fprintln(os.Stderr, "Error: --record-original-urls and --clean are mutually exclusive")
+		return exitConfigError
+	case *recordOriginalURLs:
+		originals = rewrite.OriginalsRecord
+	case *clean:
+		originals = rewrite.OriginalsStrip
+	}
+	signKey := loadSignKey(*signKeyFile)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	config := rewrite.Config{
+		Root:      *inputDir,
+		Pages:     pages,
+		SignKey:   signKey,
+		Forms:     formPolicy,
+		Originals: originals,
+	}
+	if *diff {
+		// The diff is the output; keep it free of headers
+		config.DryRun = true
+		config.Diff = os.Stdout
+	} else {
+		fmt.Println("UE2 Docs - Rewrite Links")
+		fmt.Println("========================")
+		fmt.Println()
+		fmt.Printf("Input Dir:    %s\n", *inputDir)
+		if len(pages) > 0 {
+			fmt.Printf("Pages:        %d\n", len(pages))
+		}
+		printSignKey(signKey)
+		fmt.Println()
+	}
+
+	result, err := rewrite.New(config).Run(ctx)
+	if result == nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	if !*diff {
+		fmt.Printf("Checked:      %d\n", result.Checked)
+		fmt.Printf("Changed:      %d\n", result.Changed)
+		fmt.Printf("Unchanged:    %d\n", result.Unchanged)
+		fmt.Printf("Failed:       %d\n", result.Failed)
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		fmt.Fprintf(os.Stderr, "Rewrite interrupted: %v\n", err)
+		return exitInterrupted
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	case result.Failed > 0:
+		return exitPartialFailure
+	}
+	return exitOK
+}
+
+// mirrorPath returns the storage path of a page given either as a path in
+// the mirror or as a file path under the mirror directory
+func mirrorPath(root, arg string) string {
+	if rel, err := filepath.Rel(root, arg); err == nil && !strings.HasPrefix(rel, "..") {
+		if _, err := os.Stat(arg); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(arg)
+}
@@ -1,19 +1,103 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"expvar"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aldehir/ue2-docs/internal/fetcher"
+	"github.com/aldehir/ue2-docs/internal/hooks"
+	"github.com/aldehir/ue2-docs/internal/linkcheck"
+	"github.com/aldehir/ue2-docs/internal/metrics"
+	"github.com/aldehir/ue2-docs/internal/parser"
+	"github.com/aldehir/ue2-docs/internal/s3"
+	"github.com/aldehir/ue2-docs/internal/scraper"
+	"github.com/aldehir/ue2-docs/internal/state"
+	"github.com/aldehir/ue2-docs/internal/storage"
+	"github.com/aldehir/ue2-docs/internal/urlutil"
+	"github.com/aldehir/ue2-docs/internal/wayback"
 )
 
-func runScrape(args []string) {
+func runScrape(args []string) int {
 	fs := flag.NewFlagSet("scrape", flag.ExitOnError)
 
-	rootURL := fs.String("root-url", "https://docs.unrealengine.com/udk/Two/SiteMap.html", "Starting URL to scrape")
-	outputDir := fs.String("output", "./output", "Output directory for scraped content")
-	workers := fs.Int("workers", 10, "Number of concurrent workers")
-	whitelist := fs.String("whitelist", "", "Comma-separated list of additional domains to allow")
+	rootURL := fs.String("root-url", "https://docs.unrealengine.com/udk/Two/SiteMap.html", "Starting URL to scrape; a web.archive.org/web/<timestamp>/<url> snapshot mirrors the archived site")
+	outputDir := fs.String("output", "./output", "Output directory for scraped content, or s3://bucket/prefix to write to an S3 bucket (see "+s3EnvUsage+")")
+	s3PartSize := fs.Int64("s3-part-size", s3.DefaultConfig().PartSize, "For s3:// output, upload files larger than this many bytes in parts of this size (minimum 5 MiB)")
+	s3Concurrency := fs.Int("s3-concurrency", s3.DefaultConfig().Concurrency, "For s3:// output, maximum upload requests in flight at once, each holding at most one part in memory")
+	workers := fs.Int("workers", 10, "Number of concurrent fetch workers")
+	parseWorkers := fs.Int("parse-workers", 0, "Number of workers parsing and rewriting fetched pages (0 = one per CPU)")
+	writeWorkers := fs.Int("write-workers", 0, "Number of workers saving fetched resources to disk (0 = same as --workers)")
+	politenessDelay := fs.Duration("politeness-delay", 0, "Minimum time between requests to the same host; workers fetch from other hosts meanwhile (0 = none)")
+	robotsTxt := fs.Bool("robots-txt", true, "Skip the URLs the site's robots.txt disallows for the crawler's user agent, recording them as failed")
+	crawlDelay := fs.Bool("crawl-delay", true, "Slow each host to the Crawl-delay its robots.txt asks for, where longer than --politeness-delay")
+	referrerLimit := fs.Int("max-queued-per-page", 500, "Most links of one page queued at once, the rest trickling in as those are fetched, so one huge index cannot crowd out the rest of the site (0 = unlimited)")
+	maxCrawlDelay := fs.Duration("max-crawl-delay", time.Minute, "Cap on the robots.txt Crawl-delay honoured by --crawl-delay (0 = uncapped)")
+	whitelist := fs.String("whitelist", "", "Comma-separated list of additional domains to allow, and with --schemes file, directories of file:// assets (starting with /)")
+	schemePolicy := fs.String("scheme-policy", "any", "Which of http and https links to crawl: any, https-only, or upgrade (http links fetched over HTTPS, falling back to HTTP for hosts without it), so pages linked by both schemes are mirrored once")
+	schemes := fs.String("schemes", "", "Comma-separated asset schemes to fetch besides http(s): ftp (from whitelisted hosts) and file (from whitelisted directories within --local-base, for file:// roots)")
+	interactive := fs.Bool("interactive", false, "Ask whether to whitelist a host outside the crawl scope once enough links lead to it, instead of leaving its resources out")
+	interactiveLinks := fs.Int("interactive-links", 25, "Links to an out-of-scope host that make --interactive ask about it")
 	maxDepth := fs.Int("max-depth", 0, "Maximum link depth (0 = unlimited)")
+	suggest := fs.Bool("suggest-whitelist", false, "Instead of scraping, crawl the pages near the root URL without saving them and print a --whitelist value covering the hosts they embed images, stylesheets and scripts from")
+	suggestDepth := fs.Int("suggest-depth", 2, "Link depth crawled by --suggest-whitelist")
+	sitemap := fs.Bool("sitemap", true, "Also queue the in-scope URLs listed in the site's sitemap.xml and robots.txt sitemaps, finding pages no link reaches")
+	docVersion := fs.String("doc-version", "", "Documentation version being scraped, e.g. two or three; saved under <output>/<version>/ alongside other versions, with a version index page")
+	maxBodySize := fs.Int64("max-body-size", 0, "Skip resources larger than this many bytes (0 = unlimited)")
+	maxRetries := fs.Int("max-retries", fetcher.DefaultConfig().MaxRetries, "Times to retry a failed fetch")
+	retriesByType := fs.String("retries-by-type", "", "Comma-separated type=count retry limits overriding --max-retries, e.g. image=0,html=5 (types: html, css, js, image, font, other)")
+	lenientHTTP := fs.Bool("lenient-http", false, lenientHTTPUsage)
+	userAgent := fs.String("user-agent", "", userAgentUsage)
+	contact := fs.String("contact", "", contactUsage)
+	userAgentRotation := fs.String("user-agent-rotation", "none", userAgentRotationUsage)
+	resumeDownloads := fs.Bool("resume-downloads", true, "Resume downloads cut off partway with Range requests, validated by ETag or Last-Modified, instead of starting them over")
+	maxBuffered := fs.Int64("max-buffered", 256<<20, "Cap on response bytes held in memory by all workers at once; workers wait for room instead of exceeding it (0 = unlimited)")
+	statePath := fs.String("state", "", "Crawl state file recording every fetch, for use with 'ue2-docs stats'; Zstandard-compressed if named *.zst")
+	compressManifest := fs.Bool("compress-manifest", false, "Write the manifest Zstandard-compressed, as manifest.json.zst, for archive-scale crawls")
+	skipFailed := fs.String("skip-failed", "", "Crawl state file of an earlier crawl (--state) whose 404 and 410 URLs are skipped instead of fetched again")
+	retryFailed := fs.String("retry-failed", "", "Comma-separated list of URLs or glob patterns of --skip-failed URLs to fetch anyway (* = all)")
+	checkpointInterval := fs.String("checkpoint-interval", "1m", "How often to flush crawl progress (the state file and manifest) to disk during the crawl: a duration such as 30s, or a number of saved pages such as 500; shorter loses less to a crash but costs more IO (0 = only at the end)")
+	localBase := fs.String("local-base", "", "For file:// roots, the directory whose layout is kept in the output, e.g. the top of an HTTrack mirror (default: directory of the root file)")
+	publishDir := fs.String("publish-dir", "", "Live directory to publish each finished directory of the output to during the crawl, for a web server to host while the crawl continues")
+	publishSymlinks := fs.Bool("publish-symlinks", false, "Symlink published files to the output instead of copying them")
+	rsyncFriendly := fs.Bool("rsync-friendly", false, "Leave files unchanged since the last crawl untouched and date files by the origin's Last-Modified, so rsync transfers only real changes")
+	directoryListings := fs.Bool("directory-listings", false, "Write an index.html listing the contents of every output directory without an index page, so a web server serving the mirror never answers directory URLs with 403 or 404")
+	compareOnly := fs.Bool("compare-only", false, "Fetch every resource but write nothing, comparing each with the existing mirror in --output and listing the files a crawl would change")
+	keepOriginal := fs.Bool("keep-original", false, "Also save every page as fetched, before link rewriting, under <output>/.original/ for reprocessing without re-crawling")
+	recordOriginalURLs := fs.Bool("record-original-urls", false, "Keep the URL of every link rewritten to a local path in a data-original-href (or -src) attribute, for debugging rewrites and resolving them again with 'ue2-docs rewrite'")
+	compressHTML := fs.Bool("compress-html", false, "Store pages gzip-compressed as <page>.html.gz for archiving; convert, repair, rewrite and optimize read them transparently")
+	ignoreRobotsMeta := fs.Bool("ignore-robots-meta", false, "Ignore robots meta tags and X-Robots-Tag headers (follow and index every page)")
+	nofollowLinks := fs.String("nofollow-links", "record", "How to treat rel=nofollow links: record (link graph only), follow, or ignore")
+	externalLinks := fs.String("external-links", "record", "How to treat links marked external: record (link graph only), follow, or ignore")
+	forms := fs.String("forms", "disable", formsUsage)
+	checkExternal := fs.String("check-external", "", "Probe out-of-scope links after the crawl and write a JSON report of dead ones to this file")
+	externalRate := fs.Int("external-rate", 2, "Maximum requests per second when probing external links")
+	waybackSubmit := fs.Bool("wayback", false, "Submit every saved page to the Wayback Machine's Save Page Now (keys from WAYBACK_ACCESS_KEY/WAYBACK_SECRET_KEY, optional)")
+	waybackInterval := fs.Duration("wayback-interval", wayback.DefaultConfig().Interval, "Minimum time between Wayback Machine submissions")
+	signKeyFile := fs.String("sign-key", "", signKeyUsage)
+	gitCommit := fs.Bool("git", false, gitUsage)
+	logFormat := fs.String("log-format", "text", "Log format: text or json; fetch lines carry worker, trace, url and referrer fields")
+	debugAddr := fs.String("debug-addr", "", "Serve pprof profiles (/debug/pprof/), expvar counters (/debug/vars) and Prometheus metrics (/metrics) on this address during the crawl, e.g. localhost:6060")
+	logLevel := fs.String("log-level", "info", "Log level: debug (every fetch), info, warn (failures and retries) or error")
+	prioritizeFile := fs.String("prioritize-file", "", "File of URLs or glob patterns (one per line) to fetch first")
+	sectionNames := fs.String("sections", "", "Comma-separated sections of the site to mirror, as named in --sections-file; pages of other sections are not followed, so several partial mirrors can be merged")
+	sectionsFile := fs.String("sections-file", "sections.txt", "File mapping section names, as [Name] lines, to the path prefixes or glob patterns (one per line) of their pages")
+	paginate := fs.String("paginate", "", "Comma-separated pagination rules keeping the query parameters that page through listings, which are otherwise dropped like every query: a URL or glob pattern, '?', and the parameters separated by '&' ('*' = all but --drop-params), e.g. 'http://udn.epicgames.com/bin/search/*?start&limit'")
+	dropParams := fs.String("drop-params", strings.Join(urlutil.DefaultTrackingParams, ","), "Comma-separated query parameters, or glob patterns of them, that --paginate rules keeping every parameter drop, so tracking and session parameters do not make one page look like many")
+	webhooks := fs.String("webhook", "", "Comma-separated list of webhook URLs to notify of crawl events (Discord/Slack compatible)")
+	hookCommand := fs.String("hook-command", "", "Shell command to run on crawl events (event details in UE2DOCS_* env vars)")
+	notifyEvery := fs.Int("notify-every", 0, "Notify hooks after every N saved pages (0 = disabled)")
+	notifyErrorRate := fs.Float64("notify-error-rate", 0, "Notify hooks once the failure rate exceeds this fraction, e.g. 0.2 (0 = disabled)")
 
 	fs.Usage = func() {
 		fmt.Println("Usage: ue2-docs scrape [flags]")
@@ -25,25 +109,737 @@ func runScrape(args []string) {
 		fmt.Println()
 		fmt.Println("Example:")
 		fmt.Println("  ue2-docs scrape --root-url https://docs.unrealengine.com/udk/Two/SiteMap.html --output ./scraped")
+		fmt.Println("  ue2-docs scrape --root-url file:///mnt/httrack/udn.epicgames.com/Two/SiteMap.html --local-base /mnt/httrack")
+		fmt.Println("  ue2-docs scrape --root-url https://docs.unrealengine.com/udk/Three/SiteMap.html --doc-version three --output ./scraped")
+		fmt.Println("  ue2-docs scrape --root-url http://udn.epicgames.com/Two/WebHome.html --suggest-whitelist")
+		fmt.Println("  ue2-docs scrape --sections API,Networking --sections-file sections.txt --output ./part-api")
 	}
 
 	fs.Parse(args)
 
+	nofollowPolicy, err := parser.ParseLinkPolicy(*nofollowLinks)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --nofollow-links: %v\n", err)
+		return exitConfigError
+	}
+	externalPolicy, err := parser.ParseLinkPolicy(*externalLinks)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --external-links: %v\n", err)
+		return exitConfigError
+	}
+	formPolicy := parseFormPolicy(*forms)
+	policy, err := urlutil.ParseSchemePolicy(*schemePolicy)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --scheme-policy: %v\n", err)
+		return exitConfigError
+	}
+	pagination, err := urlutil.NewPagination(splitList(*paginate))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --paginate: %v\n", err)
+		return exitConfigError
+	}
+	pagination.SetTrackingParams(splitList(*dropParams))
+	agents, rotation := parseUserAgents(*userAgent, *userAgentRotation)
+
+	logger := newLogger(*logFormat, *logLevel)
+	if *suggest {
+		fetcherConfig := fetcher.DefaultConfig()
+		fetcherConfig.MaxBodySize = *maxBodySize
+		fetcherConfig.MaxRetries = *maxRetries
+		fetcherConfig.Lenient = *lenientHTTP
+		setUserAgents(&fetcherConfig, agents, rotation, *contact)
+		return suggestWhitelist(scraper.Config{
+			RootURL:          *rootURL,
+			Workers:          *workers,
+			Whitelist:        splitList(*whitelist),
+			SchemePolicy:     policy,
+			Pagination:       pagination,
+			Fetcher:          fetcherConfig,
+			PolitenessDelay:  *politenessDelay,
+			LocalBase:        *localBase,
+			IgnoreRobotsMeta: *ignoreRobotsMeta,
+			NoFollowLinks:    nofollowPolicy,
+			ExternalLinks:    externalPolicy,
+			Logger:           logger,
+		}, *suggestDepth)
+	}
+
+	if *compareOnly && (*gitCommit || *publishDir != "" || *keepOriginal) {
+		fmt.Fprintf(os.Stderr, "Error: --compare-only writes nothing, so cannot be combined with --git, --publish-dir or --keep-original\n")
+		return exitConfigError
+	}
+
+	signKey := loadSignKey(*signKeyFile)
+
+	var backend storage.Backend
+	if storage.IsS3(*outputDir) {
+		if *gitCommit {
+			fmt.Fprintf(os.Stderr, "Error: --git needs an output directory on disk\n")
+			return exitConfigError
+		}
+		backend = newS3Backend(*outputDir, *s3PartSize, *s3Concurrency, *contact)
+	}
+	repo := openGitOutput(context.Background(), *gitCommit, *outputDir)
+	checkpointEvery, checkpointPages := parseCheckpointInterval(*checkpointInterval)
+	typeRetries := parseRetriesByType(*retriesByType)
+	knownDead := loadKnownDead(*skipFailed, *retryFailed)
+
+	var priority *urlutil.Matcher
+	if *prioritizeFile != "" {
+		patterns, err := urlutil.LoadPatterns(*prioritizeFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitConfigError
+		}
+		priority, err = urlutil.NewMatcher(patterns)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitConfigError
+		}
+	}
+
+	var sections *urlutil.PathMatcher
+	if *sectionNames != "" {
+		defined, err := urlutil.LoadSections(*sectionsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --sections: %v\n", err)
+			return exitConfigError
+		}
+		sections, err = urlutil.SelectSections(defined, splitList(*sectionNames))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --sections: %v\n", err)
+			return exitConfigError
+		}
+	}
+
+	var hookList []hooks.Hook
+	for _, u := range splitList(*webhooks) {
+		hookList = append(hookList, hooks.NewWebhookHook(u))
+	}
+	if *hookCommand != "" {
+		hookList = append(hookList, hooks.NewCommandHook(*hookCommand))
+	}
+	dispatcher := hooks.NewDispatcher(hookList...)
+
+	var archive *wayback.Submitter
+	if *waybackSubmit {
+		config := wayback.DefaultConfig()
+		config.Interval = *waybackInterval
+		config.UserAgent = fetcher.ArchivalUserAgent(*contact)
+		config.AccessKey = os.Getenv("WAYBACK_ACCESS_KEY")
+		config.SecretKey = os.Getenv("WAYBACK_SECRET_KEY")
+		archive = wayback.NewSubmitter(config)
+	}
+
 	fmt.Println("UE2 Docs - Scrape")
 	fmt.Println("=================")
 	fmt.Println()
 	fmt.Printf("Root URL:     %s\n", *rootURL)
-	fmt.Printf("Output Dir:   %s\n", *outputDir)
+	if timestamp, original, ok := urlutil.ParseWayback(*rootURL); ok {
+		fmt.Printf("Snapshot:     %s from the Wayback Machine (timestamp %s)\n", original, timestamp)
+	}
+	if backend != nil {
+		fmt.Printf("Output:       %s (parts of %d bytes, %d requests at once)\n", backend, *s3PartSize, *s3Concurrency)
+	} else {
+		fmt.Printf("Output Dir:   %s\n", *outputDir)
+	}
 	fmt.Printf("Workers:      %d\n", *workers)
+	if *parseWorkers > 0 {
+		fmt.Printf("Parsers:      %d\n", *parseWorkers)
+	}
+	if *writeWorkers > 0 {
+		fmt.Printf("Writers:      %d\n", *writeWorkers)
+	}
+	if *docVersion != "" {
+		fmt.Printf("Version:      %s\n", *docVersion)
+	}
+	if *localBase != "" {
+		fmt.Printf("Local Base:   %s\n", *localBase)
+	}
+	if *politenessDelay > 0 {
+		fmt.Printf("Politeness:   %s between requests per host\n", *politenessDelay)
+	}
+	if !*robotsTxt {
+		fmt.Printf("Robots.txt:   Disallow rules ignored\n")
+	}
+	if !*crawlDelay {
+		fmt.Printf("Crawl Delay:  robots.txt Crawl-delay ignored\n")
+	}
+	if *referrerLimit > 0 {
+		fmt.Printf("Per Page:     at most %d links of one page queued at once\n", *referrerLimit)
+	}
 	if *whitelist != "" {
 		fmt.Printf("Whitelist:    %s\n", *whitelist)
 	}
+	if policy != urlutil.SchemeAny {
+		fmt.Printf("Scheme Policy: %s\n", policy)
+	}
+	if *schemes != "" {
+		fmt.Printf("Schemes:      http, https, %s\n", strings.Join(splitList(*schemes), ", "))
+	}
 	if *maxDepth > 0 {
 		fmt.Printf("Max Depth:    %d\n", *maxDepth)
 	}
+	if len(typeRetries) > 0 {
+		fmt.Printf("Retries:      %d, per type %s\n", *maxRetries, *retriesByType)
+	}
+	if *lenientHTTP {
+		fmt.Printf("HTTP:         lenient (malformed responses rescued)\n")
+	}
+	printUserAgents(agents, rotation, *contact)
+	if *maxBodySize > 0 {
+		fmt.Printf("Max Body:     %d bytes\n", *maxBodySize)
+	}
+	if *publishDir != "" {
+		mode := "copies"
+		if *publishSymlinks {
+			mode = "symlinks"
+		}
+		fmt.Printf("Publish Dir:  %s (%s)\n", *publishDir, mode)
+	}
+	if *rsyncFriendly {
+		fmt.Printf("Rsync:        unchanged files kept, dated by Last-Modified\n")
+	}
+	if *directoryListings {
+		fmt.Printf("Listings:     index.html written for directories without one\n")
+	}
+	if *compareOnly {
+		fmt.Printf("Compare Only: nothing written; files compared with the mirror\n")
+	}
+	if *keepOriginal {
+		originals := filepath.Join(*outputDir, storage.OriginalDir)
+		if backend != nil {
+			originals = backend.String() + "/" + storage.OriginalDir
+		}
+		fmt.Printf("Originals:    %s\n", originals)
+	}
+	if *compressHTML {
+		fmt.Printf("Compression:  pages stored gzip-compressed\n")
+	}
+	if *statePath != "" {
+		fmt.Printf("State File:   %s\n", *statePath)
+	}
+	if *skipFailed != "" {
+		fmt.Printf("Skip Failed:  %d dead URLs from %s\n", len(knownDead), *skipFailed)
+	}
+	switch {
+	case checkpointEvery > 0:
+		fmt.Printf("Checkpoint:   every %s\n", checkpointEvery)
+	case checkpointPages > 0:
+		fmt.Printf("Checkpoint:   every %d saved pages\n", checkpointPages)
+	}
+	if repo != nil {
+		fmt.Printf("Git:          one commit per run in %s\n", repo.Dir())
+	}
+	if sections != nil {
+		fmt.Printf("Sections:     %s (%d patterns from %s)\n", *sectionNames, sections.Len(), *sectionsFile)
+	}
+	if priority != nil {
+		fmt.Printf("Prioritize:   %d patterns from %s\n", priority.Len(), *prioritizeFile)
+	}
+	if pagination.Len() > 0 {
+		fmt.Printf("Paginate:     %d rules\n", pagination.Len())
+	}
+	if dispatcher.Len() > 0 {
+		fmt.Printf("Hooks:        %d\n", dispatcher.Len())
+	}
+	if archive != nil {
+		fmt.Printf("Wayback:      one page every %s\n", *waybackInterval)
+	}
+	if *debugAddr != "" {
+		fmt.Printf("Debug:        http://%s/debug/pprof/\n", *debugAddr)
+	}
+	printSignKey(signKey)
 	fmt.Println()
 
-	// TODO: Initialize and start scraper
-	fmt.Println("Scraper not yet implemented. See plan.md for implementation roadmap.")
-	os.Exit(0)
+	var sink metrics.Sink
+	var prometheus *metrics.Prometheus
+	if *debugAddr != "" {
+		prometheus = metrics.NewPrometheus("ue2docs")
+		sink = prometheus
+	}
+
+	fetcherConfig := fetcher.DefaultConfig()
+	fetcherConfig.MaxBodySize = *maxBodySize
+	fetcherConfig.MaxRetries = *maxRetries
+	fetcherConfig.Resume = *resumeDownloads
+	fetcherConfig.Lenient = *lenientHTTP
+	setUserAgents(&fetcherConfig, agents, rotation, *contact)
+
+	var scopePrompt scraper.ScopePrompt
+	if *interactive {
+		scopePrompt = promptWhitelist(bufio.NewReader(os.Stdin))
+	}
+
+	s, err := scraper.New(scraper.Config{
+		RootURL:            *rootURL,
+		OutputDir:          *outputDir,
+		Backend:            backend,
+		Workers:            *workers,
+		ParseWorkers:       *parseWorkers,
+		WriteWorkers:       *writeWorkers,
+		Whitelist:          splitList(*whitelist),
+		Schemes:            splitList(*schemes),
+		SchemePolicy:       policy,
+		ScopePrompt:        scopePrompt,
+		ScopePromptLinks:   *interactiveLinks,
+		MaxDepth:           *maxDepth,
+		Sitemap:            *sitemap,
+		Priority:           priority,
+		Sections:           sections,
+		Pagination:         pagination,
+		Fetcher:            fetcherConfig,
+		PolitenessDelay:    *politenessDelay,
+		RobotsCrawlDelay:   *crawlDelay,
+		RobotsTxt:          *robotsTxt,
+		MaxCrawlDelay:      *maxCrawlDelay,
+		ReferrerLimit:      *referrerLimit,
+		MaxRetriesByType:   typeRetries,
+		KnownDead:          knownDead,
+		MaxBufferedBytes:   *maxBuffered,
+		StatePath:          *statePath,
+		CompressManifest:   *compressManifest,
+		CheckpointInterval: checkpointEvery,
+		CheckpointPages:    checkpointPages,
+		Version:            *docVersion,
+		SignKey:            signKey,
+		LocalBase:          *localBase,
+		KeepOriginal:       *keepOriginal,
+		CompressHTML:       *compressHTML,
+		RecordOriginalURLs: *recordOriginalURLs,
+		RsyncFriendly:      *rsyncFriendly,
+		CompareOnly:        *compareOnly,
+		DirectoryListings:  *directoryListings,
+		PublishDir:         *publishDir,
+		PublishSymlinks:    *publishSymlinks,
+		IgnoreRobotsMeta:   *ignoreRobotsMeta,
+		NoFollowLinks:      nofollowPolicy,
+		ExternalLinks:      externalPolicy,
+		Forms:              formPolicy,
+		Metrics:            sink,
+		Logger:             logger,
+		Hooks:              dispatcher,
+		Archive:            archive,
+		NotifyEvery:        *notifyEvery,
+		ErrorRateThreshold: *notifyErrorRate,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitConfigError
+	}
+
+	if *debugAddr != "" {
+		expvar.Publish("crawl", expvar.Func(func() any { return s.Progress() }))
+		startDebugServer(*debugAddr, prometheus)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := s.Run(ctx)
+	if result == nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	fmt.Println()
+	fmt.Printf("Visited:      %d\n", result.Visited)
+	fmt.Printf("Saved:        %d\n", result.Saved)
+	fmt.Printf("Failed:       %d\n", result.Failed)
+	if *sitemap {
+		fmt.Printf("From Sitemap: %d\n", result.SitemapURLs)
+	}
+	if result.Mismatched > 0 {
+		fmt.Printf("Mismatched:   %d (Content-Type contradicted extension; see 'ue2-docs stats')\n", result.Mismatched)
+	}
+	if result.Coalesced > 0 {
+		fmt.Printf("Coalesced:    %d (shared another worker's fetch of the same URL)\n", result.Coalesced)
+	}
+	if *rsyncFriendly {
+		fmt.Printf("Unchanged:    %d (left in place)\n", result.Unchanged)
+	}
+	if result.KnownDead > 0 {
+		fmt.Printf("Known Dead:   %d (skipped; failed permanently before)\n", result.KnownDead)
+	}
+	if result.Checkpoints > 0 {
+		fmt.Printf("Checkpoints:  %d\n", result.Checkpoints)
+	}
+	if len(result.Whitelisted) > 0 {
+		fmt.Printf("Whitelisted:  %s (pages saved before; run 'ue2-docs rewrite' to link them to the mirror)\n", strings.Join(result.Whitelisted, ","))
+	}
+	if *directoryListings {
+		fmt.Printf("Listings:     %d\n", result.Listings)
+	}
+	if *publishDir != "" {
+		fmt.Printf("Published:    %d files to %s\n", result.Published, *publishDir)
+	}
+	fmt.Printf("Bytes:        %d\n", result.Bytes)
+	fmt.Printf("Duration:     %s\n", result.Duration.Round(time.Millisecond))
+	printSummary(result.Summary)
+	printOutOfScopeHosts(result.OutOfScopeHosts)
+	printCompleteness(result.Completeness)
+	printFormActions(s.FormActions(), result.Forms, formPolicy)
+	if *compareOnly {
+		printChanges(result.Changes, result.Unchanged)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Scrape interrupted: %v\n", err)
+		if repo != nil {
+			fmt.Printf("Git:          not committed (interrupted)\n")
+		}
+	} else {
+		if *checkExternal != "" {
+			checkExternalLinks(ctx, s, *checkExternal, *externalRate)
+		}
+		commitOutput(ctx, repo, fmt.Sprintf("Scrape %s: %d saved, %d failed", *rootURL, result.Saved, result.Failed), []string{
+			fmt.Sprintf("Visited:  %d", result.Visited),
+			fmt.Sprintf("Saved:    %d", result.Saved),
+			fmt.Sprintf("Failed:   %d", result.Failed),
+			fmt.Sprintf("Bytes:    %d", result.Bytes),
+			fmt.Sprintf("Duration: %s", result.Duration.Round(time.Second)),
+		})
+	}
+
+	if archive != nil {
+		if pending := archive.Pending(); pending > 0 && ctx.Err() == nil {
+			fmt.Println()
+			fmt.Printf("Submitting %d remaining pages to the Wayback Machine (Ctrl-C to stop)...\n", pending)
+		}
+		unsubmitted := archive.Close(ctx)
+		fmt.Printf("Wayback:      %d submitted, %d failed, %d unsubmitted\n", archive.Submitted(), archive.Failed(), unsubmitted)
+	}
+
+	status, code := crawlStatus(result, err)
+	fmt.Println()
+	fmt.Println(summaryLine(status, code, result))
+	return code
+}
+
+// printSummary reports the URLs visited by status class and type
+func printSummary(summary scraper.Summary) {
+	fmt.Printf("Statuses:     %s\n", formatCounts(summary.ByStatus))
+	fmt.Printf("Types:        %s\n", formatCounts(summary.ByType))
+}
+
+// formatCounts formats counts as "key count" pairs sorted by key
+func formatCounts(counts map[string]int) string {
+	if len(counts) == 0 {
+		return "none"
+	}
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprintf("%s %d", key, counts[key])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// printChanges lists the files a --compare-only crawl found different
+// from, or missing in, the mirror
+func printChanges(changes []storage.Change, unchanged int) {
+	if len(changes) == 0 {
+		fmt.Printf("Changes:      none (%d files identical to the mirror)\n", unchanged)
+		return
+	}
+	fmt.Printf("Changes:      %d files would change, %d identical to the mirror\n", len(changes), unchanged)
+	for _, c := range changes {
+		kind := "changed"
+		if c.New {
+			kind = "new"
+		}
+		fmt.Printf("  %-8s %s\n", kind, c.Path)
+	}
+}
+
+// maxOutOfScopeHosts is the number of out-of-scope hosts listed after a
+// crawl
+const maxOutOfScopeHosts = 10
+
+// printOutOfScopeHosts lists the hosts outside the crawl scope the crawled
+// pages link to, most linked first, as candidates for --whitelist
+func printOutOfScopeHosts(hosts []scraper.HostLinks) {
+	if len(hosts) == 0 {
+		return
+	}
+	fmt.Printf("Out of Scope: %d hosts linked to but not mirrored (see --whitelist)\n", len(hosts))
+	for i, h := range hosts {
+		if i == maxOutOfScopeHosts {
+			fmt.Printf("  ... and %d more\n", len(hosts)-i)
+			break
+		}
+		fmt.Printf("  %s: %s links\n", h.Host, groupThousands(h.Links))
+	}
+}
+
+// groupThousands formats n with commas between groups of three digits
+func groupThousands(n int) string {
+	digits := strconv.Itoa(n)
+	var sb strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteRune(d)
+	}
+	return sb.String()
+}
+
+// printCompleteness reports the estimated completeness of a crawl and the
+// lists it was measured against
+func printCompleteness(c scraper.Completeness) {
+	fmt.Printf("Completeness: %.1f%% (estimated)\n", c.Estimate()*100)
+	lists := []struct {
+		name     string
+		coverage scraper.Coverage
+	}{
+		{"Discovered", c.Discovered},
+		{"Sitemap", c.Sitemap},
+		{"Root Links", c.RootLinks},
+	}
+	for _, list := range lists {
+		if list.coverage.Total > 0 {
+			fmt.Printf("  %-12s%d of %d fetched\n", list.name+":", list.coverage.Fetched, list.coverage.Total)
+		}
+	}
+}
+
+// parseCheckpointInterval parses the --checkpoint-interval flag, a
+// duration or a number of saved pages, exiting on failure
+func parseCheckpointInterval(s string) (time.Duration, int) {
+	if pages, err := strconv.Atoi(s); err == nil && pages >= 0 {
+		return 0, pages
+	}
+	interval, err := time.ParseDuration(s)
+	if err != nil || interval < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --checkpoint-interval: %q is neither a duration nor a number of pages\n", s)
+		os.Exit(exitConfigError)
+	}
+	return interval, 0
+}
+
+// parseRetriesByType parses the --retries-by-type flag, exiting on failure
+func parseRetriesByType(s string) map[urlutil.ResourceType]int {
+	retries := make(map[urlutil.ResourceType]int)
+	for _, pair := range splitList(s) {
+		name, count, ok := strings.Cut(pair, "=")
+		rt, err := urlutil.ParseResourceType(name)
+		n, convErr := strconv.Atoi(strings.TrimSpace(count))
+		if !ok || err != nil || convErr != nil || n < 0 {
+			fmt.Fprintf(os.Stderr, "Error: --retries-by-type: %q is not type=count with a known type and a count of 0 or more\n", pair)
+			os.Exit(exitConfigError)
+		}
+		retries[rt] = n
+	}
+	return retries
+}
+
+// promptWhitelist asks on the terminal whether to whitelist an
+// out-of-scope host, reading the answers from in
+func promptWhitelist(in *bufio.Reader) scraper.ScopePrompt {
+	return func(host string, links int, examples []string) bool {
+		fmt.Printf("\nFound %d links to %s, outside the crawl scope, such as:\n", links, host)
+		for _, example := range examples {
+			fmt.Printf("  %s\n", example)
+		}
+		fmt.Printf("Whitelist %s and mirror what it serves? [y/N] ", host)
+		answer, _ := in.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return true
+		}
+		return false
+	}
+}
+
+// loadKnownDead loads the URLs of the --skip-failed state file that failed
+// permanently, leaving out those matching --retry-failed, exiting on failure
+func loadKnownDead(path, retry string) []state.Record {
+	if path == "" {
+		if retry != "" {
+			fmt.Fprintf(os.Stderr, "Error: --retry-failed requires --skip-failed\n")
+			os.Exit(exitConfigError)
+		}
+		return nil
+	}
+	records, err := state.Load(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --skip-failed: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+	force, err := urlutil.NewMatcher(splitList(retry))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --retry-failed: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+
+	var dead []state.Record
+	for _, rec := range records {
+		if rec.Gone() && !force.Match(rec.URL) {
+			dead = append(dead, rec)
+		}
+	}
+	return dead
+}
+
+// lenientHTTPUsage describes the --lenient-http flag shared by commands
+// that fetch
+const lenientHTTPUsage = "Retry responses rejected as malformed, such as HTTP/0.9 replies without a status line or broken headers, over a raw connection parsed as best it can, to rescue ancient servers"
+
+// userAgentUsage, contactUsage and userAgentRotationUsage describe the
+// flags shared by commands that fetch setting how they identify themselves
+const (
+	userAgentUsage         = "User-Agent to send, or several separated by '|' for --user-agent-rotation (default: an honest archival crawler User-Agent naming --contact)"
+	contactUsage           = "URL, or mailto: address, site operators can reach you at, named in the default User-Agent (default " + fetcher.DefaultContact + ")"
+	userAgentRotationUsage = "Which of several --user-agent values to send: none (the first), request (each request the next in turn) or host (each host the next in turn, kept for all its requests)"
+)
+
+// parseUserAgents parses the --user-agent and --user-agent-rotation
+// flags, exiting on failure
+func parseUserAgents(userAgent, rotation string) ([]string, fetcher.UserAgentRotation) {
+	r, err := fetcher.ParseUserAgentRotation(rotation)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --user-agent-rotation: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+	var agents []string
+	for _, agent := range strings.Split(userAgent, "|") {
+		if agent = strings.TrimSpace(agent); agent != "" {
+			agents = append(agents, agent)
+		}
+	}
+	return agents, r
+}
+
+// setUserAgents sets the user agents of config, which identifies itself
+// as an archival crawler reachable at contact unless given agents
+func setUserAgents(config *fetcher.Config, agents []string, rotation fetcher.UserAgentRotation, contact string) {
+	config.UserAgent = fetcher.ArchivalUserAgent(contact)
+	config.UserAgents = agents
+	config.UserAgentRotation = rotation
+}
+
+// printUserAgents prints the header line of custom user agents
+func printUserAgents(agents []string, rotation fetcher.UserAgentRotation, contact string) {
+	switch {
+	case len(agents) > 1:
+		fmt.Printf("User-Agent:   %d, rotation %s\n", len(agents), rotation)
+	case len(agents) == 1:
+		fmt.Printf("User-Agent:   %s\n", agents[0])
+	case contact != "":
+		fmt.Printf("User-Agent:   %s\n", fetcher.ArchivalUserAgent(contact))
+	}
+}
+
+// formsUsage describes the --forms flag shared by commands that write pages
+const formsUsage = "How to treat forms, whose actions are server-side scripts the mirror cannot run: disable (keep visible, remove action), strip, or keep"
+
+// parseFormPolicy parses the --forms flag, exiting on failure
+func parseFormPolicy(s string) parser.FormPolicy {
+	policy, err := parser.ParseFormPolicy(s)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --forms: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+	return policy
+}
+
+// printFormActions reports the scripts the forms of saved pages submit to,
+// which were not crawled
+func printFormActions(actions []scraper.FormAction, changed int, policy parser.FormPolicy) {
+	if len(actions) == 0 {
+		return
+	}
+	switch policy {
+	case parser.FormDisable:
+		fmt.Printf("Forms:        %d disabled, %d actions not crawled\n", changed, len(actions))
+	case parser.FormStrip:
+		fmt.Printf("Forms:        %d stripped, %d actions not crawled\n", changed, len(actions))
+	default:
+		fmt.Printf("Forms:        %d actions not crawled (forms kept)\n", len(actions))
+	}
+	for _, a := range actions {
+		fmt.Printf("  %s (on %s)\n", a.Action, a.Page)
+	}
+}
+
+// checkExternalLinks probes the out-of-scope links found during a crawl and
+// writes a report of the dead ones
+func checkExternalLinks(ctx context.Context, s *scraper.Scraper, reportPath string, rate int) {
+	links := s.OutOfScopeLinks()
+
+	fmt.Println()
+	fmt.Printf("Checking %d external links...\n", len(links))
+
+	config := linkcheck.DefaultConfig()
+	if rate > 0 {
+		limiter := fetcher.NewSimpleRateLimiter(rate, time.Second)
+		defer limiter.Stop()
+		config.RateLimiter = limiter
+	}
+
+	statuses := linkcheck.New(config).CheckAll(ctx, links)
+	for i := range statuses {
+		for _, edge := range s.Links().Incoming(statuses[i].URL) {
+			statuses[i].Referrers = append(statuses[i].Referrers, edge.From)
+		}
+	}
+
+	report := linkcheck.NewReport(statuses)
+	if err := report.Save(reportPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+	fmt.Printf("Dead External: %d of %d (report: %s)\n", len(report.Dead), report.Checked, reportPath)
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// s3EnvUsage names the environment variables configuring S3 output
+const s3EnvUsage = "AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, AWS_REGION, and AWS_ENDPOINT_URL for S3-compatible stores"
+
+// newS3Backend creates the backend for an s3://bucket/prefix output,
+// configured from the environment like the AWS CLI, or exits. Requests
+// name contact in their User-Agent like the crawl's own
+func newS3Backend(location string, partSize int64, concurrency int, contact string) storage.Backend {
+	config := s3.DefaultConfig()
+	config.UserAgent = fetcher.ArchivalUserAgent(contact)
+	config.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	config.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	config.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		config.Region = region
+	}
+	config.Endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	config.PartSize = partSize
+	config.Concurrency = concurrency
+
+	if config.AccessKey == "" || config.SecretKey == "" {
+		fmt.Fprintf(os.Stderr, "Error: %s output needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY\n", location)
+		os.Exit(exitConfigError)
+	}
+	if partSize < s3.MinPartSize {
+		fmt.Fprintf(os.Stderr, "Error: --s3-part-size must be at least %d bytes\n", s3.MinPartSize)
+		os.Exit(exitConfigError)
+	}
+
+	backend, err := storage.S3(s3.New(config), location)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --output: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+	return backend
 }
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/aldehir/ue2-docs/internal/search"
+)
+
+func runSearch(args []string) int {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+
+	dir := fs.String("dir", "./markdown", "Converted docs directory, as written by 'ue2-docs convert'")
+	indexPath := fs.String("index", "search-index.json", "Search index written by 'convert --search-index', relative to --dir")
+	limit := fs.Int("limit", 10, "Maximum number of pages to list (0 = all)")
+	words := fs.Int("snippet-words", 24, "Words of each page shown around the best match (0 = no snippets)")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: ue2-docs search [flags] <query>")
+		fmt.Println()
+		fmt.Println("Search the converted docs with the full-text index written by 'convert',")
+		fmt.Println("listing the pages containing every word of the query, best match first,")
+		fmt.Println("each with a snippet of its text.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Example:")
+		fmt.Println("  ue2-docs search \"PhysicsVolume gravity\" --dir ./markdown")
+	}
+
+	query := strings.Join(parseInterspersed(fs, args), " ")
+	if strings.TrimSpace(query) == "" {
+		fs.Usage()
+		return exitConfigError
+	}
+
+	index, err := search.Load(filepath.Join(*dir, *indexPath))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v (run 'ue2-docs convert' with --search-index first)\n", err)
+		return exitConfigError
+	}
+
+	hits := index.Search(query, 0)
+	if len(hits) == 0 {
+		fmt.Printf("No pages match %q\n", query)
+		return exitOK
+	}
+	shown := hits
+	if *limit > 0 && len(shown) > *limit {
+		shown = shown[:*limit]
+	}
+	for i, hit := range shown {
+		if i > 0 {
+			fmt.Println()
+		}
+		title := hit.Title
+		if title == "" {
+			title = hit.Path
+		}
+		fmt.Printf("%s\n  %s\n", title, filepath.Join(*dir, filepath.FromSlash(hit.Path)))
+		if *words > 0 {
+			if data, err := os.ReadFile(filepath.Join(*dir, filepath.FromSlash(hit.Path))); err == nil {
+				fmt.Printf("  %s\n", index.Analyzer.Snippet(plainMarkdown(string(data)), query, *words))
+			}
+		}
+	}
+	if len(hits) > len(shown) {
+		fmt.Printf("\n%d of %d matching pages shown (see --limit)\n", len(shown), len(hits))
+	}
+	return exitOK
+}
+
+// parseInterspersed parses flags given before, after or between the
+// positional arguments, which the flag package stops at, returning the
+// positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+var (
+	markdownLinkTarget = regexp.MustCompile(`\]\([^)]*\)`)
+	markdownSyntax     = strings.NewReplacer("#", "", "*", "", "`", "", "[", "", "]", "", "|", " ", "> ", "")
+)
+
+// plainMarkdown strips the Markdown syntax that would clutter a snippet:
+// link targets, emphasis, code spans, headings and table pipes
+func plainMarkdown(text string) string {
+	// YAML front matter holds metadata, not page text
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		if _, body, found := strings.Cut(rest, "\n---\n"); found {
+			text = body
+		}
+	}
+	text = markdownLinkTarget.ReplaceAllString(text, "]")
+	return markdownSyntax.Replace(text)
+}
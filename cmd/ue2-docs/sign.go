@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aldehir/ue2-docs/internal/minisign"
+)
+
+// signPasswordEnv names the environment variable holding the password of
+// an encrypted --sign-key
+const signPasswordEnv = "UE2DOCS_SIGN_PASSWORD"
+
+// signKeyUsage describes the --sign-key flag shared by commands that write
+// a manifest
+const signKeyUsage = "minisign secret key to sign manifest.json with, writing manifest.json.minisig (password from " + signPasswordEnv + ")"
+
+// loadSignKey loads the --sign-key secret key, exiting on failure
+// Returns nil if no key was given
+func loadSignKey(path string) *minisign.SecretKey {
+	if path == "" {
+		return nil
+	}
+
+	key, err := minisign.LoadSecretKey(path, []byte(os.Getenv(signPasswordEnv)))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --sign-key: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+	return key
+}
+
+// printSignKey prints the public key a manifest will be signed with, for
+// publishing alongside the mirror
+func printSignKey(key *minisign.SecretKey) {
+	if key != nil {
+		fmt.Printf("Signing Key:  %s\n", key.Public())
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aldehir/ue2-docs/internal/state"
+)
+
+func runStats(args []string) int {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+
+	statePath := fs.String("state", "./crawl.db", "Crawl state file written by 'scrape --state'")
+	top := fs.Int("top", 10, "Number of entries to show for largest assets and slowest hosts")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: ue2-docs stats [flags]")
+		fmt.Println()
+		fmt.Println("Print aggregate statistics from a finished or in-progress crawl.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Example:")
+		fmt.Println("  ue2-docs stats --state crawl.db")
+	}
+
+	fs.Parse(args)
+
+	records, err := state.Load(*statePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitConfigError
+	}
+
+	stats := state.Summarize(records, *top)
+
+	fmt.Println("UE2 Docs - Crawl Statistics")
+	fmt.Println("===========================")
+	fmt.Println()
+	fmt.Printf("State File:   %s\n", *statePath)
+	fmt.Printf("URLs:         %d\n", stats.Total)
+	fmt.Printf("Total Bytes:  %d\n", stats.TotalBytes)
+
+	fmt.Println()
+	fmt.Println("By Status Code:")
+	codes := make([]int, 0, len(stats.ByStatus))
+	for code := range stats.ByStatus {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		label := fmt.Sprintf("%d", code)
+		if code == 0 {
+			label = "error"
+		}
+		fmt.Printf("  %-12s %d\n", label, stats.ByStatus[code])
+	}
+
+	fmt.Println()
+	fmt.Println("By Resource Type:")
+	types := make([]string, 0, len(stats.ByType))
+	for t := range stats.ByType {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		fmt.Printf("  %-12s %d\n", t, stats.ByType[t])
+	}
+
+	if len(stats.ByCause) > 0 {
+		fmt.Println()
+		fmt.Println("Failures By Cause:")
+		causes := make([]string, 0, len(stats.ByCause))
+		for c := range stats.ByCause {
+			causes = append(causes, c)
+		}
+		sort.Strings(causes)
+		for _, c := range causes {
+			fmt.Printf("  %-22s %d\n", c, stats.ByCause[c])
+		}
+	}
+
+	if len(stats.Failures) > 0 {
+		fmt.Println()
+		fmt.Println("Failures:")
+		for _, rec := range stats.Failures {
+			status := rec.Cause
+			if rec.StatusCode != 0 {
+				status = strconv.Itoa(rec.StatusCode)
+			}
+			fmt.Printf("  %-14s %s\n", status, rec.URL)
+			if rec.Referrer != "" {
+				fmt.Printf("  %-14s linked from %s\n", "", rec.Referrer)
+			}
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("Largest Assets:")
+	for _, rec := range stats.Largest {
+		fmt.Printf("  %12d  %s\n", rec.Bytes, rec.URL)
+	}
+
+	fmt.Println()
+	fmt.Println("Slowest Hosts (average fetch time):")
+	fmt.Printf("  %10s %8s %8s %8s %8s %9s  %s\n", "total", "dns", "connect", "tls", "ttfb", "requests", "host")
+	for _, h := range stats.Slowest {
+		fmt.Printf("  %10s %8s %8s %8s %8s %9d  %s\n",
+			h.Average.Round(time.Millisecond), h.DNS.Round(time.Millisecond), h.Connect.Round(time.Millisecond),
+			h.TLS.Round(time.Millisecond), h.TTFB.Round(time.Millisecond), h.Requests, h.Host)
+	}
+
+	if len(stats.Rates) > 0 {
+		fmt.Println()
+		fmt.Printf("Crawl Rate (per %d minutes, UTC):\n", int(state.RateWindow.Minutes()))
+		fmt.Printf("  %-16s %9s %12s  %s\n", "window", "requests", "bytes", "host")
+		for _, r := range stats.Rates {
+			fmt.Printf("  %-16s %9d %12d  %s\n", r.Start.Format("2006-01-02 15:04"), r.Requests, r.Bytes, r.Host)
+		}
+	}
+
+	if len(stats.Mismatched) > 0 {
+		fmt.Println()
+		fmt.Println("Content-Type Mismatches (not saved):")
+		for _, rec := range stats.Mismatched {
+			fmt.Printf("  %-24s  %s\n", rec.ContentType, rec.URL)
+		}
+	}
+
+	if len(stats.Anomalies) > 0 {
+		fmt.Println()
+		fmt.Println("Content-Encoding Anomalies (decoded):")
+		for _, rec := range stats.Anomalies {
+			fmt.Printf("  %-40s  %s\n", rec.Anomaly, rec.URL)
+		}
+	}
+	return exitOK
+}
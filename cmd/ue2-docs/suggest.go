@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aldehir/ue2-docs/internal/scraper"
+)
+
+// suggestMinAssets is the number of embedded resources from an
+// out-of-scope host that make --suggest-whitelist recommend it, so a lone
+// hit counter or badge does not
+const suggestMinAssets = 2
+
+// suggestWhitelist crawls the pages of a site to the given depth, without
+// keeping them, and prints the out-of-scope hosts they embed images,
+// stylesheets, scripts and frames from as a --whitelist value. Returns
+// the exit code
+func suggestWhitelist(config scraper.Config, depth int) int {
+	fmt.Println("UE2 Docs - Suggest Whitelist")
+	fmt.Println("============================")
+	fmt.Println()
+	fmt.Printf("Root URL:     %s\n", config.RootURL)
+	fmt.Printf("Max Depth:    %d (pages only)\n", depth)
+	if len(config.Whitelist) > 0 {
+		fmt.Printf("Whitelist:    %s\n", strings.Join(config.Whitelist, ","))
+	}
+	fmt.Println()
+
+	dir, err := os.MkdirTemp("", "ue2-docs-suggest-")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	defer os.RemoveAll(dir)
+
+	config.OutputDir = dir
+	config.MaxDepth = depth
+	config.PagesOnly = true
+
+	s, err := scraper.New(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitConfigError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := s.Run(ctx)
+	if result == nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	fmt.Println()
+	fmt.Printf("Pages:        %d\n", result.Saved)
+	fmt.Printf("Duration:     %s\n", result.Duration.Round(time.Millisecond))
+
+	hosts := s.SuggestWhitelist(suggestMinAssets)
+	if len(hosts) == 0 {
+		fmt.Printf("Suggested:    none (the pages embed nothing from outside the crawl scope)\n")
+	} else {
+		fmt.Printf("Suggested:    %d hosts the pages embed resources from\n", len(hosts))
+		whitelist := append([]string(nil), config.Whitelist...)
+		for _, h := range hosts {
+			fmt.Printf("  %s: %s assets, %s links\n", h.Host, groupThousands(h.Assets), groupThousands(h.Links))
+			whitelist = append(whitelist, h.Host)
+		}
+		fmt.Println()
+		fmt.Printf("Recommended:  --whitelist %s\n", strings.Join(whitelist, ","))
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Crawl interrupted, suggestion based on the pages crawled: %v\n", err)
+		return exitInterrupted
+	}
+	return exitOK
+}
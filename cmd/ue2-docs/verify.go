@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/aldehir/ue2-docs/internal/verify"
+)
+
+func runVerify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+
+	inputDir := fs.String("input", "./output", "Mirror directory containing scraped content and manifest.json")
+	workers := fs.Int("workers", 0, "Number of files hashed at once (0 = one per CPU)")
+	quick := fs.Bool("quick", false, "Only check that files exist with the right size, without hashing them")
+	progress := fs.Duration("progress", 2*time.Second, "How often to report progress on stderr (0 = never)")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: ue2-docs verify [flags]")
+		fmt.Println()
+		fmt.Println("Check every file of a mirror against the size and SHA-256 hash in its")
+		fmt.Println("manifest, listing missing and damaged files. Nothing is downloaded;")
+		fmt.Println("use 'ue2-docs repair' to restore broken assets.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Example:")
+		fmt.Println("  ue2-docs verify --input ./scraped --quick")
+	}
+
+	fs.Parse(args)
+
+	fmt.Println("UE2 Docs - Verify Mirror")
+	fmt.Println("========================")
+	fmt.Println()
+	fmt.Printf("Input Dir:    %s\n", *inputDir)
+	if *workers > 0 {
+		fmt.Printf("Workers:      %d\n", *workers)
+	}
+	if *quick {
+		fmt.Printf("Mode:         quick (existence and size only)\n")
+	}
+	fmt.Println()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	v := verify.New(verify.Config{
+		Root:    *inputDir,
+		Workers: *workers,
+		Quick:   *quick,
+	})
+
+	done := make(chan struct{})
+	if *progress > 0 {
+		go reportVerifyProgress(v, *progress, done)
+	}
+	result, err := v.Run(ctx)
+	close(done)
+	if result == nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	for _, p := range result.Problems {
+		if p.Missing() {
+			fmt.Printf("missing  %s\n", p.Entry.Path)
+		} else {
+			fmt.Printf("damaged  %s: %v\n", p.Entry.Path, p.Err)
+		}
+	}
+	if len(result.Problems) > 0 {
+		fmt.Println()
+	}
+
+	fmt.Printf("Checked:      %d\n", result.Checked)
+	fmt.Printf("Missing:      %d\n", result.Missing)
+	fmt.Printf("Damaged:      %d\n", result.Damaged)
+	fmt.Printf("Bytes:        %d\n", result.Bytes)
+
+	switch {
+	case ctx.Err() != nil:
+		fmt.Fprintf(os.Stderr, "Verify interrupted: %v\n", err)
+		return exitInterrupted
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	case len(result.Problems) > 0:
+		return exitPartialFailure
+	}
+	return exitOK
+}
+
+// reportVerifyProgress prints the files checked so far to stderr every
+// interval until done is closed
+func reportVerifyProgress(v *verify.Verifier, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	start := time.Now()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			p := v.Progress()
+			rate := float64(p.Bytes) / time.Since(start).Seconds() / (1 << 20)
+			fmt.Fprintf(os.Stderr, "Verified %d of %d files (%.1f MiB/s)\n", p.Checked, p.Total, rate)
+		}
+	}
+}
@@ -1,3 +1,15 @@
 module github.com/aldehir/ue2-docs
 
 go 1.24.7
+
+require (
+	github.com/klauspost/compress v1.18.0
+	golang.org/x/crypto v0.48.0
+	golang.org/x/image v0.36.0
+	golang.org/x/net v0.50.0
+)
+
+require (
+	golang.org/x/sys v0.41.0 // indirect
+	golang.org/x/text v0.34.0 // indirect
+)
@@ -0,0 +1,179 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"github.com/aldehir/ue2-docs/internal/manifest"
+	"github.com/aldehir/ue2-docs/internal/storage"
+)
+
+// backlink is a page linking to another
+type backlink struct {
+	path  string // Markdown path of the linking page, relative to the output directory
+	title string
+}
+
+// backlinks maps the Markdown path of each page to the pages linking to
+// it, reconstructing the backlinks TWiki listed for every topic
+type backlinks map[string][]backlink
+
+// collectBacklinks reads the links between the HTML pages under the input
+// directory. Every page is parsed once up front, since a page's backlinks
+// are only known once every other page has been read
+func (c *Converter) collectBacklinks(ctx context.Context, entries *manifest.Manifest) (backlinks, error) {
+	type page struct {
+		title   string
+		targets []string // Input paths of the pages linked to
+	}
+	pages := make(map[string]page) // By input path
+
+	err := filepath.WalkDir(c.config.InputDir, func(src string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(c.config.InputDir, src)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if rel == storage.OriginalDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isHTMLFile(src) {
+			return nil
+		}
+
+		// Pages that fail to parse are reported by the conversion itself
+		doc, _, err := parseFile(src)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(pagePath(rel))
+		pages[rel] = page{title: pageTitle(doc), targets: linkedPages(doc, rel)}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("collecting backlinks in %s: %w", c.config.InputDir, err)
+	}
+
+	mdPath := func(rel string) string {
+		entry, _ := entries.Get(rel)
+		return filepath.ToSlash(markdownPath(c.outputPath(rel, entry.Version)))
+	}
+
+	links := make(backlinks)
+	for rel, p := range pages {
+		from := backlink{path: mdPath(rel), title: p.title}
+		for _, target := range p.targets {
+			if _, ok := pages[target]; ok && target != rel {
+				to := mdPath(target)
+				links[to] = append(links[to], from)
+			}
+		}
+	}
+	for _, from := range links {
+		sort.Slice(from, func(i, j int) bool { return from[i].path < from[j].path })
+	}
+	return links, nil
+}
+
+// linkedPages returns the input paths of the HTML pages linked to by the
+// page at rel, without duplicates. Only relative links are followed; the
+// scraper rewrites every link to a saved page to one
+func linkedPages(doc *html.Node, rel string) []string {
+	var targets []string
+	seen := make(map[string]bool)
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.Data == "a" || n.Data == "area") {
+			if target, ok := relativeLink(getAttr(n, "href"), rel); ok && isHTMLFile(target) && !seen[target] {
+				seen[target] = true
+				targets = append(targets, target)
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+
+	return targets
+}
+
+// relativeLink resolves a relative link on the page at rel to an input
+// path, reporting false for fragments, absolute URLs and links leaving the
+// input directory
+func relativeLink(href, rel string) (string, bool) {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "//") || strings.Contains(href, ":") {
+		return "", false
+	}
+	if i := strings.IndexAny(href, "?#"); i >= 0 {
+		href = href[:i]
+	}
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+
+	target := path.Clean(path.Join(path.Dir(rel), href))
+	if target == ".." || strings.HasPrefix(target, "../") {
+		return "", false
+	}
+	return target, true
+}
+
+// section returns a "Referenced by" section listing the pages linking to
+// page, or "" if none do
+func (b backlinks) section(page string) string {
+	from := b[filepath.ToSlash(page)]
+	if len(from) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n## Referenced by\n\n")
+	for _, link := range from {
+		rel, err := filepath.Rel(filepath.Dir(page), filepath.FromSlash(link.path))
+		if err != nil {
+			rel = link.path
+		}
+		title := link.title
+		if title == "" {
+			title = strings.TrimSuffix(path.Base(link.path), ".md")
+		}
+		fmt.Fprintf(&sb, "- [%s](%s)\n", escapeText(title), filepath.ToSlash(rel))
+	}
+	return sb.String()
+}
+
+// frontMatter returns a referenced_by front matter list of the paths,
+// relative to the output directory, of the pages linking to page
+func (b backlinks) frontMatter(page string) string {
+	from := b[filepath.ToSlash(page)]
+	if len(from) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("referenced_by:\n")
+	for _, link := range from {
+		fmt.Fprintf(&sb, "  - %s\n", strconv.Quote(link.path))
+	}
+	return sb.String()
+}
@@ -0,0 +1,89 @@
+package converter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRelativeLink(t *testing.T) {
+	tests := []struct {
+		href   string
+		want   string
+		wantOK bool
+	}{
+		{"Other.html", "Two/Other.html", true},
+		{"../Three/Page.html#Section", "Three/Page.html", true},
+		{"Script/Actor%20Functions.html?rev=2", "Two/Script/Actor Functions.html", true},
+		{"#Section", "", false},
+		{"https://udn.epicgames.com/Two/Other.html", "", false},
+		{"//udn.epicgames.com/Two/Other.html", "", false},
+		{"../../Outside.html", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.href, func(t *testing.T) {
+			got, ok := relativeLink(tt.href, "Two/WebHome.html")
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("relativeLink(%q) = %q, %v, want %q, %v", tt.href, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestConverter_RunBacklinks(t *testing.T) {
+	input := t.TempDir()
+	output := t.TempDir()
+
+	files := map[string]string{
+		"Two/WebHome.html":       `<title>Welcome</title><p><a href="Script/Actor.html">Actor</a> <a href="Script/Actor.html#Tick">Tick</a> <a href="WebHome.html">Home</a></p>`,
+		"Two/Script/Actor.html":  `<title>Actor</title><p>See <a href="Pawn.html">Pawn</a>.</p>`,
+		"Two/Script/Pawn.html":   `<title>Pawn</title><p><a href="Actor.html">Actor</a> <a href="../WebHome.html">Home</a> <a href="Missing.html">Missing</a></p>`,
+		"Two/Script/Orphan.html": `<title>Orphan</title><p>No links here.</p>`,
+	}
+	for name, content := range files {
+		path := filepath.Join(input, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := New(Config{InputDir: input, OutputDir: output, PreserveStructure: true, Backlinks: true, FrontMatter: true}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Linked != 3 {
+		t.Errorf("Linked = %d, want 3", result.Linked)
+	}
+
+	tests := []struct {
+		page string
+		want string
+	}{
+		{
+			page: "Two/Script/Actor.md",
+			want: "---\ntitle: \"Actor\"\nreferenced_by:\n  - \"Two/Script/Pawn.md\"\n  - \"Two/WebHome.md\"\n---\n\n" +
+				"See [Pawn](Pawn.md).\n\n## Referenced by\n\n- [Pawn](Pawn.md)\n- [Welcome](../WebHome.md)\n",
+		},
+		{
+			page: "Two/WebHome.md",
+			want: "---\ntitle: \"Welcome\"\nreferenced_by:\n  - \"Two/Script/Pawn.md\"\n---\n\n" +
+				"[Actor](Script/Actor.md) [Tick](Script/Actor.md#Tick) [Home](WebHome.md)\n\n## Referenced by\n\n- [Pawn](Script/Pawn.md)\n",
+		},
+		{
+			page: "Two/Script/Orphan.md",
+			want: "---\ntitle: \"Orphan\"\n---\n\nNo links here.\n",
+		},
+	}
+	for _, tt := range tests {
+		md, err := os.ReadFile(filepath.Join(output, filepath.FromSlash(tt.page)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(md) != tt.want {
+			t.Errorf("%s =\n%s\nwant\n%s", tt.page, md, tt.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aldehir/ue2-docs/internal/diff"
+)
+
+// PreviousDir is the directory, under the output directory, where updates
+// keep the previous Markdown of pages that changed
+const PreviousDir = ".previous"
+
+// pageChange is a page whose Markdown changed since the previous run
+type pageChange struct {
+	path     string // Slash-separated Markdown path
+	title    string
+	old, new string
+}
+
+// changelog accumulates the pages changed by an update
+type changelog struct {
+	pages []pageChange
+}
+
+// writePage writes the Markdown of a converted page to mdRel under the
+// output directory. When updating (changes is non-nil), a differing previous
+// version is first kept under PreviousDir and recorded in changes
+func (c *Converter) writePage(mdRel, title, markdown string, changes *changelog) error {
+	dst := filepath.Join(c.config.OutputDir, mdRel)
+
+	if changes != nil {
+		old, err := os.ReadFile(dst)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return fmt.Errorf("reading previous %s: %w", dst, err)
+		case string(old) != markdown:
+			if err := writeFile(filepath.Join(c.config.OutputDir, PreviousDir, mdRel), string(old)); err != nil {
+				return err
+			}
+			changes.pages = append(changes.pages, pageChange{
+				path:  filepath.ToSlash(mdRel),
+				title: title,
+				old:   string(old),
+				new:   markdown,
+			})
+		}
+	}
+
+	return writeFile(dst, markdown)
+}
+
+// write adds the changed pages to the changelog page for now's date in dir,
+// appending to it when an earlier update ran the same day, and regenerates
+// the changelog's index. rel is dir relative to the output directory
+func (cl *changelog) write(dir, rel string, now time.Time) error {
+	date := now.Format(time.DateOnly)
+	file := path.Join(rel, date+".md")
+	dst := filepath.Join(dir, date+".md")
+
+	var sb strings.Builder
+	existing, err := os.ReadFile(dst)
+	switch {
+	case err == nil:
+		sb.Write(existing)
+	case errors.Is(err, os.ErrNotExist):
+		fmt.Fprintf(&sb, "# Changes on %s\n", date)
+	default:
+		return fmt.Errorf("reading %s: %w", dst, err)
+	}
+
+	for _, page := range cl.pages {
+		title := page.title
+		if title == "" {
+			title = strings.TrimSuffix(path.Base(page.path), path.Ext(page.path))
+		}
+		target, err := filepath.Rel(path.Dir(file), page.path)
+		if err != nil {
+			target = page.path
+		}
+		fmt.Fprintf(&sb, "\n## [%s](%s)\n\n", escapeText(title), filepath.ToSlash(target))
+
+		unified := diff.Unified("a/"+page.path, "b/"+page.path, []byte(page.old), []byte(page.new))
+		sb.WriteString(fencedCode(strings.TrimSuffix(unified, "\n"), "diff") + "\n")
+	}
+
+	if err := writeFile(dst, sb.String()); err != nil {
+		return err
+	}
+	return writeChangesIndex(dir)
+}
+
+// writeChangesIndex lists the dated changelog pages in dir, newest first
+func writeChangesIndex(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", dir, err)
+	}
+
+	var dates []string
+	for _, e := range entries {
+		date, ok := strings.CutSuffix(e.Name(), ".md")
+		if _, err := time.Parse(time.DateOnly, date); ok && err == nil {
+			dates = append(dates, date)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(dates)))
+
+	var sb strings.Builder
+	sb.WriteString("# Changes\n\nEdits to the documentation found by each update, newest first.\n\n")
+	for _, date := range dates {
+		fmt.Fprintf(&sb, "- [%s](%s.md)\n", date, date)
+	}
+
+	return writeFile(filepath.Join(dir, "index.md"), sb.String())
+}
@@ -0,0 +1,599 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+
+	"github.com/aldehir/ue2-docs/internal/manifest"
+	"github.com/aldehir/ue2-docs/internal/parser"
+	"github.com/aldehir/ue2-docs/internal/search"
+	"github.com/aldehir/ue2-docs/internal/storage"
+)
+
+// Config holds converter configuration
+type Config struct {
+	InputDir          string
+	OutputDir         string
+	PreserveStructure bool // Keep the input directory layout; otherwise flatten into OutputDir
+
+	// RawHTML keeps elements with no Markdown equivalent, such as applets,
+	// embedded objects and forms, as raw HTML between comments naming the
+	// element, rather than reducing them to their text or dropping them
+	RawHTML bool
+
+	// TOC adds a table of contents linking to the headings from TOCMinLevel
+	// to TOCMaxLevel (default 2 to 3) at the top of each page with at
+	// least two of them, replacing the boxes rendered by TWiki's %TOC%
+	TOC         bool
+	TOCMinLevel int
+	TOCMaxLevel int
+
+	// Reflow breaks the text of paragraphs into lines: not at all, at
+	// WrapWidth columns (default DefaultWrapWidth) or one sentence per
+	// line. TrimTrailingSpace strips whitespace from the ends of lines and
+	// CollapseBlankLines allows at most one blank line in a row, none at
+	// the start or end of a page. Fenced code is left as it is
+	Reflow             Reflow
+	WrapWidth          int
+	TrimTrailingSpace  bool
+	CollapseBlankLines bool
+
+	// EntityMap holds extra text replacements applied on top of
+	// DefaultEntityMap, e.g. from LoadEntityMap
+	EntityMap map[string]string
+
+	// Icons replaces the small icon images of TWiki pages, such as
+	// new.gif and arrow bullets, with the text or emoji of DefaultIconMap
+	// overlaid with IconMap, e.g. from LoadIconMap, or drops them where
+	// that is ""
+	Icons   bool
+	IconMap map[string]string
+
+	// QuickReference is the path, relative to OutputDir, of a generated
+	// appendix listing console commands, INI settings and exec functions
+	// found across the docs ("" = disabled)
+	QuickReference string
+
+	// SearchIndex is the path, relative to OutputDir, of a generated
+	// full-text index of the converted pages ("" = disabled)
+	SearchIndex string
+
+	// Analyzer tokenizes text for the search index; the zero value uses
+	// search.DefaultAnalyzer
+	Analyzer search.Analyzer
+
+	// RepairReport is the path, relative to OutputDir, of a report of the
+	// broken markup repaired in each page before conversion ("" =
+	// disabled)
+	RepairReport string
+
+	// FrontMatter starts each page with YAML front matter holding its
+	// title, TWiki author, revision and parent topic, and, as
+	// last_modified, the latest date matched by DatePatterns (nil =
+	// DefaultDatePatterns) in ISO-8601 form
+	FrontMatter  bool
+	DatePatterns []*regexp.Regexp
+
+	// Backlinks ends every page other pages link to with a "Referenced by"
+	// section listing them, as TWiki's backlinks did, and with
+	// FrontMatter also lists them as referenced_by
+	Backlinks bool
+
+	// HeadingIDs gives every heading an explicit, stable anchor, written
+	// as {#id} after its text: the anchor of the original HTML where it
+	// had one, from an id or name on, in or just before the heading, so
+	// deep links into the old pages keep working, and otherwise the one
+	// generated from its title. Tables of contents link to these anchors
+	HeadingIDs bool
+
+	// PrettyTitles shows links whose text is the bare topic name of the
+	// page they point at, such as ActorVariables, with the page's title
+	// from its <title> tag instead, or else with the name split into
+	// words, "Actor Variables"
+	PrettyTitles bool
+
+	// Update converts into the output of an earlier run, keeping the
+	// previous Markdown of every page whose conversion changed under
+	// PreviousDir
+	Update bool
+
+	// Changes is the directory, relative to OutputDir, of a changelog with
+	// a dated page of diffs for each update that changed pages ("" =
+	// disabled; requires Update)
+	Changes string
+
+	// Formats lists the output formats written, each from the same parse
+	// of every page (nil = FormatMarkdown alone). Update and Changes apply
+	// to the Markdown
+	Formats []Format
+
+	// StreamThreshold is the size in bytes of a stored page above which
+	// Run converts it by streaming, parsing one top-level block of its
+	// body at a time rather than the whole page, to bound memory on huge
+	// generated pages (0 = DefaultStreamThreshold, negative = never).
+	// Streamed pages get no table of contents, and their front matter
+	// comes from the head alone, without a footer date
+	StreamThreshold int64
+
+	// Stamp records on each page, at its top or bottom, where it came
+	// from: StampText (default DefaultStampText) with {url}, {date} and
+	// {attribution} replaced by the URL the page was scraped from, the
+	// date it was saved and Attribution, such as a copyright notice.
+	// Notice is the path, relative to OutputDir, of a NOTICE file naming
+	// the sites scraped, when, and Attribution ("" = disabled)
+	Stamp       Stamp
+	StampText   string
+	Attribution string
+	Notice      string
+
+	// Logger receives the files that failed to convert; nil =
+	// slog.Default()
+	Logger *slog.Logger
+}
+
+// Result summarizes a conversion run
+type Result struct {
+	Converted int
+	Copied    int // Non-HTML assets copied alongside the Markdown
+	Failed    int
+	Repaired  int // Pages whose HTML needed repairs before conversion
+	Linked    int // Pages given a "Referenced by" section, with Backlinks
+
+	References int // Entries in the quick reference appendix
+	Indexed    int // Pages in the search index
+	Changed    int // Pages whose Markdown differs from the previous run, with Update
+}
+
+// Converter converts scraped HTML documents to Markdown
+type Converter struct {
+	config       Config
+	entities     *strings.Replacer
+	datePatterns []*regexp.Regexp
+	titles       topicTitles       // Titles of the input pages, with PrettyTitles
+	icons        map[string]string // Icon substitutions by file name; nil unless Icons
+	logger       *slog.Logger
+}
+
+// New creates a new converter with the given configuration
+func New(config Config) *Converter {
+	datePatterns := config.DatePatterns
+	if datePatterns == nil {
+		datePatterns = DefaultDatePatterns()
+	}
+
+	var icons map[string]string
+	if config.Icons {
+		icons = newIconMap(config.IconMap)
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &Converter{
+		config:       config,
+		entities:     newEntityReplacer(config.EntityMap),
+		datePatterns: datePatterns,
+		icons:        icons,
+		logger:       logger,
+	}
+}
+
+// Convert converts an HTML document to Markdown. The document's character
+// encoding is detected from its BOM or <meta> tags, falling back to
+// Windows-1252 for legacy pages that are not valid UTF-8
+func (c *Converter) Convert(r io.Reader) (string, error) {
+	decoded, err := parser.NewUTF8Reader(r, "")
+	if err != nil {
+		return "", fmt.Errorf("detecting character encoding: %w", err)
+	}
+
+	doc, _, err := parse(decoded)
+	if err != nil {
+		return "", fmt.Errorf("parsing HTML: %w", err)
+	}
+
+	return c.ConvertNode(doc), nil
+}
+
+// ConvertNode converts a parsed HTML document to Markdown. Image maps in
+// the document are replaced by lists of their links
+func (c *Converter) ConvertNode(doc *html.Node) string {
+	root := findElement(doc, "body")
+	if root == nil {
+		root = doc
+	}
+	imageMapLinks(root)
+
+	blocks := c.renderBlocks(root)
+	if c.config.HeadingIDs {
+		assignAnchors(blocks, NewAnchorSet())
+	}
+	if c.config.TOC {
+		blocks = c.addTOC(blocks)
+	}
+
+	out := joinBlocks(blocks)
+	if out == "" {
+		return ""
+	}
+	return c.layout(out + "\n")
+}
+
+// ConvertFile converts the HTML file at src and writes Markdown to dst
+func (c *Converter) ConvertFile(src, dst string) error {
+	doc, _, err := parseFile(src)
+	if err != nil {
+		return err
+	}
+	return writeFile(dst, c.frontMatter(doc, "", nil)+c.ConvertNode(doc))
+}
+
+// parseFile parses and repairs the HTML file at src
+func parseFile(src string) (*html.Node, repairs, error) {
+	in, err := openPage(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer in.Close()
+
+	doc, found, err := parse(in)
+	if err != nil {
+		return nil, nil, fmt.Errorf("converting %s: parsing HTML: %w", src, err)
+	}
+
+	return doc, found, nil
+}
+
+// openPage opens the HTML file at src for reading as UTF-8, decompressing
+// pages the scraper stored compressed
+func openPage(src string) (io.ReadCloser, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", src, err)
+	}
+	var encoding string
+	if src != pagePath(src) {
+		encoding = manifest.EncodingGzip
+	}
+	in, err := storage.Decode(f, encoding)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", src, err)
+	}
+
+	decoded, err := parser.NewUTF8Reader(in, "")
+	if err != nil {
+		in.Close()
+		return nil, fmt.Errorf("converting %s: detecting character encoding: %w", src, err)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{decoded, in}, nil
+}
+
+// writeFile writes a converted file, creating parent directories as needed
+func writeFile(dst, content string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("creating directory for %s: %w", dst, err)
+	}
+
+	if err := os.WriteFile(dst, []byte(content), 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", dst, err)
+	}
+
+	return nil
+}
+
+// Run converts every HTML file under the input directory, copying other
+// files (images, attachments) so links in the Markdown keep working.
+// Cancelling ctx stops after the current file and skips generating the
+// quick reference and search index
+func (c *Converter) Run(ctx context.Context) (*Result, error) {
+	result := &Result{}
+
+	// The manifest tells which documentation version each file belongs to
+	entries, err := manifest.LoadDir(c.config.InputDir)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		entries = manifest.New()
+	case err != nil:
+		return result, err
+	}
+	versions := newVersionLinks(entries.Entries(), func(e manifest.Entry) string {
+		return markdownPath(c.outputPath(e.Path, e.Version))
+	})
+
+	var refs *quickRef
+	if c.config.QuickReference != "" {
+		refs = newQuickRef()
+	}
+
+	var index *search.Index
+	if c.config.SearchIndex != "" {
+		analyzer := c.config.Analyzer
+		if analyzer.Name == "" {
+			analyzer = search.DefaultAnalyzer()
+		}
+		index = search.NewIndex(analyzer)
+	}
+
+	var links backlinks
+	if c.config.Backlinks {
+		if links, err = c.collectBacklinks(ctx, entries); err != nil {
+			return result, err
+		}
+	}
+
+	if c.config.PrettyTitles {
+		if c.titles, err = c.collectTitles(ctx); err != nil {
+			return result, err
+		}
+	}
+
+	repaired := make(map[string]repairs)
+
+	out, err := openOutputs(c.config.OutputDir, c.config.Formats)
+	if err != nil {
+		return result, err
+	}
+	defer out.close()
+
+	var changes *changelog
+	if c.config.Update {
+		changes = &changelog{}
+	}
+
+	err = filepath.WalkDir(c.config.InputDir, func(src string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(c.config.InputDir, src)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// Unmodified pages kept by the scraper are not part of the docs
+			if rel == storage.OriginalDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if rel == manifest.Filename || rel == manifest.CompressedFilename {
+			return nil
+		}
+		// Directory listings generated for serving the mirror are not docs
+		if isHTMLFile(src) && storage.IsListing(src) {
+			return nil
+		}
+		if isHTMLFile(src) {
+			rel = pagePath(rel)
+		}
+		entry, _ := entries.Get(filepath.ToSlash(rel))
+		rel = c.outputPath(rel, entry.Version)
+
+		if !isHTMLFile(src) {
+			if err := out.asset(src, rel); err != nil {
+				c.logger.Warn("failed to convert", "path", src, "error", err)
+				result.Failed++
+				return nil
+			}
+			result.Copied++
+			return nil
+		}
+
+		mdRel := markdownPath(rel)
+		referencedBy := links.section(mdRel)
+		header := c.stampHeader(entry)
+		footer := referencedBy + versions.footer(mdRel, entry.Version) + c.stampFooter(entry)
+
+		var doc *html.Node
+		var found repairs
+		var text strings.Builder
+		streamed := c.streams(d)
+		if streamed {
+			// doc is the page's head alone, its body read part by part
+			visit := func(n *html.Node) {
+				if refs != nil {
+					refs.collect(n, filepath.ToSlash(mdRel))
+				}
+				if index != nil {
+					text.WriteString(textContent(n))
+				}
+			}
+			doc, found, err = c.convertStreamed(src, mdRel, entry, out, changes, links, header, footer, visit)
+		} else {
+			doc, found, err = parseFile(src)
+			if err == nil {
+				markdown := c.frontMatter(doc, mdRel, links) + header + c.ConvertNode(doc) + footer
+				if out.markdown {
+					err = c.writePage(mdRel, pageTitle(doc), markdown, changes)
+				}
+				if err == nil {
+					err = out.page(mdRel, entry, pageTitle(doc), markdown)
+				}
+			}
+		}
+		if len(found) > 0 {
+			repaired[filepath.ToSlash(mdRel)] = found
+		}
+		if err != nil {
+			c.logger.Warn("failed to convert", "path", src, "error", err)
+			result.Failed++
+			return nil
+		}
+		result.Converted++
+		if referencedBy != "" {
+			result.Linked++
+		}
+
+		if refs != nil && !streamed {
+			refs.collect(doc, filepath.ToSlash(mdRel))
+		}
+		// Pages are kept out of the index by their robots meta tag, or by the
+		// X-Robots-Tag header recorded in the manifest
+		if index != nil && !entry.NoIndex && !parser.MetaRobots(doc).NoIndex {
+			if !streamed {
+				text.WriteString(pageText(doc))
+			}
+			index.Add(filepath.ToSlash(mdRel), pageTitle(doc), text.String())
+		}
+		return nil
+	})
+	if err != nil {
+		return result, fmt.Errorf("walking %s: %w", c.config.InputDir, err)
+	}
+	if err := out.close(); err != nil {
+		return result, err
+	}
+
+	if changes != nil {
+		result.Changed = len(changes.pages)
+		if c.config.Changes != "" && len(changes.pages) > 0 {
+			dir := filepath.Join(c.config.OutputDir, c.config.Changes)
+			if err := changes.write(dir, filepath.ToSlash(c.config.Changes), time.Now()); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	if c.config.Notice != "" {
+		text := notice(entries.Entries(), c.config.Attribution, time.Now())
+		if err := writeFile(filepath.Join(c.config.OutputDir, c.config.Notice), text); err != nil {
+			return result, err
+		}
+	}
+
+	result.Repaired = len(repaired)
+	if c.config.RepairReport != "" {
+		file := filepath.ToSlash(c.config.RepairReport)
+		if err := writeFile(filepath.Join(c.config.OutputDir, c.config.RepairReport), repairReport(file, repaired)); err != nil {
+			return result, err
+		}
+	}
+
+	if refs != nil {
+		file := filepath.ToSlash(c.config.QuickReference)
+		if err := writeFile(filepath.Join(c.config.OutputDir, c.config.QuickReference), refs.render(file)); err != nil {
+			return result, err
+		}
+		result.References = refs.Len()
+	}
+
+	if index != nil {
+		dst := filepath.Join(c.config.OutputDir, c.config.SearchIndex)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return result, fmt.Errorf("creating directory for %s: %w", dst, err)
+		}
+		if err := index.Save(dst); err != nil {
+			return result, err
+		}
+		result.Indexed = index.Len()
+	}
+
+	return result, nil
+}
+
+// outputPath maps a file's path relative to the input directory to its path
+// relative to the output directory
+func (c *Converter) outputPath(rel, version string) string {
+	if c.config.PreserveStructure {
+		return filepath.FromSlash(rel)
+	}
+	// Flattened versions still get a directory each
+	return filepath.Join(version, filepath.Base(rel))
+}
+
+// markdownPath replaces the extension of an HTML path with .md
+func markdownPath(rel string) string {
+	return strings.TrimSuffix(rel, filepath.Ext(rel)) + ".md"
+}
+
+// isHTMLFile reports whether a file name has an HTML extension, possibly
+// followed by that of a compressed page
+func isHTMLFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(pagePath(name))) {
+	case ".html", ".htm":
+		return true
+	}
+	return false
+}
+
+// pagePath strips the suffix of a page stored gzip-compressed from its
+// file name
+func pagePath(name string) string {
+	return strings.TrimSuffix(name, ".gz")
+}
+
+// copyFile copies a file, creating parent directories as needed
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// findElement returns the first element with the given name in document order
+func findElement(n *html.Node, name string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == name {
+		return n
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if found := findElement(child, name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// pageTitle returns a document's <title>, or its first heading if the title
+// is missing
+func pageTitle(doc *html.Node) string {
+	for _, name := range []string{"title", "h1"} {
+		if n := findElement(doc, name); n != nil {
+			if title := strings.TrimSpace(collapseWhitespace(textContent(n))); title != "" {
+				return title
+			}
+		}
+	}
+	return ""
+}
+
+// pageText returns the text of a document's body
+func pageText(doc *html.Node) string {
+	if body := findElement(doc, "body"); body != nil {
+		return textContent(body)
+	}
+	return textContent(doc)
+}
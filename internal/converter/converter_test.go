@@ -0,0 +1,450 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aldehir/ue2-docs/internal/manifest"
+	"github.com/aldehir/ue2-docs/internal/search"
+)
+
+func TestConvert_Inline(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "emphasis keeps spaces outside markers",
+			html: `<p><b>bold </b>text and <i> italic</i></p>`,
+			want: "**bold** text and *italic*\n",
+		},
+		{
+			name: "local html links point at markdown",
+			html: `<p><a href="../Two/Page.html#Section">Page</a></p>`,
+			want: "[Page](../Two/Page.md#Section)\n",
+		},
+		{
+			name: "external links are untouched",
+			html: `<p><a href="http://example.com/page.html">Page</a></p>`,
+			want: "[Page](http://example.com/page.html)\n",
+		},
+		{
+			name: "image with alt text",
+			html: `<p><img src="rsrc/shot.png" alt="Editor"></p>`,
+			want: "![Editor](rsrc/shot.png)\n",
+		},
+		{
+			name: "markdown syntax in text is escaped",
+			html: `<p>a*b &lt;T&gt; ` + "`x`" + `</p>`,
+			want: "a\\*b \\<T> \\`x\\`\n",
+		},
+		{
+			name: "code containing backticks",
+			html: "<p><code>a`b</code></p>",
+			want: "``a`b``\n",
+		},
+		{
+			name: "scripts and styles are dropped",
+			html: `<script>alert(1)</script><style>p{}</style><p>kept</p>`,
+			want: "kept\n",
+		},
+		{
+			name: "empty document",
+			html: ``,
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(Config{}).Convert(strings.NewReader(tt.html))
+			if err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Convert() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvert_RawHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "element without text",
+			html: `<p>Before</p><applet code="Demo.class" width="200"></applet><p>After</p>`,
+			want: "Before\n\n<!-- raw HTML: <applet> -->\n<applet code=\"Demo.class\" width=\"200\"></applet>\n<!-- end raw HTML -->\n\nAfter\n",
+		},
+		{
+			name: "island splits its paragraph",
+			html: `<p>E = mc<sup>2</sup> holds</p>`,
+			want: "E = mc\n\n<!-- raw HTML: <sup> -->\n<sup>2</sup>\n<!-- end raw HTML -->\n\nholds\n",
+		},
+		{
+			name: "nested in inline markup",
+			html: `<p><b>Press <input type="button" value="Go"></b></p>`,
+			want: "**Press <!-- raw HTML: <input> --><input type=\"button\" value=\"Go\"/><!-- end raw HTML -->**\n",
+		},
+		{
+			name: "table with raw cell",
+			html: `<table><tr><td>a</td><td><iframe src="x.html"></iframe></td></tr><tr><td>b</td><td>c</td></tr></table>`,
+			want: "<!-- raw HTML: <table> -->\n<table><tbody><tr><td>a</td><td><iframe src=\"x.html\"></iframe></td></tr><tr><td>b</td><td>c</td></tr></tbody></table>\n<!-- end raw HTML -->\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(Config{RawHTML: true}).Convert(strings.NewReader(tt.html))
+			if err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Convert() = %q, want %q", got, tt.want)
+			}
+
+			// Without the option the elements are reduced to their text
+			plain, _ := New(Config{}).Convert(strings.NewReader(tt.html))
+			if strings.Contains(plain, "raw HTML") {
+				t.Errorf("Convert() without RawHTML = %q", plain)
+			}
+		})
+	}
+}
+
+func TestConverter_Run(t *testing.T) {
+	input := t.TempDir()
+	output := t.TempDir()
+
+	files := map[string]string{
+		"example.com/docs/Page.html":           "<h1>Page</h1><img src=\"img/a.png\">",
+		"example.com/docs/img/a.png":           "PNG",
+		"example.com/docs/sub/Sub.html":        "<p>Sub</p>",
+		".original/example.com/docs/Page.html": "<h1>Page</h1>",
+	}
+	for name, content := range files {
+		path := filepath.Join(input, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := New(Config{InputDir: input, OutputDir: output, PreserveStructure: true}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if result.Converted != 2 || result.Copied != 1 || result.Failed != 0 {
+		t.Errorf("Run() = %+v, want 2 converted, 1 copied", result)
+	}
+
+	md, err := os.ReadFile(filepath.Join(output, "example.com", "docs", "Page.md"))
+	if err != nil {
+		t.Fatalf("reading converted page: %v", err)
+	}
+	if string(md) != "# Page\n\n![](img/a.png)\n" {
+		t.Errorf("converted page = %q", md)
+	}
+
+	if _, err := os.Stat(filepath.Join(output, "example.com", "docs", "img", "a.png")); err != nil {
+		t.Errorf("expected asset to be copied: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(output, "example.com", "docs", "sub", "Sub.md")); err != nil {
+		t.Errorf("expected nested page to keep its directory: %v", err)
+	}
+}
+
+func TestConverter_RunFormats(t *testing.T) {
+	input := t.TempDir()
+	output := t.TempDir()
+
+	files := map[string]string{
+		"Two/Page.html":  "<title>Page</title><h1>Page</h1><img src=\"a.png\">",
+		"Two/a.png":      "PNG",
+		"Two/Other.html": "<p>Other</p>",
+	}
+	for name, content := range files {
+		path := filepath.Join(input, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	formats, err := ParseFormats("mkdocs,ndjson")
+	if err != nil {
+		t.Fatalf("ParseFormats() error = %v", err)
+	}
+	result, err := New(Config{InputDir: input, OutputDir: output, PreserveStructure: true, Formats: formats}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Converted != 2 || result.Copied != 1 {
+		t.Errorf("Run() = %+v, want 2 converted, 1 copied", result)
+	}
+
+	// Without the markdown format, nothing is written to the output root
+	if _, err := os.Stat(filepath.Join(output, "Two")); err == nil {
+		t.Error("Markdown written without the markdown format")
+	}
+	for _, name := range []string{"mkdocs.yml", "docs/Two/Page.md", "docs/Two/a.png"} {
+		if _, err := os.Stat(filepath.Join(output, MkDocsDir, filepath.FromSlash(name))); err != nil {
+			t.Errorf("MkDocs project missing %s: %v", name, err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(output, NDJSONFile))
+	if err != nil {
+		t.Fatalf("reading %s: %v", NDJSONFile, err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("%s has %d lines, want 2:\n%s", NDJSONFile, len(lines), data)
+	}
+	var pages []ndjsonPage
+	for _, line := range lines {
+		var pg ndjsonPage
+		if err := json.Unmarshal([]byte(line), &pg); err != nil {
+			t.Fatalf("decoding %q: %v", line, err)
+		}
+		pages = append(pages, pg)
+	}
+	if pages[1].Path != "Two/Page.md" || pages[1].Title != "Page" || pages[1].Markdown != "# Page\n\n![](a.png)\n" {
+		t.Errorf("page = %+v", pages[1])
+	}
+
+	if _, err := ParseFormats("markdown,pdf"); err == nil {
+		t.Error("ParseFormats() with unknown format expected error")
+	}
+}
+
+func TestConverter_RunSearchIndex(t *testing.T) {
+	input := t.TempDir()
+	output := t.TempDir()
+
+	files := map[string]string{
+		"Two/ActorFunctions.html": "<title>Actor Functions</title><p>PostNetBeginPlay is called on clients.</p>",
+		"Two/Hidden.html":         `<meta name="robots" content="noindex"><p>PostNetBeginPlay</p>`,
+		"Two/Headed.html":         `<p>PostNetBeginPlay, kept out by X-Robots-Tag</p>`,
+	}
+	m := manifest.New()
+	for name, content := range files {
+		path := filepath.Join(input, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		m.Add(manifest.Entry{Path: name, NoIndex: name == "Two/Headed.html"})
+	}
+	if err := m.Save(filepath.Join(input, manifest.Filename)); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := New(Config{InputDir: input, OutputDir: output, PreserveStructure: true, SearchIndex: "search-index.json"}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Indexed != 1 {
+		t.Errorf("Indexed = %d, want 1 (noindex pages are skipped)", result.Indexed)
+	}
+
+	index, err := search.Load(filepath.Join(output, "search-index.json"))
+	if err != nil {
+		t.Fatalf("loading search index: %v", err)
+	}
+	hits := index.Search("begin play", 0)
+	if len(hits) != 1 || hits[0].Path != "Two/ActorFunctions.md" || hits[0].Title != "Actor Functions" {
+		t.Errorf("Search() = %+v, want Two/ActorFunctions.md", hits)
+	}
+}
+
+func TestConverter_RunLogsFailures(t *testing.T) {
+	input := t.TempDir()
+	output := t.TempDir()
+	if err := os.WriteFile(filepath.Join(input, "logo.png"), []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A directory in the way of the copy makes it fail
+	os.MkdirAll(filepath.Join(output, "logo.png"), 0755)
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	result, err := New(Config{InputDir: input, OutputDir: output, PreserveStructure: true, Logger: logger}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Failed != 1 {
+		t.Errorf("Failed = %d, want 1", result.Failed)
+	}
+	if !strings.Contains(logs.String(), "failed to convert") || !strings.Contains(logs.String(), "logo.png") {
+		t.Errorf("failure not logged to Config.Logger: %q", logs.String())
+	}
+}
+
+func TestConverter_RunFlattenVersions(t *testing.T) {
+	input := t.TempDir()
+	output := t.TempDir()
+
+	m := manifest.New()
+	for _, version := range []string{"two", "three"} {
+		rel := version + "/example.com/docs/Page.html"
+		path := filepath.Join(input, filepath.FromSlash(rel))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte("<p>"+version+"</p>"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		m.Add(manifest.Entry{Path: rel, Version: version})
+	}
+	if err := m.Save(filepath.Join(input, manifest.Filename)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New(Config{InputDir: input, OutputDir: output}).Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	for _, version := range []string{"two", "three"} {
+		md, err := os.ReadFile(filepath.Join(output, version, "Page.md"))
+		if err != nil || !strings.HasPrefix(string(md), version+"\n") {
+			t.Errorf("flattened %s page = %q, %v", version, md, err)
+		}
+	}
+}
+
+func TestConverter_RunVersionLinks(t *testing.T) {
+	input := t.TempDir()
+	output := t.TempDir()
+
+	m := manifest.New()
+	files := map[string]string{
+		"two/example.com/Two/Actor.html":       "two",
+		"three/example.com/Three/Actor.html":   "three",
+		"two/example.com/Two/Karma.html":       "two",
+		"three/example.com/Three/Index.html":   "three",
+		"three/example.com/Three/A/Index.html": "three",
+		"two/example.com/Two/Index.html":       "two",
+	}
+	for rel, version := range files {
+		path := filepath.Join(input, filepath.FromSlash(rel))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte("<p>Text</p>"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		m.Add(manifest.Entry{Path: rel, Version: version})
+	}
+	if err := m.Save(filepath.Join(input, manifest.Filename)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New(Config{InputDir: input, OutputDir: output, PreserveStructure: true}).Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	tests := []struct {
+		page string
+		want string
+	}{
+		{"two/example.com/Two/Actor.md", "This page in other versions: [three](../../../three/example.com/Three/Actor.md)\n"},
+		{"three/example.com/Three/Actor.md", "This page in other versions: [two](../../../two/example.com/Two/Actor.md)\n"},
+		{"two/example.com/Two/Karma.md", ""},
+		{"two/example.com/Two/Index.md", ""}, // Ambiguous in three
+	}
+	for _, tt := range tests {
+		md, err := os.ReadFile(filepath.Join(output, filepath.FromSlash(tt.page)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		footer := ""
+		if i := strings.Index(string(md), "This page in other versions"); i >= 0 {
+			footer = string(md[i:])
+		}
+		if footer != tt.want {
+			t.Errorf("%s footer = %q, want %q", tt.page, footer, tt.want)
+		}
+	}
+}
+
+func TestConverter_RunUpdate(t *testing.T) {
+	input := t.TempDir()
+	output := t.TempDir()
+	page := filepath.Join(input, "Two", "Karma.html")
+	os.MkdirAll(filepath.Dir(page), 0755)
+
+	config := Config{InputDir: input, OutputDir: output, PreserveStructure: true, Update: true, Changes: "changes"}
+	convert := func(body string) *Result {
+		t.Helper()
+		if err := os.WriteFile(page, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		result, err := New(config).Run(context.Background())
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		return result
+	}
+
+	// New and unchanged pages are not changes
+	for i := 0; i < 2; i++ {
+		if result := convert("<title>Karma</title><p>Gravity is 1.0</p>"); result.Changed != 0 {
+			t.Errorf("run %d: Changed = %d, want 0", i+1, result.Changed)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(output, "changes")); !os.IsNotExist(err) {
+		t.Errorf("changelog written without changes: %v", err)
+	}
+
+	if result := convert("<title>Karma</title><p>Gravity is 2.0</p>"); result.Changed != 1 {
+		t.Errorf("Changed = %d, want 1", result.Changed)
+	}
+
+	previous, err := os.ReadFile(filepath.Join(output, PreviousDir, "Two", "Karma.md"))
+	if string(previous) != "Gravity is 1.0\n" {
+		t.Errorf("previous version = %q, %v", previous, err)
+	}
+
+	date := time.Now().Format(time.DateOnly)
+	log, err := os.ReadFile(filepath.Join(output, "changes", date+".md"))
+	if err != nil {
+		t.Fatalf("reading changelog: %v", err)
+	}
+	want := "# Changes on " + date + "\n\n" +
+		"## [Karma](../Two/Karma.md)\n\n" +
+		"```diff\n--- a/Two/Karma.md\n+++ b/Two/Karma.md\n@@ -1 +1 @@\n-Gravity is 1.0\n+Gravity is 2.0\n```\n"
+	if string(log) != want {
+		t.Errorf("changelog =\n%s\nwant\n%s", log, want)
+	}
+
+	index, err := os.ReadFile(filepath.Join(output, "changes", "index.md"))
+	if err != nil || !strings.Contains(string(index), "- ["+date+"]("+date+".md)\n") {
+		t.Errorf("changelog index = %q, %v", index, err)
+	}
+}
+
+func TestConverter_RunCancelled(t *testing.T) {
+	input := t.TempDir()
+	os.WriteFile(filepath.Join(input, "Page.html"), []byte("<p>Page</p>"), 0644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err := New(Config{InputDir: input, OutputDir: t.TempDir(), SearchIndex: "search-index.json"}).Run(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v, want context.Canceled", err)
+	}
+	if result == nil || result.Converted != 0 || result.Indexed != 0 {
+		t.Errorf("Run() = %+v, want nothing converted", result)
+	}
+}
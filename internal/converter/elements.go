@@ -0,0 +1,679 @@
+package converter
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// blockKind identifies the kind of a rendered Markdown block
+type blockKind int
+
+const (
+	blockParagraph blockKind = iota
+	blockHeading
+	blockList
+	blockCode
+	blockQuote
+	blockTable
+	blockRule
+	blockHTML
+)
+
+// block is a rendered chunk of Markdown separated from its neighbours by a
+// blank line
+type block struct {
+	kind blockKind
+	text string
+
+	level  int    // Heading level
+	title  string // Heading text without markup
+	anchor string // Heading anchor: from the original HTML, or assigned with HeadingIDs
+}
+
+// blockElements lists elements that start a new Markdown block
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true,
+	"center": true, "dd": true, "div": true, "dl": true, "dt": true,
+	"footer": true, "form": true, "h1": true, "h2": true, "h3": true,
+	"h4": true, "h5": true, "h6": true, "header": true, "hr": true,
+	"li": true, "main": true, "nav": true, "ol": true, "p": true,
+	"pre": true, "section": true, "table": true, "ul": true,
+}
+
+// skipElements lists elements whose content is never converted
+var skipElements = map[string]bool{
+	"head": true, "script": true, "style": true, "noscript": true,
+	"template": true, "title": true,
+}
+
+// rawElements lists elements with no Markdown equivalent. Converted as
+// text they lose their meaning, or vanish entirely when they have no text,
+// so Config.RawHTML keeps them as raw HTML
+var rawElements = map[string]bool{
+	"applet": true, "audio": true, "button": true, "canvas": true,
+	"embed": true, "form": true, "iframe": true, "input": true,
+	"map": true, "math": true, "object": true, "select": true,
+	"sub": true, "sup": true, "svg": true, "textarea": true, "video": true,
+}
+
+// isRaw reports whether n is kept as raw HTML
+func (c *Converter) isRaw(n *html.Node) bool {
+	return c.config.RawHTML && n.Type == html.ElementNode && rawElements[n.Data]
+}
+
+// containsRaw reports whether any descendant of n is kept as raw HTML
+func (c *Converter) containsRaw(n *html.Node) bool {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if c.isRaw(child) || c.containsRaw(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBlock reports whether n is an element that starts a new block
+func isBlock(n *html.Node) bool {
+	return n.Type == html.ElementNode && blockElements[n.Data]
+}
+
+// renderBlocks converts the children of n into a sequence of blocks. Runs of
+// inline content between block elements become paragraphs
+func (c *Converter) renderBlocks(n *html.Node) []block {
+	var blocks []block
+	var inline strings.Builder
+
+	flush := func() {
+		if text := tidyInline(inline.String()); text != "" {
+			blocks = append(blocks, block{kind: blockParagraph, text: text})
+		}
+		inline.Reset()
+	}
+
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && skipElements[child.Data] {
+			continue
+		}
+		// The generated table of contents replaces the one TWiki rendered
+		if c.config.TOC && isTWikiTOC(child) {
+			continue
+		}
+
+		if c.isRaw(child) {
+			flush()
+			blocks = append(blocks, block{kind: blockHTML, text: rawIsland(child)})
+			continue
+		}
+
+		if !isBlock(child) {
+			inline.WriteString(c.renderInline(child))
+			continue
+		}
+
+		flush()
+		blocks = append(blocks, c.renderBlock(child)...)
+	}
+	flush()
+
+	return blocks
+}
+
+// renderBlock converts a single block-level element
+func (c *Converter) renderBlock(n *html.Node) []block {
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level := int(n.Data[1] - '0')
+		text := strings.TrimSpace(c.renderInlineChildren(n))
+		if text == "" {
+			return nil
+		}
+		return []block{{
+			kind:   blockHeading,
+			text:   strings.Repeat("#", level) + " " + text,
+			level:  level,
+			title:  strings.TrimSpace(collapseWhitespace(c.entities.Replace(textContent(n)))),
+			anchor: headingAnchor(n),
+		}}
+
+	case "p":
+		// Raw HTML islands split the paragraph around them
+		if c.containsRaw(n) {
+			return c.renderBlocks(n)
+		}
+		text := tidyInline(c.renderInlineChildren(n))
+		if text == "" {
+			return nil
+		}
+		return []block{{kind: blockParagraph, text: text}}
+
+	case "hr":
+		return []block{{kind: blockRule, text: "---"}}
+
+	case "pre":
+		return []block{{kind: blockCode, text: renderPre(n)}}
+
+	case "ul", "ol":
+		text := c.renderList(n)
+		if text == "" {
+			return nil
+		}
+		return []block{{kind: blockList, text: text}}
+
+	case "blockquote":
+		inner := joinBlocks(c.renderBlocks(n))
+		if inner == "" {
+			return nil
+		}
+		return []block{{kind: blockQuote, text: prefixLines(inner, "> ", ">")}}
+
+	case "dt":
+		text := strings.TrimSpace(c.renderInlineChildren(n))
+		if text == "" {
+			return nil
+		}
+		return []block{{kind: blockParagraph, text: wrapInline("**", text)}}
+
+	case "table":
+		return c.renderTable(n)
+
+	default:
+		// Generic containers (div, center, dl, dd, li outside a list, ...)
+		return c.renderBlocks(n)
+	}
+}
+
+// renderList converts a <ul> or <ol> element, including nested lists
+//
+// TWiki frequently emits nested lists as direct children of the parent list
+// rather than inside an <li>; those are attached to the preceding item.
+func (c *Converter) renderList(n *html.Node) string {
+	ordered := n.Data == "ol"
+	index := 1
+	if start, err := strconv.Atoi(getAttr(n, "start")); ordered && err == nil {
+		index = start
+	}
+
+	var items []string
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode {
+			// Stray text directly inside a list becomes its own item
+			if text := strings.TrimSpace(c.renderInline(child)); text != "" {
+				items = append(items, listItem(listMarker(ordered, &index), text))
+			}
+			continue
+		}
+
+		switch child.Data {
+		case "li":
+			content := joinItemBlocks(c.renderBlocks(child))
+			items = append(items, listItem(listMarker(ordered, &index), content))
+
+		case "ul", "ol":
+			nested := c.renderList(child)
+			if nested == "" {
+				continue
+			}
+			if len(items) == 0 {
+				items = append(items, nested)
+				continue
+			}
+			last := len(items) - 1
+			items[last] += "\n" + indentLines(nested, markerWidth(items[last]))
+
+		default:
+			content := joinItemBlocks(c.renderBlocks(child))
+			if content != "" {
+				items = append(items, listItem(listMarker(ordered, &index), content))
+			}
+		}
+	}
+
+	return strings.Join(items, "\n")
+}
+
+// listMarker returns the marker for the next list item
+func listMarker(ordered bool, index *int) string {
+	if !ordered {
+		return "- "
+	}
+	marker := fmt.Sprintf("%d. ", *index)
+	*index++
+	return marker
+}
+
+// markerWidth returns the width of the list marker at the start of an item
+func markerWidth(item string) int {
+	if strings.HasPrefix(item, "- ") {
+		return 2
+	}
+	if i := strings.Index(item, ". "); i > 0 {
+		if _, err := strconv.Atoi(item[:i]); err == nil {
+			return i + 2
+		}
+	}
+	return 0
+}
+
+// listItem formats item content behind a marker, indenting continuation
+// lines so nested blocks stay inside the item
+func listItem(marker, content string) string {
+	if content == "" {
+		return strings.TrimRight(marker, " ")
+	}
+	return marker + indentLines(content, len(marker))[len(marker):]
+}
+
+// joinItemBlocks joins the blocks of a list item. Nested lists follow the
+// preceding line directly so the list stays tight; other blocks are
+// separated by a blank line
+func joinItemBlocks(blocks []block) string {
+	var sb strings.Builder
+	for i, b := range blocks {
+		if i > 0 {
+			if b.kind == blockList && blocks[i-1].kind == blockParagraph {
+				sb.WriteString("\n")
+			} else {
+				sb.WriteString("\n\n")
+			}
+		}
+		sb.WriteString(b.text)
+	}
+	return sb.String()
+}
+
+// joinBlocks joins blocks with blank lines between them
+func joinBlocks(blocks []block) string {
+	texts := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		texts = append(texts, b.text)
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// renderTable converts a table to a GFM table when every cell holds inline
+// content. Layout tables (a single row or column) are unwrapped into their
+// blocks; anything else falls back to raw HTML so no content is lost
+func (c *Converter) renderTable(n *html.Node) []block {
+	rows := tableRows(n)
+	if len(rows) == 0 {
+		return nil
+	}
+
+	columns := 0
+	simple := true
+	for _, row := range rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+		for _, cell := range row {
+			if hasBlockContent(cell) || c.containsRaw(cell) {
+				simple = false
+			}
+		}
+	}
+
+	if !simple {
+		if len(rows) == 1 || columns == 1 {
+			var blocks []block
+			for _, row := range rows {
+				for _, cell := range row {
+					blocks = append(blocks, c.renderBlocks(cell)...)
+				}
+			}
+			return blocks
+		}
+		if c.config.RawHTML {
+			return []block{{kind: blockHTML, text: rawIsland(n)}}
+		}
+		return []block{{kind: blockHTML, text: renderRaw(n)}}
+	}
+
+	var lines []string
+	for i, row := range rows {
+		cells := make([]string, columns)
+		for j := range cells {
+			if j < len(row) {
+				cells[j] = c.renderCell(row[j])
+			}
+		}
+		lines = append(lines, "| "+strings.Join(cells, " | ")+" |")
+
+		if i == 0 {
+			sep := make([]string, columns)
+			for j := range sep {
+				sep[j] = "---"
+			}
+			lines = append(lines, "| "+strings.Join(sep, " | ")+" |")
+		}
+	}
+
+	return []block{{kind: blockTable, text: strings.Join(lines, "\n")}}
+}
+
+// renderCell converts the inline content of a table cell, keeping it on a
+// single line
+func (c *Converter) renderCell(cell *html.Node) string {
+	var parts []string
+	for _, b := range c.renderBlocks(cell) {
+		parts = append(parts, b.text)
+	}
+	text := strings.Join(parts, "<br>")
+	text = strings.ReplaceAll(text, "\n", "<br>")
+	return strings.ReplaceAll(text, "|", `\|`)
+}
+
+// tableRows returns the cells of each row in a table, looking through
+// thead/tbody/tfoot but not into nested tables
+func tableRows(table *html.Node) [][]*html.Node {
+	var rows [][]*html.Node
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			switch child.Data {
+			case "thead", "tbody", "tfoot":
+				walk(child)
+			case "tr":
+				var cells []*html.Node
+				for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.Data == "td" || cell.Data == "th") {
+						cells = append(cells, cell)
+					}
+				}
+				if len(cells) > 0 {
+					rows = append(rows, cells)
+				}
+			}
+		}
+	}
+	walk(table)
+
+	return rows
+}
+
+// hasBlockContent reports whether a table cell contains block elements that
+// cannot be represented inside a GFM table cell
+func hasBlockContent(n *html.Node) bool {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode {
+			continue
+		}
+		switch child.Data {
+		case "ul", "ol", "pre", "table", "blockquote", "dl",
+			"h1", "h2", "h3", "h4", "h5", "h6":
+			return true
+		}
+		if hasBlockContent(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderInlineChildren converts the children of n as inline content
+func (c *Converter) renderInlineChildren(n *html.Node) string {
+	var sb strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		sb.WriteString(c.renderInline(child))
+	}
+	return collapseSpaces(sb.String())
+}
+
+// renderInline converts a node as inline content
+func (c *Converter) renderInline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return escapeText(collapseWhitespace(c.entities.Replace(n.Data)))
+	case html.ElementNode:
+	default:
+		return ""
+	}
+
+	if skipElements[n.Data] {
+		return ""
+	}
+	if c.isRaw(n) {
+		// Nested in inline markup, where a block cannot start
+		return "<!-- raw HTML: <" + n.Data + "> -->" + collapseWhitespace(renderRaw(n)) + "<!-- end raw HTML -->"
+	}
+
+	switch n.Data {
+	case "br":
+		return "\\\n"
+
+	case "strong", "b":
+		return wrapInline("**", c.renderInlineChildren(n))
+
+	case "em", "i":
+		return wrapInline("*", c.renderInlineChildren(n))
+
+	case "code", "tt", "kbd", "samp":
+		return inlineCode(textContent(n))
+
+	case "a":
+		text := strings.TrimSpace(c.renderInlineChildren(n))
+		href := strings.TrimSpace(getAttr(n, "href"))
+		if href == "" {
+			return text
+		}
+		if c.config.PrettyTitles {
+			if title := c.titles.linkTitle(strings.TrimSpace(textContent(n)), href); title != "" {
+				text = escapeText(title)
+			}
+		}
+		href = markdownLink(href)
+		if text == "" {
+			text = href
+		}
+		return "[" + text + "](" + href + ")"
+
+	case "img":
+		src := strings.TrimSpace(getAttr(n, "src"))
+		if src == "" {
+			return ""
+		}
+		if text, ok := c.icon(src); ok {
+			return text
+		}
+		return "![" + escapeText(c.entities.Replace(getAttr(n, "alt"))) + "](" + src + ")"
+
+	default:
+		return c.renderInlineChildren(n)
+	}
+}
+
+// renderPre converts a <pre> element to a fenced code block
+func renderPre(n *html.Node) string {
+	return fencedCode(strings.Trim(textContent(n), "\n"), "")
+}
+
+// fencedCode wraps code in a fenced block with an optional info string,
+// using a longer fence if the code contains one
+func fencedCode(code, info string) string {
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+
+	return fence + info + "\n" + code + "\n" + fence
+}
+
+// renderRaw renders a node back to HTML for passthrough
+func renderRaw(n *html.Node) string {
+	var sb strings.Builder
+	html.Render(&sb, n)
+	return sb.String()
+}
+
+// rawIsland renders n as a raw HTML block between comments naming the
+// element it came from
+func rawIsland(n *html.Node) string {
+	return "<!-- raw HTML: <" + n.Data + "> -->\n" + renderRaw(n) + "\n<!-- end raw HTML -->"
+}
+
+// markdownLink rewrites relative links to local HTML pages so they point at
+// the converted Markdown files
+func markdownLink(href string) string {
+	if strings.HasPrefix(href, "#") || strings.HasPrefix(href, "//") || strings.Contains(href, ":") {
+		return href
+	}
+
+	target, fragment := href, ""
+	if i := strings.Index(href, "#"); i >= 0 {
+		target, fragment = href[:i], href[i:]
+	}
+
+	switch strings.ToLower(path.Ext(target)) {
+	case ".html", ".htm":
+		target = strings.TrimSuffix(target, path.Ext(target)) + ".md"
+	}
+
+	return target + fragment
+}
+
+// wrapInline wraps text in an emphasis marker, keeping surrounding spaces
+// outside the markers so the emphasis stays valid Markdown
+func wrapInline(marker, text string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+
+	leading := text[:len(text)-len(strings.TrimLeft(text, " "))]
+	trailing := text[len(strings.TrimRight(text, " ")):]
+
+	return leading + marker + trimmed + marker + trailing
+}
+
+// inlineCode wraps text in backticks, using a longer fence if the text
+// itself contains backticks
+func inlineCode(text string) string {
+	text = collapseWhitespace(text)
+	if strings.TrimSpace(text) == "" {
+		return text
+	}
+
+	fence := "`"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+		return fence + " " + text + " " + fence
+	}
+	return fence + text + fence
+}
+
+// textContent returns the concatenated text of a node and its descendants
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+
+	var sb strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.Data == "br" {
+			sb.WriteString("\n")
+			continue
+		}
+		sb.WriteString(textContent(child))
+	}
+	return sb.String()
+}
+
+// getAttr returns the value of an attribute, or "" if it is not present
+func getAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if strings.EqualFold(attr.Key, key) {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// collapseWhitespace replaces runs of whitespace with a single space.
+// Non-breaking spaces are deliberately preserved
+func collapseWhitespace(s string) string {
+	var sb strings.Builder
+	space := false
+	for _, r := range s {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f' {
+			if !space {
+				sb.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		sb.WriteRune(r)
+		space = false
+	}
+	return sb.String()
+}
+
+// tidyInline trims inline content and the spaces left around hard line breaks
+func tidyInline(s string) string {
+	lines := strings.Split(collapseSpaces(s), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSuffix(strings.TrimSpace(strings.Join(lines, "\n")), "\\")
+}
+
+// collapseSpaces collapses runs of spaces left behind by joining inline
+// fragments, without touching hard line breaks
+func collapseSpaces(s string) string {
+	for strings.Contains(s, "  ") {
+		s = strings.ReplaceAll(s, "  ", " ")
+	}
+	return s
+}
+
+// markdownEscaper escapes characters that would otherwise be interpreted as
+// Markdown syntax in running text
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"`", "\\`",
+	"<", `\<`,
+)
+
+// entityPattern matches text that Markdown would decode as an HTML entity
+var entityPattern = regexp.MustCompile(`&(#[0-9]+|#[xX][0-9a-fA-F]+|[A-Za-z][A-Za-z0-9]*);`)
+
+// escapeText escapes Markdown syntax characters in text, including literal
+// entity references that would otherwise be decoded by Markdown renderers
+func escapeText(s string) string {
+	return entityPattern.ReplaceAllString(markdownEscaper.Replace(s), `\&$1;`)
+}
+
+// indentLines indents every non-empty line by width spaces
+func indentLines(s string, width int) string {
+	pad := strings.Repeat(" ", width)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = pad + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// prefixLines prefixes every line with prefix, using emptyPrefix for blank lines
+func prefixLines(s, prefix, emptyPrefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = emptyPrefix
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
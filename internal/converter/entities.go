@@ -0,0 +1,141 @@
+package converter
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DefaultEntityMap lists text artifacts found in legacy TWiki pages and
+// their proper UTF-8 replacements. It is applied to decoded text, so it
+// covers entities that were escaped twice in the source (and therefore
+// survive HTML decoding as literal text) and UTF-8 that was re-encoded as
+// Latin-1 somewhere along the way
+var DefaultEntityMap = map[string]string{
+	// Double-escaped entities
+	"&nbsp;":   "\u00a0",
+	"&copy;":   "©",
+	"&reg;":    "®",
+	"&trade;":  "™",
+	"&mdash;":  "—",
+	"&ndash;":  "–",
+	"&hellip;": "…",
+	"&lsquo;":  "‘",
+	"&rsquo;":  "’",
+	"&ldquo;":  "“",
+	"&rdquo;":  "”",
+	"&times;":  "×",
+	"&deg;":    "°",
+
+	// UTF-8 decoded as Latin-1/Windows-1252
+	"Â\u00a0":  "\u00a0",
+	"Â©":       "©",
+	"Â®":       "®",
+	"Â°":       "°",
+	"Â±":       "±",
+	"Ã—":       "×",
+	"â„¢":      "™",
+	"â€”":      "—",
+	"â€“":      "–",
+	"â€¦":      "…",
+	"â€˜":      "‘",
+	"â€™":      "’",
+	"â€œ":      "“",
+	"â€\u009d": "”",
+	"â€\u009c": "“",
+}
+
+// newEntityReplacer builds a replacer from the default entity map overlaid
+// with custom entries. Mapping an entry to itself disables the default
+func newEntityReplacer(custom map[string]string) *strings.Replacer {
+	merged := make(map[string]string, len(DefaultEntityMap)+len(custom))
+	for from, to := range DefaultEntityMap {
+		merged[from] = to
+	}
+	for from, to := range custom {
+		merged[from] = to
+	}
+
+	pairs := make([]string, 0, len(merged)*2)
+	for from, to := range merged {
+		if from != to {
+			pairs = append(pairs, from, to)
+		}
+	}
+
+	return strings.NewReplacer(pairs...)
+}
+
+// LoadEntityMap reads custom entity mappings from a file. Each line holds two
+// Go-quoted strings, the text to replace and its replacement:
+//
+//	"&amp;nbsp;" " "
+//	"%BR%" "\n"
+//
+// Blank lines and lines starting with '#' are ignored
+func LoadEntityMap(filename string) (map[string]string, error) {
+	return loadPairs(filename, "entity map")
+}
+
+// loadPairs reads a file of lines holding two Go-quoted strings into a
+// map, naming the file what in errors
+func loadPairs(filename, what string) (map[string]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", what, err)
+	}
+	defer f.Close()
+
+	entities := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		from, rest, err := unquotePrefix(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", what, lineNum, err)
+		}
+		to, rest, err := unquotePrefix(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", what, lineNum, err)
+		}
+		if strings.TrimSpace(rest) != "" {
+			return nil, fmt.Errorf("%s line %d: unexpected text after replacement", what, lineNum)
+		}
+		if from == "" {
+			return nil, fmt.Errorf("%s line %d: empty pattern", what, lineNum)
+		}
+
+		entities[from] = to
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", what, err)
+	}
+
+	return entities, nil
+}
+
+// unquotePrefix parses a Go-quoted string at the start of s, returning it
+// unquoted along with the remainder of s
+func unquotePrefix(s string) (string, string, error) {
+	quoted, err := strconv.QuotedPrefix(s)
+	if err != nil {
+		return "", "", fmt.Errorf("expected quoted string at %q", s)
+	}
+
+	value, err := strconv.Unquote(quoted)
+	if err != nil {
+		return "", "", err
+	}
+
+	return value, s[len(quoted):], nil
+}
@@ -0,0 +1,92 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConvert_EntityMap(t *testing.T) {
+	tests := []struct {
+		name   string
+		custom map[string]string
+		html   string
+		want   string
+	}{
+		{
+			name: "mojibake is repaired",
+			html: "<p>itâ€™s Â© 2004</p>",
+			want: "it’s © 2004\n",
+		},
+		{
+			name: "non-breaking space is kept as UTF-8",
+			html: "<p>5&nbsp;m</p>",
+			want: "5\u00a0m\n",
+		},
+		{
+			name:   "custom entries are applied",
+			custom: map[string]string{"%BR%": "", "UT2k4": "UT2004"},
+			html:   "<p>UT2k4%BR%</p>",
+			want:   "UT2004\n",
+		},
+		{
+			name:   "mapping an entry to itself disables the default",
+			custom: map[string]string{"&copy;": "&copy;"},
+			html:   "<p>&amp;copy;</p>",
+			want:   "\\&copy;\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(Config{EntityMap: tt.custom}).Convert(strings.NewReader(tt.html))
+			if err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Convert() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadEntityMap(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "entities.txt")
+	content := "# TWiki artifacts\n\"%BR%\" \"\\n\"\n\n\"&amp;nbsp;\"   \"\\u00a0\"\n"
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entities, err := LoadEntityMap(filename)
+	if err != nil {
+		t.Fatalf("LoadEntityMap() error = %v", err)
+	}
+
+	if len(entities) != 2 {
+		t.Fatalf("LoadEntityMap() = %v, want 2 entries", entities)
+	}
+	if entities["%BR%"] != "\n" {
+		t.Errorf("entities[%%BR%%] = %q, want newline", entities["%BR%"])
+	}
+	if entities["&amp;nbsp;"] != "\u00a0" {
+		t.Errorf("entities[&amp;nbsp;] = %q, want non-breaking space", entities["&amp;nbsp;"])
+	}
+}
+
+func TestLoadEntityMap_Invalid(t *testing.T) {
+	for _, content := range []string{
+		"unquoted \"x\"\n",
+		"\"a\"\n",
+		"\"a\" \"b\" trailing\n",
+		"\"\" \"b\"\n",
+	} {
+		filename := filepath.Join(t.TempDir(), "entities.txt")
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadEntityMap(filename); err == nil {
+			t.Errorf("LoadEntityMap(%q) expected error", content)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package converter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aldehir/ue2-docs/internal/manifest"
+)
+
+// Format is an output format of a conversion. Every format is written from
+// the same conversion of each page, which is parsed only once
+type Format int
+
+const (
+	// FormatMarkdown writes a Markdown file for each page in OutputDir
+	FormatMarkdown Format = iota
+	// FormatMkDocs writes an MkDocs project to MkDocsDir, the pages and
+	// their assets under its docs directory
+	FormatMkDocs
+	// FormatNDJSON writes one JSON object per page to NDJSONFile, for
+	// loading a corpus into other tools
+	FormatNDJSON
+)
+
+// MkDocsDir is the directory, relative to OutputDir, of the MkDocs project
+// written with FormatMkDocs
+const MkDocsDir = "mkdocs"
+
+// NDJSONFile is the file, relative to OutputDir, written with FormatNDJSON
+const NDJSONFile = "pages.ndjson"
+
+// String returns the format name as accepted by ParseFormats
+func (f Format) String() string {
+	switch f {
+	case FormatMarkdown:
+		return "markdown"
+	case FormatMkDocs:
+		return "mkdocs"
+	case FormatNDJSON:
+		return "ndjson"
+	default:
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+}
+
+// ParseFormats parses a comma-separated list of formats, such as
+// "markdown,ndjson"
+func ParseFormats(s string) ([]Format, error) {
+	var formats []Format
+	seen := make(map[Format]bool)
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		var f Format
+		switch name {
+		case "markdown":
+			f = FormatMarkdown
+		case "mkdocs":
+			f = FormatMkDocs
+		case "ndjson":
+			f = FormatNDJSON
+		default:
+			return nil, fmt.Errorf("unknown format %q (want markdown, mkdocs or ndjson)", name)
+		}
+		if !seen[f] {
+			seen[f] = true
+			formats = append(formats, f)
+		}
+	}
+	if len(formats) == 0 {
+		return nil, fmt.Errorf("no format given")
+	}
+	return formats, nil
+}
+
+// mkdocsConfig is the mkdocs.yml of a FormatMkDocs project. Directory URLs
+// are off so the converted pages' relative links to .md files and assets
+// resolve as they are
+const mkdocsConfig = `site_name: UE2 Docs
+docs_dir: docs
+use_directory_urls: false
+markdown_extensions:
+  - attr_list
+  - meta
+  - tables
+  - toc:
+      permalink: true
+`
+
+// ndjsonPage is a page as written with FormatNDJSON
+type ndjsonPage struct {
+	Path     string `json:"path"` // Markdown path relative to OutputDir
+	URL      string `json:"url,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Title    string `json:"title"`
+	Markdown string `json:"markdown"`
+}
+
+// outputs writes the converted pages and copied assets of a run in each
+// of its formats
+type outputs struct {
+	dir      string
+	markdown bool
+	mkdocs   bool
+
+	ndjson *os.File
+	buf    *bufio.Writer
+	enc    *json.Encoder
+	closed bool
+}
+
+// openOutputs prepares the formats of a run, nil meaning Markdown alone
+func openOutputs(dir string, formats []Format) (*outputs, error) {
+	if len(formats) == 0 {
+		formats = []Format{FormatMarkdown}
+	}
+
+	o := &outputs{dir: dir}
+	for _, f := range formats {
+		switch f {
+		case FormatMarkdown:
+			o.markdown = true
+		case FormatMkDocs:
+			o.mkdocs = true
+		case FormatNDJSON:
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, fmt.Errorf("creating %s: %w", dir, err)
+			}
+			file, err := os.Create(filepath.Join(dir, NDJSONFile))
+			if err != nil {
+				return nil, fmt.Errorf("creating %s: %w", NDJSONFile, err)
+			}
+			o.ndjson = file
+			o.buf = bufio.NewWriter(file)
+			o.enc = json.NewEncoder(o.buf)
+		}
+	}
+	return o, nil
+}
+
+// page writes a converted page in the formats other than Markdown, which
+// the converter writes itself
+func (o *outputs) page(mdRel string, entry manifest.Entry, title, markdown string) error {
+	if o.mkdocs {
+		if err := writeFile(filepath.Join(o.dir, MkDocsDir, "docs", mdRel), markdown); err != nil {
+			return err
+		}
+	}
+	if o.enc != nil {
+		err := o.enc.Encode(ndjsonPage{
+			Path:     filepath.ToSlash(mdRel),
+			URL:      entry.URL,
+			Version:  entry.Version,
+			Title:    title,
+			Markdown: markdown,
+		})
+		if err != nil {
+			return fmt.Errorf("writing %s: %w", NDJSONFile, err)
+		}
+	}
+	return nil
+}
+
+// stream writes a page to the formats with files of their own as write
+// produces it, without holding the page whole
+func (o *outputs) stream(mdRel string, write func(w io.Writer) error) error {
+	var dsts []string
+	if o.markdown {
+		dsts = append(dsts, filepath.Join(o.dir, mdRel))
+	}
+	if o.mkdocs {
+		dsts = append(dsts, filepath.Join(o.dir, MkDocsDir, "docs", mdRel))
+	}
+
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	writers := []io.Writer{io.Discard}
+	for _, dst := range dsts {
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return fmt.Errorf("creating directory for %s: %w", dst, err)
+		}
+		f, err := os.Create(dst)
+		if err != nil {
+			return fmt.Errorf("writing %s: %w", dst, err)
+		}
+		files = append(files, f)
+		writers = append(writers, f)
+	}
+
+	bw := bufio.NewWriter(io.MultiWriter(writers...))
+	if err := write(bw); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("writing %s: %w", mdRel, err)
+	}
+	for i, f := range files {
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("writing %s: %w", dsts[i], err)
+		}
+	}
+	files = nil
+	return nil
+}
+
+// asset copies a file the pages use to every format with files of its own
+func (o *outputs) asset(src, rel string) error {
+	if o.markdown {
+		if err := copyFile(src, filepath.Join(o.dir, rel)); err != nil {
+			return err
+		}
+	}
+	if o.mkdocs {
+		if err := copyFile(src, filepath.Join(o.dir, MkDocsDir, "docs", rel)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close finishes the formats, writing the MkDocs configuration. Closing
+// again does nothing
+func (o *outputs) close() error {
+	if o.closed {
+		return nil
+	}
+	o.closed = true
+
+	var err error
+	if o.mkdocs {
+		err = writeFile(filepath.Join(o.dir, MkDocsDir, "mkdocs.yml"), mkdocsConfig)
+	}
+	if o.ndjson != nil {
+		if ferr := o.buf.Flush(); ferr != nil && err == nil {
+			err = fmt.Errorf("writing %s: %w", NDJSONFile, ferr)
+		}
+		if cerr := o.ndjson.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("writing %s: %w", NDJSONFile, cerr)
+		}
+	}
+	return err
+}
@@ -0,0 +1,124 @@
+package converter
+
+import (
+	"bytes"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// update rewrites the golden files with the current converter output:
+//
+//	go test ./internal/converter -update
+var update = flag.Bool("update", false, "update golden Markdown files in testdata/corpus")
+
+// corpusDir holds representative scraped pages (*.html) next to their
+// expected Markdown output (*.md)
+const corpusDir = "testdata/corpus"
+
+// TestConvert_Corpus converts every page in the corpus and compares the
+// result with its golden file
+func TestConvert_Corpus(t *testing.T) {
+	var inputs []string
+	err := filepath.WalkDir(corpusDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && isHTMLFile(path) {
+			inputs = append(inputs, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walking corpus: %v", err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("no pages found in corpus")
+	}
+
+	for _, input := range inputs {
+		name := filepath.ToSlash(strings.TrimPrefix(input, corpusDir+string(filepath.Separator)))
+		t.Run(name, func(t *testing.T) {
+			f, err := os.Open(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			got, err := New(Config{}).Convert(f)
+			if err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+
+			golden := strings.TrimSuffix(input, filepath.Ext(input)) + ".md"
+
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatalf("updating golden file: %v", err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("reading golden file (run with -update to create it): %v", err)
+			}
+
+			if got != string(want) {
+				line, gotLine, wantLine := firstDiff(got, string(want))
+				t.Errorf("output differs from %s at line %d\n got: %q\nwant: %q\n(run with -update to accept the new output)",
+					golden, line, gotLine, wantLine)
+			}
+		})
+	}
+}
+
+// firstDiff returns the first line number (1-based) where got and want
+// differ, along with the differing lines
+func firstDiff(got, want string) (int, string, string) {
+	gotLines := strings.Split(got, "\n")
+	wantLines := strings.Split(want, "\n")
+
+	for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+		var g, w string
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if g != w || i >= len(gotLines) || i >= len(wantLines) {
+			return i + 1, g, w
+		}
+	}
+
+	return 0, "", ""
+}
+
+// BenchmarkConvert_Corpus converts each page of the corpus
+func BenchmarkConvert_Corpus(b *testing.B) {
+	pages, err := filepath.Glob(filepath.Join(corpusDir, "pages", "*.html"))
+	if err != nil || len(pages) == 0 {
+		b.Fatalf("no pages found in corpus: %v", err)
+	}
+
+	for _, page := range pages {
+		data, err := os.ReadFile(page)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(filepath.Base(page), func(b *testing.B) {
+			c := New(Config{TOC: true})
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := c.Convert(bytes.NewReader(data)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
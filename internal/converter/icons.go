@@ -0,0 +1,90 @@
+package converter
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// DefaultIconMap lists the small GIF icons TWiki pages are sprinkled with,
+// by file name, and the text or emoji standing in for them in Markdown.
+// Icons mapped to "" are dropped, such as the spacers used for layout
+var DefaultIconMap = map[string]string{
+	// TWikiDocGraphics, as inserted by %N%, %U%, %T%, %H%, %X%, %Y% ...
+	"new.gif":           "🆕",
+	"updated.gif":       "🔄",
+	"tip.gif":           "💡",
+	"idea.gif":          "💡",
+	"help.gif":          "❓",
+	"info.gif":          "ℹ️",
+	"warning.gif":       "⚠️",
+	"choice-yes.gif":    "✅",
+	"choice-no.gif":     "❌",
+	"choice-cancel.gif": "❌",
+	"star.gif":          "⭐",
+	"pencil.gif":        "✏️",
+	"bullet.gif":        "•",
+
+	// Arrow bullets
+	"arrowright.gif":  "→",
+	"arrowbright.gif": "→",
+	"arrowdot.gif":    "→",
+	"arrowleft.gif":   "←",
+	"arrowbleft.gif":  "←",
+	"arrowup.gif":     "↑",
+	"arrowbup.gif":    "↑",
+	"arrowdown.gif":   "↓",
+	"arrowbdown.gif":  "↓",
+
+	// Layout spacers
+	"spacer.gif":      "",
+	"pixel.gif":       "",
+	"clear.gif":       "",
+	"transparent.gif": "",
+}
+
+// newIconMap builds the icon substitutions from the default icon map
+// overlaid with custom entries, keyed by lowercased file name. Mapping an
+// icon to its own file name keeps it as an image
+func newIconMap(custom map[string]string) map[string]string {
+	merged := make(map[string]string, len(DefaultIconMap)+len(custom))
+	for name, text := range DefaultIconMap {
+		merged[strings.ToLower(name)] = text
+	}
+	for name, text := range custom {
+		merged[strings.ToLower(name)] = text
+	}
+	for name, text := range merged {
+		if strings.EqualFold(name, text) {
+			delete(merged, name)
+		}
+	}
+	return merged
+}
+
+// icon returns the text standing in for the image at src, reporting false
+// if it is not a known icon
+func (c *Converter) icon(src string) (string, bool) {
+	if c.icons == nil {
+		return "", false
+	}
+	if i := strings.IndexAny(src, "?#"); i >= 0 {
+		src = src[:i]
+	}
+	name := path.Base(src)
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	text, ok := c.icons[strings.ToLower(name)]
+	return text, ok
+}
+
+// LoadIconMap reads custom icon substitutions from a file in the format of
+// LoadEntityMap: an icon's file name and the text replacing it, "" to drop
+// it, as two Go-quoted strings per line
+//
+//	"new.gif" "**NEW**"
+//	"rule.gif" ""
+func LoadIconMap(filename string) (map[string]string, error) {
+	return loadPairs(filename, "icon map")
+}
@@ -0,0 +1,76 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConvert_Icons(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		html   string
+		want   string
+	}{
+		{
+			name:   "known icons become text",
+			config: Config{Icons: true},
+			html:   `<p><img src="../pub/TWiki/TWikiDocGraphics/new.gif" alt="NEW"> Physics <img src="rsrc/TWiki/TWikiDocGraphics/ARROWRIGHT.GIF?v=2"> Karma</p>`,
+			want:   "🆕 Physics → Karma\n",
+		},
+		{
+			name:   "spacers are dropped",
+			config: Config{Icons: true},
+			html:   `<p><img src="spacer.gif" width="10">Indented</p>`,
+			want:   "Indented\n",
+		},
+		{
+			name:   "other images are kept",
+			config: Config{Icons: true},
+			html:   `<p><img src="rsrc/diagram.gif" alt="Diagram"></p>`,
+			want:   "![Diagram](rsrc/diagram.gif)\n",
+		},
+		{
+			name:   "custom entries",
+			config: Config{Icons: true, IconMap: map[string]string{"hot.gif": "🔥", "new.gif": "new.gif", "rule.gif": ""}},
+			html:   `<p><img src="hot.gif"><img src="rule.gif"> <img src="new.gif" alt="NEW"></p>`,
+			want:   "🔥 ![NEW](new.gif)\n",
+		},
+		{
+			name:   "disabled",
+			config: Config{},
+			html:   `<p><img src="new.gif" alt="NEW"></p>`,
+			want:   "![NEW](new.gif)\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(tt.config).Convert(strings.NewReader(tt.html))
+			if err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Convert() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadIconMap(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "icons.txt")
+	content := "# Site icons\n\"hot.gif\" \"🔥\"\n\"rule.gif\" \"\"\n"
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	icons, err := LoadIconMap(filename)
+	if err != nil {
+		t.Fatalf("LoadIconMap() error = %v", err)
+	}
+	if len(icons) != 2 || icons["hot.gif"] != "🔥" || icons["rule.gif"] != "" {
+		t.Errorf("LoadIconMap() = %v", icons)
+	}
+}
@@ -0,0 +1,127 @@
+package converter
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// imageMapLinks replaces each image map used by an image with a list of
+// the map's links placed beneath the image, since Markdown images cannot
+// carry clickable regions; the UDK editor docs annotate screenshots this
+// way. Maps no image uses become lists where they stand
+func imageMapLinks(root *html.Node) {
+	maps := make(map[string]*html.Node)
+	var images []*html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "map":
+				for _, key := range []string{"name", "id"} {
+					if name := getAttr(n, key); name != "" && maps[name] == nil {
+						maps[name] = n
+					}
+				}
+			case "img":
+				if getAttr(n, "usemap") != "" {
+					images = append(images, n)
+				}
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(root)
+
+	used := make(map[*html.Node]bool)
+	for _, img := range images {
+		m := maps[strings.TrimPrefix(strings.TrimSpace(getAttr(img, "usemap")), "#")]
+		if m == nil {
+			continue
+		}
+		used[m] = true
+
+		list := areaList(m)
+		if list == nil {
+			continue
+		}
+		after := listAnchor(img)
+		after.Parent.InsertBefore(list, after.NextSibling)
+	}
+
+	for _, m := range maps {
+		if m.Parent == nil {
+			continue // Listed under both its name and id
+		}
+		if !used[m] {
+			if list := areaList(m); list != nil {
+				m.Parent.InsertBefore(list, m)
+			}
+		}
+		m.Parent.RemoveChild(m)
+	}
+}
+
+// areaList returns a <ul> linking to the targets of a map's areas, or nil
+// if none has one
+func areaList(m *html.Node) *html.Node {
+	list := &html.Node{Type: html.ElementNode, Data: "ul", DataAtom: atom.Ul}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "area" {
+			href := strings.TrimSpace(getAttr(n, "href"))
+			if href == "" {
+				return
+			}
+			text := strings.TrimSpace(getAttr(n, "alt"))
+			if text == "" {
+				text = strings.TrimSpace(getAttr(n, "title"))
+			}
+
+			a := &html.Node{Type: html.ElementNode, Data: "a", DataAtom: atom.A,
+				Attr: []html.Attribute{{Key: "href", Val: href}}}
+			if text != "" {
+				a.AppendChild(&html.Node{Type: html.TextNode, Data: text})
+			}
+			item := &html.Node{Type: html.ElementNode, Data: "li", DataAtom: atom.Li}
+			item.AppendChild(a)
+			list.AppendChild(item)
+			return
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(m)
+
+	if list.FirstChild == nil {
+		return nil
+	}
+	return list
+}
+
+// listAnchor returns the node a list placed beneath an image follows: the
+// paragraph or heading holding the image, or else the last node of the run
+// of inline content around it
+func listAnchor(img *html.Node) *html.Node {
+	n := img
+	for n.Parent != nil && n.Parent.Parent != nil && !isBlock(n.Parent) &&
+		n.Parent.Data != "body" && n.Parent.Data != "td" && n.Parent.Data != "th" {
+		n = n.Parent
+	}
+	switch n.Parent.Data {
+	case "p", "h1", "h2", "h3", "h4", "h5", "h6", "dt":
+		// Blocks that cannot hold a list
+		if n.Parent.Parent != nil {
+			return n.Parent
+		}
+	}
+	for n.NextSibling != nil && !isBlock(n.NextSibling) {
+		n = n.NextSibling
+	}
+	return n
+}
@@ -0,0 +1,213 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Reflow controls how the text of paragraphs is broken into lines
+type Reflow int
+
+const (
+	// ReflowNone writes every paragraph on one line
+	ReflowNone Reflow = iota
+	// ReflowWidth wraps paragraphs at Config.WrapWidth columns
+	ReflowWidth
+	// ReflowSentence writes every sentence on a line of its own, so
+	// changing one sentence changes one line of a diff
+	ReflowSentence
+)
+
+// DefaultWrapWidth is the line width of ReflowWidth when Config.WrapWidth
+// is not set
+const DefaultWrapWidth = 80
+
+// String returns the reflow name as accepted by ParseReflow
+func (r Reflow) String() string {
+	switch r {
+	case ReflowNone:
+		return "none"
+	case ReflowWidth:
+		return "width"
+	case ReflowSentence:
+		return "sentence"
+	default:
+		return fmt.Sprintf("Reflow(%d)", int(r))
+	}
+}
+
+// ParseReflow parses "none", "width" or "sentence"
+func ParseReflow(s string) (Reflow, error) {
+	switch s {
+	case "none":
+		return ReflowNone, nil
+	case "width":
+		return ReflowWidth, nil
+	case "sentence":
+		return ReflowSentence, nil
+	default:
+		return 0, fmt.Errorf("unknown reflow %q (want none, width or sentence)", s)
+	}
+}
+
+var (
+	// leadPattern matches the block quote markers, indentation and list
+	// marker a line of Markdown starts with
+	leadPattern = regexp.MustCompile(`^((?:> ?)*)( *)((?:[-*+]|\d+\.) )?`)
+
+	// blockWordPattern matches words that would start a block of their own
+	// at the start of a line, so a line must not be broken before them
+	blockWordPattern = regexp.MustCompile("^(?:[-*+>|<=#]|\\d+[.)]|```|~~~)")
+
+	// abbreviations end in a period without ending a sentence
+	abbreviations = map[string]bool{
+		"e.g.": true, "i.e.": true, "etc.": true, "vs.": true, "cf.": true,
+		"approx.": true, "no.": true, "fig.": true, "ver.": true,
+	}
+)
+
+// layout applies the whitespace options to Markdown: reflowing paragraph
+// text, trimming trailing whitespace and collapsing blank lines. Fenced
+// code, tables, headings and raw HTML are left as they are
+func (c *Converter) layout(markdown string) string {
+	reflow := c.config.Reflow
+	if reflow == ReflowNone && !c.config.TrimTrailingSpace && !c.config.CollapseBlankLines {
+		return markdown
+	}
+	width := c.config.WrapWidth
+	if width <= 0 {
+		width = DefaultWrapWidth
+	}
+
+	var out []string
+	fence, raw := "", false
+	blanks := 0
+	for _, line := range strings.Split(strings.TrimSuffix(markdown, "\n"), "\n") {
+		lead := leadPattern.FindString(line)
+		text := line[len(lead):]
+
+		switch {
+		case fence != "":
+			if strings.TrimSpace(text) == fence {
+				fence = ""
+			}
+			out = append(out, line)
+			continue
+		case raw:
+			raw = !strings.Contains(line, "<!-- end raw HTML -->")
+		case strings.HasPrefix(text, "<!-- raw HTML:"):
+			raw = !strings.Contains(line, "<!-- end raw HTML -->")
+		case strings.HasPrefix(text, "```") || strings.HasPrefix(text, "~~~"):
+			fence = text[:len(text)-len(strings.TrimLeft(text, "`~"))]
+			if strings.Contains(text[len(fence):], "`") {
+				fence = "" // An inline code span, not a fence
+			}
+		}
+
+		if c.config.TrimTrailingSpace {
+			line = strings.TrimRight(line, " \t")
+			text = strings.TrimRight(text, " \t")
+		}
+		if strings.TrimSpace(line) == "" {
+			blanks++
+			if c.config.CollapseBlankLines && (blanks > 1 || len(out) == 0) {
+				continue
+			}
+			out = append(out, line)
+			continue
+		}
+		blanks = 0
+
+		if reflow != ReflowNone && fence == "" && !raw && reflowable(text) {
+			out = append(out, reflowLine(lead, text, reflow, width)...)
+			continue
+		}
+		out = append(out, line)
+	}
+
+	if c.config.CollapseBlankLines {
+		for len(out) > 0 && strings.TrimSpace(out[len(out)-1]) == "" {
+			out = out[:len(out)-1]
+		}
+	}
+	if len(out) == 0 {
+		return ""
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+// reflowable reports whether the text of a line is paragraph text, rather
+// than a heading, table row, rule, code fence or raw HTML
+func reflowable(text string) bool {
+	switch {
+	case text == "",
+		strings.HasPrefix(text, "#"),
+		strings.HasPrefix(text, "|"),
+		strings.HasPrefix(text, "<"),
+		strings.HasPrefix(text, "```"),
+		strings.HasPrefix(text, "~~~"),
+		strings.Trim(text, "-*_ ") == "",
+		strings.Contains(text, "<!--"):
+		return false
+	}
+	return true
+}
+
+// reflowLine breaks the text of one line of Markdown, which starts with
+// lead, into lines. Lines after the first are indented to continue the
+// same paragraph, keeping block quote markers
+func reflowLine(lead, text string, reflow Reflow, width int) []string {
+	// A trailing backslash is a hard break and must stay last
+	hardBreak := strings.HasSuffix(text, "\\") && !strings.HasSuffix(text, "\\\\")
+	if hardBreak {
+		text = strings.TrimSuffix(text, "\\")
+	}
+	// Only spaces separate words; non-breaking spaces are kept
+	words := strings.FieldsFunc(text, func(r rune) bool { return r == ' ' })
+	if len(words) == 0 {
+		return []string{lead + text}
+	}
+
+	m := leadPattern.FindStringSubmatch(lead)
+	cont := m[1] + m[2] + strings.Repeat(" ", len(m[3]))
+
+	var lines []string
+	current := lead + words[0]
+	for i, word := range words[1:] {
+		prev := words[i]
+		var breakHere bool
+		switch reflow {
+		case ReflowWidth:
+			breakHere = utf8.RuneCountInString(current)+1+utf8.RuneCountInString(word) > width
+		case ReflowSentence:
+			breakHere = endsSentence(prev, word)
+		}
+		if breakHere && !blockWordPattern.MatchString(word) {
+			lines = append(lines, current)
+			current = cont + word
+			continue
+		}
+		current += " " + word
+	}
+	if hardBreak {
+		current += "\\"
+	}
+	return append(lines, current)
+}
+
+// endsSentence reports whether word ends a sentence that next starts
+// another
+func endsSentence(word, next string) bool {
+	end := strings.TrimRight(word, `"')]*_’”`)
+	if end == "" || !strings.ContainsAny(end[len(end)-1:], ".!?") {
+		return false
+	}
+	if abbreviations[strings.ToLower(strings.TrimLeft(end, `"'([*_‘“`))] {
+		return false
+	}
+	first, _ := utf8.DecodeRuneInString(strings.TrimLeft(next, `"'([*_‘“`))
+	return unicode.IsUpper(first) || unicode.IsDigit(first)
+}
@@ -0,0 +1,126 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestConverter_Layout(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		markdown string
+		want     string
+	}{
+		{
+			name:     "no options",
+			config:   Config{},
+			markdown: "One.  \n\n\n\nTwo.\n",
+			want:     "One.  \n\n\n\nTwo.\n",
+		},
+		{
+			name:     "wrap at width",
+			config:   Config{Reflow: ReflowWidth, WrapWidth: 20},
+			markdown: "The quick brown fox jumps over the lazy dog.\n",
+			want:     "The quick brown fox\njumps over the lazy\ndog.\n",
+		},
+		{
+			name:     "wrapped list items and quotes keep their indentation",
+			config:   Config{Reflow: ReflowWidth, WrapWidth: 16},
+			markdown: "- first item of the list\n  - nested item text\n10. tenth item here\n> quoted text in a block\n",
+			want:     "- first item of\n  the list\n  - nested item\n    text\n10. tenth item\n    here\n> quoted text in\n> a block\n",
+		},
+		{
+			name:     "no break before block markers",
+			config:   Config{Reflow: ReflowWidth, WrapWidth: 10},
+			markdown: "Released in 2004. - after # one\n",
+			want:     "Released\nin 2004. -\nafter #\none\n",
+		},
+		{
+			name:     "one sentence per line",
+			config:   Config{Reflow: ReflowSentence},
+			markdown: "Actors tick. Pawns move, e.g. bots! Why? \"Quoted.\" The end (really).\n- Item one. Item two.\n",
+			want:     "Actors tick.\nPawns move, e.g. bots!\nWhy?\n\"Quoted.\"\nThe end (really).\n- Item one.\n  Item two.\n",
+		},
+		{
+			name:     "hard breaks, code, tables and headings are kept",
+			config:   Config{Reflow: ReflowWidth, WrapWidth: 10},
+			markdown: "# A long heading text\n\nfirst line here\\\nsecond\n\n```\ncode that is long\n```\n\n| a long | table row |\n",
+			want:     "# A long heading text\n\nfirst line\nhere\\\nsecond\n\n```\ncode that is long\n```\n\n| a long | table row |\n",
+		},
+		{
+			name:     "raw HTML is kept",
+			config:   Config{Reflow: ReflowWidth, WrapWidth: 10},
+			markdown: "<!-- raw HTML: <applet> -->\n<applet code=\"A\">\nsome long text inside\n</applet>\n<!-- end raw HTML -->\n",
+			want:     "<!-- raw HTML: <applet> -->\n<applet code=\"A\">\nsome long text inside\n</applet>\n<!-- end raw HTML -->\n",
+		},
+		{
+			name:     "trim trailing space outside code",
+			config:   Config{TrimTrailingSpace: true},
+			markdown: "Text  \n\n```\ncode  \n```\n- item\t\n",
+			want:     "Text\n\n```\ncode  \n```\n- item\n",
+		},
+		{
+			name:     "collapse blank lines",
+			config:   Config{CollapseBlankLines: true},
+			markdown: "\n\nOne\n\n\n  \nTwo\n\n```\n\n\n```\n\n\n",
+			want:     "One\n\nTwo\n\n```\n\n\n```\n",
+		},
+		{
+			name:     "non-breaking spaces are kept",
+			config:   Config{Reflow: ReflowWidth, WrapWidth: 8},
+			markdown: "Range 5 m to 10 m\n",
+			want:     "Range\n5 m to\n10 m\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := New(tt.config).layout(tt.markdown); got != tt.want {
+				t.Errorf("layout() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestConverter_LayoutCorpus checks that reflowing the corpus keeps lines
+// within the width, unless a single word is longer, and is idempotent
+func TestConverter_LayoutCorpus(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join(corpusDir, "*", "*.html"))
+	if err != nil || len(inputs) == 0 {
+		t.Fatalf("no pages found in corpus: %v", err)
+	}
+
+	c := New(Config{Reflow: ReflowWidth, WrapWidth: 60, TrimTrailingSpace: true, CollapseBlankLines: true})
+	for _, input := range inputs {
+		f, err := os.Open(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.Convert(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("Convert(%s) error = %v", input, err)
+		}
+
+		if again := c.layout(got); again != got {
+			t.Errorf("%s: layout is not idempotent", input)
+		}
+		fence := false
+		for _, line := range strings.Split(got, "\n") {
+			if strings.HasPrefix(strings.TrimLeft(line, "> "), "```") {
+				fence = !fence
+			}
+			text := strings.TrimLeft(line, "> ")
+			if fence || !reflowable(text) || !strings.Contains(strings.TrimSpace(line), " ") {
+				continue
+			}
+			if utf8.RuneCountInString(line) > 60 && !strings.Contains(line, "<") {
+				t.Errorf("%s: line longer than 60 columns: %q", input, line)
+			}
+		}
+	}
+}
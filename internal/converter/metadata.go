@@ -0,0 +1,146 @@
+package converter
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+
+	"github.com/aldehir/ue2-docs/internal/parser"
+)
+
+// DefaultDatePatterns returns the patterns matching the dates TWiki prints
+// in page footers, such as "r1.4 - 05 Jun 2009 - 14:23 - Main.SomeUser"
+// or "Last changed on 05 Jun 2009". The first group of each captures the
+// date
+func DefaultDatePatterns() []*regexp.Regexp {
+	return []*regexp.Regexp{
+		regexp.MustCompile(`\br\d+(?:\.\d+)* - (\d{1,2} [A-Z][a-z]{2} \d{4})\b`),
+		regexp.MustCompile(`(?i)\blast (?:updated|modified|changed|edited)\b\D{0,40}?(\d{1,2} [a-z]{3,9},? \d{4}|\d{4}-\d{2}-\d{2}|[a-z]{3,9} \d{1,2},? \d{4})\b`),
+	}
+}
+
+// LoadDatePatterns reads date patterns from a file with one regular
+// expression per line, whose first group captures the date. Blank lines
+// and lines starting with '#' are ignored
+func LoadDatePatterns(filename string) ([]*regexp.Regexp, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("opening date patterns: %w", err)
+	}
+	defer f.Close()
+
+	var patterns []*regexp.Regexp
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		re, err := regexp.Compile(line)
+		if err != nil {
+			return nil, fmt.Errorf("date patterns line %d: %w", lineNum, err)
+		}
+		if re.NumSubexp() < 1 {
+			return nil, fmt.Errorf("date patterns line %d: no group capturing the date", lineNum)
+		}
+		patterns = append(patterns, re)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading date patterns: %w", err)
+	}
+
+	return patterns, nil
+}
+
+// dateLayouts are the formats dates captured by date patterns are parsed in
+var dateLayouts = []string{
+	"2 Jan 2006",
+	"2 January 2006",
+	"2 Jan, 2006",
+	"2 January, 2006",
+	"Jan 2 2006",
+	"Jan 2, 2006",
+	"January 2 2006",
+	"January 2, 2006",
+	time.DateOnly,
+	"2006/01/02",
+	"2006.01.02",
+}
+
+// parseDate parses a date in one of dateLayouts, ignoring case
+func parseDate(s string) (time.Time, bool) {
+	// Month names are matched case-sensitively, so normalize to "Jun"
+	words := strings.Fields(strings.ToLower(s))
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	s = strings.Join(words, " ")
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// pageDate returns the latest date matched by any of patterns in a page's
+// text, or the zero time if none matches
+func pageDate(doc *html.Node, patterns []*regexp.Regexp) time.Time {
+	text := parser.SpacedText(doc)
+
+	var latest time.Time
+	for _, re := range patterns {
+		for _, m := range re.FindAllStringSubmatch(text, -1) {
+			if len(m) < 2 {
+				continue
+			}
+			if t, ok := parseDate(m[1]); ok && t.After(latest) {
+				latest = t
+			}
+		}
+	}
+	return latest
+}
+
+// frontMatter returns the YAML front matter for the page converted to
+// mdRel, or "" unless enabled. Besides the title and date it keeps the
+// page's TWiki topic metadata, which the conversion otherwise loses, and
+// the pages in links linking to it
+func (c *Converter) frontMatter(doc *html.Node, mdRel string, links backlinks) string {
+	if !c.config.FrontMatter {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("---\n")
+	if title := pageTitle(doc); title != "" {
+		fmt.Fprintf(&sb, "title: %s\n", strconv.Quote(title))
+	}
+	if date := pageDate(doc, c.datePatterns); !date.IsZero() {
+		fmt.Fprintf(&sb, "last_modified: %s\n", date.Format(time.DateOnly))
+	}
+	topic := parser.TopicInfo(doc)
+	for _, field := range []struct{ key, value string }{
+		{"author", topic.Author},
+		{"revision", topic.Revision},
+		{"parent", topic.Parent},
+	} {
+		if field.value != "" {
+			fmt.Fprintf(&sb, "%s: %s\n", field.key, strconv.Quote(field.value))
+		}
+	}
+	sb.WriteString(links.frontMatter(mdRel))
+	sb.WriteString("---\n\n")
+	return sb.String()
+}
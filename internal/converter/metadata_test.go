@@ -0,0 +1,71 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+func TestPageDate(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"twiki revision", `<p>r1.4 - 05 Jun 2009 - 14:23 - <a>Main.SomeUser</a></p>`, "2009-06-05"},
+		{"single digit day", `<p>r12 - 5 Jan 2004 - Main.SomeUser</p>`, "2004-01-05"},
+		{"last changed", `<p>Last changed on&nbsp;12 March 2003 by someone</p>`, "2003-03-12"},
+		{"month first", `<p>Last updated: Sep 9, 2005</p>`, "2005-09-09"},
+		{"iso", `<p>Last modified 2006-11-30</p>`, "2006-11-30"},
+		{"latest wins", `<p>r1.1 - 01 Feb 2002 - A</p><p>r1.2 - 03 Feb 2003 - B</p>`, "2003-02-03"},
+		{"invalid date", `<p>r1.1 - 31 Feb 2002 - A</p>`, ""},
+		{"none", `<p>Released in 2004</p>`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := html.Parse(strings.NewReader(tt.html))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := ""
+			if date := pageDate(doc, DefaultDatePatterns()); !date.IsZero() {
+				got = date.Format(time.DateOnly)
+			}
+			if got != tt.want {
+				t.Errorf("pageDate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverter_FrontMatter(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "Page.html")
+	os.WriteFile(src, []byte(`<title>Karma "Physics"</title><p>Body</p><p>Published 2004.07.15</p><p>r1.2 - 05 Jun 2009 - Main.SomeUser</p>`), 0644)
+
+	patterns := []*regexp.Regexp{regexp.MustCompile(`Published (\d{4}\.\d{2}\.\d{2})`)}
+	dst := filepath.Join(dir, "Page.md")
+	if err := New(Config{FrontMatter: true, DatePatterns: patterns}).ConvertFile(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(dst)
+	want := "---\ntitle: \"Karma \\\"Physics\\\"\"\nlast_modified: 2004-07-15\nauthor: \"SomeUser\"\nrevision: \"r1.2\"\n---\n\nBody\n\nPublished 2004.07.15\n\nr1.2 - 05 Jun 2009 - Main.SomeUser\n"
+	if string(got) != want {
+		t.Errorf("ConvertFile() = %q, want %q", got, want)
+	}
+
+	if _, err := LoadDatePatterns(filepath.Join(dir, "missing")); err == nil {
+		t.Error("LoadDatePatterns() of a missing file succeeded")
+	}
+	patternFile := filepath.Join(dir, "patterns.txt")
+	os.WriteFile(patternFile, []byte("# footer dates\n\nPublished (\\d{4})\\.(\\d{2})\n(unclosed\n"), 0644)
+	if _, err := LoadDatePatterns(patternFile); err == nil || !strings.Contains(err.Error(), "line 4") {
+		t.Errorf("LoadDatePatterns() error = %v, want one for line 4", err)
+	}
+}
@@ -0,0 +1,149 @@
+package converter
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aldehir/ue2-docs/internal/manifest"
+)
+
+// Stamp is where a converted page records where it came from
+type Stamp int
+
+const (
+	// StampNone leaves pages unstamped
+	StampNone Stamp = iota
+	// StampHeader starts each page, after its front matter, with a quoted
+	// provenance line
+	StampHeader
+	// StampFooter ends each page with a provenance line below a rule
+	StampFooter
+)
+
+// DefaultStampText is the provenance line of Config.StampText when unset
+const DefaultStampText = "Archived from {url} on {date}. {attribution}"
+
+// String returns the stamp name as accepted by ParseStamp
+func (s Stamp) String() string {
+	switch s {
+	case StampNone:
+		return "none"
+	case StampHeader:
+		return "header"
+	case StampFooter:
+		return "footer"
+	default:
+		return fmt.Sprintf("Stamp(%d)", int(s))
+	}
+}
+
+// ParseStamp parses "none", "header" or "footer"
+func ParseStamp(s string) (Stamp, error) {
+	switch s {
+	case "none":
+		return StampNone, nil
+	case "header":
+		return StampHeader, nil
+	case "footer":
+		return StampFooter, nil
+	default:
+		return 0, fmt.Errorf("unknown stamp %q (want none, header or footer)", s)
+	}
+}
+
+// stampLine returns the provenance line of the page saved as entry, an
+// entry without a URL being a page from outside the manifest
+func (c *Converter) stampLine(entry manifest.Entry) string {
+	text := c.config.StampText
+	if text == "" {
+		text = DefaultStampText
+	}
+	source := "an unknown source"
+	if entry.URL != "" {
+		source = "<" + entry.URL + ">"
+	}
+	date := "an unknown date"
+	if !entry.SavedAt.IsZero() {
+		date = entry.SavedAt.UTC().Format(time.DateOnly)
+	}
+	return strings.TrimSpace(strings.NewReplacer(
+		"{url}", source,
+		"{date}", date,
+		"{attribution}", c.config.Attribution,
+	).Replace(text))
+}
+
+// stampHeader returns the provenance to start the page of entry with, or
+// "" unless Stamp is StampHeader
+func (c *Converter) stampHeader(entry manifest.Entry) string {
+	if c.config.Stamp != StampHeader {
+		return ""
+	}
+	return "> " + c.stampLine(entry) + "\n\n"
+}
+
+// stampFooter returns the provenance to end the page of entry with, or ""
+// unless Stamp is StampFooter
+func (c *Converter) stampFooter(entry manifest.Entry) string {
+	if c.config.Stamp != StampFooter {
+		return ""
+	}
+	return "\n---\n\n" + c.stampLine(entry) + "\n"
+}
+
+// notice renders the NOTICE file of a conversion of the mirror described
+// by entries: the sites it was scraped from, when, and the attribution
+func notice(entries []manifest.Entry, attribution string, converted time.Time) string {
+	var sb strings.Builder
+	sb.WriteString("NOTICE\n======\n\n")
+	sb.WriteString("These pages were converted by ue2-docs from an archived copy of\n")
+	sb.WriteString("documentation published on the web, kept for preservation.\n")
+	if attribution != "" {
+		sb.WriteString("\n" + attribution + "\n")
+	}
+
+	hosts := map[string]int{}
+	var first, last time.Time
+	for _, e := range entries {
+		u, err := url.Parse(e.URL)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		hosts[u.Scheme+"://"+u.Host]++
+		if e.SavedAt.IsZero() {
+			continue
+		}
+		if first.IsZero() || e.SavedAt.Before(first) {
+			first = e.SavedAt
+		}
+		if e.SavedAt.After(last) {
+			last = e.SavedAt
+		}
+	}
+
+	if len(hosts) > 0 {
+		sb.WriteString("\nSources:\n\n")
+		sites := make([]string, 0, len(hosts))
+		for site := range hosts {
+			sites = append(sites, site)
+		}
+		sort.Strings(sites)
+		for _, site := range sites {
+			fmt.Fprintf(&sb, "  %s (%d files)\n", site, hosts[site])
+		}
+	}
+	sb.WriteString("\n")
+	if !first.IsZero() {
+		from, to := first.UTC().Format(time.DateOnly), last.UTC().Format(time.DateOnly)
+		if from == to {
+			fmt.Fprintf(&sb, "Scraped:   %s\n", from)
+		} else {
+			fmt.Fprintf(&sb, "Scraped:   %s to %s\n", from, to)
+		}
+	}
+	fmt.Fprintf(&sb, "Converted: %s\n", converted.UTC().Format(time.DateOnly))
+	return sb.String()
+}
@@ -0,0 +1,69 @@
+package converter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aldehir/ue2-docs/internal/manifest"
+)
+
+func TestConverter_RunStamp(t *testing.T) {
+	input := t.TempDir()
+	saved := time.Date(2026, 9, 14, 10, 0, 0, 0, time.UTC)
+	m := manifest.New()
+	for _, name := range []string{"Actor.html", "Karma.html"} {
+		rel := "udn.epicgames.com/Two/" + name
+		path := filepath.Join(input, filepath.FromSlash(rel))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte("<p>Text</p>"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		m.Add(manifest.Entry{URL: "http://" + rel, Path: rel, SavedAt: saved})
+	}
+	if err := m.Save(filepath.Join(input, manifest.Filename)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		stamp Stamp
+		want  string
+	}{
+		{StampHeader, "> Archived from <http://udn.epicgames.com/Two/Actor.html> on 2026-09-14. Documentation (c) Epic Games.\n\nText\n"},
+		{StampFooter, "Text\n\n---\n\nArchived from <http://udn.epicgames.com/Two/Actor.html> on 2026-09-14. Documentation (c) Epic Games.\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.stamp.String(), func(t *testing.T) {
+			output := t.TempDir()
+			_, err := New(Config{
+				InputDir:          input,
+				OutputDir:         output,
+				PreserveStructure: true,
+				Stamp:             tt.stamp,
+				Attribution:       "Documentation (c) Epic Games.",
+				Notice:            "NOTICE",
+			}).Run(context.Background())
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			md, err := os.ReadFile(filepath.Join(output, "udn.epicgames.com", "Two", "Actor.md"))
+			if err != nil || string(md) != tt.want {
+				t.Errorf("page = %q, %v, want %q", md, err, tt.want)
+			}
+
+			notice, err := os.ReadFile(filepath.Join(output, "NOTICE"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range []string{"Documentation (c) Epic Games.", "http://udn.epicgames.com (2 files)", "Scraped:   2026-09-14\n"} {
+				if !strings.Contains(string(notice), want) {
+					t.Errorf("NOTICE missing %q:\n%s", want, notice)
+				}
+			}
+		})
+	}
+}
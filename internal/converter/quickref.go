@@ -0,0 +1,316 @@
+package converter
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// RefKind classifies a quick reference entry
+type RefKind int
+
+const (
+	RefConsoleCommand RefKind = iota
+	RefINISetting
+	RefExecFunction
+)
+
+// Reference is a console command, INI setting or exec function mentioned in
+// the documentation
+type Reference struct {
+	Kind  RefKind
+	Name  string   // Command, "[Section] Key", or function name
+	Usage string   // Example or signature, from the first mention
+	Pages []string // Slash-separated Markdown paths of the pages mentioning it
+}
+
+var (
+	// execPattern matches UnrealScript exec function declarations
+	execPattern = regexp.MustCompile(`\bexec\s+(?:(?:simulated|final|static|native)\s+)*function\s+(?:\w+\s+)?(\w+)\s*\(([^)]*)\)`)
+	// iniSection matches an INI section header line
+	iniSection = regexp.MustCompile(`^\s*\[([\w.]+)\]\s*$`)
+	// iniSetting matches a Key=Value line; +/- prefixes add to or remove from arrays
+	iniSetting = regexp.MustCompile(`^\s*[+\-.!]?(\w+)\s*=\s*(.*)$`)
+	// consoleCommand matches text that looks like a console command
+	consoleCommand = regexp.MustCompile(`^[A-Za-z][\w.]*(?: [^\s].*)?$`)
+)
+
+// maxCommandLength bounds inline code treated as a console command, so
+// whole code samples are not mistaken for commands
+const maxCommandLength = 60
+
+// quickRef accumulates references across converted pages
+type quickRef struct {
+	entries map[RefKind]map[string]*Reference
+}
+
+func newQuickRef() *quickRef {
+	return &quickRef{entries: make(map[RefKind]map[string]*Reference)}
+}
+
+// collect records the references found in a document converted to page
+func (q *quickRef) collect(doc *html.Node, page string) {
+	for _, ref := range extractReferences(doc) {
+		byName := q.entries[ref.Kind]
+		if byName == nil {
+			byName = make(map[string]*Reference)
+			q.entries[ref.Kind] = byName
+		}
+
+		key := strings.ToLower(ref.Name)
+		existing, ok := byName[key]
+		if !ok {
+			existing = &Reference{Kind: ref.Kind, Name: ref.Name, Usage: ref.Usage}
+			byName[key] = existing
+		}
+		if len(existing.Pages) == 0 || existing.Pages[len(existing.Pages)-1] != page {
+			existing.Pages = append(existing.Pages, page)
+		}
+	}
+}
+
+// Len returns the number of distinct references collected
+func (q *quickRef) Len() int {
+	n := 0
+	for _, byName := range q.entries {
+		n += len(byName)
+	}
+	return n
+}
+
+// render formats the collected references as a Markdown appendix written
+// to file, linking each entry back to its pages
+func (q *quickRef) render(file string) string {
+	var sb strings.Builder
+	sb.WriteString("# Quick Reference\n")
+
+	sections := []struct {
+		kind    RefKind
+		title   string
+		headers []string
+	}{
+		{RefConsoleCommand, "Console Commands", []string{"Command", "Pages"}},
+		{RefINISetting, "INI Settings", []string{"Setting", "Example", "Pages"}},
+		{RefExecFunction, "Exec Functions", []string{"Function", "Signature", "Pages"}},
+	}
+
+	for _, section := range sections {
+		refs := q.sorted(section.kind)
+		if len(refs) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&sb, "\n## %s\n\n", section.title)
+		sb.WriteString("| " + strings.Join(section.headers, " | ") + " |\n")
+		sb.WriteString("|" + strings.Repeat(" --- |", len(section.headers)) + "\n")
+
+		for _, ref := range refs {
+			cells := []string{inlineCode(ref.Name)}
+			if section.kind != RefConsoleCommand {
+				cells = append(cells, inlineCode(ref.Usage))
+			}
+			cells = append(cells, pageLinks(ref.Pages, file))
+			sb.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+		}
+	}
+
+	return sb.String()
+}
+
+// sorted returns the references of a kind ordered by name
+func (q *quickRef) sorted(kind RefKind) []*Reference {
+	var refs []*Reference
+	for _, ref := range q.entries[kind] {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return strings.ToLower(refs[i].Name) < strings.ToLower(refs[j].Name)
+	})
+	return refs
+}
+
+// pageLinks formats links to pages relative to the appendix file
+func pageLinks(pages []string, file string) string {
+	links := make([]string, len(pages))
+	for i, page := range pages {
+		title := strings.TrimSuffix(path.Base(page), path.Ext(page))
+		target, err := filepath.Rel(path.Dir(file), page)
+		if err != nil {
+			target = page
+		}
+		links[i] = "[" + escapeText(title) + "](" + filepath.ToSlash(target) + ")"
+	}
+	return strings.ReplaceAll(strings.Join(links, ", "), "|", `\|`)
+}
+
+// extractReferences finds console commands, INI settings and exec functions
+// in a document:
+//   - exec functions from UnrealScript declarations anywhere in the text
+//   - INI settings from Key=Value lines under a [Section] in code blocks
+//   - console commands from inline code in text mentioning the console, and
+//     from the first column of tables headed "Command"
+func extractReferences(doc *html.Node) []Reference {
+	var refs []Reference
+
+	root := findElement(doc, "body")
+	if root == nil {
+		root = doc
+	}
+
+	for _, m := range execPattern.FindAllStringSubmatch(textContent(root), -1) {
+		refs = append(refs, Reference{
+			Kind:  RefExecFunction,
+			Name:  m[1],
+			Usage: collapseSpaces(collapseWhitespace(m[1] + "(" + strings.TrimSpace(m[2]) + ")")),
+		})
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type != html.ElementNode {
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				walk(c)
+			}
+			return
+		}
+
+		switch n.Data {
+		case "pre":
+			refs = append(refs, iniSettings(textContent(n))...)
+			return
+		case "table":
+			refs = append(refs, commandTable(n)...)
+		case "p", "li", "dd", "td":
+			if strings.Contains(strings.ToLower(textContent(n)), "console") {
+				refs = append(refs, inlineCommands(n)...)
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+
+	return refs
+}
+
+// iniSettings extracts settings from INI snippets in a code block
+func iniSettings(code string) []Reference {
+	var refs []Reference
+	section := ""
+
+	for _, line := range strings.Split(code, "\n") {
+		if m := iniSection.FindStringSubmatch(line); m != nil {
+			section = m[1]
+			continue
+		}
+		if section == "" {
+			continue
+		}
+		if m := iniSetting.FindStringSubmatch(line); m != nil {
+			refs = append(refs, Reference{
+				Kind:  RefINISetting,
+				Name:  "[" + section + "] " + m[1],
+				Usage: m[1] + "=" + strings.TrimSpace(m[2]),
+			})
+		}
+	}
+
+	return refs
+}
+
+// inlineCommands treats the inline code in a block mentioning the console
+// as console commands
+func inlineCommands(block *html.Node) []Reference {
+	var refs []Reference
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "code", "tt", "kbd":
+				if name, ok := commandName(textContent(n)); ok {
+					refs = append(refs, Reference{Kind: RefConsoleCommand, Name: name})
+				}
+				return
+			case "p", "li", "dd", "td", "ul", "ol", "table", "pre":
+				if n != block {
+					// Nested blocks are visited on their own
+					return
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(block)
+
+	return refs
+}
+
+// commandTable extracts console commands from the first column of a table
+// whose first header cell mentions "command"
+func commandTable(table *html.Node) []Reference {
+	var rows []*html.Node
+	var findRows func(n *html.Node)
+	findRows = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			switch {
+			case c.Type == html.ElementNode && c.Data == "tr":
+				rows = append(rows, c)
+			case c.Type == html.ElementNode && c.Data == "table":
+				// Nested tables are handled separately
+			default:
+				findRows(c)
+			}
+		}
+	}
+	findRows(table)
+
+	if len(rows) < 2 {
+		return nil
+	}
+	header := firstCell(rows[0])
+	if header == nil || !strings.Contains(strings.ToLower(textContent(header)), "command") {
+		return nil
+	}
+
+	var refs []Reference
+	for _, row := range rows[1:] {
+		cell := firstCell(row)
+		if cell == nil {
+			continue
+		}
+		if name, ok := commandName(textContent(cell)); ok {
+			refs = append(refs, Reference{Kind: RefConsoleCommand, Name: name})
+		}
+	}
+	return refs
+}
+
+// firstCell returns the first th or td of a table row
+func firstCell(row *html.Node) *html.Node {
+	for c := row.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && (c.Data == "th" || c.Data == "td") {
+			return c
+		}
+	}
+	return nil
+}
+
+// commandName normalizes text that may be a console command
+// Returns false if the text does not look like one
+func commandName(text string) (string, bool) {
+	name := strings.TrimSpace(collapseWhitespace(strings.ReplaceAll(text, " ", " ")))
+	if name == "" || len(name) > maxCommandLength || !consoleCommand.MatchString(name) {
+		return "", false
+	}
+	return name, true
+}
@@ -0,0 +1,154 @@
+package converter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestExtractReferences(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want []Reference
+	}{
+		{
+			name: "exec function declaration",
+			html: `<pre>exec function SetJumpZ( float F )
+{
+}</pre>`,
+			want: []Reference{{Kind: RefExecFunction, Name: "SetJumpZ", Usage: "SetJumpZ(float F)"}},
+		},
+		{
+			name: "ini settings under a section",
+			html: `<pre>[Engine.GameInfo]
+bChangeLevels=True
++ServerActors=IpDrv.UdpBeacon
+; comment</pre>`,
+			want: []Reference{
+				{Kind: RefINISetting, Name: "[Engine.GameInfo] bChangeLevels", Usage: "bChangeLevels=True"},
+				{Kind: RefINISetting, Name: "[Engine.GameInfo] ServerActors", Usage: "ServerActors=IpDrv.UdpBeacon"},
+			},
+		},
+		{
+			name: "key value lines outside a section are ignored",
+			html: `<pre>X=1</pre>`,
+		},
+		{
+			name: "inline code in text about the console",
+			html: `<p>Open the console and type <code>stat fps</code> or <tt>togglescreenshotmode</tt>.</p>`,
+			want: []Reference{
+				{Kind: RefConsoleCommand, Name: "stat fps"},
+				{Kind: RefConsoleCommand, Name: "togglescreenshotmode"},
+			},
+		},
+		{
+			name: "inline code elsewhere is not a command",
+			html: `<p>Set <code>bHidden</code> to true.</p>`,
+		},
+		{
+			name: "first column of a command table",
+			html: `<table><tr><th>Command</th><th>Description</th></tr>
+<tr><td>rmode 1</td><td>Wireframe</td></tr>
+<tr><td>ghost</td><td>Fly through walls</td></tr></table>`,
+			want: []Reference{
+				{Kind: RefConsoleCommand, Name: "rmode 1"},
+				{Kind: RefConsoleCommand, Name: "ghost"},
+			},
+		},
+		{
+			name: "tables with other headers are skipped",
+			html: `<table><tr><th>Property</th></tr><tr><td>DrawScale</td></tr></table>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := html.Parse(strings.NewReader(tt.html))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := extractReferences(doc)
+			if len(got) != len(tt.want) {
+				t.Fatalf("extractReferences() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].Kind != tt.want[i].Kind || got[i].Name != tt.want[i].Name || got[i].Usage != tt.want[i].Usage {
+					t.Errorf("extractReferences()[%d] = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestQuickRef_Render(t *testing.T) {
+	q := newQuickRef()
+	for page, src := range map[string]string{
+		"Two/ConsoleCommands.md": `<table><tr><th>Command</th></tr><tr><td>ghost</td></tr><tr><td>fly</td></tr></table>`,
+		"Two/Cheats.md":          `<p>In the console, type <code>Ghost</code>.</p>`,
+	} {
+		doc, err := html.Parse(strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		q.collect(doc, page)
+	}
+
+	if q.Len() != 2 {
+		t.Errorf("Len() = %d, want 2 (commands are case-insensitive)", q.Len())
+	}
+
+	got := q.render("QuickReference.md")
+	for _, want := range []string{
+		"## Console Commands\n\n| Command | Pages |\n| --- | --- |\n",
+		"| `fly` | [ConsoleCommands](Two/ConsoleCommands.md) |\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("render() missing %q in:\n%s", want, got)
+		}
+	}
+	if !strings.Contains(got, "[Cheats](Two/Cheats.md)") || !strings.Contains(got, "[ConsoleCommands](Two/ConsoleCommands.md)") {
+		t.Errorf("render() should link ghost to both pages:\n%s", got)
+	}
+	if strings.Contains(got, "INI Settings") {
+		t.Errorf("render() should omit empty sections:\n%s", got)
+	}
+
+	nested := q.render("appendix/QuickReference.md")
+	if !strings.Contains(nested, "(../Two/ConsoleCommands.md)") {
+		t.Errorf("links should be relative to the appendix:\n%s", nested)
+	}
+}
+
+func TestConverter_RunQuickReference(t *testing.T) {
+	input := t.TempDir()
+	output := t.TempDir()
+
+	page := filepath.Join(input, "example.com", "Two", "Engine.html")
+	os.MkdirAll(filepath.Dir(page), 0755)
+	if err := os.WriteFile(page, []byte("<pre>[Engine.Engine]\nGameRenderDevice=D3DDrv.D3DRenderDevice</pre>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := New(Config{InputDir: input, OutputDir: output, PreserveStructure: true, QuickReference: "QuickReference.md"}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.References != 1 {
+		t.Errorf("References = %d, want 1", result.References)
+	}
+
+	md, err := os.ReadFile(filepath.Join(output, "QuickReference.md"))
+	if err != nil {
+		t.Fatalf("reading quick reference: %v", err)
+	}
+	want := "| `[Engine.Engine] GameRenderDevice` | `GameRenderDevice=D3DDrv.D3DRenderDevice` | [Engine](example.com/Two/Engine.md) |"
+	if !strings.Contains(string(md), want) {
+		t.Errorf("quick reference = %q, want row %q", md, want)
+	}
+}
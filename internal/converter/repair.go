@@ -0,0 +1,215 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// repair is a kind of fix made to a page's HTML
+type repair struct {
+	problem string // e.g. "unclosed" or "stray"
+	tag     string // e.g. "<b>" or "</div>"
+}
+
+// repairs counts the fixes made to a page's HTML by kind
+type repairs map[repair]int
+
+// String lists the repairs, e.g. "unclosed `<b>` (2), stray `</div>`"
+func (r repairs) String() string {
+	kinds := make([]repair, 0, len(r))
+	for k := range r {
+		kinds = append(kinds, k)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		if kinds[i].problem != kinds[j].problem {
+			return kinds[i].problem < kinds[j].problem
+		}
+		return kinds[i].tag < kinds[j].tag
+	})
+
+	parts := make([]string, len(kinds))
+	for i, k := range kinds {
+		parts[i] = fmt.Sprintf("%s `%s`", k.problem, k.tag)
+		if n := r[k]; n > 1 {
+			parts[i] += fmt.Sprintf(" (%d)", n)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// optionalEnd lists the elements whose end tag HTML lets pages leave out
+var optionalEnd = map[string]bool{
+	"html": true, "head": true, "body": true, "p": true, "li": true,
+	"dt": true, "dd": true, "option": true, "optgroup": true, "colgroup": true,
+	"thead": true, "tbody": true, "tfoot": true, "tr": true, "td": true, "th": true,
+	"rb": true, "rt": true, "rtc": true, "rp": true,
+}
+
+// voidElements lists the elements that never have content
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"param": true, "source": true, "track": true, "wbr": true,
+	"basefont": true, "frame": true, "isindex": true, "keygen": true,
+	"nop": true, // TWiki's WikiWord escape, never closed
+}
+
+// parse parses a decoded HTML document and repairs it for conversion. The
+// tolerant parser already recovers a tree from broken markup; the
+// tokenizer pass finds what it had to recover from, and fixups undo the
+// TWiki breakage it recovers from badly
+func parse(r io.Reader) (*html.Node, repairs, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	found := validate(data)
+
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	fixup(doc, found)
+
+	return doc, found, nil
+}
+
+// validate tokenizes a document, tracking open elements to find unclosed,
+// stray and improperly nested tags
+func validate(data []byte) repairs {
+	found := repairs{}
+	var open []string
+
+	unclosed := func(names []string) {
+		for _, name := range names {
+			if !optionalEnd[name] {
+				found[repair{"unclosed", "<" + name + ">"}]++
+			}
+		}
+	}
+
+	z := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			unclosed(open)
+			return found
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			if tag == "form" && contains(open, "form") {
+				found[repair{"nested", "<form>"}]++
+			}
+			if tt == html.StartTagToken && !voidElements[tag] {
+				open = append(open, tag)
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			if voidElements[tag] {
+				continue
+			}
+			i := lastIndex(open, tag)
+			if i < 0 {
+				found[repair{"stray", "</" + tag + ">"}]++
+				continue
+			}
+			unclosed(open[i+1:])
+			open = open[:i]
+		}
+	}
+}
+
+// fixup repairs TWiki breakage in a parsed document, recording the fixes
+func fixup(n *html.Node, found repairs) {
+	for child := n.FirstChild; child != nil; {
+		next := child.NextSibling
+		fixup(child, found)
+
+		if child.Type == html.ElementNode {
+			switch {
+			case child.Data == "nop":
+				// <nop> only stops TWiki linking the WikiWord after it
+				unwrap(child)
+				found[repair{"unwrapped", "<nop>"}]++
+
+			case (child.Data == "ul" || child.Data == "ol") && (n.Data == "ul" || n.Data == "ol"):
+				// Lists nested directly in their parent belong to the item
+				// before them
+				if item := prevElement(child); item != nil && item.Data == "li" {
+					n.RemoveChild(child)
+					item.AppendChild(child)
+					found[repair{"misplaced", "<" + child.Data + ">"}]++
+				}
+			}
+		}
+		child = next
+	}
+}
+
+// unwrap replaces an element with its children
+func unwrap(n *html.Node) {
+	for child := n.FirstChild; child != nil; child = n.FirstChild {
+		n.RemoveChild(child)
+		n.Parent.InsertBefore(child, n)
+	}
+	n.Parent.RemoveChild(n)
+}
+
+// prevElement returns the element before n among its siblings
+func prevElement(n *html.Node) *html.Node {
+	for sib := n.PrevSibling; sib != nil; sib = sib.PrevSibling {
+		if sib.Type == html.ElementNode {
+			return sib
+		}
+	}
+	return nil
+}
+
+// contains reports whether names holds name
+func contains(names []string, name string) bool {
+	return lastIndex(names, name) >= 0
+}
+
+// lastIndex returns the index of the last occurrence of name in names, or -1
+func lastIndex(names []string, name string) int {
+	for i := len(names) - 1; i >= 0; i-- {
+		if names[i] == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// repairReport lists the repairs made to each page, keyed by the page's
+// slash-separated path relative to the output directory. rel is the
+// report's own path
+func repairReport(rel string, pages map[string]repairs) string {
+	paths := make([]string, 0, len(pages))
+	for p := range pages {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var sb strings.Builder
+	sb.WriteString("# HTML Repairs\n\nBroken markup found in each page and repaired before conversion.\n\n")
+	for _, p := range paths {
+		target, err := filepath.Rel(path.Dir(rel), p)
+		if err != nil {
+			target = p
+		}
+		fmt.Fprintf(&sb, "- [%s](%s): %s\n", escapeText(p), filepath.ToSlash(target), pages[p])
+	}
+	return sb.String()
+}
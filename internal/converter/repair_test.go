@@ -0,0 +1,86 @@
+package converter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParse_Repairs(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"well formed", `<html><body><p>Text<br><b>bold</b></p><ul><li>a<li>b</ul></body></html>`, ""},
+		{"unclosed", `<div><b>bold<i>italic</div>`, "unclosed `<b>`, unclosed `<i>`"},
+		{"unclosed at end", `<table><tr><td><b>cell`, "unclosed `<b>`, unclosed `<table>`"},
+		{"stray", `<p>text</span></div></p>`, "stray `</div>`, stray `</span>`"},
+		{"nested forms", `<form><form action="x"><input></form></form>`, "nested `<form>`"},
+		{"nop", `<p><nop>WikiWord and <nop>OtherWord</p>`, "unwrapped `<nop>` (2)"},
+		{"nested list", `<ul><li>a</li><ul><li>b</li></ul></ul>`, "misplaced `<ul>`"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, found, err := parse(strings.NewReader(tt.html))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := found.String(); got != tt.want {
+				t.Errorf("repairs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_Fixups(t *testing.T) {
+	doc, _, err := parse(strings.NewReader(`<p>See <nop>WikiWord</p><ul><li>a</li><ol><li>b</li></ol><li>c</li></ul>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := findElement(doc, "p")
+	if p.FirstChild == nil || p.FirstChild.Data != "See " || p.FirstChild.NextSibling.Data != "WikiWord" {
+		t.Error("<nop> was not replaced by its text")
+	}
+	if ol := findElement(doc, "ol"); ol.Parent.Data != "li" || textContent(ol.Parent.FirstChild) != "a" {
+		t.Errorf("nested list parent = <%s>, want the item before it", ol.Parent.Data)
+	}
+	if got := New(Config{}).ConvertNode(doc); got != "See WikiWord\n\n- a\n  1. b\n- c\n" {
+		t.Errorf("ConvertNode() = %q", got)
+	}
+}
+
+func TestConverter_RunRepairReport(t *testing.T) {
+	input := t.TempDir()
+	output := t.TempDir()
+	for name, content := range map[string]string{
+		"Two/Broken.html": "<div><b>bold</div></span>",
+		"Two/Clean.html":  "<p>clean</p>",
+	} {
+		path := filepath.Join(input, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(content), 0644)
+	}
+
+	config := Config{InputDir: input, OutputDir: output, PreserveStructure: true, RepairReport: "reports/repairs.md"}
+	result, err := New(config).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Repaired != 1 {
+		t.Errorf("Repaired = %d, want 1", result.Repaired)
+	}
+
+	report, err := os.ReadFile(filepath.Join(output, "reports", "repairs.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "- [Two/Broken.md](../Two/Broken.md): stray `</span>`, unclosed `<b>`\n"
+	if !strings.HasSuffix(string(report), want) || strings.Contains(string(report), "Clean") {
+		t.Errorf("report = %q, want only %q", report, want)
+	}
+}
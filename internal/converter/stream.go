@@ -0,0 +1,313 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/aldehir/ue2-docs/internal/manifest"
+)
+
+// DefaultStreamThreshold is the size of a stored page above which Run
+// converts it by streaming when Config.StreamThreshold is not set
+const DefaultStreamThreshold = 8 << 20
+
+// headElements lists the elements a page's head is made of. Any other
+// element starts its body, whether or not a <body> tag does
+var headElements = map[string]bool{
+	"html": true, "head": true, "title": true, "meta": true, "link": true,
+	"style": true, "script": true, "base": true, "noscript": true,
+	"template": true,
+}
+
+// wrapperElements lists the generic containers that render as nothing but
+// their children, so a streamed page converts those one at a time
+var wrapperElements = map[string]bool{
+	"article": true, "aside": true, "center": true, "div": true,
+	"footer": true, "header": true, "main": true, "nav": true,
+	"section": true,
+}
+
+// streams reports whether Run converts the page of d by streaming
+func (c *Converter) streams(d fs.DirEntry) bool {
+	threshold := c.config.StreamThreshold
+	switch {
+	case threshold < 0:
+		return false
+	case threshold == 0:
+		threshold = DefaultStreamThreshold
+	}
+	info, err := d.Info()
+	return err == nil && info.Size() > threshold
+}
+
+// convertStreamed converts the page at src by streaming, writing it after
+// front matter made from the page's head and header, and before footer. The page goes
+// straight to the outputs unless they need it whole, as Update and
+// FormatNDJSON do. Returns the page's head, for its title and metadata,
+// and the repairs made
+func (c *Converter) convertStreamed(src, mdRel string, entry manifest.Entry, out *outputs, changes *changelog, links backlinks, header, footer string, visit func(*html.Node)) (*html.Node, repairs, error) {
+	head, err := readHead(src)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var found repairs
+	write := func(w io.Writer) error {
+		if _, err := io.WriteString(w, c.frontMatter(head, mdRel, links)+header); err != nil {
+			return err
+		}
+		var err error
+		if found, err = c.streamBody(src, w, visit); err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, footer)
+		return err
+	}
+
+	if changes == nil && out.enc == nil {
+		return head, found, out.stream(mdRel, write)
+	}
+
+	var sb strings.Builder
+	if err := write(&sb); err != nil {
+		return head, found, err
+	}
+	markdown := sb.String()
+	if out.markdown {
+		if err := c.writePage(mdRel, pageTitle(head), markdown, changes); err != nil {
+			return head, found, err
+		}
+	}
+	return head, found, out.page(mdRel, entry, pageTitle(head), markdown)
+}
+
+// headTracker follows a page's tokens to tell its head from its body
+type headTracker struct {
+	body bool   // The body has started
+	skip string // Head element whose content is being skipped
+}
+
+// inBody reports whether a token belongs to the body, which starts after a
+// <body> tag or with the first token that cannot be part of a head
+func (h *headTracker) inBody(tt html.TokenType, name string, raw []byte) bool {
+	if h.body {
+		return true
+	}
+	switch tt {
+	case html.StartTagToken, html.SelfClosingTagToken:
+		if name == "body" {
+			h.body = true
+			return false
+		}
+		if headElements[name] {
+			if h.skip == "" && tt == html.StartTagToken && name != "html" && name != "head" && !voidElements[name] {
+				h.skip = name
+			}
+			return false
+		}
+	case html.EndTagToken:
+		if name == h.skip {
+			h.skip = ""
+		}
+		return false
+	case html.TextToken:
+		if h.skip != "" || len(bytes.TrimSpace(raw)) == 0 {
+			return false
+		}
+	default:
+		return false
+	}
+	h.body = true
+	return true
+}
+
+// readHead parses the head of the page at src, reading no further than the
+// start of its body
+func readHead(src string) (*html.Node, error) {
+	in, err := openPage(src)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	var head bytes.Buffer
+	var tracker headTracker
+	z := html.NewTokenizer(in)
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if err := z.Err(); err != io.EOF {
+				return nil, fmt.Errorf("converting %s: parsing HTML: %w", src, err)
+			}
+			break
+		}
+		if tracker.inBody(tt, tagName(z, tt), z.Raw()) {
+			break
+		}
+		head.Write(z.Raw())
+	}
+
+	doc, err := html.Parse(&head)
+	if err != nil {
+		return nil, fmt.Errorf("converting %s: parsing HTML: %w", src, err)
+	}
+	return doc, nil
+}
+
+// tagName returns the name of the tag z is at, or "" for other tokens
+func tagName(z *html.Tokenizer, tt html.TokenType) string {
+	switch tt {
+	case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+		name, _ := z.TagName()
+		return string(name)
+	}
+	return ""
+}
+
+// streamBody converts the body of the page at src to Markdown, writing it
+// to w. Rather than parsing the whole page, it parses and converts one
+// top-level block at a time, looking through generic containers such as
+// TWiki's layout <div>s, so memory is bounded by the largest block instead
+// of the page. visit, if not nil, is called with each parsed part of the
+// body. Streamed pages get no table of contents
+func (c *Converter) streamBody(src string, w io.Writer, visit func(*html.Node)) (repairs, error) {
+	in, err := openPage(src)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	sc := *c
+	sc.config.TOC = false
+	s := &bodyStream{c: &sc, w: w, visit: visit, found: repairs{}, anchors: NewAnchorSet()}
+
+	var tracker headTracker
+	var open, wrappers []string
+	z := html.NewTokenizer(in)
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if err := z.Err(); err != io.EOF {
+				return s.found, fmt.Errorf("converting %s: parsing HTML: %w", src, err)
+			}
+			break
+		}
+		name := tagName(z, tt)
+		if !tracker.inBody(tt, name, z.Raw()) || name == "body" || name == "html" {
+			continue
+		}
+
+		complete := false
+		switch tt {
+		case html.StartTagToken:
+			if len(open) == 1 && open[0] == "p" && blockElements[name] {
+				// The block closes the paragraph left open before it
+				open = nil
+				if err := s.flush(); err != nil {
+					return s.found, err
+				}
+			}
+			if len(open) == 0 && wrapperElements[name] {
+				if err := s.flush(); err != nil {
+					return s.found, err
+				}
+				wrappers = append(wrappers, name)
+				continue
+			}
+			if voidElements[name] {
+				complete = true
+			} else {
+				open = append(open, name)
+			}
+
+		case html.SelfClosingTagToken:
+			complete = true
+
+		case html.EndTagToken:
+			if len(open) == 0 {
+				if i := lastIndex(wrappers, name); i >= 0 {
+					wrappers = wrappers[:i]
+					if err := s.flush(); err != nil {
+						return s.found, err
+					}
+					continue
+				}
+			}
+			if i := lastIndex(open, name); i >= 0 {
+				open = open[:i]
+				complete = true
+			}
+		}
+
+		s.part.Write(z.Raw())
+		if complete && len(open) == 0 && blockElements[name] {
+			if err := s.flush(); err != nil {
+				return s.found, err
+			}
+		}
+	}
+
+	if err := s.flush(); err != nil {
+		return s.found, err
+	}
+	return s.found, nil
+}
+
+// bodyStream converts the parts of a streamed page's body as they end
+type bodyStream struct {
+	c       *Converter
+	w       io.Writer
+	visit   func(*html.Node)
+	part    bytes.Buffer // HTML of the part read so far
+	found   repairs
+	anchors AnchorSet
+	wrote   bool
+}
+
+// flush converts the part read so far and writes its Markdown
+func (s *bodyStream) flush() error {
+	if s.part.Len() == 0 {
+		return nil
+	}
+	data := s.part.Bytes()
+	defer s.part.Reset()
+
+	for r, n := range validate(data) {
+		s.found[r] += n
+	}
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(bytes.NewReader(data), body)
+	if err != nil {
+		return fmt.Errorf("parsing HTML: %w", err)
+	}
+	for _, n := range nodes {
+		body.AppendChild(n)
+	}
+	fixup(body, s.found)
+	imageMapLinks(body)
+	if s.visit != nil {
+		s.visit(body)
+	}
+
+	blocks := s.c.renderBlocks(body)
+	if s.c.config.HeadingIDs {
+		assignAnchors(blocks, s.anchors)
+	}
+	out := joinBlocks(blocks)
+	if out == "" {
+		return nil
+	}
+	out = s.c.layout(out + "\n")
+	if s.wrote {
+		out = "\n" + out
+	}
+	s.wrote = true
+	_, err = io.WriteString(s.w, out)
+	return err
+}
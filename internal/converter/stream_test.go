@@ -0,0 +1,60 @@
+package converter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConverter_RunStreamed(t *testing.T) {
+	input := t.TempDir()
+	page := `<html><head><title>Actor Index</title><meta name="robots" content="noindex"></head>
+<body><div id="patternPage"><div class="patternTopic">
+<h1>Actor Index</h1>
+Loose text with <b>bold</b>
+<p>First paragraph
+<h2><a name="Functions"></a>Functions</h2>
+<p>Second <a href="Other.html">link</a></p>
+<ul><li>One<li>Two</ul>
+<center><table><tr><th>Name</th><th>Type</th></tr><tr><td>Health</td><td>int</td></tr></table></center>
+<h2>Functions</h2>
+<pre>function Tick();</pre>
+<hr>
+</div></div>
+Trailing text
+</body></html>`
+	if err := os.WriteFile(filepath.Join(input, "Index.html"), []byte(page), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	convert := func(threshold int64) string {
+		output := t.TempDir()
+		config := Config{
+			InputDir:          input,
+			OutputDir:         output,
+			PreserveStructure: true,
+			HeadingIDs:        true,
+			FrontMatter:       true,
+			StreamThreshold:   threshold,
+		}
+		result, err := New(config).Run(context.Background())
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if result.Converted != 1 {
+			t.Fatalf("Run() = %+v, want 1 converted", result)
+		}
+		data, err := os.ReadFile(filepath.Join(output, "Index.md"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	whole := convert(-1)
+	streamed := convert(1)
+	if streamed != whole {
+		t.Errorf("streamed conversion differs:\n%s\nwant:\n%s", streamed, whole)
+	}
+}
@@ -0,0 +1,121 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/aldehir/ue2-docs/internal/storage"
+)
+
+// wikiWordPattern matches TWiki topic names, CamelCase words such as
+// ActorVariables
+var wikiWordPattern = regexp.MustCompile(`^[A-Z]+[a-z0-9]+[A-Z][A-Za-z0-9]*$`)
+
+// titleSeparators split the decorations TWiki adds to page titles, as in
+// "ActorVariables < Two < TWiki" or "UDN - Two - ActorVariables"
+var titleSeparators = regexp.MustCompile(`\s+[<>|\-–.:]\s+`)
+
+// topicTitles maps topic names to the human titles of their pages
+type topicTitles map[string]string
+
+// collectTitles reads the <title> of every HTML page under the input
+// directory, keyed by topic name, the page's file name without extension
+func (c *Converter) collectTitles(ctx context.Context) (topicTitles, error) {
+	titles := make(topicTitles)
+
+	err := filepath.WalkDir(c.config.InputDir, func(src string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if rel, _ := filepath.Rel(c.config.InputDir, src); rel == storage.OriginalDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isHTMLFile(src) {
+			return nil
+		}
+
+		// Pages that fail to parse are reported by the conversion itself
+		doc, _, err := parseFile(src)
+		if err != nil {
+			return nil
+		}
+		name := pagePath(filepath.Base(src))
+		topic := strings.TrimSuffix(name, filepath.Ext(name))
+		if _, ok := titles[topic]; !ok && wikiWordPattern.MatchString(topic) {
+			titles[topic] = humanTitle(pageTitle(doc), topic)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("collecting titles in %s: %w", c.config.InputDir, err)
+	}
+	return titles, nil
+}
+
+// linkTitle returns the human title to show instead of text, the text of a
+// link to href, or "" to keep the text. Only links whose text is the bare
+// topic name of the page they point at are retitled
+func (t topicTitles) linkTitle(text, href string) string {
+	if !wikiWordPattern.MatchString(text) {
+		return ""
+	}
+	target := href
+	if i := strings.IndexAny(target, "?#"); i >= 0 {
+		target = target[:i]
+	}
+	if strings.TrimSuffix(path.Base(target), path.Ext(target)) != text {
+		return ""
+	}
+	if title, ok := t[text]; ok {
+		return title
+	}
+	return splitWikiWord(text)
+}
+
+// humanTitle picks the human title of topic from its page's <title>: the
+// part of the title that spells out the topic name in words, or the whole
+// title if it is undecorated and differs from the topic name. Otherwise
+// the topic name is split into words
+func humanTitle(title, topic string) string {
+	parts := titleSeparators.Split(strings.TrimSpace(title), -1)
+	for _, part := range parts {
+		if strings.Contains(part, " ") && strings.EqualFold(strings.Join(strings.Fields(part), ""), topic) {
+			return part
+		}
+	}
+	if len(parts) == 1 && parts[0] != "" && parts[0] != topic {
+		return parts[0]
+	}
+	return splitWikiWord(topic)
+}
+
+// splitWikiWord splits a CamelCase topic name into words, keeping
+// acronyms and trailing digits together: "HUDOverlayTutorial2" becomes
+// "HUD Overlay Tutorial2"
+func splitWikiWord(word string) string {
+	runes := []rune(word)
+	var sb strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				sb.WriteByte(' ')
+			}
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,101 @@
+package converter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitWikiWord(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{"ActorVariables", "Actor Variables"},
+		{"UnrealScriptReference", "Unreal Script Reference"},
+		{"HUDOverlayTutorial2", "HUD Overlay Tutorial2"},
+		{"Version2Features", "Version2 Features"},
+		{"WebHome", "Web Home"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.word, func(t *testing.T) {
+			if got := splitWikiWord(tt.word); got != tt.want {
+				t.Errorf("splitWikiWord(%q) = %q, want %q", tt.word, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHumanTitle(t *testing.T) {
+	tests := []struct {
+		title string
+		topic string
+		want  string
+	}{
+		{"Actor Variables", "ActorVariables", "Actor Variables"},
+		{"UDN - Two - Actor Variables", "ActorVariables", "Actor Variables"},
+		{"ActorVariables < Two < TWiki", "ActorVariables", "Actor Variables"},
+		{"Variables Of Actors", "ActorVariables", "Variables Of Actors"},
+		{"ActorVariables", "ActorVariables", "Actor Variables"},
+		{"", "ActorVariables", "Actor Variables"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			if got := humanTitle(tt.title, tt.topic); got != tt.want {
+				t.Errorf("humanTitle(%q, %q) = %q, want %q", tt.title, tt.topic, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverter_RunPrettyTitles(t *testing.T) {
+	input := t.TempDir()
+	output := t.TempDir()
+
+	files := map[string]string{
+		"Two/WebHome.html": `<title>WebHome &lt; Two &lt; TWiki</title><p>` +
+			`<a href="ActorVariables.html">ActorVariables</a> ` +
+			`<a href="ActorVariables.html#Physics">the physics</a> ` +
+			`<a href="PawnStates.html">PawnStates</a> ` +
+			`<a href="https://udn.epicgames.com/Two/ExternalTopic.html">ExternalTopic</a></p>`,
+		"Two/ActorVariables.html": `<title>Variables Of Every Actor</title><p><a href="WebHome.html">WebHome</a></p>`,
+	}
+	for name, content := range files {
+		path := filepath.Join(input, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := New(Config{InputDir: input, OutputDir: output, PreserveStructure: true, PrettyTitles: true}).Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	home, err := os.ReadFile(filepath.Join(output, "Two", "WebHome.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"[Variables Of Every Actor](ActorVariables.md)",
+		"[the physics](ActorVariables.md#Physics)",
+		"[Pawn States](PawnStates.md)", // Not scraped: the name is split
+		"[External Topic](https://udn.epicgames.com/Two/ExternalTopic.html)",
+	} {
+		if !strings.Contains(string(home), want) {
+			t.Errorf("WebHome.md missing %q:\n%s", want, home)
+		}
+	}
+
+	actor, err := os.ReadFile(filepath.Join(output, "Two", "ActorVariables.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(actor), "[Web Home](WebHome.md)") {
+		t.Errorf("ActorVariables.md = %q, want the WebHome link titled from its name", actor)
+	}
+}
@@ -0,0 +1,180 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// Default heading levels included in a table of contents
+const (
+	defaultTOCMinLevel = 2
+	defaultTOCMaxLevel = 3
+)
+
+// addTOC inserts a table of contents after the page title, or at the top of
+// pages without one
+func (c *Converter) addTOC(blocks []block) []block {
+	minLevel, maxLevel := c.config.TOCMinLevel, c.config.TOCMaxLevel
+	if minLevel <= 0 {
+		minLevel = defaultTOCMinLevel
+	}
+	if maxLevel <= 0 {
+		maxLevel = max(defaultTOCMaxLevel, minLevel)
+	}
+
+	// Every heading takes part in making anchors unique, listed or not
+	anchors := NewAnchorSet()
+	var items []string
+	for _, b := range blocks {
+		if b.kind != blockHeading {
+			continue
+		}
+		anchor := b.anchor
+		if !c.config.HeadingIDs {
+			anchor = anchors.Add(b.title)
+		}
+		if b.level < minLevel || b.level > maxLevel || b.title == "" {
+			continue
+		}
+		indent := strings.Repeat("  ", b.level-minLevel)
+		items = append(items, fmt.Sprintf("%s- [%s](#%s)", indent, escapeText(b.title), anchor))
+	}
+	if len(items) < 2 {
+		return blocks
+	}
+
+	toc := block{kind: blockList, text: strings.Join(items, "\n")}
+	at := 0
+	if len(blocks) > 0 && blocks[0].kind == blockHeading && blocks[0].level == 1 {
+		at = 1
+	}
+	return append(blocks[:at:at], append([]block{toc}, blocks[at:]...)...)
+}
+
+// AnchorSet generates the anchors Markdown renderers such as GitHub give
+// headings, numbering repeated titles. Tables of contents link to them
+type AnchorSet map[string]int
+
+// NewAnchorSet returns an AnchorSet for the headings of one page
+func NewAnchorSet() AnchorSet {
+	return make(AnchorSet)
+}
+
+// Add returns the anchor of the next heading with the given title
+func (s AnchorSet) Add(title string) string {
+	slug := slugify(title)
+	n := s[slug]
+	s[slug]++
+	if n == 0 {
+		return slug
+	}
+	return fmt.Sprintf("%s-%d", slug, n)
+}
+
+// assignAnchors gives every heading an explicit anchor, appended to its
+// text as {#id}: its original one, unless an earlier heading took it, or
+// else the one generated from its title. anchors holds those already taken
+// on the page
+func assignAnchors(blocks []block, anchors AnchorSet) {
+	for i := range blocks {
+		b := &blocks[i]
+		if b.kind != blockHeading {
+			continue
+		}
+		if b.anchor != "" && anchors[b.anchor] == 0 {
+			anchors[b.anchor]++
+		} else {
+			b.anchor = anchors.Add(b.title)
+		}
+		if b.anchor != "" {
+			b.text += " {#" + b.anchor + "}"
+		}
+	}
+}
+
+// anchorPattern matches the anchors kept from the original HTML; others
+// could not be written as {#id}
+var anchorPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_:.-]*$`)
+
+// headingAnchor returns the anchor the original HTML gave a heading: its
+// id, or the name or id of an empty anchor inside it or just before it, as
+// TWiki writes them. Returns "" if it has none
+func headingAnchor(n *html.Node) string {
+	if id := getAttr(n, "id"); anchorPattern.MatchString(id) {
+		return id
+	}
+	if anchor := innerAnchor(n); anchor != "" {
+		return anchor
+	}
+	for prev := n.PrevSibling; prev != nil; prev = prev.PrevSibling {
+		if prev.Type == html.TextNode && strings.TrimSpace(prev.Data) == "" {
+			continue
+		}
+		if prev.Type == html.ElementNode && prev.Data == "a" && getAttr(prev, "href") == "" &&
+			strings.TrimSpace(textContent(prev)) == "" {
+			return namedAnchor(prev)
+		}
+		break
+	}
+	return ""
+}
+
+// innerAnchor returns the name or id of the first anchor inside n
+func innerAnchor(n *html.Node) string {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode {
+			continue
+		}
+		if child.Data == "a" {
+			if anchor := namedAnchor(child); anchor != "" {
+				return anchor
+			}
+		}
+		if anchor := innerAnchor(child); anchor != "" {
+			return anchor
+		}
+	}
+	return ""
+}
+
+// namedAnchor returns the name or id of an <a> element, if usable
+func namedAnchor(a *html.Node) string {
+	for _, key := range []string{"name", "id"} {
+		if anchor := getAttr(a, key); anchorPattern.MatchString(anchor) {
+			return anchor
+		}
+	}
+	return ""
+}
+
+// slugify lowercases a heading title, drops punctuation and replaces
+// spaces with hyphens
+func slugify(title string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(title)) {
+		switch {
+		case r == ' ':
+			sb.WriteByte('-')
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsNumber(r):
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// isTWikiTOC reports whether n is a table of contents rendered by TWiki
+func isTWikiTOC(n *html.Node) bool {
+	if n.Type != html.ElementNode || n.Data != "div" {
+		return false
+	}
+	for _, class := range strings.Fields(getAttr(n, "class")) {
+		if class == "twikiToc" {
+			return true
+		}
+	}
+	return false
+}
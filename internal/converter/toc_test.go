@@ -0,0 +1,113 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConvert_TOC(t *testing.T) {
+	page := `<h1><a name="UnrealScript"></a> UnrealScript </h1>
+<div class="twikiToc"><ul><li><a href="#Variables">Variables</a></li></ul></div>
+<h2><a name="Variables"></a> Variables </h2>
+<h3>Simple <i>Variables</i></h3>
+<h4>Too Deep</h4>
+<h2>Functions &amp; Events</h2>
+<h3>Simple Variables</h3>`
+
+	tests := []struct {
+		name   string
+		config Config
+		html   string
+		want   string
+	}{
+		{
+			name:   "default levels after title",
+			config: Config{TOC: true},
+			html:   page,
+			want: "# UnrealScript\n\n" +
+				"- [Variables](#variables)\n" +
+				"  - [Simple Variables](#simple-variables)\n" +
+				"- [Functions & Events](#functions--events)\n" +
+				"  - [Simple Variables](#simple-variables-1)\n\n" +
+				"## Variables\n\n### Simple *Variables*\n\n#### Too Deep\n\n## Functions & Events\n\n### Simple Variables\n",
+		},
+		{
+			name:   "custom levels",
+			config: Config{TOC: true, TOCMinLevel: 3, TOCMaxLevel: 4},
+			html:   page,
+			want: "# UnrealScript\n\n" +
+				"- [Simple Variables](#simple-variables)\n" +
+				"  - [Too Deep](#too-deep)\n" +
+				"- [Simple Variables](#simple-variables-1)\n\n" +
+				"## Variables\n\n### Simple *Variables*\n\n#### Too Deep\n\n## Functions & Events\n\n### Simple Variables\n",
+		},
+		{
+			name:   "no title",
+			config: Config{TOC: true},
+			html:   `<h2>One</h2><p>a</p><h2>Two</h2>`,
+			want:   "- [One](#one)\n- [Two](#two)\n\n## One\n\na\n\n## Two\n",
+		},
+		{
+			name:   "single heading gets no toc",
+			config: Config{TOC: true},
+			html:   `<h1>Title</h1><h2>Only</h2>`,
+			want:   "# Title\n\n## Only\n",
+		},
+		{
+			name:   "heading ids from original anchors",
+			config: Config{TOC: true, HeadingIDs: true},
+			html:   page,
+			want: "# UnrealScript {#UnrealScript}\n\n" +
+				"- [Variables](#Variables)\n" +
+				"  - [Simple Variables](#simple-variables)\n" +
+				"- [Functions & Events](#functions--events)\n" +
+				"  - [Simple Variables](#simple-variables-1)\n\n" +
+				"## Variables {#Variables}\n\n### Simple *Variables* {#simple-variables}\n\n#### Too Deep {#too-deep}\n\n" +
+				"## Functions & Events {#functions--events}\n\n### Simple Variables {#simple-variables-1}\n",
+		},
+		{
+			name:   "heading ids from id and preceding anchor",
+			config: Config{HeadingIDs: true},
+			html:   `<h2 id="Intro">Introduction</h2><a name="Latent_Functions"></a> <h2>Latent Functions</h2><h2><a name="Intro"></a>Again</h2><h2><a name="bad name"></a>Odd</h2>`,
+			want:   "## Introduction {#Intro}\n\n## Latent Functions {#Latent_Functions}\n\n## Again {#again}\n\n## Odd {#odd}\n",
+		},
+		{
+			name:   "disabled keeps twiki toc",
+			config: Config{},
+			html:   `<div class="twikiToc"><ul><li><a href="#A">A</a></li></ul></div><h2>A</h2><h2>B</h2>`,
+			want:   "- [A](#A)\n\n## A\n\n## B\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(tt.config).Convert(strings.NewReader(tt.html))
+			if err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Convert() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Variables", "variables"},
+		{"Simple Variables", "simple-variables"},
+		{"Functions & Events", "functions--events"},
+		{"The `Tick()` function", "the-tick-function"},
+		{"bNetDirty_Flag", "bnetdirty_flag"},
+		{"Über Äpfel", "über-äpfel"},
+	}
+
+	for _, tt := range tests {
+		if got := slugify(tt.title); got != tt.want {
+			t.Errorf("slugify(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package converter
+
+import (
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aldehir/ue2-docs/internal/manifest"
+)
+
+// versionLinks matches pages on the same topic across documentation
+// versions, so each converted page can link to its counterparts
+type versionLinks struct {
+	// pages maps a topic to its Markdown path in each version; "" marks a
+	// topic with several pages in one version, which cannot be matched
+	pages map[string]map[string]string
+}
+
+// newVersionLinks indexes the HTML pages of every version in a manifest.
+// mdPath maps a manifest path to the path of its converted Markdown
+func newVersionLinks(entries []manifest.Entry, mdPath func(manifest.Entry) string) *versionLinks {
+	v := &versionLinks{pages: make(map[string]map[string]string)}
+
+	for _, e := range entries {
+		if e.Version == "" || !isHTMLFile(e.Path) {
+			continue
+		}
+
+		t := topic(e.Path)
+		byVersion := v.pages[t]
+		if byVersion == nil {
+			byVersion = make(map[string]string)
+			v.pages[t] = byVersion
+		}
+
+		if _, dup := byVersion[e.Version]; dup {
+			byVersion[e.Version] = ""
+		} else {
+			byVersion[e.Version] = mdPath(e)
+		}
+	}
+
+	return v
+}
+
+// footer returns a Markdown section linking a page of a version to the same
+// topic in other versions, or "" if there are none
+func (v *versionLinks) footer(page, version string) string {
+	byVersion := v.pages[topic(page)]
+	if version == "" || byVersion[version] == "" {
+		return ""
+	}
+
+	var versions []string
+	for other, target := range byVersion {
+		if other != version && target != "" {
+			versions = append(versions, other)
+		}
+	}
+	if len(versions) == 0 {
+		return ""
+	}
+	sort.Strings(versions)
+
+	links := make([]string, len(versions))
+	for i, other := range versions {
+		rel, err := filepath.Rel(filepath.Dir(page), byVersion[other])
+		if err != nil {
+			rel = byVersion[other]
+		}
+		links[i] = "[" + escapeText(other) + "](" + filepath.ToSlash(rel) + ")"
+	}
+
+	return "\n---\n\nThis page in other versions: " + strings.Join(links, ", ") + "\n"
+}
+
+// topic identifies a page across versions by its case-insensitive file name
+func topic(p string) string {
+	base := path.Base(filepath.ToSlash(p))
+	return strings.ToLower(strings.TrimSuffix(base, path.Ext(base)))
+}
@@ -0,0 +1,150 @@
+// Package diff compares texts line by line
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// edit is one line of a line-by-line diff
+type edit struct {
+	op   byte // ' ' unchanged, '-' removed, '+' added
+	line string
+	a, b int // Line indexes in the old and new text before this edit
+}
+
+// Unified returns a unified diff between two texts, or "" if they are equal
+func Unified(oldName, newName string, a, b []byte) string {
+	edits := diffLines(splitLines(string(a)), splitLines(string(b)))
+
+	out := &strings.Builder{}
+	for start := 0; start < len(edits); {
+		// Find the next change and the end of the hunk around it, merging
+		// changes whose context would overlap
+		first := start
+		for first < len(edits) && edits[first].op == ' ' {
+			first++
+		}
+		if first == len(edits) {
+			break
+		}
+		last := first
+		for i := first + 1; i < len(edits) && i <= last+2*diffContext+1; i++ {
+			if edits[i].op != ' ' {
+				last = i
+			}
+		}
+
+		lo := max(first-diffContext, start)
+		hi := min(last+diffContext+1, len(edits))
+
+		if out.Len() == 0 {
+			fmt.Fprintf(out, "--- %s\n+++ %s\n", oldName, newName)
+		}
+		var oldCount, newCount int
+		for _, e := range edits[lo:hi] {
+			if e.op != '+' {
+				oldCount++
+			}
+			if e.op != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(edits[lo].a, oldCount), hunkRange(edits[lo].b, newCount))
+		for _, e := range edits[lo:hi] {
+			fmt.Fprintf(out, "%c%s\n", e.op, e.line)
+		}
+
+		start = hi
+	}
+	return out.String()
+}
+
+// hunkRange formats the line range of a hunk the way diff -u does
+func hunkRange(index, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", index)
+	case 1:
+		return fmt.Sprintf("%d", index+1)
+	}
+	return fmt.Sprintf("%d,%d", index+1, count)
+}
+
+// splitLines splits text into lines without their line endings
+func splitLines(text string) []string {
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+}
+
+// diffLines computes a shortest edit script between two sets of lines with
+// Myers' algorithm
+func diffLines(a, b []string) []edit {
+	n, m := len(a), len(b)
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	var trace [][]int
+
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk back from the end through the recorded frontiers
+	var edits []edit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{op: ' ', line: a[x], a: x, b: y})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				edits = append(edits, edit{op: '+', line: b[y], a: x, b: y})
+			} else {
+				x--
+				edits = append(edits, edit{op: '-', line: a[x], a: x, b: y})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
@@ -0,0 +1,53 @@
+package diff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "equal",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: "",
+		},
+		{
+			name: "separate hunks",
+			a:    "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\no\n",
+			b:    "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\no\np\n",
+			want: "--- old\n+++ new\n" +
+				"@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n" +
+				"@@ -13,3 +13,4 @@\n m\n n\n o\n+p\n",
+		},
+		{
+			name: "merged hunks",
+			a:    "a\nb\nc\nd\ne\nf\ng\nh\ni\n",
+			b:    "A\nb\nc\nd\ne\nf\ng\nH\ni\n",
+			want: "--- old\n+++ new\n" +
+				"@@ -1,9 +1,9 @@\n-a\n+A\n b\n c\n d\n e\n f\n g\n-h\n+H\n i\n",
+		},
+		{
+			name: "insert into empty",
+			a:    "",
+			b:    "a\n",
+			want: "--- old\n+++ new\n@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			name: "delete everything",
+			a:    "a\nb\n",
+			b:    "",
+			want: "--- old\n+++ new\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unified("old", "new", []byte(tt.a), []byte(tt.b)); got != tt.want {
+				t.Errorf("Unified() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+// Package export writes converted Markdown documentation in other forms: a
+// browsable static HTML site styled by an embedded theme, so the output
+// reads well without installing a site generator such as MkDocs or Hugo,
+// and an Obsidian vault
+package export
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ThemeDir is the directory, under the output directory, holding the
+// theme's stylesheet and script
+const ThemeDir = "_theme"
+
+//go:embed theme
+var theme embed.FS
+
+// pageTemplate lays out every exported page
+var pageTemplate = template.Must(template.ParseFS(theme, "theme/page.html"))
+
+// Config holds export configuration
+type Config struct {
+	InputDir  string // Converted Markdown, as written by 'ue2-docs convert'
+	OutputDir string
+	Title     string // Site title shown on every page; defaults to DefaultTitle
+
+	// Home is the page, relative to InputDir, the header links to. When
+	// empty or missing, an index listing every page is generated instead
+	Home string
+}
+
+// DefaultTitle is the site title used when Config.Title is empty
+const DefaultTitle = "Unreal Engine 2 Documentation"
+
+// Result summarizes an export
+type Result struct {
+	Pages    int // Markdown pages exported
+	Assets   int // Other files copied, such as images
+	Sections int // Index notes generated for vault folders
+}
+
+// Exporter renders a directory of Markdown pages to HTML
+type Exporter struct {
+	config Config
+}
+
+// New creates a new Exporter with the given configuration
+func New(config Config) *Exporter {
+	if config.Title == "" {
+		config.Title = DefaultTitle
+	}
+	return &Exporter{config: config}
+}
+
+// indexEntry is a page listed on a generated index
+type indexEntry struct {
+	path  string // Of the exported page, relative to the output directory
+	title string
+}
+
+// Run exports every Markdown page under InputDir to OutputDir, copying
+// other files alongside them and writing the theme under ThemeDir
+func (e *Exporter) Run(ctx context.Context) (*Result, error) {
+	home := e.config.Home
+	if home != "" {
+		if _, err := os.Stat(filepath.Join(e.config.InputDir, filepath.FromSlash(home))); err != nil {
+			home = ""
+		}
+	}
+	if home == "" {
+		home = "index.html"
+	} else {
+		home = htmlPath(home)
+	}
+
+	result := &Result{}
+	var pages []indexEntry
+	err := walkInput(ctx, e.config.InputDir, func(src, rel string) error {
+		if path.Ext(rel) != ".md" {
+			result.Assets++
+			return copyFile(src, filepath.Join(e.config.OutputDir, filepath.FromSlash(rel)))
+		}
+
+		title, err := e.page(src, rel, home)
+		if err != nil {
+			return err
+		}
+		result.Pages++
+		pages = append(pages, indexEntry{path: htmlPath(rel), title: title})
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	if err := e.writeTheme(); err != nil {
+		return result, err
+	}
+
+	// Without a home page the header links to a generated index
+	if home == "index.html" && !hasPage(pages, home) {
+		if err := e.writeIndex(pages); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// page renders the Markdown page src, at rel in the input directory,
+// returning its title
+func (e *Exporter) page(src, rel, home string) (string, error) {
+	markdown, err := os.ReadFile(src)
+	if err != nil {
+		return "", err
+	}
+
+	doc := renderMarkdown(string(markdown))
+	title := doc.Title
+	if title == "" {
+		title = strings.TrimSuffix(path.Base(rel), ".md")
+	}
+
+	return title, e.write(htmlPath(rel), doc.Title, doc.Body, home)
+}
+
+// write lays out a page body and writes it to rel in the output directory
+func (e *Exporter) write(rel, title, body, home string) error {
+	root := strings.Repeat("../", strings.Count(rel, "/"))
+
+	var buf bytes.Buffer
+	err := pageTemplate.Execute(&buf, struct {
+		Site, Title, Root, ThemeDir, Home string
+		Body                              template.HTML
+	}{
+		Site:     e.config.Title,
+		Title:    title,
+		Root:     root,
+		ThemeDir: ThemeDir,
+		Home:     home,
+		Body:     template.HTML(body),
+	})
+	if err != nil {
+		return fmt.Errorf("rendering %s: %w", rel, err)
+	}
+
+	return writeFile(filepath.Join(e.config.OutputDir, filepath.FromSlash(rel)), buf.Bytes())
+}
+
+// writeTheme writes the theme's stylesheet and script under ThemeDir
+func (e *Exporter) writeTheme() error {
+	for _, name := range []string{"style.css", "theme.js"} {
+		data, err := theme.ReadFile("theme/" + name)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(filepath.Join(e.config.OutputDir, ThemeDir, name), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeIndex writes index.html, listing every page by title
+func (e *Exporter) writeIndex(pages []indexEntry) error {
+	sort.Slice(pages, func(i, j int) bool { return pages[i].path < pages[j].path })
+
+	var sb strings.Builder
+	sb.WriteString("<h1 id=\"pages\">Pages</h1>\n<ul>\n")
+	for _, p := range pages {
+		fmt.Fprintf(&sb, "<li><a href=\"%s\">%s</a></li>\n", template.HTMLEscapeString(p.path), template.HTMLEscapeString(p.title))
+	}
+	sb.WriteString("</ul>\n")
+
+	return e.write("index.html", "", sb.String(), "index.html")
+}
+
+// walkInput calls fn for every file under dir with its path relative to
+// dir, skipping hidden files and directories, which hold converter
+// bookkeeping such as the previous versions of updated pages
+func walkInput(ctx context.Context, dir string, fn func(src, rel string) error) error {
+	return filepath.WalkDir(dir, func(src string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, src)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if strings.HasPrefix(d.Name(), ".") && rel != "." {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		return fn(src, rel)
+	})
+}
+
+// hasPage reports whether pages includes the page exported to rel
+func hasPage(pages []indexEntry, rel string) bool {
+	for _, p := range pages {
+		if p.path == rel {
+			return true
+		}
+	}
+	return false
+}
+
+// htmlPath returns the exported path of a Markdown page
+func htmlPath(rel string) string {
+	return strings.TrimSuffix(rel, ".md") + ".html"
+}
+
+// writeFile writes data to dst, creating its directory
+func writeFile(dst string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("creating directory for %s: %w", dst, err)
+	}
+	if err := os.WriteFile(dst, data, 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", dst, err)
+	}
+	return nil
+}
+
+// copyFile copies src to dst, creating its directory
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
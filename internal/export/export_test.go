@@ -0,0 +1,95 @@
+package export
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExporter_Run(t *testing.T) {
+	inputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "Two", "WebHome.md"), "# Welcome\n\nSee [the reference](Script/Reference.md).\n")
+	writeTestFile(t, filepath.Join(inputDir, "Two", "Script", "Reference.md"), "# Reference\n\n![Diagram](../rsrc/diagram.gif)\n")
+	writeTestFile(t, filepath.Join(inputDir, "Two", "rsrc", "diagram.gif"), "GIF89a")
+	writeTestFile(t, filepath.Join(inputDir, ".previous", "Two", "WebHome.md"), "# Old\n")
+
+	outputDir := t.TempDir()
+	result, err := New(Config{InputDir: inputDir, OutputDir: outputDir, Title: "UDN"}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Pages != 2 || result.Assets != 1 {
+		t.Errorf("Run() = %+v, want 2 pages and 1 asset", result)
+	}
+
+	read := func(rel string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(rel)))
+		if err != nil {
+			t.Fatalf("reading %s: %v", rel, err)
+		}
+		return string(data)
+	}
+
+	page := read("Two/Script/Reference.html")
+	for _, want := range []string{
+		"<title>Reference - UDN</title>",
+		`<link rel="stylesheet" href="../../_theme/style.css">`,
+		`<script src="../../_theme/theme.js">`,
+		`<a href="../../index.html">UDN</a>`,
+		`<img src="../rsrc/diagram.gif" alt="Diagram">`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("Reference.html missing %s\n%s", want, page)
+		}
+	}
+	if !strings.Contains(read("Two/WebHome.html"), `<a href="Script/Reference.html">the reference</a>`) {
+		t.Error("WebHome.html link not pointed at the exported page")
+	}
+
+	index := read("index.html")
+	if !strings.Contains(index, `<a href="Two/Script/Reference.html">Reference</a>`) || !strings.Contains(index, `<a href="Two/WebHome.html">Welcome</a>`) {
+		t.Errorf("index.html does not list the pages\n%s", index)
+	}
+
+	read("_theme/style.css")
+	read("_theme/theme.js")
+	read("Two/rsrc/diagram.gif")
+	if _, err := os.Stat(filepath.Join(outputDir, ".previous")); !os.IsNotExist(err) {
+		t.Error("hidden converter directory was exported")
+	}
+}
+
+func TestExporter_Home(t *testing.T) {
+	inputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "Two", "WebHome.md"), "# Welcome\n")
+	writeTestFile(t, filepath.Join(inputDir, "Two", "Page.md"), "# Page\n")
+
+	outputDir := t.TempDir()
+	if _, err := New(Config{InputDir: inputDir, OutputDir: outputDir, Home: "Two/WebHome.md"}).Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	page, err := os.ReadFile(filepath.Join(outputDir, "Two", "Page.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), `<a href="../Two/WebHome.html">`+DefaultTitle+`</a>`) {
+		t.Errorf("header does not link to the home page\n%s", page)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "index.html")); !os.IsNotExist(err) {
+		t.Error("index generated despite a home page")
+	}
+}
@@ -0,0 +1,513 @@
+package export
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aldehir/ue2-docs/internal/converter"
+)
+
+// document is a Markdown page rendered to HTML
+type document struct {
+	Title string // From front matter, else the first level 1 heading
+	Body  string
+}
+
+// renderMarkdown renders the Markdown written by the converter to HTML. It
+// covers the subset the converter emits (headings, paragraphs, lists,
+// tables, fenced code, block quotes, rules, raw HTML islands, emphasis,
+// code spans, links and images) rather than all of CommonMark. Links to
+// local .md files are pointed at the exported .html pages
+func renderMarkdown(src string) document {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+
+	var doc document
+	lines, doc.Title = frontMatterTitle(lines)
+
+	r := &renderer{anchors: converter.NewAnchorSet()}
+	r.blocks(lines, false)
+	if doc.Title == "" {
+		doc.Title = r.title
+	}
+	doc.Body = r.sb.String()
+	return doc
+}
+
+// frontMatterTitle strips YAML front matter from the start of a page,
+// returning the remaining lines and the title it holds, if any
+func frontMatterTitle(lines []string) ([]string, string) {
+	if len(lines) == 0 || lines[0] != "---" {
+		return lines, ""
+	}
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "---" {
+			continue
+		}
+		title := ""
+		for _, line := range lines[1:i] {
+			if value, ok := strings.CutPrefix(line, "title: "); ok {
+				if unquoted, err := strconv.Unquote(value); err == nil {
+					title = unquoted
+				} else {
+					title = value
+				}
+			}
+		}
+		return lines[i+1:], title
+	}
+	return lines, ""
+}
+
+// renderer accumulates the HTML of one page
+type renderer struct {
+	sb      strings.Builder
+	anchors converter.AnchorSet
+	title   string // First level 1 heading
+}
+
+var (
+	headingPattern   = regexp.MustCompile(`^(#{1,6}) (.*)$`)
+	fencePattern     = regexp.MustCompile("^(`{3,})(.*)$")
+	listPattern      = regexp.MustCompile(`^(- |(\d+)\. )`)
+	separatorPattern = regexp.MustCompile(`^\|( *:?-{3,}:? *\|)+$`)
+	headingIDPattern = regexp.MustCompile(` \{#([^\s{}]+)\}$`)
+)
+
+// blocks renders a sequence of block-level lines. Paragraphs of tight list
+// items are rendered without <p>
+func (r *renderer) blocks(lines []string, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+
+		case line == "---":
+			r.sb.WriteString("<hr>\n")
+			i++
+
+		case headingPattern.MatchString(line):
+			m := headingPattern.FindStringSubmatch(line)
+			r.heading(len(m[1]), m[2])
+			i++
+
+		case fencePattern.MatchString(line):
+			i = r.code(lines, i)
+
+		case strings.HasPrefix(line, ">"):
+			var quoted []string
+			for ; i < len(lines) && strings.HasPrefix(lines[i], ">"); i++ {
+				quoted = append(quoted, strings.TrimPrefix(strings.TrimPrefix(lines[i], ">"), " "))
+			}
+			r.sb.WriteString("<blockquote>\n")
+			r.blocks(quoted, false)
+			r.sb.WriteString("</blockquote>\n")
+
+		case listPattern.MatchString(line):
+			i = r.list(lines, i)
+
+		case strings.HasPrefix(line, "|") && i+1 < len(lines) && separatorPattern.MatchString(lines[i+1]):
+			i = r.table(lines, i)
+
+		case strings.HasPrefix(line, "<"):
+			i = r.rawHTML(lines, i)
+
+		default:
+			start := i
+			for i < len(lines) && strings.TrimSpace(lines[i]) != "" && (i == start || !startsBlock(lines, i)) {
+				i++
+			}
+			text := inline(strings.Join(lines[start:i], "\n"))
+			if tight {
+				r.sb.WriteString(text + "\n")
+			} else {
+				r.sb.WriteString("<p>" + text + "</p>\n")
+			}
+		}
+	}
+}
+
+// startsBlock reports whether line i starts a block that interrupts a
+// paragraph
+func startsBlock(lines []string, i int) bool {
+	line := lines[i]
+	return headingPattern.MatchString(line) || fencePattern.MatchString(line) ||
+		strings.HasPrefix(line, ">") || listPattern.MatchString(line) || line == "---"
+}
+
+// heading renders a heading with its explicit {#id} anchor, or else the
+// anchor the converter's tables of contents link to
+func (r *renderer) heading(level int, text string) {
+	text, id := headingID(text)
+	content := inline(text)
+	title := plainText(content)
+	if level == 1 && r.title == "" {
+		r.title = title
+	}
+	if id == "" {
+		id = r.anchors.Add(title)
+	}
+	tag := "h" + strconv.Itoa(level)
+	r.sb.WriteString("<" + tag + ` id="` + html.EscapeString(id) + `">` + content + "</" + tag + ">\n")
+}
+
+// headingID splits the explicit {#id} anchor the converter writes after
+// a heading's text from it, returning "" for headings without one
+func headingID(text string) (string, string) {
+	if m := headingIDPattern.FindStringSubmatchIndex(text); m != nil {
+		return text[:m[0]], text[m[2]:m[3]]
+	}
+	return text, ""
+}
+
+// code renders the fenced code block starting at line i, returning the
+// index of the line after it
+func (r *renderer) code(lines []string, i int) int {
+	m := fencePattern.FindStringSubmatch(lines[i])
+	fence, info := m[1], strings.TrimSpace(m[2])
+
+	var code []string
+	for i++; i < len(lines) && lines[i] != fence; i++ {
+		code = append(code, lines[i])
+	}
+
+	r.sb.WriteString("<pre><code")
+	if info != "" {
+		r.sb.WriteString(` class="language-` + html.EscapeString(info) + `"`)
+	}
+	r.sb.WriteString(">" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+	return i + 1
+}
+
+// list renders the list starting at line i, returning the index of the
+// line after it. Item content is indented by the width of its marker
+func (r *renderer) list(lines []string, i int) int {
+	m := listPattern.FindStringSubmatch(lines[i])
+	ordered := m[2] != ""
+	if ordered {
+		if m[2] == "1" {
+			r.sb.WriteString("<ol>\n")
+		} else {
+			r.sb.WriteString(`<ol start="` + m[2] + `">` + "\n")
+		}
+	} else {
+		r.sb.WriteString("<ul>\n")
+	}
+
+	for i < len(lines) {
+		m := listPattern.FindStringSubmatch(lines[i])
+		if m == nil || (m[2] != "") != ordered {
+			break
+		}
+		width := len(m[1])
+		item := []string{lines[i][width:]}
+		i++
+
+		// Continuation lines are indented; blank lines belong to the item
+		// only when indented content follows them
+		for i < len(lines) {
+			if strings.HasPrefix(lines[i], strings.Repeat(" ", width)) {
+				item = append(item, lines[i][width:])
+				i++
+				continue
+			}
+			next := i
+			for next < len(lines) && strings.TrimSpace(lines[next]) == "" {
+				next++
+			}
+			if next == i || next == len(lines) || !strings.HasPrefix(lines[next], strings.Repeat(" ", width)) {
+				break
+			}
+			item = append(item, lines[i:next]...)
+			i = next
+		}
+
+		tight := true
+		for _, line := range item {
+			if strings.TrimSpace(line) == "" {
+				tight = false
+			}
+		}
+		r.sb.WriteString("<li>")
+		r.blocks(item, tight)
+		r.sb.WriteString("</li>\n")
+	}
+
+	if ordered {
+		r.sb.WriteString("</ol>\n")
+	} else {
+		r.sb.WriteString("</ul>\n")
+	}
+	return i
+}
+
+// table renders the table starting at line i, whose second line separates
+// the header from the body, returning the index of the line after it
+func (r *renderer) table(lines []string, i int) int {
+	r.sb.WriteString("<table>\n<thead>\n")
+	r.row(lines[i], "th")
+	r.sb.WriteString("</thead>\n<tbody>\n")
+	for i += 2; i < len(lines) && strings.HasPrefix(lines[i], "|"); i++ {
+		r.row(lines[i], "td")
+	}
+	r.sb.WriteString("</tbody>\n</table>\n")
+	return i
+}
+
+// row renders a table row, splitting cells at unescaped pipes
+func (r *renderer) row(line, tag string) {
+	line = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(line), "|"), "|")
+
+	var cells []string
+	var cell strings.Builder
+	for j := 0; j < len(line); j++ {
+		switch {
+		case line[j] == '\\' && j+1 < len(line) && line[j+1] == '|':
+			cell.WriteByte('|')
+			j++
+		case line[j] == '|':
+			cells = append(cells, cell.String())
+			cell.Reset()
+		default:
+			cell.WriteByte(line[j])
+		}
+	}
+	cells = append(cells, cell.String())
+
+	r.sb.WriteString("<tr>")
+	for _, c := range cells {
+		r.sb.WriteString("<" + tag + ">" + inline(strings.TrimSpace(c)) + "</" + tag + ">")
+	}
+	r.sb.WriteString("</tr>\n")
+}
+
+// rawIslandEnd closes the raw HTML islands of the converter
+const rawIslandEnd = "<!-- end raw HTML -->"
+
+// rawHTML passes through the raw HTML block starting at line i, returning
+// the index of the line after it. Raw islands run to their closing comment;
+// other HTML runs to the next blank line
+func (r *renderer) rawHTML(lines []string, i int) int {
+	start := i
+	if strings.HasPrefix(lines[i], "<!-- raw HTML:") {
+		for i < len(lines) && !strings.HasSuffix(lines[i], rawIslandEnd) {
+			i++
+		}
+		i = min(i+1, len(lines))
+	} else {
+		for i < len(lines) && strings.TrimSpace(lines[i]) != "" {
+			i++
+		}
+	}
+	r.sb.WriteString(strings.Join(lines[start:i], "\n") + "\n")
+	return i
+}
+
+var (
+	entityPattern = regexp.MustCompile(`^&(#[0-9]+|#[xX][0-9a-fA-F]+|[A-Za-z][A-Za-z0-9]*);`)
+	tagPattern    = regexp.MustCompile(`^(<!--.*?-->|</?[A-Za-z][A-Za-z0-9]*(\s[^<>]*)?/?>)`)
+)
+
+// inline renders inline Markdown to HTML
+func inline(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			sb.WriteString("<br>\n")
+			i += 2
+
+		case c == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+			sb.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+
+		case c == '`':
+			n := runLength(s[i:], '`')
+			fence := s[i : i+n]
+			end := strings.Index(s[i+n:], fence)
+			if end < 0 {
+				sb.WriteString(fence)
+				i += n
+				continue
+			}
+			code := s[i+n : i+n+end]
+			if len(code) > 1 && code[0] == ' ' && code[len(code)-1] == ' ' {
+				code = code[1 : len(code)-1]
+			}
+			sb.WriteString("<code>" + html.EscapeString(code) + "</code>")
+			i += n + end + n
+
+		case c == '*':
+			delim, open, close := "*", "<em>", "</em>"
+			switch {
+			case strings.HasPrefix(s[i:], "***"):
+				delim, open, close = "***", "<strong><em>", "</em></strong>"
+			case strings.HasPrefix(s[i:], "**"):
+				delim, open, close = "**", "<strong>", "</strong>"
+			}
+			end := closingDelim(s[i+len(delim):], delim)
+			if end <= 0 {
+				sb.WriteString(delim)
+				i += len(delim)
+				continue
+			}
+			sb.WriteString(open + inline(s[i+len(delim):i+len(delim)+end]) + close)
+			i += len(delim) + end + len(delim)
+
+		case c == '[' || (c == '!' && strings.HasPrefix(s[i:], "![")):
+			out, n := link(s[i:])
+			if n == 0 {
+				sb.WriteString(html.EscapeString(s[i : i+1]))
+				i++
+				continue
+			}
+			sb.WriteString(out)
+			i += n
+
+		case c == '<':
+			if tag := tagPattern.FindString(s[i:]); tag != "" {
+				sb.WriteString(tag)
+				i += len(tag)
+				continue
+			}
+			sb.WriteString("&lt;")
+			i++
+
+		case c == '&':
+			if entity := entityPattern.FindString(s[i:]); entity != "" {
+				sb.WriteString(entity)
+				i += len(entity)
+				continue
+			}
+			sb.WriteString("&amp;")
+			i++
+
+		default:
+			sb.WriteString(html.EscapeString(s[i : i+1]))
+			i++
+		}
+	}
+	return sb.String()
+}
+
+// link renders the link or image at the start of s, returning its HTML and
+// the length of its Markdown, or 0 if s does not start with one
+func link(s string) (string, int) {
+	image := s[0] == '!'
+	open := 0
+	if image {
+		open = 1
+	}
+
+	textEnd := matching(s, open, '[', ']')
+	if textEnd < 0 || textEnd+1 >= len(s) || s[textEnd+1] != '(' {
+		return "", 0
+	}
+	hrefEnd := matching(s, textEnd+1, '(', ')')
+	if hrefEnd < 0 {
+		return "", 0
+	}
+
+	text := s[open+1 : textEnd]
+	href := html.EscapeString(htmlLink(s[textEnd+2 : hrefEnd]))
+	if image {
+		return `<img src="` + href + `" alt="` + html.EscapeString(plainText(inline(text))) + `">`, hrefEnd + 1
+	}
+	return `<a href="` + href + `">` + inline(text) + "</a>", hrefEnd + 1
+}
+
+// matching returns the index of the bracket closing the one at s[start],
+// skipping escaped and nested brackets, or -1
+func matching(s string, start int, open, close byte) int {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// closingDelim returns the index of the emphasis delimiter closing a span
+// that starts at the beginning of s, skipping escapes and code spans, or -1
+func closingDelim(s, delim string) int {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case s[i] == '`':
+			n := runLength(s[i:], '`')
+			if end := strings.Index(s[i+n:], s[i:i+n]); end >= 0 {
+				i += n + end + n - 1
+			}
+		case strings.HasPrefix(s[i:], delim):
+			// A single * inside strong text is the start of emphasis
+			if delim == "*" && strings.HasPrefix(s[i:], "**") {
+				if end := closingDelim(s[i+2:], "**"); end >= 0 {
+					i += 2 + end + 1
+					continue
+				}
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// htmlLink points relative links to Markdown pages at the exported HTML
+func htmlLink(href string) string {
+	if strings.Contains(href, ":") || strings.HasPrefix(href, "//") {
+		return href
+	}
+	target, fragment, _ := strings.Cut(href, "#")
+	if base, ok := strings.CutSuffix(target, ".md"); ok {
+		target = base + ".html"
+	}
+	if fragment == "" && !strings.Contains(href, "#") {
+		return target
+	}
+	return target + "#" + fragment
+}
+
+// plainText returns the text of rendered inline HTML without its tags
+func plainText(s string) string {
+	var sb strings.Builder
+	inTag := false
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>' && inTag:
+			inTag = false
+		case !inTag:
+			sb.WriteRune(r)
+		}
+	}
+	return strings.TrimSpace(html.UnescapeString(sb.String()))
+}
+
+// runLength returns the number of times c repeats at the start of s
+func runLength(s string, c byte) int {
+	n := 0
+	for n < len(s) && s[n] == c {
+		n++
+	}
+	return n
+}
+
+// isPunct reports whether c is ASCII punctuation, which Markdown lets a
+// backslash escape
+func isPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
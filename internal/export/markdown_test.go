@@ -0,0 +1,114 @@
+package export
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{
+			name:     "headings",
+			markdown: "# Title\n\n## Variables\n\n## Variables",
+			want:     "<h1 id=\"title\">Title</h1>\n<h2 id=\"variables\">Variables</h2>\n<h2 id=\"variables-1\">Variables</h2>\n",
+		},
+		{
+			name:     "explicit heading ids",
+			markdown: "# Title {#UnrealScript}\n\n## Latent Functions {#Latent_Functions}",
+			want:     "<h1 id=\"UnrealScript\">Title</h1>\n<h2 id=\"Latent_Functions\">Latent Functions</h2>\n",
+		},
+		{
+			name:     "paragraph with hard break",
+			markdown: "First line\\\nsecond *line*",
+			want:     "<p>First line<br>\nsecond <em>line</em></p>\n",
+		},
+		{
+			name:     "escapes and entities",
+			markdown: `\*not emphasis\* \<b> a \&amp; b &copy; & c`,
+			want:     "<p>*not emphasis* &lt;b&gt; a &amp;amp; b &copy; &amp; c</p>\n",
+		},
+		{
+			name:     "strong, emphasis and code",
+			markdown: "**bold** *it* ***both*** `a < b` ``x ` y``",
+			want:     "<p><strong>bold</strong> <em>it</em> <strong><em>both</em></strong> <code>a &lt; b</code> <code>x ` y</code></p>\n",
+		},
+		{
+			name:     "links and images",
+			markdown: "[Ref](Script/Reference.md#Functions) [![Logo](rsrc/logo.gif)](WebHome.md) [Ext](https://udn.epicgames.com/Two/A.md) [Top](#top)",
+			want:     "<p><a href=\"Script/Reference.html#Functions\">Ref</a> <a href=\"WebHome.html\"><img src=\"rsrc/logo.gif\" alt=\"Logo\"></a> <a href=\"https://udn.epicgames.com/Two/A.md\">Ext</a> <a href=\"#top\">Top</a></p>\n",
+		},
+		{
+			name:     "tight nested list",
+			markdown: "- One\n- Two\n  - Nested\n1. First",
+			want:     "<ul>\n<li>One\n</li>\n<li>Two\n<ul>\n<li>Nested\n</li>\n</ul>\n</li>\n</ul>\n<ol>\n<li>First\n</li>\n</ol>\n",
+		},
+		{
+			name:     "loose list item",
+			markdown: "3. Item\n\n   More\n4. Next",
+			want:     "<ol start=\"3\">\n<li><p>Item</p>\n<p>More</p>\n</li>\n<li>Next\n</li>\n</ol>\n",
+		},
+		{
+			name:     "table",
+			markdown: "| Type | Notes |\n| --- | --- |\n| `bool` | True \\| False |",
+			want:     "<table>\n<thead>\n<tr><th>Type</th><th>Notes</th></tr>\n</thead>\n<tbody>\n<tr><td><code>bool</code></td><td>True | False</td></tr>\n</tbody>\n</table>\n",
+		},
+		{
+			name:     "fenced code",
+			markdown: "```uc\nvar int a; // <a>\n\n```",
+			want:     "<pre><code class=\"language-uc\">var int a; // &lt;a&gt;\n</code></pre>\n",
+		},
+		{
+			name:     "block quote and rule",
+			markdown: "> **Note:** quoted\n>\n> again\n\n---",
+			want:     "<blockquote>\n<p><strong>Note:</strong> quoted</p>\n<p>again</p>\n</blockquote>\n<hr>\n",
+		},
+		{
+			name:     "raw HTML island",
+			markdown: "<!-- raw HTML: <applet> -->\n<applet code=\"A\">\n\n</applet>\n<!-- end raw HTML -->\n\nAfter",
+			want:     "<!-- raw HTML: <applet> -->\n<applet code=\"A\">\n\n</applet>\n<!-- end raw HTML -->\n<p>After</p>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderMarkdown(tt.markdown).Body; got != tt.want {
+				t.Errorf("renderMarkdown() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderMarkdown_Title(t *testing.T) {
+	if got := renderMarkdown("---\ntitle: \"Front \\\"Matter\\\"\"\n---\n\n# Heading").Title; got != `Front "Matter"` {
+		t.Errorf("Title from front matter = %q", got)
+	}
+	if got := renderMarkdown("Intro\n\n# The *Heading*").Title; got != "The Heading" {
+		t.Errorf("Title from heading = %q", got)
+	}
+}
+
+func TestRenderMarkdown_Corpus(t *testing.T) {
+	markdown, err := os.ReadFile("../converter/testdata/corpus/pages/UnrealScriptReference.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := renderMarkdown(string(markdown)).Body
+	for _, want := range []string{
+		`<h1 id="unrealscript-language-reference">UnrealScript Language Reference</h1>`,
+		`<h3 id="simple-variables">Simple Variables</h3>`,
+		"<pre><code>var int a;",
+		`<li><strong>Instance variables</strong>, which apply`,
+		`<td>True | False</td>`,
+		`<a href="UnrealScriptReference.html#Latent_Functions">latent functions</a>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("rendered page missing %s\n%s", want, body)
+		}
+	}
+}
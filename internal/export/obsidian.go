@@ -0,0 +1,380 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aldehir/ue2-docs/internal/converter"
+)
+
+// DefaultAttachments is the vault folder other files are moved to when
+// VaultConfig.Attachments is empty
+const DefaultAttachments = "attachments"
+
+// VaultConfig holds Obsidian vault export configuration
+type VaultConfig struct {
+	InputDir    string // Converted Markdown, as written by 'ue2-docs convert'
+	OutputDir   string // The vault
+	Attachments string // Folder for images and other files; defaults to DefaultAttachments
+}
+
+// Vault writes converted Markdown as an Obsidian vault: links between pages
+// become [[wiki links]], images and other files move to an attachments
+// folder and are embedded with ![[...]], and every folder gets an index note
+// listing its pages and subfolders
+type Vault struct {
+	config VaultConfig
+}
+
+// NewVault creates a new Vault exporter with the given configuration
+func NewVault(config VaultConfig) *Vault {
+	if config.Attachments == "" {
+		config.Attachments = DefaultAttachments
+	}
+	return &Vault{config: config}
+}
+
+// vaultPage is a page of the vault
+type vaultPage struct {
+	src   string
+	title string
+	// anchors maps the anchors of the page's headings, lowercased and
+	// without hyphens, to their titles, which Obsidian links to instead
+	anchors map[string]string
+}
+
+// Run exports every Markdown page under InputDir to the vault
+func (v *Vault) Run(ctx context.Context) (*Result, error) {
+	result := &Result{}
+
+	// Links are rewritten against every page's headings, so pages are read
+	// before any is written
+	pages := make(map[string]*vaultPage)
+	err := walkInput(ctx, v.config.InputDir, func(src, rel string) error {
+		if path.Ext(rel) != ".md" {
+			result.Assets++
+			return copyFile(src, filepath.Join(v.config.OutputDir, v.config.Attachments, filepath.FromSlash(rel)))
+		}
+
+		markdown, err := os.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		pages[rel] = scanPage(src, string(markdown))
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	for rel, page := range pages {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		markdown, err := os.ReadFile(page.src)
+		if err != nil {
+			return result, err
+		}
+		if err := writeFile(filepath.Join(v.config.OutputDir, filepath.FromSlash(rel)), []byte(v.wikiLinks(string(markdown), rel, pages))); err != nil {
+			return result, err
+		}
+		result.Pages++
+	}
+
+	n, err := v.writeIndexNotes(pages)
+	result.Sections = n
+	return result, err
+}
+
+// scanPage reads the title and heading anchors of a page
+func scanPage(src, markdown string) *vaultPage {
+	page := &vaultPage{src: src, anchors: make(map[string]string)}
+	lines, title := frontMatterTitle(strings.Split(markdown, "\n"))
+	page.title = title
+
+	anchors := converter.NewAnchorSet()
+	fence := ""
+	for _, line := range lines {
+		if fence != "" {
+			if line == fence {
+				fence = ""
+			}
+			continue
+		}
+		if m := fencePattern.FindStringSubmatch(line); m != nil {
+			fence = m[1]
+			continue
+		}
+		if m := headingPattern.FindStringSubmatch(line); m != nil {
+			text, id := headingID(m[2])
+			heading := plainText(inline(text))
+			if id != "" {
+				page.anchors[strings.ToLower(strings.ReplaceAll(id, "_", "-"))] = heading
+			}
+			anchor := strings.ToLower(anchors.Add(heading))
+			page.anchors[anchor] = heading
+			// TWiki also names anchors as WikiWords, e.g. GettingStarted
+			if squeezed := strings.ReplaceAll(anchor, "-", ""); page.anchors[squeezed] == "" {
+				page.anchors[squeezed] = heading
+			}
+			if page.title == "" && len(m[1]) == 1 {
+				page.title = heading
+			}
+		}
+	}
+	return page
+}
+
+// wikiLinks rewrites the links of the page at rel to wiki links, leaving
+// code and external links alone
+func (v *Vault) wikiLinks(markdown, rel string, pages map[string]*vaultPage) string {
+	lines := strings.Split(markdown, "\n")
+	fence := ""
+	for i, line := range lines {
+		if fence != "" {
+			if line == fence {
+				fence = ""
+			}
+			continue
+		}
+		if m := fencePattern.FindStringSubmatch(line); m != nil {
+			fence = m[1]
+			continue
+		}
+		if m := headingPattern.FindStringSubmatch(line); m != nil {
+			// Obsidian links to headings by their text, and would show
+			// explicit anchors as part of it
+			text, _ := headingID(m[2])
+			line = m[1] + " " + text
+		}
+		lines[i] = v.wikiLine(line, rel, pages, strings.HasPrefix(line, "|"))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// wikiLine rewrites the links of one line. In table rows the pipe between
+// a link's target and its label is escaped so it does not split the cell
+func (v *Vault) wikiLine(s, rel string, pages map[string]*vaultPage, table bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			sb.WriteString(s[i : i+2])
+			i += 2
+
+		case s[i] == '`':
+			n := runLength(s[i:], '`')
+			end := strings.Index(s[i+n:], s[i:i+n])
+			if end < 0 {
+				end = -n
+			}
+			sb.WriteString(s[i : i+n+end+n])
+			i += n + end + n
+
+		case s[i] == '[' || strings.HasPrefix(s[i:], "!["):
+			out, n := v.wikiLink(s[i:], rel, pages, table)
+			if n == 0 {
+				sb.WriteByte(s[i])
+				i++
+				continue
+			}
+			sb.WriteString(out)
+			i += n
+
+		default:
+			sb.WriteByte(s[i])
+			i++
+		}
+	}
+	return sb.String()
+}
+
+// wikiLink rewrites the link or image at the start of s, returning the
+// rewritten text and the length of the original, or 0 if s does not start
+// with a link to a page or file in the vault
+func (v *Vault) wikiLink(s, rel string, pages map[string]*vaultPage, table bool) (string, int) {
+	image := s[0] == '!'
+	open := 0
+	if image {
+		open = 1
+	}
+	textEnd := matching(s, open, '[', ']')
+	if textEnd < 0 || textEnd+1 >= len(s) || s[textEnd+1] != '(' {
+		return "", 0
+	}
+	hrefEnd := matching(s, textEnd+1, '(', ')')
+	if hrefEnd < 0 {
+		return "", 0
+	}
+	text, href := s[open+1:textEnd], s[textEnd+2:hrefEnd]
+
+	target, ok := v.linkTarget(href, rel, pages)
+	if !ok {
+		// External links stay Markdown links, with any images in their
+		// text still embedded from the vault
+		return s[:open+1] + v.wikiLine(text, rel, pages, table) + s[textEnd:hrefEnd+1], hrefEnd + 1
+	}
+	if image {
+		return "![[" + target + "]]", hrefEnd + 1
+	}
+
+	sep := "|"
+	if table {
+		sep = `\|`
+	}
+
+	// Images cannot sit inside a wiki link, so linked images are embedded
+	// before a link labelled with their alt text
+	prefix := ""
+	if strings.Contains(text, "![") {
+		prefix = v.wikiLine(text, rel, pages, table) + " "
+		text = strings.TrimSpace(plainText(inline(imagePattern.ReplaceAllString(text, "$1"))))
+		if text == "" {
+			return prefix + "[[" + target + "]]", hrefEnd + 1
+		}
+	}
+
+	base, _, _ := strings.Cut(path.Base(target), "#")
+	if text == "" || text == base || text == target {
+		return prefix + "[[" + target + "]]", hrefEnd + 1
+	}
+	return prefix + "[[" + target + sep + text + "]]", hrefEnd + 1
+}
+
+// imagePattern matches an image, capturing its alt text
+var imagePattern = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+
+// linkTarget returns the wiki link target for a link on the page at rel:
+// the vault path of a page without its extension, with the title of the
+// linked heading, or of a file under the attachments folder. It reports
+// false for external links and links leaving the vault
+func (v *Vault) linkTarget(href, rel string, pages map[string]*vaultPage) (string, bool) {
+	if href == "" || strings.Contains(href, ":") || strings.HasPrefix(href, "//") {
+		return "", false
+	}
+
+	target, fragment, _ := strings.Cut(href, "#")
+	if unescaped, err := url.PathUnescape(target); err == nil {
+		target = unescaped
+	}
+
+	linked := rel
+	if target != "" {
+		linked = path.Clean(path.Join(path.Dir(rel), target))
+		if linked == ".." || strings.HasPrefix(linked, "../") {
+			return "", false
+		}
+	}
+
+	if path.Ext(linked) != ".md" {
+		return v.config.Attachments + "/" + linked, true
+	}
+
+	wiki := strings.TrimSuffix(linked, ".md")
+	if target == "" {
+		wiki = ""
+	}
+	if page, ok := pages[linked]; ok && fragment != "" {
+		// TWiki anchors such as Latent_Functions name the heading the
+		// converter's anchors are generated from
+		key := strings.ToLower(strings.ReplaceAll(fragment, "_", "-"))
+		heading, ok := page.anchors[key]
+		if !ok {
+			heading, ok = page.anchors[strings.ReplaceAll(key, "-", "")]
+		}
+		if ok {
+			return wiki + "#" + heading, true
+		}
+	}
+	if wiki == "" {
+		// A fragment with no heading on the same page links nowhere
+		return "", false
+	}
+	return wiki, true
+}
+
+// writeIndexNotes writes an index note into every folder with pages,
+// listing them and its subfolders, returning the number written. A folder's
+// note is named after it, as folder note plugins expect, and the vault's
+// is Index.md; existing pages of those names are left as they are
+func (v *Vault) writeIndexNotes(pages map[string]*vaultPage) (int, error) {
+	folders := make(map[string][]string) // Folder -> pages and subfolders
+	for rel := range pages {
+		folders[path.Dir(rel)] = append(folders[path.Dir(rel)], rel)
+		for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+			parent := path.Dir(dir)
+			if _, ok := folders[dir]; !ok {
+				folders[dir] = nil
+			}
+			if !contains(folders[parent], dir+"/") {
+				folders[parent] = append(folders[parent], dir+"/")
+			}
+		}
+	}
+
+	written := 0
+	for dir, entries := range folders {
+		note := indexNote(dir)
+		if _, ok := pages[note]; ok {
+			continue
+		}
+
+		sort.Strings(entries)
+		var sb strings.Builder
+		name := path.Base(dir)
+		if dir == "." {
+			name = "Index"
+		}
+		fmt.Fprintf(&sb, "# %s\n", name)
+
+		var subfolders, notes []string
+		for _, entry := range entries {
+			if sub, ok := strings.CutSuffix(entry, "/"); ok {
+				subfolders = append(subfolders, fmt.Sprintf("- [[%s|%s]]", strings.TrimSuffix(indexNote(sub), ".md"), path.Base(sub)))
+				continue
+			}
+			title := pages[entry].title
+			if title == "" {
+				title = strings.TrimSuffix(path.Base(entry), ".md")
+			}
+			notes = append(notes, fmt.Sprintf("- [[%s|%s]]", strings.TrimSuffix(entry, ".md"), title))
+		}
+		if len(subfolders) > 0 {
+			sb.WriteString("\n## Sections\n\n" + strings.Join(subfolders, "\n") + "\n")
+		}
+		if len(notes) > 0 {
+			sb.WriteString("\n## Pages\n\n" + strings.Join(notes, "\n") + "\n")
+		}
+
+		if err := writeFile(filepath.Join(v.config.OutputDir, filepath.FromSlash(note)), []byte(sb.String())); err != nil {
+			return written, err
+		}
+		written++
+	}
+	return written, nil
+}
+
+// indexNote returns the vault path of the index note of a folder
+func indexNote(dir string) string {
+	if dir == "." {
+		return "Index.md"
+	}
+	return dir + "/" + path.Base(dir) + ".md"
+}
+
+// contains reports whether list includes s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package export
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVault_WikiLinks(t *testing.T) {
+	pages := map[string]*vaultPage{
+		"Two/WebHome.md":           scanPage("", "# Welcome\n\n## Getting Started\n"),
+		"Two/Script/Reference.md":  scanPage("", "# UnrealScript Reference\n\n## Latent Functions\n"),
+		"Two/Script/Reference2.md": scanPage("", "---\ntitle: \"Reference 2\"\n---\n"),
+		"Two/Script/Actor.md":      scanPage("", "# Actor {#Actor}\n\n## Timers {#TimerFunctions}\n"),
+	}
+	v := NewVault(VaultConfig{})
+
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{
+			name:     "page link",
+			markdown: "See [the reference](Script/Reference.md).",
+			want:     "See [[Two/Script/Reference|the reference]].",
+		},
+		{
+			name:     "label matching page name",
+			markdown: "[Reference](Script/Reference.md)",
+			want:     "[[Two/Script/Reference]]",
+		},
+		{
+			name:     "TWiki anchor to heading",
+			markdown: "[latent functions](Script/Reference.md#Latent_Functions)",
+			want:     "[[Two/Script/Reference#Latent Functions|latent functions]]",
+		},
+		{
+			name:     "same page heading",
+			markdown: "[start](#GettingStarted) [start](#Getting_Started) [nowhere](#Nowhere)",
+			want:     "[[#Getting Started|start]] [[#Getting Started|start]] [nowhere](#Nowhere)",
+		},
+		{
+			name:     "explicit heading id",
+			markdown: "[timers](Script/Actor.md#TimerFunctions)",
+			want:     "[[Two/Script/Actor#Timers|timers]]",
+		},
+		{
+			name:     "heading id stripped",
+			markdown: "## Latent Functions {#Latent_Functions}\n\nText {#kept}",
+			want:     "## Latent Functions\n\nText {#kept}",
+		},
+		{
+			name:     "unknown anchor links to page",
+			markdown: "[top](Script/Reference.md#top)",
+			want:     "[[Two/Script/Reference|top]]",
+		},
+		{
+			name:     "image",
+			markdown: "![Diagram](rsrc/diagram%20one.gif)",
+			want:     "![[attachments/Two/rsrc/diagram one.gif]]",
+		},
+		{
+			name:     "linked image",
+			markdown: "[![UDN](rsrc/logo.gif)](WebHome.md)",
+			want:     "![[attachments/Two/rsrc/logo.gif]] [[Two/WebHome|UDN]]",
+		},
+		{
+			name:     "external and escaped",
+			markdown: "[UDN](https://udn.epicgames.com/Two/) \\[not a link](WebHome.md) `[code](WebHome.md)` [out](../../Other.md)",
+			want:     "[UDN](https://udn.epicgames.com/Two/) \\[not a link](WebHome.md) `[code](WebHome.md)` [out](../../Other.md)",
+		},
+		{
+			name:     "table cell",
+			markdown: "| [Ref](Script/Reference.md#Latent_Functions) | x |",
+			want:     "| [[Two/Script/Reference#Latent Functions\\|Ref]] | x |",
+		},
+		{
+			name:     "fenced code",
+			markdown: "```\n[Ref](Script/Reference.md)\n```",
+			want:     "```\n[Ref](Script/Reference.md)\n```",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := v.wikiLinks(tt.markdown, "Two/WebHome.md", pages); got != tt.want {
+				t.Errorf("wikiLinks() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVault_Run(t *testing.T) {
+	inputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "Two", "WebHome.md"), "# Welcome\n\nSee [the reference](Script/Reference.md).\n")
+	writeTestFile(t, filepath.Join(inputDir, "Two", "Script", "Reference.md"), "# UnrealScript Reference\n\n![Diagram](../rsrc/diagram.gif)\n")
+	writeTestFile(t, filepath.Join(inputDir, "Two", "rsrc", "diagram.gif"), "GIF89a")
+	writeTestFile(t, filepath.Join(inputDir, ".previous", "Two", "WebHome.md"), "# Old\n")
+
+	outputDir := t.TempDir()
+	result, err := NewVault(VaultConfig{InputDir: inputDir, OutputDir: outputDir}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Pages != 2 || result.Assets != 1 || result.Sections != 3 {
+		t.Errorf("Run() = %+v, want 2 pages, 1 asset and 3 sections", result)
+	}
+
+	read := func(rel string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(rel)))
+		if err != nil {
+			t.Fatalf("reading %s: %v", rel, err)
+		}
+		return string(data)
+	}
+
+	if got := read("Two/Script/Reference.md"); !strings.Contains(got, "![[attachments/Two/rsrc/diagram.gif]]") {
+		t.Errorf("Reference.md image not embedded\n%s", got)
+	}
+	read("attachments/Two/rsrc/diagram.gif")
+
+	wantNotes := map[string]string{
+		"Index.md":             "# Index\n\n## Sections\n\n- [[Two/Two|Two]]\n",
+		"Two/Two.md":           "# Two\n\n## Sections\n\n- [[Two/Script/Script|Script]]\n\n## Pages\n\n- [[Two/WebHome|Welcome]]\n",
+		"Two/Script/Script.md": "# Script\n\n## Pages\n\n- [[Two/Script/Reference|UnrealScript Reference]]\n",
+	}
+	for note, want := range wantNotes {
+		if got := read(note); got != want {
+			t.Errorf("%s =\n%s\nwant\n%s", note, got, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, ".previous")); !os.IsNotExist(err) {
+		t.Error("hidden converter directory was exported")
+	}
+}
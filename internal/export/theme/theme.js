@@ -0,0 +1,82 @@
+// Minimal behaviour for documentation exported by ue2-docs export html:
+// an "on this page" outline, heading permalinks and copy buttons on code
+
+(function () {
+  "use strict";
+
+  var headings = document.querySelectorAll("main h2[id], main h3[id]");
+
+  // Outline of the page's sections, highlighting the one being read
+  var toc = document.querySelector("nav.toc");
+  if (toc && headings.length > 1) {
+    var list = document.createElement("ul");
+    var current = list;
+    var links = [];
+    headings.forEach(function (h) {
+      var item = document.createElement("li");
+      var a = document.createElement("a");
+      a.href = "#" + h.id;
+      a.textContent = h.textContent;
+      item.appendChild(a);
+      links.push(a);
+
+      if (h.tagName === "H3" && list.lastChild) {
+        current = list.lastChild.querySelector("ul");
+        if (!current) {
+          current = document.createElement("ul");
+          list.lastChild.appendChild(current);
+        }
+      } else {
+        current = list;
+      }
+      current.appendChild(item);
+    });
+    toc.appendChild(list);
+
+    var highlight = function () {
+      var active = 0;
+      headings.forEach(function (h, i) {
+        if (h.getBoundingClientRect().top < 80) {
+          active = i;
+        }
+      });
+      links.forEach(function (a, i) {
+        a.classList.toggle("active", i === active);
+      });
+    };
+    window.addEventListener("scroll", highlight, { passive: true });
+    highlight();
+  }
+
+  // Permalinks beside every heading with an anchor
+  document.querySelectorAll("main [id]").forEach(function (h) {
+    if (!/^H[1-6]$/.test(h.tagName)) {
+      return;
+    }
+    var a = document.createElement("a");
+    a.className = "anchor";
+    a.href = "#" + h.id;
+    a.textContent = "#";
+    a.setAttribute("aria-label", "Link to this section");
+    h.appendChild(a);
+  });
+
+  // Copy buttons on code blocks
+  if (navigator.clipboard) {
+    document.querySelectorAll("pre > code").forEach(function (code) {
+      var button = document.createElement("button");
+      button.className = "copy";
+      button.type = "button";
+      button.textContent = "Copy";
+      button.addEventListener("click", function () {
+        navigator.clipboard.writeText(code.textContent).then(function () {
+          button.textContent = "Copied";
+          setTimeout(function () {
+            button.textContent = "Copy";
+          }, 1500);
+        });
+      });
+      code.parentNode.appendChild(button);
+    });
+  }
+})();
@@ -0,0 +1,144 @@
+package fetcher
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/aldehir/ue2-docs/internal/urlutil"
+)
+
+// sniffLen is how much of a body is examined to tell whether it is really
+// compressed
+const sniffLen = 512
+
+// decodeBody returns a reader for a response body with any Content-Encoding
+// the transport left in place removed. Archived hosts often get encoding
+// wrong: deflate without the zlib wrapper, encodings declared on plain
+// bodies, or text gzipped twice. Such bodies are decoded anyway and the
+// workaround is described in the returned anomaly
+func decodeBody(resp *http.Response, resourceType urlutil.ResourceType) (io.Reader, string, error) {
+	br := bufio.NewReaderSize(resp.Body, sniffLen)
+	var r io.Reader = br
+	var anomaly string
+	decoded := false // A compressed layer has been removed
+
+	switch encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		if isGzip(br) {
+			gz, err := gzip.NewReader(br)
+			if err != nil {
+				return nil, "", fmt.Errorf("%w: %v", ErrBadEncoding, err)
+			}
+			r = gz
+			decoded = true
+		} else {
+			anomaly = "gzip declared on an uncompressed body"
+		}
+	case "deflate":
+		switch {
+		case isZlib(br):
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, "", fmt.Errorf("%w: %v", ErrBadEncoding, err)
+			}
+			r = zr
+			decoded = true
+		case isGzip(br):
+			gz, err := gzip.NewReader(br)
+			if err != nil {
+				return nil, "", fmt.Errorf("%w: %v", ErrBadEncoding, err)
+			}
+			r = gz
+			decoded = true
+			anomaly = "gzip body declared as deflate"
+		case isTextual(resourceType) && isPlainText(br):
+			anomaly = "deflate declared on an uncompressed body"
+		default:
+			// Many servers send raw DEFLATE data, as the encoding's
+			// name suggests, rather than the zlib format it specifies
+			r = flate.NewReader(br)
+			decoded = true
+			anomaly = "raw deflate without zlib header"
+		}
+	default:
+		return nil, "", fmt.Errorf("%w: unsupported Content-Encoding %q", ErrBadEncoding, encoding)
+	}
+
+	// A compressed layer left over after decoding means the server
+	// compressed an already compressed file. Only text is checked, since
+	// binary downloads may legitimately be gzip files
+	if isTextual(resourceType) {
+		inner := bufio.NewReaderSize(r, sniffLen)
+		r = inner
+		if isGzip(inner) {
+			gz, err := gzip.NewReader(inner)
+			if err != nil {
+				return nil, "", fmt.Errorf("%w: %v", ErrBadEncoding, err)
+			}
+			r = gz
+			switch {
+			case anomaly != "":
+				anomaly += ", then gzip"
+			case decoded:
+				anomaly = "double gzip"
+			default:
+				anomaly = "undeclared gzip"
+			}
+		}
+	}
+
+	return corruptionReader{r}, anomaly, nil
+}
+
+// isGzip reports whether a buffered body starts with the gzip magic number
+func isGzip(br *bufio.Reader) bool {
+	magic, _ := br.Peek(2)
+	return len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b
+}
+
+// isZlib reports whether a buffered body starts with a valid zlib header
+func isZlib(br *bufio.Reader) bool {
+	header, _ := br.Peek(2)
+	return len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0
+}
+
+// isPlainText reports whether a buffered body starts with uncompressed text
+func isPlainText(br *bufio.Reader) bool {
+	prefix, _ := br.Peek(sniffLen)
+	return strings.HasPrefix(http.DetectContentType(prefix), "text/") && !bytes.ContainsRune(prefix, 0)
+}
+
+// isTextual reports whether a resource type is text that is never itself
+// compressed
+func isTextual(resourceType urlutil.ResourceType) bool {
+	switch resourceType {
+	case urlutil.ResourceHTML, urlutil.ResourceCSS, urlutil.ResourceJS:
+		return true
+	}
+	return false
+}
+
+// corruptionReader marks decompression failures with ErrBadEncoding,
+// leaving network errors from the underlying body as they are
+type corruptionReader struct {
+	r io.Reader
+}
+
+func (c corruptionReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	var corrupt flate.CorruptInputError
+	if errors.As(err, &corrupt) || errors.Is(err, gzip.ErrChecksum) || errors.Is(err, gzip.ErrHeader) ||
+		errors.Is(err, zlib.ErrChecksum) || errors.Is(err, zlib.ErrHeader) {
+		err = fmt.Errorf("%w: %v", ErrBadEncoding, err)
+	}
+	return n, err
+}
@@ -0,0 +1,132 @@
+package fetcher
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zlibBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func flateBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFetcher_DecodeBody(t *testing.T) {
+	page := []byte("<html><body><p>Unreal Engine 2 documentation</p></body></html>")
+	archive := gzipBytes(t, []byte("tarball"))
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		encoding    string
+		body        []byte
+		want        []byte
+		wantAnomaly string
+	}{
+		{"identity", "/Page.html", "text/html", "", page, page, ""},
+		{"gzip", "/Page.html", "text/html", "gzip", gzipBytes(t, page), page, ""},
+		{"zlib deflate", "/Page.html", "text/html", "deflate", zlibBytes(t, page), page, ""},
+		{"raw deflate", "/Page.html", "text/html", "deflate", flateBytes(t, page), page, "raw deflate without zlib header"},
+		{"gzip as deflate", "/Page.html", "text/html", "deflate", gzipBytes(t, page), page, "gzip body declared as deflate"},
+		{"plain gzip", "/Page.html", "text/html", "gzip", page, page, "gzip declared on an uncompressed body"},
+		{"plain deflate", "/Page.html", "text/html", "deflate", page, page, "deflate declared on an uncompressed body"},
+		{"double gzip", "/Page.html", "text/html", "gzip", gzipBytes(t, gzipBytes(t, page)), page, "double gzip"},
+		{"undeclared gzip", "/Page.css", "text/css", "", gzipBytes(t, page), page, "undeclared gzip"},
+		{"gzip archive", "/Tools.tar.gz", "application/x-gzip", "", archive, archive, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				if tt.encoding != "" {
+					w.Header().Set("Content-Encoding", tt.encoding)
+				}
+				w.Write(tt.body)
+			}))
+			defer server.Close()
+
+			buf := &bytes.Buffer{}
+			resp, err := New(DefaultConfig()).Fetch(context.Background(), server.URL+tt.path, buf)
+			if err != nil {
+				t.Fatalf("Fetch() error = %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), tt.want) {
+				t.Errorf("body = %q, want %q", buf.Bytes(), tt.want)
+			}
+			if resp.EncodingAnomaly != tt.wantAnomaly {
+				t.Errorf("EncodingAnomaly = %q, want %q", resp.EncodingAnomaly, tt.wantAnomaly)
+			}
+		})
+	}
+}
+
+func TestFetcher_DecodeBodyCorrupt(t *testing.T) {
+	corrupt := gzipBytes(t, []byte("<html><body>truncated checksum</body></html>"))
+	corrupt[len(corrupt)-5] ^= 0xff
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+	}{
+		{"bad checksum", "gzip", corrupt},
+		{"unsupported encoding", "br", []byte("\x0b\x02\x80hello\x03")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				w.Header().Set("Content-Type", "text/html")
+				w.Header().Set("Content-Encoding", tt.encoding)
+				w.Write(tt.body)
+			}))
+			defer server.Close()
+
+			_, err := New(DefaultConfig()).Fetch(context.Background(), server.URL, &bytes.Buffer{})
+			if !errors.Is(err, ErrBadEncoding) {
+				t.Fatalf("expected ErrBadEncoding, got %v", err)
+			}
+			if attempts != 1 {
+				t.Errorf("expected 1 attempt (no retries when undecodable), got %d", attempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package fetcher
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrClientStatus matches a StatusError for a 4xx response
+	ErrClientStatus = errors.New("client error status")
+
+	// ErrServerStatus matches a StatusError for a 5xx response
+	ErrServerStatus = errors.New("server error status")
+
+	// ErrTooLarge is returned when a response body exceeds Config.MaxBodySize
+	ErrTooLarge = errors.New("response body too large")
+
+	// ErrBadEncoding is returned when a response body cannot be decoded
+	// from its Content-Encoding
+	ErrBadEncoding = errors.New("undecodable content encoding")
+
+	// ErrFTPArgument is returned for ftp:// URLs whose path or credentials
+	// hold line breaks or NUL, which would end the FTP command they are
+	// sent in
+	ErrFTPArgument = errors.New("control character in FTP command argument")
+)
+
+// StatusError is returned for a response with a non-2xx status code.
+// errors.Is reports whether it is an ErrClientStatus or ErrServerStatus
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("HTTP %d", e.StatusCode)
+}
+
+// Is matches the status class sentinels
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrClientStatus:
+		return e.StatusCode >= 400 && e.StatusCode < 500
+	case ErrServerStatus:
+		return e.StatusCode >= 500 && e.StatusCode < 600
+	}
+	return false
+}
+
+// StatusCode returns the status code of the StatusError wrapped in err, or
+// 0 if there is none
+func StatusCode(err error) int {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode
+	}
+	return 0
+}
@@ -2,33 +2,94 @@ package fetcher
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"net/http"
+	"net/http/httptrace"
+	neturl "net/url"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/aldehir/ue2-docs/internal/metrics"
 	"github.com/aldehir/ue2-docs/internal/urlutil"
 )
 
+// ErrRedirectLoop is returned when a redirect chain revisits a URL
+var ErrRedirectLoop = errors.New("redirect loop")
+
+// ErrRedirectScheme is returned when an http or https URL redirects to
+// another scheme
+var ErrRedirectScheme = errors.New("redirect to another scheme")
+
+// maxRedirects is the longest redirect chain followed before giving up
+const maxRedirects = 10
+
 // Response represents a fetched resource
 type Response struct {
 	URL          string
+	FinalURL     string   // URL the response was served from after redirects
+	Redirects    []string // URLs redirected through, starting with URL
 	StatusCode   int
 	ContentType  string
 	ResourceType urlutil.ResourceType
 	BytesWritten int64
 	Headers      http.Header
+	Timing       Timing // Breakdown of the final attempt
+	Attempts     int    // Requests made, including retries
+	ResumedFrom  int64  // Offset an interrupted body was resumed from (0 = not resumed)
+
+	// EncodingAnomaly describes a broken Content-Encoding that was worked
+	// around while decoding the body, e.g. "double gzip" ("" = none)
+	EncodingAnomaly string
 }
 
 // Config holds fetcher configuration
 type Config struct {
-	Timeout       time.Duration
-	MaxRetries    int
-	InitialDelay  time.Duration
-	MaxDelay      time.Duration
-	UserAgent     string
-	RateLimiter   RateLimiter
+	Timeout      time.Duration
+	MaxRetries   int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	UserAgent    string
+	RateLimiter  RateLimiter
+	Transport    http.RoundTripper // nil = http.DefaultTransport plus ftp://, and file:// with LocalFiles
+	MaxBodySize  int64             // Larger responses fail with ErrTooLarge (0 = unlimited)
+	RetryPolicy  RetryPolicy       // Which failures to retry; nil = DefaultRetryPolicy
+
+	// Resume continues bodies cut off partway with Range requests,
+	// validated by ETag or Last-Modified, instead of fetching them again
+	// from the start. Only writers implementing Resetter are resumed, as
+	// they must be reset should the server send the whole body instead
+	Resume bool
+
+	// Lenient retries requests whose responses net/http rejects as
+	// malformed, such as HTTP/0.9 replies without a status line or headers
+	// without a colon, over a raw connection parsed as best it can, to
+	// rescue ancient servers
+	Lenient bool
+
+	// LocalFiles serves file:// URLs from the local filesystem, so an
+	// existing local mirror can be crawled from a file:// root. Otherwise
+	// file:// URLs fail, and no server can point the fetcher at a local
+	// file
+	LocalFiles bool
+
+	// UpgradeHTTPS fetches http:// URLs over HTTPS, and falls back to
+	// plain HTTP for hosts that cannot be reached over HTTPS at all, such
+	// as those without TLS. Each host is probed once, without retries, and
+	// its outcome remembered
+	UpgradeHTTPS bool
+
+	// UserAgents, when set, replaces UserAgent with several user agents,
+	// which requests are sent with as UserAgentRotation says
+	UserAgents        []string
+	UserAgentRotation UserAgentRotation
+
+	// Metrics receives a count, duration and byte count of every request,
+	// and of retries; nil = metrics.Nop
+	Metrics metrics.Sink
 }
 
 // DefaultConfig returns a sensible default configuration
@@ -38,8 +99,9 @@ func DefaultConfig() Config {
 		MaxRetries:   3,
 		InitialDelay: 1 * time.Second,
 		MaxDelay:     30 * time.Second,
-		UserAgent:    "ue2-docs-scraper/1.0",
+		UserAgent:    ArchivalUserAgent(""),
 		RateLimiter:  nil, // No rate limiting by default
+		Resume:       true,
 	}
 }
 
@@ -47,30 +109,115 @@ func DefaultConfig() Config {
 type Fetcher struct {
 	client *http.Client
 	config Config
+	agents *userAgents
+
+	secureHosts sync.Map // Host -> whether it serves HTTPS, with UpgradeHTTPS
 }
 
 // New creates a new Fetcher with the given configuration
 func New(config Config) *Fetcher {
+	transport := config.Transport
+	if transport == nil {
+		transport = defaultTransport(config.LocalFiles)
+	}
+	if config.Lenient {
+		transport = &lenientTransport{base: transport}
+	}
+
+	config.Metrics = metrics.OrNop(config.Metrics)
+
 	return &Fetcher{
 		client: &http.Client{
-			Timeout: config.Timeout,
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				// Follow up to 10 redirects
-				if len(via) >= 10 {
-					return fmt.Errorf("too many redirects")
-				}
-				return nil
-			},
+			Transport:     transport,
+			Timeout:       config.Timeout,
+			CheckRedirect: checkRedirect,
 		},
 		config: config,
+		agents: newUserAgents(config),
 	}
 }
 
-// Fetch retrieves a resource and streams it to the provided writer
+// defaultTransport returns the default HTTP transport extended to
+// download ftp:// URLs and, with localFiles, to serve file:// URLs from
+// the local filesystem, so an existing local mirror can be crawled like a
+// live site
+func defaultTransport(localFiles bool) http.RoundTripper {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if localFiles {
+		t.RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
+	}
+	t.RegisterProtocol("ftp", &ftpTransport{})
+	return t
+}
+
+// checkRedirect refuses redirects from the web to other schemes, such as
+// file:// or ftp://, which would have a server choose what is read from
+// disk or another host's FTP server, and stops circular redirect chains
+// as soon as a URL repeats, rather than letting them run into the
+// redirect cap
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if web(via[0].URL.Scheme) && !web(req.URL.Scheme) {
+		return fmt.Errorf("%w: %s", ErrRedirectScheme, req.URL)
+	}
+	for _, prev := range via {
+		if prev.URL.String() == req.URL.String() {
+			return fmt.Errorf("%w: %s", ErrRedirectLoop, req.URL)
+		}
+	}
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("too many redirects")
+	}
+	return nil
+}
+
+// web reports whether scheme is http or https
+func web(scheme string) bool {
+	return scheme == "http" || scheme == "https"
+}
+
+// redirectChain returns the URLs redirected through to reach resp, in order
+func redirectChain(resp *http.Response) []string {
+	var chain []string
+	for r := resp.Request.Response; r != nil; r = r.Request.Response {
+		chain = append([]string{r.Request.URL.String()}, chain...)
+	}
+	return chain
+}
+
+// Resetter is implemented by writers that can discard what they were
+// written, such as bytes.Buffer
+type Resetter interface {
+	Reset()
+}
+
+// Fetch retrieves a resource and streams it to the provided writer. A
+// writer implementing Resetter is reset before each retry, so a body cut
+// off partway is not left in front of the retried one, unless the body is
+// resumed with Config.Resume
 func (f *Fetcher) Fetch(ctx context.Context, url string, w io.Writer) (*Response, error) {
+	if f.config.UpgradeHTTPS {
+		return f.fetchUpgraded(ctx, url, w)
+	}
+	return f.fetch(ctx, url, w)
+}
+
+// fetch fetches url with retries
+func (f *Fetcher) fetch(ctx context.Context, url string, w io.Writer) (*Response, error) {
+	return f.retry(ctx, url, w, f.doFetch)
+}
+
+// request performs a single request of url, streaming any body to w
+type request func(ctx context.Context, url string, w io.Writer, from *partial) (*Response, error)
+
+// retry makes the request of url with rate limiting, retrying it as the
+// retry policy says
+func (f *Fetcher) retry(ctx context.Context, url string, w io.Writer, do request) (*Response, error) {
 	var lastErr error
+	var resume *partial
+	_, resettable := w.(Resetter)
+	maxRetries := maxRetriesFrom(ctx, f.config.MaxRetries)
 
-	for attempt := 0; attempt <= f.config.MaxRetries; attempt++ {
+	for attempt := 0; attempt <= maxRetries; attempt++ {
 		if attempt > 0 {
 			// Calculate exponential backoff delay
 			delay := f.calculateBackoff(attempt)
@@ -90,35 +237,98 @@ func (f *Fetcher) Fetch(ctx context.Context, url string, w io.Writer) (*Response
 			}
 		}
 
-		resp, err := f.doFetch(ctx, url, w)
+		if r, ok := w.(Resetter); ok && attempt > 0 && resume == nil {
+			r.Reset()
+		}
+
+		requestStart := time.Now()
+		resp, err := do(ctx, url, w, resume)
+		f.report(url, resp, err, time.Since(requestStart))
 		if err == nil {
+			resp.Attempts = attempt + 1
 			return resp, nil
 		}
 
 		lastErr = err
+		if f.config.Resume && resettable {
+			resume = resumable(resume, resp, err)
+		}
 
 		// Don't retry on context cancellation
 		if ctx.Err() != nil {
 			return nil, ctx.Err()
 		}
 
-		// Don't retry on client errors (4xx), only on server errors (5xx) or network errors
-		if resp != nil && resp.StatusCode >= 400 && resp.StatusCode < 500 {
-			return nil, fmt.Errorf("client error %d: %w", resp.StatusCode, err)
+		if !f.retryPolicy().Retry(err) {
+			return nil, err
+		}
+		if attempt < maxRetries {
+			f.config.Metrics.Add(metrics.Retries, 1, metrics.Label{Name: "host", Value: hostOf(url)})
+		}
+
+		if logger := loggerFrom(ctx); logger != nil && attempt < maxRetries {
+			if resume != nil {
+				logger.Warn("fetch attempt failed, resuming", "attempt", attempt+1, "max_attempts", maxRetries+1, "offset", resume.offset, "error", err)
+			} else {
+				logger.Warn("fetch attempt failed, retrying", "attempt", attempt+1, "max_attempts", maxRetries+1, "error", err)
+			}
 		}
 	}
 
-	return nil, fmt.Errorf("failed after %d retries: %w", f.config.MaxRetries, lastErr)
+	return nil, fmt.Errorf("failed after %d retries: %w", maxRetries, lastErr)
+}
+
+// report reports a request to the metrics sink
+func (f *Fetcher) report(url string, resp *Response, err error, elapsed time.Duration) {
+	host := metrics.Label{Name: "host", Value: hostOf(url)}
+	status := "error"
+	if resp != nil && resp.StatusCode != 0 {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	f.config.Metrics.Add(metrics.Requests, 1, host, metrics.Label{Name: "status", Value: status})
+	f.config.Metrics.Observe(metrics.RequestDuration, elapsed, host)
+	if resp != nil && resp.BytesWritten > resp.ResumedFrom {
+		f.config.Metrics.Add(metrics.BytesFetched, resp.BytesWritten-resp.ResumedFrom, host)
+	}
+}
+
+// hostOf returns the host of a URL, or "" if it cannot be parsed
+func hostOf(rawURL string) string {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
 }
 
-// doFetch performs a single HTTP request and streams the response to a writer
-func (f *Fetcher) doFetch(ctx context.Context, url string, w io.Writer) (*Response, error) {
+// retryPolicy returns the configured retry policy or the default
+func (f *Fetcher) retryPolicy() RetryPolicy {
+	if f.config.RetryPolicy != nil {
+		return f.config.RetryPolicy
+	}
+	return DefaultRetryPolicy
+}
+
+// doFetch performs a single HTTP request and streams the response to a
+// writer, asking only for the rest of the body if resuming from a partial
+// one
+func (f *Fetcher) doFetch(ctx context.Context, url string, w io.Writer, from *partial) (*Response, error) {
+	recorder := newTimingRecorder()
+	ctx = httptrace.WithClientTrace(ctx, recorder.trace())
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
 
-	req.Header.Set("User-Agent", f.config.UserAgent)
+	req.Header.Set("User-Agent", f.agents.pick(req.URL.Host))
+	// Asking for gzip explicitly stops the transport from decoding it, which
+	// would fail on bodies decodeBody can still rescue
+	req.Header.Set("Accept-Encoding", "gzip")
+	if from != nil {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", from.offset))
+		req.Header.Set("If-Range", from.validator)
+	}
 
 	resp, err := f.client.Do(req)
 	if err != nil {
@@ -132,29 +342,100 @@ func (f *Fetcher) doFetch(ctx context.Context, url string, w io.Writer) (*Respon
 			URL:        url,
 			StatusCode: resp.StatusCode,
 			Headers:    resp.Header,
-		}, fmt.Errorf("HTTP %d", resp.StatusCode)
+			Timing:     recorder.finish(),
+		}, &StatusError{StatusCode: resp.StatusCode}
 	}
 
-	// Stream response body to writer
-	bytesWritten, err := io.Copy(w, resp.Body)
-	if err != nil {
+	// A 206 continues the partial body; anything else is the whole body,
+	// the resource having changed or the server ignoring ranges
+	var offset int64
+	if from != nil && resp.StatusCode == http.StatusPartialContent {
+		if err := checkContentRange(resp, from.offset); err != nil {
+			return &Response{
+				URL:        url,
+				StatusCode: resp.StatusCode,
+				Headers:    resp.Header,
+				Timing:     recorder.finish(),
+			}, err
+		}
+		offset = from.offset
+	} else if r, ok := w.(Resetter); ok && from != nil {
+		r.Reset()
+	}
+
+	max := f.config.MaxBodySize
+	if max > 0 && resp.ContentLength >= 0 && offset+resp.ContentLength > max {
 		return &Response{
-			URL:          url,
-			StatusCode:   resp.StatusCode,
-			BytesWritten: bytesWritten,
-			Headers:      resp.Header,
-		}, fmt.Errorf("streaming response body: %w", err)
+			URL:        url,
+			StatusCode: resp.StatusCode,
+			Headers:    resp.Header,
+			Timing:     recorder.finish(),
+		}, fmt.Errorf("%w: %d bytes exceeds %d", ErrTooLarge, offset+resp.ContentLength, max)
 	}
 
+	finalURL := resp.Request.URL.String()
+	redirects := redirectChain(resp)
 	contentType := resp.Header.Get("Content-Type")
+	resourceType := urlutil.DetectResourceType(finalURL, contentType)
+
+	// The rest of a body is sent as is, checked by checkContentRange
+	var body io.Reader = resp.Body
+	var anomaly string
+	if offset == 0 {
+		body, anomaly, err = decodeBody(resp, resourceType)
+	}
+	if err != nil {
+		return &Response{
+			URL:        url,
+			StatusCode: resp.StatusCode,
+			Headers:    resp.Header,
+			Timing:     recorder.finish(),
+		}, err
+	}
+
+	// Stream response body to writer, reading one byte past the limit to
+	// catch bodies without a Content-Length that exceed it
+	if max > 0 {
+		body = io.LimitReader(body, max+1-offset)
+	}
+	reader := &bodyReader{r: body}
+	written, err := io.Copy(w, reader)
+	bytesWritten := offset + written
+	if err == nil && max > 0 && bytesWritten > max {
+		err = fmt.Errorf("%w: exceeds %d bytes", ErrTooLarge, max)
+	} else if err != nil {
+		err = &streamError{err: err, read: err == reader.err}
+	}
+	if err != nil {
+		return &Response{
+			URL:             url,
+			StatusCode:      resp.StatusCode,
+			BytesWritten:    bytesWritten,
+			Headers:         resp.Header,
+			Timing:          recorder.finish(),
+			ResumedFrom:     offset,
+			EncodingAnomaly: anomaly,
+		}, err
+	}
+
+	// The resumed body is whole, as a 200 would have sent it
+	statusCode := resp.StatusCode
+	if offset > 0 {
+		statusCode = http.StatusOK
+	}
 
 	return &Response{
-		URL:          url,
-		StatusCode:   resp.StatusCode,
-		ContentType:  contentType,
-		ResourceType: urlutil.DetectResourceType(url, contentType),
-		BytesWritten: bytesWritten,
-		Headers:      resp.Header,
+		URL:             url,
+		FinalURL:        finalURL,
+		Redirects:       redirects,
+		StatusCode:      statusCode,
+		ContentType:     contentType,
+		ResourceType:    resourceType,
+		BytesWritten:    bytesWritten,
+		Headers:         resp.Header,
+		Timing:          recorder.finish(),
+		ResumedFrom:     offset,
+		EncodingAnomaly: anomaly,
 	}, nil
 }
 
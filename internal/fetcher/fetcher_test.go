@@ -4,12 +4,18 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/aldehir/ue2-docs/internal/metrics"
 	"github.com/aldehir/ue2-docs/internal/urlutil"
 )
 
@@ -137,6 +143,179 @@ func TestFetcher_Fetch_RetryOnServerError(t *testing.T) {
 	}
 }
 
+func TestFetcher_Fetch_LogsRetries(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("success"))
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.MaxRetries = 3
+	config.InitialDelay = time.Millisecond
+	fetcher := New(config)
+
+	logs := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(logs, nil)).With("trace", "abc123")
+	resp, err := fetcher.Fetch(WithLogger(context.Background(), logger), server.URL, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if resp.Attempts != 3 {
+		t.Errorf("Attempts = %d, want 3", resp.Attempts)
+	}
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("logged %d lines, want one per retried attempt:\n%s", len(lines), logs)
+	}
+	for i, line := range lines {
+		if !strings.Contains(line, fmt.Sprintf("attempt=%d max_attempts=4", i+1)) || !strings.Contains(line, "trace=abc123") {
+			t.Errorf("log line %d = %q, want attempt %d with the caller's fields", i, line, i+1)
+		}
+	}
+}
+
+func TestFetcher_Fetch_RetryResetsWriter(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			// Promise more than is sent, cutting the body off
+			w.Header().Set("Content-Length", "100")
+			w.Write([]byte("partial"))
+			return
+		}
+		w.Write([]byte("complete"))
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.MaxRetries = 1
+	config.InitialDelay = time.Millisecond
+	buf := &bytes.Buffer{}
+	if _, err := New(config).Fetch(context.Background(), server.URL, buf); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if buf.String() != "complete" {
+		t.Errorf("body = %q, want only the retried attempt's", buf.String())
+	}
+}
+
+func TestFetcher_Fetch_Resume(t *testing.T) {
+	body := strings.Repeat("0123456789", 10)
+
+	tests := []struct {
+		name        string
+		headers     map[string]string
+		etag        string // Served on the retried attempt
+		wantRange   string
+		wantResumed int64
+	}{
+		{"strong etag", map[string]string{"ETag": `"v1"`}, `"v1"`, "bytes=30-", 30},
+		{"last modified", map[string]string{"Last-Modified": "Mon, 01 Mar 2004 12:00:00 GMT"}, "", "bytes=30-", 30},
+		{"changed since", map[string]string{"ETag": `"v1"`}, `"v2"`, "bytes=30-", 0},
+		{"weak etag", map[string]string{"ETag": `W/"v1"`}, `W/"v1"`, "", 0},
+		{"no validator", nil, "", "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			var gotRange atomic.Value
+			gotRange.Store("")
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if attempts.Add(1) == 1 {
+					for k, v := range tt.headers {
+						w.Header().Set(k, v)
+					}
+					// Promise the whole body, cutting it off after 30 bytes
+					w.Header().Set("Content-Length", "100")
+					w.Write([]byte(body[:30]))
+					return
+				}
+				gotRange.Store(r.Header.Get("Range"))
+				if tt.etag != "" {
+					w.Header().Set("ETag", tt.etag)
+				}
+				if tt.etag == `"v2"` {
+					body := strings.ToUpper(body)
+					w.Write([]byte(body))
+					return
+				}
+				http.ServeContent(w, r, "file.bin", time.Date(2004, 3, 1, 12, 0, 0, 0, time.UTC), strings.NewReader(body))
+			}))
+			defer server.Close()
+
+			config := DefaultConfig()
+			config.MaxRetries = 1
+			config.InitialDelay = time.Millisecond
+			config.Resume = true
+			buf := &bytes.Buffer{}
+			resp, err := New(config).Fetch(context.Background(), server.URL+"/file.bin", buf)
+			if err != nil {
+				t.Fatalf("Fetch() error = %v", err)
+			}
+
+			if got := gotRange.Load().(string); got != tt.wantRange {
+				t.Errorf("Range = %q, want %q", got, tt.wantRange)
+			}
+			if resp.ResumedFrom != tt.wantResumed {
+				t.Errorf("ResumedFrom = %d, want %d", resp.ResumedFrom, tt.wantResumed)
+			}
+			want := body
+			if tt.etag == `"v2"` {
+				want = strings.ToUpper(body)
+			}
+			if buf.String() != want {
+				t.Errorf("body = %q, want %q", buf.String(), want)
+			}
+			if resp.StatusCode != http.StatusOK || resp.BytesWritten != int64(len(want)) {
+				t.Errorf("StatusCode = %d, BytesWritten = %d, want 200 and %d", resp.StatusCode, resp.BytesWritten, len(want))
+			}
+		})
+	}
+}
+
+func TestFetcher_Fetch_Metrics(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("complete"))
+	}))
+	defer server.Close()
+
+	sink := metrics.NewPrometheus("")
+	config := DefaultConfig()
+	config.MaxRetries = 1
+	config.InitialDelay = time.Millisecond
+	config.Metrics = sink
+	if _, err := New(config).Fetch(context.Background(), server.URL, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	sink.WriteTo(&buf)
+	host := strings.TrimPrefix(server.URL, "http://")
+	for _, want := range []string{
+		fmt.Sprintf("requests_total{host=%q,status=\"503\"} 1", host),
+		fmt.Sprintf("requests_total{host=%q,status=\"200\"} 1", host),
+		fmt.Sprintf("retries_total{host=%q} 1", host),
+		fmt.Sprintf("bytes_fetched_total{host=%q} 8", host),
+		fmt.Sprintf("request_duration_seconds_count{host=%q} 2", host),
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
 func TestFetcher_Fetch_NoRetryOnClientError(t *testing.T) {
 	var attempts atomic.Int32
 
@@ -158,6 +337,12 @@ func TestFetcher_Fetch_NoRetryOnClientError(t *testing.T) {
 	if err == nil {
 		t.Fatal("expected error for 404, got nil")
 	}
+	if !errors.Is(err, ErrClientStatus) || errors.Is(err, ErrServerStatus) {
+		t.Errorf("expected ErrClientStatus, got %v", err)
+	}
+	if code := StatusCode(err); code != http.StatusNotFound {
+		t.Errorf("StatusCode(err) = %d, want 404", code)
+	}
 
 	// Should only attempt once, no retries on 4xx errors
 	if attempts.Load() != 1 {
@@ -187,6 +372,9 @@ func TestFetcher_Fetch_MaxRetriesExceeded(t *testing.T) {
 	if err == nil {
 		t.Fatal("expected error after max retries, got nil")
 	}
+	if !errors.Is(err, ErrServerStatus) {
+		t.Errorf("expected ErrServerStatus, got %v", err)
+	}
 
 	// Should attempt initial + 2 retries = 3 total
 	expected := int32(3)
@@ -379,6 +567,125 @@ func TestFetcher_TooManyRedirects(t *testing.T) {
 	}
 }
 
+func TestFetcher_RedirectChain(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/b", http.StatusFound)
+	})
+	mux.HandleFunc("/b", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/c.html", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/c.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("done"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := New(DefaultConfig()).Fetch(context.Background(), server.URL+"/a", &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if resp.URL != server.URL+"/a" {
+		t.Errorf("URL = %q, want the requested URL", resp.URL)
+	}
+	if resp.FinalURL != server.URL+"/c.html" {
+		t.Errorf("FinalURL = %q, want %q", resp.FinalURL, server.URL+"/c.html")
+	}
+	want := []string{server.URL + "/a", server.URL + "/b"}
+	if len(resp.Redirects) != len(want) || resp.Redirects[0] != want[0] || resp.Redirects[1] != want[1] {
+		t.Errorf("Redirects = %v, want %v", resp.Redirects, want)
+	}
+}
+
+func TestFetcher_RedirectLoop(t *testing.T) {
+	var requests atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Redirect(w, r, "/b", http.StatusFound)
+	})
+	mux.HandleFunc("/b", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/a", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	_, err := New(DefaultConfig()).Fetch(context.Background(), server.URL+"/a", &bytes.Buffer{})
+	if !errors.Is(err, ErrRedirectLoop) {
+		t.Fatalf("expected ErrRedirectLoop, got %v", err)
+	}
+
+	// Detected on the first revisit, without retries
+	if requests.Load() != 1 {
+		t.Errorf("expected 1 request to /a, got %d", requests.Load())
+	}
+}
+
+func TestFetcher_RedirectToOtherScheme(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "secret.txt")
+	if err := os.WriteFile(secret, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range []string{"file://" + filepath.ToSlash(secret), "ftp://127.0.0.1:1/file.zip"} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, target, http.StatusFound)
+		}))
+
+		config := DefaultConfig()
+		config.LocalFiles = true
+		buf := &bytes.Buffer{}
+		_, err := New(config).Fetch(context.Background(), server.URL, buf)
+		server.Close()
+		if !errors.Is(err, ErrRedirectScheme) {
+			t.Errorf("redirect to %s: expected ErrRedirectScheme, got %v", target, err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("redirect to %s: read %q", target, buf.String())
+		}
+	}
+}
+
+func TestFetcher_LocalFiles(t *testing.T) {
+	page := filepath.Join(t.TempDir(), "Page.html")
+	if err := os.WriteFile(page, []byte("<p>Page</p>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	url := "file://" + filepath.ToSlash(page)
+
+	noLocal := DefaultConfig()
+	noLocal.MaxRetries = 0
+	if _, err := New(noLocal).Fetch(context.Background(), url, &bytes.Buffer{}); err == nil {
+		t.Error("fetched a file:// URL without LocalFiles")
+	}
+
+	config := DefaultConfig()
+	config.LocalFiles = true
+	buf := &bytes.Buffer{}
+	if _, err := New(config).Fetch(context.Background(), url, buf); err != nil {
+		t.Fatalf("Fetch() with LocalFiles error = %v", err)
+	}
+	if buf.String() != "<p>Page</p>" {
+		t.Errorf("body = %q", buf.String())
+	}
+}
+
+func TestFetcher_RedirectCap(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Every redirect goes somewhere new
+		http.Redirect(w, r, r.URL.Path+"x", http.StatusFound)
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.MaxRetries = 0
+
+	_, err := New(config).Fetch(context.Background(), server.URL+"/x", &bytes.Buffer{})
+	if err == nil || errors.Is(err, ErrRedirectLoop) {
+		t.Fatalf("expected redirect cap error, got %v", err)
+	}
+}
+
 func TestFetcher_WriterError(t *testing.T) {
 	expectedBody := []byte("test content")
 
@@ -414,3 +721,125 @@ type errorWriter struct {
 func (w *errorWriter) Write(p []byte) (n int, err error) {
 	return 0, w.err
 }
+
+func TestFetcher_Fetch_Timing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("slow"))
+	}))
+	defer server.Close()
+
+	fetcher := New(DefaultConfig())
+	resp, err := fetcher.Fetch(context.Background(), server.URL, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	timing := resp.Timing
+	if timing.TTFB < 20*time.Millisecond {
+		t.Errorf("expected TTFB of at least 20ms, got %v", timing.TTFB)
+	}
+	if timing.Total < timing.TTFB {
+		t.Errorf("expected total (%v) to be at least TTFB (%v)", timing.Total, timing.TTFB)
+	}
+	if timing.Connect <= 0 {
+		t.Errorf("expected connect time for a new connection, got %v", timing.Connect)
+	}
+	// httptest servers listen on an IP address, so no DNS lookup happens
+	if timing.DNS != 0 {
+		t.Errorf("expected no DNS time for an IP address, got %v", timing.DNS)
+	}
+}
+
+func TestFetcher_Fetch_TimingOnErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	fetcher := New(DefaultConfig())
+	resp, err := fetcher.doFetch(context.Background(), server.URL, &bytes.Buffer{}, nil)
+	if err == nil {
+		t.Fatal("expected error for 404")
+	}
+	if resp == nil || resp.Timing.Total <= 0 {
+		t.Errorf("expected timing on error response, got %+v", resp)
+	}
+}
+
+func TestFetcher_MaxBodySize(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		if r.URL.Path == "/chunked" {
+			// No Content-Length, so the limit is only hit while streaming
+			w.Write([]byte("12345"))
+			w.(http.Flusher).Flush()
+			w.Write([]byte("67890"))
+			return
+		}
+		w.Write([]byte("1234567890"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		path    string
+		max     int64
+		wantErr bool
+	}{
+		{"unlimited", "/", 0, false},
+		{"within limit", "/", 10, false},
+		{"content length over limit", "/", 9, true},
+		{"streamed within limit", "/chunked", 10, false},
+		{"streamed over limit", "/chunked", 9, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts.Store(0)
+			config := DefaultConfig()
+			config.MaxBodySize = tt.max
+			config.InitialDelay = time.Millisecond
+
+			_, err := New(config).Fetch(context.Background(), server.URL+tt.path, &bytes.Buffer{})
+			if tt.wantErr {
+				if !errors.Is(err, ErrTooLarge) {
+					t.Fatalf("expected ErrTooLarge, got %v", err)
+				}
+				if attempts.Load() != 1 {
+					t.Errorf("expected 1 attempt (no retries when too large), got %d", attempts.Load())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestStatusError_Is(t *testing.T) {
+	tests := []struct {
+		code       int
+		wantClient bool
+		wantServer bool
+	}{
+		{http.StatusNotModified, false, false},
+		{http.StatusNotFound, true, false},
+		{http.StatusGone, true, false},
+		{http.StatusInternalServerError, false, true},
+		{http.StatusServiceUnavailable, false, true},
+	}
+
+	for _, tt := range tests {
+		err := error(&StatusError{StatusCode: tt.code})
+		if got := errors.Is(err, ErrClientStatus); got != tt.wantClient {
+			t.Errorf("errors.Is(%d, ErrClientStatus) = %v, want %v", tt.code, got, tt.wantClient)
+		}
+		if got := errors.Is(err, ErrServerStatus); got != tt.wantServer {
+			t.Errorf("errors.Is(%d, ErrServerStatus) = %v, want %v", tt.code, got, tt.wantServer)
+		}
+	}
+}
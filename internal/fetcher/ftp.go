@@ -0,0 +1,237 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/textproto"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ftpReplyTimeout bounds the wait for the reply ending a transfer, which a
+// server only sends a body closed early once it notices
+const ftpReplyTimeout = 5 * time.Second
+
+// ftpTransport fetches ftp:// URLs by anonymous passive-mode FTP, or with
+// the URL's credentials. Downloads a file with RETR, answering files the
+// server refuses to send, as it does missing ones, with 404 Not Found
+type ftpTransport struct {
+	dialer net.Dialer
+}
+
+func (t *ftpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return nil, fmt.Errorf("ftp: unsupported method %s", req.Method)
+	}
+	ctx := req.Context()
+
+	// The path and credentials are sent as command arguments, so a line
+	// break in them, such as a crawled link's %0D%0A, would send commands
+	// of its own
+	user, pass := "anonymous", "anonymous@"
+	if req.URL.User != nil {
+		user = req.URL.User.Username()
+		pass, _ = req.URL.User.Password()
+	}
+	for _, arg := range []string{req.URL.Path, user, pass} {
+		if strings.ContainsAny(arg, "\r\n\x00") {
+			return nil, fmt.Errorf("ftp: %w: %q", ErrFTPArgument, arg)
+		}
+	}
+
+	addr := req.URL.Host
+	if req.URL.Port() == "" {
+		addr = net.JoinHostPort(req.URL.Hostname(), "21")
+	}
+	conn, err := t.dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("ftp: %w", err)
+	}
+	c := &ftpConn{conn: conn, text: textproto.NewConn(conn)}
+	c.stop = context.AfterFunc(ctx, func() { c.closeAll() })
+
+	resp, err := c.retrieve(ctx, req, &t.dialer, user, pass)
+	if err != nil || resp.Body == http.NoBody {
+		c.quit()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ftp: %w", err)
+	}
+	return resp, nil
+}
+
+// ftpConn is the control connection of an FTP session, and its data
+// connection while a file is transferred
+type ftpConn struct {
+	conn net.Conn
+	text *textproto.Conn
+	data net.Conn
+	stop func() bool
+}
+
+// retrieve logs in as user and starts downloading the file of req.URL
+func (c *ftpConn) retrieve(ctx context.Context, req *http.Request, dialer *net.Dialer, user, pass string) (*http.Response, error) {
+	if _, _, err := c.text.ReadResponse(220); err != nil {
+		return nil, err
+	}
+
+	code, msg, err := c.cmd("USER %s", user)
+	if err == nil && code == 331 {
+		code, msg, err = c.cmd("PASS %s", pass)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if code != 230 && code != 202 {
+		return ftpStatus(req, http.StatusForbidden), nil
+	}
+	if code, msg, err = c.cmd("TYPE I"); err != nil {
+		return nil, err
+	} else if code != 200 {
+		return nil, fmt.Errorf("TYPE I: %d %s", code, msg)
+	}
+
+	file := req.URL.Path
+	size := int64(-1)
+	if code, msg, err := c.cmd("SIZE %s", file); err == nil && code == 213 {
+		if n, err := strconv.ParseInt(strings.TrimSpace(msg), 10, 64); err == nil {
+			size = n
+		}
+	}
+
+	port, err := c.passive()
+	if err != nil {
+		return nil, err
+	}
+	host, _, _ := net.SplitHostPort(c.conn.RemoteAddr().String())
+	if c.data, err = dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port)); err != nil {
+		return nil, err
+	}
+
+	code, msg, err = c.cmd("RETR %s", file)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case code == 125 || code == 150:
+	case code >= 500:
+		return ftpStatus(req, http.StatusNotFound), nil
+	default:
+		return nil, fmt.Errorf("RETR: %d %s", code, msg)
+	}
+
+	header := make(http.Header)
+	if contentType := mime.TypeByExtension(path.Ext(file)); contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	if size >= 0 {
+		header.Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.0",
+		ProtoMajor:    1,
+		Header:        header,
+		Body:          &ftpBody{c: c},
+		ContentLength: size,
+		Request:       req,
+	}, nil
+}
+
+// passive asks the server for a data port, by EPSV or else PASV. The data
+// connection goes to the control connection's address whatever PASV
+// claims, which old servers behind NAT get wrong
+func (c *ftpConn) passive() (string, error) {
+	if code, msg, err := c.cmd("EPSV"); err != nil {
+		return "", err
+	} else if code == 229 {
+		start, end := strings.Index(msg, "(|||"), strings.LastIndex(msg, "|)")
+		if start >= 0 && end > start+4 {
+			return msg[start+4 : end], nil
+		}
+	}
+
+	code, msg, err := c.cmd("PASV")
+	if err != nil {
+		return "", err
+	}
+	start, end := strings.Index(msg, "("), strings.LastIndex(msg, ")")
+	if code != 227 || start < 0 || end < start {
+		return "", fmt.Errorf("PASV: %d %s", code, msg)
+	}
+	fields := strings.Split(msg[start+1:end], ",")
+	if len(fields) != 6 {
+		return "", fmt.Errorf("PASV: %d %s", code, msg)
+	}
+	hi, err1 := strconv.Atoi(strings.TrimSpace(fields[4]))
+	lo, err2 := strconv.Atoi(strings.TrimSpace(fields[5]))
+	if err1 != nil || err2 != nil {
+		return "", fmt.Errorf("PASV: %d %s", code, msg)
+	}
+	return strconv.Itoa(hi<<8 | lo), nil
+}
+
+// cmd sends a command and reads the reply, whatever its code
+func (c *ftpConn) cmd(format string, args ...any) (int, string, error) {
+	if _, err := c.text.Cmd(format, args...); err != nil {
+		return 0, "", err
+	}
+	code, msg, err := c.text.ReadResponse(0)
+	if _, ok := err.(*textproto.Error); ok {
+		err = nil
+	}
+	return code, msg, err
+}
+
+// quit ends the session, closing its connections
+func (c *ftpConn) quit() {
+	c.text.Cmd("QUIT")
+	c.closeAll()
+}
+
+// closeAll closes the connections of the session
+func (c *ftpConn) closeAll() {
+	c.stop()
+	if c.data != nil {
+		c.data.Close()
+	}
+	c.conn.Close()
+}
+
+// ftpStatus returns an empty response with the given status for req
+func ftpStatus(req *http.Request, status int) *http.Response {
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode: status,
+		Proto:      "HTTP/1.0",
+		ProtoMajor: 1,
+		Header:     make(http.Header),
+		Body:       http.NoBody,
+		Request:    req,
+	}
+}
+
+// ftpBody streams a file from the data connection, ending the session
+// when closed
+type ftpBody struct {
+	c *ftpConn
+}
+
+func (b *ftpBody) Read(p []byte) (int, error) {
+	return b.c.data.Read(p)
+}
+
+func (b *ftpBody) Close() error {
+	b.c.data.Close()
+	// The transfer's final reply, which is of no use once the data is read
+	b.c.conn.SetReadDeadline(time.Now().Add(ftpReplyTimeout))
+	b.c.text.ReadResponse(0)
+	b.c.quit()
+	return nil
+}
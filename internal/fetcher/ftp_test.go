@@ -0,0 +1,147 @@
+package fetcher
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+// ftpServer serves files over passive-mode FTP to anonymous users,
+// returning the server's base URL
+func ftpServer(t *testing.T, files map[string]string) string {
+	t.Helper()
+	return ftpServerLog(t, files, nil)
+}
+
+// ftpServerLog is ftpServer sending every command received to cmds
+func ftpServerLog(t *testing.T, files map[string]string, cmds chan<- string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveFTP(conn, files, cmds)
+		}
+	}()
+	return "ftp://" + ln.Addr().String()
+}
+
+func serveFTP(conn net.Conn, files map[string]string, cmds chan<- string) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(format string, args ...any) { fmt.Fprintf(conn, format+"\r\n", args...) }
+
+	var data net.Listener
+	reply("220 Ready")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+		if cmds != nil {
+			cmds <- cmd
+		}
+		switch cmd {
+		case "USER":
+			reply("331 Password required")
+		case "PASS":
+			reply("230 Logged in")
+		case "TYPE":
+			reply("200 Binary")
+		case "SIZE":
+			if body, ok := files[arg]; ok {
+				reply("213 %d", len(body))
+			} else {
+				reply("550 No such file")
+			}
+		case "EPSV":
+			if data, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+				return
+			}
+			reply("229 Entering extended passive mode (|||%d|)", data.Addr().(*net.TCPAddr).Port)
+		case "RETR":
+			dc, err := data.Accept()
+			data.Close()
+			if err != nil {
+				return
+			}
+			body, ok := files[arg]
+			if !ok {
+				dc.Close()
+				reply("550 No such file")
+				continue
+			}
+			reply("150 Opening data connection")
+			dc.Write([]byte(body))
+			dc.Close()
+			reply("226 Transfer complete")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Not implemented")
+		}
+	}
+}
+
+func TestFetcher_FTP(t *testing.T) {
+	base := ftpServer(t, map[string]string{"/pub/Manual.pdf": "%PDF doc"})
+	config := DefaultConfig()
+	config.MaxRetries = 0
+	f := New(config)
+
+	var body bytes.Buffer
+	resp, err := f.Fetch(context.Background(), base+"/pub/Manual.pdf", &body)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if body.String() != "%PDF doc" || resp.BytesWritten != 8 {
+		t.Errorf("body = %q (%d bytes), want %q", body.String(), resp.BytesWritten, "%PDF doc")
+	}
+	if resp.ContentType != "application/pdf" {
+		t.Errorf("ContentType = %q, want application/pdf", resp.ContentType)
+	}
+
+	_, err = f.Fetch(context.Background(), base+"/pub/Missing.pdf", &body)
+	if StatusCode(err) != 404 {
+		t.Errorf("Fetch() of missing file error = %v, want HTTP 404", err)
+	}
+}
+
+func TestFetcher_FTPControlChars(t *testing.T) {
+	cmds := make(chan string, 100)
+	base := ftpServerLog(t, map[string]string{"/a": "a"}, cmds)
+	config := DefaultConfig()
+	config.MaxRetries = 0
+	f := New(config)
+
+	for _, u := range []string{
+		base + "/a%0D%0ADELE%20/a",
+		base + "/a%0ADELE%20/a",
+		base + "/a%00",
+		strings.Replace(base, "ftp://", "ftp://anonymous:x%0D%0ADELE%20%2Fa@", 1) + "/a",
+	} {
+		_, err := f.Fetch(context.Background(), u, &bytes.Buffer{})
+		if !errors.Is(err, ErrFTPArgument) {
+			t.Errorf("Fetch(%q) error = %v, want ErrFTPArgument", u, err)
+		}
+	}
+	close(cmds)
+	for cmd := range cmds {
+		t.Errorf("server received %s, want no connection", cmd)
+	}
+}
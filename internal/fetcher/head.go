@@ -0,0 +1,62 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+
+	"github.com/aldehir/ue2-docs/internal/urlutil"
+)
+
+// Head retrieves the status and headers of a resource without its body,
+// rate limited and retried like Fetch. Servers refusing HEAD requests are
+// sent a GET instead, its body counted into BytesWritten and discarded
+func (f *Fetcher) Head(ctx context.Context, url string) (*Response, error) {
+	resp, err := f.retry(ctx, url, io.Discard, f.doHead)
+	if code := StatusCode(err); code == http.StatusMethodNotAllowed || code == http.StatusNotImplemented {
+		return f.Fetch(ctx, url, io.Discard)
+	}
+	return resp, err
+}
+
+// doHead performs a single HEAD request
+func (f *Fetcher) doHead(ctx context.Context, url string, _ io.Writer, _ *partial) (*Response, error) {
+	recorder := newTimingRecorder()
+	ctx = httptrace.WithClientTrace(ctx, recorder.trace())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", f.agents.pick(req.URL.Host))
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("executing request: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &Response{
+			URL:        url,
+			StatusCode: resp.StatusCode,
+			Headers:    resp.Header,
+			Timing:     recorder.finish(),
+		}, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	finalURL := resp.Request.URL.String()
+	contentType := resp.Header.Get("Content-Type")
+	return &Response{
+		URL:          url,
+		FinalURL:     finalURL,
+		Redirects:    redirectChain(resp),
+		StatusCode:   resp.StatusCode,
+		ContentType:  contentType,
+		ResourceType: urlutil.DetectResourceType(finalURL, contentType),
+		Headers:      resp.Header,
+		Timing:       recorder.finish(),
+	}, nil
+}
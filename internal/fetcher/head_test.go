@@ -0,0 +1,68 @@
+package fetcher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetcher_Head(t *testing.T) {
+	var gets int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			gets++
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Content-Length", "1234")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	resp, err := New(DefaultConfig()).Head(context.Background(), server.URL+"/logo.png")
+	if err != nil {
+		t.Fatalf("Head: %v", err)
+	}
+	if gets != 0 {
+		t.Errorf("sent %d GET requests, want none", gets)
+	}
+	if resp.StatusCode != http.StatusOK || resp.ContentType != "image/png" {
+		t.Errorf("got %d %q, want 200 image/png", resp.StatusCode, resp.ContentType)
+	}
+	if got := resp.Headers.Get("Content-Length"); got != "1234" {
+		t.Errorf("Content-Length = %q, want 1234", got)
+	}
+}
+
+func TestFetcher_Head_FallsBackToGet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+
+	resp, err := New(DefaultConfig()).Head(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Head: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if resp.BytesWritten != int64(len("<html></html>")) {
+		t.Errorf("BytesWritten = %d, want %d", resp.BytesWritten, len("<html></html>"))
+	}
+}
+
+func TestFetcher_Head_Status(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	_, err := New(DefaultConfig()).Head(context.Background(), server.URL)
+	if StatusCode(err) != http.StatusNotFound {
+		t.Fatalf("err = %v, want HTTP 404", err)
+	}
+}
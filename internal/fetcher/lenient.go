@@ -0,0 +1,224 @@
+package fetcher
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// maxLenientHeaderBytes caps the status line and headers read from a
+// lenient response, as no header can be trusted to end them
+const maxLenientHeaderBytes = 1 << 20
+
+// lenientTransport retries requests whose responses the base transport
+// rejects as malformed over a raw connection, parsing whatever comes back
+// as best it can: a reply without a status line is taken as an HTTP/0.9
+// body, header lines without a colon are skipped, and an unparsable status
+// code is taken as 200
+type lenientTransport struct {
+	base   http.RoundTripper
+	dialer net.Dialer
+}
+
+func (t *lenientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err == nil || !malformedResponse(err) || req.Context().Err() != nil {
+		return resp, err
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return resp, err
+	}
+
+	if logger := loggerFrom(req.Context()); logger != nil {
+		logger.Warn("malformed response, retrying leniently", "error", err)
+	}
+	return t.rawRoundTrip(req)
+}
+
+// malformedResponse reports whether err is net/http rejecting a response
+// it could not parse, rather than a network failure
+func malformedResponse(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "malformed") || strings.Contains(msg, "invalid header") ||
+		strings.Contains(msg, "missing status")
+}
+
+// rawRoundTrip sends req as HTTP/1.0 over a connection of its own, closed
+// along with the response body, and reads the response leniently
+func (t *lenientTransport) rawRoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	addr := req.URL.Host
+	if req.URL.Port() == "" {
+		port := "80"
+		if req.URL.Scheme == "https" {
+			port = "443"
+		}
+		addr = net.JoinHostPort(req.URL.Hostname(), port)
+	}
+
+	conn, err := t.dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("lenient fetch: %w", err)
+	}
+	if req.URL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: req.URL.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("lenient fetch: %w", err)
+		}
+		conn = tlsConn
+	}
+	// Unblock reads should the request be cancelled
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+
+	if err := writeRawRequest(conn, req); err != nil {
+		stop()
+		conn.Close()
+		return nil, fmt.Errorf("lenient fetch: %w", err)
+	}
+
+	resp, err := readLenientResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		stop()
+		conn.Close()
+		return nil, fmt.Errorf("lenient fetch: %w", err)
+	}
+	resp.Body = &rawBody{Reader: resp.Body, conn: conn, stop: stop}
+	return resp, nil
+}
+
+// writeRawRequest writes req as an HTTP/1.0 request asking to close the
+// connection after the response, which spares ancient servers chunking
+// and keep-alive
+func writeRawRequest(w io.Writer, req *http.Request) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s %s HTTP/1.0\r\nHost: %s\r\n", req.Method, req.URL.RequestURI(), req.URL.Host)
+	for key, values := range req.Header {
+		for _, v := range values {
+			fmt.Fprintf(bw, "%s: %s\r\n", key, v)
+		}
+	}
+	bw.WriteString("Connection: close\r\n\r\n")
+	return bw.Flush()
+}
+
+// readLenientResponse reads a response from r, which is its body if it
+// does not start with a status line
+func readLenientResponse(r *bufio.Reader, req *http.Request) (*http.Response, error) {
+	resp := &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/0.9",
+		ProtoMinor:    9,
+		Header:        make(http.Header),
+		Request:       req,
+		ContentLength: -1,
+		Close:         true,
+	}
+
+	prefix, err := r.Peek(len("HTTP/"))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if !strings.EqualFold(string(prefix), "HTTP/") {
+		resp.Body = io.NopCloser(r)
+		return resp, nil
+	}
+
+	read := 0
+	line, err := readLenientLine(r, &read)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(line)
+	resp.Proto = fields[0]
+	if major, minor, ok := http.ParseHTTPVersion(strings.ToUpper(fields[0])); ok {
+		resp.ProtoMajor, resp.ProtoMinor = major, minor
+	} else {
+		resp.ProtoMajor, resp.ProtoMinor = 1, 0
+	}
+	if len(fields) > 1 {
+		if code, err := strconv.Atoi(fields[1]); err == nil && code >= 100 && code <= 999 {
+			resp.StatusCode = code
+			resp.Status = strings.TrimSpace(strings.TrimPrefix(line, fields[0]))
+		}
+	}
+
+	for {
+		line, err := readLenientLine(r, &read)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		key, value, ok := strings.Cut(line, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			continue
+		}
+		resp.Header.Add(textproto.CanonicalMIMEHeaderKey(key), strings.TrimSpace(value))
+	}
+
+	var body io.Reader = r
+	switch {
+	case strings.Contains(strings.ToLower(resp.Header.Get("Transfer-Encoding")), "chunked"):
+		body = httputil.NewChunkedReader(r)
+		resp.Header.Del("Transfer-Encoding")
+	default:
+		if n, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64); err == nil && n >= 0 {
+			body = io.LimitReader(r, n)
+			resp.ContentLength = n
+		}
+	}
+	resp.Body = io.NopCloser(body)
+	return resp, nil
+}
+
+// readLenientLine reads a line ending in LF, with or without CR, counting
+// its length toward maxLenientHeaderBytes in read
+func readLenientLine(r *bufio.Reader, read *int) (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		*read += len(chunk)
+		if *read > maxLenientHeaderBytes {
+			return "", fmt.Errorf("response headers exceed %d bytes", maxLenientHeaderBytes)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF && len(line) > 0 {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		break
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+// rawBody is the body of a lenient response, closing its connection
+type rawBody struct {
+	io.Reader
+	conn net.Conn
+	stop func() bool
+}
+
+func (b *rawBody) Close() error {
+	b.stop()
+	return b.conn.Close()
+}
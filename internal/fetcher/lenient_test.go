@@ -0,0 +1,88 @@
+package fetcher
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"testing"
+)
+
+// rawServer answers every connection with reply, after reading the
+// request's headers, returning the server's base URL
+func rawServer(t *testing.T, reply string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == "\r\n" {
+						break
+					}
+				}
+				conn.Write([]byte(reply))
+			}()
+		}
+	}()
+	return "http://" + ln.Addr().String()
+}
+
+func TestFetcher_Lenient(t *testing.T) {
+	tests := []struct {
+		name       string
+		reply      string
+		wantStatus int
+		wantType   string
+		wantBody   string
+	}{
+		{"http/0.9", "<html><body>Hello</body></html>", 200, "", "<html><body>Hello</body></html>"},
+		{"header without colon", "HTTP/1.0 200 OK\r\nContent-Type: text/html\r\nBroken header line\r\n\r\n<p>Hi</p>", 200, "text/html", "<p>Hi</p>"},
+		{"bad status code", "HTTP/1.0 OK\r\nContent-Type: text/plain\r\nContent-Length: 2\r\n\r\nHi and more", 200, "text/plain", "Hi"},
+		{"not found", "HTTP/1.0 404 Not Found\r\nX Bad: yes\r\n\r\ngone", 404, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url := rawServer(t, tt.reply) + "/Two/Page.html"
+			config := DefaultConfig()
+			config.MaxRetries = 0
+
+			if _, err := New(config).Fetch(context.Background(), url, &bytes.Buffer{}); err == nil {
+				t.Fatal("strict Fetch() succeeded, want malformed response error")
+			}
+
+			config.Lenient = true
+			var body bytes.Buffer
+			resp, err := New(config).Fetch(context.Background(), url, &body)
+			if tt.wantStatus != http.StatusOK {
+				if StatusCode(err) != tt.wantStatus {
+					t.Fatalf("Fetch() error = %v, want HTTP %d", err, tt.wantStatus)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Fetch() error = %v", err)
+			}
+			if resp.ContentType != tt.wantType {
+				t.Errorf("ContentType = %q, want %q", resp.ContentType, tt.wantType)
+			}
+			if body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", body.String(), tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package fetcher
+
+import (
+	"context"
+	"log/slog"
+)
+
+// loggerKey is the context key of the logger set by WithLogger
+type loggerKey struct{}
+
+// WithLogger returns a context that makes fetches with it log each failed
+// attempt that is retried to logger, typically one carrying fields that
+// identify the caller's worker and request
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// loggerFrom returns the logger set by WithLogger, or nil
+func loggerFrom(ctx context.Context) *slog.Logger {
+	logger, _ := ctx.Value(loggerKey{}).(*slog.Logger)
+	return logger
+}
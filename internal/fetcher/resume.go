@@ -0,0 +1,101 @@
+package fetcher
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// errBadContentRange is returned when a server answers a Range request
+// with a different part of the body than was asked for
+var errBadContentRange = errors.New("unexpected Content-Range")
+
+// partial is a body cut off partway, to be resumed with a Range request
+type partial struct {
+	offset    int64  // Bytes of the body already written
+	validator string // ETag or Last-Modified of the body, sent as If-Range
+}
+
+// streamError is a failure partway through streaming a body
+type streamError struct {
+	err  error
+	read bool // Reading the body failed, rather than writing it
+}
+
+func (e *streamError) Error() string {
+	return "streaming response body: " + e.err.Error()
+}
+
+func (e *streamError) Unwrap() error {
+	return e.err
+}
+
+// resumable returns the partial body left by a failed attempt, given the
+// one it resumed, or nil if the next attempt must start over. A body is
+// only resumed when it was cut off while reading, was sent without a
+// Content-Encoding, so the bytes written are the bytes sent, and carries a
+// validator to tell that the rest is of the same version of the resource
+func resumable(prev *partial, resp *Response, err error) *partial {
+	var streamErr *streamError
+	if !errors.As(err, &streamErr) {
+		if errors.Is(err, errBadContentRange) {
+			return nil
+		}
+		// Nothing was written, so the partial body is as it was
+		return prev
+	}
+	if !streamErr.read || resp.BytesWritten == 0 || resp.EncodingAnomaly != "" ||
+		!identityEncoded(resp.Headers) || resp.Headers.Get("Accept-Ranges") == "none" {
+		return nil
+	}
+	validator := validatorOf(resp.Headers)
+	if validator == "" {
+		return nil
+	}
+	return &partial{offset: resp.BytesWritten, validator: validator}
+}
+
+// validatorOf returns the validator to send as If-Range for the rest of a
+// body: its ETag if strong, since weak ETags are not allowed there, or
+// else its Last-Modified date
+func validatorOf(headers http.Header) string {
+	if etag := headers.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return headers.Get("Last-Modified")
+}
+
+// identityEncoded reports whether a body was sent without a Content-Encoding
+func identityEncoded(headers http.Header) bool {
+	encoding := strings.ToLower(strings.TrimSpace(headers.Get("Content-Encoding")))
+	return encoding == "" || encoding == "identity"
+}
+
+// checkContentRange checks that a 206 response continues a body from offset
+func checkContentRange(resp *http.Response, offset int64) error {
+	var start int64
+	if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-", &start); err != nil || start != offset {
+		return fmt.Errorf("%w: asked for bytes from %d, got %q", errBadContentRange, offset, resp.Header.Get("Content-Range"))
+	}
+	if !identityEncoded(resp.Header) {
+		return fmt.Errorf("%w: range sent with Content-Encoding %q", errBadContentRange, resp.Header.Get("Content-Encoding"))
+	}
+	return nil
+}
+
+// bodyReader records the error reading a body fails with, telling it
+// apart from errors writing it out
+type bodyReader struct {
+	r   io.Reader
+	err error
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
@@ -0,0 +1,46 @@
+package fetcher
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestResumable(t *testing.T) {
+	prev := &partial{offset: 10, validator: `"v1"`}
+	readErr := &streamError{err: errors.New("connection reset"), read: true}
+	headers := func(kv ...string) http.Header {
+		h := http.Header{}
+		for i := 0; i < len(kv); i += 2 {
+			h.Set(kv[i], kv[i+1])
+		}
+		return h
+	}
+
+	tests := []struct {
+		name string
+		resp *Response
+		err  error
+		want *partial
+	}{
+		{"cut off", &Response{BytesWritten: 30, Headers: headers("ETag", `"v1"`)}, readErr, &partial{30, `"v1"`}},
+		{"last modified", &Response{BytesWritten: 30, Headers: headers("Last-Modified", "Mon, 01 Mar 2004 12:00:00 GMT")}, readErr, &partial{30, "Mon, 01 Mar 2004 12:00:00 GMT"}},
+		{"weak etag", &Response{BytesWritten: 30, Headers: headers("ETag", `W/"v1"`)}, readErr, nil},
+		{"encoded", &Response{BytesWritten: 30, Headers: headers("ETag", `"v1"`, "Content-Encoding", "gzip")}, readErr, nil},
+		{"anomaly", &Response{BytesWritten: 30, Headers: headers("ETag", `"v1"`), EncodingAnomaly: "undeclared gzip"}, readErr, nil},
+		{"no ranges", &Response{BytesWritten: 30, Headers: headers("ETag", `"v1"`, "Accept-Ranges", "none")}, readErr, nil},
+		{"nothing written", &Response{Headers: headers("ETag", `"v1"`)}, readErr, nil},
+		{"write failed", &Response{BytesWritten: 30, Headers: headers("ETag", `"v1"`)}, &streamError{err: errors.New("disk full")}, nil},
+		{"server error", &Response{StatusCode: 503}, &StatusError{StatusCode: 503}, prev},
+		{"bad range", &Response{StatusCode: 206}, errBadContentRange, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resumable(prev, tt.resp, tt.err)
+			if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+				t.Errorf("resumable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
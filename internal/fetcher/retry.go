@@ -0,0 +1,92 @@
+package fetcher
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/url"
+)
+
+// RetryPolicy decides whether a failed fetch attempt is worth repeating
+type RetryPolicy interface {
+	Retry(err error) bool
+}
+
+// RetryPolicyFunc adapts a function to a RetryPolicy
+type RetryPolicyFunc func(err error) bool
+
+// Retry calls f(err)
+func (f RetryPolicyFunc) Retry(err error) bool {
+	return f(err)
+}
+
+// DefaultRetryPolicy retries server errors (5xx), timeouts, connection
+// resets and other transient network failures. Failures that will never
+// succeed are not retried: client errors (4xx), redirect loops, redirects
+// to other schemes, oversized or undecodable bodies, malformed URLs,
+// unsafe FTP paths, TLS certificate errors and hosts that do not exist
+var DefaultRetryPolicy RetryPolicy = RetryPolicyFunc(retryTransient)
+
+func retryTransient(err error) bool {
+	switch {
+	case errors.Is(err, ErrServerStatus):
+		return true
+	case errors.Is(err, ErrClientStatus),
+		errors.Is(err, ErrRedirectLoop),
+		errors.Is(err, ErrRedirectScheme),
+		errors.Is(err, ErrTooLarge),
+		errors.Is(err, ErrBadEncoding),
+		errors.Is(err, ErrFTPArgument):
+		return false
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && urlErr.Op == "parse" {
+		return false
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return false
+	}
+	if isCertificateError(err) {
+		return false
+	}
+
+	// Timeouts, resets, refused connections and truncated bodies may all
+	// go away on their own
+	return true
+}
+
+// isCertificateError reports whether err is a TLS certificate verification
+// failure
+func isCertificateError(err error) bool {
+	var verifyErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	return errors.As(err, &verifyErr) ||
+		errors.As(err, &unknownAuthority) ||
+		errors.As(err, &hostname) ||
+		errors.As(err, &invalid)
+}
+
+// maxRetriesKey is the context key of the retry limit set by WithMaxRetries
+type maxRetriesKey struct{}
+
+// WithMaxRetries returns a context that makes fetches with it retry at most
+// n times, overriding Config.MaxRetries, e.g. to give up on images sooner
+// than on pages
+func WithMaxRetries(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, maxRetriesKey{}, n)
+}
+
+// maxRetriesFrom returns the retry limit set by WithMaxRetries, or def
+func maxRetriesFrom(ctx context.Context, def int) int {
+	if n, ok := ctx.Value(maxRetriesKey{}).(int); ok {
+		return max(n, 0)
+	}
+	return def
+}
@@ -0,0 +1,123 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDefaultRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"server error", &StatusError{StatusCode: 503}, true},
+		{"client error", &StatusError{StatusCode: 404}, false},
+		{"redirect loop", &url.Error{Op: "Get", URL: "http://a/", Err: ErrRedirectLoop}, false},
+		{"too large", fmt.Errorf("streaming response body: %w", ErrTooLarge), false},
+		{"timeout", &url.Error{Op: "Get", URL: "http://a/", Err: &net.OpError{Op: "dial", Err: &net.DNSError{IsTimeout: true}}}, true},
+		{"connection reset", &url.Error{Op: "Get", URL: "http://a/", Err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}}, true},
+		{"dns temporary", &url.Error{Op: "Get", URL: "http://a/", Err: &net.OpError{Op: "dial", Err: &net.DNSError{IsTemporary: true}}}, true},
+		{"dns nxdomain", &url.Error{Op: "Get", URL: "http://a/", Err: &net.OpError{Op: "dial", Err: &net.DNSError{IsNotFound: true}}}, false},
+		{"malformed url", fmt.Errorf("creating request: %w", &url.Error{Op: "parse", URL: "://x", Err: errors.New("missing protocol scheme")}), false},
+		{"unknown authority", &url.Error{Op: "Get", URL: "https://a/", Err: x509.UnknownAuthorityError{}}, false},
+		{"hostname mismatch", &url.Error{Op: "Get", URL: "https://a/", Err: x509.HostnameError{Host: "a"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DefaultRetryPolicy.Retry(tt.err); got != tt.want {
+				t.Errorf("Retry(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetcher_NoRetryOnCertificateError(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.MaxRetries = 3
+	config.InitialDelay = time.Millisecond
+
+	// The test server's self-signed certificate is not trusted
+	_, err := New(config).Fetch(context.Background(), server.URL, &bytes.Buffer{})
+	if err == nil || !isCertificateError(err) {
+		t.Fatalf("expected certificate error, got %v", err)
+	}
+	if attempts.Load() != 0 {
+		t.Errorf("handler reached %d times, want 0", attempts.Load())
+	}
+}
+
+func TestFetcher_CustomRetryPolicy(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.MaxRetries = 2
+	config.InitialDelay = time.Millisecond
+	// Some servers answer 404 while a page is being regenerated
+	config.RetryPolicy = RetryPolicyFunc(func(err error) bool {
+		return errors.Is(err, ErrClientStatus)
+	})
+
+	_, err := New(config).Fetch(context.Background(), server.URL, &bytes.Buffer{})
+	if !errors.Is(err, ErrClientStatus) {
+		t.Fatalf("expected ErrClientStatus, got %v", err)
+	}
+	if attempts.Load() != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts.Load())
+	}
+}
+
+func TestWithMaxRetries(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.MaxRetries = 3
+	config.InitialDelay = time.Millisecond
+	f := New(config)
+
+	tests := []struct {
+		ctx  context.Context
+		want int32
+	}{
+		{context.Background(), 4},
+		{WithMaxRetries(context.Background(), 0), 1},
+		{WithMaxRetries(context.Background(), 1), 2},
+	}
+	for _, tt := range tests {
+		attempts.Store(0)
+		if _, err := f.Fetch(tt.ctx, server.URL, &bytes.Buffer{}); err == nil {
+			t.Fatal("Fetch() succeeded, want failure")
+		}
+		if got := attempts.Load(); got != tt.want {
+			t.Errorf("%d attempts, want %d", got, tt.want)
+		}
+	}
+}
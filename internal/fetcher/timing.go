@@ -0,0 +1,89 @@
+package fetcher
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timing breaks down where the time for a single fetch was spent. Phases
+// that did not happen (e.g. DNS and connect on a reused connection) are zero.
+// When redirects are followed, phase durations are summed across hops
+type Timing struct {
+	DNS     time.Duration // Resolving the host name
+	Connect time.Duration // Establishing the TCP connection
+	TLS     time.Duration // TLS handshake
+	TTFB    time.Duration // Time from sending the request to the first response byte
+	Total   time.Duration // Time from sending the request until the body was fully read
+}
+
+// timingRecorder collects Timing from httptrace callbacks, which may be
+// invoked from other goroutines (e.g. parallel dials)
+type timingRecorder struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	connectStart map[string]time.Time
+	tlsStart     time.Time
+	timing       Timing
+}
+
+func newTimingRecorder() *timingRecorder {
+	return &timingRecorder{
+		start:        time.Now(),
+		connectStart: make(map[string]time.Time),
+	}
+}
+
+// trace returns a ClientTrace that feeds the recorder
+func (tr *timingRecorder) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			tr.mu.Lock()
+			tr.dnsStart = time.Now()
+			tr.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			tr.mu.Lock()
+			tr.timing.DNS += time.Since(tr.dnsStart)
+			tr.mu.Unlock()
+		},
+		ConnectStart: func(network, addr string) {
+			tr.mu.Lock()
+			tr.connectStart[network+addr] = time.Now()
+			tr.mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			tr.mu.Lock()
+			// Only count the connection that succeeded when dialing in parallel
+			if start, ok := tr.connectStart[network+addr]; ok && err == nil {
+				tr.timing.Connect += time.Since(start)
+			}
+			tr.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			tr.mu.Lock()
+			tr.tlsStart = time.Now()
+			tr.mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			tr.mu.Lock()
+			tr.timing.TLS += time.Since(tr.tlsStart)
+			tr.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			tr.mu.Lock()
+			tr.timing.TTFB = time.Since(tr.start)
+			tr.mu.Unlock()
+		},
+	}
+}
+
+// finish records the total duration and returns the collected timing
+func (tr *timingRecorder) finish() Timing {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.timing.Total = time.Since(tr.start)
+	return tr.timing
+}
@@ -0,0 +1,93 @@
+package fetcher
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	neturl "net/url"
+	"strings"
+)
+
+// fetchUpgraded fetches an http(s) URL over HTTPS, falling back to plain
+// HTTP if its host cannot be reached over HTTPS. The Response keeps the
+// URL asked for, with FinalURL telling the scheme it was fetched by
+func (f *Fetcher) fetchUpgraded(ctx context.Context, rawURL string, w io.Writer) (*Response, error) {
+	u, err := neturl.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return f.fetch(ctx, rawURL, w)
+	}
+	host := strings.ToLower(u.Host)
+	secure, plain := *u, *u
+	secure.Scheme, plain.Scheme = "https", "http"
+
+	known, probed := f.secureHosts.Load(host)
+	if probed && !known.(bool) {
+		resp, err := f.fetch(ctx, plain.String(), w)
+		return askedFor(resp, rawURL), err
+	}
+	if probed {
+		resp, err := f.fetch(ctx, secure.String(), w)
+		return askedFor(resp, rawURL), err
+	}
+
+	// Probe without retries, so hosts without HTTPS fall back at once
+	resp, err := f.fetch(WithMaxRetries(ctx, 0), secure.String(), w)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err == nil || !unreachable(err) {
+		f.secureHosts.Store(host, true)
+		if err != nil && f.retryPolicy().Retry(err) {
+			reset(w)
+			resp, err = f.fetch(ctx, secure.String(), w)
+		}
+		return askedFor(resp, rawURL), err
+	}
+
+	f.secureHosts.Store(host, false)
+	if logger := loggerFrom(ctx); logger != nil {
+		logger.Info("host unreachable over HTTPS, falling back to HTTP", "host", host, "error", err)
+	}
+	reset(w)
+	resp, err = f.fetch(ctx, plain.String(), w)
+	return askedFor(resp, rawURL), err
+}
+
+// askedFor sets the URL of resp, if any, to the URL asked for
+func askedFor(resp *Response, rawURL string) *Response {
+	if resp != nil {
+		resp.URL = rawURL
+	}
+	return resp
+}
+
+// reset empties w, if it can be
+func reset(w io.Writer) {
+	if r, ok := w.(Resetter); ok {
+		r.Reset()
+	}
+}
+
+// unreachable reports whether err means HTTPS could not be spoken with a
+// host at all: the connection failed or timed out, or TLS did, before
+// any of a body was read
+func unreachable(err error) bool {
+	var streamErr *streamError
+	if errors.As(err, &streamErr) {
+		return false
+	}
+	var opErr *net.OpError
+	var recordErr tls.RecordHeaderError
+	var certErr *tls.CertificateVerificationError
+	var urlErr *neturl.Error
+	switch {
+	case errors.As(err, &opErr), errors.As(err, &recordErr), errors.As(err, &certErr):
+		return true
+	case errors.As(err, &urlErr) && urlErr.Timeout():
+		return true
+	}
+	// What net/http makes of a RecordHeaderError from a plain HTTP server
+	return strings.Contains(err.Error(), "server gave HTTP response to HTTPS client")
+}
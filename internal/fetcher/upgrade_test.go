@@ -0,0 +1,54 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetcher_UpgradeHTTPS(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html>ok</html>"))
+	})
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+	secure := httptest.NewTLSServer(handler)
+	defer secure.Close()
+
+	tests := []struct {
+		name       string
+		server     *httptest.Server
+		wantScheme string
+	}{
+		{"upgraded", secure, "https"},
+		{"fallback", plain, "http"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.UpgradeHTTPS = true
+			config.Transport = secure.Client().Transport
+			f := New(config)
+
+			url := "http://" + tt.server.Listener.Addr().String() + "/Two/Page.html"
+			// Twice: first probing the host, then from what was learned
+			for i := 0; i < 2; i++ {
+				var body bytes.Buffer
+				resp, err := f.Fetch(context.Background(), url, &body)
+				if err != nil {
+					t.Fatalf("Fetch() #%d error = %v", i+1, err)
+				}
+				if resp.URL != url || !strings.HasPrefix(resp.FinalURL, tt.wantScheme+"://") {
+					t.Errorf("Fetch() #%d URL, FinalURL = %s, %s; want %s fetched over %s", i+1, resp.URL, resp.FinalURL, url, tt.wantScheme)
+				}
+				if body.String() != "<html>ok</html>" {
+					t.Errorf("Fetch() #%d body = %q", i+1, body.String())
+				}
+			}
+		})
+	}
+}
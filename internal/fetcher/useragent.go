@@ -0,0 +1,104 @@
+package fetcher
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultContact is the URL ArchivalUserAgent names when given none
+const DefaultContact = "https://github.com/aldehir/ue2-docs"
+
+// ArchivalUserAgent returns a User-Agent identifying the crawler honestly
+// as an archival bot, with a URL (or mailto: address) its operator can be
+// reached at, as archival crawling etiquette asks of bots
+func ArchivalUserAgent(contact string) string {
+	if contact == "" {
+		contact = DefaultContact
+	}
+	return "ue2-docs-scraper/1.0 (archival crawler; +" + contact + ")"
+}
+
+// UserAgentRotation controls which of Config.UserAgents a request is sent
+// with
+type UserAgentRotation int
+
+const (
+	// RotateNone sends every request with the first user agent
+	RotateNone UserAgentRotation = iota
+	// RotateRequest sends each request with the next user agent in turn
+	RotateRequest
+	// RotateHost gives each host the next user agent in turn, sending
+	// every request to the host with it, so each site sees one client
+	RotateHost
+)
+
+// String returns the rotation name as accepted by ParseUserAgentRotation
+func (r UserAgentRotation) String() string {
+	switch r {
+	case RotateNone:
+		return "none"
+	case RotateRequest:
+		return "request"
+	case RotateHost:
+		return "host"
+	default:
+		return fmt.Sprintf("UserAgentRotation(%d)", int(r))
+	}
+}
+
+// ParseUserAgentRotation parses "none", "request" or "host"
+func ParseUserAgentRotation(s string) (UserAgentRotation, error) {
+	switch s {
+	case "none":
+		return RotateNone, nil
+	case "request":
+		return RotateRequest, nil
+	case "host":
+		return RotateHost, nil
+	default:
+		return 0, fmt.Errorf("unknown user agent rotation %q (want none, request or host)", s)
+	}
+}
+
+// userAgents picks the User-Agent of each request
+type userAgents struct {
+	agents   []string
+	rotation UserAgentRotation
+	next     atomic.Uint64
+	hosts    sync.Map // Host -> user agent, with RotateHost
+}
+
+// newUserAgents returns the user agents of config: its UserAgents, or else
+// its UserAgent alone
+func newUserAgents(config Config) *userAgents {
+	agents := config.UserAgents
+	if len(agents) == 0 {
+		agents = []string{config.UserAgent}
+	}
+	return &userAgents{agents: agents, rotation: config.UserAgentRotation}
+}
+
+// pick returns the User-Agent of a request to host
+func (u *userAgents) pick(host string) string {
+	if len(u.agents) == 1 {
+		return u.agents[0]
+	}
+	switch u.rotation {
+	case RotateRequest:
+		return u.turn()
+	case RotateHost:
+		if agent, ok := u.hosts.Load(host); ok {
+			return agent.(string)
+		}
+		agent, _ := u.hosts.LoadOrStore(host, u.turn())
+		return agent.(string)
+	default:
+		return u.agents[0]
+	}
+}
+
+// turn returns the next user agent in turn
+func (u *userAgents) turn() string {
+	return u.agents[(u.next.Add(1)-1)%uint64(len(u.agents))]
+}
@@ -0,0 +1,61 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFetcher_UserAgentRotation(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.Header.Get("User-Agent"))
+		mu.Unlock()
+	}))
+	defer server.Close()
+	// The same server under a second host name
+	other := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	tests := []struct {
+		rotation UserAgentRotation
+		urls     []string
+		want     []string
+	}{
+		{RotateNone, []string{server.URL, server.URL, other}, []string{"a", "a", "a"}},
+		{RotateRequest, []string{server.URL, server.URL, server.URL}, []string{"a", "b", "a"}},
+		{RotateHost, []string{server.URL, other, server.URL, other}, []string{"a", "b", "a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rotation.String(), func(t *testing.T) {
+			seen = nil
+			config := DefaultConfig()
+			config.UserAgents = []string{"a", "b"}
+			config.UserAgentRotation = tt.rotation
+			f := New(config)
+			for _, url := range tt.urls {
+				if _, err := f.Fetch(context.Background(), url, &bytes.Buffer{}); err != nil {
+					t.Fatalf("Fetch(%s) error = %v", url, err)
+				}
+			}
+			if !slices.Equal(seen, tt.want) {
+				t.Errorf("user agents = %q, want %q", seen, tt.want)
+			}
+		})
+	}
+}
+
+func TestArchivalUserAgent(t *testing.T) {
+	if ua := ArchivalUserAgent("mailto:docs@example.com"); !strings.Contains(ua, "+mailto:docs@example.com") {
+		t.Errorf("ArchivalUserAgent() = %q, want the contact", ua)
+	}
+	if ua := DefaultConfig().UserAgent; !strings.Contains(ua, DefaultContact) {
+		t.Errorf("default User-Agent = %q, want %s as contact", ua, DefaultContact)
+	}
+}
@@ -0,0 +1,136 @@
+// Package gitrepo commits an output directory to a git repository after
+// each run, so every scrape or conversion becomes one commit: history,
+// diffs between runs and distribution through ordinary git hosting come
+// for free. It drives the git command, which must be installed
+package gitrepo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultName and DefaultEmail identify commits when git has no user
+// configured
+const (
+	DefaultName  = "ue2-docs"
+	DefaultEmail = "ue2-docs@localhost"
+)
+
+// Repo is a git working tree holding an output directory
+type Repo struct {
+	dir string
+	env []string // Added to git's environment
+}
+
+// Open opens the repository at dir, initializing one there if dir is not
+// already the top of a working tree. The directory is created if missing
+func Open(ctx context.Context, dir string) (*Repo, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git output needs git installed: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating %s: %w", dir, err)
+	}
+
+	r := &Repo{dir: dir, env: identity(ctx, dir)}
+	top, err := r.git(ctx, "rev-parse", "--show-toplevel")
+	if err == nil && sameDir(strings.TrimSpace(top), dir) {
+		return r, nil
+	}
+	// A directory inside another working tree, such as an output directory
+	// under a checkout, gets a repository of its own
+	if _, err := r.git(ctx, "init", "--quiet"); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Commit records every change in the working tree, including new and
+// deleted files, as one commit with the given message. It returns the
+// commit's hash, or "" if nothing changed since the last commit
+func (r *Repo) Commit(ctx context.Context, message string) (string, error) {
+	if _, err := r.git(ctx, "add", "--all", "."); err != nil {
+		return "", err
+	}
+	// diff --cached --quiet exits 1 when something is staged
+	if _, err := r.git(ctx, "diff", "--cached", "--quiet"); err == nil {
+		return "", nil
+	} else if !isExit(err, 1) {
+		return "", err
+	}
+
+	if _, err := r.run(ctx, message, "commit", "--quiet", "--no-verify", "--file", "-"); err != nil {
+		return "", err
+	}
+	hash, err := r.git(ctx, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(hash), nil
+}
+
+// Dir returns the top of the working tree
+func (r *Repo) Dir() string {
+	return r.dir
+}
+
+// git runs a git command in the working tree, returning its output
+func (r *Repo) git(ctx context.Context, args ...string) (string, error) {
+	return r.run(ctx, "", args...)
+}
+
+// run runs a git command in the working tree with stdin as its input
+func (r *Repo) run(ctx context.Context, stdin string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.dir
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Env = append(os.Environ(), r.env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
+
+// identity returns environment variables naming DefaultName and
+// DefaultEmail as author and committer, unless git has a user configured
+func identity(ctx context.Context, dir string) []string {
+	cmd := exec.CommandContext(ctx, "git", "config", "user.email")
+	cmd.Dir = dir
+	if out, err := cmd.Output(); err == nil && len(bytes.TrimSpace(out)) > 0 {
+		return nil
+	}
+	return []string{
+		"GIT_AUTHOR_NAME=" + DefaultName, "GIT_AUTHOR_EMAIL=" + DefaultEmail,
+		"GIT_COMMITTER_NAME=" + DefaultName, "GIT_COMMITTER_EMAIL=" + DefaultEmail,
+	}
+}
+
+// isExit reports whether err is a command exiting with code
+func isExit(err error, code int) bool {
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) && exitErr.ExitCode() == code
+}
+
+// sameDir reports whether two paths name the same directory
+func sameDir(a, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(filepath.Clean(b))
+	if err != nil {
+		return false
+	}
+	return os.SameFile(ai, bi)
+}
@@ -0,0 +1,84 @@
+package gitrepo
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func requireGit(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+}
+
+func TestRepo_Commit(t *testing.T) {
+	requireGit(t)
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "output")
+
+	r, err := Open(ctx, dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "Page.md"), []byte("# Page\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := r.Commit(ctx, "Convert: 1 page\n\nConverted: 1")
+	if err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if first == "" {
+		t.Fatal("Commit() made no commit for a new file")
+	}
+	if subject, _ := r.git(ctx, "log", "-1", "--format=%s"); strings.TrimSpace(subject) != "Convert: 1 page" {
+		t.Errorf("commit subject = %q", subject)
+	}
+
+	if again, err := r.Commit(ctx, "Convert: nothing changed"); err != nil || again != "" {
+		t.Errorf("Commit() without changes = %q, %v; want no commit", again, err)
+	}
+
+	// Reopening finds the existing repository, and deletions are committed
+	r, err = Open(ctx, dir)
+	if err != nil {
+		t.Fatalf("Open() of existing repository error = %v", err)
+	}
+	os.Remove(filepath.Join(dir, "Page.md"))
+	second, err := r.Commit(ctx, "Convert: page removed")
+	if err != nil || second == "" || second == first {
+		t.Fatalf("Commit() after a deletion = %q, %v", second, err)
+	}
+	if files, _ := r.git(ctx, "ls-files"); files != "" {
+		t.Errorf("deleted file still tracked: %q", files)
+	}
+	if count, _ := r.git(ctx, "rev-list", "--count", "HEAD"); strings.TrimSpace(count) != "2" {
+		t.Errorf("%s commits, want 2", strings.TrimSpace(count))
+	}
+}
+
+func TestOpen_InsideAnotherRepository(t *testing.T) {
+	requireGit(t)
+	ctx := context.Background()
+	outer := t.TempDir()
+	if _, err := Open(ctx, outer); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	inner := filepath.Join(outer, "markdown")
+	r, err := Open(ctx, inner)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(inner, ".git")); err != nil {
+		t.Errorf("no repository initialized in %s: %v", inner, err)
+	}
+	if r.Dir() != inner {
+		t.Errorf("Dir() = %s, want %s", r.Dir(), inner)
+	}
+}
@@ -0,0 +1,181 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// EventType identifies a crawl event that hooks can be notified of
+type EventType string
+
+const (
+	// EventPagesSaved fires every time another batch of pages has been saved
+	EventPagesSaved EventType = "pages_saved"
+	// EventErrorRateExceeded fires once when the failure rate crosses the threshold
+	EventErrorRateExceeded EventType = "error_rate_exceeded"
+	// EventCrawlFinished fires when the crawl ends, successfully or not
+	EventCrawlFinished EventType = "crawl_finished"
+)
+
+// Event describes something that happened during a crawl
+type Event struct {
+	Type    EventType `json:"event"`
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+	Visited int       `json:"visited"`
+	Saved   int       `json:"saved"`
+	Failed  int       `json:"failed"`
+}
+
+// Hook receives crawl events
+type Hook interface {
+	Notify(ctx context.Context, ev Event) error
+}
+
+// WebhookHook posts events as JSON to a URL
+//
+// The payload carries the message in both "content" and "text" fields so the
+// same URL format works for Discord and Slack incoming webhooks.
+type WebhookHook struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookHook creates a webhook hook posting to the given URL
+func NewWebhookHook(url string) *WebhookHook {
+	return &WebhookHook{
+		URL:    url,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Notify posts the event to the webhook URL
+func (h *WebhookHook) Notify(ctx context.Context, ev Event) error {
+	payload := struct {
+		Event
+		Content string `json:"content"`
+		Text    string `json:"text"`
+	}{
+		Event:   ev,
+		Content: ev.Message,
+		Text:    ev.Message,
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encoding webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned HTTP %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// CommandHook runs a shell command for each event
+// Event details are passed in UE2DOCS_* environment variables
+type CommandHook struct {
+	Command string
+}
+
+// NewCommandHook creates a hook that runs the given shell command
+func NewCommandHook(command string) *CommandHook {
+	return &CommandHook{Command: command}
+}
+
+// Notify runs the command with the event in its environment
+func (h *CommandHook) Notify(ctx context.Context, ev Event) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", h.Command)
+	cmd.Env = append(os.Environ(),
+		"UE2DOCS_EVENT="+string(ev.Type),
+		"UE2DOCS_MESSAGE="+ev.Message,
+		fmt.Sprintf("UE2DOCS_VISITED=%d", ev.Visited),
+		fmt.Sprintf("UE2DOCS_SAVED=%d", ev.Saved),
+		fmt.Sprintf("UE2DOCS_FAILED=%d", ev.Failed),
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running hook command: %w", err)
+	}
+	return nil
+}
+
+// Dispatcher delivers events to a set of hooks without blocking the caller
+type Dispatcher struct {
+	hooks   []Hook
+	timeout time.Duration
+	wg      sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher for the given hooks
+func NewDispatcher(hooks ...Hook) *Dispatcher {
+	return &Dispatcher{
+		hooks:   hooks,
+		timeout: 30 * time.Second,
+	}
+}
+
+// Len returns the number of registered hooks
+func (d *Dispatcher) Len() int {
+	if d == nil {
+		return 0
+	}
+	return len(d.hooks)
+}
+
+// Emit delivers the event to all hooks in the background
+// Hook failures are logged and otherwise ignored
+func (d *Dispatcher) Emit(ev Event) {
+	if d == nil {
+		return
+	}
+
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	for _, h := range d.hooks {
+		d.wg.Add(1)
+		go func(h Hook) {
+			defer d.wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+			defer cancel()
+
+			if err := h.Notify(ctx, ev); err != nil {
+				log.Printf("hook %s: %v", ev.Type, err)
+			}
+		}(h)
+	}
+}
+
+// Wait blocks until all pending deliveries have finished
+func (d *Dispatcher) Wait() {
+	if d == nil {
+		return
+	}
+	d.wg.Wait()
+}
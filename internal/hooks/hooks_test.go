@@ -0,0 +1,125 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWebhookHook_Notify(t *testing.T) {
+	var got map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("expected POST, got %s", r.Method)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("expected JSON content type, got %q", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding payload: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	hook := NewWebhookHook(server.URL)
+	err := hook.Notify(context.Background(), Event{
+		Type:    EventCrawlFinished,
+		Message: "done",
+		Saved:   42,
+	})
+	if err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	if got["event"] != string(EventCrawlFinished) {
+		t.Errorf("event = %v, want %v", got["event"], EventCrawlFinished)
+	}
+	if got["content"] != "done" || got["text"] != "done" {
+		t.Errorf("expected message in content and text, got %v / %v", got["content"], got["text"])
+	}
+	if got["saved"] != float64(42) {
+		t.Errorf("saved = %v, want 42", got["saved"])
+	}
+}
+
+func TestWebhookHook_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	if err := NewWebhookHook(server.URL).Notify(context.Background(), Event{}); err == nil {
+		t.Error("expected error for non-2xx webhook response")
+	}
+}
+
+func TestCommandHook_Notify(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "event.txt")
+
+	hook := NewCommandHook(`printf '%s %s' "$UE2DOCS_EVENT" "$UE2DOCS_SAVED" > ` + out)
+	err := hook.Notify(context.Background(), Event{Type: EventPagesSaved, Saved: 100})
+	if err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("reading hook output: %v", err)
+	}
+	if string(data) != "pages_saved 100" {
+		t.Errorf("hook output = %q, want %q", data, "pages_saved 100")
+	}
+}
+
+type recordingHook struct {
+	mu     sync.Mutex
+	events []Event
+	err    error
+}
+
+func (h *recordingHook) Notify(ctx context.Context, ev Event) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, ev)
+	return h.err
+}
+
+func TestDispatcher_Emit(t *testing.T) {
+	a := &recordingHook{}
+	b := &recordingHook{err: errors.New("unreachable")}
+	d := NewDispatcher(a, b)
+
+	d.Emit(Event{Type: EventCrawlFinished, Message: "finished"})
+	d.Wait()
+
+	for i, h := range []*recordingHook{a, b} {
+		if len(h.events) != 1 {
+			t.Fatalf("hook %d received %d events, want 1", i, len(h.events))
+		}
+		if h.events[0].Time.IsZero() {
+			t.Errorf("hook %d: expected event time to be set", i)
+		}
+		if !strings.Contains(h.events[0].Message, "finished") {
+			t.Errorf("hook %d: unexpected message %q", i, h.events[0].Message)
+		}
+	}
+}
+
+func TestDispatcher_Nil(t *testing.T) {
+	var d *Dispatcher
+	d.Emit(Event{Type: EventCrawlFinished})
+	d.Wait()
+
+	if d.Len() != 0 {
+		t.Errorf("nil dispatcher Len() = %v, want 0", d.Len())
+	}
+}
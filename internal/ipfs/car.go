@@ -0,0 +1,179 @@
+package ipfs
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ChunkSize and MaxLinks shape the DAG like 'ipfs add --cid-version=1':
+// files are split into raw leaves of ChunkSize bytes, gathered by
+// balanced nodes of at most MaxLinks children
+const (
+	ChunkSize = 256 << 10
+	MaxLinks  = 174
+)
+
+// Multicodec codes of the blocks of a file's DAG
+const (
+	codecRaw   = 0x55
+	codecDagPB = 0x70
+)
+
+// CID is a version 1 content identifier with a SHA-256 multihash
+type CID []byte
+
+// newCID returns the CID of a block encoded with codec
+func newCID(codec uint64, block []byte) CID {
+	sum := sha256.Sum256(block)
+	c := binary.AppendUvarint([]byte{1}, codec)
+	c = append(c, 0x12, 0x20) // sha2-256, 32 bytes
+	return append(c, sum[:]...)
+}
+
+// String returns the CID in base32, as IPFS prints version 1 CIDs
+func (c CID) String() string {
+	return "b" + strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(c))
+}
+
+// link is a link from a node of a file's DAG to a child
+type link struct {
+	cid      CID
+	fileSize uint64 // Of the part of the file under the child
+	tsize    uint64 // Of every block under the child, including it
+}
+
+// WriteCAR writes the contents of r as a UnixFS file in a CAR version 1
+// archive, returning the file's CID. The header naming the root is only
+// known once every block is written, so w must be seekable to fill it in
+func WriteCAR(w io.WriteSeeker, r io.Reader) (CID, error) {
+	// The root's CID has the same length whatever the file, so the header
+	// is written with a placeholder first
+	placeholder := carHeader(newCID(codecDagPB, nil))
+	if _, err := w.Write(placeholder); err != nil {
+		return nil, fmt.Errorf("writing CAR header: %w", err)
+	}
+
+	var leaves []link
+	buf := make([]byte, ChunkSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("reading file: %w", err)
+		}
+		// An empty file is a single empty leaf
+		if n > 0 || len(leaves) == 0 {
+			cid := newCID(codecRaw, buf[:n])
+			if err := writeBlock(w, cid, buf[:n]); err != nil {
+				return nil, err
+			}
+			leaves = append(leaves, link{cid: cid, fileSize: uint64(n), tsize: uint64(n)})
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	// Each level gathers the one below into nodes of up to MaxLinks links,
+	// filling every subtree before starting the next, until one remains
+	level := leaves
+	for len(level) > 1 {
+		var next []link
+		for i := 0; i < len(level); i += MaxLinks {
+			children := level[i:min(i+MaxLinks, len(level))]
+			block := fileNode(children)
+			cid := newCID(codecDagPB, block)
+			if err := writeBlock(w, cid, block); err != nil {
+				return nil, err
+			}
+			node := link{cid: cid, tsize: uint64(len(block))}
+			for _, child := range children {
+				node.fileSize += child.fileSize
+				node.tsize += child.tsize
+			}
+			next = append(next, node)
+		}
+		level = next
+	}
+	root := level[0].cid
+
+	if _, err := w.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("writing CAR header: %w", err)
+	}
+	if _, err := w.Write(carHeader(root)); err != nil {
+		return nil, fmt.Errorf("writing CAR header: %w", err)
+	}
+	if _, err := w.Seek(0, io.SeekEnd); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// carHeader encodes the CAR header naming root, prefixed by its length.
+// The header is the DAG-CBOR map {"roots": [root], "version": 1}
+func carHeader(root CID) []byte {
+	cbor := []byte{0xa2, 0x65} // A map of two entries; text of 5 bytes
+	cbor = append(cbor, "roots"...)
+	// An array of one tag 42 (a CID) holding bytes of a one byte length,
+	// starting with the identity multibase prefix
+	cbor = append(cbor, 0x81, 0xd8, 0x2a, 0x58, byte(len(root)+1), 0x00)
+	cbor = append(cbor, root...)
+	cbor = append(cbor, 0x67) // Text of 7 bytes
+	cbor = append(cbor, "version"...)
+	cbor = append(cbor, 0x01)
+	return append(binary.AppendUvarint(nil, uint64(len(cbor))), cbor...)
+}
+
+// writeBlock writes a block and its CID as a CAR section
+func writeBlock(w io.Writer, cid CID, block []byte) error {
+	section := binary.AppendUvarint(nil, uint64(len(cid)+len(block)))
+	section = append(section, cid...)
+	if _, err := w.Write(section); err != nil {
+		return fmt.Errorf("writing CAR block: %w", err)
+	}
+	if _, err := w.Write(block); err != nil {
+		return fmt.Errorf("writing CAR block: %w", err)
+	}
+	return nil
+}
+
+// fileNode encodes a DAG-PB node of a UnixFS file linking to children:
+// the links, then the UnixFS data giving the file size under each
+func fileNode(children []link) []byte {
+	var data []byte
+	data = appendVarintField(data, 1, 2) // Type: File
+	var total uint64
+	for _, child := range children {
+		total += child.fileSize
+	}
+	data = appendVarintField(data, 3, total)
+	for _, child := range children {
+		data = appendVarintField(data, 4, child.fileSize)
+	}
+
+	var node []byte
+	for _, child := range children {
+		var l []byte
+		l = appendBytesField(l, 1, child.cid)
+		l = appendBytesField(l, 2, nil) // Name, empty for file parts
+		l = appendVarintField(l, 3, child.tsize)
+		node = appendBytesField(node, 2, l)
+	}
+	return appendBytesField(node, 1, data)
+}
+
+// appendVarintField appends a protobuf varint field
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3)
+	return binary.AppendUvarint(b, v)
+}
+
+// appendBytesField appends a protobuf length-delimited field
+func appendBytesField(b []byte, field int, v []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
@@ -0,0 +1,94 @@
+// Package ipfs publishes packaged mirrors to IPFS for decentralized
+// preservation, either by adding them to a local node through its RPC API
+// or by writing a CAR file any node or pinning service can import
+package ipfs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultAPI is the RPC API address of a local Kubo node
+const DefaultAPI = "http://127.0.0.1:5001"
+
+// Node is an IPFS node reached through the Kubo RPC API
+type Node struct {
+	api    string
+	client *http.Client
+}
+
+// NewNode creates a Node for the RPC API at api, e.g. DefaultAPI
+func NewNode(api string) *Node {
+	return &Node{api: strings.TrimSuffix(api, "/"), client: &http.Client{}}
+}
+
+// AddFile adds the file at path to the node as a version 1 CID and pins
+// it, returning the CID
+func (n *Node) AddFile(ctx context.Context, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	// The file is streamed to the node rather than held in memory
+	body, w := io.Pipe()
+	form := multipart.NewWriter(w)
+	go func() {
+		part, err := form.CreateFormFile("file", filepath.Base(path))
+		if err == nil {
+			_, err = io.Copy(part, f)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		w.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.api+"/api/v0/add?cid-version=1&pin=true&progress=false", body)
+	if err != nil {
+		body.Close()
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("adding %s to IPFS: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct{ Message string }
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		if apiErr.Message == "" {
+			apiErr.Message = resp.Status
+		}
+		return "", fmt.Errorf("adding %s to IPFS: %s", path, apiErr.Message)
+	}
+
+	// The node reports every object added, the file's last
+	var cid string
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var added struct{ Hash string }
+		if err := dec.Decode(&added); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return "", fmt.Errorf("adding %s to IPFS: decoding response: %w", path, err)
+		}
+		cid = added.Hash
+	}
+	if cid == "" {
+		return "", fmt.Errorf("adding %s to IPFS: no CID in response", path)
+	}
+	return cid, nil
+}
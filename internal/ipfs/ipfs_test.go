@@ -0,0 +1,151 @@
+package ipfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCID_String(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"", "bafkreihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku"},
+		{"hello world", "bafkreifzjut3te2nhyekklss27nh3k72ysco7y32koao5eei66wof36n5e"},
+	}
+	for _, tt := range tests {
+		if got := newCID(codecRaw, []byte(tt.data)).String(); got != tt.want {
+			t.Errorf("CID of %q = %s, want %s", tt.data, got, tt.want)
+		}
+	}
+}
+
+// readCAR decodes a CAR file into its root and blocks by CID
+func readCAR(t *testing.T, data []byte) (CID, map[string][]byte) {
+	t.Helper()
+	headerLen, n := binary.Uvarint(data)
+	header := data[n : n+int(headerLen)]
+	rest := data[n+int(headerLen):]
+
+	i := bytes.Index(header, []byte("roots"))
+	root := CID(header[i+5+6 : i+5+6+36])
+
+	blocks := make(map[string][]byte)
+	for len(rest) > 0 {
+		size, n := binary.Uvarint(rest)
+		section := rest[n : n+int(size)]
+		blocks[string(section[:36])] = section[36:]
+		rest = rest[n+int(size):]
+	}
+	return root, blocks
+}
+
+// fileContent reassembles a file from its DAG
+func fileContent(t *testing.T, cid CID, blocks map[string][]byte) []byte {
+	t.Helper()
+	block, ok := blocks[string(cid)]
+	if !ok {
+		t.Fatalf("block %s missing", cid)
+	}
+	if cid[1] == codecRaw {
+		return block
+	}
+
+	var content []byte
+	for len(block) > 0 && block[0] == 0x12 { // Links
+		size, n := binary.Uvarint(block[1:])
+		l := block[1+n : 1+n+int(size)]
+		child := CID(l[2 : 2+l[1]])
+		content = append(content, fileContent(t, child, blocks)...)
+		block = block[1+n+int(size):]
+	}
+	return content
+}
+
+func TestWriteCAR(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		blocks int
+	}{
+		{"empty", 0, 1},
+		{"one chunk", 1000, 1},
+		{"two chunks", ChunkSize + 1, 3},
+		{"two levels", ChunkSize * (MaxLinks + 1), MaxLinks + 1 + 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := make([]byte, tt.size)
+			// Every chunk differs so that no blocks are shared
+			for i := range data {
+				data[i] = byte(i*7 + i/ChunkSize)
+			}
+
+			f, err := os.Create(filepath.Join(t.TempDir(), "out.car"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			root, err := WriteCAR(f, bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("WriteCAR() error = %v", err)
+			}
+
+			car, _ := os.ReadFile(f.Name())
+			headerRoot, blocks := readCAR(t, car)
+			if !bytes.Equal(headerRoot, root) {
+				t.Errorf("header root = %s, want %s", headerRoot, root)
+			}
+			if len(blocks) != tt.blocks {
+				t.Errorf("%d blocks, want %d", len(blocks), tt.blocks)
+			}
+			if got := fileContent(t, root, blocks); !bytes.Equal(got, data) {
+				t.Errorf("reassembled %d bytes, want %d", len(got), len(data))
+			}
+			if tt.blocks == 1 && root.String() != newCID(codecRaw, data).String() {
+				t.Errorf("single chunk root = %s, want the raw leaf", root)
+			}
+		})
+	}
+}
+
+func TestNode_AddFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v0/add" || r.URL.Query().Get("cid-version") != "1" {
+			http.Error(w, `{"Message":"unexpected request"}`, http.StatusBadRequest)
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, `{"Message":"no file"}`, http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		w.Write([]byte(`{"Name":"` + header.Filename + `","Hash":"` + newCID(codecRaw, data).String() + `","Size":"11"}` + "\n"))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "mirror.tar.gz")
+	os.WriteFile(path, []byte("hello world"), 0644)
+
+	cid, err := NewNode(server.URL).AddFile(context.Background(), path)
+	if err != nil {
+		t.Fatalf("AddFile() error = %v", err)
+	}
+	if cid != "bafkreifzjut3te2nhyekklss27nh3k72ysco7y32koao5eei66wof36n5e" {
+		t.Errorf("AddFile() = %s", cid)
+	}
+
+	_, err = NewNode(server.URL+"/nowhere").AddFile(context.Background(), path)
+	if err == nil || !strings.Contains(err.Error(), "unexpected request") {
+		t.Errorf("AddFile() error = %v, want the node's message", err)
+	}
+}
@@ -0,0 +1,188 @@
+// Package linkcheck probes links with lightweight requests to find out which
+// ones are dead
+package linkcheck
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aldehir/ue2-docs/internal/fetcher"
+)
+
+// Config holds link checker configuration
+type Config struct {
+	Timeout     time.Duration
+	UserAgent   string
+	Workers     int
+	RateLimiter fetcher.RateLimiter // Shared by all workers; nil = unlimited
+}
+
+// DefaultConfig returns a sensible default configuration
+func DefaultConfig() Config {
+	return Config{
+		Timeout:   15 * time.Second,
+		UserAgent: "ue2-docs-scraper/1.0",
+		Workers:   4,
+	}
+}
+
+// Status is the outcome of checking a single link
+type Status struct {
+	URL        string   `json:"url"`
+	StatusCode int      `json:"status,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	Timeout    bool     `json:"timeout,omitempty"`   // Error was a timeout
+	Referrers  []string `json:"referrers,omitempty"` // Pages linking to the URL
+}
+
+// Dead reports whether the link is gone: the request failed outright (DNS,
+// connection or TLS errors) or the server answered 404 or 410. Other errors,
+// such as 403 from bot protection, do not prove a link is dead, and nor do
+// timeouts, which a slow but live server also gives
+func (s Status) Dead() bool {
+	if s.Error != "" {
+		return !s.Timeout
+	}
+	return s.StatusCode == http.StatusNotFound || s.StatusCode == http.StatusGone
+}
+
+// Checker probes links with HEAD requests
+type Checker struct {
+	client *http.Client
+	config Config
+}
+
+// New creates a new Checker with the given configuration
+func New(config Config) *Checker {
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+	return &Checker{
+		client: &http.Client{Timeout: config.Timeout},
+		config: config,
+	}
+}
+
+// Check probes a single link. Servers that reject HEAD are retried with GET,
+// without reading the body
+func (c *Checker) Check(ctx context.Context, url string) Status {
+	status := Status{URL: url}
+
+	if c.config.RateLimiter != nil {
+		if err := c.config.RateLimiter.Wait(ctx); err != nil {
+			status.Error = err.Error()
+			return status
+		}
+	}
+
+	code, err := c.probe(ctx, http.MethodHead, url)
+	if err == nil && (code == http.StatusMethodNotAllowed || code == http.StatusNotImplemented) {
+		code, err = c.probe(ctx, http.MethodGet, url)
+	}
+	if err != nil {
+		status.Error = err.Error()
+		var netErr net.Error
+		status.Timeout = errors.As(err, &netErr) && netErr.Timeout()
+		return status
+	}
+
+	status.StatusCode = code
+	return status
+}
+
+// CheckAll probes every link concurrently, returning statuses in the same
+// order as urls. Links not checked before ctx is cancelled are omitted
+func (c *Checker) CheckAll(ctx context.Context, urls []string) []Status {
+	statuses := make([]Status, len(urls))
+	checked := make([]bool, len(urls))
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < c.config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				statuses[i] = c.Check(ctx, urls[i])
+				checked[i] = ctx.Err() == nil
+			}
+		}()
+	}
+
+feed:
+	for i := range urls {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	var result []Status
+	for i, s := range statuses {
+		if checked[i] {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// probe performs a single request and returns the status code
+func (c *Checker) probe(ctx context.Context, method, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", c.config.UserAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+// Report lists the dead links found by a check
+type Report struct {
+	Generated time.Time `json:"generated"`
+	Checked   int       `json:"checked"`
+	Dead      []Status  `json:"dead"`
+}
+
+// NewReport builds a report from check results
+func NewReport(statuses []Status) Report {
+	report := Report{
+		Generated: time.Now().UTC(),
+		Checked:   len(statuses),
+		Dead:      []Status{},
+	}
+	for _, s := range statuses {
+		if s.Dead() {
+			report.Dead = append(report.Dead, s)
+		}
+	}
+	return report
+}
+
+// Save writes the report to a file as JSON
+func (r Report) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding link report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("writing link report: %w", err)
+	}
+	return nil
+}
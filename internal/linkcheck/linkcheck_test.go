@@ -0,0 +1,102 @@
+package linkcheck
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestChecker_CheckAll(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+		case "/gone":
+			w.WriteHeader(http.StatusGone)
+		case "/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+		case "/no-head":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		case "/slow":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	urls := []string{
+		server.URL + "/ok",
+		server.URL + "/missing",
+		server.URL + "/gone",
+		server.URL + "/forbidden",
+		server.URL + "/no-head",
+		"http://127.0.0.1:1/unreachable",
+		server.URL + "/slow",
+	}
+	wantDead := []bool{false, true, true, false, false, true, false}
+
+	config := DefaultConfig()
+	config.Timeout = 200 * time.Millisecond
+	statuses := New(config).CheckAll(context.Background(), urls)
+	if len(statuses) != len(urls) {
+		t.Fatalf("CheckAll() returned %d statuses, want %d", len(statuses), len(urls))
+	}
+	for i, s := range statuses {
+		if s.URL != urls[i] {
+			t.Errorf("status %d is for %s, want %s", i, s.URL, urls[i])
+		}
+		if s.Dead() != wantDead[i] {
+			t.Errorf("%s: Dead() = %v, want %v (status %d, error %q)", s.URL, s.Dead(), wantDead[i], s.StatusCode, s.Error)
+		}
+	}
+	if statuses[4].StatusCode != http.StatusOK {
+		t.Errorf("HEAD-rejecting server should be retried with GET, got %d", statuses[4].StatusCode)
+	}
+	if !statuses[6].Timeout {
+		t.Errorf("slow server: Timeout = false, want true (error %q)", statuses[6].Error)
+	}
+}
+
+func TestChecker_CheckAllCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	statuses := New(DefaultConfig()).CheckAll(ctx, []string{"http://127.0.0.1:1/a", "http://127.0.0.1:1/b"})
+	if len(statuses) != 0 {
+		t.Errorf("CheckAll() with cancelled context = %v, want none", statuses)
+	}
+}
+
+func TestReport_Save(t *testing.T) {
+	report := NewReport([]Status{
+		{URL: "https://a.com/", StatusCode: 200},
+		{URL: "https://b.com/", StatusCode: 404, Referrers: []string{"https://docs.com/Page.html"}},
+	})
+
+	path := filepath.Join(t.TempDir(), "external.json")
+	if err := report.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded Report
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Checked != 2 || len(loaded.Dead) != 1 || loaded.Dead[0].URL != "https://b.com/" || len(loaded.Dead[0].Referrers) != 1 {
+		t.Errorf("loaded report = %+v", loaded)
+	}
+}
@@ -0,0 +1,58 @@
+package manifest
+
+import (
+	"path"
+	"strings"
+)
+
+// Lookup returns the entries a reference to a page names, sorted by path:
+// the entry saved from a URL, the entry at a path in the mirror, or else
+// the pages of a TWiki topic, given as "Web.Topic" or "Topic" regardless
+// of case. A topic may name pages in several webs or versions
+func (m *Manifest) Lookup(ref string) []Entry {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return nil
+	}
+	if e, ok := m.Get(strings.TrimPrefix(ref, "/")); ok {
+		return []Entry{e}
+	}
+
+	entries := m.Entries()
+	if strings.Contains(ref, "://") {
+		ref, _, _ = strings.Cut(ref, "#")
+		for _, e := range entries {
+			if e.URL == ref {
+				return []Entry{e}
+			}
+		}
+		return nil
+	}
+
+	web, topic, ok := strings.Cut(ref, ".")
+	if !ok || strings.Contains(topic, ".") {
+		web, topic = "", ref
+	}
+	var found []Entry
+	for _, e := range entries {
+		base := path.Base(e.Path)
+		name := strings.TrimSuffix(base, path.Ext(base))
+		if !isPage(e) || !strings.EqualFold(name, topic) {
+			continue
+		}
+		if web != "" && !strings.EqualFold(path.Base(path.Dir(e.Path)), web) {
+			continue
+		}
+		found = append(found, e)
+	}
+	return found
+}
+
+// isPage reports whether an entry is an HTML page
+func isPage(e Entry) bool {
+	if strings.HasPrefix(e.ContentType, "text/html") {
+		return true
+	}
+	ext := strings.ToLower(path.Ext(e.Path))
+	return e.ContentType == "" && (ext == ".html" || ext == ".htm")
+}
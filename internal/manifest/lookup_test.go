@@ -0,0 +1,42 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestManifest_Lookup(t *testing.T) {
+	m := New()
+	for _, e := range []Entry{
+		{URL: "https://udn.epicgames.com/Two/PhysicsVolume.html", Path: "udn.epicgames.com/Two/PhysicsVolume.html", ContentType: "text/html"},
+		{URL: "https://udn.epicgames.com/Three/PhysicsVolume.html", Path: "udn.epicgames.com/Three/PhysicsVolume.html", ContentType: "text/html"},
+		{URL: "https://udn.epicgames.com/Two/Actor.html", Path: "udn.epicgames.com/Two/Actor.html"},
+		{URL: "https://udn.epicgames.com/Two/images/Actor.png", Path: "udn.epicgames.com/Two/images/Actor.png", ContentType: "image/png"},
+	} {
+		m.Add(e)
+	}
+
+	tests := []struct {
+		ref  string
+		want []string
+	}{
+		{"https://udn.epicgames.com/Two/Actor.html#Events", []string{"udn.epicgames.com/Two/Actor.html"}},
+		{"https://udn.epicgames.com/Two/Missing.html", nil},
+		{"udn.epicgames.com/Two/images/Actor.png", []string{"udn.epicgames.com/Two/images/Actor.png"}},
+		{"actor", []string{"udn.epicgames.com/Two/Actor.html"}},
+		{"PhysicsVolume", []string{"udn.epicgames.com/Three/PhysicsVolume.html", "udn.epicgames.com/Two/PhysicsVolume.html"}},
+		{"Two.PhysicsVolume", []string{"udn.epicgames.com/Two/PhysicsVolume.html"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			var got []string
+			for _, e := range m.Lookup(tt.ref) {
+				got = append(got, e.Path)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lookup(%q) = %v, want %v", tt.ref, got, tt.want)
+			}
+		})
+	}
+}
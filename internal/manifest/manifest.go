@@ -0,0 +1,341 @@
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aldehir/ue2-docs/internal/zst"
+)
+
+// Filename is the name of the manifest file in the root of a mirror
+const Filename = "manifest.json"
+
+// CompressedFilename is the name of a Zstandard-compressed manifest, which
+// takes the place of Filename
+const CompressedFilename = Filename + zst.Extension
+
+// Version is the current manifest format version
+const Version = 1
+
+// ErrUnsafePath is returned for a manifest listing a file outside its
+// mirror, such as "../file" or "/file"
+var ErrUnsafePath = errors.New("unsafe path in manifest")
+
+// Entry describes a single saved file in the mirror
+type Entry struct {
+	URL         string    `json:"url"`
+	Path        string    `json:"path"` // Slash-separated, relative to the mirror root
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	ContentType string    `json:"content_type,omitempty"`
+	NoIndex     bool      `json:"noindex,omitempty"`  // Excluded from generated indexes (robots noindex)
+	Source      string    `json:"source,omitempty"`   // Path of the file this one was generated from, if not fetched
+	Version     string    `json:"version,omitempty"`  // Documentation version, for mirrors holding several
+	Author      string    `json:"author,omitempty"`   // TWiki user who last changed the page
+	Revision    string    `json:"revision,omitempty"` // TWiki topic revision, e.g. "r1.4"
+	Parent      string    `json:"parent,omitempty"`   // TWiki parent topic
+	SavedAt     time.Time `json:"saved_at"`
+
+	// Encoding is the compression the file is stored with, EncodingGzip
+	// for a file at Path plus ".gz", or "" for none. Size and SHA256
+	// describe the uncompressed content either way
+	Encoding string `json:"encoding,omitempty"`
+
+	// LastModified is the origin's Last-Modified time, if it sent one
+	LastModified time.Time `json:"last_modified,omitzero"`
+}
+
+// EncodingGzip marks files stored gzip-compressed
+const EncodingGzip = "gzip"
+
+// File returns the path of the file holding the entry's content: Path, or
+// Path with ".gz" appended for gzip-compressed files
+func (e Entry) File() string {
+	if e.Encoding == EncodingGzip {
+		return e.Path + ".gz"
+	}
+	return e.Path
+}
+
+// Manifest is a thread-safe index of the files in a mirror, keyed by path
+type Manifest struct {
+	mu      sync.RWMutex
+	entries map[string]Entry
+	roots   map[string]string
+}
+
+// New creates an empty manifest
+func New() *Manifest {
+	return &Manifest{
+		entries: make(map[string]Entry),
+		roots:   make(map[string]string),
+	}
+}
+
+// Add records an entry, replacing any previous entry for the same path
+func (m *Manifest) Add(e Entry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[e.Path] = e
+}
+
+// Remove deletes the entry for a path, if present
+func (m *Manifest) Remove(path string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, path)
+}
+
+// RemoveVersion deletes the entries and root page of a documentation version
+func (m *Manifest) RemoveVersion(version string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for path, e := range m.entries {
+		if e.Version == version {
+			delete(m.entries, path)
+		}
+	}
+	delete(m.roots, version)
+}
+
+// SetVersionRoot records the path of a documentation version's root page
+func (m *Manifest) SetVersionRoot(version, path string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.roots[version] = path
+}
+
+// VersionRoots returns a copy of the root page path of each documentation
+// version, keyed by version
+func (m *Manifest) VersionRoots() map[string]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	roots := make(map[string]string, len(m.roots))
+	for version, path := range m.roots {
+		roots[version] = path
+	}
+	return roots
+}
+
+// Get returns the entry for a path
+// Returns (entry, true) if the path is in the manifest, (Entry{}, false) otherwise
+func (m *Manifest) Get(path string) (Entry, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	e, ok := m.entries[path]
+	return e, ok
+}
+
+// Len returns the number of entries in the manifest
+func (m *Manifest) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.entries)
+}
+
+// Entries returns a snapshot of all entries sorted by path
+func (m *Manifest) Entries() []Entry {
+	m.mu.RLock()
+	entries := make([]Entry, 0, len(m.entries))
+	for _, e := range m.entries {
+		entries = append(entries, e)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	return entries
+}
+
+// Load reads a manifest from a file, compressed or not
+func Load(path string) (*Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// LoadDir reads the manifest in the root of a mirror, CompressedFilename
+// if there is one and Filename otherwise
+func LoadDir(dir string) (*Manifest, error) {
+	m, err := Load(filepath.Join(dir, CompressedFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return Load(filepath.Join(dir, Filename))
+	}
+	return m, err
+}
+
+// Parse decodes a manifest read from a mirror kept elsewhere than on disk
+func Parse(data []byte) (*Manifest, error) {
+	return Read(bytes.NewReader(data))
+}
+
+// Read decodes a manifest, compressed or not, one entry at a time, so a
+// huge manifest is never held in memory as JSON
+func Read(r io.Reader) (*Manifest, error) {
+	zr, err := zst.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	defer zr.Close()
+
+	m := New()
+	if err := m.decode(json.NewDecoder(zr)); err != nil {
+		return nil, fmt.Errorf("decoding manifest: %w", err)
+	}
+	return m, nil
+}
+
+// decode reads the fields of a manifest's JSON object into m
+func (m *Manifest) decode(dec *json.Decoder) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "version":
+			var version int
+			if err := dec.Decode(&version); err != nil {
+				return err
+			}
+			if version > Version {
+				return fmt.Errorf("manifest version %d is newer than supported version %d", version, Version)
+			}
+		case "versions":
+			if err := dec.Decode(&m.roots); err != nil {
+				return err
+			}
+		case "files":
+			if err := expectDelim(dec, '['); err != nil {
+				return err
+			}
+			for dec.More() {
+				var e Entry
+				if err := dec.Decode(&e); err != nil {
+					return err
+				}
+				if !filepath.IsLocal(filepath.FromSlash(e.Path)) {
+					return fmt.Errorf("%w: %q", ErrUnsafePath, e.Path)
+				}
+				m.entries[e.Path] = e
+			}
+			if err := expectDelim(dec, ']'); err != nil {
+				return err
+			}
+		default:
+			var skipped json.RawMessage
+			if err := dec.Decode(&skipped); err != nil {
+				return err
+			}
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+// expectDelim reads the next token, failing unless it is delim
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != delim {
+		return fmt.Errorf("found %v, want %v", tok, delim)
+	}
+	return nil
+}
+
+// Write encodes the manifest to w one entry at a time, so writing a huge
+// manifest never holds all of it encoded in memory
+func (m *Manifest) Write(w io.Writer) error {
+	generated, err := json.Marshal(time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("encoding manifest: %w", err)
+	}
+	versions, err := json.MarshalIndent(m.VersionRoots(), "  ", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding manifest: %w", err)
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "{\n  \"version\": %d,\n  \"generated\": %s,\n  \"versions\": %s,\n  \"files\": [", Version, generated, versions)
+	for i, e := range m.Entries() {
+		data, err := json.MarshalIndent(e, "    ", "  ")
+		if err != nil {
+			return fmt.Errorf("encoding manifest: %w", err)
+		}
+		if i > 0 {
+			bw.WriteByte(',')
+		}
+		bw.WriteString("\n    ")
+		bw.Write(data)
+	}
+	bw.WriteString("\n  ]\n}\n")
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("writing manifest: %w", err)
+	}
+	return nil
+}
+
+// Save writes the manifest to a file atomically, so readers never observe a
+// partially written manifest. Paths ending in zst.Extension are written
+// Zstandard-compressed
+func (m *Manifest) Save(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".manifest-*")
+	if err != nil {
+		return fmt.Errorf("creating manifest: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("creating manifest: %w", err)
+	}
+	if err := m.writeFile(tmp, zst.Compressed(path)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing manifest: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("replacing manifest: %w", err)
+	}
+	return nil
+}
+
+// writeFile writes the manifest to f, compressed if compress is set
+func (m *Manifest) writeFile(f *os.File, compress bool) error {
+	if !compress {
+		return m.Write(f)
+	}
+	zw, err := zst.NewWriter(f)
+	if err != nil {
+		return fmt.Errorf("writing manifest: %w", err)
+	}
+	if err := m.Write(zw); err != nil {
+		zw.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("writing manifest: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package manifest
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestManifest_AddAndGet(t *testing.T) {
+	m := New()
+
+	m.Add(Entry{Path: "example.com/b.html", Size: 1})
+	m.Add(Entry{Path: "example.com/a.html", Size: 2})
+	m.Add(Entry{Path: "example.com/b.html", Size: 3})
+
+	if m.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", m.Len())
+	}
+
+	e, ok := m.Get("example.com/b.html")
+	if !ok || e.Size != 3 {
+		t.Errorf("Get() = %+v, %v; want latest entry with size 3", e, ok)
+	}
+
+	if _, ok := m.Get("example.com/missing.html"); ok {
+		t.Error("Get() of unknown path should return false")
+	}
+
+	entries := m.Entries()
+	if len(entries) != 2 || entries[0].Path != "example.com/a.html" {
+		t.Errorf("Entries() = %v, want sorted by path", entries)
+	}
+}
+
+func TestManifest_SaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), Filename)
+
+	m := New()
+	m.Add(Entry{URL: "https://example.com/a.html", Path: "example.com/a.html", Size: 10, SHA256: "abc"})
+	if err := m.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("manifest mode = %v, want 0644", info.Mode().Perm())
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	e, ok := loaded.Get("example.com/a.html")
+	if !ok || e.URL != "https://example.com/a.html" || e.SHA256 != "abc" || e.Size != 10 {
+		t.Errorf("loaded entry = %+v", e)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".manifest-*"))
+	if len(matches) != 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+}
+
+func TestManifest_SaveCompressed(t *testing.T) {
+	dir := t.TempDir()
+
+	m := New()
+	m.Add(Entry{URL: "https://example.com/a.html", Path: "example.com/a.html", Size: 10})
+	m.SetVersionRoot("v2", "example.com/a.html")
+	if err := m.Save(filepath.Join(dir, CompressedFilename)); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, CompressedFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) < 4 || data[0] != 0x28 || data[1] != 0xb5 {
+		t.Errorf("manifest not Zstandard-compressed: % x", data[:min(len(data), 4)])
+	}
+
+	loaded, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("LoadDir() error = %v", err)
+	}
+	if e, ok := loaded.Get("example.com/a.html"); !ok || e.Size != 10 {
+		t.Errorf("loaded entry = %+v, %v", e, ok)
+	}
+	if root := loaded.VersionRoots()["v2"]; root != "example.com/a.html" {
+		t.Errorf("version root = %q", root)
+	}
+}
+
+func TestManifest_Versions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), Filename)
+
+	m := New()
+	m.Add(Entry{Path: "two/example.com/a.html", Version: "two"})
+	m.Add(Entry{Path: "three/example.com/a.html", Version: "three"})
+	m.SetVersionRoot("two", "two/example.com/a.html")
+	m.SetVersionRoot("three", "three/example.com/a.html")
+	if err := m.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if roots := loaded.VersionRoots(); len(roots) != 2 || roots["two"] != "two/example.com/a.html" {
+		t.Errorf("VersionRoots() = %v", roots)
+	}
+
+	loaded.RemoveVersion("two")
+	if _, ok := loaded.Get("two/example.com/a.html"); ok || loaded.Len() != 1 {
+		t.Errorf("RemoveVersion() left %v", loaded.Entries())
+	}
+	if roots := loaded.VersionRoots(); len(roots) != 1 || roots["three"] == "" {
+		t.Errorf("VersionRoots() after RemoveVersion = %v", roots)
+	}
+}
+
+func TestLoad_NewerVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), Filename)
+	os.WriteFile(path, []byte(`{"version": 99, "files": []}`), 0644)
+
+	if _, err := Load(path); err == nil {
+		t.Error("expected error for unsupported manifest version")
+	}
+}
+
+func TestLoad_UnsafePath(t *testing.T) {
+	for _, path := range []string{"../payload.txt", "/etc/passwd", "a/../../b"} {
+		file := filepath.Join(t.TempDir(), Filename)
+		os.WriteFile(file, []byte(`{"version": 1, "files": [{"url": "", "path": "`+path+`"}]}`), 0644)
+
+		if _, err := Load(file); !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("Load() of %q error = %v, want ErrUnsafePath", path, err)
+		}
+	}
+}
+
+func TestManifest_Concurrent(t *testing.T) {
+	m := New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			m.Add(Entry{Path: filepath.Join("example.com", string(rune('a'+i%26))+".html")})
+			m.Entries()
+		}(i)
+	}
+	wg.Wait()
+
+	if m.Len() != 26 {
+		t.Errorf("Len() = %d, want 26", m.Len())
+	}
+}
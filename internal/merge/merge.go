@@ -0,0 +1,187 @@
+// Package merge combines partial mirrors, such as those of contributors
+// each scraping a few sections of the site, into one
+package merge
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+
+	"github.com/aldehir/ue2-docs/internal/manifest"
+	"github.com/aldehir/ue2-docs/internal/minisign"
+	"github.com/aldehir/ue2-docs/internal/storage"
+)
+
+// ErrConflicts is returned when mirrors hold different copies of a file
+// and Config.Newest is unset
+var ErrConflicts = errors.New("mirrors hold conflicting copies of files")
+
+// Config holds merge configuration
+type Config struct {
+	Into    string   // Mirror to merge into, created if missing
+	Mirrors []string // Partial mirrors merged in, in order
+
+	// Newest settles conflicts, files two mirrors hold different copies
+	// of, by keeping the copy saved last. Otherwise any conflict fails
+	// the merge before a file is written
+	Newest bool
+
+	SignKey *minisign.SecretKey // Signs the merged manifest; nil disables signing
+}
+
+// Conflict is a file two mirrors hold different copies of
+type Conflict struct {
+	Path    string
+	Mirrors [2]string // The mirror holding each copy, the one kept first with Newest
+}
+
+// Result summarizes a merge
+type Result struct {
+	Copied    int   // Files written into the merged mirror
+	Identical int   // Files held by several mirrors with the same content
+	Bytes     int64 // Of the files copied
+	Conflicts []Conflict
+}
+
+// source is the copy of a file chosen for the merged mirror
+type source struct {
+	entry  manifest.Entry
+	store  *storage.Storage
+	mirror string
+}
+
+// Merge copies the files of every mirror into Config.Into and writes the
+// union of their manifests there. A file held by several mirrors, or
+// already in Into, is copied once if its copies are identical by SHA-256;
+// copies that differ are conflicts. Every file copied is first checked
+// against the size and SHA-256 its manifest records
+func Merge(ctx context.Context, config Config) (*Result, error) {
+	into, err := storage.Open(config.Into)
+	if err != nil {
+		return nil, err
+	}
+	into.SignWith(config.SignKey)
+
+	chosen := make(map[string]source)
+	for _, e := range into.Manifest().Entries() {
+		chosen[e.Path] = source{entry: e, store: into, mirror: config.Into}
+	}
+
+	result := &Result{}
+	for _, dir := range config.Mirrors {
+		if filepath.Clean(dir) == filepath.Clean(config.Into) {
+			return nil, fmt.Errorf("cannot merge %s into itself", dir)
+		}
+		st, err := storage.Open(dir)
+		if err != nil {
+			return nil, err
+		}
+		if st.Manifest().Len() == 0 {
+			return nil, fmt.Errorf("no manifest entries in %s", dir)
+		}
+
+		for _, e := range st.Manifest().Entries() {
+			cur, ok := chosen[e.Path]
+			switch {
+			case !ok:
+				chosen[e.Path] = source{entry: e, store: st, mirror: dir}
+			case cur.entry.SHA256 == e.SHA256:
+				result.Identical++
+			case config.Newest && e.SavedAt.After(cur.entry.SavedAt):
+				result.Conflicts = append(result.Conflicts, Conflict{Path: e.Path, Mirrors: [2]string{dir, cur.mirror}})
+				chosen[e.Path] = source{entry: e, store: st, mirror: dir}
+			default:
+				result.Conflicts = append(result.Conflicts, Conflict{Path: e.Path, Mirrors: [2]string{cur.mirror, dir}})
+			}
+		}
+		for version, root := range st.Manifest().VersionRoots() {
+			into.Manifest().SetVersionRoot(version, root)
+		}
+	}
+	if len(result.Conflicts) > 0 && !config.Newest {
+		return result, fmt.Errorf("%w: %d files", ErrConflicts, len(result.Conflicts))
+	}
+
+	paths := make([]string, 0, len(chosen))
+	for path := range chosen {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	// Every copy is checked before any is written, so a damaged mirror
+	// leaves the merged one untouched
+	var copies []source
+	for _, path := range paths {
+		if src := chosen[path]; src.store != into {
+			copies = append(copies, src)
+		}
+	}
+	for _, src := range copies {
+		if err := verify(ctx, src); err != nil {
+			return result, err
+		}
+	}
+
+	for _, src := range copies {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if err := copyEntry(ctx, into, src); err != nil {
+			return result, err
+		}
+		result.Copied++
+		result.Bytes += src.entry.Size
+	}
+
+	if err := into.WriteVersionIndex(); err != nil {
+		return result, err
+	}
+	if err := into.WriteManifest(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// copyEntry copies the file of src into the mirror into. The entry keeps
+// when it was saved, rather than when it was copied
+func copyEntry(ctx context.Context, into *storage.Storage, src source) error {
+	r, err := src.store.OpenEntry(ctx, src.entry)
+	if err != nil {
+		return fmt.Errorf("reading %s from %s: %w", src.entry.Path, src.mirror, err)
+	}
+	defer r.Close()
+
+	saved, err := into.Save(ctx, src.entry, r)
+	if err != nil {
+		return err
+	}
+	saved.SavedAt = src.entry.SavedAt
+	into.Manifest().Add(saved)
+	return nil
+}
+
+// verify checks that the content of the file of src has the size and
+// SHA-256 its manifest entry records
+func verify(ctx context.Context, src source) error {
+	r, err := src.store.OpenEntry(ctx, src.entry)
+	if err != nil {
+		return fmt.Errorf("reading %s from %s: %w", src.entry.Path, src.mirror, err)
+	}
+	defer r.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(hash, r)
+	if err != nil {
+		return fmt.Errorf("reading %s from %s: %w", src.entry.Path, src.mirror, err)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); n != src.entry.Size || sum != src.entry.SHA256 {
+		return fmt.Errorf("%s in %s is damaged: %d bytes with SHA-256 %s, manifest says %d bytes with %s (run 'ue2-docs repair' on it first)",
+			src.entry.Path, src.mirror, n, sum, src.entry.Size, src.entry.SHA256)
+	}
+	return nil
+}
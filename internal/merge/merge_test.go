@@ -0,0 +1,144 @@
+package merge
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aldehir/ue2-docs/internal/manifest"
+	"github.com/aldehir/ue2-docs/internal/storage"
+)
+
+// writeMirror saves files, by path, to a new mirror in dir with a manifest
+// dated saved
+func writeMirror(t *testing.T, dir string, files map[string]string, saved time.Time) {
+	t.Helper()
+	st := storage.New(dir)
+	for path, body := range files {
+		entry, err := st.Save(context.Background(), manifest.Entry{URL: "https://example.com/" + path, Path: path}, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry.SavedAt = saved
+		st.Manifest().Add(entry)
+	}
+	if err := st.WriteManifest(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	dir := t.TempDir()
+	partA, partB, full := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "full")
+	saved := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	writeMirror(t, partA, map[string]string{
+		"SiteMap.html":               "<p>Site map</p>",
+		"UnrealScriptReference.html": "<p>API</p>",
+	}, saved)
+	writeMirror(t, partB, map[string]string{
+		"SiteMap.html":        "<p>Site map</p>",
+		"NetworkingTome.html": "<p>Networking</p>",
+	}, saved)
+
+	result, err := Merge(context.Background(), Config{Into: full, Mirrors: []string{partA, partB}})
+	if err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	if result.Copied != 3 || result.Identical != 1 || len(result.Conflicts) != 0 {
+		t.Errorf("Merge() = %+v, want 3 copied and 1 identical", result)
+	}
+
+	m, err := manifest.LoadDir(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Len() != 3 {
+		t.Errorf("merged manifest has %d entries, want 3", m.Len())
+	}
+	e, _ := m.Get("NetworkingTome.html")
+	if !e.SavedAt.Equal(saved) {
+		t.Errorf("SavedAt = %v, want the part's %v", e.SavedAt, saved)
+	}
+	if data, _ := os.ReadFile(filepath.Join(full, "NetworkingTome.html")); string(data) != "<p>Networking</p>" {
+		t.Errorf("NetworkingTome.html = %q", data)
+	}
+
+	// Merging again finds everything already there
+	result, err = Merge(context.Background(), Config{Into: full, Mirrors: []string{partA, partB}})
+	if err != nil {
+		t.Fatalf("second Merge() error = %v", err)
+	}
+	if result.Copied != 0 || result.Identical != 4 {
+		t.Errorf("second Merge() = %+v, want nothing copied", result)
+	}
+}
+
+func TestMerge_Conflicts(t *testing.T) {
+	dir := t.TempDir()
+	older, newer, full := filepath.Join(dir, "older"), filepath.Join(dir, "newer"), filepath.Join(dir, "full")
+	writeMirror(t, older, map[string]string{"SiteMap.html": "<p>Old</p>", "A.html": "a"}, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	writeMirror(t, newer, map[string]string{"SiteMap.html": "<p>New</p>"}, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC))
+
+	result, err := Merge(context.Background(), Config{Into: full, Mirrors: []string{older, newer}})
+	if !errors.Is(err, ErrConflicts) {
+		t.Fatalf("Merge() error = %v, want ErrConflicts", err)
+	}
+	if len(result.Conflicts) != 1 || result.Conflicts[0].Path != "SiteMap.html" {
+		t.Errorf("Conflicts = %+v", result.Conflicts)
+	}
+	if _, err := os.Stat(filepath.Join(full, "A.html")); !os.IsNotExist(err) {
+		t.Error("failed merge wrote files")
+	}
+
+	result, err = Merge(context.Background(), Config{Into: full, Mirrors: []string{older, newer}, Newest: true})
+	if err != nil {
+		t.Fatalf("Merge() with Newest error = %v", err)
+	}
+	if len(result.Conflicts) != 1 || result.Conflicts[0].Mirrors != [2]string{newer, older} {
+		t.Errorf("Conflicts = %+v, want the newer copy kept", result.Conflicts)
+	}
+	if data, _ := os.ReadFile(filepath.Join(full, "SiteMap.html")); string(data) != "<p>New</p>" {
+		t.Errorf("SiteMap.html = %q, want the newer copy", data)
+	}
+}
+
+func TestMerge_Damaged(t *testing.T) {
+	dir := t.TempDir()
+	part, full := filepath.Join(dir, "part"), filepath.Join(dir, "full")
+	writeMirror(t, part, map[string]string{"A.html": "a", "B.html": "b"}, time.Now())
+	os.WriteFile(filepath.Join(part, "B.html"), []byte("truncated"), 0644)
+
+	if _, err := Merge(context.Background(), Config{Into: full, Mirrors: []string{part}}); err == nil || !strings.Contains(err.Error(), "B.html") {
+		t.Fatalf("Merge() error = %v, want B.html reported damaged", err)
+	}
+	if _, err := os.Stat(filepath.Join(full, "A.html")); !os.IsNotExist(err) {
+		t.Error("merge of a damaged mirror wrote files")
+	}
+}
+
+func TestMerge_UnsafePath(t *testing.T) {
+	dir := t.TempDir()
+	part, full := filepath.Join(dir, "a", "part"), filepath.Join(dir, "b", "full")
+	os.MkdirAll(part, 0755)
+	os.WriteFile(filepath.Join(dir, "a", "payload.txt"), []byte("payload"), 0644)
+	os.WriteFile(filepath.Join(part, manifest.Filename), []byte(`{"version": 1, "files": [
+{"url": "", "path": "../payload.txt", "size": 7, "sha256": "239f59ed55e737c77147cf55ad0c1b030b6d7ee748a7426952f9b852d5a935e5"}]}`), 0644)
+
+	if _, err := Merge(context.Background(), Config{Into: full, Mirrors: []string{part}}); !errors.Is(err, manifest.ErrUnsafePath) {
+		t.Fatalf("Merge() error = %v, want ErrUnsafePath", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b", "payload.txt")); !os.IsNotExist(err) {
+		t.Error("merge wrote outside the merged mirror")
+	}
+}
+
+func TestMerge_NoManifest(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Merge(context.Background(), Config{Into: filepath.Join(dir, "full"), Mirrors: []string{filepath.Join(dir, "missing")}}); err == nil {
+		t.Error("Merge() of a mirror without a manifest succeeded")
+	}
+}
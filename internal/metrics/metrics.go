@@ -0,0 +1,86 @@
+// Package metrics defines the sink crawls report their telemetry into, so
+// embedders of the fetcher and scraper can wire them to their own
+// monitoring. No-op, log and Prometheus sinks are built in
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Sink receives counters and timers. Implementations must be safe for
+// concurrent use
+type Sink interface {
+	// Add adds delta to the counter name
+	Add(name string, delta int64, labels ...Label)
+	// Observe records one duration of the timer name
+	Observe(name string, d time.Duration, labels ...Label)
+}
+
+// Label is a dimension of a metric, such as the host a request went to
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Metrics reported by the fetcher
+const (
+	Requests        = "requests"         // HTTP requests made, by host and status ("error" without a response)
+	Retries         = "retries"          // Failed requests that were retried, by host
+	BytesFetched    = "bytes_fetched"    // Body bytes received, by host
+	RequestDuration = "request_duration" // Time from sending a request to reading its body, by host
+)
+
+// Metrics reported by the scraper
+const (
+	Saved      = "saved"       // Resources saved, by type
+	Failed     = "failed"      // Resources that failed, by cause
+	BytesSaved = "bytes_saved" // Bytes of the resources saved
+)
+
+// Nop discards every metric
+var Nop Sink = nop{}
+
+type nop struct{}
+
+func (nop) Add(string, int64, ...Label)             {}
+func (nop) Observe(string, time.Duration, ...Label) {}
+
+// OrNop returns s, or Nop if s is nil
+func OrNop(s Sink) Sink {
+	if s == nil {
+		return Nop
+	}
+	return s
+}
+
+// Log writes every metric to a logger as it is reported, for debugging or
+// for log pipelines that aggregate metrics themselves
+type Log struct {
+	logger *slog.Logger
+	level  slog.Level
+}
+
+// NewLog creates a Log sink writing to logger at level
+func NewLog(logger *slog.Logger, level slog.Level) *Log {
+	return &Log{logger: logger, level: level}
+}
+
+// Add logs a counter increment
+func (l *Log) Add(name string, delta int64, labels ...Label) {
+	l.log(name, "delta", delta, labels)
+}
+
+// Observe logs a timer observation
+func (l *Log) Observe(name string, d time.Duration, labels ...Label) {
+	l.log(name, "duration", d, labels)
+}
+
+func (l *Log) log(name, key string, value any, labels []Label) {
+	args := []any{"metric", name, key, value}
+	for _, label := range labels {
+		args = append(args, label.Name, label.Value)
+	}
+	l.logger.Log(context.Background(), l.level, "metric", args...)
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheus(t *testing.T) {
+	p := NewPrometheus("ue2docs")
+	host := Label{Name: "host", Value: "example.com"}
+	p.Add(Requests, 1, Label{Name: "status", Value: "200"}, host)
+	p.Add(Requests, 2, host, Label{Name: "status", Value: "200"})
+	p.Add(Requests, 1, host, Label{Name: "status", Value: "error"})
+	p.Add(BytesSaved, 512)
+	p.Observe(RequestDuration, 250*time.Millisecond, host)
+	p.Observe(RequestDuration, 750*time.Millisecond, host)
+	p.Add(Failed, 1, Label{Name: "cause", Value: "say \"hi\"\\\n"})
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	want := `# TYPE ue2docs_bytes_saved_total counter
+ue2docs_bytes_saved_total 512
+# TYPE ue2docs_failed_total counter
+ue2docs_failed_total{cause="say \"hi\"\\\n"} 1
+# TYPE ue2docs_requests_total counter
+ue2docs_requests_total{host="example.com",status="200"} 3
+ue2docs_requests_total{host="example.com",status="error"} 1
+# TYPE ue2docs_request_duration_seconds summary
+ue2docs_request_duration_seconds_sum{host="example.com"} 1
+ue2docs_request_duration_seconds_count{host="example.com"} 2
+`
+	if got := rec.Body.String(); got != want {
+		t.Errorf("metrics =\n%s\nwant\n%s", got, want)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+}
+
+func TestLog(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewLog(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})), slog.LevelDebug)
+	sink.Add(Saved, 1, Label{Name: "type", Value: "html"})
+	sink.Observe(RequestDuration, time.Second)
+
+	got := buf.String()
+	for _, want := range []string{"metric=saved delta=1 type=html", "metric=request_duration duration=1s"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("log = %q, want it to contain %q", got, want)
+		}
+	}
+}
+
+func TestOrNop(t *testing.T) {
+	if OrNop(nil) != Nop {
+		t.Error("OrNop(nil) is not Nop")
+	}
+	p := NewPrometheus("")
+	if OrNop(p) != Sink(p) {
+		t.Error("OrNop(p) is not p")
+	}
+}
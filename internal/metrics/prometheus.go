@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prometheus aggregates metrics in memory and serves them in the
+// Prometheus text exposition format. Counters are exposed as
+// <namespace>_<name>_total and timers as summaries of seconds,
+// <namespace>_<name>_seconds
+type Prometheus struct {
+	namespace string
+
+	mu       sync.Mutex
+	counters map[string]map[string]int64 // By name, then by encoded labels
+	timers   map[string]map[string]*summary
+}
+
+// summary is the count and sum of a timer's observations
+type summary struct {
+	count int64
+	sum   time.Duration
+}
+
+// NewPrometheus creates a Prometheus sink whose metric names start with
+// namespace, e.g. "ue2docs"
+func NewPrometheus(namespace string) *Prometheus {
+	return &Prometheus{
+		namespace: namespace,
+		counters:  make(map[string]map[string]int64),
+		timers:    make(map[string]map[string]*summary),
+	}
+}
+
+// Add adds delta to a counter
+func (p *Prometheus) Add(name string, delta int64, labels ...Label) {
+	key := encodeLabels(labels)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.counters[name] == nil {
+		p.counters[name] = make(map[string]int64)
+	}
+	p.counters[name][key] += delta
+}
+
+// Observe records a timer observation
+func (p *Prometheus) Observe(name string, d time.Duration, labels ...Label) {
+	key := encodeLabels(labels)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.timers[name] == nil {
+		p.timers[name] = make(map[string]*summary)
+	}
+	s := p.timers[name][key]
+	if s == nil {
+		s = &summary{}
+		p.timers[name][key] = s
+	}
+	s.count++
+	s.sum += d
+}
+
+// ServeHTTP serves the metrics, for scraping by a Prometheus server
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the text exposition format, sorted by
+// name and labels
+func (p *Prometheus) WriteTo(w io.Writer) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var b strings.Builder
+	for _, name := range sortedKeys(p.counters) {
+		metric := p.metricName(name) + "_total"
+		fmt.Fprintf(&b, "# TYPE %s counter\n", metric)
+		for _, labels := range sortedKeys(p.counters[name]) {
+			fmt.Fprintf(&b, "%s%s %d\n", metric, labels, p.counters[name][labels])
+		}
+	}
+	for _, name := range sortedKeys(p.timers) {
+		metric := p.metricName(name) + "_seconds"
+		fmt.Fprintf(&b, "# TYPE %s summary\n", metric)
+		for _, labels := range sortedKeys(p.timers[name]) {
+			s := p.timers[name][labels]
+			fmt.Fprintf(&b, "%s_sum%s %g\n", metric, labels, s.sum.Seconds())
+			fmt.Fprintf(&b, "%s_count%s %d\n", metric, labels, s.count)
+		}
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// metricName prefixes a metric's name with the namespace
+func (p *Prometheus) metricName(name string) string {
+	if p.namespace == "" {
+		return name
+	}
+	return p.namespace + "_" + name
+}
+
+// encodeLabels encodes labels as they appear in the exposition format,
+// e.g. {host="example.com",status="200"}, sorted by name
+func encodeLabels(labels []Label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	sorted := append([]Label(nil), labels...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, label := range sorted {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", label.Name, labelEscaper.Replace(label.Value))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// labelEscaper escapes label values as the exposition format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// sortedKeys returns the keys of m in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
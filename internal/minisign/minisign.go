@@ -0,0 +1,304 @@
+// Package minisign signs files in the minisign format
+// (https://jedisct1.github.io/minisign/), so published mirrors can be
+// verified with the standard minisign tool
+package minisign
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Extension is appended to the name of a signed file to name its signature
+const Extension = ".minisig"
+
+// ErrWrongPassword is returned when an encrypted secret key fails its
+// checksum after decryption
+var ErrWrongPassword = errors.New("wrong password for secret key")
+
+// Algorithm identifiers
+var (
+	algEd25519   = [2]byte{'E', 'd'} // Key algorithm; also legacy non-prehashed signatures
+	algPrehashed = [2]byte{'E', 'D'} // Signatures over the BLAKE2b-512 hash of the file
+	kdfScrypt    = [2]byte{'S', 'c'}
+	kdfNone      = [2]byte{0, 0}
+	chkBlake2b   = [2]byte{'B', '2'}
+)
+
+// secretKeyLength is the decoded size of a secret key file's key line
+const secretKeyLength = 2 + 2 + 2 + 32 + 8 + 8 + 8 + ed25519.PrivateKeySize + 32
+
+// SecretKey is a decrypted minisign secret key
+type SecretKey struct {
+	ID  [8]byte
+	Key ed25519.PrivateKey
+}
+
+// PublicKey is a minisign public key
+type PublicKey struct {
+	ID  [8]byte
+	Key ed25519.PublicKey
+}
+
+// LoadSecretKey reads a secret key file as written by "minisign -G",
+// decrypting it with password unless it was created without one
+func LoadSecretKey(path string, password []byte) (*SecretKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading secret key: %w", err)
+	}
+	key, err := ParseSecretKey(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("loading secret key %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// ParseSecretKey decodes the contents of a secret key file
+func ParseSecretKey(data, password []byte) (*SecretKey, error) {
+	raw, err := decodeKeyLine(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != secretKeyLength {
+		return nil, fmt.Errorf("secret key is %d bytes, want %d", len(raw), secretKeyLength)
+	}
+
+	var sigAlg, kdfAlg, chkAlg [2]byte
+	copy(sigAlg[:], raw[0:2])
+	copy(kdfAlg[:], raw[2:4])
+	copy(chkAlg[:], raw[4:6])
+	salt := raw[6:38]
+	opsLimit := binary.LittleEndian.Uint64(raw[38:46])
+	memLimit := binary.LittleEndian.Uint64(raw[46:54])
+	keynum := append([]byte(nil), raw[54:]...) // Key ID, secret key, checksum
+
+	if sigAlg != algEd25519 || chkAlg != chkBlake2b {
+		return nil, fmt.Errorf("unsupported key algorithm %q", sigAlg[:])
+	}
+
+	switch kdfAlg {
+	case kdfNone:
+	case kdfScrypt:
+		stream, err := deriveKey(password, salt, opsLimit, memLimit, len(keynum))
+		if err != nil {
+			return nil, err
+		}
+		for i := range keynum {
+			keynum[i] ^= stream[i]
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key derivation %q", kdfAlg[:])
+	}
+
+	key := &SecretKey{Key: ed25519.PrivateKey(keynum[8 : 8+ed25519.PrivateKeySize])}
+	copy(key.ID[:], keynum[:8])
+
+	// Some implementations leave the checksum of unencrypted keys zeroed
+	stored := keynum[8+ed25519.PrivateKeySize:]
+	if kdfAlg == kdfNone && bytes.Equal(stored, make([]byte, len(stored))) {
+		return key, nil
+	}
+
+	sum := key.checksum()
+	if subtle.ConstantTimeCompare(sum[:], stored) != 1 {
+		if kdfAlg == kdfScrypt {
+			return nil, ErrWrongPassword
+		}
+		return nil, errors.New("secret key checksum mismatch")
+	}
+	return key, nil
+}
+
+// checksum returns the BLAKE2b-256 checksum minisign stores with a secret key
+func (k *SecretKey) checksum() [32]byte {
+	h, _ := blake2b.New256(nil)
+	h.Write(algEd25519[:])
+	h.Write(k.ID[:])
+	h.Write(k.Key)
+	var sum [32]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// Public returns the public key matching a secret key
+func (k *SecretKey) Public() *PublicKey {
+	return &PublicKey{ID: k.ID, Key: k.Key.Public().(ed25519.PublicKey)}
+}
+
+// String encodes a public key as written in the second line of a
+// minisign.pub file
+func (p *PublicKey) String() string {
+	raw := append(append(algEd25519[:], p.ID[:]...), p.Key...)
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+// ParsePublicKey decodes a public key as printed by String or stored in a
+// minisign.pub file
+func ParsePublicKey(data []byte) (*PublicKey, error) {
+	raw, err := decodeKeyLine(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != 2+8+ed25519.PublicKeySize || !bytes.Equal(raw[:2], algEd25519[:]) {
+		return nil, errors.New("invalid public key")
+	}
+	p := &PublicKey{Key: ed25519.PublicKey(raw[10:])}
+	copy(p.ID[:], raw[2:10])
+	return p, nil
+}
+
+// Sign returns a prehashed signature of message in the minisign signature
+// file format, carrying trustedComment
+func (k *SecretKey) Sign(message []byte, trustedComment string) []byte {
+	hash := blake2b.Sum512(message)
+	sig := ed25519.Sign(k.Key, hash[:])
+	global := ed25519.Sign(k.Key, append(append([]byte(nil), sig...), trustedComment...))
+
+	raw := append(append(algPrehashed[:], k.ID[:]...), sig...)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "untrusted comment: signature from ue2-docs secret key %X\n", reverse(k.ID))
+	fmt.Fprintf(&buf, "%s\n", base64.StdEncoding.EncodeToString(raw))
+	fmt.Fprintf(&buf, "trusted comment: %s\n", trustedComment)
+	fmt.Fprintf(&buf, "%s\n", base64.StdEncoding.EncodeToString(global))
+	return buf.Bytes()
+}
+
+// SignFile writes a signature of the file at path to path+Extension, with
+// minisign's default trusted comment
+func (k *SecretKey) SignFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	comment := fmt.Sprintf("timestamp:%d\tfile:%s\thashed", time.Now().Unix(), filepath.Base(path))
+	if err := os.WriteFile(path+Extension, k.Sign(data, comment), 0644); err != nil {
+		return fmt.Errorf("writing signature: %w", err)
+	}
+	return nil
+}
+
+// Verify checks a signature file against a message and returns its trusted
+// comment
+func (p *PublicKey) Verify(message, signature []byte) (string, error) {
+	lines := splitLines(signature)
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return "", errors.New("malformed signature")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(raw) != 2+8+ed25519.SignatureSize {
+		return "", errors.New("malformed signature")
+	}
+	if !bytes.Equal(raw[2:10], p.ID[:]) {
+		return "", fmt.Errorf("signed by key %X, not %X", reverse([8]byte(raw[2:10])), reverse(p.ID))
+	}
+
+	msg := message
+	switch [2]byte(raw[:2]) {
+	case algPrehashed:
+		hash := blake2b.Sum512(message)
+		msg = hash[:]
+	case algEd25519:
+	default:
+		return "", fmt.Errorf("unsupported signature algorithm %q", raw[:2])
+	}
+
+	sig := raw[10:]
+	if !ed25519.Verify(p.Key, msg, sig) {
+		return "", errors.New("invalid signature")
+	}
+
+	comment := strings.TrimPrefix(lines[2], "trusted comment: ")
+	global, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || !ed25519.Verify(p.Key, append(append([]byte(nil), sig...), comment...), global) {
+		return "", errors.New("invalid trusted comment signature")
+	}
+	return comment, nil
+}
+
+// deriveKey derives the stream a secret key is encrypted with, choosing
+// scrypt parameters from the stored limits the way libsodium does
+func deriveKey(password, salt []byte, opsLimit, memLimit uint64, length int) ([]byte, error) {
+	if opsLimit < 32768 {
+		opsLimit = 32768
+	}
+	const r = 8
+
+	var logN, p uint64
+	if opsLimit < memLimit/32 {
+		p = 1
+		maxN := opsLimit / (r * 4)
+		for logN = 1; logN < 63; logN++ {
+			if uint64(1)<<logN > maxN/2 {
+				break
+			}
+		}
+	} else {
+		maxN := memLimit / (r * 128)
+		for logN = 1; logN < 63; logN++ {
+			if uint64(1)<<logN > maxN/2 {
+				break
+			}
+		}
+		maxrp := (opsLimit / 4) / (uint64(1) << logN)
+		if maxrp > 0x3fffffff {
+			maxrp = 0x3fffffff
+		}
+		p = maxrp / r
+	}
+
+	stream, err := scrypt.Key(password, salt, 1<<logN, r, int(p), length)
+	if err != nil {
+		return nil, fmt.Errorf("deriving key: %w", err)
+	}
+	return stream, nil
+}
+
+// decodeKeyLine decodes the base64 line of a key file, skipping its
+// untrusted comment
+func decodeKeyLine(data []byte) ([]byte, error) {
+	for _, line := range splitLines(data) {
+		if line == "" || strings.HasPrefix(line, "untrusted comment:") {
+			continue
+		}
+		raw, err := base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			return nil, fmt.Errorf("decoding key: %w", err)
+		}
+		return raw, nil
+	}
+	return nil, errors.New("no key found")
+}
+
+// splitLines splits data into lines without their line endings
+func splitLines(data []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	return lines
+}
+
+// reverse returns a key ID in the byte order minisign prints it in
+func reverse(id [8]byte) [8]byte {
+	for i, j := 0, len(id)-1; i < j; i, j = i+1, j-1 {
+		id[i], id[j] = id[j], id[i]
+	}
+	return id
+}
@@ -0,0 +1,114 @@
+package minisign
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// encryptKey encodes a secret key file encrypted with cheap scrypt limits
+func encryptKey(t *testing.T, key *SecretKey, password []byte) []byte {
+	t.Helper()
+
+	raw := make([]byte, secretKeyLength)
+	copy(raw[0:], algEd25519[:])
+	copy(raw[2:], kdfScrypt[:])
+	copy(raw[4:], chkBlake2b[:])
+	rand.Read(raw[6:38])
+	binary.LittleEndian.PutUint64(raw[38:], 32768)
+	binary.LittleEndian.PutUint64(raw[46:], 1<<20)
+
+	sum := key.checksum()
+	keynum := append(append(append([]byte(nil), key.ID[:]...), key.Key...), sum[:]...)
+	stream, err := deriveKey(password, raw[6:38], 32768, 1<<20, len(keynum))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range keynum {
+		raw[54+i] = keynum[i] ^ stream[i]
+	}
+
+	return []byte("untrusted comment: test key\n" + base64.StdEncoding.EncodeToString(raw) + "\n")
+}
+
+func TestParseSecretKey_Encrypted(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	key := &SecretKey{ID: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}, Key: priv}
+	data := encryptKey(t, key, []byte("hunter2"))
+
+	parsed, err := ParseSecretKey(data, []byte("hunter2"))
+	if err != nil {
+		t.Fatalf("ParseSecretKey() error = %v", err)
+	}
+	if parsed.ID != key.ID || !bytes.Equal(parsed.Key, key.Key) {
+		t.Error("ParseSecretKey() returned a different key")
+	}
+
+	if _, err := ParseSecretKey(data, []byte("wrong")); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("ParseSecretKey() with wrong password error = %v, want ErrWrongPassword", err)
+	}
+}
+
+func TestSignFile_Verify(t *testing.T) {
+	key, err := LoadSecretKey(filepath.Join("testdata", "unencrypted.key"), nil)
+	if err != nil {
+		t.Fatalf("LoadSecretKey() error = %v", err)
+	}
+
+	pubData, _ := os.ReadFile(filepath.Join("testdata", "minisign.pub"))
+	pub, err := ParsePublicKey(pubData)
+	if err != nil {
+		t.Fatalf("ParsePublicKey() error = %v", err)
+	}
+	if key.Public().String() != pub.String() {
+		t.Errorf("Public() = %s, want %s", key.Public(), pub)
+	}
+
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	os.WriteFile(path, []byte(`{"version": 1}`), 0644)
+	if err := key.SignFile(path); err != nil {
+		t.Fatalf("SignFile() error = %v", err)
+	}
+
+	sig, err := os.ReadFile(path + Extension)
+	if err != nil {
+		t.Fatal(err)
+	}
+	comment, err := pub.Verify([]byte(`{"version": 1}`), sig)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if !strings.HasSuffix(comment, "\tfile:manifest.json\thashed") {
+		t.Errorf("trusted comment = %q", comment)
+	}
+
+	if _, err := pub.Verify([]byte(`{"version": 2}`), sig); err == nil {
+		t.Error("Verify() accepted a signature of different content")
+	}
+	tampered := bytes.Replace(sig, []byte("trusted comment: timestamp"), []byte("trusted comment: timestamq"), 1)
+	if _, err := pub.Verify([]byte(`{"version": 1}`), tampered); err == nil {
+		t.Error("Verify() accepted a tampered trusted comment")
+	}
+}
+
+// Signatures made by another minisign implementation verify
+func TestVerify_Reference(t *testing.T) {
+	pubData, _ := os.ReadFile(filepath.Join("testdata", "minisign.pub"))
+	pub, err := ParsePublicKey(pubData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message, _ := os.ReadFile(filepath.Join("testdata", "manifest.json"))
+	sig, _ := os.ReadFile(filepath.Join("testdata", "manifest.json"+Extension))
+	if _, err := pub.Verify(message, sig); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+}
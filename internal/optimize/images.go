@@ -0,0 +1,134 @@
+package optimize
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/png"
+
+	"golang.org/x/image/bmp"
+)
+
+// errUnsupported is returned for images that cannot be optimized losslessly
+var errUnsupported = errors.New("cannot optimize losslessly")
+
+// pngSignature starts every PNG file
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// colorChunks are PNG chunks describing color management, which Go's PNG
+// encoder does not preserve
+var colorChunks = map[string]bool{
+	"gAMA": true,
+	"cHRM": true,
+	"sRGB": true,
+	"iCCP": true,
+}
+
+// recompressPNG re-encodes a PNG at the best compression level. Images with
+// color management chunks are rejected, since re-encoding would drop them
+// and change how the image is displayed
+func recompressPNG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, fmt.Errorf("not a PNG")
+	}
+
+	for pos := len(pngSignature); pos+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		chunk := string(data[pos+4 : pos+8])
+		if colorChunks[chunk] {
+			return nil, fmt.Errorf("%w: PNG has %s chunk", errUnsupported, chunk)
+		}
+		pos += 12 + length
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decoding PNG: %w", err)
+	}
+
+	buf := &bytes.Buffer{}
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := enc.Encode(buf, img); err != nil {
+		return nil, fmt.Errorf("encoding PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// convertBMP converts a BMP image to PNG
+func convertBMP(data []byte) ([]byte, error) {
+	img, err := bmp.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decoding BMP: %w", err)
+	}
+
+	buf := &bytes.Buffer{}
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := enc.Encode(buf, img); err != nil {
+		return nil, fmt.Errorf("encoding PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// stripJPEG removes comments and metadata segments that do not affect how a
+// JPEG is displayed. The compressed image data is copied unchanged
+func stripJPEG(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, fmt.Errorf("not a JPEG")
+	}
+
+	out := []byte{0xFF, 0xD8}
+	pos := 2
+	for pos+2 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil, fmt.Errorf("malformed JPEG marker at offset %d", pos)
+		}
+
+		marker := data[pos+1]
+		switch {
+		case marker == 0xFF:
+			// Fill byte
+			pos++
+			continue
+		case marker == 0xDA:
+			// Start of scan; everything after is entropy-coded image data
+			return append(out, data[pos:]...), nil
+		case marker == 0x01, marker >= 0xD0 && marker <= 0xD7:
+			// Standalone markers without a length
+			out = append(out, data[pos:pos+2]...)
+			pos += 2
+			continue
+		}
+
+		if pos+4 > len(data) {
+			break
+		}
+		end := pos + 2 + int(binary.BigEndian.Uint16(data[pos+2:]))
+		if end < pos+4 || end > len(data) {
+			return nil, fmt.Errorf("truncated JPEG segment at offset %d", pos)
+		}
+
+		if !droppableSegment(marker, data[pos+4:end]) {
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+	}
+
+	return nil, fmt.Errorf("JPEG has no image data")
+}
+
+// droppableSegment reports whether a JPEG segment is safe to remove: comments
+// and application metadata other than JFIF (APP0), Exif (APP1, which may
+// carry the orientation), ICC profiles (APP2) and Adobe color info (APP14)
+func droppableSegment(marker byte, payload []byte) bool {
+	switch {
+	case marker == 0xFE:
+		return true
+	case marker == 0xE1:
+		return !bytes.HasPrefix(payload, []byte("Exif\x00"))
+	case marker >= 0xE3 && marker <= 0xEF:
+		return marker != 0xEE
+	default:
+		return false
+	}
+}
@@ -0,0 +1,250 @@
+// Package optimize shrinks the images in a mirror without changing how they
+// look, and optionally adds thumbnails for oversized inline images, updating
+// the manifest to match
+package optimize
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"github.com/aldehir/ue2-docs/internal/manifest"
+	"github.com/aldehir/ue2-docs/internal/minisign"
+	"github.com/aldehir/ue2-docs/internal/parser"
+	"github.com/aldehir/ue2-docs/internal/storage"
+)
+
+// Config holds optimizer configuration
+type Config struct {
+	Root       string // Mirror directory containing manifest.json
+	ConvertBMP bool   // Convert BMP images to PNG and rewrite references to them
+
+	// ThumbnailWidth replaces inline images wider than this with a
+	// thumbnail linking to the full image (0 = disabled)
+	ThumbnailWidth int
+
+	// SignKey signs the updated manifest; nil disables signing
+	SignKey *minisign.SecretKey
+}
+
+// Result summarizes an optimization pass
+type Result struct {
+	Optimized  int // Images rewritten in place
+	Converted  int // BMPs converted to PNG
+	Unchanged  int // Images that could not be made smaller
+	Failed     int
+	Thumbnails int // Thumbnails generated
+	Rewritten  int // HTML pages updated to reference converted images or thumbnails
+	BytesSaved int64
+}
+
+// Optimizer losslessly recompresses the images in a mirror
+type Optimizer struct {
+	config Config
+}
+
+// New creates a new optimizer with the given configuration
+func New(config Config) *Optimizer {
+	return &Optimizer{config: config}
+}
+
+// mirrorBase is the URL a mirror path is resolved against when rewriting
+// references between saved files
+const mirrorBase = "http://mirror.invalid/"
+
+// Run optimizes every image listed in the mirror's manifest, generates
+// thumbnails if enabled, and saves the updated manifest. Cancelling ctx stops
+// after the current file; the manifest still records the work done so far
+func (o *Optimizer) Run(ctx context.Context) (*Result, error) {
+	st, err := storage.Open(o.config.Root)
+	if err != nil {
+		return nil, err
+	}
+	st.SignWith(o.config.SignKey)
+
+	result := &Result{}
+	renamed := make(map[string]string) // Old path -> new path
+
+	for _, entry := range st.Manifest().Entries() {
+		if ctx.Err() != nil {
+			break
+		}
+
+		ext := strings.ToLower(path.Ext(entry.Path))
+		if ext == ".bmp" && !o.config.ConvertBMP {
+			continue
+		}
+
+		var optimize func([]byte) ([]byte, error)
+		switch ext {
+		case ".png":
+			optimize = recompressPNG
+		case ".jpg", ".jpeg":
+			optimize = stripJPEG
+		case ".bmp":
+			optimize = convertBMP
+		default:
+			continue
+		}
+
+		data, err := os.ReadFile(st.FullPath(entry.Path))
+		if err != nil {
+			log.Printf("failed to read %s: %v", entry.Path, err)
+			result.Failed++
+			continue
+		}
+
+		out, err := optimize(data)
+		if errors.Is(err, errUnsupported) {
+			result.Unchanged++
+			continue
+		}
+		if err != nil {
+			log.Printf("failed to optimize %s: %v", entry.Path, err)
+			result.Failed++
+			continue
+		}
+
+		if ext == ".bmp" {
+			newPath := strings.TrimSuffix(entry.Path, path.Ext(entry.Path)) + ".png"
+			if _, exists := st.Manifest().Get(newPath); exists {
+				log.Printf("not converting %s: %s already exists", entry.Path, newPath)
+				result.Failed++
+				continue
+			}
+
+			converted := entry
+			converted.Path = newPath
+			converted.ContentType = "image/png"
+			if _, err := st.Save(ctx, converted, bytes.NewReader(out)); err != nil {
+				log.Printf("failed to convert %s: %v", entry.Path, err)
+				result.Failed++
+				continue
+			}
+			if err := st.Remove(entry.Path); err != nil {
+				log.Printf("failed to remove %s: %v", entry.Path, err)
+			}
+
+			renamed[entry.Path] = newPath
+			result.Converted++
+			result.BytesSaved += int64(len(data) - len(out))
+			continue
+		}
+
+		if len(out) >= len(data) {
+			result.Unchanged++
+			continue
+		}
+
+		if _, err := st.Save(ctx, entry, bytes.NewReader(out)); err != nil {
+			log.Printf("failed to save %s: %v", entry.Path, err)
+			result.Failed++
+			continue
+		}
+		result.Optimized++
+		result.BytesSaved += int64(len(data) - len(out))
+	}
+
+	var thumbs *thumbnailer
+	if o.config.ThumbnailWidth > 0 {
+		thumbs = &thumbnailer{
+			st:       st,
+			maxWidth: o.config.ThumbnailWidth,
+			made:     make(map[string]string),
+			result:   result,
+		}
+	}
+
+	if len(renamed) > 0 || thumbs != nil {
+		for _, entry := range st.Manifest().Entries() {
+			if ctx.Err() != nil {
+				break
+			}
+
+			changed, err := rewritePage(ctx, st, entry, func(doc *html.Node) bool {
+				changed := len(renamed) > 0 && rewriteReferences(doc, entry.Path, renamed)
+				if thumbs != nil && thumbs.rewritePage(ctx, doc, entry.Path) {
+					changed = true
+				}
+				return changed
+			})
+			if err != nil {
+				log.Printf("failed to update %s: %v", entry.Path, err)
+				result.Failed++
+				continue
+			}
+			if changed {
+				result.Rewritten++
+			}
+		}
+	}
+
+	if err := st.WriteManifest(); err != nil {
+		return result, err
+	}
+
+	return result, ctx.Err()
+}
+
+// rewritePage applies fn to a saved HTML page, saving the page if fn reports
+// a change. Returns true if the page was changed
+func rewritePage(ctx context.Context, st *storage.Storage, entry manifest.Entry, fn func(doc *html.Node) bool) (bool, error) {
+	ext := strings.ToLower(path.Ext(entry.Path))
+	if ext != ".html" && ext != ".htm" {
+		return false, nil
+	}
+
+	f, err := st.OpenEntry(ctx, entry)
+	if err != nil {
+		return false, err
+	}
+	doc, err := parser.Parse(f)
+	f.Close()
+	if err != nil {
+		return false, err
+	}
+
+	if !fn(doc) {
+		return false, nil
+	}
+
+	buf := &bytes.Buffer{}
+	if err := parser.Render(buf, doc); err != nil {
+		return false, err
+	}
+	if _, err := st.Save(ctx, entry, buf); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// rewriteReferences points references to renamed files at their new paths.
+// Returns true if any reference was changed
+func rewriteReferences(doc *html.Node, pagePath string, renamed map[string]string) bool {
+	// Saved pages link to each other by relative path, so resolving against
+	// the page's own path yields the target's path in the mirror
+	changed := false
+	parser.RewriteLinks(doc, mirrorBase+pagePath, func(absURL string) (string, bool) {
+		u, err := url.Parse(absURL)
+		if err != nil || !strings.HasPrefix(absURL, mirrorBase) {
+			return "", false
+		}
+
+		newPath, ok := renamed[strings.TrimPrefix(u.Path, "/")]
+		if !ok {
+			return "", false
+		}
+
+		changed = true
+		return storage.RelativePath(pagePath, newPath), true
+	})
+	return changed
+}
@@ -0,0 +1,239 @@
+package optimize
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/bmp"
+
+	"github.com/aldehir/ue2-docs/internal/manifest"
+	"github.com/aldehir/ue2-docs/internal/storage"
+)
+
+func testImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 4), uint8(y * 4), 128, 255})
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, level png.CompressionLevel) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	enc := png.Encoder{CompressionLevel: level}
+	if err := enc.Encode(buf, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// jpegWithComment returns a JPEG with a comment segment after SOI
+func jpegWithComment(t *testing.T, comment string) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := jpeg.Encode(buf, testImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	length := len(comment) + 2
+	segment := append([]byte{0xFF, 0xFE, byte(length >> 8), byte(length)}, comment...)
+	return append(append([]byte{0xFF, 0xD8}, segment...), data[2:]...)
+}
+
+func TestStripJPEG(t *testing.T) {
+	data := jpegWithComment(t, strings.Repeat("x", 500))
+
+	out, err := stripJPEG(data)
+	if err != nil {
+		t.Fatalf("stripJPEG() error = %v", err)
+	}
+	if len(out) != len(data)-504 {
+		t.Errorf("stripped %d bytes, want 504", len(data)-len(out))
+	}
+
+	// Image data must be untouched
+	want, _ := jpeg.Decode(bytes.NewReader(data))
+	got, err := jpeg.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("stripped JPEG does not decode: %v", err)
+	}
+	if got.At(10, 20) != want.At(10, 20) {
+		t.Error("stripped JPEG decodes differently")
+	}
+
+	if _, err := stripJPEG([]byte("not a jpeg")); err == nil {
+		t.Error("stripJPEG() expected error for non-JPEG input")
+	}
+}
+
+func TestRecompressPNG(t *testing.T) {
+	data := encodePNG(t, png.NoCompression)
+
+	out, err := recompressPNG(data)
+	if err != nil {
+		t.Fatalf("recompressPNG() error = %v", err)
+	}
+	if len(out) >= len(data) {
+		t.Errorf("recompressed PNG is %d bytes, original %d", len(out), len(data))
+	}
+
+	img, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("recompressed PNG does not decode: %v", err)
+	}
+	r1, g1, b1, _ := img.At(33, 7).RGBA()
+	r2, g2, b2, _ := testImage().At(33, 7).RGBA()
+	if r1 != r2 || g1 != g2 || b1 != b2 {
+		t.Error("recompressed PNG decodes differently")
+	}
+}
+
+func TestOptimizer_Run(t *testing.T) {
+	root := t.TempDir()
+	st := storage.New(root)
+
+	bmpData := &bytes.Buffer{}
+	if err := bmp.Encode(bmpData, testImage()); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{
+		"example.com/docs/Page.html":     []byte(`<html><body><img src="rsrc/shot.bmp"><a href="rsrc/shot.bmp#x">Full</a></body></html>`),
+		"example.com/docs/rsrc/shot.bmp": bmpData.Bytes(),
+		"example.com/docs/big.png":       encodePNG(t, png.NoCompression),
+		"example.com/docs/photo.jpg":     jpegWithComment(t, "Created with an image editor"),
+		"example.com/docs/small.png":     encodePNG(t, png.BestCompression),
+	}
+	for p, data := range files {
+		if _, err := st.Save(context.Background(), manifest.Entry{URL: "https://" + p, Path: p}, bytes.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.WriteManifest(); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := New(Config{Root: root, ConvertBMP: true}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if result.Optimized != 2 || result.Converted != 1 || result.Unchanged != 1 || result.Failed != 0 || result.Rewritten != 1 {
+		t.Errorf("Run() = %+v, want 2 optimized, 1 converted, 1 unchanged, 1 rewritten", result)
+	}
+	if result.BytesSaved <= 0 {
+		t.Errorf("BytesSaved = %d, want > 0", result.BytesSaved)
+	}
+
+	m, err := manifest.Load(filepath.Join(root, manifest.Filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Get("example.com/docs/rsrc/shot.bmp"); ok {
+		t.Error("converted BMP still in manifest")
+	}
+	converted, ok := m.Get("example.com/docs/rsrc/shot.png")
+	if !ok || converted.URL != "https://example.com/docs/rsrc/shot.bmp" || converted.ContentType != "image/png" {
+		t.Errorf("converted entry = %+v, %v", converted, ok)
+	}
+	if _, err := os.Stat(filepath.Join(root, "example.com/docs/rsrc/shot.bmp")); !os.IsNotExist(err) {
+		t.Error("converted BMP still on disk")
+	}
+
+	page, err := os.ReadFile(filepath.Join(root, "example.com/docs/Page.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`src="rsrc/shot.png"`, `href="rsrc/shot.png#x"`} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("page missing %s: %s", want, page)
+		}
+	}
+
+	photo, _ := m.Get("example.com/docs/photo.jpg")
+	if photo.Size != int64(len(files["example.com/docs/photo.jpg"])-32) {
+		t.Errorf("photo.jpg manifest size = %d, want comment stripped", photo.Size)
+	}
+}
+
+func TestOptimizer_Thumbnails(t *testing.T) {
+	root := t.TempDir()
+	st := storage.New(root)
+
+	files := map[string][]byte{
+		"example.com/docs/Gallery.html": []byte(`<html><body>
+<p><img src="rsrc/big.png" alt="Big"></p>
+<a href="Other.html"><img src="rsrc/big.png"></a>
+<img src="rsrc/icon.png">
+</body></html>`),
+		"example.com/docs/rsrc/big.png":  encodePNG(t, png.BestCompression),
+		"example.com/docs/rsrc/icon.png": encodePNG(t, png.BestCompression),
+	}
+	for p, data := range files {
+		if _, err := st.Save(context.Background(), manifest.Entry{URL: "https://" + p, Path: p}, bytes.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Only big.png exceeds the thumbnail width
+	small := &bytes.Buffer{}
+	png.Encode(small, image.NewRGBA(image.Rect(0, 0, 16, 16)))
+	st.Save(context.Background(), manifest.Entry{URL: "https://example.com/docs/rsrc/icon.png", Path: "example.com/docs/rsrc/icon.png"}, small)
+	if err := st.WriteManifest(); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := New(Config{Root: root, ThumbnailWidth: 32}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Thumbnails != 1 || result.Rewritten != 1 {
+		t.Errorf("Run() = %+v, want 1 thumbnail and 1 rewritten page", result)
+	}
+
+	thumbPath := "example.com/docs/rsrc/big.thumb.png"
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(thumbPath)))
+	if err != nil {
+		t.Fatalf("thumbnail not written: %v", err)
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width != 32 || cfg.Height != 32 {
+		t.Errorf("thumbnail is %dx%d (%v), want 32x32", cfg.Width, cfg.Height, err)
+	}
+
+	m, _ := manifest.Load(filepath.Join(root, manifest.Filename))
+	if e, ok := m.Get(thumbPath); !ok || e.Source != "example.com/docs/rsrc/big.png" {
+		t.Errorf("thumbnail manifest entry = %+v, %v", e, ok)
+	}
+
+	page, _ := os.ReadFile(filepath.Join(root, "example.com/docs/Gallery.html"))
+	for _, want := range []string{
+		`<a href="rsrc/big.png"><img src="rsrc/big.thumb.png" alt="Big"/></a>`,
+		`<a href="Other.html"><img src="rsrc/big.thumb.png"/></a>`,
+		`<img src="rsrc/icon.png"/>`,
+	} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("page missing %s:\n%s", want, page)
+		}
+	}
+
+	// A second pass finds nothing left to do
+	result, err = New(Config{Root: root, ThumbnailWidth: 32}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("second Run() error = %v", err)
+	}
+	if result.Thumbnails != 0 || result.Rewritten != 0 {
+		t.Errorf("second Run() = %+v, want no changes", result)
+	}
+}
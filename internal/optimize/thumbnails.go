@@ -0,0 +1,195 @@
+package optimize
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif" // Register GIF decoding
+	"image/jpeg"
+	"image/png"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/net/html"
+
+	"github.com/aldehir/ue2-docs/internal/manifest"
+	"github.com/aldehir/ue2-docs/internal/storage"
+)
+
+// thumbnailSuffix is inserted before the extension of generated thumbnails
+const thumbnailSuffix = ".thumb"
+
+// thumbnailer generates thumbnails for oversized inline images, reusing
+// thumbnails already generated for other pages
+type thumbnailer struct {
+	st       *storage.Storage
+	maxWidth int
+	made     map[string]string // Image path -> thumbnail path ("" = none needed)
+	result   *Result
+}
+
+// thumbnailPath returns the path of the thumbnail for an image. JPEGs keep
+// their format; everything else becomes PNG
+func thumbnailPath(imagePath string) string {
+	ext := path.Ext(imagePath)
+	base := strings.TrimSuffix(imagePath, ext) + thumbnailSuffix
+	switch strings.ToLower(ext) {
+	case ".jpg", ".jpeg":
+		return base + ext
+	default:
+		return base + ".png"
+	}
+}
+
+// isThumbnail reports whether a path is a generated thumbnail
+func isThumbnail(p string) bool {
+	return strings.HasSuffix(strings.TrimSuffix(p, path.Ext(p)), thumbnailSuffix)
+}
+
+// rewritePage replaces oversized inline images on a page with thumbnails
+// linking to the full image. Returns true if the page was changed
+func (t *thumbnailer) rewritePage(ctx context.Context, doc *html.Node, pagePath string) bool {
+	base, _ := url.Parse(mirrorBase + pagePath)
+	changed := false
+
+	var walk func(n *html.Node, inLink bool)
+	walk = func(n *html.Node, inLink bool) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "a":
+				inLink = true
+			case "img":
+				if t.replaceImage(ctx, n, base, pagePath, inLink) {
+					changed = true
+				}
+			}
+		}
+
+		for c := n.FirstChild; c != nil; {
+			next := c.NextSibling // replaceImage may wrap c in a link
+			walk(c, inLink)
+			c = next
+		}
+	}
+	walk(doc, false)
+
+	return changed
+}
+
+// replaceImage points an <img> at the thumbnail of its image, wrapping it in
+// a link to the full image unless it is already inside one
+func (t *thumbnailer) replaceImage(ctx context.Context, img *html.Node, base *url.URL, pagePath string, inLink bool) bool {
+	var src *html.Attribute
+	for i := range img.Attr {
+		if strings.EqualFold(img.Attr[i].Key, "src") {
+			src = &img.Attr[i]
+		}
+	}
+	if src == nil {
+		return false
+	}
+
+	ref, err := url.Parse(strings.TrimSpace(src.Val))
+	if err != nil {
+		return false
+	}
+	abs := base.ResolveReference(ref)
+	if abs.Host != base.Host {
+		return false
+	}
+	imagePath := strings.TrimPrefix(abs.Path, "/")
+
+	thumbPath, err := t.thumbnail(ctx, imagePath)
+	if err != nil || thumbPath == "" {
+		if err != nil {
+			t.result.Failed++
+		}
+		return false
+	}
+
+	src.Val = storage.RelativePath(pagePath, thumbPath)
+
+	if !inLink {
+		link := &html.Node{
+			Type: html.ElementNode,
+			Data: "a",
+			Attr: []html.Attribute{{Key: "href", Val: storage.RelativePath(pagePath, imagePath)}},
+		}
+		img.Parent.InsertBefore(link, img)
+		img.Parent.RemoveChild(img)
+		link.AppendChild(img)
+	}
+
+	return true
+}
+
+// thumbnail returns the path of the thumbnail for an image in the mirror,
+// generating it if needed. Returns "" for images small enough to show inline
+// and for paths that are not images in the mirror
+func (t *thumbnailer) thumbnail(ctx context.Context, imagePath string) (string, error) {
+	if thumbPath, ok := t.made[imagePath]; ok {
+		return thumbPath, nil
+	}
+	t.made[imagePath] = ""
+
+	if isThumbnail(imagePath) {
+		return "", nil
+	}
+	if _, ok := t.st.Manifest().Get(imagePath); !ok {
+		return "", nil
+	}
+
+	thumbPath := thumbnailPath(imagePath)
+	if _, ok := t.st.Manifest().Get(thumbPath); ok {
+		t.made[imagePath] = thumbPath
+		return thumbPath, nil
+	}
+
+	data, err := os.ReadFile(t.st.FullPath(imagePath))
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", imagePath, err)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width <= t.maxWidth {
+		// Not a decodable image, or already small enough
+		return "", nil
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("decoding %s: %w", imagePath, err)
+	}
+
+	height := max(1, cfg.Height*t.maxWidth/cfg.Width)
+	dst := image.NewRGBA(image.Rect(0, 0, t.maxWidth, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+
+	buf := &bytes.Buffer{}
+	contentType := "image/png"
+	if strings.HasSuffix(thumbPath, ".png") {
+		err = png.Encode(buf, dst)
+	} else {
+		contentType = "image/jpeg"
+		err = jpeg.Encode(buf, dst, &jpeg.Options{Quality: 85})
+	}
+	if err != nil {
+		return "", fmt.Errorf("encoding thumbnail for %s: %w", imagePath, err)
+	}
+
+	if _, err := t.st.Save(ctx, manifest.Entry{
+		Path:        thumbPath,
+		ContentType: contentType,
+		Source:      imagePath,
+	}, buf); err != nil {
+		return "", err
+	}
+
+	t.made[imagePath] = thumbPath
+	t.result.Thumbnails++
+	return thumbPath, nil
+}
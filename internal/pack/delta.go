@@ -0,0 +1,307 @@
+package pack
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DeltaFilename is the entry leading a delta archive that describes it:
+// the files it carries and those deleted since the base release. Being
+// hidden, it never collides with a file of the mirror
+const DeltaFilename = ".ue2-docs-delta.json"
+
+// DeltaInfo describes a delta archive
+type DeltaInfo struct {
+	Base    string            `json:"base"`   // SHA-256 of the base archive
+	Target  string            `json:"target"` // SHA-256 of the target archive
+	Files   map[string]string `json:"files"`  // SHA-256 of each file carried, by path
+	Deleted []string          `json:"deleted"`
+}
+
+// DeltaResult summarizes a delta archive
+type DeltaResult struct {
+	Files   int   // New and changed files carried
+	Deleted int   // Files of the base missing in the target
+	Bytes   int64 // Of the files carried
+	Size    int64 // Of the delta archive
+	SHA256  string
+}
+
+// Delta writes to output an archive taking a mirror packaged as the base
+// archive to the one packaged as the target: the target's files whose
+// content is new or changed, compared by SHA-256 regardless of their
+// times, and the list of files deleted. Paths in the delta are relative
+// to the mirror, without the archives' prefixes, so releases named apart
+// still compare. Like packages, deltas are reproducible
+func Delta(ctx context.Context, base, target, output string) (*DeltaResult, error) {
+	baseFiles, baseSum, err := hashArchive(ctx, base)
+	if err != nil {
+		return nil, err
+	}
+	targetFiles, targetSum, err := hashArchive(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	info := DeltaInfo{Base: baseSum, Target: targetSum, Files: map[string]string{}, Deleted: []string{}}
+	for name, sum := range targetFiles {
+		if baseFiles[name] != sum {
+			info.Files[name] = sum
+		}
+	}
+	for name := range baseFiles {
+		if _, ok := targetFiles[name]; !ok {
+			info.Deleted = append(info.Deleted, name)
+		}
+	}
+	sort.Strings(info.Deleted)
+
+	result := &DeltaResult{Files: len(info.Files), Deleted: len(info.Deleted)}
+	result.Size, result.SHA256, err = writeArchive(output, func(tw *tar.Writer) error {
+		data, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return err
+		}
+		hdr := &tar.Header{
+			Name:     DeltaFilename,
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			Size:     int64(len(data)),
+			ModTime:  time.Unix(0, 0),
+			Format:   tar.FormatPAX,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+
+		return readArchive(ctx, target, func(name string, hdr *tar.Header, r io.Reader) error {
+			if _, ok := info.Files[name]; !ok {
+				return nil
+			}
+			if err := tw.WriteHeader(&tar.Header{
+				Name:     name,
+				Typeflag: tar.TypeReg,
+				Mode:     0644,
+				Size:     hdr.Size,
+				ModTime:  hdr.ModTime,
+				Format:   tar.FormatPAX,
+			}); err != nil {
+				return err
+			}
+			n, err := io.Copy(tw, r)
+			result.Bytes += n
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ApplyResult summarizes a delta applied to a mirror
+type ApplyResult struct {
+	Written int // New and changed files written
+	Deleted int // Files deleted, not counting those already gone
+}
+
+// Apply applies a delta archive to the mirror in dir, unpacked from the
+// delta's base archive: it writes the files the delta carries, each
+// checked against its SHA-256 and renamed into place once complete, then
+// deletes the files the target no longer has, along with directories
+// left empty
+func Apply(ctx context.Context, delta, dir string) (*ApplyResult, error) {
+	var info *DeltaInfo
+	result := &ApplyResult{}
+	err := readArchive(ctx, delta, func(name string, hdr *tar.Header, r io.Reader) error {
+		if info == nil {
+			if name != DeltaFilename {
+				return fmt.Errorf("%s is not a delta archive: it does not start with %s", delta, DeltaFilename)
+			}
+			info = &DeltaInfo{}
+			if err := json.NewDecoder(r).Decode(info); err != nil {
+				return fmt.Errorf("reading %s: %w", DeltaFilename, err)
+			}
+			return nil
+		}
+
+		want, ok := info.Files[name]
+		if !ok {
+			return fmt.Errorf("%s is not listed in %s", name, DeltaFilename)
+		}
+		if err := applyFile(dir, name, want, hdr.ModTime, r); err != nil {
+			return err
+		}
+		result.Written++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, fmt.Errorf("%s is not a delta archive: it is empty", delta)
+	}
+	if result.Written != len(info.Files) {
+		return nil, fmt.Errorf("%s carries %d of the %d files it lists", delta, result.Written, len(info.Files))
+	}
+
+	for _, name := range info.Deleted {
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return nil, fmt.Errorf("unsafe path in delta: %s", name)
+		}
+		full := filepath.Join(dir, filepath.FromSlash(name))
+		err := os.Remove(full)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("deleting %s: %w", name, err)
+		}
+		result.Deleted++
+		removeEmptyDirs(dir, filepath.Dir(full))
+	}
+	return result, nil
+}
+
+// applyFile writes the contents of r to name under dir, dated modified,
+// failing unless their SHA-256 is want
+func applyFile(dir, name, want string, modified time.Time, r io.Reader) error {
+	if !filepath.IsLocal(filepath.FromSlash(name)) {
+		return fmt.Errorf("unsafe path in delta: %s", name)
+	}
+	full := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return fmt.Errorf("creating directory for %s: %w", name, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(full), ".tmp-"+filepath.Base(full)+"-*")
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", name, err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+		return fmt.Errorf("%s: SHA-256 %s, want %s", name, got, want)
+	}
+	if err := os.Chtimes(tmp.Name(), modified, modified); err != nil {
+		return fmt.Errorf("dating %s: %w", name, err)
+	}
+	if err := os.Rename(tmp.Name(), full); err != nil {
+		return fmt.Errorf("renaming %s: %w", name, err)
+	}
+	return nil
+}
+
+// removeEmptyDirs removes dir and its parents up to, but not including,
+// root while they are empty
+func removeEmptyDirs(root, dir string) {
+	root = filepath.Clean(root)
+	for dir != root && strings.HasPrefix(dir, root) {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// hashArchive returns the SHA-256 of every regular file in a package,
+// keyed by its path within the mirror, and of the archive itself
+func hashArchive(ctx context.Context, archive string) (map[string]string, string, error) {
+	files := map[string]string{}
+	err := readArchive(ctx, archive, func(name string, hdr *tar.Header, r io.Reader) error {
+		hash := sha256.New()
+		if _, err := io.Copy(hash, r); err != nil {
+			return err
+		}
+		files[name] = hex.EncodeToString(hash.Sum(nil))
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return nil, "", fmt.Errorf("reading %s: %w", archive, err)
+	}
+	return files, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// readArchive calls fn for every regular file of a .tar.gz archive in
+// order, named by its path without the archive's prefix directory. The
+// entries of delta archives, which have no prefix, are named as they are
+func readArchive(ctx context.Context, archive string, fn func(name string, hdr *tar.Header, r io.Reader) error) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", archive, err)
+	}
+	tr := tar.NewReader(gz)
+
+	delta := false
+	for first := true; ; first = false {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", archive, err)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if first && hdr.Name == DeltaFilename {
+			delta = true
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(hdr.Name)
+		if !delta {
+			_, name, _ = strings.Cut(name, "/")
+		}
+		if name == "" {
+			continue
+		}
+		if err := fn(name, hdr, tr); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package pack
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDeltaApply(t *testing.T) {
+	ctx := context.Background()
+	releases := t.TempDir()
+
+	base := writeMirror(t)
+	baseArchive := filepath.Join(releases, "ue2-docs-2026-09.tar.gz")
+	if _, err := New(Config{InputDir: base, Output: baseArchive}).Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// A month later: one page changed, one added, the logo gone and the
+	// sitemap rewritten with the same content
+	target := writeMirror(t)
+	os.WriteFile(filepath.Join(target, "index.html"), []byte("<html>new</html>"), 0644)
+	os.MkdirAll(filepath.Join(target, "Three"), 0755)
+	os.WriteFile(filepath.Join(target, "Three", "SiteMap.html"), []byte("<html>three</html>"), 0644)
+	os.RemoveAll(filepath.Join(target, "Two", "images"))
+	os.WriteFile(filepath.Join(target, "Two", "SiteMap.html"), []byte("<html>map</html>"), 0644)
+	targetArchive := filepath.Join(releases, "ue2-docs-2026-10.tar.gz")
+	if _, err := New(Config{InputDir: target, Output: targetArchive}).Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	delta := filepath.Join(releases, "delta.tar.gz")
+	result, err := Delta(ctx, baseArchive, targetArchive, delta)
+	if err != nil {
+		t.Fatalf("Delta() error = %v", err)
+	}
+	if result.Files != 2 || result.Deleted != 1 {
+		t.Errorf("Delta() = %+v, want 2 files and 1 deleted", result)
+	}
+	want := []string{DeltaFilename, "Three/SiteMap.html", "index.html"}
+	if got := archiveNames(t, delta); !reflect.DeepEqual(got, want) {
+		t.Errorf("delta entries = %v, want %v", got, want)
+	}
+
+	applied, err := Apply(ctx, delta, base)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if applied.Written != 2 || applied.Deleted != 1 {
+		t.Errorf("Apply() = %+v, want 2 written and 1 deleted", applied)
+	}
+	if _, err := os.Stat(filepath.Join(base, "Two", "images")); !os.IsNotExist(err) {
+		t.Error("directory emptied by deletions left behind")
+	}
+
+	// The updated mirror packages to the target's files
+	updated := filepath.Join(releases, "updated.tar.gz")
+	if _, err := New(Config{InputDir: base, Output: updated}).Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	got, _, err := hashArchive(ctx, updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFiles, _, err := hashArchive(ctx, targetArchive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, wantFiles) {
+		t.Errorf("updated mirror = %v, want %v", got, wantFiles)
+	}
+}
+
+func TestApply_NotDelta(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "ue2-docs.tar.gz")
+	if _, err := New(Config{InputDir: writeMirror(t), Output: archive}).Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if _, err := Apply(context.Background(), archive, t.TempDir()); err == nil {
+		t.Error("Apply() of a package succeeded, want an error")
+	}
+}
@@ -0,0 +1,206 @@
+// Package pack packages a mirror as a single compressed archive for
+// distribution. Archives are reproducible: packaging the same files, with
+// the same modification times, gives the same bytes, so checksums, CIDs and
+// torrents of a release can be recreated by anyone holding the mirror
+package pack
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Config holds packaging configuration
+type Config struct {
+	InputDir string // The mirror, or converted docs
+	Output   string // Archive to write, e.g. ue2-docs.tar.gz
+
+	// Prefix is the directory the files are placed under inside the
+	// archive; defaults to the archive's name without its extension
+	Prefix string
+}
+
+// Result summarizes a packaged archive
+type Result struct {
+	Files  int
+	Bytes  int64  // Of the files packaged
+	Size   int64  // Of the archive
+	SHA256 string // Of the archive
+}
+
+// Packager writes a directory to a .tar.gz archive
+type Packager struct {
+	config Config
+}
+
+// New creates a new Packager with the given configuration
+func New(config Config) *Packager {
+	if config.Prefix == "" {
+		config.Prefix = ArchiveName(config.Output)
+	}
+	return &Packager{config: config}
+}
+
+// ArchiveName returns the name of an archive without its directory and its
+// .tar.gz or .tgz extension
+func ArchiveName(archive string) string {
+	name := filepath.Base(archive)
+	for _, ext := range []string{".tar.gz", ".tgz"} {
+		if trimmed, ok := strings.CutSuffix(name, ext); ok {
+			return trimmed
+		}
+	}
+	return name
+}
+
+// Run packages every file under InputDir, skipping hidden files and
+// directories such as kept originals and repositories, which are not part
+// of a release. The archive is written to a temporary file and renamed into
+// place once complete
+func (p *Packager) Run(ctx context.Context) (*Result, error) {
+	result := &Result{}
+	var err error
+	result.Size, result.SHA256, err = writeArchive(p.config.Output, func(tw *tar.Writer) error {
+		return p.walk(ctx, func(src, rel string, info fs.FileInfo) error {
+			hdr := &tar.Header{
+				Name:    path.Join(p.config.Prefix, rel),
+				ModTime: info.ModTime().UTC().Truncate(time.Second),
+				Format:  tar.FormatPAX,
+			}
+			if info.IsDir() {
+				// Directory times only record when the mirror was written
+				hdr.Typeflag = tar.TypeDir
+				hdr.Name += "/"
+				hdr.Mode = 0755
+				hdr.ModTime = time.Unix(0, 0)
+				return tw.WriteHeader(hdr)
+			}
+			hdr.Typeflag = tar.TypeReg
+			hdr.Mode = 0644
+			hdr.Size = info.Size()
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			n, err := copyFile(tw, src)
+			if err != nil {
+				return fmt.Errorf("packaging %s: %w", rel, err)
+			}
+			result.Files++
+			result.Bytes += n
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// writeArchive writes a .tar.gz archive to output with fn, through a
+// temporary file renamed into place once complete, returning its size and
+// SHA-256
+func writeArchive(output string, fn func(tw *tar.Writer) error) (int64, string, error) {
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return 0, "", fmt.Errorf("creating directory for %s: %w", output, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(output), ".tmp-"+filepath.Base(output)+"-*")
+	if err != nil {
+		return 0, "", fmt.Errorf("creating %s: %w", output, err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	hash := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(tmp, hash)}
+	// A zero header leaves the name and time out of the gzip stream
+	gz := gzip.NewWriter(counter)
+	tw := tar.NewWriter(gz)
+
+	err = fn(tw)
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if err != nil {
+		tmp.Close()
+		return 0, "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, "", fmt.Errorf("writing %s: %w", output, err)
+	}
+	if err := os.Rename(tmp.Name(), output); err != nil {
+		return 0, "", fmt.Errorf("renaming %s: %w", output, err)
+	}
+	return counter.n, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// walk calls fn for every directory and regular file under InputDir in
+// lexical order, with paths relative to it, skipping hidden entries and
+// the archive itself
+func (p *Packager) walk(ctx context.Context, fn func(src, rel string, info fs.FileInfo) error) error {
+	output, _ := filepath.Abs(p.config.Output)
+	return filepath.WalkDir(p.config.InputDir, func(src string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(p.config.InputDir, src)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if abs, _ := filepath.Abs(src); abs == output || !(d.IsDir() || d.Type().IsRegular()) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(src, filepath.ToSlash(rel), info)
+	})
+}
+
+// copyFile copies the file src to w
+func copyFile(w io.Writer, src string) (int64, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return io.Copy(w, f)
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,130 @@
+package pack
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeMirror creates a small mirror with a hidden originals directory
+func writeMirror(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"manifest.json":         "{}",
+		"index.html":            "<html></html>",
+		"Two/SiteMap.html":      "<html>map</html>",
+		"Two/images/logo.png":   "png",
+		".originals/index.html": "original",
+		"Two/.hidden":           "hidden",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// archiveNames lists the entries of a .tar.gz archive
+func archiveNames(t *testing.T, archive string) []string {
+	t.Helper()
+	f, err := os.Open(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return names
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+}
+
+func TestPackager_Run(t *testing.T) {
+	mirror := writeMirror(t)
+	archive := filepath.Join(t.TempDir(), "ue2-docs-2026.tar.gz")
+
+	result, err := New(Config{InputDir: mirror, Output: archive}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Files != 4 {
+		t.Errorf("Files = %d, want 4", result.Files)
+	}
+	if info, _ := os.Stat(archive); info == nil || info.Size() != result.Size {
+		t.Errorf("Size = %d, does not match the archive", result.Size)
+	}
+
+	want := []string{
+		"ue2-docs-2026/Two/",
+		"ue2-docs-2026/Two/SiteMap.html",
+		"ue2-docs-2026/Two/images/",
+		"ue2-docs-2026/Two/images/logo.png",
+		"ue2-docs-2026/index.html",
+		"ue2-docs-2026/manifest.json",
+	}
+	if got := archiveNames(t, archive); !reflect.DeepEqual(got, want) {
+		t.Errorf("archive entries = %v, want %v", got, want)
+	}
+}
+
+func TestPackager_Reproducible(t *testing.T) {
+	mirror := writeMirror(t)
+	modified := time.Date(2004, 3, 1, 12, 0, 0, 0, time.UTC)
+	filepath.Walk(mirror, func(path string, info os.FileInfo, err error) error {
+		return os.Chtimes(path, modified, modified)
+	})
+
+	first, err := New(Config{InputDir: mirror, Output: filepath.Join(t.TempDir(), "a.tar.gz"), Prefix: "mirror"}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// Rewriting a directory changes its time, which must not matter
+	os.WriteFile(filepath.Join(mirror, "Two", "new.tmp"), nil, 0644)
+	os.Remove(filepath.Join(mirror, "Two", "new.tmp"))
+
+	second, err := New(Config{InputDir: mirror, Output: filepath.Join(t.TempDir(), "b.tar.gz"), Prefix: "mirror"}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if first.SHA256 != second.SHA256 {
+		t.Errorf("SHA256 differs between runs: %s and %s", first.SHA256, second.SHA256)
+	}
+}
+
+func TestArchiveName(t *testing.T) {
+	tests := []struct {
+		archive string
+		want    string
+	}{
+		{"ue2-docs.tar.gz", "ue2-docs"},
+		{"/releases/ue2-docs.tgz", "ue2-docs"},
+		{"ue2-docs.tar", "ue2-docs.tar"},
+	}
+	for _, tt := range tests {
+		if got := ArchiveName(tt.archive); got != tt.want {
+			t.Errorf("ArchiveName(%q) = %q, want %q", tt.archive, got, tt.want)
+		}
+	}
+}
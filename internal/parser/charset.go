@@ -0,0 +1,57 @@
+package parser
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// sniffLen is the number of bytes inspected when detecting an encoding
+const sniffLen = 1024
+
+// NewUTF8Reader returns a reader that decodes an HTML document to UTF-8
+//
+// The encoding is taken from the BOM, the Content-Type header value (if
+// any), or <meta> tags in the first 1KB of the document. Legacy pages that
+// declare nothing and are not valid UTF-8 are decoded as Windows-1252, as
+// browsers do.
+func NewUTF8Reader(r io.Reader, contentType string) (io.Reader, error) {
+	br := bufio.NewReaderSize(r, sniffLen)
+
+	peeked, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+
+	enc, name, _ := charset.DetermineEncoding(peeked, contentType)
+	if name == "utf-8" {
+		return br, nil
+	}
+
+	return enc.NewDecoder().Reader(br), nil
+}
+
+// SetUTF8Charset updates any charset declared in <meta> tags to UTF-8, so a
+// document decoded by NewUTF8Reader is labelled correctly when rendered
+func SetUTF8Charset(doc *html.Node) {
+	if doc.Type == html.ElementNode && doc.DataAtom == atom.Meta {
+		for i, attr := range doc.Attr {
+			switch strings.ToLower(attr.Key) {
+			case "charset":
+				doc.Attr[i].Val = "utf-8"
+			case "content":
+				if strings.Contains(strings.ToLower(attr.Val), "charset=") {
+					doc.Attr[i].Val = "text/html; charset=utf-8"
+				}
+			}
+		}
+	}
+
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		SetUTF8Charset(c)
+	}
+}
@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestNewUTF8Reader(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		want        string
+	}{
+		{
+			name: "utf-8 passes through",
+			body: []byte("<p>café “x”</p>"),
+			want: "<p>café “x”</p>",
+		},
+		{
+			name: "latin-1 meta is decoded as windows-1252",
+			body: []byte("<meta charset=\"iso-8859-1\"><p>caf\xe9 \x93x\x94</p>"),
+			want: "<meta charset=\"iso-8859-1\"><p>café “x”</p>",
+		},
+		{
+			name:        "content type header wins",
+			body:        []byte("<p>caf\xe9</p>"),
+			contentType: "text/html; charset=iso-8859-1",
+			want:        "<p>café</p>",
+		},
+		{
+			name: "undeclared invalid utf-8 falls back to windows-1252",
+			body: []byte("<p>\xa9 Epic</p>"),
+			want: "<p>© Epic</p>",
+		},
+		{
+			name: "empty document",
+			body: []byte{},
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewUTF8Reader(bytes.NewReader(tt.body), tt.contentType)
+			if err != nil {
+				t.Fatalf("NewUTF8Reader() error = %v", err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("reading decoded body: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("decoded = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRender_RelabelsCharset(t *testing.T) {
+	doc, err := Parse(strings.NewReader(`<html><head>
+<meta http-equiv="Content-Type" content="text/html; charset=iso-8859-1">
+<meta charset="windows-1252">
+<meta name="description" content="charset-free">
+</head><body></body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := Render(buf, doc); err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	out := buf.String()
+
+	if strings.Contains(out, "iso-8859-1") || strings.Contains(out, "windows-1252") {
+		t.Errorf("expected legacy charsets to be replaced:\n%s", out)
+	}
+	if !strings.Contains(out, `content="text/html; charset=utf-8"`) || !strings.Contains(out, `charset="utf-8"`) {
+		t.Errorf("expected utf-8 charset declarations:\n%s", out)
+	}
+	if !strings.Contains(out, `content="charset-free"`) {
+		t.Errorf("unrelated meta content should be untouched:\n%s", out)
+	}
+}
@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"fmt"
+
+	"golang.org/x/net/html"
+)
+
+// FormPolicy controls what happens to the forms of saved pages, whose
+// actions point at server-side scripts, such as TWiki's search, that a
+// mirror cannot run
+type FormPolicy int
+
+const (
+	// FormDisable keeps forms visible but removes their action and
+	// disables their controls
+	FormDisable FormPolicy = iota
+	// FormStrip removes forms entirely
+	FormStrip
+	// FormKeep leaves forms as served
+	FormKeep
+)
+
+// String returns the policy name as accepted by ParseFormPolicy
+func (p FormPolicy) String() string {
+	switch p {
+	case FormDisable:
+		return "disable"
+	case FormStrip:
+		return "strip"
+	case FormKeep:
+		return "keep"
+	default:
+		return fmt.Sprintf("FormPolicy(%d)", int(p))
+	}
+}
+
+// ParseFormPolicy parses "disable", "strip" or "keep"
+func ParseFormPolicy(s string) (FormPolicy, error) {
+	switch s {
+	case "disable":
+		return FormDisable, nil
+	case "strip":
+		return FormStrip, nil
+	case "keep":
+		return FormKeep, nil
+	default:
+		return 0, fmt.Errorf("unknown form policy %q (want disable, strip or keep)", s)
+	}
+}
+
+// formControls lists the elements disabled along with their form
+var formControls = map[string]bool{
+	"input": true, "button": true, "select": true, "textarea": true,
+}
+
+// FormActions returns the absolute URLs the forms of a document submit to,
+// resolved against baseURL, without duplicates. They are never crawled:
+// submitting a form runs a script rather than fetching a document
+func FormActions(doc *html.Node, baseURL string) []string {
+	var actions []string
+	seen := make(map[string]bool)
+	for _, form := range findAll(doc, "form") {
+		abs, _, ok := resolve(getAttr(form, "action"), baseURL)
+		if ok && !seen[abs] {
+			seen[abs] = true
+			actions = append(actions, abs)
+		}
+	}
+	return actions
+}
+
+// ApplyFormPolicy applies policy to the forms of a document, returning the
+// number of forms it changed
+func ApplyFormPolicy(doc *html.Node, policy FormPolicy) int {
+	if policy == FormKeep {
+		return 0
+	}
+
+	forms := findAll(doc, "form")
+	for _, form := range forms {
+		if policy == FormStrip {
+			form.Parent.RemoveChild(form)
+			continue
+		}
+
+		removeAttr(form, "action")
+		for _, control := range findAll(form, "") {
+			if formControls[control.Data] {
+				removeAttr(control, "disabled")
+				control.Attr = append(control.Attr, html.Attribute{Key: "disabled"})
+			}
+		}
+	}
+	return len(forms)
+}
+
+// findAll returns the elements named name under n, outermost first, or all
+// elements for ""; elements nested in a match are not returned
+func findAll(n *html.Node, name string) []*html.Node {
+	var found []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && (name == "" || c.Data == name) {
+			found = append(found, c)
+			if name != "" {
+				continue
+			}
+		}
+		found = append(found, findAll(c, name)...)
+	}
+	return found
+}
+
+// removeAttr removes an attribute from an element
+func removeAttr(n *html.Node, key string) {
+	attrs := n.Attr[:0]
+	for _, attr := range n.Attr {
+		if attr.Key != key {
+			attrs = append(attrs, attr)
+		}
+	}
+	n.Attr = attrs
+}
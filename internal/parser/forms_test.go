@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const formPage = `<html><body>
+<form name="jump" action="../bin/view/Two/WebHome"><input type="text" name="topic"></form>
+<p>Body</p>
+<form action="/twiki/bin/search/Two/" method="post"><input type="text" name="search"><button>Go</button></form>
+<form action="../bin/view/Two/WebHome"><select name="web"></select></form>
+</body></html>`
+
+func TestFormActions(t *testing.T) {
+	doc, err := Parse(strings.NewReader(formPage))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	got := FormActions(doc, "https://example.com/twiki/pub/Page.html")
+	want := []string{
+		"https://example.com/twiki/bin/view/Two/WebHome",
+		"https://example.com/twiki/bin/search/Two",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("FormActions() = %v, want %v", got, want)
+	}
+
+	// Form actions are reported, never crawled
+	for _, link := range ExtractLinks(doc, "https://example.com/twiki/pub/Page.html") {
+		if strings.Contains(link, "/bin/") {
+			t.Errorf("ExtractLinks() returned form action %s", link)
+		}
+	}
+}
+
+func TestApplyFormPolicy(t *testing.T) {
+	tests := []struct {
+		policy  FormPolicy
+		changed int
+		want    []string
+		notWant []string
+	}{
+		{
+			policy:  FormDisable,
+			changed: 3,
+			want:    []string{`<form name="jump">`, `<input type="text" name="topic" disabled=""/>`, `<button disabled="">`, `<select name="web" disabled="">`},
+			notWant: []string{"action="},
+		},
+		{
+			policy:  FormStrip,
+			changed: 3,
+			want:    []string{"<p>Body</p>"},
+			notWant: []string{"<form", "<input"},
+		},
+		{
+			policy:  FormKeep,
+			want:    []string{`action="../bin/view/Two/WebHome"`},
+			notWant: []string{"disabled"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(formPage))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			if got := ApplyFormPolicy(doc, tt.policy); got != tt.changed {
+				t.Errorf("ApplyFormPolicy() = %d, want %d", got, tt.changed)
+			}
+
+			buf := &bytes.Buffer{}
+			if err := Render(buf, doc); err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			out := buf.String()
+			for _, s := range tt.want {
+				if !strings.Contains(out, s) {
+					t.Errorf("output missing %s\n%s", s, out)
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(out, s) {
+					t.Errorf("output contains %s\n%s", s, out)
+				}
+			}
+		})
+	}
+}
+
+func TestParseFormPolicy(t *testing.T) {
+	for _, p := range []FormPolicy{FormDisable, FormStrip, FormKeep} {
+		got, err := ParseFormPolicy(p.String())
+		if err != nil || got != p {
+			t.Errorf("ParseFormPolicy(%q) = %v, %v", p.String(), got, err)
+		}
+	}
+
+	if _, err := ParseFormPolicy("remove"); err == nil {
+		t.Error("ParseFormPolicy(\"remove\") expected error")
+	}
+}
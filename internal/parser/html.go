@@ -0,0 +1,371 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"github.com/aldehir/ue2-docs/internal/urlutil"
+)
+
+// linkAttrs maps element names to the attributes that reference other resources
+var linkAttrs = map[string][]string{
+	"a":      {"href"},
+	"area":   {"href"}, // Regions of image maps
+	"link":   {"href"},
+	"img":    {"src"},
+	"script": {"src"},
+	"frame":  {"src"},
+	"iframe": {"src"},
+	"input":  {"src"},
+	"body":   {"background"},
+	"table":  {"background"},
+	"td":     {"background"},
+}
+
+// OriginalAttrPrefix starts the name of the attribute recording the URL a
+// rewritten reference had, followed by the rewritten attribute's name, as
+// in data-original-href
+const OriginalAttrPrefix = "data-original-"
+
+// RewriteFunc maps an absolute URL referenced by a document to its new value
+// Returns false to leave the reference untouched
+type RewriteFunc func(absURL string) (string, bool)
+
+// Parse parses an HTML document
+func Parse(r io.Reader) (*html.Node, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("parsing HTML: %w", err)
+	}
+	return doc, nil
+}
+
+// RefKind classifies how a document references a resource
+type RefKind int
+
+const (
+	RefAnchor RefKind = iota // Navigation: links, image map regions and <link> relations
+	RefAsset                 // Embedded: images, scripts and backgrounds
+	RefFrame                 // Pages shown inside the document by <frame> and <iframe>
+	RefCSS                   // Stylesheets
+)
+
+func (k RefKind) String() string {
+	switch k {
+	case RefAnchor:
+		return "anchor"
+	case RefAsset:
+		return "asset"
+	case RefFrame:
+		return "frame"
+	case RefCSS:
+		return "css"
+	}
+	return fmt.Sprintf("RefKind(%d)", int(k))
+}
+
+// Embedded reports whether the referenced resource is part of the
+// document rather than somewhere it leads
+func (k RefKind) Embedded() bool {
+	return k != RefAnchor
+}
+
+// Reference is a single reference to a resource in a document
+type Reference struct {
+	URL      string // Absolute, without fragment or query
+	Query    string // Query string the reference had, for URLs whose query matters
+	Kind     RefKind
+	Attr     string // Attribute holding the reference, e.g. "href"
+	Position int    // Index among the document's references, in document order
+	NoFollow bool   // Marked rel="nofollow"
+	External bool   // Marked rel="external" or with TWiki's externalLink class
+}
+
+// Link is a resource reference found in a document
+type Link struct {
+	URL      string
+	Kind     RefKind // Kind of the first reference to the URL
+	NoFollow bool    // Marked rel="nofollow"
+	External bool    // Marked rel="external" or with TWiki's externalLink class
+}
+
+// ExtractLinks returns the absolute URLs of all resources referenced by the
+// document, resolved against baseURL. Fragments are stripped and duplicates
+// removed, preserving document order
+func ExtractLinks(doc *html.Node, baseURL string) []string {
+	var links []string
+	for _, link := range ExtractLinkInfo(doc, baseURL) {
+		links = append(links, link.URL)
+	}
+	return links
+}
+
+// ExtractLinkInfo is like ExtractLinks but also reports how each link is
+// marked. A URL referenced more than once is only considered nofollow or
+// external if every reference to it is
+func ExtractLinkInfo(doc *html.Node, baseURL string) []Link {
+	return MergeLinks(ExtractReferences(doc, baseURL))
+}
+
+// MergeLinks turns a document's references into its links, one for each
+// URL, as ExtractLinkInfo does. Callers may change the URLs of the
+// references first, such as to keep the query of some
+func MergeLinks(refs []Reference) []Link {
+	var links []Link
+	index := make(map[string]int)
+
+	for _, ref := range refs {
+		if i, ok := index[ref.URL]; ok {
+			links[i].NoFollow = links[i].NoFollow && ref.NoFollow
+			links[i].External = links[i].External && ref.External
+			continue
+		}
+		index[ref.URL] = len(links)
+		links = append(links, Link{URL: ref.URL, Kind: ref.Kind, NoFollow: ref.NoFollow, External: ref.External})
+	}
+
+	return links
+}
+
+// ExtractReferences returns every resource reference in the document,
+// resolved against baseURL, in document order. Unlike ExtractLinks, a URL
+// referenced several times is returned once per reference
+func ExtractReferences(doc *html.Node, baseURL string) []Reference {
+	var refs []Reference
+	walkRefs(doc, func(n *html.Node, attr *html.Attribute) {
+		if ref, _, ok := reference(n, attr, baseURL); ok {
+			ref.Position = len(refs)
+			refs = append(refs, ref)
+		}
+	})
+	return refs
+}
+
+// reference describes the reference held by an attribute of n, returning
+// the fragment stripped from its URL alongside. A URL recorded by
+// RewriteLinksRecording is used in place of the attribute's rewritten
+// value. Returns false for references that do not resolve to a fetchable URL
+func reference(n *html.Node, attr *html.Attribute, baseURL string) (Reference, string, bool) {
+	val := attr.Val
+	if original := getAttr(n, OriginalAttrPrefix+attr.Key); original != "" {
+		val = original
+	}
+	abs, query, ok := resolve(val, baseURL)
+	if !ok {
+		return Reference{}, "", false
+	}
+	fragment := ""
+	if i := strings.Index(abs, "#"); i >= 0 {
+		fragment = abs[i:]
+	}
+
+	ref := Reference{
+		URL:   urlutil.StripFragment(abs),
+		Query: query,
+		Kind:  refKind(n),
+		Attr:  strings.ToLower(attr.Key),
+	}
+	if n.Data == "a" || n.Data == "area" || n.Data == "link" {
+		rel := strings.Fields(strings.ToLower(getAttr(n, "rel")))
+		ref.NoFollow = hasToken(rel, "nofollow")
+		ref.External = hasToken(rel, "external") ||
+			hasToken(strings.Fields(getAttr(n, "class")), "externalLink")
+	}
+	return ref, fragment, true
+}
+
+// refKind classifies the reference made by an element in linkAttrs
+func refKind(n *html.Node) RefKind {
+	switch n.Data {
+	case "a", "area":
+		return RefAnchor
+	case "link":
+		if hasToken(strings.Fields(strings.ToLower(getAttr(n, "rel"))), "stylesheet") {
+			return RefCSS
+		}
+		return RefAnchor
+	case "frame", "iframe":
+		return RefFrame
+	}
+	return RefAsset
+}
+
+// RewriteLinks rewrites every resource reference in the document using fn.
+// Fragments on the original reference are carried over to the rewritten value
+func RewriteLinks(doc *html.Node, baseURL string, fn RewriteFunc) {
+	RewriteReferences(doc, baseURL, func(ref Reference) (string, bool) {
+		return fn(ref.URL)
+	})
+}
+
+// RewriteLinksRecording is like RewriteLinks but also records the absolute
+// URL of every rewritten reference in an OriginalAttrPrefix attribute, so
+// the page's links can be resolved again later and wrong rewrites traced
+// back to the URL they came from
+func RewriteLinksRecording(doc *html.Node, baseURL string, fn RewriteFunc) {
+	rewriteRefs(doc, baseURL, true, func(ref Reference) (string, bool) {
+		return fn(ref.URL)
+	})
+}
+
+// RewriteReferences is like RewriteLinks but passes fn the whole
+// reference, so the rewrite can depend on its kind or query
+func RewriteReferences(doc *html.Node, baseURL string, fn func(ref Reference) (string, bool)) {
+	rewriteRefs(doc, baseURL, false, fn)
+}
+
+// RewriteReferencesRecording is like RewriteReferences, recording URLs as
+// RewriteLinksRecording does
+func RewriteReferencesRecording(doc *html.Node, baseURL string, fn func(ref Reference) (string, bool)) {
+	rewriteRefs(doc, baseURL, true, fn)
+}
+
+// rewriteRefs rewrites the references in doc with fn, recording the URL of
+// each rewritten one if record is set
+func rewriteRefs(doc *html.Node, baseURL string, record bool, fn func(ref Reference) (string, bool)) {
+	position := 0
+	walkRefs(doc, func(n *html.Node, attr *html.Attribute) {
+		ref, fragment, ok := reference(n, attr, baseURL)
+		if !ok {
+			return
+		}
+		ref.Position = position
+		position++
+
+		rewritten, ok := fn(ref)
+		if !ok {
+			return
+		}
+
+		attr.Val = rewritten + fragment
+		if record {
+			original := ref.URL
+			if ref.Query != "" {
+				original += "?" + ref.Query
+			}
+			setAttr(n, OriginalAttrPrefix+strings.ToLower(attr.Key), original+fragment)
+		}
+	})
+}
+
+// StripOriginals removes the attributes recorded by RewriteLinksRecording,
+// returning how many were removed
+func StripOriginals(doc *html.Node) int {
+	removed := 0
+	if doc.Type == html.ElementNode {
+		kept := doc.Attr[:0]
+		for _, attr := range doc.Attr {
+			if strings.HasPrefix(strings.ToLower(attr.Key), OriginalAttrPrefix) {
+				removed++
+				continue
+			}
+			kept = append(kept, attr)
+		}
+		doc.Attr = kept
+	}
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		removed += StripOriginals(c)
+	}
+	return removed
+}
+
+// Render writes the document as UTF-8 HTML, relabelling any declared charset
+func Render(w io.Writer, doc *html.Node) error {
+	SetUTF8Charset(doc)
+	if err := html.Render(w, doc); err != nil {
+		return fmt.Errorf("rendering HTML: %w", err)
+	}
+	return nil
+}
+
+// walkRefs calls fn for every attribute in the tree that references a resource
+func walkRefs(n *html.Node, fn func(n *html.Node, attr *html.Attribute)) {
+	if n.Type == html.ElementNode {
+		if attrs, ok := linkAttrs[n.Data]; ok {
+			for i := range n.Attr {
+				for _, name := range attrs {
+					if strings.EqualFold(n.Attr[i].Key, name) {
+						fn(n, &n.Attr[i])
+					}
+				}
+			}
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walkRefs(c, fn)
+	}
+}
+
+// getAttr returns the value of an attribute, or "" if it is not present
+func getAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if strings.EqualFold(attr.Key, key) {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// setAttr sets an attribute, adding it if it is not present
+func setAttr(n *html.Node, key, val string) {
+	for i := range n.Attr {
+		if strings.EqualFold(n.Attr[i].Key, key) {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: val})
+}
+
+// hasToken reports whether tokens contains token
+func hasToken(tokens []string, token string) bool {
+	for _, t := range tokens {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve resolves a reference to an absolute, normalized http(s) or ftp
+// URL, or file URL when the document itself was read from a file:// URL,
+// returning the query normalizing dropped alongside. Returns false for
+// empty references, in-page anchors, and non-fetchable schemes such as
+// mailto: and javascript:
+func resolve(ref, baseURL string) (string, string, bool) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(ref, "#") {
+		return "", "", false
+	}
+
+	abs, err := urlutil.Normalize(ref, baseURL)
+	if err != nil {
+		return "", "", false
+	}
+
+	u, err := url.Parse(abs)
+	if err != nil {
+		return "", "", false
+	}
+	switch u.Scheme {
+	case "http", "https", "ftp":
+	case "file":
+		// Web pages must never pull in local files
+		if !strings.HasPrefix(baseURL, "file:") {
+			return "", "", false
+		}
+	default:
+		return "", "", false
+	}
+
+	var query string
+	if r, err := url.Parse(ref); err == nil {
+		query = r.RawQuery
+	}
+	return abs, query, true
+}
@@ -0,0 +1,299 @@
+package parser
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const testPage = `<html><head>
+<link rel="stylesheet" href="../style.css">
+<script src="/js/app.js"></script>
+</head><body background="bg.gif">
+<a href="Other.html#section">Other</a>
+<a href="Other.html">Other again</a>
+<a href="#top">Top</a>
+<a href="mailto:someone@example.com">Mail</a>
+<a href="javascript:void(0)">JS</a>
+<a href="https://external.com/page.html?x=1">External</a>
+<img src="rsrc/shot.png" usemap="#shot">
+<map name="shot"><area shape="rect" coords="0,0,10,10" href="Toolbar.html#top" alt="Toolbar"></map>
+</body></html>`
+
+func TestExtractLinks(t *testing.T) {
+	doc, err := Parse(strings.NewReader(testPage))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	links := ExtractLinks(doc, "https://example.com/docs/Two/Page.html")
+
+	want := []string{
+		"https://example.com/docs/style.css",
+		"https://example.com/js/app.js",
+		"https://example.com/docs/Two/bg.gif",
+		"https://example.com/docs/Two/Other.html",
+		"https://external.com/page.html",
+		"https://example.com/docs/Two/rsrc/shot.png",
+		"https://example.com/docs/Two/Toolbar.html",
+	}
+
+	if len(links) != len(want) {
+		t.Fatalf("ExtractLinks() = %v, want %v", links, want)
+	}
+	for i := range want {
+		if links[i] != want[i] {
+			t.Errorf("links[%d] = %v, want %v", i, links[i], want[i])
+		}
+	}
+}
+
+func TestRewriteLinks(t *testing.T) {
+	doc, err := Parse(strings.NewReader(testPage))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	RewriteLinks(doc, "https://example.com/docs/Two/Page.html", func(absURL string) (string, bool) {
+		if strings.HasPrefix(absURL, "https://external.com/") {
+			return "", false
+		}
+		return "local:" + absURL[len("https://example.com/"):], true
+	})
+
+	buf := &bytes.Buffer{}
+	if err := Render(buf, doc); err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		`href="local:docs/style.css"`,
+		`src="local:js/app.js"`,
+		`href="local:docs/Two/Other.html#section"`,
+		`href="#top"`,
+		`href="https://external.com/page.html?x=1"`,
+		`src="local:docs/Two/rsrc/shot.png"`,
+		`href="local:docs/Two/Toolbar.html#top"`,
+		`usemap="#shot"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("rendered output missing %s\n%s", want, out)
+		}
+	}
+}
+
+func TestExtractLinkInfo(t *testing.T) {
+	doc, err := Parse(strings.NewReader(`<html><body>
+<a href="Plain.html">Plain</a>
+<a href="Spam.html" rel="NoFollow noopener">Spam</a>
+<a href="https://other.com/" rel="external">Other</a>
+<a href="https://twiki.org/" class="twikiLink externalLink">TWiki</a>
+<a href="Both.html" rel="nofollow">Both</a>
+<a href="Both.html">Both again</a>
+</body></html>`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	got := ExtractLinkInfo(doc, "https://example.com/docs/Page.html")
+	want := []Link{
+		{URL: "https://example.com/docs/Plain.html"},
+		{URL: "https://example.com/docs/Spam.html", NoFollow: true},
+		{URL: "https://other.com/", External: true},
+		{URL: "https://twiki.org/", External: true},
+		{URL: "https://example.com/docs/Both.html"},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("ExtractLinkInfo() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("link %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestExtractReferences(t *testing.T) {
+	doc, err := Parse(strings.NewReader(`<html><head>
+<link rel="stylesheet" href="style.css">
+<link rel="next" href="Next.html">
+</head><body background="bg.gif">
+<a href="Other.html#Top">Other</a>
+<img src="shot.png">
+<iframe src="Frame.html"></iframe>
+<a href="shot.png" rel="nofollow">Full size</a>
+</body></html>`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	got := ExtractReferences(doc, "https://example.com/docs/Page.html")
+	want := []Reference{
+		{URL: "https://example.com/docs/style.css", Kind: RefCSS, Attr: "href", Position: 0},
+		{URL: "https://example.com/docs/Next.html", Kind: RefAnchor, Attr: "href", Position: 1},
+		{URL: "https://example.com/docs/bg.gif", Kind: RefAsset, Attr: "background", Position: 2},
+		{URL: "https://example.com/docs/Other.html", Kind: RefAnchor, Attr: "href", Position: 3},
+		{URL: "https://example.com/docs/shot.png", Kind: RefAsset, Attr: "src", Position: 4},
+		{URL: "https://example.com/docs/Frame.html", Kind: RefFrame, Attr: "src", Position: 5},
+		{URL: "https://example.com/docs/shot.png", Kind: RefAnchor, Attr: "href", Position: 6, NoFollow: true},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("ExtractReferences() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("reference %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRewriteReferences(t *testing.T) {
+	doc, err := Parse(strings.NewReader(`<a href="Shot.png#x">Full size</a><img src="Shot.png">`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	// Only embedded copies point at the thumbnail
+	RewriteReferences(doc, "https://example.com/Page.html", func(ref Reference) (string, bool) {
+		if !ref.Kind.Embedded() {
+			return "", false
+		}
+		return "Shot-thumb.png", true
+	})
+
+	var out strings.Builder
+	if err := Render(&out, doc); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `<a href="Shot.png#x">`) || !strings.Contains(out.String(), `<img src="Shot-thumb.png"/>`) {
+		t.Errorf("rewritten = %s", out.String())
+	}
+}
+
+func TestRewriteLinksRecording(t *testing.T) {
+	doc, err := Parse(strings.NewReader(`<a href="Other.html#x">Other</a><img src="/shot.png"><a href="https://other.com/">Out</a>`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	rewrite := func(absURL string) (string, bool) {
+		if strings.HasPrefix(absURL, "https://other.com/") {
+			return "", false
+		}
+		return "local/" + absURL[len("https://example.com/"):], true
+	}
+	RewriteLinksRecording(doc, "https://example.com/docs/Page.html", rewrite)
+
+	var out strings.Builder
+	Render(&out, doc)
+	for _, want := range []string{
+		`<a href="local/docs/Other.html#x" data-original-href="https://example.com/docs/Other.html#x">`,
+		`<img src="local/shot.png" data-original-src="https://example.com/shot.png"/>`,
+		`<a href="https://other.com/">`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("rendered output missing %s\n%s", want, out.String())
+		}
+	}
+
+	// Rewritten links resolve from the recorded URL, not the local path
+	links := ExtractLinks(doc, "https://example.com/docs/Page.html")
+	if len(links) != 3 || links[0] != "https://example.com/docs/Other.html" {
+		t.Errorf("ExtractLinks() after rewriting = %v", links)
+	}
+
+	if n := StripOriginals(doc); n != 2 {
+		t.Errorf("StripOriginals() = %d, want 2", n)
+	}
+	out.Reset()
+	Render(&out, doc)
+	if strings.Contains(out.String(), OriginalAttrPrefix) {
+		t.Errorf("recorded URLs left after StripOriginals():\n%s", out.String())
+	}
+}
+
+func TestParseLinkPolicy(t *testing.T) {
+	for _, p := range []LinkPolicy{LinkRecord, LinkFollow, LinkIgnore} {
+		got, err := ParseLinkPolicy(p.String())
+		if err != nil || got != p {
+			t.Errorf("ParseLinkPolicy(%q) = %v, %v", p.String(), got, err)
+		}
+	}
+
+	if _, err := ParseLinkPolicy("skip"); err == nil {
+		t.Error("ParseLinkPolicy(\"skip\") expected error")
+	}
+}
+
+func TestExtractLinks_FileScheme(t *testing.T) {
+	doc, err := Parse(strings.NewReader(`<html><body>
+<a href="Other.html">Other</a>
+<a href="file:///etc/passwd">Local</a>
+</body></html>`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	links := ExtractLinks(doc, "file:///mnt/dump/Page.html")
+	if len(links) != 2 || links[0] != "file:///mnt/dump/Other.html" {
+		t.Errorf("ExtractLinks() from file page = %v", links)
+	}
+
+	// Web pages must not reach into the local filesystem
+	links = ExtractLinks(doc, "https://example.com/Page.html")
+	if len(links) != 1 || links[0] != "https://example.com/Other.html" {
+		t.Errorf("ExtractLinks() from web page = %v", links)
+	}
+}
+
+// benchmarkPage returns a page the size of a long reference page, with the
+// link density of the TWiki docs
+func benchmarkPage() []byte {
+	var sb strings.Builder
+	sb.WriteString(`<html><head><title>UnrealScript Reference</title><link rel="stylesheet" href="../style.css"></head><body>`)
+	for i := 0; i < 200; i++ {
+		sb.WriteString(`<h2><a name="Section` + strconv.Itoa(i) + `"></a>Section</h2>
+<p>See <a href="../Engine/Actor.html#Tick">Actor</a>, <a href="UnrealScriptReference.html#Functions">functions</a>
+and <a href="http://udn.epicgames.com/Two/WebHome" rel="nofollow">the home page</a>.</p>
+<table><tr><td><img src="rsrc/Two/Diagram.gif"></td><td><code>var() float Speed;</code></td></tr></table>
+`)
+	}
+	sb.WriteString(`</body></html>`)
+	return []byte(sb.String())
+}
+
+func BenchmarkExtractLinkInfo(b *testing.B) {
+	doc, err := Parse(bytes.NewReader(benchmarkPage()))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ExtractLinkInfo(doc, "https://udn.epicgames.com/Two/Script/UnrealScriptReference.html")
+	}
+}
+
+func BenchmarkParseRewriteRender(b *testing.B) {
+	page := benchmarkPage()
+
+	b.SetBytes(int64(len(page)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		doc, err := Parse(bytes.NewReader(page))
+		if err != nil {
+			b.Fatal(err)
+		}
+		RewriteLinks(doc, "https://udn.epicgames.com/Two/Script/UnrealScriptReference.html", func(absURL string) (string, bool) {
+			return "local.html", true
+		})
+		if err := Render(io.Discard, doc); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
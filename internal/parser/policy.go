@@ -0,0 +1,44 @@
+package parser
+
+import "fmt"
+
+// LinkPolicy controls how the crawler treats a class of links, such as
+// rel="nofollow" or external links
+type LinkPolicy int
+
+const (
+	// LinkRecord records the link in the link graph without enqueueing it
+	LinkRecord LinkPolicy = iota
+	// LinkFollow treats the link like any other
+	LinkFollow
+	// LinkIgnore drops the link entirely
+	LinkIgnore
+)
+
+// String returns the policy name as accepted by ParseLinkPolicy
+func (p LinkPolicy) String() string {
+	switch p {
+	case LinkRecord:
+		return "record"
+	case LinkFollow:
+		return "follow"
+	case LinkIgnore:
+		return "ignore"
+	default:
+		return fmt.Sprintf("LinkPolicy(%d)", int(p))
+	}
+}
+
+// ParseLinkPolicy parses "record", "follow" or "ignore"
+func ParseLinkPolicy(s string) (LinkPolicy, error) {
+	switch s {
+	case "record":
+		return LinkRecord, nil
+	case "follow":
+		return LinkFollow, nil
+	case "ignore":
+		return LinkIgnore, nil
+	default:
+		return 0, fmt.Errorf("unknown link policy %q (want record, follow or ignore)", s)
+	}
+}
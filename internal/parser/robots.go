@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Robots holds indexing directives from <meta name="robots"> tags and
+// X-Robots-Tag headers
+type Robots struct {
+	NoIndex  bool // Page should be left out of generated indexes and sitemaps
+	NoFollow bool // Links on the page should not be followed
+}
+
+// Merge combines two sets of directives; a restriction in either wins
+func (r Robots) Merge(other Robots) Robots {
+	return Robots{
+		NoIndex:  r.NoIndex || other.NoIndex,
+		NoFollow: r.NoFollow || other.NoFollow,
+	}
+}
+
+// ParseRobots parses a comma-separated directive list such as
+// "noindex, nofollow". Values scoped to a specific crawler with a
+// "botname:" prefix are ignored
+func ParseRobots(value string) Robots {
+	var r Robots
+
+	for _, directive := range strings.Split(value, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+
+		if i := strings.Index(directive, ":"); i >= 0 {
+			name := strings.TrimSpace(directive[:i])
+			if name != "noindex" && name != "nofollow" && name != "none" {
+				// "googlebot: noindex" applies to a single crawler
+				continue
+			}
+		}
+
+		switch directive {
+		case "noindex":
+			r.NoIndex = true
+		case "nofollow":
+			r.NoFollow = true
+		case "none":
+			r.NoIndex = true
+			r.NoFollow = true
+		}
+	}
+
+	return r
+}
+
+// HeaderRobots parses the values of X-Robots-Tag headers
+func HeaderRobots(values []string) Robots {
+	var r Robots
+	for _, v := range values {
+		r = r.Merge(ParseRobots(v))
+	}
+	return r
+}
+
+// MetaRobots returns the directives from <meta name="robots"> tags in the document
+func MetaRobots(doc *html.Node) Robots {
+	var r Robots
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "meta" {
+			var name, content string
+			for _, attr := range n.Attr {
+				switch strings.ToLower(attr.Key) {
+				case "name":
+					name = strings.ToLower(strings.TrimSpace(attr.Val))
+				case "content":
+					content = attr.Val
+				}
+			}
+			if name == "robots" {
+				r = r.Merge(ParseRobots(content))
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	return r
+}
@@ -0,0 +1,54 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRobots(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Robots
+	}{
+		{"", Robots{}},
+		{"index, follow", Robots{}},
+		{"noindex", Robots{NoIndex: true}},
+		{"NOFOLLOW", Robots{NoFollow: true}},
+		{"noindex, nofollow", Robots{NoIndex: true, NoFollow: true}},
+		{"none", Robots{NoIndex: true, NoFollow: true}},
+		{"googlebot: noindex", Robots{}},
+		{"noarchive, nofollow", Robots{NoFollow: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := ParseRobots(tt.value); got != tt.want {
+				t.Errorf("ParseRobots(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetaRobots(t *testing.T) {
+	doc, err := Parse(strings.NewReader(`<html><head>
+<meta name="description" content="noindex">
+<meta name="Robots" content="nofollow">
+<meta name="robots" content="noindex">
+</head><body></body></html>`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := Robots{NoIndex: true, NoFollow: true}
+	if got := MetaRobots(doc); got != want {
+		t.Errorf("MetaRobots() = %+v, want %+v", got, want)
+	}
+}
+
+func TestHeaderRobots(t *testing.T) {
+	got := HeaderRobots([]string{"noindex", "otherbot: nofollow"})
+	want := Robots{NoIndex: true}
+	if got != want {
+		t.Errorf("HeaderRobots() = %+v, want %+v", got, want)
+	}
+}
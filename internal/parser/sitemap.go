@@ -0,0 +1,223 @@
+package parser
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sitemap holds the locations listed in a sitemap.xml file: page URLs for a
+// <urlset>, or further sitemaps for a <sitemapindex>
+type Sitemap struct {
+	URLs     []string
+	Sitemaps []string
+}
+
+// ParseSitemap parses a sitemap or sitemap index in the sitemaps.org format
+func ParseSitemap(r io.Reader) (Sitemap, error) {
+	var doc struct {
+		XMLName  xml.Name
+		URLs     []string `xml:"url>loc"`
+		Sitemaps []string `xml:"sitemap>loc"`
+	}
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return Sitemap{}, fmt.Errorf("parsing sitemap: %w", err)
+	}
+
+	switch doc.XMLName.Local {
+	case "urlset", "sitemapindex":
+	default:
+		return Sitemap{}, fmt.Errorf("parsing sitemap: unexpected root element <%s>", doc.XMLName.Local)
+	}
+
+	var sitemap Sitemap
+	for _, loc := range doc.URLs {
+		if loc = strings.TrimSpace(loc); loc != "" {
+			sitemap.URLs = append(sitemap.URLs, loc)
+		}
+	}
+	for _, loc := range doc.Sitemaps {
+		if loc = strings.TrimSpace(loc); loc != "" {
+			sitemap.Sitemaps = append(sitemap.Sitemaps, loc)
+		}
+	}
+	return sitemap, nil
+}
+
+// RobotsSitemaps returns the sitemap URLs named by "Sitemap:" lines in a
+// robots.txt file
+func RobotsSitemaps(r io.Reader) []string {
+	var sitemaps []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		name, value, ok := strings.Cut(line, ":")
+		if !ok || !strings.EqualFold(strings.TrimSpace(name), "sitemap") {
+			continue
+		}
+		if value = strings.TrimSpace(value); value != "" {
+			sitemaps = append(sitemaps, value)
+		}
+	}
+	return sitemaps
+}
+
+// RobotsCrawlDelay returns the "Crawl-delay:" a robots.txt file asks of a
+// crawler identifying as userAgent: that of the groups naming the product
+// token of userAgent, regardless of case, or only if none does, that of
+// the "User-agent: *" groups. Reports false if those groups set none
+func RobotsCrawlDelay(r io.Reader, userAgent string) (time.Duration, bool) {
+	for _, line := range robotsGroupLines(r, userAgent) {
+		if line.name != "crawl-delay" {
+			continue
+		}
+		seconds, err := strconv.ParseFloat(line.value, 64)
+		if err != nil || seconds < 0 {
+			continue
+		}
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	return 0, false
+}
+
+// ProductToken returns the product name a User-Agent header starts with,
+// lowercased, such as "ue2-docs" for "UE2-Docs/1.0 (+https://...)". It
+// is what robots.txt "User-agent:" lines are matched against (RFC 9309)
+func ProductToken(userAgent string) string {
+	product, _, _ := strings.Cut(strings.TrimSpace(userAgent), " ")
+	product, _, _ = strings.Cut(product, "/")
+	return strings.ToLower(product)
+}
+
+// RobotsRules are the Allow and Disallow rules a robots.txt file sets for
+// one crawler
+type RobotsRules struct {
+	rules []robotsRule
+}
+
+// robotsRule is a single Allow or Disallow line
+type robotsRule struct {
+	pattern string // Path prefix, where "*" matches any run of characters and a final "$" the end
+	allow   bool
+}
+
+// ParseRobotsRules returns the Allow and Disallow rules a robots.txt file
+// sets for a crawler identifying as userAgent: those of the groups naming
+// its product token, or if none does, those of the "User-agent: *" groups
+func ParseRobotsRules(r io.Reader, userAgent string) RobotsRules {
+	var rules RobotsRules
+	for _, line := range robotsGroupLines(r, userAgent) {
+		if line.value == "" || (line.name != "allow" && line.name != "disallow") {
+			continue
+		}
+		rules.rules = append(rules.rules, robotsRule{pattern: line.value, allow: line.name == "allow"})
+	}
+	return rules
+}
+
+// Allowed reports whether the rules let the crawler fetch a URL, given its
+// escaped path and query. The longest matching rule decides, Allow winning
+// a tie; paths no rule matches, and /robots.txt itself, are allowed
+func (r RobotsRules) Allowed(path string) bool {
+	if path == "/robots.txt" {
+		return true
+	}
+	allowed, longest := true, -1
+	for _, rule := range r.rules {
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if n := len(rule.pattern); n > longest || (n == longest && rule.allow) {
+			allowed, longest = rule.allow, n
+		}
+	}
+	return allowed
+}
+
+// robotsMatch reports whether a robots.txt rule pattern matches path
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	if len(parts) == 1 {
+		return !anchored || rest == ""
+	}
+
+	// The leftmost match of each middle part leaves the most room for the
+	// parts after it
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	last := parts[len(parts)-1]
+	if anchored {
+		return strings.HasSuffix(rest, last)
+	}
+	return strings.Contains(rest, last)
+}
+
+// robotsLine is a "name: value" line of a robots.txt group, its name
+// lowercased
+type robotsLine struct {
+	name, value string
+}
+
+// robotsGroupLines returns the lines of the robots.txt groups that apply to
+// a crawler identifying as userAgent: the groups naming its product token,
+// regardless of case, or if none does, the "User-agent: *" groups (RFC
+// 9309)
+func robotsGroupLines(r io.Reader, userAgent string) []robotsLine {
+	product := ProductToken(userAgent)
+	var (
+		named, wildcard []robotsLine
+		hasNamed        bool // Some group names the product token
+		isNamed, isWild bool // The current group's User-agent lines
+		inRules         bool // A rule followed the group's User-agent lines
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		name, value = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(value)
+
+		if name == "user-agent" {
+			if inRules {
+				isNamed, isWild, inRules = false, false, false
+			}
+			agent := strings.ToLower(value)
+			isNamed = isNamed || (agent != "" && agent == product)
+			isWild = isWild || agent == "*"
+			hasNamed = hasNamed || isNamed
+			continue
+		}
+		inRules = true
+		switch {
+		case isNamed:
+			named = append(named, robotsLine{name, value})
+		case isWild:
+			wildcard = append(wildcard, robotsLine{name, value})
+		}
+	}
+
+	if hasNamed {
+		return named
+	}
+	return wildcard
+}
@@ -0,0 +1,168 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSitemap(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Sitemap
+		wantErr bool
+	}{
+		{
+			name: "urlset",
+			input: `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc> https://example.com/Two/WebHome.html </loc><lastmod>2004-06-01</lastmod></url>
+  <url><loc>https://example.com/Two/Karma.html</loc></url>
+  <url><loc></loc></url>
+</urlset>`,
+			want: Sitemap{URLs: []string{"https://example.com/Two/WebHome.html", "https://example.com/Two/Karma.html"}},
+		},
+		{
+			name: "index",
+			input: `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>https://example.com/sitemap-two.xml.gz</loc></sitemap>
+</sitemapindex>`,
+			want: Sitemap{Sitemaps: []string{"https://example.com/sitemap-two.xml.gz"}},
+		},
+		{
+			name:    "html error page",
+			input:   `<html><body>Not Found</body></html>`,
+			wantErr: true,
+		},
+		{
+			name:    "not xml",
+			input:   `User-agent: *`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSitemap(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSitemap() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSitemap() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRobotsSitemaps(t *testing.T) {
+	input := `User-agent: *
+Disallow: /cgi-bin/
+sitemap: https://example.com/sitemap-index.xml # all docs
+Sitemap:
+Sitemap: https://example.com/two.xml
+`
+	want := []string{"https://example.com/sitemap-index.xml", "https://example.com/two.xml"}
+	if got := RobotsSitemaps(strings.NewReader(input)); !reflect.DeepEqual(got, want) {
+		t.Errorf("RobotsSitemaps() = %v, want %v", got, want)
+	}
+}
+
+func TestRobotsCrawlDelay(t *testing.T) {
+	input := `User-agent: Googlebot
+Crawl-delay: 1
+
+User-agent: *
+Disallow: /cgi-bin/
+Crawl-delay: 10 # seconds
+
+User-agent: BadBot
+User-agent: UE2-Docs-Scraper
+Crawl-delay: 2.5
+`
+	tests := []struct {
+		name      string
+		input     string
+		userAgent string
+		want      time.Duration
+		ok        bool
+	}{
+		{"named group", input, "ue2-docs-scraper/1.0 (archival crawler)", 2500 * time.Millisecond, true},
+		{"named group without version", input, "UE2-Docs-Scraper", 2500 * time.Millisecond, true},
+		{"token inside another product", input, "MyBadBotClone/2.0", 10 * time.Second, true},
+		{"token in comment", input, "Mozilla/5.0 (compatible; Googlebot/2.1)", 10 * time.Second, true},
+		{"wildcard group", input, "Mozilla/5.0", 10 * time.Second, true},
+		{"no delay", "User-agent: *\nDisallow: /\n", "Mozilla/5.0", 0, false},
+		{"invalid delay", "User-agent: *\nCrawl-delay: soon\n", "Mozilla/5.0", 0, false},
+		{"named group without delay", "User-agent: ue2-docs-scraper\nDisallow: /private/\n\nUser-agent: *\nCrawl-delay: 10\n", "ue2-docs-scraper/1.0", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := RobotsCrawlDelay(strings.NewReader(tt.input), tt.userAgent)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("RobotsCrawlDelay() = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestProductToken(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{"UE2-Docs/1.0 (+https://example.com)", "ue2-docs"},
+		{"  curl/8.5.0", "curl"},
+		{"Googlebot", "googlebot"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := ProductToken(tt.userAgent); got != tt.want {
+			t.Errorf("ProductToken(%q) = %q, want %q", tt.userAgent, got, tt.want)
+		}
+	}
+}
+
+func TestRobotsRules(t *testing.T) {
+	input := `User-agent: *
+Disallow: /
+
+User-agent: UE2-Docs
+Disallow: /cgi-bin/
+Disallow: /*.php$
+Disallow: /private
+Allow: /private/docs/
+Disallow: /tmp/*/cache
+`
+	rules := ParseRobotsRules(strings.NewReader(input), "ue2-docs/1.0")
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/docs/Index.html", true},
+		{"/cgi-bin/search", false},
+		{"/index.php", false},
+		{"/index.php?page=2", true},
+		{"/private.html", false},
+		{"/private/docs/Guide.html", true},
+		{"/tmp/a/b/cache/x", false},
+		{"/tmp/cache", true},
+		{"/robots.txt", true},
+	}
+	for _, tt := range tests {
+		if got := rules.Allowed(tt.path); got != tt.want {
+			t.Errorf("Allowed(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	// Other crawlers fall back to the wildcard group
+	if ParseRobotsRules(strings.NewReader(input), "Mozilla/5.0").Allowed("/docs/Index.html") {
+		t.Error("wildcard group should disallow everything for other crawlers")
+	}
+	// A named group without Disallow rules allows everything
+	empty := "User-agent: ue2-docs\nCrawl-delay: 1\n\nUser-agent: *\nDisallow: /\n"
+	if !ParseRobotsRules(strings.NewReader(empty), "ue2-docs/1.0").Allowed("/docs/") {
+		t.Error("named group without rules should override the wildcard group")
+	}
+}
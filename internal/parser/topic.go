@@ -0,0 +1,68 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Topic holds the TWiki metadata printed in a page's header and footer
+type Topic struct {
+	Author   string // Wiki name of the user who last changed the topic
+	Revision string // Revision of the topic, e.g. "r1.4"
+	Parent   string // Name of the parent topic
+}
+
+var (
+	// revisionPattern matches footers such as
+	// "r1.4 - 05 Jun 2009 - 14:23 - Main.SomeUser"
+	revisionPattern = regexp.MustCompile(`\b(r\d+(?:\.\d+)*) - \d{1,2} [A-Z][a-z]{2} \d{4}(?: - \d{1,2}:\d{2}(?::\d{2})?(?: GMT)?)?(?: - (?:(?:Main|TWiki)\.)?([A-Z][A-Za-z0-9]*))?`)
+
+	// parentPattern matches "Parents: WebHome > SomeTopic" and "Topic
+	// parents: SomeTopic"
+	parentPattern = regexp.MustCompile(`(?i)\b(?:topic )?parents?: ((?:[A-Za-z0-9.]+ ?> ?)*[A-Za-z0-9.]+)`)
+)
+
+// TopicInfo extracts the TWiki topic metadata of a page. Fields are empty
+// when the page does not mention them
+func TopicInfo(doc *html.Node) Topic {
+	text := SpacedText(doc)
+
+	var topic Topic
+	if m := revisionPattern.FindStringSubmatch(text); m != nil {
+		topic.Revision = m[1]
+		topic.Author = m[2]
+	}
+	if m := parentPattern.FindStringSubmatch(text); m != nil {
+		// The breadcrumb lists every ancestor; the last is the parent
+		ancestors := strings.Split(m[1], ">")
+		parent := strings.TrimSpace(ancestors[len(ancestors)-1])
+		if i := strings.LastIndex(parent, "."); i >= 0 {
+			parent = parent[i+1:] // Web-qualified, e.g. "Two.WebHome"
+		}
+		topic.Parent = parent
+	}
+	return topic
+}
+
+// SpacedText returns the text of a node with runs of whitespace collapsed
+// to single spaces and a space between text nodes, so the text of adjacent
+// blocks does not run together
+func SpacedText(n *html.Node) string {
+	var words []string
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			words = append(words, strings.Fields(n.Data)...)
+		case n.Type == html.ElementNode && (n.Data == "script" || n.Data == "style"):
+			return
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			collect(child)
+		}
+	}
+	collect(n)
+	return strings.Join(words, " ")
+}
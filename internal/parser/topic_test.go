@@ -0,0 +1,57 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTopicInfo(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want Topic
+	}{
+		{
+			name: "footer with linked author",
+			html: `<p>Topic revision: r1.4 - 05 Jun 2009 - 14:23:47 - <a href="/twiki/bin/view/Main/SomeUser">Main.SomeUser</a></p>`,
+			want: Topic{Author: "SomeUser", Revision: "r1.4"},
+		},
+		{
+			name: "short footer",
+			html: `<div>r12 - 5 Jan 2004 - TWikiGuest</div>`,
+			want: Topic{Author: "TWikiGuest", Revision: "r12"},
+		},
+		{
+			name: "revision without author",
+			html: `<div>r1.1 - 12 Mar 2003</div>`,
+			want: Topic{Revision: "r1.1"},
+		},
+		{
+			name: "parents breadcrumb",
+			html: `<p>Parents: <a>WebHome</a> &gt; <a>Two.UnrealScript</a></p><p>r1.2 - 01 Feb 2002 - Main.A</p>`,
+			want: Topic{Author: "A", Revision: "r1.2", Parent: "UnrealScript"},
+		},
+		{
+			name: "topic parent",
+			html: `<p>Topic parents: WebHome</p>`,
+			want: Topic{Parent: "WebHome"},
+		},
+		{
+			name: "none",
+			html: `<p>Release 2.0 on 05 Jun 2009</p>`,
+			want: Topic{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(tt.html))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := TopicInfo(doc); got != tt.want {
+				t.Errorf("TopicInfo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+// Package probe asks for the status of a list of URLs, such as the old
+// links of a forum thread, without downloading their bodies
+package probe
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aldehir/ue2-docs/internal/fetcher"
+)
+
+// Config holds probe configuration
+type Config struct {
+	Workers int
+	Fetcher fetcher.Config // Set RateLimiter to go easy on the servers
+}
+
+// Result is the outcome of probing a single URL
+type Result struct {
+	URL         string `json:"url"`
+	FinalURL    string `json:"final_url,omitempty"` // Set if redirected
+	StatusCode  int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Size        int64  `json:"size"` // -1 if the server did not say
+	Error       string `json:"error,omitempty"`
+}
+
+// OK reports whether the URL resolved to a 2xx response
+func (r Result) OK() bool {
+	return r.Error == "" && r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// Prober probes URLs through a fetcher, with its rate limiting and retries
+type Prober struct {
+	config  Config
+	fetcher *fetcher.Fetcher
+}
+
+// New creates a new Prober with the given configuration
+func New(config Config) *Prober {
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+	return &Prober{
+		config:  config,
+		fetcher: fetcher.New(config.Fetcher),
+	}
+}
+
+// Probe asks for the status of url with a HEAD request, or a GET where
+// HEAD is refused
+func (p *Prober) Probe(ctx context.Context, url string) Result {
+	result := Result{URL: url, Size: -1}
+	resp, err := p.fetcher.Head(ctx, url)
+	if err != nil {
+		// A status answered is the outcome sought, not an error
+		if code := fetcher.StatusCode(err); code != 0 {
+			result.StatusCode = code
+		} else {
+			result.Error = err.Error()
+		}
+		return result
+	}
+
+	result.StatusCode = resp.StatusCode
+	result.ContentType = resp.ContentType
+	if resp.FinalURL != "" && resp.FinalURL != url {
+		result.FinalURL = resp.FinalURL
+	}
+	if n, err := strconv.ParseInt(resp.Headers.Get("Content-Length"), 10, 64); err == nil && n >= 0 {
+		result.Size = n
+	} else if resp.BytesWritten > 0 {
+		result.Size = resp.BytesWritten
+	}
+	return result
+}
+
+// ProbeAll probes every URL concurrently, returning results in the same
+// order as urls. URLs not probed before ctx is cancelled are omitted
+func (p *Prober) ProbeAll(ctx context.Context, urls []string) []Result {
+	results := make([]Result, len(urls))
+	probed := make([]bool, len(urls))
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < p.config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = p.Probe(ctx, urls[i])
+				probed[i] = ctx.Err() == nil
+			}
+		}()
+	}
+
+feed:
+	for i := range urls {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	var done []Result
+	for i, r := range results {
+		if probed[i] {
+			done = append(done, r)
+		}
+	}
+	return done
+}
+
+// ReadURLs reads a list of URLs, one per line, skipping blank lines and
+// comments starting with #
+func ReadURLs(r io.Reader) ([]string, error) {
+	var urls []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	return urls, scanner.Err()
+}
+
+// Summary counts results by status class
+type Summary struct {
+	OK      int // 2xx
+	Missing int // 404 and 410
+	Other   int // Any other status
+	Failed  int // No response at all
+}
+
+// Summarize counts results by status class
+func Summarize(results []Result) Summary {
+	var s Summary
+	for _, r := range results {
+		switch {
+		case r.Error != "":
+			s.Failed++
+		case r.OK():
+			s.OK++
+		case r.StatusCode == http.StatusNotFound || r.StatusCode == http.StatusGone:
+			s.Missing++
+		default:
+			s.Other++
+		}
+	}
+	return s
+}
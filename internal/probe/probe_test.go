@@ -0,0 +1,77 @@
+package probe
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aldehir/ue2-docs/internal/fetcher"
+)
+
+func TestProber_ProbeAll(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("Content-Length", "42")
+		case "/old.html":
+			http.Redirect(w, r, "/page.html", http.StatusMovedPermanently)
+		case "/gone.html":
+			w.WriteHeader(http.StatusGone)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	config := fetcher.DefaultConfig()
+	config.MaxRetries = 0
+	p := New(Config{Workers: 2, Fetcher: config})
+
+	urls := []string{
+		server.URL + "/page.html",
+		server.URL + "/old.html",
+		server.URL + "/gone.html",
+		server.URL + "/missing.html",
+		"http://127.0.0.1:1/unreachable",
+	}
+	results := p.ProbeAll(context.Background(), urls)
+	if len(results) != len(urls) {
+		t.Fatalf("got %d results, want %d", len(results), len(urls))
+	}
+	for i, r := range results {
+		if r.URL != urls[i] {
+			t.Errorf("result %d is for %s, want %s", i, r.URL, urls[i])
+		}
+	}
+
+	if r := results[0]; !r.OK() || r.ContentType != "text/html" || r.Size != 42 || r.FinalURL != "" {
+		t.Errorf("page: %+v", r)
+	}
+	if r := results[1]; r.StatusCode != http.StatusOK || r.FinalURL != server.URL+"/page.html" {
+		t.Errorf("redirect: %+v", r)
+	}
+	if r := results[2]; r.StatusCode != http.StatusGone || r.Error != "" || r.Size != -1 {
+		t.Errorf("gone: %+v", r)
+	}
+	if r := results[4]; r.Error == "" || r.StatusCode != 0 {
+		t.Errorf("unreachable: %+v", r)
+	}
+
+	want := Summary{OK: 2, Missing: 2, Failed: 1}
+	if got := Summarize(results); got != want {
+		t.Errorf("Summarize = %+v, want %+v", got, want)
+	}
+}
+
+func TestReadURLs(t *testing.T) {
+	urls, err := ReadURLs(strings.NewReader("# links from the thread\nhttp://a.example/\n\n  http://b.example/x  \n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(urls) != 2 || urls[0] != "http://a.example/" || urls[1] != "http://b.example/x" {
+		t.Errorf("ReadURLs = %q", urls)
+	}
+}
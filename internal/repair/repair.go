@@ -0,0 +1,181 @@
+// Package repair re-downloads the assets of an existing mirror that are
+// missing or damaged on disk, without crawling its pages again
+package repair
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/aldehir/ue2-docs/internal/fetcher"
+	"github.com/aldehir/ue2-docs/internal/manifest"
+	"github.com/aldehir/ue2-docs/internal/minisign"
+	"github.com/aldehir/ue2-docs/internal/storage"
+	"github.com/aldehir/ue2-docs/internal/urlutil"
+	"github.com/aldehir/ue2-docs/internal/verify"
+)
+
+// Config holds repair configuration
+type Config struct {
+	Root    string // Mirror directory containing manifest.json
+	Workers int
+	Fetcher fetcher.Config      // Set RateLimiter to go easy on the origin
+	SignKey *minisign.SecretKey // Signs the updated manifest; nil disables signing
+}
+
+// Result summarizes a repair pass
+type Result struct {
+	Checked  int
+	Missing  int // Listed in the manifest but absent from disk
+	Damaged  int // Present with the wrong size or checksum
+	Repaired int
+	Skipped  int // Broken pages and generated files, which cannot be re-downloaded as saved
+	Failed   int
+	Bytes    int64 // Bytes downloaded
+}
+
+// Repairer checks a mirror against its manifest and re-downloads broken assets
+type Repairer struct {
+	config  Config
+	fetcher *fetcher.Fetcher
+}
+
+// New creates a new Repairer with the given configuration
+func New(config Config) *Repairer {
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+	return &Repairer{
+		config:  config,
+		fetcher: fetcher.New(config.Fetcher),
+	}
+}
+
+// Run checks every file in the manifest and re-downloads the broken assets.
+// HTML pages are never fetched, since their saved copies have rewritten
+// links; broken pages are reported as skipped. The manifest is saved if
+// anything was repaired or it is to be signed
+func (r *Repairer) Run(ctx context.Context) (*Result, error) {
+	st, err := storage.Open(r.config.Root)
+	if err != nil {
+		return nil, err
+	}
+	st.SignWith(r.config.SignKey)
+	if st.Manifest().Len() == 0 {
+		return nil, fmt.Errorf("no manifest entries in %s", r.config.Root)
+	}
+	// Mirrors crawled from a file:// root are repaired from the same files
+	for _, e := range st.Manifest().Entries() {
+		if strings.HasPrefix(e.URL, "file:") {
+			config := r.config.Fetcher
+			config.LocalFiles = true
+			r.fetcher = fetcher.New(config)
+			break
+		}
+	}
+
+	result := &Result{}
+	var mu sync.Mutex
+
+	entries := make(chan manifest.Entry)
+	var wg sync.WaitGroup
+	for i := 0; i < r.config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range entries {
+				r.check(ctx, st, entry, result, &mu)
+			}
+		}()
+	}
+
+	for _, entry := range st.Manifest().Entries() {
+		select {
+		case entries <- entry:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(entries)
+	wg.Wait()
+
+	if result.Repaired > 0 || r.config.SignKey != nil {
+		if err := st.WriteManifest(); err != nil {
+			return result, err
+		}
+	}
+
+	return result, ctx.Err()
+}
+
+// check verifies a single file and re-downloads it if it is broken
+func (r *Repairer) check(ctx context.Context, st *storage.Storage, entry manifest.Entry, result *Result, mu *sync.Mutex) {
+	problem := verify.File(st, entry, false)
+
+	mu.Lock()
+	result.Checked++
+	switch {
+	case errors.Is(problem, os.ErrNotExist):
+		result.Missing++
+	case problem != nil:
+		result.Damaged++
+	}
+	mu.Unlock()
+
+	if problem == nil {
+		return
+	}
+
+	if !refetchable(entry) {
+		log.Printf("cannot repair %s: %v", entry.Path, problem)
+		mu.Lock()
+		result.Skipped++
+		mu.Unlock()
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	resp, err := r.fetcher.Fetch(ctx, entry.URL, buf)
+	if err == nil {
+		_, err = st.Save(ctx, entry, buf)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("failed to repair %s: %v", entry.Path, err)
+			result.Failed++
+		}
+		return
+	}
+	result.Repaired++
+	result.Bytes += resp.BytesWritten
+}
+
+// refetchable reports whether downloading an entry's URL reproduces the
+// saved file: not for pages, whose links were rewritten, nor for generated
+// or converted files
+func refetchable(entry manifest.Entry) bool {
+	if entry.URL == "" || entry.Source != "" {
+		return false
+	}
+	if urlutil.DetectResourceType(entry.URL, entry.ContentType) == urlutil.ResourceHTML {
+		return false
+	}
+
+	u, err := url.Parse(entry.URL)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(path.Ext(u.Path), path.Ext(entry.Path))
+}
@@ -0,0 +1,95 @@
+package repair
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aldehir/ue2-docs/internal/fetcher"
+	"github.com/aldehir/ue2-docs/internal/manifest"
+	"github.com/aldehir/ue2-docs/internal/storage"
+)
+
+func TestRepairer_Run(t *testing.T) {
+	content := map[string]string{
+		"/docs/intact.png":    "intact image",
+		"/docs/missing.png":   "missing image",
+		"/docs/truncated.css": "body { color: black; }",
+		"/docs/Page.html":     "<p>Page</p>",
+	}
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		body, ok := content[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	root := t.TempDir()
+	st := storage.New(root)
+	paths := make(map[string]string)
+	for urlPath, body := range content {
+		entry := manifest.Entry{URL: server.URL + urlPath}
+		entry.Path, _ = storage.PathFor(entry.URL)
+		if _, err := st.Save(context.Background(), entry, strings.NewReader(body)); err != nil {
+			t.Fatal(err)
+		}
+		paths[urlPath] = entry.Path
+	}
+	thumb := manifest.Entry{Path: "thumb.png", Source: paths["/docs/intact.png"]}
+	if _, err := st.Save(context.Background(), thumb, strings.NewReader("thumbnail")); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.WriteManifest(); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Remove(st.FullPath(paths["/docs/missing.png"]))
+	os.Remove(st.FullPath(paths["/docs/Page.html"]))
+	os.Remove(st.FullPath("thumb.png"))
+	os.WriteFile(st.FullPath(paths["/docs/truncated.css"]), []byte("body {"), 0644)
+
+	config := fetcher.DefaultConfig()
+	config.MaxRetries = 0
+	result, err := New(Config{Root: root, Workers: 2, Fetcher: config}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	want := Result{Checked: 5, Missing: 3, Damaged: 1, Repaired: 2, Skipped: 2, Bytes: int64(len(content["/docs/missing.png"]) + len(content["/docs/truncated.css"]))}
+	if *result != want {
+		t.Errorf("Run() = %+v, want %+v", *result, want)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("made %d requests, want only the 2 broken assets", requests.Load())
+	}
+
+	for _, urlPath := range []string{"/docs/missing.png", "/docs/truncated.css"} {
+		data, err := os.ReadFile(st.FullPath(paths[urlPath]))
+		if err != nil || string(data) != content[urlPath] {
+			t.Errorf("%s after repair = %q, %v", urlPath, data, err)
+		}
+	}
+
+	result, err = New(Config{Root: root, Fetcher: config}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("second Run() error = %v", err)
+	}
+	if result.Repaired != 0 || result.Missing != 2 {
+		t.Errorf("second Run() = %+v, want only the skipped files still missing", *result)
+	}
+}
+
+func TestRepairer_NoManifest(t *testing.T) {
+	if _, err := New(Config{Root: t.TempDir()}).Run(context.Background()); err == nil {
+		t.Error("expected error for a directory without a manifest")
+	}
+}
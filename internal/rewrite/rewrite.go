@@ -0,0 +1,278 @@
+// Package rewrite re-applies link rewriting to the pages of an existing
+// mirror, so improvements to the rewriter reach pages crawled before them
+// without crawling again
+package rewrite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/aldehir/ue2-docs/internal/diff"
+	"github.com/aldehir/ue2-docs/internal/manifest"
+	"github.com/aldehir/ue2-docs/internal/minisign"
+	"github.com/aldehir/ue2-docs/internal/parser"
+	"github.com/aldehir/ue2-docs/internal/storage"
+	"github.com/aldehir/ue2-docs/internal/urlutil"
+)
+
+// Config holds rewrite configuration
+type Config struct {
+	Root    string              // Mirror directory containing manifest.json
+	Pages   []string            // Storage paths of the pages to rewrite; empty = every page
+	DryRun  bool                // Report changes without writing them
+	Diff    io.Writer           // Receives a unified diff of every changed page; nil = no diffs
+	SignKey *minisign.SecretKey // Signs the updated manifest; nil disables signing
+
+	// Forms controls the forms of rewritten pages, as scraper.Config.Forms
+	// does for scraped ones, since originals keep theirs as served
+	Forms parser.FormPolicy
+
+	// Originals controls the data-original- attributes recording the URLs
+	// of rewritten links
+	Originals OriginalURLs
+}
+
+// OriginalURLs is what rewriting does with the attributes recording the
+// URLs of rewritten links. Links that have one are resolved from it either
+// way, so saved pages rewrite correctly without their originals
+type OriginalURLs int
+
+const (
+	OriginalsKeep   OriginalURLs = iota // Leave recorded URLs as they are, recording no new ones
+	OriginalsRecord                     // Record the URL of every rewritten link
+	OriginalsStrip                      // Remove recorded URLs, for a clean mirror
+)
+
+// Result summarizes a rewrite pass
+type Result struct {
+	Checked   int
+	Changed   int
+	Unchanged int
+	Failed    int
+}
+
+// Rewriter rewrites the links of saved pages to point at the local copies
+// listed in a mirror's manifest
+type Rewriter struct {
+	config Config
+}
+
+// New creates a new Rewriter with the given configuration
+func New(config Config) *Rewriter {
+	return &Rewriter{config: config}
+}
+
+// Run rewrites the configured pages. Each page is rewritten from the
+// unmodified copy kept under storage.OriginalDir when the mirror was
+// scraped with --keep-original, and from its saved copy otherwise. Links
+// to resources missing from the mirror are left as they are
+func (rw *Rewriter) Run(ctx context.Context) (*Result, error) {
+	st, err := storage.Open(rw.config.Root)
+	if err != nil {
+		return nil, err
+	}
+	st.SignWith(rw.config.SignKey)
+	if st.Manifest().Len() == 0 {
+		return nil, fmt.Errorf("no manifest entries in %s", rw.config.Root)
+	}
+
+	entries := st.Manifest().Entries()
+	paths := NewURLIndex(entries)
+
+	var pages []manifest.Entry
+	if len(rw.config.Pages) == 0 {
+		for _, e := range entries {
+			if isPage(e) {
+				pages = append(pages, e)
+			}
+		}
+	}
+	for _, p := range rw.config.Pages {
+		e, ok := st.Manifest().Get(p)
+		if !ok {
+			return nil, fmt.Errorf("%s is not in the manifest", p)
+		}
+		pages = append(pages, e)
+	}
+
+	result := &Result{}
+	for _, page := range pages {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		result.Checked++
+
+		changed, err := rw.page(ctx, st, page, paths)
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			log.Printf("failed to rewrite %s: %v", page.Path, err)
+			result.Failed++
+		case changed:
+			result.Changed++
+		default:
+			result.Unchanged++
+		}
+	}
+
+	if result.Changed > 0 && !rw.config.DryRun {
+		if err := st.WriteManifest(); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// page rewrites a single page, reporting whether its content changed
+func (rw *Rewriter) page(ctx context.Context, st *storage.Storage, page manifest.Entry, paths *URLIndex) (bool, error) {
+	saved, err := st.ReadEntry(ctx, page)
+	if err != nil {
+		return false, err
+	}
+
+	source := saved
+	original, err := os.ReadFile(st.FullPath(storage.OriginalDir + "/" + page.Path))
+	switch {
+	case err == nil:
+		source = original
+	case !errors.Is(err, os.ErrNotExist):
+		return false, err
+	}
+
+	rewritten, err := Page(source, page, paths, rw.config.Forms, rw.config.Originals)
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(saved, rewritten) {
+		return false, nil
+	}
+
+	if rw.config.Diff != nil {
+		if _, err := io.WriteString(rw.config.Diff, diff.Unified("a/"+page.Path, "b/"+page.Path, saved, rewritten)); err != nil {
+			return false, err
+		}
+	}
+	if rw.config.DryRun {
+		return true, nil
+	}
+
+	_, err = st.Save(ctx, page, bytes.NewReader(rewritten))
+	return true, err
+}
+
+// Page rewrites the links of a page saved as entry to relative paths,
+// using paths to map URLs to the storage paths of resources in the mirror,
+// applies forms to its forms and handles recorded URLs as originals says
+func Page(body []byte, entry manifest.Entry, paths *URLIndex, forms parser.FormPolicy, originals OriginalURLs) ([]byte, error) {
+	decoded, err := parser.NewUTF8Reader(bytes.NewReader(body), entry.ContentType)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parser.Parse(decoded)
+	if err != nil {
+		return nil, err
+	}
+
+	rewriteRefs := parser.RewriteReferences
+	if originals == OriginalsRecord {
+		rewriteRefs = parser.RewriteReferencesRecording
+	}
+	rewriteRefs(doc, entry.URL, func(ref parser.Reference) (string, bool) {
+		// Pages of mirrored snapshots may link into the archive
+		absURL := ref.URL
+		if _, original, ok := urlutil.ParseWayback(absURL); ok {
+			if normalized, err := urlutil.Normalize(original, ""); err == nil {
+				absURL = normalized
+			}
+		}
+
+		target, ok := paths.Path(absURL, ref.Query)
+		if !ok {
+			return "", false
+		}
+		return storage.RelativePath(entry.Path, target), true
+	})
+	parser.ApplyFormPolicy(doc, forms)
+	if originals == OriginalsStrip {
+		parser.StripOriginals(doc)
+	}
+
+	out := &bytes.Buffer{}
+	if err := parser.Render(out, doc); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// URLIndex maps the URLs of a mirror's resources to their storage paths
+type URLIndex struct {
+	paths map[string]string
+
+	// Query parameters kept on the URLs of the pages of paginated
+	// listings, by their URL without a query
+	params map[string][]string
+}
+
+// NewURLIndex indexes the URLs of a mirror's manifest entries. The
+// parameters a crawl kept on the URLs of paginated listings are learned
+// from the URLs themselves
+func NewURLIndex(entries []manifest.Entry) *URLIndex {
+	idx := &URLIndex{
+		paths:  make(map[string]string, len(entries)),
+		params: make(map[string][]string),
+	}
+	for _, e := range entries {
+		if e.URL == "" {
+			continue
+		}
+		idx.paths[e.URL] = e.Path
+
+		base, query, ok := strings.Cut(e.URL, "?")
+		if !ok {
+			continue
+		}
+		values, _ := url.ParseQuery(query)
+		for name := range values {
+			if !slices.Contains(idx.params[base], name) {
+				idx.params[base] = append(idx.params[base], name)
+			}
+		}
+	}
+	return idx
+}
+
+// Path returns the storage path of the resource at absURL, a URL without
+// a query, as a reference with the given query points at it
+func (idx *URLIndex) Path(absURL, query string) (string, bool) {
+	if params := idx.params[absURL]; query != "" && len(params) > 0 {
+		absURL = urlutil.CanonicalQuery(absURL, query, func(param string) bool {
+			return slices.Contains(params, param)
+		})
+	}
+	p, ok := idx.paths[absURL]
+	return p, ok
+}
+
+// isPage reports whether a manifest entry is a scraped HTML page
+func isPage(e manifest.Entry) bool {
+	if e.URL == "" || e.Source != "" {
+		return false
+	}
+	switch path.Ext(e.Path) {
+	case ".html", ".htm":
+		return true
+	}
+	return urlutil.DetectResourceType(e.URL, e.ContentType) == urlutil.ResourceHTML
+}
@@ -0,0 +1,170 @@
+package rewrite
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aldehir/ue2-docs/internal/manifest"
+	"github.com/aldehir/ue2-docs/internal/storage"
+)
+
+// newMirror saves a page whose original links to another page, an image
+// and a missing page, with a stale rewritten copy
+func newMirror(t *testing.T) string {
+	t.Helper()
+	ctx := context.Background()
+	st := storage.New(t.TempDir())
+
+	files := []struct {
+		entry   manifest.Entry
+		content string
+	}{
+		{manifest.Entry{URL: "https://example.com/docs/Page.html", Path: "example.com/docs/Page.html", ContentType: "text/html"},
+			`<html><head></head><body><a href="https://example.com/docs/Page.html">stale</a></body></html>`},
+		{manifest.Entry{URL: "https://example.com/docs/sub/Other.html", Path: "example.com/docs/sub/Other.html", ContentType: "text/html"},
+			`<html><head></head><body>Other</body></html>`},
+		{manifest.Entry{URL: "https://example.com/docs/img/logo.bmp", Path: "example.com/docs/img/logo.png", ContentType: "image/png"},
+			"PNG"},
+	}
+	for _, f := range files {
+		if _, err := st.Save(ctx, f.entry, strings.NewReader(f.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	original := `<html><head></head><body><a href="sub/Other.html#top">Other</a>
+<img src="/docs/img/logo.bmp">
+<a href="Missing.html">Missing</a></body></html>`
+	if err := st.SaveOriginal(ctx, "example.com/docs/Page.html", strings.NewReader(original)); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.WriteManifest(); err != nil {
+		t.Fatal(err)
+	}
+	return st.Root()
+}
+
+func TestRewriter_DryRun(t *testing.T) {
+	root := newMirror(t)
+	before, _ := os.ReadFile(root + "/example.com/docs/Page.html")
+
+	diff := &bytes.Buffer{}
+	result, err := New(Config{Root: root, DryRun: true, Diff: diff}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Checked != 2 || result.Changed != 1 || result.Unchanged != 1 {
+		t.Errorf("Run() = %+v, want 2 checked, 1 changed", result)
+	}
+
+	for _, want := range []string{
+		"--- a/example.com/docs/Page.html\n+++ b/example.com/docs/Page.html\n",
+		`-<html><head></head><body><a href="https://example.com/docs/Page.html">stale</a></body></html>`,
+		`+<html><head></head><body><a href="sub/Other.html#top">Other</a>`,
+		`+<img src="img/logo.png"/>`,
+		`+<a href="Missing.html">Missing</a></body></html>`,
+	} {
+		if !strings.Contains(diff.String(), want) {
+			t.Errorf("diff missing %q:\n%s", want, diff)
+		}
+	}
+
+	after, _ := os.ReadFile(root + "/example.com/docs/Page.html")
+	if !bytes.Equal(before, after) {
+		t.Errorf("dry run modified the page: %s", after)
+	}
+}
+
+func TestRewriter_Run(t *testing.T) {
+	root := newMirror(t)
+
+	result, err := New(Config{Root: root, Pages: []string{"example.com/docs/Page.html"}}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Checked != 1 || result.Changed != 1 {
+		t.Errorf("Run() = %+v, want 1 checked and changed", result)
+	}
+
+	data, _ := os.ReadFile(root + "/example.com/docs/Page.html")
+	if !strings.Contains(string(data), `<img src="img/logo.png"/>`) {
+		t.Errorf("page not rewritten: %s", data)
+	}
+
+	m, err := manifest.Load(root + "/" + manifest.Filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, _ := m.Get("example.com/docs/Page.html"); e.Size != int64(len(data)) {
+		t.Errorf("manifest size = %d, want %d", e.Size, len(data))
+	}
+
+	// Rewriting again changes nothing
+	result, err = New(Config{Root: root}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Changed != 0 {
+		t.Errorf("second Run() changed %d pages, want 0", result.Changed)
+	}
+}
+
+func TestRewriter_UnknownPage(t *testing.T) {
+	root := newMirror(t)
+	if _, err := New(Config{Root: root, Pages: []string{"example.com/nope.html"}}).Run(context.Background()); err == nil {
+		t.Error("Run() expected error for a page missing from the manifest")
+	}
+}
+
+func TestRewriter_Originals(t *testing.T) {
+	root := newMirror(t)
+	// Without the original, links can only be resolved again from the
+	// URLs recorded in the saved page
+	if err := os.RemoveAll(root + "/" + storage.OriginalDir); err != nil {
+		t.Fatal(err)
+	}
+	saved := `<html><head></head><body><a href="wrong/Other.html#top" data-original-href="https://example.com/docs/sub/Other.html#top">Other</a></body></html>`
+	if err := os.WriteFile(root+"/example.com/docs/Page.html", []byte(saved), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New(Config{Root: root, Originals: OriginalsRecord}).Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	data, _ := os.ReadFile(root + "/example.com/docs/Page.html")
+	if want := `<a href="sub/Other.html#top" data-original-href="https://example.com/docs/sub/Other.html#top">`; !strings.Contains(string(data), want) {
+		t.Errorf("page = %s, want %s", data, want)
+	}
+
+	if _, err := New(Config{Root: root, Originals: OriginalsStrip}).Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	data, _ = os.ReadFile(root + "/example.com/docs/Page.html")
+	if want := `<a href="sub/Other.html#top">`; !strings.Contains(string(data), want) {
+		t.Errorf("cleaned page = %s, want %s", data, want)
+	}
+}
+
+func TestURLIndex_Path(t *testing.T) {
+	idx := NewURLIndex([]manifest.Entry{
+		{URL: "https://example.com/bin/search/Two", Path: "example.com/bin/search/Two/index.html"},
+		{URL: "https://example.com/bin/search/Two?start=20", Path: "example.com/bin/search/Two/index_start=20.html"},
+		{URL: "https://example.com/docs/Page.html", Path: "example.com/docs/Page.html"},
+	})
+
+	tests := []struct {
+		url, query string
+		want       string
+	}{
+		{"https://example.com/bin/search/Two", "skin=print&start=20", "example.com/bin/search/Two/index_start=20.html"},
+		{"https://example.com/bin/search/Two", "skin=print", "example.com/bin/search/Two/index.html"},
+		{"https://example.com/docs/Page.html", "rev=2", "example.com/docs/Page.html"},
+	}
+	for _, tt := range tests {
+		if got, ok := idx.Path(tt.url, tt.query); !ok || got != tt.want {
+			t.Errorf("Path(%q, %q) = %q, %v; want %q", tt.url, tt.query, got, ok, tt.want)
+		}
+	}
+}
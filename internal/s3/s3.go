@@ -0,0 +1,369 @@
+// Package s3 is a minimal client for Amazon S3 and S3-compatible object
+// stores such as MinIO, covering what writing a mirror for static hosting
+// needs: uploading objects, in parts when large, reading and deleting them
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aldehir/ue2-docs/internal/fetcher"
+)
+
+// MinPartSize is the smallest part S3 accepts in a multipart upload, other
+// than the last
+const MinPartSize = 5 << 20
+
+// Config holds S3 client configuration
+type Config struct {
+	// Endpoint is the base URL of an S3-compatible service, e.g.
+	// http://localhost:9000 for MinIO, addressed with the bucket in the
+	// path. Empty = Amazon S3 in Region, with the bucket in the host name
+	Endpoint string
+	Region   string
+
+	AccessKey    string
+	SecretKey    string
+	SessionToken string // For temporary credentials; optional
+
+	// PartSize is the size of the parts of multipart uploads; objects no
+	// larger are uploaded in one request. Raised to MinPartSize if smaller
+	PartSize int64
+	// Concurrency caps the requests in flight at once across all uploads,
+	// and so the part buffers held in memory
+	Concurrency int
+
+	Timeout   time.Duration // Per request
+	UserAgent string
+}
+
+// DefaultConfig returns a configuration for Amazon S3 in us-east-1
+func DefaultConfig() Config {
+	return Config{
+		Region:      "us-east-1",
+		PartSize:    8 << 20,
+		Concurrency: 8,
+		Timeout:     5 * time.Minute,
+		UserAgent:   fetcher.ArchivalUserAgent(""),
+	}
+}
+
+// Client makes requests to an object store
+type Client struct {
+	config Config
+	client *http.Client
+	slots  chan struct{} // Holds a token for every request in flight
+	now    func() time.Time
+}
+
+// New creates a new Client with the given configuration
+func New(config Config) *Client {
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	if config.PartSize < MinPartSize {
+		config.PartSize = MinPartSize
+	}
+	if config.Concurrency < 1 {
+		config.Concurrency = 1
+	}
+	return &Client{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		slots:  make(chan struct{}, config.Concurrency),
+		now:    time.Now,
+	}
+}
+
+// Error is an error response from the object store
+type Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("%s: %s (HTTP %d)", e.Code, e.Message, e.StatusCode)
+}
+
+// Put uploads size bytes from r to key in bucket, in parts uploaded
+// concurrently when larger than the part size
+func (c *Client) Put(ctx context.Context, bucket, key string, r io.Reader, size int64, contentType string) error {
+	if size <= c.config.PartSize {
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return fmt.Errorf("reading %s: %w", key, err)
+		}
+		resp, err := c.do(ctx, http.MethodPut, bucket, key, nil, contentHeader(contentType), data)
+		if err != nil {
+			return fmt.Errorf("uploading %s: %w", key, err)
+		}
+		resp.Body.Close()
+		return nil
+	}
+	return c.putMultipart(ctx, bucket, key, r, contentType)
+}
+
+// contentHeader returns the headers giving an object's content type, if
+// known
+func contentHeader(contentType string) http.Header {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	return header
+}
+
+// part is an uploaded part of a multipart upload
+type part struct {
+	Number int    `xml:"PartNumber"`
+	ETag   string `xml:"ETag"`
+}
+
+// putMultipart uploads r in parts, aborting the upload if any part fails
+func (c *Client) putMultipart(ctx context.Context, bucket, key string, r io.Reader, contentType string) error {
+	resp, err := c.do(ctx, http.MethodPost, bucket, key, map[string]string{"uploads": ""}, contentHeader(contentType), nil)
+	if err != nil {
+		return fmt.Errorf("starting upload of %s: %w", key, err)
+	}
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&initiated)
+	resp.Body.Close()
+	if err != nil || initiated.UploadID == "" {
+		return fmt.Errorf("starting upload of %s: no upload ID in response", key)
+	}
+	uploadID := initiated.UploadID
+
+	parts, err := c.uploadParts(ctx, bucket, key, uploadID, r)
+	if err == nil {
+		err = c.complete(ctx, bucket, key, uploadID, parts)
+	}
+	if err != nil {
+		// Abandoned parts are billed until aborted
+		abortCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if resp, abortErr := c.do(abortCtx, http.MethodDelete, bucket, key, map[string]string{"uploadId": uploadID}, nil, nil); abortErr == nil {
+			resp.Body.Close()
+		}
+		return fmt.Errorf("uploading %s: %w", key, err)
+	}
+	return nil
+}
+
+// uploadParts reads r one part at a time, uploading each while the next is
+// read. A part is only read once a request slot is free, so memory use
+// stays within Concurrency parts
+func (c *Client) uploadParts(ctx context.Context, bucket, key, uploadID string, r io.Reader) ([]part, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		parts    []part
+		firstErr error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+		mu.Unlock()
+	}
+
+	for number := 1; ; number++ {
+		select {
+		case c.slots <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			if firstErr != nil {
+				return nil, firstErr
+			}
+			return nil, ctx.Err()
+		}
+
+		data := make([]byte, c.config.PartSize)
+		n, err := io.ReadFull(r, data)
+		if err == io.EOF && number > 1 {
+			<-c.slots
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			<-c.slots
+			fail(fmt.Errorf("reading part %d: %w", number, err))
+			break
+		}
+
+		wg.Add(1)
+		go func(number int, data []byte) {
+			defer wg.Done()
+			defer func() { <-c.slots }()
+			query := map[string]string{"partNumber": strconv.Itoa(number), "uploadId": uploadID}
+			resp, err := c.send(ctx, http.MethodPut, bucket, key, query, nil, data)
+			if err != nil {
+				fail(fmt.Errorf("part %d: %w", number, err))
+				return
+			}
+			resp.Body.Close()
+			mu.Lock()
+			parts = append(parts, part{Number: number, ETag: resp.Header.Get("ETag")})
+			mu.Unlock()
+		}(number, data[:n])
+
+		if n < len(data) {
+			break
+		}
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return parts, nil
+}
+
+// complete assembles the uploaded parts into the object
+func (c *Client) complete(ctx context.Context, bucket, key, uploadID string, parts []part) error {
+	// Parts finish in any order but must be listed in order
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []part   `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, bucket, key, map[string]string{"uploadId": uploadID}, nil, body)
+	if err != nil {
+		return fmt.Errorf("completing upload: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// A failure after the upload was accepted is reported in a 200 response
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("completing upload: %w", err)
+	}
+	if bytes.Contains(data, []byte("<Error>")) {
+		e := &Error{StatusCode: resp.StatusCode}
+		xml.Unmarshal(data, e)
+		return fmt.Errorf("completing upload: %w", e)
+	}
+	return nil
+}
+
+// Get returns the contents of key in bucket. A missing key is reported
+// with an error wrapping fs.ErrNotExist
+func (c *Client) Get(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodGet, bucket, key, nil, nil, nil)
+	if err != nil {
+		var e *Error
+		if errors.As(err, &e) && e.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("getting %s: %w", key, fs.ErrNotExist)
+		}
+		return nil, fmt.Errorf("getting %s: %w", key, err)
+	}
+	return resp.Body, nil
+}
+
+// Delete removes key from bucket; deleting a missing key succeeds
+func (c *Client) Delete(ctx context.Context, bucket, key string) error {
+	resp, err := c.do(ctx, http.MethodDelete, bucket, key, nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("deleting %s: %w", key, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do makes a request once a request slot is free
+func (c *Client) do(ctx context.Context, method, bucket, key string, query map[string]string, header http.Header, body []byte) (*http.Response, error) {
+	select {
+	case c.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-c.slots }()
+	return c.send(ctx, method, bucket, key, query, header, body)
+}
+
+// send makes a signed request, returning an *Error for error responses.
+// The caller must hold a request slot
+func (c *Client) send(ctx context.Context, method, bucket, key string, query map[string]string, header http.Header, body []byte) (*http.Response, error) {
+	u, err := c.objectURL(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if c.config.UserAgent != "" {
+		req.Header.Set("User-Agent", c.config.UserAgent)
+	}
+	payloadHash := emptyHash
+	if len(body) > 0 {
+		payloadHash = hashHex(body)
+	}
+	c.sign(req, payloadHash, c.now())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		e := &Error{StatusCode: resp.StatusCode}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		xml.Unmarshal(data, e)
+		return nil, e
+	}
+	return resp, nil
+}
+
+// objectURL returns the URL of key in bucket: virtual-hosted on Amazon S3,
+// unless the bucket name would break TLS there, and path-style otherwise
+func (c *Client) objectURL(bucket, key string) (*url.URL, error) {
+	objectPath := "/" + key
+	var u *url.URL
+	switch {
+	case c.config.Endpoint != "":
+		var err error
+		if u, err = url.Parse(strings.TrimSuffix(c.config.Endpoint, "/")); err != nil {
+			return nil, fmt.Errorf("invalid endpoint %q: %w", c.config.Endpoint, err)
+		}
+		objectPath = u.Path + "/" + bucket + objectPath
+	case strings.Contains(bucket, "."):
+		u = &url.URL{Scheme: "https", Host: "s3." + c.config.Region + ".amazonaws.com"}
+		objectPath = "/" + bucket + objectPath
+	default:
+		u = &url.URL{Scheme: "https", Host: bucket + ".s3." + c.config.Region + ".amazonaws.com"}
+	}
+	u.Path = objectPath
+	u.RawPath = uriEncode(objectPath, false)
+	return u, nil
+}
@@ -0,0 +1,149 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"sync"
+)
+
+// maxPooledBuffer is the largest buffer kept for reuse; bigger ones, from
+// the occasional huge download, are left to the garbage collector
+const maxPooledBuffer = 4 << 20
+
+// bufferPool hands out response buffers, recycling them through a
+// sync.Pool, and caps the bytes held by all of them at once. A write that
+// would take the total over the cap blocks until other buffers are
+// released, except in the oldest buffer, which always proceeds so workers
+// cannot deadlock each waiting on the others
+type bufferPool struct {
+	pool  sync.Pool
+	limit int64 // 0 = unlimited
+
+	mu      sync.Mutex
+	used    int64
+	next    uint64              // Sequence number of the next buffer
+	active  map[uint64]struct{} // Sequence numbers of unreleased buffers
+	changed chan struct{}       // Closed and replaced whenever bytes are released
+}
+
+func newBufferPool(limit int64) *bufferPool {
+	return &bufferPool{
+		limit:   limit,
+		active:  make(map[uint64]struct{}),
+		changed: make(chan struct{}),
+	}
+}
+
+// get returns an empty buffer whose writes wait for room under the cap
+// until ctx is cancelled. It must be released when its bytes are no longer
+// referenced
+func (p *bufferPool) get(ctx context.Context) *responseBuffer {
+	buf, _ := p.pool.Get().(*bytes.Buffer)
+	if buf == nil {
+		buf = &bytes.Buffer{}
+	}
+	buf.Reset()
+
+	p.mu.Lock()
+	seq := p.next
+	p.next++
+	p.active[seq] = struct{}{}
+	p.mu.Unlock()
+
+	return &responseBuffer{buf: buf, pool: p, seq: seq, ctx: ctx}
+}
+
+// inUse returns the bytes currently held by unreleased buffers
+func (p *bufferPool) inUse() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.used
+}
+
+// reserve accounts for n more bytes in b, waiting while they would exceed
+// the cap and b is not the oldest buffer
+func (p *bufferPool) reserve(b *responseBuffer, n int64) error {
+	for {
+		p.mu.Lock()
+		if p.limit <= 0 || p.used+n <= p.limit || p.isOldest(b.seq) {
+			p.used += n
+			b.held += n
+			p.mu.Unlock()
+			return nil
+		}
+		changed := p.changed
+		p.mu.Unlock()
+
+		select {
+		case <-b.ctx.Done():
+			return b.ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// isOldest reports whether seq is the oldest unreleased buffer; p.mu must
+// be held
+func (p *bufferPool) isOldest(seq uint64) bool {
+	for other := range p.active {
+		if other < seq {
+			return false
+		}
+	}
+	return true
+}
+
+// responseBuffer is a buffer from a bufferPool. It deliberately does not
+// implement io.ReaderFrom, so io.Copy cannot bypass the cap
+type responseBuffer struct {
+	buf  *bytes.Buffer
+	pool *bufferPool
+	seq  uint64
+	held int64
+	ctx  context.Context
+}
+
+func (b *responseBuffer) Write(p []byte) (int, error) {
+	if err := b.pool.reserve(b, int64(len(p))); err != nil {
+		return 0, err
+	}
+	return b.buf.Write(p)
+}
+
+// Reset empties the buffer, returning its bytes to the cap
+func (b *responseBuffer) Reset() {
+	p := b.pool
+	p.mu.Lock()
+	p.used -= b.held
+	b.held = 0
+	p.notify()
+	p.mu.Unlock()
+
+	b.buf.Reset()
+}
+
+// Bytes returns the buffered bytes, valid until the buffer is released
+func (b *responseBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+// notify wakes writers waiting for room; p.mu must be held
+func (p *bufferPool) notify() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// release returns the buffer to its pool, waking writers waiting for room
+func (b *responseBuffer) release() {
+	p := b.pool
+	p.mu.Lock()
+	p.used -= b.held
+	delete(p.active, b.seq)
+	p.notify()
+	p.mu.Unlock()
+
+	if b.buf.Cap() <= maxPooledBuffer {
+		p.pool.Put(b.buf)
+	}
+	b.buf = nil
+}
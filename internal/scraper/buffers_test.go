@@ -0,0 +1,79 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBufferPool_BlocksAtCap(t *testing.T) {
+	p := newBufferPool(10)
+	ctx := context.Background()
+
+	first := p.get(ctx)
+	second := p.get(ctx)
+
+	// The oldest buffer may exceed the cap so the crawl keeps moving
+	if _, err := first.Write(make([]byte, 12)); err != nil {
+		t.Fatalf("oldest buffer Write() error = %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := second.Write(make([]byte, 4))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("Write() over the cap returned %v without waiting", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	first.release()
+	if err := <-done; err != nil {
+		t.Fatalf("Write() after release error = %v", err)
+	}
+	if got := p.inUse(); got != 4 {
+		t.Errorf("inUse() = %d, want 4", got)
+	}
+	if string(second.Bytes()) != string(make([]byte, 4)) {
+		t.Errorf("Bytes() = %q", second.Bytes())
+	}
+
+	second.release()
+	if got := p.inUse(); got != 0 {
+		t.Errorf("inUse() after release = %d, want 0", got)
+	}
+}
+
+func TestBufferPool_Cancelled(t *testing.T) {
+	p := newBufferPool(4)
+	first := p.get(context.Background())
+	first.Write(make([]byte, 4))
+	defer first.release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	second := p.get(ctx)
+	defer second.release()
+	cancel()
+
+	if _, err := second.Write([]byte("x")); !errors.Is(err, context.Canceled) {
+		t.Errorf("Write() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestBufferPool_Unlimited(t *testing.T) {
+	p := newBufferPool(0)
+	for i := 0; i < 3; i++ {
+		b := p.get(context.Background())
+		defer b.release()
+		if _, err := b.Write(make([]byte, 1<<20)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if got := p.inUse(); got != 3<<20 {
+		t.Errorf("inUse() = %d, want %d", got, 3<<20)
+	}
+}
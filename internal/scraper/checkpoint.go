@@ -0,0 +1,131 @@
+package scraper
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aldehir/ue2-docs/internal/state"
+	"github.com/aldehir/ue2-docs/internal/storage"
+)
+
+// checkpointer flushes a crawl's state to disk while it runs: it syncs the
+// state file and writes the manifest, which is otherwise only written once
+// the crawl ends, so a crash loses at most one interval of progress. A
+// checkpoint is due every interval and after every pages saved pages,
+// whichever comes first
+type checkpointer struct {
+	interval time.Duration // 0 = not time based
+	pages    int64         // 0 = not page based
+	storage  *storage.Storage
+	state    *state.Writer // nil without a state file
+	logger   *slog.Logger
+
+	// mu is held while a checkpoint is written; one due meanwhile is
+	// skipped rather than queued behind it
+	mu      sync.Mutex
+	since   atomic.Int64   // Pages saved since the last checkpoint
+	written atomic.Int64   // Checkpoints written
+	pending sync.WaitGroup // Checkpoints due by pages, being written
+
+	stopped chan struct{} // Closed by stop
+	done    chan struct{} // Closed once timed checkpoints have stopped
+}
+
+// newCheckpointer creates a checkpointer, or returns nil if neither
+// interval nor pages is set
+func newCheckpointer(interval time.Duration, pages int, store *storage.Storage, w *state.Writer, logger *slog.Logger) *checkpointer {
+	if interval <= 0 && pages <= 0 {
+		return nil
+	}
+	return &checkpointer{
+		interval: interval,
+		pages:    int64(max(pages, 0)),
+		storage:  store,
+		state:    w,
+		logger:   logger,
+	}
+}
+
+// start writes a checkpoint every interval until stop is called
+func (c *checkpointer) start() {
+	if c == nil || c.interval <= 0 {
+		return
+	}
+	c.stopped = make(chan struct{})
+	c.done = make(chan struct{})
+	go func() {
+		defer close(c.done)
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stopped:
+				return
+			case <-ticker.C:
+				c.checkpoint()
+			}
+		}
+	}()
+}
+
+// stop stops timed checkpoints, waiting for any being written to finish
+func (c *checkpointer) stop() {
+	if c == nil {
+		return
+	}
+	if c.stopped != nil {
+		close(c.stopped)
+		<-c.done
+	}
+	c.pending.Wait()
+}
+
+// saved counts a saved page, writing a checkpoint if one is due. The
+// checkpoint is written in the background, so the worker saving the page
+// is not held up writing out a large manifest
+func (c *checkpointer) saved() {
+	if c == nil || c.pages <= 0 {
+		return
+	}
+	if c.since.Add(1) >= c.pages {
+		c.pending.Add(1)
+		go func() {
+			defer c.pending.Done()
+			c.checkpoint()
+		}()
+	}
+}
+
+// checkpoint syncs the state file and writes the manifest, unless another
+// checkpoint is already being written
+func (c *checkpointer) checkpoint() {
+	if !c.mu.TryLock() {
+		return
+	}
+	defer c.mu.Unlock()
+	c.since.Store(0)
+
+	start := time.Now()
+	if c.state != nil {
+		if err := c.state.Sync(); err != nil {
+			c.logger.Error("checkpointing crawl state", "error", err)
+		}
+	}
+	if err := c.storage.WriteManifest(); err != nil {
+		c.logger.Error("checkpointing manifest", "error", err)
+		return
+	}
+	c.written.Add(1)
+	c.logger.Debug("checkpoint written", "files", c.storage.Manifest().Len(),
+		"duration", time.Since(start).Round(time.Millisecond))
+}
+
+// count returns the checkpoints written
+func (c *checkpointer) count() int {
+	if c == nil {
+		return 0
+	}
+	return int(c.written.Load())
+}
@@ -0,0 +1,64 @@
+package scraper
+
+// Coverage counts how many of a list of URLs a crawl fetched
+type Coverage struct {
+	Total   int
+	Fetched int
+}
+
+// Ratio returns the fraction of the URLs fetched, or 1 for an empty list
+func (c Coverage) Ratio() float64 {
+	if c.Total == 0 {
+		return 1
+	}
+	return float64(c.Fetched) / float64(c.Total)
+}
+
+// Completeness estimates how much of a site a crawl mirrored by checking
+// independent lists of the URLs it should contain against those fetched
+type Completeness struct {
+	Discovered Coverage // URLs queued from links, sitemaps and the root
+	Sitemap    Coverage // In-scope URLs listed in sitemaps
+	RootLinks  Coverage // Resources followed from the root page, typically SiteMap.html
+}
+
+// Estimate returns the estimated fraction of the site mirrored: the lowest
+// coverage of the lists, since a gap in any of them is a gap in the mirror
+func (c Completeness) Estimate() float64 {
+	estimate := 1.0
+	for _, coverage := range []Coverage{c.Discovered, c.Sitemap, c.RootLinks} {
+		estimate = min(estimate, coverage.Ratio())
+	}
+	return estimate
+}
+
+// completeness measures the crawl against the URLs it queued, the URLs
+// listed in sitemaps, and the links on the root page
+func (s *Scraper) completeness() Completeness {
+	var c Completeness
+	c.Discovered = Coverage{Total: s.queue.Seen(), Fetched: int(s.fetched.Load())}
+	c.Sitemap = s.coverage(s.sitemapURLs)
+
+	seen := make(map[string]bool)
+	var links []string
+	for _, edge := range s.links.Outgoing(s.tracker.Canonical(s.config.RootURL)) {
+		if edge.Followed && !seen[edge.To] {
+			seen[edge.To] = true
+			links = append(links, edge.To)
+		}
+	}
+	c.RootLinks = s.coverage(links)
+
+	return c
+}
+
+// coverage counts how many of urls were fetched successfully
+func (s *Scraper) coverage(urls []string) Coverage {
+	c := Coverage{Total: len(urls)}
+	for _, u := range urls {
+		if _, ok := s.complete.Load(u); ok {
+			c.Fetched++
+		}
+	}
+	return c
+}
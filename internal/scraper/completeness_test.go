@@ -0,0 +1,25 @@
+package scraper
+
+import "testing"
+
+func TestCompleteness_Estimate(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Completeness
+		want float64
+	}{
+		{"empty", Completeness{}, 1},
+		{"complete", Completeness{Discovered: Coverage{10, 10}, Sitemap: Coverage{4, 4}}, 1},
+		{"failures", Completeness{Discovered: Coverage{10, 8}}, 0.8},
+		{"sitemap gap", Completeness{Discovered: Coverage{10, 10}, Sitemap: Coverage{20, 10}}, 0.5},
+		{"root links gap", Completeness{Discovered: Coverage{10, 9}, RootLinks: Coverage{4, 3}}, 0.75},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.c.Estimate(); got != tt.want {
+				t.Errorf("Estimate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package scraper
+
+import (
+	"errors"
+
+	"github.com/aldehir/ue2-docs/internal/fetcher"
+	"github.com/aldehir/ue2-docs/internal/state"
+)
+
+var (
+	// ErrContentTypeMismatch is recorded for resources whose Content-Type
+	// contradicts their extension; such resources are not saved
+	ErrContentTypeMismatch = errors.New("content type does not match extension")
+
+	// ErrFiltered is recorded for resources that left the crawl scope, such
+	// as an in-scope URL redirecting to another site; they are not saved
+	ErrFiltered = errors.New("outside crawl scope")
+
+	// ErrRobotsDisallowed is recorded for resources that robots.txt forbids
+	// fetching
+	ErrRobotsDisallowed = errors.New("disallowed by robots.txt")
+)
+
+// Cause classifies a failure for the crawl state file and report, returning
+// one of the state.Cause constants
+func Cause(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, fetcher.ErrClientStatus):
+		return state.CauseClientStatus
+	case errors.Is(err, fetcher.ErrServerStatus):
+		return state.CauseServerStatus
+	case errors.Is(err, fetcher.ErrTooLarge):
+		return state.CauseTooLarge
+	case errors.Is(err, fetcher.ErrBadEncoding):
+		return state.CauseBadEncoding
+	case errors.Is(err, fetcher.ErrRedirectLoop):
+		return state.CauseRedirectLoop
+	case errors.Is(err, ErrFiltered), errors.Is(err, fetcher.ErrRedirectScheme),
+		errors.Is(err, fetcher.ErrFTPArgument):
+		return state.CauseFiltered
+	case errors.Is(err, ErrRobotsDisallowed):
+		return state.CauseRobotsDisallowed
+	case errors.Is(err, ErrContentTypeMismatch):
+		return state.CauseContentTypeMismatch
+	}
+	return state.CauseOther
+}
@@ -0,0 +1,44 @@
+package scraper
+
+import (
+	"context"
+	"sync"
+)
+
+// flightGroup coalesces concurrent calls for the same key: the first call
+// runs and the others wait for it to finish, sharing whatever it recorded
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]chan struct{}
+}
+
+// do runs fn for key unless a call for key is already running, in which
+// case it waits for that call instead and reports shared. Waiting stops
+// early when ctx is cancelled
+func (g *flightGroup) do(ctx context.Context, key string, fn func()) (shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]chan struct{})
+	}
+	if done, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+		return true
+	}
+	done := make(chan struct{})
+	g.calls[key] = done
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(done)
+	}()
+
+	fn()
+	return false
+}
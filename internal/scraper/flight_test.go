@@ -0,0 +1,68 @@
+package scraper
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFlightGroup_Do(t *testing.T) {
+	var g flightGroup
+	ctx := context.Background()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	first := make(chan bool)
+	go func() {
+		first <- g.do(ctx, "a", func() {
+			close(started)
+			<-release
+		})
+	}()
+	<-started
+
+	// Calls for the same key wait for the running one; others run at once
+	ran := false
+	second := make(chan bool)
+	go func() { second <- g.do(ctx, "a", func() { ran = true }) }()
+	if shared := g.do(ctx, "b", func() {}); shared {
+		t.Error("do() for another key shared the running call")
+	}
+
+	select {
+	case <-second:
+		t.Fatal("do() returned while the call for its key was running")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	if shared := <-first; shared {
+		t.Error("first do() reported shared")
+	}
+	if shared := <-second; !shared || ran {
+		t.Errorf("second do() shared = %v, ran = %v; want shared without running", shared, ran)
+	}
+
+	// The key is free again once the call finishes
+	if shared := g.do(ctx, "a", func() {}); shared {
+		t.Error("do() after the call finished reported shared")
+	}
+}
+
+func TestFlightGroup_Cancelled(t *testing.T) {
+	var g flightGroup
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	go g.do(context.Background(), "a", func() {
+		close(started)
+		<-release
+	})
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if shared := g.do(ctx, "a", func() { t.Error("cancelled do() ran fn") }); !shared {
+		t.Error("cancelled do() did not report shared")
+	}
+}
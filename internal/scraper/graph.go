@@ -0,0 +1,81 @@
+package scraper
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/aldehir/ue2-docs/internal/parser"
+)
+
+// Edge is a link from one page to another resource
+type Edge struct {
+	From     string
+	To       string
+	Kind     parser.RefKind // Navigation link, or embedded asset, frame or stylesheet
+	NoFollow bool           // Marked rel="nofollow"
+	External bool           // Marked as an external link
+	Followed bool           // Whether the crawler enqueued the target
+}
+
+// LinkGraph records every link discovered during a crawl, including links
+// that were not followed, in a thread-safe manner
+type LinkGraph struct {
+	mu       sync.RWMutex
+	outgoing map[string][]Edge
+	incoming map[string][]Edge
+}
+
+// NewLinkGraph creates an empty link graph
+func NewLinkGraph() *LinkGraph {
+	return &LinkGraph{
+		outgoing: make(map[string][]Edge),
+		incoming: make(map[string][]Edge),
+	}
+}
+
+// Add records an edge
+func (g *LinkGraph) Add(e Edge) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.outgoing[e.From] = append(g.outgoing[e.From], e)
+	g.incoming[e.To] = append(g.incoming[e.To], e)
+}
+
+// Outgoing returns the links found on a page, in document order
+func (g *LinkGraph) Outgoing(from string) []Edge {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return append([]Edge(nil), g.outgoing[from]...)
+}
+
+// Incoming returns the links pointing at a URL
+func (g *LinkGraph) Incoming(to string) []Edge {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return append([]Edge(nil), g.incoming[to]...)
+}
+
+// Targets returns every URL that is linked to, sorted
+func (g *LinkGraph) Targets() []string {
+	g.mu.RLock()
+	targets := make([]string, 0, len(g.incoming))
+	for to := range g.incoming {
+		targets = append(targets, to)
+	}
+	g.mu.RUnlock()
+
+	sort.Strings(targets)
+	return targets
+}
+
+// Len returns the number of edges in the graph
+func (g *LinkGraph) Len() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	n := 0
+	for _, edges := range g.outgoing {
+		n += len(edges)
+	}
+	return n
+}
@@ -0,0 +1,29 @@
+package scraper
+
+import "testing"
+
+func TestLinkGraph(t *testing.T) {
+	g := NewLinkGraph()
+	g.Add(Edge{From: "a", To: "b", Followed: true})
+	g.Add(Edge{From: "a", To: "c", NoFollow: true})
+	g.Add(Edge{From: "b", To: "c", Followed: true})
+
+	if g.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", g.Len())
+	}
+
+	out := g.Outgoing("a")
+	if len(out) != 2 || out[0].To != "b" || out[1].To != "c" {
+		t.Errorf("Outgoing(a) = %+v", out)
+	}
+
+	in := g.Incoming("c")
+	if len(in) != 2 || in[0].From != "a" || in[1].From != "b" {
+		t.Errorf("Incoming(c) = %+v", in)
+	}
+
+	targets := g.Targets()
+	if len(targets) != 2 || targets[0] != "b" || targets[1] != "c" {
+		t.Errorf("Targets() = %v", targets)
+	}
+}
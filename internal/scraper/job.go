@@ -0,0 +1,29 @@
+package scraper
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+)
+
+// job is a queue item being processed by a worker
+type job struct {
+	*QueueItem
+
+	// trace identifies the fetch in the log and the crawl state file
+	trace string
+	// log carries the worker, trace, URL and referrer as fields
+	log *slog.Logger
+}
+
+// newJob starts processing an item with a new trace ID, logging to logger
+func newJob(item *QueueItem, logger *slog.Logger) *job {
+	trace := fmt.Sprintf("%016x", rand.Uint64())
+
+	logger = logger.With("trace", trace, "url", item.URL)
+	if item.Referrer != "" {
+		logger = logger.With("referrer", item.Referrer)
+	}
+
+	return &job{QueueItem: item, trace: trace, log: logger}
+}
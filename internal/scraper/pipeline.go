@@ -0,0 +1,189 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/aldehir/ue2-docs/internal/fetcher"
+	"github.com/aldehir/ue2-docs/internal/hooks"
+	"github.com/aldehir/ue2-docs/internal/manifest"
+	"github.com/aldehir/ue2-docs/internal/metrics"
+	"github.com/aldehir/ue2-docs/internal/parser"
+	"github.com/aldehir/ue2-docs/internal/storage"
+	"github.com/aldehir/ue2-docs/internal/urlutil"
+)
+
+// page is a fetched resource passed from the fetch workers to the parse
+// workers, if it is a page, and on to the write workers
+type page struct {
+	*job
+	resp    *fetcher.Response
+	saveURL string
+	relPath string
+	robots  parser.Robots
+	topic   parser.Topic
+
+	body []byte               // Page body, rewritten once parsed
+	buf  *responseBuffer      // Holds the fetched body of a page until parsed
+	file *storage.PendingFile // Holds a resource streamed to disk
+}
+
+// release frees the memory or temporary file still held by a page
+func (p *page) release() {
+	if p.buf != nil {
+		p.buf.release()
+		p.buf = nil
+	}
+	if p.file != nil {
+		p.file.Discard()
+		p.file = nil
+	}
+}
+
+// pipeline runs the parse and write stages, each with its own pool of
+// workers, until the fetch workers are done
+type pipeline struct {
+	parses chan *page
+	writes chan *page
+
+	parsers sync.WaitGroup
+	writers sync.WaitGroup
+}
+
+// startPipeline starts the parse and write workers
+func (s *Scraper) startPipeline(ctx context.Context) *pipeline {
+	p := &pipeline{
+		parses: make(chan *page, s.config.ParseWorkers),
+		writes: make(chan *page, s.config.WriteWorkers),
+	}
+
+	for i := 0; i < s.config.ParseWorkers; i++ {
+		p.parsers.Add(1)
+		go func() {
+			defer p.parsers.Done()
+			for pg := range p.parses {
+				s.parse(ctx, p, pg)
+			}
+		}()
+	}
+	for i := 0; i < s.config.WriteWorkers; i++ {
+		p.writers.Add(1)
+		go func() {
+			defer p.writers.Done()
+			for pg := range p.writes {
+				s.write(ctx, pg)
+			}
+		}()
+	}
+
+	return p
+}
+
+// wait drains the stages once the fetch workers are done
+func (p *pipeline) wait() {
+	close(p.parses)
+	p.parsers.Wait()
+	close(p.writes)
+	p.writers.Wait()
+}
+
+// send passes a page to the next stage, reporting false if ctx was
+// cancelled first
+func send(ctx context.Context, stage chan<- *page, pg *page) bool {
+	select {
+	case stage <- pg:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// parse enqueues the links of a page and rewrites them, passing the page
+// on to be written
+func (s *Scraper) parse(ctx context.Context, p *pipeline, pg *page) {
+	if s.config.KeepOriginal {
+		if err := s.storage.SaveOriginal(ctx, pg.relPath, bytes.NewReader(pg.body)); err != nil && ctx.Err() == nil {
+			pg.log.Warn("saving original", "error", err)
+		}
+	}
+
+	body, robots, topic, err := s.processHTML(pg.QueueItem, pg.saveURL, pg.relPath, pg.body, pg.resp.ContentType, pg.robots)
+	// The rewritten body is a copy; free the buffer for other fetches
+	pg.release()
+	if err != nil {
+		s.recordFailure(pg.job, err)
+		s.finish(pg.QueueItem)
+		return
+	}
+	pg.body, pg.robots, pg.topic = body, robots, topic
+
+	if !send(ctx, p.writes, pg) {
+		s.finish(pg.QueueItem)
+	}
+}
+
+// write saves a resource to storage, finishing its item
+func (s *Scraper) write(ctx context.Context, pg *page) {
+	defer s.finish(pg.QueueItem)
+	defer pg.release()
+
+	entry := manifest.Entry{
+		URL:         pg.saveURL,
+		Path:        pg.relPath,
+		ContentType: pg.resp.ContentType,
+		NoIndex:     pg.robots.NoIndex && !s.config.IgnoreRobotsMeta,
+		Version:     s.config.Version,
+		Author:      pg.topic.Author,
+		Revision:    pg.topic.Revision,
+		Parent:      pg.topic.Parent,
+	}
+	if modified, err := http.ParseTime(pg.resp.Headers.Get("Last-Modified")); err == nil {
+		entry.LastModified = modified.UTC()
+	}
+	if s.config.CompressHTML && pg.resp.ResourceType == urlutil.ResourceHTML {
+		entry.Encoding = manifest.EncodingGzip
+	}
+	var err error
+	if pg.file != nil {
+		entry, err = pg.file.Commit(entry)
+	} else {
+		entry, err = s.storage.Save(ctx, entry, bytes.NewReader(pg.body))
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			s.recordFailure(pg.job, err)
+		}
+		return
+	}
+	if pg.Depth == 0 && s.config.Version != "" {
+		s.storage.Manifest().SetVersionRoot(s.config.Version, entry.Path)
+	}
+
+	if s.publisher != nil {
+		s.publisher.saved(entry.File())
+	}
+
+	s.bytes.Add(entry.Size)
+	s.saved.Add(1)
+	s.config.Metrics.Add(metrics.Saved, 1, metrics.Label{Name: "type", Value: pg.resp.ResourceType.String()})
+	s.config.Metrics.Add(metrics.BytesSaved, entry.Size)
+	s.markComplete(pg.URL)
+	s.checkpoints.saved()
+
+	if pg.resp.ResourceType != urlutil.ResourceHTML {
+		return
+	}
+
+	// Save Page Now captures a page's embedded resources itself
+	if !s.config.CompareOnly {
+		s.config.Archive.Enqueue(pg.saveURL)
+	}
+
+	pages := s.pages.Add(1)
+	if every := int64(s.config.NotifyEvery); every > 0 && pages%every == 0 {
+		s.emit(hooks.EventPagesSaved, fmt.Sprintf("%d pages saved from %s", pages, s.config.RootURL))
+	}
+}
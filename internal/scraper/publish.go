@@ -0,0 +1,185 @@
+package scraper
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// publisher publishes the directories of the output to a live directory
+// as soon as every queued resource under them is done, so a web server can
+// host finished sections of a mirror while the crawl continues. A
+// directory is published again whenever it finishes again, after links
+// found elsewhere queue more resources under it
+type publisher struct {
+	src     string // Output directory
+	dst     string // Live directory
+	symlink bool   // Symlink files instead of copying them
+	logger  *slog.Logger
+
+	mu sync.Mutex
+	// outstanding counts the queued and in-progress resources under each
+	// directory of the output, including those in subdirectories
+	outstanding map[string]int
+
+	published atomic.Int64 // Files published
+	temps     atomic.Int64 // Names temporary files, as a file can be published twice at once
+}
+
+// newPublisher creates a publisher from the output directory src to the
+// live directory dst
+func newPublisher(src, dst string, symlink bool, logger *slog.Logger) *publisher {
+	return &publisher{
+		src:         src,
+		dst:         dst,
+		symlink:     symlink,
+		logger:      logger,
+		outstanding: make(map[string]int),
+	}
+}
+
+// queue calls add, which queues a resource saved under relPath, recording
+// the resource if add reports it was queued. The resource cannot finish
+// before it is recorded, as done waits for the lock held meanwhile
+func (p *publisher) queue(relPath string, add func() bool) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !add() {
+		return false
+	}
+	for _, dir := range ancestors(relPath) {
+		p.outstanding[dir]++
+	}
+	return true
+}
+
+// done records that the resource queued under relPath is finished with,
+// publishing the directories it finishes. Subdirectories finish first, so
+// sections appear bottom-up
+func (p *publisher) done(relPath string) {
+	var finished []string
+	p.mu.Lock()
+	for _, dir := range ancestors(relPath) {
+		p.outstanding[dir]--
+		if p.outstanding[dir] == 0 {
+			finished = append(finished, dir)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, dir := range finished {
+		p.publishDir(dir)
+	}
+}
+
+// saved publishes a file saved to a directory with nothing outstanding,
+// such as the target of a redirect from another section
+func (p *publisher) saved(relPath string) {
+	p.mu.Lock()
+	finished := p.outstanding[path.Dir(relPath)] == 0
+	p.mu.Unlock()
+
+	if finished {
+		if err := p.publishFile(relPath); err != nil {
+			p.logger.Warn("publishing file", "path", relPath, "error", err)
+		}
+	}
+}
+
+// publishDir publishes the files directly in an output directory;
+// subdirectories are published when they finish. Hidden files, such as
+// files being saved and the originals kept by KeepOriginal, are skipped
+func (p *publisher) publishDir(dir string) {
+	entries, err := os.ReadDir(filepath.Join(p.src, filepath.FromSlash(dir)))
+	if err != nil {
+		// Nothing was saved there, e.g. every resource failed
+		if !os.IsNotExist(err) {
+			p.logger.Warn("publishing directory", "dir", dir, "error", err)
+		}
+		return
+	}
+
+	files := 0
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if err := p.publishFile(path.Join(dir, e.Name())); err != nil {
+			p.logger.Warn("publishing file", "path", path.Join(dir, e.Name()), "error", err)
+			continue
+		}
+		files++
+	}
+	if files > 0 {
+		p.logger.Info("published section", "dir", dir, "files", files)
+	}
+}
+
+// publishFile copies or links a file of the output into the live
+// directory, replacing the published copy in one step so a web server
+// never serves a partial file
+func (p *publisher) publishFile(relPath string) error {
+	src := filepath.Join(p.src, filepath.FromSlash(relPath))
+	dst := filepath.Join(p.dst, filepath.FromSlash(relPath))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("creating directory for %s: %w", dst, err)
+	}
+
+	tmp := filepath.Join(filepath.Dir(dst), fmt.Sprintf(".tmp-%s-%d", filepath.Base(dst), p.temps.Add(1)))
+	if p.symlink {
+		abs, err := filepath.Abs(src)
+		if err != nil {
+			return err
+		}
+		if err := os.Symlink(abs, tmp); err != nil {
+			return fmt.Errorf("linking %s: %w", dst, err)
+		}
+	} else if err := copyTo(src, tmp); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("copying %s: %w", dst, err)
+	}
+
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("publishing %s: %w", dst, err)
+	}
+	p.published.Add(1)
+	return nil
+}
+
+// copyTo copies the file src to dst
+func copyTo(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// ancestors returns the directories containing relPath, innermost first,
+// ending with "." for the output directory itself
+func ancestors(relPath string) []string {
+	var dirs []string
+	for dir := path.Dir(relPath); ; dir = path.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == "." || dir == "/" {
+			return dirs
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package scraper
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAncestors(t *testing.T) {
+	got := ancestors("example.com/docs/Two/Page.html")
+	want := []string{"example.com/docs/Two", "example.com/docs", "example.com", "."}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ancestors() = %v, want %v", got, want)
+	}
+}
+
+func TestPublisher(t *testing.T) {
+	for _, symlink := range []bool{false, true} {
+		name := "copy"
+		if symlink {
+			name = "symlink"
+		}
+		t.Run(name, func(t *testing.T) {
+			src, dst := t.TempDir(), t.TempDir()
+			save := func(rel string) {
+				path := filepath.Join(src, filepath.FromSlash(rel))
+				os.MkdirAll(filepath.Dir(path), 0755)
+				if err := os.WriteFile(path, []byte(rel), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			published := func(rel string) bool {
+				data, err := os.ReadFile(filepath.Join(dst, filepath.FromSlash(rel)))
+				return err == nil && string(data) == rel
+			}
+
+			p := newPublisher(src, dst, symlink, slog.Default())
+			for _, rel := range []string{"docs/Index.html", "docs/a/A.html", "docs/a/A2.html", "docs/b/B.html"} {
+				p.queue(rel, func() bool { return true })
+			}
+			if p.queue("docs/a/A.html", func() bool { return false }) {
+				t.Error("queue() = true for a resource that was not queued")
+			}
+
+			save("docs/a/A.html")
+			p.done("docs/a/A.html")
+			if published("docs/a/A.html") {
+				t.Error("docs/a published before A2.html finished")
+			}
+
+			save("docs/a/A2.html")
+			save("docs/a/.tmp-A3.html-1")
+			p.done("docs/a/A2.html")
+			if !published("docs/a/A.html") || !published("docs/a/A2.html") {
+				t.Error("docs/a not published once finished")
+			}
+			if published("docs/a/.tmp-A3.html-1") {
+				t.Error("hidden file published")
+			}
+
+			save("docs/Index.html")
+			p.done("docs/Index.html")
+			if published("docs/Index.html") {
+				t.Error("docs published before docs/b finished")
+			}
+
+			// A redirect target lands in a finished section
+			save("docs/a/Moved.html")
+			p.saved("docs/a/Moved.html")
+			if !published("docs/a/Moved.html") {
+				t.Error("file saved to a finished section not published")
+			}
+
+			p.done("docs/b/B.html") // Failed, nothing saved
+			if !published("docs/Index.html") {
+				t.Error("docs not published once finished")
+			}
+
+			if symlink {
+				if fi, err := os.Lstat(filepath.Join(dst, "docs", "Index.html")); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+					t.Errorf("published file is not a symlink: %v", err)
+				}
+			}
+			if got := p.published.Load(); got != 4 {
+				t.Errorf("published = %d, want 4", got)
+			}
+		})
+	}
+}
@@ -2,15 +2,28 @@ package scraper
 
 import (
 	"container/heap"
+	"context"
+	"errors"
+	neturl "net/url"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/aldehir/ue2-docs/internal/urlutil"
 )
 
 // QueueItem represents an item in the URL queue
 type QueueItem struct {
-	URL  string
-	Type urlutil.ResourceType
+	URL     string
+	Type    urlutil.ResourceType
+	Boosted bool // Fetched ahead of all non-boosted items regardless of weight
+	Depth   int  // Number of links followed from the root URL
+
+	// Referrer is the page or sitemap the URL was first found in ("" for
+	// the root URL)
+	Referrer string
+
+	limited bool // Counted against the referrer limit
 }
 
 // Weight returns the priority weight for this item
@@ -24,8 +37,17 @@ type priorityQueue []*QueueItem
 func (pq priorityQueue) Len() int { return len(pq) }
 
 func (pq priorityQueue) Less(i, j int) bool {
+	return higher(pq[i], pq[j])
+}
+
+// higher reports whether a is fetched before b
+func higher(a, b *QueueItem) bool {
+	// Boosted items always come first
+	if a.Boosted != b.Boosted {
+		return a.Boosted
+	}
 	// Higher weight = higher priority (so we want descending order)
-	return pq[i].Weight() > pq[j].Weight()
+	return a.Weight() > b.Weight()
 }
 
 func (pq priorityQueue) Swap(i, j int) {
@@ -46,26 +68,118 @@ func (pq *priorityQueue) Pop() interface{} {
 	return item
 }
 
-// Queue is a thread-safe priority queue for URLs
+// hostQueue holds the queued items of one host
+type hostQueue struct {
+	pq    priorityQueue
+	next  time.Time     // When the host's next item may be taken
+	delay time.Duration // The host's own delay, where longer than the queue's
+}
+
+// referrerQueue tracks the items found in one referrer, with a referrer
+// limit
+type referrerQueue struct {
+	queued int          // Items in the hosts' queues
+	held   []*QueueItem // Items waiting for one of those to be taken, in the order found
+}
+
+// Queue is a thread-safe priority queue for URLs: the crawl's frontier.
+// Items are kept per host, so that with a politeness delay, an item is
+// only taken once the delay since the last item of its host has passed,
+// leaving workers to fetch from other hosts meanwhile instead of sleeping
 type Queue struct {
-	pq      priorityQueue
-	mu      sync.Mutex
-	seen    map[string]bool // Track URLs to prevent duplicates
+	hosts     map[string]*hostQueue
+	referrers map[string]*referrerQueue
+	size      int // Items in all hosts' queues, and held back by referrer
+	mu        sync.Mutex
+	seen      map[string]bool // Track URLs to prevent duplicates
+	boost     *urlutil.Matcher
+	delay     time.Duration // Minimum time between items of the same host
+	limit     int           // Most items of one referrer in the hosts' queues (0 = unlimited)
+	changed   chan struct{} // Closed and replaced whenever items are added
+	closed    bool
 }
 
+// ErrQueueClosed is returned by Next once the queue is closed and drained
+var ErrQueueClosed = errors.New("queue closed")
+
 // NewQueue creates a new priority queue
 func NewQueue() *Queue {
-	q := &Queue{
-		pq:   make(priorityQueue, 0),
-		seen: make(map[string]bool),
+	return &Queue{
+		hosts:     make(map[string]*hostQueue),
+		referrers: make(map[string]*referrerQueue),
+		seen:      make(map[string]bool),
+		changed:   make(chan struct{}),
+	}
+}
+
+// SetPriorityMatcher sets the matcher used to boost URLs ahead of the normal
+// type-weight ordering. Only URLs added after the call are affected
+func (q *Queue) SetPriorityMatcher(m *urlutil.Matcher) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.boost = m
+}
+
+// SetPoliteness sets the minimum time between taking items of the same
+// host (0 = none)
+func (q *Queue) SetPoliteness(delay time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.delay = delay
+}
+
+// SetReferrerLimit caps the items found in any one referrer that are
+// queued at once, so a page with thousands of links cannot flood the queue
+// and crowd out the rest of the site. Its further items are held back, in
+// the order found, and queued one by one as its queued items are taken.
+// Boosted items and those without a referrer are never held back. Only
+// URLs added after the call are affected (0 = unlimited)
+func (q *Queue) SetReferrerLimit(limit int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.limit = limit
+}
+
+// SetHostDelay sets the minimum time between taking items of one host,
+// such as the Crawl-delay its robots.txt asks for, counting the host's
+// next item from now. The longer of it and the politeness delay applies
+func (q *Queue) SetHostDelay(host string, delay time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	h := q.hostQueue(host)
+	h.delay = delay
+	if next := time.Now().Add(delay); next.After(h.next) {
+		h.next = next
+	}
+}
+
+// hostQueue returns the queue of host, creating it if needed; q.mu must be
+// held
+func (q *Queue) hostQueue(host string) *hostQueue {
+	h := q.hosts[host]
+	if h == nil {
+		h = &hostQueue{}
+		q.hosts[host] = h
 	}
-	heap.Init(&q.pq)
-	return q
+	return h
 }
 
 // Add adds a URL to the queue with the given resource type
 // Returns true if the URL was added, false if it was already in the queue
 func (q *Queue) Add(url string, resourceType urlutil.ResourceType) bool {
+	return q.AddWithDepth(url, resourceType, 0)
+}
+
+// AddWithDepth adds a URL found the given number of links away from the root
+// Returns true if the URL was added, false if it was already in the queue
+func (q *Queue) AddWithDepth(url string, resourceType urlutil.ResourceType, depth int) bool {
+	return q.AddFrom(url, resourceType, depth, "")
+}
+
+// AddFrom adds a URL found on the referrer page, the given number of links
+// away from the root
+// Returns true if the URL was added, false if it was already in the queue
+func (q *Queue) AddFrom(url string, resourceType urlutil.ResourceType, depth int, referrer string) bool {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
@@ -77,40 +191,249 @@ func (q *Queue) Add(url string, resourceType urlutil.ResourceType) bool {
 	// Mark as seen
 	q.seen[url] = true
 
-	// Add to priority queue
+	// Add to the host's priority queue
 	item := &QueueItem{
-		URL:  url,
-		Type: resourceType,
+		URL:     url,
+		Type:    resourceType,
+		Boosted: q.boost.Match(url),
+		Depth:   depth,
+
+		Referrer: referrer,
+	}
+	q.size++
+	if q.limit > 0 && referrer != "" && !item.Boosted {
+		r := q.referrers[referrer]
+		if r == nil {
+			r = &referrerQueue{}
+			q.referrers[referrer] = r
+		}
+		item.limited = true
+		if r.queued >= q.limit {
+			r.held = append(r.held, item)
+			return true
+		}
+		r.queued++
 	}
-	heap.Push(&q.pq, item)
+	q.push(item)
 
 	return true
 }
 
-// Pop removes and returns the highest priority item from the queue
-// Returns (item, true) if an item was available, (nil, false) if queue is empty
-func (q *Queue) Pop() (*QueueItem, bool) {
+// push adds an item to its host's queue; q.mu must be held
+func (q *Queue) push(item *QueueItem) {
+	h := q.hostQueue(hostOf(item.URL))
+	heap.Push(&h.pq, item)
+	q.notify()
+}
+
+// release frees the place of a taken item among the items queued from its
+// referrer, queuing the next item held back in it; q.mu must be held
+func (q *Queue) release(item *QueueItem) {
+	if !item.limited {
+		return
+	}
+	r := q.referrers[item.Referrer]
+	r.queued--
+	if len(r.held) > 0 {
+		next := r.held[0]
+		r.held[0] = nil
+		r.held = r.held[1:]
+		r.queued++
+		q.push(next)
+	}
+	if r.queued == 0 {
+		delete(q.referrers, item.Referrer)
+	}
+}
+
+// hostOf returns the host of a URL, or "" if it cannot be parsed
+func hostOf(rawURL string) string {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// notify wakes goroutines waiting in Next; q.mu must be held
+func (q *Queue) notify() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// take removes and returns the highest priority item among the hosts out
+// of their politeness delay at now. If there is none, it returns nil and
+// the time the next host with items comes out of its delay, or the zero
+// time if no host has items; q.mu must be held
+func (q *Queue) take(now time.Time) (*QueueItem, time.Time) {
+	var best *hostQueue
+	var wake time.Time
+	for _, h := range q.hosts {
+		switch {
+		case h.pq.Len() == 0:
+		case h.next.After(now):
+			if wake.IsZero() || h.next.Before(wake) {
+				wake = h.next
+			}
+		case best == nil || higher(h.pq[0], best.pq[0]):
+			best = h
+		}
+	}
+	if best == nil {
+		return nil, wake
+	}
+
+	item := heap.Pop(&best.pq).(*QueueItem)
+	best.next = now.Add(max(q.delay, best.delay))
+	q.size--
+	q.release(item)
+	return item, time.Time{}
+}
+
+// Next removes and returns the highest priority item whose host is out of
+// its politeness delay, waiting for one to be added or come out of its
+// delay otherwise. Returns ErrQueueClosed once the queue is closed and
+// empty, or ctx's error if it is cancelled first
+func (q *Queue) Next(ctx context.Context) (*QueueItem, error) {
+	for {
+		q.mu.Lock()
+		item, wake := q.take(time.Now())
+		if item != nil {
+			q.mu.Unlock()
+			return item, nil
+		}
+		if q.closed && q.size == 0 {
+			q.mu.Unlock()
+			return nil, ErrQueueClosed
+		}
+		changed := q.changed
+		q.mu.Unlock()
+
+		var ready <-chan time.Time
+		var timer *time.Timer
+		if !wake.IsZero() {
+			timer = time.NewTimer(time.Until(wake))
+			ready = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-changed:
+		case <-ready:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Close wakes every goroutine waiting in Next once no more items will be
+// added; items already queued can still be taken
+func (q *Queue) Close() {
 	q.mu.Lock()
 	defer q.mu.Unlock()
-
-	if q.pq.Len() == 0 {
-		return nil, false
+	if !q.closed {
+		q.closed = true
+		q.notify()
 	}
+}
 
-	item := heap.Pop(&q.pq).(*QueueItem)
-	return item, true
+// Pop removes and returns the highest priority item whose host is out of
+// its politeness delay
+// Returns (item, true) if an item was available, (nil, false) if queue is
+// empty or every host with items is still in its delay
+func (q *Queue) Pop() (*QueueItem, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	item, _ := q.take(time.Now())
+	return item, item != nil
 }
 
 // IsEmpty returns true if the queue is empty
 func (q *Queue) IsEmpty() bool {
 	q.mu.Lock()
 	defer q.mu.Unlock()
-	return q.pq.Len() == 0
+	return q.size == 0
 }
 
 // Len returns the number of items in the queue
 func (q *Queue) Len() int {
 	q.mu.Lock()
 	defer q.mu.Unlock()
-	return q.pq.Len()
+	return q.size
+}
+
+// Seen returns the number of distinct URLs ever added to the queue
+func (q *Queue) Seen() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.seen)
+}
+
+// Peek returns a copy of the highest priority item without removing it,
+// whether or not its host is in its politeness delay
+// Returns (item, true) if an item was available, (zero item, false) if queue is empty
+func (q *Queue) Peek() (QueueItem, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// Each heap keeps its highest priority item first
+	var best *QueueItem
+	for _, h := range q.hosts {
+		if h.pq.Len() > 0 && (best == nil || higher(h.pq[0], best)) {
+			best = h.pq[0]
+		}
+	}
+	if best == nil {
+		return QueueItem{}, false
+	}
+	return *best, true
+}
+
+// CountsByType returns the number of queued items of each resource type
+func (q *Queue) CountsByType() map[urlutil.ResourceType]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	counts := make(map[urlutil.ResourceType]int)
+	for _, h := range q.hosts {
+		for _, item := range h.pq {
+			counts[item.Type]++
+		}
+	}
+	for _, r := range q.referrers {
+		for _, item := range r.held {
+			counts[item.Type]++
+		}
+	}
+	return counts
+}
+
+// Items returns copies of up to limit queued items in priority order,
+// without removing them (limit <= 0 = all items). Politeness delays may
+// have items of other hosts taken before them, and referrer limits items
+// of other referrers
+func (q *Queue) Items(limit int) []QueueItem {
+	q.mu.Lock()
+	snapshot := make(priorityQueue, 0, q.size)
+	for _, h := range q.hosts {
+		snapshot = append(snapshot, h.pq...)
+	}
+	for _, r := range q.referrers {
+		snapshot = append(snapshot, r.held...)
+	}
+	q.mu.Unlock()
+
+	sort.SliceStable(snapshot, snapshot.Less)
+	if limit > 0 && limit < len(snapshot) {
+		snapshot = snapshot[:limit]
+	}
+	items := make([]QueueItem, len(snapshot))
+	for i, item := range snapshot {
+		items[i] = *item
+	}
+	return items
 }
@@ -1,9 +1,12 @@
 package scraper
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/aldehir/ue2-docs/internal/urlutil"
 )
@@ -204,6 +207,46 @@ func TestQueue_PopEmpty(t *testing.T) {
 	}
 }
 
+func TestQueue_Next(t *testing.T) {
+	q := NewQueue()
+
+	got := make(chan *QueueItem)
+	go func() {
+		item, _ := q.Next(context.Background())
+		got <- item
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	q.Add("https://example.com/a", urlutil.ResourceHTML)
+
+	select {
+	case item := <-got:
+		if item == nil || item.URL != "https://example.com/a" {
+			t.Errorf("Next() = %v, want the added item", item)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Next() did not wake up when an item was added")
+	}
+
+	q.Add("https://example.com/b", urlutil.ResourceHTML)
+	q.Close()
+	if item, err := q.Next(context.Background()); err != nil || item.URL != "https://example.com/b" {
+		t.Errorf("Next() after Close() = %v, %v; want remaining item", item, err)
+	}
+	if _, err := q.Next(context.Background()); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("Next() on closed empty queue error = %v, want ErrQueueClosed", err)
+	}
+}
+
+func TestQueue_NextCancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := NewQueue().Next(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Next() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
 func TestQueueItem_Weight(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -229,3 +272,249 @@ func TestQueueItem_Weight(t *testing.T) {
 		})
 	}
 }
+
+func TestQueue_PriorityMatcher(t *testing.T) {
+	q := NewQueue()
+
+	m, err := urlutil.NewMatcher([]string{
+		"https://example.com/api/*",
+		"https://example.com/logo.png",
+	})
+	if err != nil {
+		t.Fatalf("NewMatcher() error = %v", err)
+	}
+	q.SetPriorityMatcher(m)
+
+	q.Add("https://example.com/page.html", urlutil.ResourceHTML)
+	q.Add("https://example.com/logo.png", urlutil.ResourceImage)
+	q.Add("https://example.com/style.css", urlutil.ResourceCSS)
+	q.Add("https://example.com/api/Actor.html", urlutil.ResourceHTML)
+
+	// Boosted items come first (by weight among themselves), then the rest
+	expected := []string{
+		"https://example.com/api/Actor.html",
+		"https://example.com/logo.png",
+		"https://example.com/page.html",
+		"https://example.com/style.css",
+	}
+
+	for i, want := range expected {
+		item, ok := q.Pop()
+		if !ok {
+			t.Fatalf("Pop %d: expected item but queue was empty", i)
+		}
+		if item.URL != want {
+			t.Errorf("Pop %d: got %v, want %v", i, item.URL, want)
+		}
+	}
+}
+
+func TestQueue_AddFrom(t *testing.T) {
+	q := NewQueue()
+	q.AddFrom("https://example.com/a.png", urlutil.ResourceImage, 1, "https://example.com/page.html")
+	q.AddFrom("https://example.com/a.png", urlutil.ResourceImage, 2, "https://example.com/other.html")
+
+	item, ok := q.Pop()
+	if !ok || item.Referrer != "https://example.com/page.html" || item.Depth != 1 {
+		t.Errorf("Pop() = %+v, want the first referrer", item)
+	}
+	if q.Seen() != 1 {
+		t.Errorf("Seen() = %d, want 1", q.Seen())
+	}
+}
+
+func BenchmarkQueue_AddPop(b *testing.B) {
+	urls := make([]string, 1024)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("https://udn.epicgames.com/Two/Page%d.html", i)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		q := NewQueue()
+		for j, url := range urls {
+			q.AddWithDepth(url, urlutil.ResourceType(j%3), j%8)
+			// Already queued URLs are dropped at enqueue time
+			q.AddWithDepth(url, urlutil.ResourceType(j%3), j%8)
+		}
+		for !q.IsEmpty() {
+			q.Pop()
+		}
+	}
+}
+
+func TestQueue_Introspection(t *testing.T) {
+	q := NewQueue()
+	if _, ok := q.Peek(); ok {
+		t.Error("Peek() on empty queue returned an item")
+	}
+
+	q.Add("https://example.com/image.png", urlutil.ResourceImage)
+	q.Add("https://example.com/logo.png", urlutil.ResourceImage)
+	q.Add("https://example.com/page.html", urlutil.ResourceHTML)
+	q.Add("https://example.com/style.css", urlutil.ResourceCSS)
+
+	item, ok := q.Peek()
+	if !ok || item.URL != "https://example.com/page.html" {
+		t.Errorf("Peek() = %v, %v, want the page", item.URL, ok)
+	}
+	if q.Len() != 4 {
+		t.Errorf("Len() after Peek() = %d, want 4", q.Len())
+	}
+
+	counts := q.CountsByType()
+	if counts[urlutil.ResourceImage] != 2 || counts[urlutil.ResourceHTML] != 1 || counts[urlutil.ResourceCSS] != 1 {
+		t.Errorf("CountsByType() = %v", counts)
+	}
+
+	items := q.Items(2)
+	if len(items) != 2 || items[0].Type != urlutil.ResourceHTML || items[1].Type != urlutil.ResourceCSS {
+		t.Errorf("Items(2) = %v, want the page then the stylesheet", items)
+	}
+	if all := q.Items(0); len(all) != 4 || all[3].Type != urlutil.ResourceImage {
+		t.Errorf("Items(0) = %v, want all 4 in order", all)
+	}
+
+	// Items are taken in the order listed
+	for i, want := range q.Items(0) {
+		got, _ := q.Pop()
+		if got.Type != want.Type {
+			t.Errorf("Pop %d: got type %v, Items listed %v", i, got.Type, want.Type)
+		}
+	}
+}
+
+func TestQueue_Politeness(t *testing.T) {
+	q := NewQueue()
+	q.SetPoliteness(50 * time.Millisecond)
+	q.Add("https://a.example/1.html", urlutil.ResourceHTML)
+	q.Add("https://a.example/2.html", urlutil.ResourceHTML)
+	q.Add("https://b.example/logo.png", urlutil.ResourceImage)
+
+	first, _ := q.Pop()
+	if hostOf(first.URL) != "a.example" {
+		t.Fatalf("first Pop() = %s, want a page of a.example", first.URL)
+	}
+	// a.example is in its delay, so the lower priority image comes next
+	second, ok := q.Pop()
+	if !ok || second.URL != "https://b.example/logo.png" {
+		t.Fatalf("second Pop() = %v, want the image of b.example", second)
+	}
+	if item, ok := q.Pop(); ok {
+		t.Fatalf("Pop() during the delay = %s, want none", item.URL)
+	}
+	if q.Len() != 1 {
+		t.Errorf("Len() = %d, want 1 item waiting out its delay", q.Len())
+	}
+
+	start := time.Now()
+	third, err := q.Next(context.Background())
+	if err != nil || hostOf(third.URL) != "a.example" {
+		t.Fatalf("Next() = %v, %v, want the other page of a.example", third, err)
+	}
+	if waited := time.Since(start); waited < 30*time.Millisecond {
+		t.Errorf("Next() returned after %s, before the delay passed", waited)
+	}
+}
+
+func TestQueue_HostDelay(t *testing.T) {
+	q := NewQueue()
+	q.SetHostDelay("b.example", time.Hour)
+	q.Add("https://a.example/1.html", urlutil.ResourceHTML)
+	q.Add("https://a.example/2.html", urlutil.ResourceHTML)
+	q.Add("https://b.example/1.html", urlutil.ResourceHTML)
+
+	// b.example's delay counts from when it was set, a.example has none
+	for i := 0; i < 2; i++ {
+		item, ok := q.Pop()
+		if !ok || hostOf(item.URL) != "a.example" {
+			t.Fatalf("Pop() %d = %v, want a page of a.example", i, item)
+		}
+	}
+	if item, ok := q.Pop(); ok {
+		t.Errorf("Pop() during b.example's delay = %s, want none", item.URL)
+	}
+}
+
+func TestQueue_PolitenessClosed(t *testing.T) {
+	q := NewQueue()
+	q.SetPoliteness(20 * time.Millisecond)
+	q.Add("https://a.example/1.html", urlutil.ResourceHTML)
+	q.Add("https://a.example/2.html", urlutil.ResourceHTML)
+	q.Close()
+
+	// Items waiting out a delay are still taken once the queue is closed
+	for i := 0; i < 2; i++ {
+		if _, err := q.Next(context.Background()); err != nil {
+			t.Fatalf("Next() %d error = %v", i, err)
+		}
+	}
+	if _, err := q.Next(context.Background()); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("Next() on drained queue error = %v, want ErrQueueClosed", err)
+	}
+}
+
+func TestQueue_ReferrerLimit(t *testing.T) {
+	q := NewQueue()
+	q.SetReferrerLimit(2)
+	index := "https://example.com/api/index.html"
+	for i := 1; i <= 5; i++ {
+		q.AddFrom(fmt.Sprintf("https://example.com/api/%d.html", i), urlutil.ResourceHTML, 1, index)
+	}
+	q.AddFrom("https://example.com/editor/1.html", urlutil.ResourceHTML, 1, "https://example.com/editor/index.html")
+
+	if q.Len() != 6 {
+		t.Errorf("Len() = %d, want 6, counting the items held back", q.Len())
+	}
+
+	// Only two of the index's links are queued at once, so the editor's
+	// page is among the first three taken
+	var first []string
+	for i := 0; i < 3; i++ {
+		item, ok := q.Pop()
+		if !ok {
+			t.Fatalf("Pop() %d found nothing", i)
+		}
+		first = append(first, item.URL)
+	}
+	found := false
+	for _, url := range first {
+		found = found || url == "https://example.com/editor/1.html"
+	}
+	if !found {
+		t.Errorf("first items taken = %v, want the editor's page among them", first)
+	}
+
+	// The rest of the index's links trickle in as the queued ones are taken
+	for i := 0; i < 3; i++ {
+		if _, ok := q.Pop(); !ok {
+			t.Fatalf("Pop() of held item %d found nothing", i)
+		}
+	}
+	if !q.IsEmpty() {
+		t.Errorf("queue not empty after taking every item: Len() = %d", q.Len())
+	}
+}
+
+func TestQueue_ReferrerLimitNext(t *testing.T) {
+	q := NewQueue()
+	q.SetReferrerLimit(1)
+	for i := 1; i <= 3; i++ {
+		q.AddFrom(fmt.Sprintf("https://example.com/%d.html", i), urlutil.ResourceHTML, 1, "https://example.com/")
+	}
+	q.Close()
+
+	// Held items are still taken once the queue is closed, in the order found
+	for i := 1; i <= 3; i++ {
+		item, err := q.Next(context.Background())
+		if err != nil {
+			t.Fatalf("Next() %d error = %v", i, err)
+		}
+		if want := fmt.Sprintf("https://example.com/%d.html", i); item.URL != want {
+			t.Errorf("Next() %d = %s, want %s", i, item.URL, want)
+		}
+	}
+	if _, err := q.Next(context.Background()); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("Next() on drained queue error = %v, want ErrQueueClosed", err)
+	}
+}
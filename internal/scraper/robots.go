@@ -0,0 +1,97 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"sync"
+
+	"github.com/aldehir/ue2-docs/internal/parser"
+)
+
+// robotsFile is the robots.txt of one origin, fetched once
+type robotsFile struct {
+	once sync.Once
+	body []byte // nil if missing or unreadable
+
+	rulesOnce sync.Once
+	rules     parser.RobotsRules // For the crawler's user agent
+}
+
+// robots returns the robots.txt of origin, such as "https://example.com",
+// fetching it the first time it is asked for, or nil if it is missing
+func (s *Scraper) robots(ctx context.Context, origin string) []byte {
+	v, _ := s.robotsFiles.LoadOrStore(origin, &robotsFile{})
+	file := v.(*robotsFile)
+	file.once.Do(func() {
+		file.body, _ = s.fetchAux(ctx, origin+"/robots.txt")
+	})
+	return file.body
+}
+
+// robotsDisallowed reports whether the robots.txt of rawURL's origin
+// disallows it for the crawler's user agent. Nothing is disallowed unless
+// RobotsTxt is set, nor when mirroring from a snapshot
+func (s *Scraper) robotsDisallowed(ctx context.Context, rawURL string) bool {
+	if !s.config.RobotsTxt || s.snapshot != "" {
+		return false
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+
+	origin := u.Scheme + "://" + u.Host
+	body := s.robots(ctx, origin)
+	if body == nil {
+		return false
+	}
+	v, _ := s.robotsFiles.Load(origin)
+	file := v.(*robotsFile)
+	file.rulesOnce.Do(func() {
+		file.rules = parser.ParseRobotsRules(bytes.NewReader(body), s.userAgent())
+	})
+	return !file.rules.Allowed(u.RequestURI())
+}
+
+// applyCrawlDelay slows the crawl of rawURL's host to the Crawl-delay its
+// robots.txt asks of the crawler's user agent, capped at MaxCrawlDelay,
+// the first time an item of the host is taken. Hosts are left at the
+// politeness delay when mirroring from a snapshot, whose pages all come
+// from the Wayback Machine
+func (s *Scraper) applyCrawlDelay(ctx context.Context, rawURL string) {
+	if !s.config.RobotsCrawlDelay || s.snapshot != "" {
+		return
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return
+	}
+	if _, done := s.crawlDelays.LoadOrStore(u.Host, true); done {
+		return
+	}
+
+	body := s.robots(ctx, u.Scheme+"://"+u.Host)
+	if body == nil {
+		return
+	}
+	delay, ok := parser.RobotsCrawlDelay(bytes.NewReader(body), s.userAgent())
+	if !ok || delay <= s.config.PolitenessDelay {
+		return
+	}
+	if limit := s.config.MaxCrawlDelay; limit > 0 && delay > limit {
+		s.logger.Warn("capping robots.txt crawl delay", "host", u.Host, "delay", delay, "max", limit)
+		delay = limit
+	}
+	s.logger.Info("honouring robots.txt crawl delay", "host", u.Host, "delay", delay)
+	s.queue.SetHostDelay(u.Host, delay)
+}
+
+// userAgent returns the User-Agent robots.txt groups are matched against:
+// the first the crawler sends
+func (s *Scraper) userAgent() string {
+	if agents := s.config.Fetcher.UserAgents; len(agents) > 0 {
+		return agents[0]
+	}
+	return s.config.Fetcher.UserAgent
+}
@@ -0,0 +1,202 @@
+package scraper
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aldehir/ue2-docs/internal/parser"
+)
+
+// ScopePrompt asks whether to whitelist host, which lies outside the crawl
+// scope, given the number of links to it found so far and a few of the
+// URLs linked to
+type ScopePrompt func(host string, links int, examples []string) bool
+
+// defaultScopePromptLinks is the number of links to an out-of-scope host
+// that prompts a ScopePrompt when Config.ScopePromptLinks is unset
+const defaultScopePromptLinks = 25
+
+// scopeExamples is the number of linked URLs a ScopePrompt is shown
+const scopeExamples = 3
+
+// outOfScope counts the links found to each host outside the crawl scope,
+// remembering the URLs that would be followed should the host be
+// whitelisted while the crawl runs
+type outOfScope struct {
+	root   string // Host of the root URL, whose pages outside the root path no whitelist brings in
+	mu     sync.Mutex
+	hosts  map[string]*hostScope
+	asking sync.Mutex // Serializes prompts
+}
+
+// HostLinks counts the links found to a host outside the crawl scope
+type HostLinks struct {
+	Host   string
+	Links  int // Counting each page's links to a URL once
+	Assets int // Links embedding an image, stylesheet, script or frame in the page
+}
+
+// hostScope is what is known of the links to an out-of-scope host
+type hostScope struct {
+	links    int           // Links found, counting each page's once
+	assets   int           // Links embedding a resource in the page
+	examples []string      // First few URLs linked to
+	asked    bool          // The ScopePrompt was asked about the host
+	allowed  bool          // The ScopePrompt whitelisted the host
+	decided  chan struct{} // Closed once the ScopePrompt answered
+	pending  []QueueItem   // URLs to enqueue if whitelisted; dropped once asked
+	queued   map[string]bool
+}
+
+func newOutOfScope(rootURL string) *outOfScope {
+	o := &outOfScope{hosts: make(map[string]*hostScope)}
+	if u, err := url.Parse(rootURL); err == nil {
+		o.root = strings.ToLower(u.Host)
+	}
+	return o
+}
+
+// outOfScopeLink records a link of the given kind to item.URL, which the
+// filter rejected, where follow tells whether it would be enqueued if it
+// were in scope.
+// With a ScopePrompt, reaching the configured number of links to the URL's
+// host asks whether to whitelist it, holding back pages linking to the
+// host until it is answered. Returns whether the host is now whitelisted,
+// in which case the URLs to follow found earlier are enqueued
+func (s *Scraper) outOfScopeLink(item QueueItem, kind parser.RefKind, follow bool) bool {
+	rawURL := item.URL
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	host := strings.ToLower(u.Host)
+	o := s.scope
+	if host == o.root {
+		return false
+	}
+
+	o.mu.Lock()
+	h, ok := o.hosts[host]
+	if !ok {
+		h = &hostScope{decided: make(chan struct{}), queued: make(map[string]bool)}
+		o.hosts[host] = h
+	}
+	h.links++
+	if kind.Embedded() {
+		h.assets++
+	}
+	if len(h.examples) < scopeExamples && !hasString(h.examples, rawURL) {
+		h.examples = append(h.examples, rawURL)
+	}
+	if s.config.ScopePrompt == nil {
+		o.mu.Unlock()
+		return false
+	}
+	if h.asked {
+		o.mu.Unlock()
+		<-h.decided
+		allowed, _ := s.filter.IsAllowed(rawURL)
+		return allowed
+	}
+	if follow && !h.queued[rawURL] {
+		h.queued[rawURL] = true
+		h.pending = append(h.pending, item)
+	}
+	threshold := s.config.ScopePromptLinks
+	if threshold <= 0 {
+		threshold = defaultScopePromptLinks
+	}
+	if h.links < threshold {
+		o.mu.Unlock()
+		return false
+	}
+	h.asked = true
+	links, examples, pending := h.links, h.examples, h.pending
+	h.pending, h.queued = nil, nil
+	o.mu.Unlock()
+
+	o.asking.Lock()
+	whitelist := s.config.ScopePrompt(host, links, examples)
+	o.asking.Unlock()
+
+	o.mu.Lock()
+	h.allowed = whitelist
+	o.mu.Unlock()
+	if whitelist {
+		s.filter.Allow(host)
+		s.logger.Info("whitelisted out-of-scope host", "host", host, "links", links)
+		for _, p := range pending {
+			if p.URL != rawURL {
+				s.enqueue(p.URL, p.Type, p.Depth, p.Referrer)
+			}
+		}
+	}
+	close(h.decided)
+	return whitelist
+}
+
+// whitelisted returns the hosts a ScopePrompt whitelisted, sorted
+func (s *Scraper) whitelisted() []string {
+	s.scope.mu.Lock()
+	defer s.scope.mu.Unlock()
+	var hosts []string
+	for host, h := range s.scope.hosts {
+		if h.allowed {
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// OutOfScopeHosts returns the hosts outside the crawl scope that pages
+// link to, most linked first, so the next crawl can whitelist those worth
+// mirroring. Hosts whitelisted by a ScopePrompt are left out
+func (s *Scraper) OutOfScopeHosts() []HostLinks {
+	s.scope.mu.Lock()
+	var hosts []HostLinks
+	for host, h := range s.scope.hosts {
+		if !h.allowed {
+			hosts = append(hosts, HostLinks{Host: host, Links: h.links, Assets: h.assets})
+		}
+	}
+	s.scope.mu.Unlock()
+
+	sort.Slice(hosts, func(i, j int) bool {
+		if hosts[i].Links != hosts[j].Links {
+			return hosts[i].Links > hosts[j].Links
+		}
+		return hosts[i].Host < hosts[j].Host
+	})
+	return hosts
+}
+
+// SuggestWhitelist returns the out-of-scope hosts that at least minAssets
+// links embed resources from, most embedded first. Pages of legacy doc
+// sites often keep their images and stylesheets on a separate host, which
+// a mirror needs whitelisted to render; hosts only linked to, as by
+// anchors, are left out
+func (s *Scraper) SuggestWhitelist(minAssets int) []HostLinks {
+	var hosts []HostLinks
+	for _, h := range s.OutOfScopeHosts() {
+		if h.Assets > 0 && h.Assets >= minAssets {
+			hosts = append(hosts, h)
+		}
+	}
+	sort.SliceStable(hosts, func(i, j int) bool {
+		return hosts[i].Assets > hosts[j].Assets
+	})
+	return hosts
+}
+
+// hasString reports whether list contains s
+func hasString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
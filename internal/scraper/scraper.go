@@ -0,0 +1,1104 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aldehir/ue2-docs/internal/fetcher"
+	"github.com/aldehir/ue2-docs/internal/hooks"
+	"github.com/aldehir/ue2-docs/internal/metrics"
+	"github.com/aldehir/ue2-docs/internal/minisign"
+	"github.com/aldehir/ue2-docs/internal/parser"
+	"github.com/aldehir/ue2-docs/internal/state"
+	"github.com/aldehir/ue2-docs/internal/storage"
+	"github.com/aldehir/ue2-docs/internal/urlutil"
+	"github.com/aldehir/ue2-docs/internal/wayback"
+)
+
+// Config holds scraper configuration
+type Config struct {
+	RootURL   string
+	OutputDir string
+	// Backend keeps saved files elsewhere than OutputDir on disk, such as in
+	// an S3 bucket; nil = OutputDir. PublishDir and RsyncFriendly need files
+	// on disk
+	Backend   storage.Backend
+	Workers   int              // Fetch workers
+	Whitelist []string         // Domains, and directories of file URLs starting with a slash
+	MaxDepth  int              // 0 = unlimited
+	Priority  *urlutil.Matcher // URLs fetched ahead of everything else
+	Fetcher   fetcher.Config
+
+	// Sections scopes the crawl to the pages of some sections of the site,
+	// so contributors can each mirror a slice of it to be merged by
+	// manifest: other pages are not followed, the root URL excepted.
+	// Assets are fetched wherever they are, as the pages need them, and
+	// links to other pages still point where their sections' mirrors put
+	// them (nil = every page)
+	Sections *urlutil.PathMatcher
+
+	// Pagination keeps the query parameters that page through listings,
+	// such as TWiki's search results, on their URLs, so every page of a
+	// listing is crawled and saved rather than the first alone; nil =
+	// queries are dropped from every URL
+	Pagination *urlutil.Pagination
+
+	// MaxRetriesByType overrides Fetcher.MaxRetries for queued resources
+	// of the given types, e.g. to give up on missing images at once while
+	// retrying pages
+	MaxRetriesByType map[urlutil.ResourceType]int
+
+	// PolitenessDelay is the minimum time between fetches from the same
+	// host. Workers fetch from other hosts meanwhile, or wait for the
+	// queue, rather than sleeping with a URL in hand (0 = none)
+	PolitenessDelay time.Duration
+
+	// RobotsCrawlDelay slows the crawl of each host to the Crawl-delay its
+	// robots.txt asks of the crawler, where longer than PolitenessDelay.
+	// MaxCrawlDelay caps the delays taken from robots.txt, so one asking
+	// for hours cannot stall the crawl (0 = uncapped)
+	RobotsCrawlDelay bool
+	MaxCrawlDelay    time.Duration
+
+	// RobotsTxt skips the URLs robots.txt disallows for the crawler,
+	// recording them as failed with ErrRobotsDisallowed
+	RobotsTxt bool
+
+	// ReferrerLimit caps the links of any one page queued at once, holding
+	// the rest back until those are taken, so a page linking to thousands
+	// of others cannot fill the queue and keep the rest of the site waiting
+	// behind it (0 = unlimited)
+	ReferrerLimit int
+
+	// KnownDead lists URLs that failed permanently in an earlier crawl, as
+	// recorded in its state file. They are marked visited with their
+	// recorded status before the crawl starts, so re-crawls do not keep
+	// probing pages known to be gone
+	KnownDead []state.Record
+
+	// ParseWorkers and WriteWorkers size the pools that parse and rewrite
+	// pages and that save resources to disk, which are CPU- and IO-bound
+	// where fetching is network-bound (0 = one per CPU, and Workers)
+	ParseWorkers int
+	WriteWorkers int
+
+	// MaxBufferedBytes caps the response bytes held in memory by all
+	// workers at once; workers wait for room rather than exceed it, except
+	// the one that started buffering first (0 = unlimited)
+	MaxBufferedBytes int64
+	StatePath        string // Crawl state file recording every fetch ("" = disabled); Zstandard-compressed if named *.zst
+
+	// CompressManifest writes the manifest Zstandard-compressed, as
+	// manifest.json.zst, which keeps checkpoints of archive-scale crawls
+	// quick and small
+	CompressManifest bool
+
+	// CheckpointInterval and CheckpointPages flush the crawl's progress to
+	// disk while it runs, syncing the state file and writing the manifest
+	// every interval and after every N saved pages. Shorter checkpoints
+	// lose less to a crash at the cost of more IO (both 0 = only once the
+	// crawl ends)
+	CheckpointInterval time.Duration
+	CheckpointPages    int
+
+	// Version names the documentation generation being mirrored, e.g. "two"
+	// or "three". Its files are saved under OutputDir/<version>/ and merged
+	// into the existing manifest, so several versions share one mirror
+	// ("" = unversioned)
+	Version string
+
+	// SignKey signs the manifest when it is written; nil disables signing
+	SignKey *minisign.SecretKey
+
+	// LocalBase is the directory whose layout is kept when mirroring a local
+	// dump from a file:// root, e.g. the top of an HTTrack mirror containing
+	// <host>/<path> directories. Defaults to the directory of the root file
+	LocalBase string
+
+	// Sitemap pre-seeds the queue with the in-scope URLs listed in the
+	// origin's sitemap.xml and in sitemaps named by its robots.txt, finding
+	// pages no link reaches
+	Sitemap bool
+
+	// CompressHTML stores pages gzip-compressed, as <path>.gz, which
+	// shrinks text-heavy mirrors to around a third for archiving. The
+	// convert, repair, rewrite and optimize commands read them
+	// transparently; web servers can serve them as is, e.g. with nginx's
+	// gzip_static
+	CompressHTML bool
+
+	// KeepOriginal also saves every page as fetched, before its links are
+	// rewritten, under storage.OriginalDir, so improvements to rewriting
+	// and conversion can be applied without crawling again
+	KeepOriginal bool
+
+	// RecordOriginalURLs keeps the URL of every link rewritten to a local
+	// path in a data-original-href (or -src) attribute, so the rewrite
+	// command can resolve it again without the original page, and wrong
+	// rewrites can be traced to the URL they came from
+	RecordOriginalURLs bool
+
+	// RsyncFriendly leaves files whose content is unchanged untouched on a
+	// re-crawl and dates saved files by the origin's Last-Modified, so
+	// rsync distributes only the files that really changed
+	RsyncFriendly bool
+
+	// CompareOnly fetches every resource as usual but writes nothing to
+	// the output, comparing each with the existing mirror's copy instead,
+	// to tell whether anything changed without touching a published
+	// mirror; see Result.Changes. Originals, publishing and Wayback
+	// Machine submissions are skipped
+	CompareOnly bool
+
+	// DirectoryListings writes an index.html listing the contents of every
+	// directory of the output without an index page once the crawl ends,
+	// so a web server serving the mirror never answers directory URLs with
+	// 403 or 404
+	DirectoryListings bool
+
+	// IgnoreRobotsMeta disregards <meta name="robots"> and X-Robots-Tag
+	// directives, following links and indexing every page
+	IgnoreRobotsMeta bool
+
+	// NoFollowLinks controls rel="nofollow" links and ExternalLinks links
+	// marked external; by default both are recorded but not enqueued
+	NoFollowLinks parser.LinkPolicy
+	ExternalLinks parser.LinkPolicy
+
+	// Forms controls the forms of saved pages, whose actions are TWiki
+	// scripts the mirror cannot run; by default they are disabled. Form
+	// actions are never enqueued
+	Forms parser.FormPolicy
+
+	// PublishDir receives each directory of the output as soon as every
+	// resource queued under it is saved or failed, so a web server can
+	// host finished sections while the crawl continues ("" = disabled).
+	// Files are copied, or symlinked with PublishSymlinks
+	PublishDir      string
+	PublishSymlinks bool
+
+	// Metrics receives counts of saved and failed resources and bytes
+	// saved, and, unless Fetcher.Metrics is set, the fetcher's request
+	// metrics; nil = metrics.Nop
+	Metrics metrics.Sink
+
+	// Logger receives the crawl's log, with worker, trace and referrer
+	// fields on the lines about each fetch; nil = slog.Default()
+	Logger *slog.Logger
+
+	// ScopePrompt is asked whether to whitelist a host outside the crawl
+	// scope once ScopePromptLinks links to it were found (0 = 25), instead
+	// of silently leaving its resources out of the mirror; nil never asks.
+	// Pages linking to the host wait for the answer, and pages saved
+	// before it keep absolute links to the host until rewritten again
+	ScopePrompt      ScopePrompt
+	ScopePromptLinks int
+
+	// Schemes lists the asset schemes fetched besides http and https:
+	// "ftp", for downloads on whitelisted hosts, and "file", for files in
+	// whitelisted directories linked from a file:// root's pages, which
+	// must lie within LocalBase
+	Schemes []string
+
+	// SchemePolicy accepts http and https URLs alike, only https ones, or
+	// upgrades http URLs to https, so sites linking to pages by both
+	// schemes have them mirrored once. Upgrading makes the fetcher fall
+	// back to plain HTTP for hosts without working HTTPS
+	SchemePolicy urlutil.SchemePolicy
+
+	// PagesOnly fetches pages alone, recording the links to images,
+	// stylesheets and other resources without fetching them, for quick
+	// surveys of a site such as whitelist suggestions
+	PagesOnly bool
+
+	// Hooks receives crawl events; nil disables notifications
+	Hooks *hooks.Dispatcher
+	// Archive receives every saved page for submission to the Wayback
+	// Machine; nil disables submission
+	Archive *wayback.Submitter
+	// NotifyEvery emits EventPagesSaved after every N saved pages, not
+	// counting images and other assets (0 = never)
+	NotifyEvery int
+	// ErrorRateThreshold emits EventErrorRateExceeded once the fraction of
+	// failed fetches exceeds it (0 = never)
+	ErrorRateThreshold float64
+}
+
+// errorRateMinSamples is the number of fetches required before the error
+// rate is considered meaningful
+const errorRateMinSamples = 20
+
+// Result summarizes a finished crawl
+type Result struct {
+	Visited     int
+	Saved       int
+	Failed      int      // Includes Mismatched
+	Mismatched  int      // Not saved because the Content-Type contradicted the extension
+	Coalesced   int      // Fetches shared with another worker fetching the same URL
+	Forms       int      // Forms disabled or stripped from saved pages; see Scraper.FormActions
+	Published   int      // Files published to PublishDir, counting republished ones
+	Unchanged   int      // Saved files left in place as identical, with RsyncFriendly, or found identical with CompareOnly
+	Checkpoints int      // Checkpoints written during the crawl
+	Listings    int      // Directory listings written, with DirectoryListings
+	KnownDead   int      // URLs marked visited from Config.KnownDead
+	Whitelisted []string // Hosts whitelisted by Config.ScopePrompt, sorted
+
+	// OutOfScopeHosts are the hosts outside the crawl scope pages link
+	// to, most linked first
+	OutOfScopeHosts []HostLinks
+	Bytes           int64
+	Duration        time.Duration
+
+	// Changes lists the files that differ from, or are missing in, the
+	// existing mirror, with CompareOnly
+	Changes []storage.Change
+
+	SitemapURLs  int // In-scope URLs queued from sitemaps
+	Completeness Completeness
+	Summary      Summary // Visited URLs by status class and type
+}
+
+// Progress is a snapshot of a running crawl's counters
+type Progress struct {
+	Visited int
+	Saved   int
+	Failed  int
+	Pending int   // Items queued or being processed
+	Queued  int   // Items waiting in the queue
+	Seen    int   // Distinct URLs ever queued
+	Bytes   int64 // Saved so far
+
+	Buffered int64 // Response bytes held in memory
+
+	Summary Summary // Visited URLs by status class and type
+}
+
+// Scraper crawls a site starting from a root URL and saves every in-scope
+// resource with links rewritten to point at the local copies
+type Scraper struct {
+	config   Config
+	queue    *Queue
+	tracker  *Tracker
+	links    *LinkGraph
+	scope    *outOfScope
+	filter   *urlutil.Filter
+	fetcher  *fetcher.Fetcher
+	storage  *storage.Storage
+	state    *state.Writer
+	logger   *slog.Logger
+	buffers  *bufferPool
+	inflight flightGroup
+
+	// publisher publishes finished directories; nil unless PublishDir is set
+	publisher *publisher
+
+	// checkpoints flushes progress during the crawl; nil unless
+	// CheckpointInterval or CheckpointPages is set
+	checkpoints *checkpointer
+
+	// snapshot is the Wayback Machine timestamp pages are fetched from when
+	// mirroring an archived site ("" = fetch live)
+	snapshot string
+
+	pending atomic.Int64 // Items queued or being processed
+	saved   atomic.Int64
+	pages   atomic.Int64 // Saved resources that are pages, for NotifyEvery
+	failed  atomic.Int64
+	bytes   atomic.Int64
+
+	mismatched atomic.Int64
+	coalesced  atomic.Int64
+
+	// formActions maps each form action found to the first page with it;
+	// forms counts the forms
+	formActions sync.Map
+	forms       atomic.Int64
+
+	// complete holds the queued URLs fetched and saved, or redirecting to
+	// a saved resource, for estimating completeness; fetched counts them
+	complete sync.Map
+	fetched  atomic.Int64
+
+	// sitemapURLs lists the in-scope URLs found in sitemaps
+	sitemapURLs []string
+
+	// robotsFiles holds each origin's robots.txt once fetched; crawlDelays
+	// holds the hosts whose Crawl-delay has been applied
+	robotsFiles sync.Map
+	crawlDelays sync.Map
+
+	errorRateFired atomic.Bool
+}
+
+// New creates a new scraper with the given configuration
+func New(config Config) (*Scraper, error) {
+	// A Wayback Machine snapshot root mirrors the original site as archived
+	rootURL := config.RootURL
+	timestamp, original, fromSnapshot := urlutil.ParseWayback(rootURL)
+	if fromSnapshot {
+		rootURL = original
+	}
+
+	root, err := urlutil.Normalize(rootURL, "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid root URL: %w", err)
+	}
+	config.RootURL = config.Pagination.Apply(urlutil.StripFragment(root), rawQuery(rootURL))
+
+	config.RootURL = config.SchemePolicy.Apply(config.RootURL)
+	if !config.SchemePolicy.Allows(strings.SplitN(config.RootURL, ":", 2)[0]) {
+		return nil, fmt.Errorf("root URL %s is not https, as scheme policy %s requires", config.RootURL, config.SchemePolicy)
+	}
+	config.Fetcher.UpgradeHTTPS = config.Fetcher.UpgradeHTTPS || config.SchemePolicy == urlutil.SchemeUpgrade
+
+	u, err := url.Parse(config.RootURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid root URL: %w", err)
+	}
+	if u.Scheme == "file" {
+		config.Fetcher.LocalFiles = true
+		if config.LocalBase == "" {
+			config.LocalBase = path.Dir(u.Path)
+		}
+	}
+
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+	if config.ParseWorkers < 1 {
+		config.ParseWorkers = runtime.GOMAXPROCS(0)
+	}
+	if config.WriteWorkers < 1 {
+		config.WriteWorkers = config.Workers
+	}
+	config.Metrics = metrics.OrNop(config.Metrics)
+	if config.Fetcher.Metrics == nil {
+		config.Fetcher.Metrics = config.Metrics
+	}
+
+	filter := urlutil.NewFilter(config.RootURL, config.Whitelist)
+	if err := filter.AllowSchemes(config.Schemes...); err != nil {
+		return nil, err
+	}
+	filter.SetSchemePolicy(config.SchemePolicy)
+
+	backend := config.Backend
+	if backend == nil {
+		backend = storage.Dir(config.OutputDir)
+	}
+	store := storage.NewWith(backend)
+	if !store.Local() && (config.PublishDir != "" || config.RsyncFriendly) {
+		return nil, fmt.Errorf("publishing and rsync-friendly output need an output directory on disk, not %s", backend)
+	}
+	if config.Version != "" {
+		if err := storage.ValidVersion(config.Version); err != nil {
+			return nil, err
+		}
+	}
+	if config.Version != "" || config.CompareOnly {
+		if store, err = storage.OpenWith(backend); err != nil {
+			return nil, err
+		}
+	}
+	if config.Version != "" && !config.CompareOnly {
+		// Files of a previous crawl of this version are replaced, not merged
+		store.Manifest().RemoveVersion(config.Version)
+	}
+
+	store.SignWith(config.SignKey)
+	store.RsyncFriendly(config.RsyncFriendly)
+	store.CompressManifest(config.CompressManifest)
+	store.CompareOnly(config.CompareOnly)
+
+	queue := NewQueue()
+	queue.SetPriorityMatcher(config.Priority)
+	queue.SetPoliteness(config.PolitenessDelay)
+	queue.SetReferrerLimit(config.ReferrerLimit)
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	var pub *publisher
+	if config.PublishDir != "" && !config.CompareOnly {
+		pub = newPublisher(config.OutputDir, config.PublishDir, config.PublishSymlinks, logger)
+	}
+
+	return &Scraper{
+		config:    config,
+		queue:     queue,
+		tracker:   NewTracker(),
+		links:     NewLinkGraph(),
+		scope:     newOutOfScope(config.RootURL),
+		filter:    filter,
+		fetcher:   fetcher.New(config.Fetcher),
+		storage:   store,
+		logger:    logger,
+		buffers:   newBufferPool(config.MaxBufferedBytes),
+		publisher: pub,
+		snapshot:  timestamp,
+	}, nil
+}
+
+// Tracker returns the scraper's visited URL tracker
+func (s *Scraper) Tracker() *Tracker {
+	return s.tracker
+}
+
+// Links returns the graph of links discovered during the crawl
+func (s *Scraper) Links() *LinkGraph {
+	return s.links
+}
+
+// OutOfScopeLinks returns every http(s) link target discovered during the
+// crawl that lies outside the crawl scope, sorted
+func (s *Scraper) OutOfScopeLinks() []string {
+	var links []string
+	for _, target := range s.links.Targets() {
+		if !strings.HasPrefix(target, "http:") && !strings.HasPrefix(target, "https:") {
+			continue
+		}
+		if allowed, _ := s.filter.IsAllowed(target); !allowed {
+			links = append(links, target)
+		}
+	}
+	return links
+}
+
+// FormActions returns the URLs the forms of saved pages submit to, sorted,
+// each with the first page found containing a form submitting to it
+func (s *Scraper) FormActions() []FormAction {
+	var actions []FormAction
+	s.formActions.Range(func(action, page any) bool {
+		actions = append(actions, FormAction{Action: action.(string), Page: page.(string)})
+		return true
+	})
+	sort.Slice(actions, func(i, j int) bool { return actions[i].Action < actions[j].Action })
+	return actions
+}
+
+// FormAction is a script a form on a saved page submits to
+type FormAction struct {
+	Action string
+	Page   string
+}
+
+// Progress returns the crawl's current counters; it is safe to call while
+// Run is in progress
+func (s *Scraper) Progress() Progress {
+	return Progress{
+		Visited: s.tracker.VisitedCount(),
+		Saved:   int(s.saved.Load()),
+		Failed:  int(s.failed.Load()),
+		Pending: int(s.pending.Load()),
+		Queued:  s.queue.Len(),
+		Seen:    s.queue.Seen(),
+		Bytes:   s.bytes.Load(),
+
+		Buffered: s.buffers.inUse(),
+
+		Summary: s.tracker.Summary(),
+	}
+}
+
+// Run crawls until the queue is exhausted or the context is cancelled
+func (s *Scraper) Run(ctx context.Context) (*Result, error) {
+	start := time.Now()
+
+	if s.config.StatePath != "" {
+		w, err := state.Create(s.config.StatePath)
+		if err != nil {
+			return nil, err
+		}
+		defer w.Close()
+		s.state = w
+	}
+	s.checkpoints = newCheckpointer(s.config.CheckpointInterval, s.config.CheckpointPages, s.storage, s.state, s.logger)
+	s.checkpoints.start()
+
+	knownDead := s.markKnownDead()
+	s.enqueue(s.config.RootURL, urlutil.ResourceHTML, 0, "")
+
+	sitemapURLs := 0
+	if s.config.Sitemap {
+		sitemapURLs = s.seedSitemaps(ctx)
+	}
+
+	p := s.startPipeline(ctx)
+	var wg sync.WaitGroup
+	for i := 0; i < s.config.Workers; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			s.worker(ctx, p, id)
+		}(i + 1)
+	}
+	wg.Wait()
+	p.wait()
+	s.checkpoints.stop()
+
+	result := &Result{
+		Visited:     s.tracker.VisitedCount(),
+		Saved:       int(s.saved.Load()),
+		Failed:      int(s.failed.Load()),
+		Mismatched:  int(s.mismatched.Load()),
+		Coalesced:   int(s.coalesced.Load()),
+		Forms:       int(s.forms.Load()),
+		Published:   s.published(),
+		Unchanged:   s.storage.Unchanged(),
+		Changes:     s.storage.Changes(),
+		Checkpoints: s.checkpoints.count(),
+		KnownDead:   knownDead,
+		Whitelisted: s.whitelisted(),
+
+		OutOfScopeHosts: s.OutOfScopeHosts(),
+		Bytes:           s.bytes.Load(),
+		Duration:        time.Since(start),
+
+		SitemapURLs:  sitemapURLs,
+		Completeness: s.completeness(),
+		Summary:      s.tracker.Summary(),
+	}
+
+	if err := s.storage.WriteManifest(); err != nil {
+		s.logger.Error("writing manifest", "error", err)
+	}
+	if s.config.Version != "" {
+		if err := s.storage.WriteVersionIndex(); err != nil {
+			s.logger.Error("writing version index", "error", err)
+		}
+	}
+	if s.config.DirectoryListings {
+		listings, err := s.storage.WriteDirectoryListings()
+		if err != nil {
+			s.logger.Error("writing directory listings", "error", err)
+		}
+		result.Listings = listings
+	}
+	// The manifest and version index are only complete now
+	if s.publisher != nil && ctx.Err() == nil {
+		s.publisher.publishDir(".")
+		result.Published = s.published()
+	}
+
+	s.emit(hooks.EventCrawlFinished, fmt.Sprintf("Crawl of %s finished: %d visited, %d saved, %d failed in %s",
+		s.config.RootURL, result.Visited, result.Saved, result.Failed, result.Duration.Round(time.Second)))
+	s.config.Hooks.Wait()
+
+	return result, ctx.Err()
+}
+
+// worker takes items from the queue and fetches them, passing them on to
+// the later stages of p, until no work remains or ctx is cancelled. id
+// identifies the worker in logs
+func (s *Scraper) worker(ctx context.Context, p *pipeline, id int) {
+	logger := s.logger.With("worker", id)
+	for {
+		item, err := s.queue.Next(ctx)
+		if err != nil {
+			return
+		}
+		s.applyCrawlDelay(ctx, item.URL)
+
+		if !s.process(ctx, p, newJob(item, logger)) {
+			s.finish(item)
+		}
+	}
+}
+
+// finish marks a queued item as done with
+func (s *Scraper) finish(item *QueueItem) {
+	if relPath, ok := s.publishPath(item.URL); ok {
+		s.publisher.done(relPath)
+	}
+
+	// The last item finished without discovering new ones
+	if s.pending.Add(-1) == 0 {
+		s.queue.Close()
+	}
+}
+
+// enqueue adds a URL found in referrer to the queue, keeping the pending
+// count in sync. Reports whether the URL was new
+func (s *Scraper) enqueue(url string, resourceType urlutil.ResourceType, depth int, referrer string) bool {
+	// Count the item before it becomes visible to other workers, so the
+	// pending count never drops to zero while work remains
+	s.pending.Add(1)
+	add := func() bool { return s.queue.AddFrom(url, resourceType, depth, referrer) }
+	var queued bool
+	if relPath, ok := s.publishPath(url); ok {
+		queued = s.publisher.queue(relPath, add)
+	} else {
+		queued = add()
+	}
+	if !queued {
+		s.pending.Add(-1)
+	}
+	return queued
+}
+
+// outOfSection reports whether a URL is a page outside the sections the
+// crawl is scoped to
+func (s *Scraper) outOfSection(url string, resourceType urlutil.ResourceType) bool {
+	return s.config.Sections != nil && resourceType == urlutil.ResourceHTML && !s.config.Sections.Match(url)
+}
+
+// publishPath returns the storage path the publisher tracks a URL under,
+// reporting false unless publishing
+func (s *Scraper) publishPath(url string) (string, bool) {
+	if s.publisher == nil {
+		return "", false
+	}
+	relPath, err := s.pathFor(url)
+	return relPath, err == nil
+}
+
+// markKnownDead marks the URLs of Config.KnownDead visited, returning how
+// many it marked
+func (s *Scraper) markKnownDead() int {
+	marked := 0
+	for _, rec := range s.config.KnownDead {
+		rt, err := urlutil.ParseResourceType(rec.Type)
+		if err != nil {
+			rt = urlutil.DetectResourceType(rec.URL, "")
+		}
+		if s.tracker.TryMarkVisitedAs(rec.URL, rec.StatusCode, rt) {
+			marked++
+		}
+	}
+	if marked > 0 {
+		s.logger.Info("skipping URLs that failed permanently before", "count", marked)
+	}
+	return marked
+}
+
+// published returns the number of files published to PublishDir
+func (s *Scraper) published() int {
+	if s.publisher == nil {
+		return 0
+	}
+	return int(s.publisher.published.Load())
+}
+
+// process fetches a single item unless its URL was already visited,
+// reporting whether it was passed on to the later stages of p
+func (s *Scraper) process(ctx context.Context, p *pipeline, job *job) bool {
+	if s.tracker.IsVisited(job.URL) {
+		return false
+	}
+	if s.robotsDisallowed(ctx, job.URL) {
+		err := fmt.Errorf("%w: %s", ErrRobotsDisallowed, job.URL)
+		s.tracker.MarkVisitedAs(job.URL, 0, job.Type)
+		s.record(job, nil, 0, err)
+		s.recordFailure(job, err)
+		return false
+	}
+
+	// Queueing dedups URLs, but a URL can still reach a second worker while
+	// the first is fetching it; the origin should see one request, and the
+	// waiting worker shares its outcome, recorded against the same URL
+	handed := false
+	if s.inflight.do(ctx, s.fetchURL(job.URL), func() { handed = s.fetch(ctx, p, job) }) {
+		s.coalesced.Add(1)
+		job.log.Debug("coalesced with in-flight fetch")
+	}
+	return handed
+}
+
+// fetch fetches a single item and passes it to the next stage of p,
+// reporting whether it did; the item is finished once that stage is done
+// with it
+func (s *Scraper) fetch(ctx context.Context, p *pipeline, job *job) (handed bool) {
+	item := job.QueueItem
+	pg := &page{job: job}
+	defer func() {
+		if !handed {
+			pg.release()
+		}
+	}()
+
+	// Only pages are parsed and rewritten; other resources stream straight
+	// to disk instead of being held in memory
+	var w io.Writer
+	if item.Type == urlutil.ResourceHTML {
+		pg.buf = s.buffers.get(ctx)
+		w = pg.buf
+	} else {
+		var err error
+		if pg.file, err = s.storage.Create(); err != nil {
+			s.recordFailure(job, err)
+			return false
+		}
+		w = pg.file
+	}
+
+	fetchCtx := fetcher.WithLogger(ctx, job.log)
+	if retries, ok := s.config.MaxRetriesByType[item.Type]; ok {
+		fetchCtx = fetcher.WithMaxRetries(fetchCtx, retries)
+	}
+	fetchStart := time.Now()
+	resp, err := s.fetcher.Fetch(fetchCtx, s.fetchURL(item.URL), w)
+	elapsed := time.Since(fetchStart)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false
+		}
+		s.tracker.MarkVisitedAs(item.URL, 0, item.Type)
+		s.record(job, nil, elapsed, err)
+		s.recordFailure(job, err)
+		return false
+	}
+	s.tracker.MarkVisitedAs(item.URL, resp.StatusCode, resp.ResourceType)
+	job.log.Debug("fetched", "status", resp.StatusCode, "bytes", resp.BytesWritten,
+		"attempts", resp.Attempts, "duration", elapsed.Round(time.Millisecond))
+	pg.resp = resp
+
+	// A redirect within scope makes the requested URL an alias of its
+	// target, which is saved once under its own path
+	pg.saveURL = item.URL
+	duplicate := false
+	if final := s.finalURL(s.crawlURL(resp.FinalURL)); final != "" && final != item.URL {
+		// A redirect off the site usually lands on a login or parked page,
+		// which must not be saved under the requested URL's name
+		if allowed, _ := s.filter.IsAllowed(final); !allowed {
+			err := fmt.Errorf("%w: redirected to %s", ErrFiltered, final)
+			s.record(job, resp, elapsed, err)
+			s.recordFailure(job, err)
+			return false
+		}
+		s.tracker.AddAlias(item.URL, final)
+		duplicate = !s.tracker.TryMarkVisitedAs(final, resp.StatusCode, resp.ResourceType)
+		pg.saveURL = final
+	}
+
+	// An error page served in place of an asset would corrupt the mirror
+	// if saved under the asset's name
+	if urlutil.ContentTypeMismatch(pg.saveURL, resp.ContentType) {
+		err := fmt.Errorf("%w: served as %s", ErrContentTypeMismatch, resp.ContentType)
+		s.record(job, resp, elapsed, err)
+		s.mismatched.Add(1)
+		s.recordFailure(job, err)
+		return false
+	}
+
+	s.record(job, resp, elapsed, nil)
+	if duplicate {
+		job.log.Info("skipping redirect to already fetched page", "target", pg.saveURL)
+		s.markComplete(item.URL)
+		return false
+	}
+
+	if pg.relPath, err = s.pathFor(pg.saveURL); err != nil {
+		s.recordFailure(job, err)
+		return false
+	}
+
+	pg.robots = parser.HeaderRobots(resp.Headers.Values("X-Robots-Tag"))
+
+	if resp.ResourceType != urlutil.ResourceHTML {
+		return send(ctx, p.writes, pg)
+	}
+
+	switch {
+	case pg.buf != nil:
+		pg.body = pg.buf.Bytes()
+	default:
+		// Served as a page after all, e.g. from an extensionless URL
+		if pg.body, err = pg.file.ReadAll(); err != nil {
+			s.recordFailure(job, err)
+			return false
+		}
+		pg.file.Discard()
+		pg.file = nil
+	}
+	return send(ctx, p.parses, pg)
+}
+
+// markComplete records that a queued URL was mirrored
+func (s *Scraper) markComplete(url string) {
+	if _, loaded := s.complete.LoadOrStore(url, true); !loaded {
+		s.fetched.Add(1)
+	}
+}
+
+// processHTML enqueues in-scope links from a page and rewrites them to
+// relative paths. pageURL is the URL the page was served from, which links
+// are resolved against. Returns the rewritten document, the page's robots
+// directives merged with those from the response headers, and its TWiki
+// topic metadata
+func (s *Scraper) processHTML(item *QueueItem, pageURL, relPath string, body []byte, contentType string, robots parser.Robots) ([]byte, parser.Robots, parser.Topic, error) {
+	decoded, err := parser.NewUTF8Reader(bytes.NewReader(body), contentType)
+	if err != nil {
+		return nil, robots, parser.Topic{}, err
+	}
+
+	doc, err := parser.Parse(decoded)
+	if err != nil {
+		return nil, robots, parser.Topic{}, err
+	}
+
+	robots = robots.Merge(parser.MetaRobots(doc))
+	followLinks := s.config.MaxDepth <= 0 || item.Depth < s.config.MaxDepth
+
+	// A nofollow page's links lead nowhere, but its images and stylesheets
+	// are still needed to show it
+	noFollow := robots.NoFollow && !s.config.IgnoreRobotsMeta
+
+	refs := parser.ExtractReferences(doc, pageURL)
+	for i := range refs {
+		refs[i].URL = s.linkURL(refs[i])
+	}
+	for _, link := range parser.MergeLinks(refs) {
+		if noFollow && !link.Kind.Embedded() {
+			continue
+		}
+
+		policy := s.linkPolicy(link)
+		if policy == parser.LinkIgnore {
+			continue
+		}
+
+		edge := Edge{From: pageURL, To: link.URL, Kind: link.Kind, NoFollow: link.NoFollow, External: link.External}
+		resourceType := urlutil.DetectResourceType(link.URL, "")
+		follow := policy == parser.LinkFollow && (resourceType != urlutil.ResourceHTML || followLinks)
+		if s.config.PagesOnly && resourceType != urlutil.ResourceHTML {
+			follow = false
+		}
+		if s.outOfSection(link.URL, resourceType) {
+			follow = false
+		}
+		allowed, _ := s.filter.IsAllowed(link.URL)
+		if !allowed {
+			allowed = s.outOfScopeLink(QueueItem{URL: link.URL, Type: resourceType, Depth: item.Depth + 1, Referrer: pageURL}, link.Kind, follow)
+		}
+		if allowed && follow {
+			s.enqueue(link.URL, resourceType, item.Depth+1, pageURL)
+			edge.Followed = true
+		}
+		s.links.Add(edge)
+	}
+
+	// Forms run server-side scripts, so their actions are reported rather
+	// than crawled, and the forms themselves disabled in the mirror
+	for _, action := range parser.FormActions(doc, pageURL) {
+		s.formActions.LoadOrStore(action, pageURL)
+	}
+	s.forms.Add(int64(parser.ApplyFormPolicy(doc, s.config.Forms)))
+
+	rewriteRefs := parser.RewriteReferences
+	if s.config.RecordOriginalURLs {
+		rewriteRefs = parser.RewriteReferencesRecording
+	}
+	rewriteRefs(doc, pageURL, func(ref parser.Reference) (string, bool) {
+		absURL := s.linkURL(ref)
+		if allowed, _ := s.filter.IsAllowed(absURL); !allowed {
+			return "", false
+		}
+
+		// Links to a known redirect point at the saved target instead
+		target, err := s.pathFor(s.tracker.Canonical(absURL))
+		if err != nil {
+			return "", false
+		}
+
+		return storage.RelativePath(relPath, target), true
+	})
+
+	out := &bytes.Buffer{}
+	if err := parser.Render(out, doc); err != nil {
+		return nil, robots, parser.Topic{}, err
+	}
+
+	return out.Bytes(), robots, parser.TopicInfo(doc), nil
+}
+
+// pathFor returns the storage path for a URL, under the version directory
+// when mirroring a documentation version
+func (s *Scraper) pathFor(rawURL string) (string, error) {
+	var relPath string
+	var err error
+	if strings.HasPrefix(rawURL, "file:") {
+		relPath, err = storage.LocalPathFor(rawURL, s.config.LocalBase)
+	} else {
+		relPath, err = storage.PathFor(rawURL)
+	}
+	if err != nil || s.config.Version == "" {
+		return relPath, err
+	}
+	return s.config.Version + "/" + relPath, nil
+}
+
+// crawlURL maps a URL found while crawling to the URL it is crawled as: the
+// original of a snapshot URL, with the scheme the SchemePolicy gives it
+func (s *Scraper) crawlURL(rawURL string) string {
+	return s.config.SchemePolicy.Apply(s.originalURL(rawURL))
+}
+
+// linkURL maps a reference found while crawling to the URL it is crawled
+// as: its crawlURL, with the query parameters the Pagination keeps
+func (s *Scraper) linkURL(ref parser.Reference) string {
+	return s.config.Pagination.Apply(s.crawlURL(ref.URL), ref.Query)
+}
+
+// finalURL maps the URL a response was served from to the URL it is saved
+// as: normalized and without its fragment, and without its query unless
+// the Pagination keeps some of it, as crawled URLs are
+func (s *Scraper) finalURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return urlutil.StripFragment(rawURL)
+	}
+	normalized, err := urlutil.Normalize(rawURL, "")
+	if err != nil {
+		return urlutil.StripFragment(rawURL)
+	}
+	normalized = urlutil.StripFragment(normalized)
+	if u.RawQuery == "" && !u.ForceQuery {
+		return normalized
+	}
+	return s.config.Pagination.Apply(normalized, u.RawQuery)
+}
+
+// rawQuery returns the query of a URL, or "" if it cannot be parsed
+func rawQuery(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.RawQuery
+}
+
+// fetchURL returns the URL to fetch a resource from, which is its archived
+// copy when mirroring from a snapshot
+func (s *Scraper) fetchURL(rawURL string) string {
+	if s.snapshot == "" {
+		return rawURL
+	}
+	return urlutil.WaybackURL(s.snapshot, rawURL)
+}
+
+// originalURL maps a snapshot URL back to the URL it archives when mirroring
+// from a snapshot. Archived pages may still contain links rewritten to
+// point into the archive
+func (s *Scraper) originalURL(rawURL string) string {
+	if s.snapshot == "" {
+		return rawURL
+	}
+	original := urlutil.UnwrapWayback(rawURL)
+	if normalized, err := urlutil.Normalize(original, ""); err == nil {
+		return normalized
+	}
+	return original
+}
+
+// linkPolicy returns the most restrictive policy that applies to a link
+func (s *Scraper) linkPolicy(link parser.Link) parser.LinkPolicy {
+	var applicable []parser.LinkPolicy
+	if link.NoFollow {
+		applicable = append(applicable, s.config.NoFollowLinks)
+	}
+	if link.External {
+		applicable = append(applicable, s.config.ExternalLinks)
+	}
+
+	policy := parser.LinkFollow
+	for _, p := range applicable {
+		switch p {
+		case parser.LinkIgnore:
+			return parser.LinkIgnore
+		case parser.LinkRecord:
+			policy = parser.LinkRecord
+		}
+	}
+	return policy
+}
+
+// record appends the outcome of a fetch to the crawl state file, if enabled
+func (s *Scraper) record(job *job, resp *fetcher.Response, elapsed time.Duration, fetchErr error) {
+	if s.state == nil {
+		return
+	}
+
+	item := job.QueueItem
+	rec := state.Record{
+		URL:        item.URL,
+		Type:       item.Type.String(),
+		Referrer:   item.Referrer,
+		Trace:      job.trace,
+		DurationMs: elapsed.Milliseconds(),
+		Time:       time.Now(),
+	}
+	if u, err := url.Parse(item.URL); err == nil {
+		rec.Host = u.Host
+	}
+	if resp != nil {
+		rec.StatusCode = resp.StatusCode
+		rec.Type = resp.ResourceType.String()
+		rec.ContentType = resp.ContentType
+		rec.Bytes = resp.BytesWritten
+		rec.DNSMs = resp.Timing.DNS.Milliseconds()
+		rec.ConnectMs = resp.Timing.Connect.Milliseconds()
+		rec.TLSMs = resp.Timing.TLS.Milliseconds()
+		rec.TTFBMs = resp.Timing.TTFB.Milliseconds()
+		rec.Anomaly = resp.EncodingAnomaly
+	}
+	if fetchErr != nil {
+		if rec.StatusCode == 0 {
+			rec.StatusCode = fetcher.StatusCode(fetchErr)
+		}
+		rec.Error = fetchErr.Error()
+		rec.Cause = Cause(fetchErr)
+		rec.Mismatch = errors.Is(fetchErr, ErrContentTypeMismatch)
+	}
+
+	if err := s.state.Add(rec); err != nil {
+		job.log.Error("recording state", "error", err)
+	}
+}
+
+// recordFailure logs a failed resource and checks the error rate threshold
+func (s *Scraper) recordFailure(job *job, err error) {
+	job.log.Warn("failed", "error", err)
+	failed := s.failed.Add(1)
+	s.config.Metrics.Add(metrics.Failed, 1, metrics.Label{Name: "cause", Value: Cause(err)})
+
+	threshold := s.config.ErrorRateThreshold
+	if threshold <= 0 {
+		return
+	}
+
+	visited := int64(s.tracker.VisitedCount())
+	if visited < errorRateMinSamples {
+		return
+	}
+
+	rate := float64(failed) / float64(visited)
+	if rate > threshold && s.errorRateFired.CompareAndSwap(false, true) {
+		s.emit(hooks.EventErrorRateExceeded, fmt.Sprintf("Error rate %.0f%% exceeds %.0f%% (%d of %d failed) crawling %s",
+			rate*100, threshold*100, failed, visited, s.config.RootURL))
+	}
+}
+
+// emit sends an event with current crawl counts to the configured hooks
+func (s *Scraper) emit(eventType hooks.EventType, message string) {
+	s.config.Hooks.Emit(hooks.Event{
+		Type:    eventType,
+		Message: message,
+		Visited: s.tracker.VisitedCount(),
+		Saved:   int(s.saved.Load()),
+		Failed:  int(s.failed.Load()),
+	})
+}
@@ -0,0 +1,1772 @@
+package scraper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aldehir/ue2-docs/internal/fetcher"
+	"github.com/aldehir/ue2-docs/internal/hooks"
+	"github.com/aldehir/ue2-docs/internal/manifest"
+	"github.com/aldehir/ue2-docs/internal/parser"
+	"github.com/aldehir/ue2-docs/internal/state"
+	"github.com/aldehir/ue2-docs/internal/storage"
+	"github.com/aldehir/ue2-docs/internal/urlutil"
+	"github.com/aldehir/ue2-docs/internal/wayback"
+)
+
+// newTestSite serves a small documentation site under /docs/
+func newTestSite(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	pages := map[string]string{
+		"/docs/SiteMap.html": `<html><body>
+<a href="Page1.html">One</a>
+<a href="sub/Page2.html">Two</a>
+<a href="Missing.html">Missing</a>
+<a href="/outside/Page.html">Outside</a>
+<img src="img/logo.png">
+</body></html>`,
+		"/docs/Page1.html": `<html><body><a href="SiteMap.html">Home</a></body></html>`,
+		"/docs/sub/Page2.html": `<html><body><a href="../Page1.html#top">One</a>
+<p>Parents: SiteMap</p><p>r1.3 - 05 Jun 2009 - Main.SomeUser</p></body></html>`,
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/docs/img/logo.png" {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("PNG"))
+			return
+		}
+
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(body))
+	}))
+}
+
+func testConfig(rootURL, outputDir string) Config {
+	fetcherConfig := fetcher.DefaultConfig()
+	fetcherConfig.MaxRetries = 0
+
+	return Config{
+		RootURL:   rootURL,
+		OutputDir: outputDir,
+		Workers:   4,
+		Fetcher:   fetcherConfig,
+	}
+}
+
+type recordingHook struct {
+	mu     sync.Mutex
+	events []hooks.Event
+}
+
+func (h *recordingHook) Notify(ctx context.Context, ev hooks.Event) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, ev)
+	return nil
+}
+
+func (h *recordingHook) count(eventType hooks.EventType) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n := 0
+	for _, ev := range h.events {
+		if ev.Type == eventType {
+			n++
+		}
+	}
+	return n
+}
+
+func TestScraper_Run(t *testing.T) {
+	server := newTestSite(t)
+	defer server.Close()
+
+	outputDir := t.TempDir()
+	s, err := New(testConfig(server.URL+"/docs/SiteMap.html", outputDir))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	result, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if result.Visited != 5 {
+		t.Errorf("Visited = %d, want 5", result.Visited)
+	}
+	if result.Saved != 4 {
+		t.Errorf("Saved = %d, want 4", result.Saved)
+	}
+	if result.Failed != 1 {
+		t.Errorf("Failed = %d, want 1", result.Failed)
+	}
+
+	progress := s.Progress()
+	if progress.Visited != result.Visited || progress.Saved != result.Saved || progress.Failed != result.Failed ||
+		progress.Bytes != result.Bytes || progress.Pending != 0 || progress.Queued != 0 || progress.Seen != 5 {
+		t.Errorf("Progress() = %+v after %+v", progress, result)
+	}
+
+	// Missing.html is both queued and linked from the root
+	wantCompleteness := Completeness{
+		Discovered: Coverage{Total: 5, Fetched: 4},
+		RootLinks:  Coverage{Total: 4, Fetched: 3},
+	}
+	if result.Completeness != wantCompleteness {
+		t.Errorf("Completeness = %+v, want %+v", result.Completeness, wantCompleteness)
+	}
+
+	if s.Tracker().IsVisited(server.URL + "/outside/Page.html") {
+		t.Error("out-of-scope URL should not be visited")
+	}
+	if out := s.OutOfScopeLinks(); len(out) != 1 || out[0] != server.URL+"/outside/Page.html" {
+		t.Errorf("OutOfScopeLinks() = %v, want the outside page", out)
+	}
+
+	sitemap, err := storage.PathFor(server.URL + "/docs/SiteMap.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(sitemap)))
+	if err != nil {
+		t.Fatalf("reading saved sitemap: %v", err)
+	}
+
+	for _, want := range []string{`href="Page1.html"`, `href="sub/Page2.html"`, `src="img/logo.png"`, `href="/outside/Page.html"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("saved sitemap missing %s", want)
+		}
+	}
+
+	m, err := manifest.Load(filepath.Join(outputDir, manifest.Filename))
+	if err != nil {
+		t.Fatalf("loading manifest: %v", err)
+	}
+	if m.Len() != result.Saved {
+		t.Errorf("manifest has %d entries, want %d", m.Len(), result.Saved)
+	}
+	if e, ok := m.Get(sitemap); !ok || e.URL != server.URL+"/docs/SiteMap.html" {
+		t.Errorf("manifest entry for sitemap = %+v, %v", e, ok)
+	}
+
+	page2, _ := storage.PathFor(server.URL + "/docs/sub/Page2.html")
+	data, err = os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(page2)))
+	if err != nil {
+		t.Fatalf("reading saved page: %v", err)
+	}
+	if !strings.Contains(string(data), `href="../Page1.html#top"`) {
+		t.Errorf("expected relative link with fragment, got %s", data)
+	}
+	if e, _ := m.Get(page2); e.Author != "SomeUser" || e.Revision != "r1.3" || e.Parent != "SiteMap" {
+		t.Errorf("manifest entry for page 2 = %+v, want its TWiki topic metadata", e)
+	}
+
+	// Assets are streamed to disk rather than buffered
+	logo, _ := storage.PathFor(server.URL + "/docs/img/logo.png")
+	if e, ok := m.Get(logo); !ok || e.Size != 3 || e.SHA256 != fmt.Sprintf("%x", sha256.Sum256([]byte("PNG"))) {
+		t.Errorf("manifest entry for logo = %+v, %v", e, ok)
+	}
+	if data, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(logo))); err != nil || string(data) != "PNG" {
+		t.Errorf("saved logo = %q, %v", data, err)
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(outputDir, ".tmp-*"))
+	if len(leftovers) != 0 {
+		t.Errorf("pending files left behind: %v", leftovers)
+	}
+}
+
+func TestScraper_KeepOriginal(t *testing.T) {
+	server := newTestSite(t)
+	defer server.Close()
+
+	outputDir := t.TempDir()
+	config := testConfig(server.URL+"/docs/SiteMap.html", outputDir)
+	config.KeepOriginal = true
+	s, _ := New(config)
+	if _, err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	page2, _ := storage.PathFor(server.URL + "/docs/sub/Page2.html")
+	data, err := os.ReadFile(filepath.Join(outputDir, storage.OriginalDir, filepath.FromSlash(page2)))
+	if err != nil {
+		t.Fatalf("reading original page: %v", err)
+	}
+	if !strings.Contains(string(data), `<a href="../Page1.html#top">`) || strings.Contains(string(data), "<head>") {
+		t.Errorf("original page was modified: %s", data)
+	}
+
+	logo, _ := storage.PathFor(server.URL + "/docs/img/logo.png")
+	if _, err := os.Stat(filepath.Join(outputDir, storage.OriginalDir, filepath.FromSlash(logo))); !os.IsNotExist(err) {
+		t.Errorf("original kept for an image, stat error = %v", err)
+	}
+}
+
+func TestScraper_StageWorkers(t *testing.T) {
+	server := newTestSite(t)
+	defer server.Close()
+
+	// A single parser and writer behind many fetchers still finish the crawl
+	config := testConfig(server.URL+"/docs/SiteMap.html", t.TempDir())
+	config.Workers = 8
+	config.ParseWorkers = 1
+	config.WriteWorkers = 1
+	s, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Saved != 4 || result.Failed != 1 {
+		t.Errorf("Run() = %+v, want 4 saved and 1 failed", result)
+	}
+	if p := s.Progress(); p.Pending != 0 || p.Buffered != 0 {
+		t.Errorf("Progress() = %+v, want nothing pending or buffered", p)
+	}
+}
+
+func TestScraper_MaxDepth(t *testing.T) {
+	server := newTestSite(t)
+	defer server.Close()
+
+	config := testConfig(server.URL+"/docs/SiteMap.html", t.TempDir())
+	config.MaxDepth = 0
+	s, _ := New(config)
+	if _, err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	unlimited := s.Tracker().VisitedCount()
+
+	server2 := newTestSite(t)
+	defer server2.Close()
+
+	config = testConfig(server2.URL+"/docs/sub/Page2.html", t.TempDir())
+	config.MaxDepth = 1
+	s, _ = New(config)
+	if _, err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// Page2 -> Page1 (depth 1) -> SiteMap (depth 2, not followed)
+	if s.Tracker().IsVisited(server2.URL + "/docs/SiteMap.html") {
+		t.Error("page beyond max depth should not be visited")
+	}
+	if s.Tracker().VisitedCount() >= unlimited {
+		t.Errorf("depth-limited crawl visited %d URLs, expected fewer than %d", s.Tracker().VisitedCount(), unlimited)
+	}
+}
+
+func TestScraper_Logging(t *testing.T) {
+	server := newTestSite(t)
+	defer server.Close()
+
+	logs := &bytes.Buffer{}
+	statePath := filepath.Join(t.TempDir(), "crawl.db")
+	config := testConfig(server.URL+"/docs/SiteMap.html", t.TempDir())
+	config.StatePath = statePath
+	config.Logger = slog.New(slog.NewJSONHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	s, _ := New(config)
+	if _, err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	var failure struct {
+		Msg      string
+		Worker   int
+		Trace    string
+		URL      string
+		Referrer string
+	}
+	fetched := 0
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var entry struct{ Msg string }
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", line, err)
+		}
+		switch entry.Msg {
+		case "fetched":
+			fetched++
+		case "failed":
+			json.Unmarshal([]byte(line), &failure)
+		}
+	}
+
+	if fetched != 4 {
+		t.Errorf("logged %d fetches, want 4", fetched)
+	}
+	if failure.URL != server.URL+"/docs/Missing.html" || failure.Referrer != server.URL+"/docs/SiteMap.html" ||
+		failure.Worker < 1 || failure.Worker > config.Workers || failure.Trace == "" {
+		t.Errorf("failure log = %+v, want Missing.html with worker, trace and referrer", failure)
+	}
+
+	records, err := state.Load(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range records {
+		if rec.URL == failure.URL && rec.Trace != failure.Trace {
+			t.Errorf("state record trace = %q, want %q from the log", rec.Trace, failure.Trace)
+		}
+	}
+}
+
+func TestScraper_RobotsTxt(t *testing.T) {
+	var mu sync.Mutex
+	requested := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested[r.URL.Path] = true
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.Write([]byte("User-agent: *\nDisallow: /docs/private/\n"))
+		case "/docs/Index.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><a href="Public.html">Public</a><a href="private/Secret.html">Secret</a></body></html>`))
+		case "/docs/Public.html", "/docs/private/Secret.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body>Page</body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	statePath := filepath.Join(t.TempDir(), "state.jsonl")
+	config := testConfig(server.URL+"/docs/Index.html", t.TempDir())
+	config.RobotsTxt = true
+	config.StatePath = statePath
+	s, _ := New(config)
+	result, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if requested["/docs/private/Secret.html"] {
+		t.Error("URL disallowed by robots.txt was fetched")
+	}
+	if !requested["/docs/Public.html"] {
+		t.Error("allowed URL was not fetched")
+	}
+	if result.Saved != 2 || result.Failed != 1 {
+		t.Errorf("Run() = %+v, want 2 saved and 1 failed", result)
+	}
+
+	records, err := state.Load(statePath)
+	if err != nil {
+		t.Fatalf("state.Load() error = %v", err)
+	}
+	secret := server.URL + "/docs/private/Secret.html"
+	found := false
+	for _, rec := range records {
+		if rec.URL == secret {
+			found = true
+			if rec.Cause != state.CauseRobotsDisallowed {
+				t.Errorf("Cause = %q, want %q", rec.Cause, state.CauseRobotsDisallowed)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("no state record for %s", secret)
+	}
+}
+
+func TestScraper_Sitemap(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprintf(w, "User-agent: *\nSitemap: %s/maps/index.xml\n", server.URL)
+		case "/maps/index.xml":
+			fmt.Fprintf(w, `<sitemapindex><sitemap><loc>%s/maps/docs.xml.gz</loc></sitemap></sitemapindex>`, server.URL)
+		case "/maps/docs.xml.gz":
+			gz := gzip.NewWriter(w)
+			fmt.Fprintf(gz, `<urlset><url><loc>%[1]s/docs/Orphan.html</loc></url><url><loc>%[1]s/outside/Page.html</loc></url></urlset>`, server.URL)
+			gz.Close()
+		case "/sitemap.xml":
+			fmt.Fprintf(w, `<urlset><url><loc>%[1]s/docs/Index.html</loc></url><url><loc>%[1]s/docs/Other.html#top</loc></url></urlset>`, server.URL)
+		case "/docs/Index.html", "/docs/Orphan.html", "/docs/Other.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<p>Page</p>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	config := testConfig(server.URL+"/docs/Index.html", t.TempDir())
+	config.Sitemap = true
+	s, _ := New(config)
+	result, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// The root is listed too, but was already queued
+	if result.SitemapURLs != 2 {
+		t.Errorf("SitemapURLs = %d, want 2", result.SitemapURLs)
+	}
+	for _, page := range []string{"/docs/Orphan.html", "/docs/Other.html"} {
+		if !s.Tracker().IsVisited(server.URL + page) {
+			t.Errorf("%s listed in a sitemap was not visited", page)
+		}
+	}
+	if s.Tracker().IsVisited(server.URL + "/outside/Page.html") {
+		t.Error("out-of-scope sitemap URL should not be visited")
+	}
+	if result.Saved != 3 || result.Failed != 0 {
+		t.Errorf("Run() = %+v, want 3 saved", result)
+	}
+	if result.Completeness.Sitemap != (Coverage{Total: 3, Fetched: 3}) {
+		t.Errorf("Completeness.Sitemap = %+v, want 3 of 3", result.Completeness.Sitemap)
+	}
+}
+
+func TestScraper_RobotsMeta(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/docs/Index.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><meta name="robots" content="nofollow">
+<link rel="stylesheet" href="style.css"></head>
+<body><a href="Hidden.html">Hidden</a></body></html>`))
+		case "/docs/style.css":
+			w.Header().Set("Content-Type", "text/css")
+			w.Write([]byte(`body { color: black }`))
+		case "/docs/Hidden.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("X-Robots-Tag", "noindex")
+			w.Write([]byte(`<html><body>Hidden</body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	s, _ := New(testConfig(server.URL+"/docs/Index.html", t.TempDir()))
+	if _, err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if s.Tracker().IsVisited(server.URL + "/docs/Hidden.html") {
+		t.Error("links on a nofollow page should not be followed")
+	}
+	if !s.Tracker().IsVisited(server.URL + "/docs/style.css") {
+		t.Error("stylesheet of a nofollow page should still be fetched")
+	}
+
+	outputDir := t.TempDir()
+	config := testConfig(server.URL+"/docs/Index.html", outputDir)
+	config.IgnoreRobotsMeta = true
+	s, _ = New(config)
+	if _, err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !s.Tracker().IsVisited(server.URL + "/docs/Hidden.html") {
+		t.Error("nofollow should be ignored when IgnoreRobotsMeta is set")
+	}
+
+	hidden, _ := storage.PathFor(server.URL + "/docs/Hidden.html")
+	if e, _ := s.storage.Manifest().Get(hidden); e.NoIndex {
+		t.Error("noindex should not be recorded when IgnoreRobotsMeta is set")
+	}
+}
+
+func TestScraper_LinkPolicies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/docs/Index.html":
+			w.Write([]byte(`<html><body>
+<a href="Spam.html" rel="nofollow">Spam</a>
+<a href="Mirror.html" rel="external">Mirror</a>
+</body></html>`))
+		case "/docs/Spam.html", "/docs/Mirror.html":
+			w.Write([]byte(`<html><body></body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	root := server.URL + "/docs/Index.html"
+	spam := server.URL + "/docs/Spam.html"
+	mirror := server.URL + "/docs/Mirror.html"
+
+	s, _ := New(testConfig(root, t.TempDir()))
+	if _, err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if s.Tracker().IsVisited(spam) || s.Tracker().IsVisited(mirror) {
+		t.Error("nofollow and external links should not be followed by default")
+	}
+	if edges := s.Links().Incoming(spam); len(edges) != 1 || !edges[0].NoFollow || edges[0].Followed {
+		t.Errorf("Incoming(spam) = %+v, want one unfollowed nofollow edge", edges)
+	}
+
+	config := testConfig(root, t.TempDir())
+	config.NoFollowLinks = parser.LinkFollow
+	config.ExternalLinks = parser.LinkIgnore
+	s, _ = New(config)
+	if _, err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !s.Tracker().IsVisited(spam) {
+		t.Error("nofollow link should be followed with LinkFollow")
+	}
+	if edges := s.Links().Incoming(mirror); len(edges) != 0 {
+		t.Errorf("ignored external link recorded in graph: %+v", edges)
+	}
+}
+
+func TestScraper_ScopePrompt(t *testing.T) {
+	images := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("PNG"))
+	}))
+	defer images.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><img src="%[1]s/a.png"><img src="%[1]s/b.png"></body></html>`, images.URL)
+	}))
+	defer server.Close()
+	imagesHost := strings.TrimPrefix(images.URL, "http://")
+
+	for _, answer := range []bool{false, true} {
+		var asked []string
+		config := testConfig(server.URL+"/docs/Index.html", t.TempDir())
+		config.ScopePromptLinks = 2
+		config.ScopePrompt = func(host string, links int, examples []string) bool {
+			asked = append(asked, fmt.Sprintf("%s %d %d", host, links, len(examples)))
+			return answer
+		}
+		s, _ := New(config)
+		result, err := s.Run(context.Background())
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+
+		if want := imagesHost + " 2 2"; len(asked) != 1 || asked[0] != want {
+			t.Errorf("asked %v, want once about %s", asked, want)
+		}
+		for _, image := range []string{images.URL + "/a.png", images.URL + "/b.png"} {
+			if s.Tracker().IsVisited(image) != answer {
+				t.Errorf("whitelisted %v: IsVisited(%s) = %v", answer, image, !answer)
+			}
+		}
+		if answer && (len(result.Whitelisted) != 1 || result.Whitelisted[0] != imagesHost) {
+			t.Errorf("Whitelisted = %v, want [%s]", result.Whitelisted, imagesHost)
+		}
+
+		// Declined hosts are summarized for the next crawl
+		var want []HostLinks
+		if !answer {
+			want = []HostLinks{{Host: imagesHost, Links: 2, Assets: 2}}
+		}
+		if fmt.Sprint(result.OutOfScopeHosts) != fmt.Sprint(want) {
+			t.Errorf("OutOfScopeHosts = %v, want %v", result.OutOfScopeHosts, want)
+		}
+	}
+}
+
+func TestScraper_SuggestWhitelist(t *testing.T) {
+	var fetchedLocal atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/docs/local.png" {
+			fetchedLocal.Store(true)
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><link rel="stylesheet" href="http://cdn.example/style.css"></head><body>
+<img src="http://images.example/a.png"><img src="http://images.example/b.png"><img src="local.png">
+<a href="http://forum.example/t/1">Forum</a><a href="http://forum.example/t/2">Forum</a><a href="http://forum.example/t/3">Forum</a>
+</body></html>`)
+	}))
+	defer server.Close()
+
+	config := testConfig(server.URL+"/docs/Index.html", t.TempDir())
+	config.PagesOnly = true
+	s, _ := New(config)
+	if _, err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if fetchedLocal.Load() {
+		t.Error("PagesOnly fetched an image")
+	}
+
+	want := []HostLinks{{Host: "images.example", Links: 2, Assets: 2}, {Host: "cdn.example", Links: 1, Assets: 1}}
+	if got := s.SuggestWhitelist(1); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("SuggestWhitelist(1) = %v, want %v", got, want)
+	}
+	if got := s.SuggestWhitelist(2); len(got) != 1 || got[0].Host != "images.example" {
+		t.Errorf("SuggestWhitelist(2) = %v, want images.example only", got)
+	}
+}
+
+func TestScraper_Sections(t *testing.T) {
+	var fetched sync.Map
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched.Store(r.URL.Path, true)
+		switch r.URL.Path {
+		case "/docs/Index.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><a href="UnrealScriptReference.html">API</a> <a href="EditorTutorial.html">Editor</a></body></html>`))
+		case "/docs/UnrealScriptReference.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><img src="rsrc/shot.png"><a href="EditorTutorial.html">Editor</a></body></html>`))
+		case "/docs/EditorTutorial.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body>Editor</body></html>`))
+		case "/docs/rsrc/shot.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("png"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	sections, err := urlutil.SelectSections([]urlutil.Section{
+		{Name: "API", Patterns: []string{"/docs/UnrealScript"}},
+		{Name: "Editor", Patterns: []string{"/docs/Editor*"}},
+	}, []string{"API"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outputDir := t.TempDir()
+	config := testConfig(server.URL+"/docs/Index.html", outputDir)
+	config.Sections = sections
+	s, _ := New(config)
+	if _, err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// The root page and the section's assets are fetched, other pages not
+	for _, path := range []string{"/docs/Index.html", "/docs/UnrealScriptReference.html", "/docs/rsrc/shot.png"} {
+		if _, ok := fetched.Load(path); !ok {
+			t.Errorf("%s was not fetched", path)
+		}
+	}
+	if _, ok := fetched.Load("/docs/EditorTutorial.html"); ok {
+		t.Error("page outside the section was fetched")
+	}
+
+	// Links to other sections still point where their mirrors put them
+	relPath, _ := s.pathFor(server.URL + "/docs/UnrealScriptReference.html")
+	page, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(relPath)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), `href="EditorTutorial.html"`) {
+		t.Errorf("link to the other section was not kept relative:\n%s", page)
+	}
+}
+
+func TestScraper_Forms(t *testing.T) {
+	var searched atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/docs/Index.html":
+			w.Write([]byte(`<html><body>
+<form action="/bin/search/Two"><input type="text" name="search"></form>
+<a href="Page.html">Page</a>
+</body></html>`))
+		case "/docs/Page.html":
+			w.Write([]byte(`<html><body><form action="/bin/search/Two"></form></body></html>`))
+		case "/bin/search/Two":
+			searched.Store(true)
+			w.Write([]byte(`<html><body>Results</body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	outputDir := t.TempDir()
+	config := testConfig(server.URL+"/docs/Index.html", outputDir)
+	config.Whitelist = []string{"127.0.0.1"}
+	s, _ := New(config)
+	result, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if searched.Load() {
+		t.Error("form action was fetched")
+	}
+	if result.Forms != 2 {
+		t.Errorf("Forms = %d, want 2", result.Forms)
+	}
+	actions := s.FormActions()
+	if len(actions) != 1 || actions[0].Action != server.URL+"/bin/search/Two" {
+		t.Errorf("FormActions() = %+v", actions)
+	}
+
+	relPath, _ := storage.PathFor(server.URL + "/docs/Index.html")
+	saved, err := os.ReadFile(filepath.Join(outputDir, relPath))
+	if err != nil {
+		t.Fatalf("reading saved page: %v", err)
+	}
+	if bytes.Contains(saved, []byte("action=")) || !bytes.Contains(saved, []byte("disabled")) {
+		t.Errorf("form not disabled in saved page:\n%s", saved)
+	}
+}
+
+func TestScraper_Publish(t *testing.T) {
+	liveDir := t.TempDir()
+	var publishedEarly atomic.Bool
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/docs/Index.html":
+			w.Write([]byte(`<html><body><a href="a/A.html">A</a> <a href="b/B.html">B</a></body></html>`))
+		case "/docs/a/A.html":
+			w.Write([]byte(`<html><body>A</body></html>`))
+		case "/docs/b/B.html":
+			// Section a goes live while b is still being fetched
+			relPath, _ := storage.PathFor(server.URL + "/docs/a/A.html")
+			for i := 0; i < 100 && !publishedEarly.Load(); i++ {
+				if _, err := os.Stat(filepath.Join(liveDir, relPath)); err == nil {
+					publishedEarly.Store(true)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			w.Write([]byte(`<html><body>B</body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	outputDir := t.TempDir()
+	config := testConfig(server.URL+"/docs/Index.html", outputDir)
+	config.PublishDir = liveDir
+	s, _ := New(config)
+	result, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if !publishedEarly.Load() {
+		t.Error("finished section not published during the crawl")
+	}
+	for _, page := range []string{"/docs/Index.html", "/docs/a/A.html", "/docs/b/B.html"} {
+		relPath, _ := storage.PathFor(server.URL + page)
+		if _, err := os.Stat(filepath.Join(liveDir, relPath)); err != nil {
+			t.Errorf("%s not published: %v", page, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(liveDir, manifest.Filename)); err != nil {
+		t.Errorf("manifest not published: %v", err)
+	}
+	if result.Published < 4 {
+		t.Errorf("Published = %d, want at least 4", result.Published)
+	}
+}
+
+func TestScraper_RsyncFriendly(t *testing.T) {
+	modified := time.Date(2005, 6, 1, 8, 30, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		switch r.URL.Path {
+		case "/docs/Index.html":
+			w.Write([]byte(`<html><body><a href="Page.html">Page</a></body></html>`))
+		case "/docs/Page.html":
+			w.Write([]byte(`<html><body>Page</body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	outputDir := t.TempDir()
+	crawl := func() *Result {
+		t.Helper()
+		config := testConfig(server.URL+"/docs/Index.html", outputDir)
+		config.RsyncFriendly = true
+		s, _ := New(config)
+		result, err := s.Run(context.Background())
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		return result
+	}
+
+	if result := crawl(); result.Saved != 2 || result.Unchanged != 0 {
+		t.Errorf("first crawl: Saved = %d, Unchanged = %d, want 2 and 0", result.Saved, result.Unchanged)
+	}
+	if result := crawl(); result.Unchanged != 2 {
+		t.Errorf("second crawl: Unchanged = %d, want 2", result.Unchanged)
+	}
+
+	relPath, _ := storage.PathFor(server.URL + "/docs/Page.html")
+	info, err := os.Stat(filepath.Join(outputDir, relPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(modified) {
+		t.Errorf("mtime = %v, want Last-Modified %v", info.ModTime(), modified)
+	}
+}
+
+func TestScraper_CompareOnly(t *testing.T) {
+	var pageBody atomic.Value
+	pageBody.Store(`<html><body>Page</body></html>`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/docs/Index.html":
+			w.Write([]byte(`<html><body><a href="Page.html">Page</a><a href="New.html">New</a></body></html>`))
+		case "/docs/Page.html":
+			w.Write([]byte(pageBody.Load().(string)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	outputDir := t.TempDir()
+	crawl := func(compare bool) *Result {
+		t.Helper()
+		config := testConfig(server.URL+"/docs/Index.html", outputDir)
+		config.CompareOnly = compare
+		s, err := New(config)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		result, err := s.Run(context.Background())
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		return result
+	}
+
+	if result := crawl(false); result.Saved != 2 {
+		t.Fatalf("first crawl: Saved = %d, want 2", result.Saved)
+	}
+	pagePath, _ := storage.PathFor(server.URL + "/docs/Page.html")
+	before, err := os.ReadFile(filepath.Join(outputDir, pagePath))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result := crawl(true); len(result.Changes) != 0 || result.Unchanged != 2 {
+		t.Errorf("unchanged site: Changes = %v, Unchanged = %d, want none and 2", result.Changes, result.Unchanged)
+	}
+
+	pageBody.Store(`<html><body>Page, revised</body></html>`)
+	result := crawl(true)
+	want := []string{pagePath}
+	var got []string
+	for _, c := range result.Changes {
+		got = append(got, c.Path)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Changes = %v, want %v", got, want)
+	}
+	if after, _ := os.ReadFile(filepath.Join(outputDir, pagePath)); !bytes.Equal(after, before) {
+		t.Error("compare-only crawl rewrote the mirror")
+	}
+}
+
+func TestScraper_Checkpoint(t *testing.T) {
+	outputDir := t.TempDir()
+	manifestPath := filepath.Join(outputDir, manifest.Filename)
+	var checkpointed atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/docs/Index.html":
+			w.Write([]byte(`<html><body><a href="Page.html">Page</a></body></html>`))
+		case "/docs/Page.html":
+			// The index is saved by now, or soon, and checkpointed with it
+			for range 100 {
+				if _, err := os.Stat(manifestPath); err == nil {
+					checkpointed.Store(true)
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			w.Write([]byte(`<html><body>Page</body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	config := testConfig(server.URL+"/docs/Index.html", outputDir)
+	config.CheckpointPages = 1
+	config.StatePath = filepath.Join(t.TempDir(), "state.jsonl")
+	s, _ := New(config)
+	result, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !checkpointed.Load() {
+		t.Error("manifest not written during the crawl")
+	}
+	if result.Checkpoints == 0 {
+		t.Error("Checkpoints = 0, want at least 1")
+	}
+}
+
+func TestScraper_MaxRetriesByType(t *testing.T) {
+	var pageAttempts, imageAttempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/docs/Index.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><img src="gone.gif"><a href="Flaky.html">Flaky</a></body></html>`))
+		case "/docs/Flaky.html":
+			pageAttempts.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/docs/gone.gif":
+			imageAttempts.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	config := testConfig(server.URL+"/docs/Index.html", t.TempDir())
+	config.Fetcher.MaxRetries = 2
+	config.Fetcher.InitialDelay = time.Millisecond
+	config.MaxRetriesByType = map[urlutil.ResourceType]int{urlutil.ResourceImage: 0}
+	s, _ := New(config)
+	if _, err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if got := pageAttempts.Load(); got != 3 {
+		t.Errorf("page fetched %d times, want 3", got)
+	}
+	if got := imageAttempts.Load(); got != 1 {
+		t.Errorf("image fetched %d times, want 1", got)
+	}
+}
+
+func TestScraper_KnownDead(t *testing.T) {
+	var goneRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/docs/Index.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><a href="Gone.html">Gone</a></body></html>`))
+		case "/docs/Gone.html":
+			goneRequests.Add(1)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	config := testConfig(server.URL+"/docs/Index.html", t.TempDir())
+	config.KnownDead = []state.Record{
+		{URL: server.URL + "/docs/Gone.html", StatusCode: 404, Type: "HTML", Error: "client error"},
+	}
+	s, _ := New(config)
+	result, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if got := goneRequests.Load(); got != 0 {
+		t.Errorf("known dead page fetched %d times, want 0", got)
+	}
+	if result.KnownDead != 1 {
+		t.Errorf("KnownDead = %d, want 1", result.KnownDead)
+	}
+	if result.Failed != 0 {
+		t.Errorf("Failed = %d, want 0", result.Failed)
+	}
+	if got := result.Summary.ByStatus["4xx"]; got != 1 {
+		t.Errorf("Summary.ByStatus[4xx] = %d, want 1", got)
+	}
+}
+
+// objectStore is an in-memory storage.Backend
+type objectStore struct {
+	mu      sync.Mutex
+	objects map[string]string
+}
+
+func (o *objectStore) Put(ctx context.Context, relPath, tmp string, size int64) error {
+	data, err := os.ReadFile(tmp)
+	if err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.objects[relPath] = string(data)
+	return nil
+}
+
+func (o *objectStore) Open(ctx context.Context, relPath string) (io.ReadCloser, error) {
+	return nil, os.ErrNotExist
+}
+
+func (o *objectStore) Remove(ctx context.Context, relPath string) error {
+	return nil
+}
+
+func (o *objectStore) String() string {
+	return "mem://"
+}
+
+func TestScraper_Backend(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/docs/Index.html":
+			w.Write([]byte(`<html><body><a href="Page.html">Page</a></body></html>`))
+		case "/docs/Page.html":
+			w.Write([]byte(`<html><body>Page</body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	outputDir := t.TempDir()
+	store := &objectStore{objects: make(map[string]string)}
+	config := testConfig(server.URL+"/docs/Index.html", outputDir)
+	config.Backend = store
+	s, err := New(config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	relPath, _ := storage.PathFor(server.URL + "/docs/Page.html")
+	if !strings.Contains(store.objects[relPath], "Page") {
+		t.Errorf("%s not stored in the backend", relPath)
+	}
+	if _, ok := store.objects[manifest.Filename]; !ok {
+		t.Error("manifest not stored in the backend")
+	}
+	if entries, _ := os.ReadDir(outputDir); len(entries) != 0 {
+		t.Errorf("files written to the output directory: %v", entries)
+	}
+
+	config.PublishDir = t.TempDir()
+	if _, err := New(config); err == nil {
+		t.Error("New() accepted PublishDir without files on disk")
+	}
+}
+
+func TestScraper_Redirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/docs/Index.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><a href="Old.html">Old</a></body></html>`))
+		case "/docs/Old.html":
+			http.Redirect(w, r, "/docs/New.html", http.StatusMovedPermanently)
+		case "/docs/New.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><a href="New.html">Self</a><a href="Old.html">Old</a></body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	outputDir := t.TempDir()
+	s, _ := New(testConfig(server.URL+"/docs/Index.html", outputDir))
+	result, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	oldURL := server.URL + "/docs/Old.html"
+	newURL := server.URL + "/docs/New.html"
+	if s.Tracker().Canonical(oldURL) != newURL {
+		t.Errorf("Canonical(Old) = %q, want %q", s.Tracker().Canonical(oldURL), newURL)
+	}
+	if result.Saved != 2 {
+		t.Errorf("Saved = %d, want 2 (index and redirect target)", result.Saved)
+	}
+
+	newPath, _ := storage.PathFor(newURL)
+	data, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(newPath)))
+	if err != nil {
+		t.Fatalf("redirect target not saved under its own path: %v", err)
+	}
+	if !strings.Contains(string(data), `href="New.html">Old`) {
+		t.Errorf("link to known alias not rewritten to target: %s", data)
+	}
+}
+
+func TestScraper_RedirectNormalized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/docs/Index.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><a href="Old.html">Old</a></body></html>`))
+		case "/docs/Old.html":
+			http.Redirect(w, r, "/docs/Guide/", http.StatusMovedPermanently)
+		case "/docs/Guide", "/docs/Guide/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><a href="../Guide">Self</a></body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	s, _ := New(testConfig(server.URL+"/docs/Index.html", t.TempDir()))
+	result, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	guideURL := server.URL + "/docs/Guide"
+	if got := s.Tracker().Canonical(server.URL + "/docs/Old.html"); got != guideURL {
+		t.Errorf("Canonical(Old) = %q, want %q", got, guideURL)
+	}
+	if result.Saved != 2 {
+		t.Errorf("Saved = %d, want 2 (index and the redirect target once)", result.Saved)
+	}
+}
+
+func TestScraper_ContentTypeMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/docs/Index.html":
+			w.Write([]byte(`<html><body><img src="broken.gif"></body></html>`))
+		case "/docs/broken.gif":
+			w.Write([]byte(`<html><body>Error</body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	outputDir := t.TempDir()
+	statePath := filepath.Join(t.TempDir(), "crawl.db")
+	config := testConfig(server.URL+"/docs/Index.html", outputDir)
+	config.StatePath = statePath
+	s, _ := New(config)
+	result, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if result.Saved != 1 || result.Mismatched != 1 || result.Failed != 1 {
+		t.Errorf("Saved/Mismatched/Failed = %d/%d/%d, want 1/1/1", result.Saved, result.Mismatched, result.Failed)
+	}
+
+	gif, _ := storage.PathFor(server.URL + "/docs/broken.gif")
+	if _, err := os.Stat(filepath.Join(outputDir, filepath.FromSlash(gif))); !os.IsNotExist(err) {
+		t.Errorf("mismatched resource should not be saved, stat error = %v", err)
+	}
+
+	records, err := state.Load(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := state.Summarize(records, 10); len(stats.Mismatched) != 1 {
+		t.Errorf("state records %d mismatches, want 1", len(stats.Mismatched))
+	}
+}
+
+func TestScraper_FailureCauses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/docs/Index.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><a href="Missing.html">Missing</a><a href="Away.html">Away</a></body></html>`))
+		case "/docs/Away.html":
+			http.Redirect(w, r, "/login/Page.html", http.StatusFound)
+		case "/login/Page.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body>Log in</body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	outputDir := t.TempDir()
+	statePath := filepath.Join(t.TempDir(), "crawl.db")
+	config := testConfig(server.URL+"/docs/Index.html", outputDir)
+	config.StatePath = statePath
+	s, _ := New(config)
+	result, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Saved != 1 || result.Failed != 2 {
+		t.Errorf("Saved/Failed = %d/%d, want 1/2", result.Saved, result.Failed)
+	}
+
+	away, _ := storage.PathFor(server.URL + "/docs/Away.html")
+	if _, err := os.Stat(filepath.Join(outputDir, filepath.FromSlash(away))); !os.IsNotExist(err) {
+		t.Errorf("page redirected out of scope should not be saved, stat error = %v", err)
+	}
+
+	records, err := state.Load(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	causes := make(map[string]state.Record)
+	for _, rec := range records {
+		causes[rec.Cause] = rec
+	}
+	if rec := causes[state.CauseClientStatus]; rec.URL != server.URL+"/docs/Missing.html" || rec.StatusCode != http.StatusNotFound {
+		t.Errorf("client_status record = %+v, want Missing.html with status 404", rec)
+	}
+	if rec := causes[state.CauseClientStatus]; rec.Referrer != server.URL+"/docs/Index.html" {
+		t.Errorf("client_status record referrer = %q, want Index.html", rec.Referrer)
+	}
+	if rec := causes[state.CauseFiltered]; rec.URL != server.URL+"/docs/Away.html" {
+		t.Errorf("filtered record = %+v, want Away.html", rec)
+	}
+}
+
+func TestCause(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{fmt.Errorf("client error: %w", &fetcher.StatusError{StatusCode: 404}), state.CauseClientStatus},
+		{&fetcher.StatusError{StatusCode: 503}, state.CauseServerStatus},
+		{fmt.Errorf("streaming response body: %w", fetcher.ErrTooLarge), state.CauseTooLarge},
+		{fmt.Errorf("streaming response body: %w", fetcher.ErrBadEncoding), state.CauseBadEncoding},
+		{fetcher.ErrRedirectLoop, state.CauseRedirectLoop},
+		{ErrFiltered, state.CauseFiltered},
+		{ErrRobotsDisallowed, state.CauseRobotsDisallowed},
+		{ErrContentTypeMismatch, state.CauseContentTypeMismatch},
+		{errors.New("connection reset"), state.CauseOther},
+	}
+
+	for _, tt := range tests {
+		if got := Cause(tt.err); got != tt.want {
+			t.Errorf("Cause(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestScraper_Wayback(t *testing.T) {
+	server := newTestSite(t)
+	defer server.Close()
+
+	var mu sync.Mutex
+	var submitted []string
+	archive := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		submitted = append(submitted, r.FormValue("url"))
+	}))
+	defer archive.Close()
+
+	archiveConfig := wayback.DefaultConfig()
+	archiveConfig.Endpoint = archive.URL
+	archiveConfig.Interval = time.Millisecond
+	submitter := wayback.NewSubmitter(archiveConfig)
+
+	config := testConfig(server.URL+"/docs/SiteMap.html", t.TempDir())
+	config.Archive = submitter
+	s, _ := New(config)
+	if _, err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	submitter.Close(context.Background())
+
+	// Three HTML pages; the image is captured by the archive with its page
+	mu.Lock()
+	defer mu.Unlock()
+	if len(submitted) != 3 {
+		t.Errorf("submitted %v, want the 3 saved HTML pages", submitted)
+	}
+}
+
+// rerouteTransport sends every request to a test server, keeping the
+// original host and path
+type rerouteTransport struct {
+	target *url.URL
+}
+
+func (rt rerouteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rerouted := req.Clone(req.Context())
+	rerouted.Header.Set("X-Original-Host", req.URL.Host)
+	rerouted.URL.Scheme = rt.target.Scheme
+	rerouted.URL.Host = rt.target.Host
+	resp, err := http.DefaultTransport.RoundTrip(rerouted)
+	if err != nil {
+		return nil, err
+	}
+	// Report the response as served from the original URL
+	resp.Request = req
+	return resp, nil
+}
+
+func TestScraper_WaybackSnapshot(t *testing.T) {
+	var mu sync.Mutex
+	var requested []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.Header.Get("X-Original-Host")+r.URL.Path)
+		mu.Unlock()
+
+		if r.Header.Get("X-Original-Host") != "web.archive.org" {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/web/2012id_/http://udn.example.com/Two/WebHome.html":
+			// Partial timestamps redirect to the closest snapshot
+			w.Header().Set("Location", "/web/20120315000000id_/http://udn.example.com/Two/WebHome.html")
+			w.WriteHeader(http.StatusFound)
+		case "/web/20120315000000id_/http://udn.example.com/Two/WebHome.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body>
+<a href="Page.html">Page</a>
+<a href="https://web.archive.org/web/20120101000000/http://udn.example.com/Two/Rewritten.html">Rewritten</a>
+</body></html>`))
+		case "/web/2012id_/http://udn.example.com/Two/Page.html",
+			"/web/2012id_/http://udn.example.com/Two/Rewritten.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body></body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	target, _ := url.Parse(server.URL)
+	outputDir := t.TempDir()
+	config := testConfig("https://web.archive.org/web/2012*/http://udn.example.com/Two/WebHome.html", outputDir)
+	config.Fetcher.Transport = rerouteTransport{target: target}
+
+	s, err := New(config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	result, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if result.Saved != 3 || result.Failed != 0 {
+		t.Errorf("Saved/Failed = %d/%d, want 3/0 (requested %v)", result.Saved, result.Failed, requested)
+	}
+	for _, page := range []string{"WebHome.html", "Page.html", "Rewritten.html"} {
+		if !s.Tracker().IsVisited("http://udn.example.com/Two/" + page) {
+			t.Errorf("%s not visited under its original URL", page)
+		}
+	}
+
+	// Stored under the original host, with archive links made local
+	data, err := os.ReadFile(filepath.Join(outputDir, "udn.example.com", "Two", "WebHome.html"))
+	if err != nil {
+		t.Fatalf("snapshot root not stored under original path: %v", err)
+	}
+	if !strings.Contains(string(data), `href="Rewritten.html"`) {
+		t.Errorf("archive link not rewritten to local path: %s", data)
+	}
+}
+
+func TestScraper_LocalDirectory(t *testing.T) {
+	dump := t.TempDir()
+	files := map[string]string{
+		"udn.example.com/Two/SiteMap.html": `<html><body>
+<a href="Page.html">Page</a>
+<a href="http://udn.example.com/Two/Live.html">Live</a>
+<img src="rsrc/shot.png">
+</body></html>`,
+		"udn.example.com/Two/Page.html":     `<html><body><a href="SiteMap.html">Home</a></body></html>`,
+		"udn.example.com/Two/rsrc/shot.png": "PNG",
+	}
+	for name, content := range files {
+		full := filepath.Join(dump, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(full), 0755)
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	outputDir := t.TempDir()
+	config := testConfig("file://"+filepath.ToSlash(dump)+"/udn.example.com/Two/SiteMap.html", outputDir)
+	config.LocalBase = dump
+	s, err := New(config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	result, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if result.Saved != 3 || result.Failed != 0 {
+		t.Errorf("Saved/Failed = %d/%d, want 3/0", result.Saved, result.Failed)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "udn.example.com", "Two", "SiteMap.html"))
+	if err != nil {
+		t.Fatalf("local root not stored relative to LocalBase: %v", err)
+	}
+	for _, want := range []string{`href="Page.html"`, `src="rsrc/shot.png"`, `href="http://udn.example.com/Two/Live.html"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("saved page missing %s: %s", want, data)
+		}
+	}
+
+	m, err := manifest.Load(filepath.Join(outputDir, manifest.Filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Get("udn.example.com/Two/rsrc/shot.png"); !ok {
+		t.Error("manifest missing local asset")
+	}
+}
+
+func TestScraper_SchemePolicy(t *testing.T) {
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/Two/WebHome.html" {
+			host := r.Host
+			fmt.Fprintf(w, `<html><body><a href="http://%[1]s/Two/Page.html">Page</a><a href="https://%[1]s/Two/Page.html">Page</a></body></html>`, host)
+			return
+		}
+		w.Write([]byte(`<html><body>Page</body></html>`))
+	}))
+	defer server.Close()
+
+	config := testConfig(server.URL+"/Two/WebHome.html", t.TempDir())
+	config.SchemePolicy = urlutil.SchemeUpgrade
+	s, err := New(config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	result, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	// Both links are one page, fetched over plain HTTP as the server has
+	// no HTTPS
+	if result.Saved != 2 || result.Failed != 0 || fetches.Load() != 2 {
+		t.Errorf("Saved/Failed/fetches = %d/%d/%d, want 2/0/2", result.Saved, result.Failed, fetches.Load())
+	}
+
+	config.SchemePolicy = urlutil.SchemeHTTPSOnly
+	if _, err := New(config); err == nil {
+		t.Error("New() with an http root and https-only policy expected error")
+	}
+}
+
+func TestScraper_Pagination(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch start := r.URL.Query().Get("start"); start {
+		case "":
+			w.Write([]byte(`<html><body><a href="?start=10&amp;skin=print">Next</a></body></html>`))
+		case "10":
+			w.Write([]byte(`<html><body><a href="WebIndex?skin=print&amp;start=20">Next</a></body></html>`))
+		default:
+			fmt.Fprintf(w, `<html><body>Results from %s</body></html>`, start)
+		}
+	}))
+	defer server.Close()
+
+	outputDir := t.TempDir()
+	config := testConfig(server.URL+"/Two/WebIndex", outputDir)
+	pagination, err := urlutil.NewPagination([]string{server.URL + "/Two/WebIndex?start"})
+	if err != nil {
+		t.Fatalf("NewPagination() error = %v", err)
+	}
+	config.Pagination = pagination
+	s, err := New(config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	result, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Saved != 3 || result.Failed != 0 {
+		t.Errorf("Saved/Failed = %d/%d, want 3/0", result.Saved, result.Failed)
+	}
+
+	// Each page of the listing is saved under its own name, linked to the next
+	first, _ := storage.PathFor(server.URL + "/Two/WebIndex")
+	data, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(first)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `href="index_start=10.html"`) {
+		t.Errorf("first page not linked to the second:\n%s", data)
+	}
+	last, _ := storage.PathFor(server.URL + "/Two/WebIndex?start=20")
+	if data, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(last))); err != nil || !strings.Contains(string(data), "Results from 20") {
+		t.Errorf("last page = %q, %v", data, err)
+	}
+}
+
+func TestScraper_RobotsCrawlDelay(t *testing.T) {
+	var mu sync.Mutex
+	var fetched []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: *\nCrawl-delay: 0.1\n"))
+			return
+		}
+		mu.Lock()
+		fetched = append(fetched, time.Now())
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><a href="/a.html">A</a><a href="/b.html">B</a></body></html>`))
+	}))
+	defer server.Close()
+
+	config := testConfig(server.URL+"/index.html", t.TempDir())
+	config.RobotsCrawlDelay = true
+	s, err := New(config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	result, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Saved != 3 {
+		t.Fatalf("Saved = %d, want 3", result.Saved)
+	}
+
+	slices.SortFunc(fetched, time.Time.Compare)
+	for i := 1; i < len(fetched); i++ {
+		if gap := fetched[i].Sub(fetched[i-1]); gap < 80*time.Millisecond {
+			t.Errorf("request %d came %s after the previous, within the crawl delay", i, gap)
+		}
+	}
+}
+
+func TestScraper_FileScheme(t *testing.T) {
+	dump := t.TempDir()
+	files := map[string]string{
+		"udn.example.com/Two/SiteMap.html": `<html><body>
+<a href="../../files/Tools.zip">Tools</a>
+<a href="../../private/Notes.txt">Notes</a>
+</body></html>`,
+		"files/Tools.zip":   "PK",
+		"private/Notes.txt": "secret",
+	}
+	for name, content := range files {
+		full := filepath.Join(dump, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(full), 0755)
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	outputDir := t.TempDir()
+	config := testConfig("file://"+filepath.ToSlash(dump)+"/udn.example.com/Two/SiteMap.html", outputDir)
+	config.LocalBase = dump
+	config.Whitelist = []string{filepath.ToSlash(dump) + "/files"}
+	config.Schemes = []string{"file"}
+	s, err := New(config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(outputDir, "files", "Tools.zip")); err != nil {
+		t.Errorf("file in whitelisted directory not saved: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "private", "Notes.txt")); err == nil {
+		t.Error("file outside whitelisted directories saved")
+	}
+
+	config.Schemes = []string{"gopher"}
+	if _, err := New(config); err == nil {
+		t.Error("New() with unsupported scheme expected error")
+	}
+}
+
+func TestScraper_Versions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/Two/SiteMap.html", "/Three/SiteMap.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><a href="Page.html">Page</a></body></html>`))
+		case "/Two/Page.html", "/Three/Page.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body>Page</body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	outputDir := t.TempDir()
+	roots := map[string]string{"two": "/Two/SiteMap.html", "three": "/Three/SiteMap.html"}
+	for _, version := range []string{"two", "three", "two"} {
+		config := testConfig(server.URL+roots[version], outputDir)
+		config.Version = version
+		s, err := New(config)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		if _, err := s.Run(context.Background()); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+	}
+
+	m, err := manifest.Load(filepath.Join(outputDir, manifest.Filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Len() != 4 {
+		t.Errorf("manifest has %d entries, want 2 per version: %v", m.Len(), m.Entries())
+	}
+
+	host := strings.ReplaceAll(strings.TrimPrefix(server.URL, "http://"), ":", "_")
+	page := "three/" + host + "/Three/Page.html"
+	if e, ok := m.Get(page); !ok || e.Version != "three" {
+		t.Errorf("Get(%q) = %+v, %v; want an entry for version three", page, e, ok)
+	}
+	if root := m.VersionRoots()["two"]; root != "two/"+host+"/Two/SiteMap.html" {
+		t.Errorf("root of two = %q", root)
+	}
+
+	index, err := os.ReadFile(filepath.Join(outputDir, storage.VersionIndex))
+	if err != nil {
+		t.Fatalf("reading version index: %v", err)
+	}
+	if !strings.Contains(string(index), `href="three/`+host+`/Three/SiteMap.html"`) {
+		t.Errorf("version index does not link to three:\n%s", index)
+	}
+
+	if _, err := New(Config{RootURL: server.URL, OutputDir: outputDir, Version: "../two"}); err == nil {
+		t.Error("expected error for a version that is not a directory name")
+	}
+}
+
+func TestScraper_Hooks(t *testing.T) {
+	server := newTestSite(t)
+	defer server.Close()
+
+	hook := &recordingHook{}
+	config := testConfig(server.URL+"/docs/SiteMap.html", t.TempDir())
+	config.Hooks = hooks.NewDispatcher(hook)
+	config.NotifyEvery = 2
+
+	s, _ := New(config)
+	if _, err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if n := hook.count(hooks.EventCrawlFinished); n != 1 {
+		t.Errorf("crawl_finished events = %d, want 1", n)
+	}
+	// Three pages and an image are saved; only the pages count
+	if n := hook.count(hooks.EventPagesSaved); n != 1 {
+		t.Errorf("pages_saved events = %d, want 1", n)
+	}
+}
+
+func TestScraper_Cancelled(t *testing.T) {
+	server := newTestSite(t)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s, _ := New(testConfig(server.URL+"/docs/SiteMap.html", t.TempDir()))
+	result, err := s.Run(ctx)
+	if err == nil {
+		t.Error("expected context error from cancelled run")
+	}
+	if result.Visited != 0 {
+		t.Errorf("Visited = %d, want 0", result.Visited)
+	}
+}
+
+func TestScraper_CoalescesFetches(t *testing.T) {
+	var hits atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png"))
+	}))
+	defer server.Close()
+
+	s, err := New(testConfig(server.URL+"/docs/", t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two workers reach the same URL before either has marked it visited
+	item := &QueueItem{URL: server.URL + "/docs/logo.png", Type: urlutil.ResourceImage}
+	ctx := context.Background()
+	p := s.startPipeline(ctx)
+	var wg sync.WaitGroup
+	process := func() {
+		defer wg.Done()
+		s.pending.Add(1)
+		if !s.process(ctx, p, newJob(item, s.logger)) {
+			s.finish(item)
+		}
+	}
+	wg.Add(1)
+	go process()
+	<-started
+	wg.Add(1)
+	go process()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	p.wait()
+
+	if got := hits.Load(); got != 1 {
+		t.Errorf("origin saw %d requests, want 1", got)
+	}
+	if saved, coalesced := s.saved.Load(), s.coalesced.Load(); saved != 1 || coalesced != 1 {
+		t.Errorf("saved = %d, coalesced = %d; want 1 and 1", saved, coalesced)
+	}
+}
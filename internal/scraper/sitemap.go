@@ -0,0 +1,104 @@
+package scraper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/aldehir/ue2-docs/internal/fetcher"
+	"github.com/aldehir/ue2-docs/internal/parser"
+	"github.com/aldehir/ue2-docs/internal/urlutil"
+)
+
+// maxSitemaps bounds the sitemaps read from nested sitemap indexes
+const maxSitemaps = 50
+
+// seedSitemaps enqueues the in-scope URLs listed in the root's origin's
+// sitemap.xml and in any sitemaps named by its robots.txt, returning how
+// many were newly queued. The URLs are kept in s.sitemapURLs. Missing or
+// unreadable sitemaps are skipped
+func (s *Scraper) seedSitemaps(ctx context.Context) int {
+	root, err := url.Parse(s.config.RootURL)
+	if err != nil || (root.Scheme != "http" && root.Scheme != "https") {
+		return 0
+	}
+	origin := root.Scheme + "://" + root.Host
+
+	sitemaps := []string{origin + "/sitemap.xml"}
+	if body := s.robots(ctx, origin); body != nil {
+		sitemaps = append(parser.RobotsSitemaps(bytes.NewReader(body)), sitemaps...)
+	}
+
+	seeded := 0
+	seen := make(map[string]bool)
+	listed := make(map[string]bool)
+	for len(sitemaps) > 0 && len(seen) < maxSitemaps && ctx.Err() == nil {
+		loc := s.originalURL(sitemaps[0])
+		sitemaps = sitemaps[1:]
+		if seen[loc] {
+			continue
+		}
+		seen[loc] = true
+
+		body, err := s.fetchAux(ctx, loc)
+		if err != nil {
+			continue
+		}
+		sitemap, err := parser.ParseSitemap(bytes.NewReader(body))
+		if err != nil {
+			s.logger.Warn("skipping sitemap", "url", loc, "error", err)
+			continue
+		}
+		sitemaps = append(sitemaps, sitemap.Sitemaps...)
+
+		for _, raw := range sitemap.URLs {
+			normalized, err := urlutil.Normalize(s.crawlURL(raw), "")
+			if err != nil {
+				continue
+			}
+			normalized = s.config.Pagination.Apply(urlutil.StripFragment(normalized), rawQuery(raw))
+			resourceType := urlutil.DetectResourceType(normalized, "")
+			if allowed, _ := s.filter.IsAllowed(normalized); !allowed || s.outOfSection(normalized, resourceType) {
+				continue
+			}
+			if !listed[normalized] {
+				listed[normalized] = true
+				s.sitemapURLs = append(s.sitemapURLs, normalized)
+			}
+			if s.enqueue(normalized, resourceType, 1, loc) {
+				seeded++
+			}
+		}
+	}
+
+	return seeded
+}
+
+// fetchAux fetches a crawl support file such as robots.txt or a sitemap,
+// which is not saved, ungzipping it if it was served as a .gz file.
+// Failures other than a missing file are logged
+func (s *Scraper) fetchAux(ctx context.Context, rawURL string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if _, err := s.fetcher.Fetch(ctx, s.fetchURL(rawURL), buf); err != nil {
+		if code := fetcher.StatusCode(err); code != http.StatusNotFound && code != http.StatusGone && ctx.Err() == nil {
+			s.logger.Warn("fetching crawl support file", "url", rawURL, "error", err)
+		}
+		return nil, err
+	}
+
+	body := buf.Bytes()
+	if len(body) >= 2 && body[0] == 0x1f && body[1] == 0x8b {
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if body, err = io.ReadAll(gz); err != nil {
+			s.logger.Warn("decompressing crawl support file", "url", rawURL, "error", err)
+			return nil, err
+		}
+	}
+	return body, nil
+}
@@ -1,14 +1,40 @@
 package scraper
 
 import (
+	"fmt"
 	"sync"
 	"sync/atomic"
+
+	"github.com/aldehir/ue2-docs/internal/urlutil"
 )
 
 // Tracker tracks visited URLs and their HTTP status codes in a thread-safe manner
+//
+// URLs that redirect elsewhere can be registered as aliases of their target,
+// so that an alias is considered visited once its target is.
 type Tracker struct {
-	visited sync.Map // map[string]int (URL -> status code)
+	visited sync.Map // map[string]visit
+	aliases sync.Map // map[string]string (alias URL -> redirect target)
 	count   atomic.Int64
+
+	// Visits by statusIndex and typeIndex, kept as they are marked so
+	// Summary need not walk every URL
+	byStatus [6]atomic.Int64
+	byType   [urlutil.ResourceOther + 1]atomic.Int64
+}
+
+// visit is the outcome of visiting a URL
+type visit struct {
+	status       int // 0 = no response
+	resourceType urlutil.ResourceType
+}
+
+// Summary counts the visited URLs by status class and resource type
+type Summary struct {
+	// ByStatus is keyed by status class, "1xx" to "5xx", or "error" for
+	// fetches that got no response
+	ByStatus map[string]int
+	ByType   map[string]int // Keyed by urlutil.ResourceType.String()
 }
 
 // NewTracker creates a new URL tracker
@@ -18,30 +44,140 @@ func NewTracker() *Tracker {
 
 // MarkVisited marks a URL as visited with the given HTTP status code
 func (t *Tracker) MarkVisited(url string, statusCode int) {
+	t.MarkVisitedAs(url, statusCode, urlutil.ResourceUnknown)
+}
+
+// MarkVisitedAs marks a URL as visited with the given HTTP status code and
+// resource type
+func (t *Tracker) MarkVisitedAs(url string, statusCode int, resourceType urlutil.ResourceType) {
+	v := visit{statusCode, resourceType}
 	// Check if this is a new URL
-	_, existed := t.visited.Swap(url, statusCode)
-	if !existed {
+	old, existed := t.visited.Swap(url, v)
+	if existed {
+		t.uncount(old.(visit))
+	} else {
 		t.count.Add(1)
 	}
+	t.countVisit(v)
+}
+
+// TryMarkVisited marks a URL as visited unless it already is
+// Returns true if this call marked it
+func (t *Tracker) TryMarkVisited(url string, statusCode int) bool {
+	return t.TryMarkVisitedAs(url, statusCode, urlutil.ResourceUnknown)
+}
+
+// TryMarkVisitedAs is TryMarkVisited also recording the resource type
+func (t *Tracker) TryMarkVisitedAs(url string, statusCode int, resourceType urlutil.ResourceType) bool {
+	v := visit{statusCode, resourceType}
+	_, loaded := t.visited.LoadOrStore(url, v)
+	if !loaded {
+		t.count.Add(1)
+		t.countVisit(v)
+	}
+	return !loaded
+}
+
+// countVisit adds a visit to the Summary counters
+func (t *Tracker) countVisit(v visit) {
+	t.byStatus[statusIndex(v.status)].Add(1)
+	t.byType[typeIndex(v.resourceType)].Add(1)
+}
+
+// uncount removes a visit replaced by another from the Summary counters
+func (t *Tracker) uncount(v visit) {
+	t.byStatus[statusIndex(v.status)].Add(-1)
+	t.byType[typeIndex(v.resourceType)].Add(-1)
+}
+
+// AddAlias records that alias redirects to target
+func (t *Tracker) AddAlias(alias, target string) {
+	if alias != target {
+		t.aliases.Store(alias, target)
+	}
+}
+
+// Canonical follows recorded aliases from url to the URL it ultimately
+// redirects to. Returns url itself if it is not an alias
+func (t *Tracker) Canonical(url string) string {
+	seen := map[string]bool{url: true}
+	for {
+		target, ok := t.aliases.Load(url)
+		if !ok || seen[target.(string)] {
+			return url
+		}
+		url = target.(string)
+		seen[url] = true
+	}
 }
 
-// IsVisited checks if a URL has been visited
+// IsVisited checks if a URL, or the URL it redirects to, has been visited
 func (t *Tracker) IsVisited(url string) bool {
-	_, ok := t.visited.Load(url)
+	_, ok := t.GetStatus(url)
 	return ok
 }
 
-// GetStatus returns the HTTP status code for a visited URL
+// GetStatus returns the HTTP status code for a visited URL, falling back to
+// the status of its redirect target
 // Returns (statusCode, true) if the URL has been visited, (0, false) otherwise
 func (t *Tracker) GetStatus(url string) (int, bool) {
 	val, ok := t.visited.Load(url)
 	if !ok {
-		return 0, false
+		val, ok = t.visited.Load(t.Canonical(url))
+		if !ok {
+			return 0, false
+		}
 	}
-	return val.(int), true
+	return val.(visit).status, true
 }
 
 // VisitedCount returns the total number of unique URLs that have been visited
 func (t *Tracker) VisitedCount() int {
 	return int(t.count.Load())
 }
+
+// Summary counts the visited URLs by status class and resource type
+func (t *Tracker) Summary() Summary {
+	summary := Summary{
+		ByStatus: make(map[string]int),
+		ByType:   make(map[string]int),
+	}
+	for i := range t.byStatus {
+		if n := t.byStatus[i].Load(); n > 0 {
+			summary.ByStatus[statusClass(i)] = int(n)
+		}
+	}
+	for i := range t.byType {
+		if n := t.byType[i].Load(); n > 0 {
+			summary.ByType[urlutil.ResourceType(i).String()] = int(n)
+		}
+	}
+	return summary
+}
+
+// statusIndex returns the byStatus index of a status code: its first
+// digit, or 0 for no response or a code outside 100-599
+func statusIndex(statusCode int) int {
+	if statusCode < 100 || statusCode > 599 {
+		return 0
+	}
+	return statusCode / 100
+}
+
+// statusClass returns the name of a byStatus index, "1xx" to "5xx", or
+// "error" for index 0
+func statusClass(index int) string {
+	if index == 0 {
+		return "error"
+	}
+	return fmt.Sprintf("%dxx", index)
+}
+
+// typeIndex returns the byType index of a resource type, counting types
+// it does not know as unknown
+func typeIndex(rt urlutil.ResourceType) int {
+	if rt < 0 || rt > urlutil.ResourceOther {
+		return int(urlutil.ResourceUnknown)
+	}
+	return int(rt)
+}
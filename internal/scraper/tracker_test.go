@@ -1,8 +1,12 @@
 package scraper
 
 import (
+	"fmt"
+	"reflect"
 	"sync"
 	"testing"
+
+	"github.com/aldehir/ue2-docs/internal/urlutil"
 )
 
 func TestTracker_MarkVisited(t *testing.T) {
@@ -190,3 +194,103 @@ func TestTracker_VisitedCount(t *testing.T) {
 		t.Errorf("VisitedCount() = %v, want 3 (after duplicate)", tracker.VisitedCount())
 	}
 }
+
+func TestTracker_Aliases(t *testing.T) {
+	tracker := NewTracker()
+
+	tracker.AddAlias("https://example.com/a", "https://example.com/b")
+	tracker.AddAlias("https://example.com/b", "https://example.com/c")
+
+	if tracker.IsVisited("https://example.com/a") {
+		t.Error("alias should not be visited before its target")
+	}
+
+	tracker.MarkVisited("https://example.com/c", 200)
+
+	if got := tracker.Canonical("https://example.com/a"); got != "https://example.com/c" {
+		t.Errorf("Canonical() = %q, want https://example.com/c", got)
+	}
+	if !tracker.IsVisited("https://example.com/a") {
+		t.Error("alias should be visited once its target is")
+	}
+	if code, ok := tracker.GetStatus("https://example.com/b"); !ok || code != 200 {
+		t.Errorf("GetStatus(alias) = %d, %v; want 200, true", code, ok)
+	}
+	if tracker.VisitedCount() != 1 {
+		t.Errorf("VisitedCount() = %d, want 1", tracker.VisitedCount())
+	}
+}
+
+func TestTracker_AliasCycle(t *testing.T) {
+	tracker := NewTracker()
+
+	tracker.AddAlias("https://example.com/a", "https://example.com/b")
+	tracker.AddAlias("https://example.com/b", "https://example.com/a")
+
+	// Must terminate
+	if got := tracker.Canonical("https://example.com/a"); got != "https://example.com/b" {
+		t.Errorf("Canonical() = %q, want https://example.com/b", got)
+	}
+}
+
+func TestTracker_TryMarkVisited(t *testing.T) {
+	tracker := NewTracker()
+
+	if !tracker.TryMarkVisited("https://example.com/a", 200) {
+		t.Error("first TryMarkVisited should succeed")
+	}
+	if tracker.TryMarkVisited("https://example.com/a", 404) {
+		t.Error("second TryMarkVisited should fail")
+	}
+	if code, _ := tracker.GetStatus("https://example.com/a"); code != 200 {
+		t.Errorf("status = %d, want 200", code)
+	}
+}
+
+func BenchmarkTracker_Parallel(b *testing.B) {
+	tracker := NewTracker()
+	urls := make([]string, 4096)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("https://udn.epicgames.com/Two/Page%d.html", i)
+	}
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			url := urls[i%len(urls)]
+			if !tracker.IsVisited(url) {
+				tracker.TryMarkVisited(url, 200)
+			}
+			tracker.Canonical(url)
+			i++
+		}
+	})
+}
+
+func TestTracker_Summary(t *testing.T) {
+	tracker := NewTracker()
+	tracker.MarkVisitedAs("https://example.com/a.html", 200, urlutil.ResourceHTML)
+	tracker.MarkVisitedAs("https://example.com/b.html", 404, urlutil.ResourceHTML)
+	tracker.MarkVisitedAs("https://example.com/logo.png", 200, urlutil.ResourceImage)
+	tracker.MarkVisitedAs("https://example.com/down.css", 0, urlutil.ResourceCSS)
+	tracker.TryMarkVisitedAs("https://example.com/old.html", 301, urlutil.ResourceHTML)
+	tracker.MarkVisited("https://example.com/switch", 101)
+	// Revisiting replaces the earlier outcome
+	tracker.MarkVisitedAs("https://example.com/b.html", 503, urlutil.ResourceHTML)
+
+	summary := tracker.Summary()
+	wantStatus := map[string]int{"1xx": 1, "2xx": 2, "3xx": 1, "5xx": 1, "error": 1}
+	if !reflect.DeepEqual(summary.ByStatus, wantStatus) {
+		t.Errorf("ByStatus = %v, want %v", summary.ByStatus, wantStatus)
+	}
+	wantType := map[string]int{
+		urlutil.ResourceHTML.String():    3,
+		urlutil.ResourceImage.String():   1,
+		urlutil.ResourceCSS.String():     1,
+		urlutil.ResourceUnknown.String(): 1,
+	}
+	if !reflect.DeepEqual(summary.ByType, wantType) {
+		t.Errorf("ByType = %v, want %v", summary.ByType, wantType)
+	}
+}
@@ -0,0 +1,178 @@
+// Package search builds and queries a full-text index of converted pages
+package search
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Analyzer turns text into index terms. The same analyzer must be used for
+// indexing and querying, so it is stored in the index
+type Analyzer struct {
+	Name             string   `json:"name"`
+	SplitIdentifiers bool     `json:"split_identifiers"` // Also index the sub-words of identifiers like PostNetBeginPlay
+	Stem             bool     `json:"stem"`              // Reduce words to a common stem (plays, played -> play)
+	StopWords        []string `json:"stop_words,omitempty"`
+}
+
+// defaultStopWords are common English words left out of the index
+var defaultStopWords = []string{
+	"a", "an", "and", "are", "as", "at", "be", "by", "for", "from", "in",
+	"is", "it", "of", "on", "or", "that", "the", "this", "to", "with",
+}
+
+// analyzers are the built-in analyzers by name
+var analyzers = map[string]Analyzer{
+	"unrealscript": {Name: "unrealscript", SplitIdentifiers: true, Stem: true, StopWords: defaultStopWords},
+	"standard":     {Name: "standard", Stem: true, StopWords: defaultStopWords},
+	"simple":       {Name: "simple"},
+}
+
+// DefaultAnalyzer returns the analyzer suited to UnrealScript documentation
+func DefaultAnalyzer() Analyzer {
+	return analyzers["unrealscript"]
+}
+
+// ParseAnalyzer returns a built-in analyzer: "unrealscript" (identifier
+// splitting, stemming and stop words), "standard" (stemming and stop words)
+// or "simple" (lowercased words only)
+func ParseAnalyzer(name string) (Analyzer, error) {
+	a, ok := analyzers[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return Analyzer{}, fmt.Errorf("unknown analyzer %q (want unrealscript, standard or simple)", name)
+	}
+	return a, nil
+}
+
+// Tokens returns the index terms for text, in order of appearance
+func (a Analyzer) Tokens(text string) []string {
+	stop := make(map[string]bool, len(a.StopWords))
+	for _, w := range a.StopWords {
+		stop[w] = true
+	}
+
+	var terms []string
+	add := func(word string) {
+		word = strings.ToLower(word)
+		if stop[word] {
+			return
+		}
+		if a.Stem {
+			word = stem(word)
+		}
+		terms = append(terms, word)
+	}
+
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	for _, word := range words {
+		word = strings.Trim(word, "_")
+		if word == "" {
+			continue
+		}
+		add(word)
+
+		if a.SplitIdentifiers {
+			if parts := splitIdentifier(word); len(parts) > 1 {
+				for _, part := range parts {
+					add(part)
+				}
+			}
+		}
+	}
+
+	return terms
+}
+
+// splitIdentifier splits an identifier into its sub-words at underscores,
+// case changes and letter-digit boundaries:
+//
+//	PostNetBeginPlay -> Post Net Begin Play
+//	HTTPRequest      -> HTTP Request
+//	bNoDelete        -> No Delete (UnrealScript's b prefix for booleans)
+//	UT2004           -> UT 2004
+//
+// Single-character parts are dropped
+func splitIdentifier(word string) []string {
+	runes := []rune(word)
+	var parts []string
+	start := 0
+
+	flush := func(end int) {
+		if end-start > 1 {
+			parts = append(parts, string(runes[start:end]))
+		}
+		start = end
+	}
+
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+		switch {
+		case cur == '_':
+			flush(i)
+			start = i + 1
+		case prev == '_':
+			start = i
+		case unicode.IsLower(prev) && unicode.IsUpper(cur):
+			// "bNoDelete": a lone lowercase b is a type prefix, not a word
+			flush(i)
+		case unicode.IsUpper(prev) && unicode.IsUpper(cur) && i+1 < len(runes) && unicode.IsLower(runes[i+1]):
+			// End of an acronym: "HTTPRequest" splits before the R
+			flush(i)
+		case unicode.IsLetter(prev) && unicode.IsDigit(cur):
+			flush(i)
+		}
+	}
+	flush(len(runes))
+
+	return parts
+}
+
+// stem reduces an English word to a stem with a few suffix rules. It is
+// deliberately light: the goal is for plurals and verb forms to meet, not
+// to produce real words
+func stem(word string) string {
+	for _, r := range word {
+		if r < 'a' || r > 'z' {
+			return word
+		}
+	}
+	if len(word) <= 3 {
+		return word
+	}
+
+	switch {
+	case strings.HasSuffix(word, "ies") && len(word) > 4:
+		word = word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "sses"):
+		word = word[:len(word)-2]
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") &&
+		!strings.HasSuffix(word, "us") && !strings.HasSuffix(word, "is"):
+		word = word[:len(word)-1]
+	}
+
+	for _, suffix := range []string{"ing", "ed"} {
+		if base := strings.TrimSuffix(word, suffix); base != word && len(base) >= 3 && hasVowel(base) {
+			word = base
+			// "running" -> "runn" -> "run"
+			if n := len(word); word[n-1] == word[n-2] && !strings.ContainsRune("aeiouls", rune(word[n-1])) {
+				word = word[:n-1]
+			}
+			break
+		}
+	}
+
+	// "move" and "moving" meet at "mov"
+	if len(word) > 3 && strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "ee") {
+		word = word[:len(word)-1]
+	}
+
+	return word
+}
+
+// hasVowel reports whether a lowercase word contains a vowel
+func hasVowel(word string) bool {
+	return strings.ContainsAny(word, "aeiouy")
+}
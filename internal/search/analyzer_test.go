@@ -0,0 +1,82 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitIdentifier(t *testing.T) {
+	tests := []struct {
+		word string
+		want []string
+	}{
+		{"PostNetBeginPlay", []string{"Post", "Net", "Begin", "Play"}},
+		{"HTTPRequest", []string{"HTTP", "Request"}},
+		{"bNoDelete", []string{"No", "Delete"}},
+		{"UT2004", []string{"UT", "2004"}},
+		{"Max_Speed", []string{"Max", "Speed"}},
+		{"actor", []string{"actor"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.word, func(t *testing.T) {
+			if got := splitIdentifier(tt.word); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitIdentifier(%q) = %q, want %q", tt.word, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStem(t *testing.T) {
+	tests := []struct {
+		words []string
+		want  string
+	}{
+		{[]string{"play", "plays", "played", "playing"}, "play"},
+		{[]string{"move", "moves", "moved", "moving"}, "mov"},
+		{[]string{"run", "running"}, "run"},
+		{[]string{"property", "properties"}, "property"},
+		{[]string{"class", "classes"}, "class"},
+		{[]string{"status"}, "status"},
+		{[]string{"d3d9"}, "d3d9"},
+	}
+
+	for _, tt := range tests {
+		for _, word := range tt.words {
+			if got := stem(word); got != tt.want {
+				t.Errorf("stem(%q) = %q, want %q", word, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestAnalyzer_Tokens(t *testing.T) {
+	tests := []struct {
+		analyzer string
+		text     string
+		want     []string
+	}{
+		{"unrealscript", "The PostNetBeginPlay event", []string{"postnetbeginplay", "post", "net", "begin", "play", "event"}},
+		{"unrealscript", "Engine.GameInfo", []string{"engin", "gameinfo", "gam", "info"}},
+		{"standard", "The PostNetBeginPlay events", []string{"postnetbeginplay", "event"}},
+		{"simple", "The Actors", []string{"the", "actors"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.analyzer+" "+tt.text, func(t *testing.T) {
+			a, err := ParseAnalyzer(tt.analyzer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := a.Tokens(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokens(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAnalyzer_Unknown(t *testing.T) {
+	if _, err := ParseAnalyzer("porter"); err == nil {
+		t.Error("expected error for unknown analyzer")
+	}
+}
@@ -0,0 +1,176 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+)
+
+// Version is the current index format version
+const Version = 1
+
+// titleBoost is how many times title terms count relative to body terms
+const titleBoost = 3
+
+// BM25 ranking parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Document is an indexed page
+type Document struct {
+	Path   string `json:"path"` // Slash-separated, relative to the converted output
+	Title  string `json:"title,omitempty"`
+	Length int    `json:"length"` // Number of terms, for length normalization
+}
+
+// Posting records how often a term occurs in a document
+type Posting struct {
+	Doc  int `json:"doc"` // Index into Index.Docs
+	Freq int `json:"freq"`
+}
+
+// Hit is a search result
+type Hit struct {
+	Document
+	Score float64
+}
+
+// Index is an inverted index of pages. It is not safe for concurrent use
+type Index struct {
+	Analyzer Analyzer             `json:"analyzer"`
+	Docs     []Document           `json:"docs"`
+	Terms    map[string][]Posting `json:"terms"`
+}
+
+// file is the on-disk representation of an index
+type file struct {
+	Version int `json:"version"`
+	*Index
+}
+
+// NewIndex creates an empty index using the given analyzer
+func NewIndex(analyzer Analyzer) *Index {
+	return &Index{
+		Analyzer: analyzer,
+		Terms:    make(map[string][]Posting),
+	}
+}
+
+// Add indexes a page
+func (ix *Index) Add(path, title, text string) {
+	freqs := make(map[string]int)
+	length := 0
+
+	for _, term := range ix.Analyzer.Tokens(title) {
+		freqs[term] += titleBoost
+		length++
+	}
+	for _, term := range ix.Analyzer.Tokens(text) {
+		freqs[term]++
+		length++
+	}
+
+	doc := len(ix.Docs)
+	ix.Docs = append(ix.Docs, Document{Path: path, Title: title, Length: length})
+	for term, freq := range freqs {
+		ix.Terms[term] = append(ix.Terms[term], Posting{Doc: doc, Freq: freq})
+	}
+}
+
+// Len returns the number of indexed documents
+func (ix *Index) Len() int {
+	return len(ix.Docs)
+}
+
+// Search returns the documents containing every term of the query, best
+// match first. A limit of 0 returns all matches
+func (ix *Index) Search(query string, limit int) []Hit {
+	terms := ix.Analyzer.Tokens(query)
+	if len(terms) == 0 || len(ix.Docs) == 0 {
+		return nil
+	}
+
+	avgLength := 0.0
+	for _, d := range ix.Docs {
+		avgLength += float64(d.Length)
+	}
+	avgLength /= float64(len(ix.Docs))
+
+	scores := make(map[int]float64)
+	matched := make(map[int]int)
+	seen := make(map[string]bool)
+	unique := 0
+
+	for _, term := range terms {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		unique++
+
+		postings := ix.Terms[term]
+		n := float64(len(postings))
+		idf := math.Log(1 + (float64(len(ix.Docs))-n+0.5)/(n+0.5))
+
+		for _, p := range postings {
+			tf := float64(p.Freq)
+			norm := 1 - bm25B + bm25B*float64(ix.Docs[p.Doc].Length)/avgLength
+			scores[p.Doc] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+			matched[p.Doc]++
+		}
+	}
+
+	var hits []Hit
+	for doc, score := range scores {
+		if matched[doc] == unique {
+			hits = append(hits, Hit{Document: ix.Docs[doc], Score: score})
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Path < hits[j].Path
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// Save writes the index to a file
+func (ix *Index) Save(path string) error {
+	data, err := json.Marshal(file{Version: Version, Index: ix})
+	if err != nil {
+		return fmt.Errorf("encoding search index: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("writing search index: %w", err)
+	}
+	return nil
+}
+
+// Load reads an index from a file
+func Load(path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading search index: %w", err)
+	}
+
+	f := file{Index: NewIndex(Analyzer{})}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("decoding search index: %w", err)
+	}
+	if f.Version > Version {
+		return nil, fmt.Errorf("search index version %d is newer than supported version %d", f.Version, Version)
+	}
+	if f.Terms == nil {
+		f.Terms = make(map[string][]Posting)
+	}
+	return f.Index, nil
+}
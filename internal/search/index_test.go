@@ -0,0 +1,71 @@
+package search
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestIndex_Search(t *testing.T) {
+	ix := NewIndex(DefaultAnalyzer())
+	ix.Add("Two/ActorFunctions.md", "Actor Functions", "PostBeginPlay is called after gameplay begins. PostNetBeginPlay follows replication.")
+	ix.Add("Two/Playing.md", "Playing Online", "How to begin playing a match.")
+	ix.Add("Two/Textures.md", "Textures", "Importing textures into packages.")
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"begin play", []string{"Two/ActorFunctions.md", "Two/Playing.md"}},
+		{"PostNetBeginPlay", []string{"Two/ActorFunctions.md"}},
+		{"texture import", []string{"Two/Textures.md"}},
+		{"texture replication", nil},
+		{"the", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			hits := ix.Search(tt.query, 0)
+			var got []string
+			for _, h := range hits {
+				got = append(got, h.Path)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+			for _, want := range tt.want {
+				found := false
+				for _, path := range got {
+					found = found || path == want
+				}
+				if !found {
+					t.Errorf("Search(%q) = %v, missing %s", tt.query, got, want)
+				}
+			}
+		})
+	}
+
+	if hits := ix.Search("begin play", 1); len(hits) != 1 {
+		t.Errorf("Search() with limit 1 returned %d hits", len(hits))
+	}
+}
+
+func TestIndex_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "search-index.json")
+
+	ix := NewIndex(analyzers["standard"])
+	ix.Add("a.md", "Actors", "Spawning actors")
+	if err := ix.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if loaded.Analyzer.Name != "standard" || loaded.Analyzer.SplitIdentifiers {
+		t.Errorf("Analyzer = %+v, want the standard analyzer", loaded.Analyzer)
+	}
+	if hits := loaded.Search("spawn actor", 0); len(hits) != 1 || hits[0].Title != "Actors" {
+		t.Errorf("Search() after Load = %+v", hits)
+	}
+}
@@ -0,0 +1,59 @@
+package search
+
+import "strings"
+
+// Snippet returns the passage of at most words words of text that matches
+// the most distinct terms of query, earliest first, with "..." marking
+// text cut off on either side. Without any match it is the start of text
+func (a Analyzer) Snippet(text, query string, words int) string {
+	fields := strings.Fields(text)
+	if words < 1 || len(fields) <= words {
+		return strings.Join(fields, " ")
+	}
+
+	want := make(map[string]bool)
+	for _, term := range a.Tokens(query) {
+		want[term] = true
+	}
+	// The query terms each word matches
+	matches := make([][]string, len(fields))
+	for i, field := range fields {
+		for _, term := range a.Tokens(field) {
+			if want[term] {
+				matches[i] = append(matches[i], term)
+			}
+		}
+	}
+
+	best, bestCount := 0, 0
+	for start := 0; start+words <= len(fields); start++ {
+		seen := make(map[string]bool)
+		for _, terms := range matches[start : start+words] {
+			for _, term := range terms {
+				seen[term] = true
+			}
+		}
+		if len(seen) > bestCount {
+			best, bestCount = start, len(seen)
+		}
+		if bestCount == len(want) {
+			break
+		}
+	}
+	// Lead into the first match rather than start on it
+	if bestCount > 0 {
+		for len(matches[best]) == 0 {
+			best++
+		}
+		best = max(0, min(best-words/4, len(fields)-words))
+	}
+
+	snippet := strings.Join(fields[best:best+words], " ")
+	if best > 0 {
+		snippet = "..." + snippet
+	}
+	if best+words < len(fields) {
+		snippet += "..."
+	}
+	return snippet
+}
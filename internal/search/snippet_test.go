@@ -0,0 +1,28 @@
+package search
+
+import "testing"
+
+func TestAnalyzer_Snippet(t *testing.T) {
+	text := "Volumes are brushes that change the properties of the space inside them. " +
+		"A PhysicsVolume changes how actors move within it, such as its gravity and friction. " +
+		"Water is a common example."
+	a := DefaultAnalyzer()
+
+	tests := []struct {
+		name  string
+		query string
+		words int
+		want  string
+	}{
+		{"match", "PhysicsVolume gravity", 8, "...them. A PhysicsVolume changes how actors move within..."},
+		{"no match", "texture", 5, "Volumes are brushes that change..."},
+		{"short text", "water", 100, text},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := a.Snippet(text, tt.query, tt.words); got != tt.want {
+				t.Errorf("Snippet() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package state
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aldehir/ue2-docs/internal/zst"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Record describes the outcome of fetching a single URL
+type Record struct {
+	URL         string    `json:"url"`
+	Host        string    `json:"host"`
+	StatusCode  int       `json:"status"`
+	Type        string    `json:"type"`
+	ContentType string    `json:"content_type,omitempty"`
+	Referrer    string    `json:"referrer,omitempty"` // Page or sitemap the URL was first found in
+	Trace       string    `json:"trace,omitempty"`    // Trace ID of the fetch in the crawl log
+	Bytes       int64     `json:"bytes"`
+	DurationMs  int64     `json:"duration_ms"`
+	DNSMs       int64     `json:"dns_ms,omitempty"`
+	ConnectMs   int64     `json:"connect_ms,omitempty"`
+	TLSMs       int64     `json:"tls_ms,omitempty"`
+	TTFBMs      int64     `json:"ttfb_ms,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Cause       string    `json:"cause,omitempty"`                 // One of the Cause constants when Error is set
+	Mismatch    bool      `json:"content_type_mismatch,omitempty"` // Content-Type contradicted the extension
+	Anomaly     string    `json:"encoding_anomaly,omitempty"`      // Broken Content-Encoding worked around
+	Time        time.Time `json:"time"`
+}
+
+// Gone reports whether the record's URL failed permanently: the server
+// answered 404 Not Found or 410 Gone, so fetching it again is pointless
+func (r Record) Gone() bool {
+	return r.Error != "" && (r.StatusCode == http.StatusNotFound || r.StatusCode == http.StatusGone)
+}
+
+// Failure causes recorded alongside an error
+const (
+	CauseClientStatus        = "client_status"         // 4xx response
+	CauseServerStatus        = "server_status"         // 5xx response
+	CauseTooLarge            = "too_large"             // Body exceeded the size limit
+	CauseBadEncoding         = "bad_encoding"          // Body could not be decompressed
+	CauseRedirectLoop        = "redirect_loop"         // Redirect chain revisited a URL
+	CauseFiltered            = "filtered"              // Left the crawl scope, e.g. by redirect
+	CauseRobotsDisallowed    = "robots_disallowed"     // Forbidden by robots.txt
+	CauseContentTypeMismatch = "content_type_mismatch" // Content-Type contradicted the extension
+	CauseOther               = "other"                 // Network and storage errors
+)
+
+// Writer appends records to a crawl state file as JSON lines
+//
+// Each record is written as soon as it is added, so the file can be read
+// while the crawl is still running. Files named with zst.Extension are
+// Zstandard-compressed instead, their records reaching the file in blocks
+// and at every Sync; each crawl appends a frame of its own.
+type Writer struct {
+	mu  sync.Mutex
+	f   *os.File
+	zw  *zstd.Encoder // nil for an uncompressed file
+	enc *json.Encoder
+}
+
+// Create opens a crawl state file for appending, creating it if necessary
+func Create(path string) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening state file: %w", err)
+	}
+
+	w := &Writer{f: f}
+	if !zst.Compressed(path) {
+		w.enc = json.NewEncoder(f)
+		return w, nil
+	}
+	if w.zw, err = zst.NewWriter(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("opening state file: %w", err)
+	}
+	w.enc = json.NewEncoder(w.zw)
+	return w, nil
+}
+
+// Add appends a record to the state file
+func (w *Writer) Add(rec Record) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.enc.Encode(rec); err != nil {
+		return fmt.Errorf("writing state record: %w", err)
+	}
+	return nil
+}
+
+// Sync commits the records written so far to stable storage, so they
+// survive a crash of the machine
+func (w *Writer) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.zw != nil {
+		if err := w.zw.Flush(); err != nil {
+			return fmt.Errorf("syncing state file: %w", err)
+		}
+	}
+	if err := w.f.Sync(); err != nil {
+		return fmt.Errorf("syncing state file: %w", err)
+	}
+	return nil
+}
+
+// Close closes the state file
+func (w *Writer) Close() error {
+	if w.zw != nil {
+		if err := w.zw.Close(); err != nil {
+			w.f.Close()
+			return fmt.Errorf("writing state file: %w", err)
+		}
+	}
+	return w.f.Close()
+}
+
+// Load reads all records from a crawl state file, compressed or not
+// If a URL appears more than once, the latest record wins
+func Load(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening state file: %w", err)
+	}
+	defer f.Close()
+
+	r, err := zst.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("opening state file: %w", err)
+	}
+	defer r.Close()
+	return Read(r)
+}
+
+// Read reads records from JSON lines. A truncated final line or Zstandard
+// frame, as left by an interrupted crawl, is ignored
+func Read(r io.Reader) ([]Record, error) {
+	var records []Record
+	index := make(map[string]int)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var rec Record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			// Only tolerate a partial write at the end of the file
+			if !scanner.Scan() {
+				break
+			}
+			return nil, fmt.Errorf("decoding state record: %w", err)
+		}
+
+		if i, ok := index[rec.URL]; ok {
+			records[i] = rec
+			continue
+		}
+		index[rec.URL] = len(records)
+		records = append(records, rec)
+	}
+
+	if err := scanner.Err(); err != nil && !errors.Is(err, zst.ErrTruncated) {
+		return nil, fmt.Errorf("reading state file: %w", err)
+	}
+
+	return records, nil
+}
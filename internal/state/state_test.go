@@ -0,0 +1,284 @@
+package state
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriter_AddAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crawl.db")
+
+	w, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	records := []Record{
+		{URL: "https://example.com/a.html", Host: "example.com", StatusCode: 200, Type: "HTML", Bytes: 100},
+		{URL: "https://example.com/b.png", Host: "example.com", StatusCode: 0, Type: "Image", Error: "HTTP 404"},
+	}
+	for _, rec := range records {
+		if err := w.Add(rec); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if len(loaded) != 2 {
+		t.Fatalf("Load() returned %d records, want 2", len(loaded))
+	}
+	if loaded[0].URL != records[0].URL || loaded[0].Bytes != 100 {
+		t.Errorf("loaded[0] = %+v, want %+v", loaded[0], records[0])
+	}
+	if loaded[1].Error != "HTTP 404" {
+		t.Errorf("loaded[1].Error = %q, want %q", loaded[1].Error, "HTTP 404")
+	}
+}
+
+func TestWriter_Appends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crawl.db")
+
+	for _, u := range []string{"https://example.com/1", "https://example.com/2"} {
+		w, err := Create(path)
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		w.Add(Record{URL: u})
+		w.Close()
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(loaded) != 2 {
+		t.Errorf("expected records from both runs, got %d", len(loaded))
+	}
+}
+
+func TestWriter_Compressed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crawl.jsonl.zst")
+
+	// Each run appends a frame of its own
+	for _, u := range []string{"https://example.com/1", "https://example.com/2"} {
+		w, err := Create(path)
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		w.Add(Record{URL: u})
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "example.com") {
+		t.Error("state file not compressed")
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(loaded) != 2 || loaded[1].URL != "https://example.com/2" {
+		t.Errorf("Load() = %+v, want records from both runs", loaded)
+	}
+}
+
+func TestLoad_CompressedCrash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crawl.jsonl.zst")
+	w, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	w.Add(Record{URL: "https://example.com/1"})
+	w.Add(Record{URL: "https://example.com/2"})
+	if err := w.Sync(); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	// A crash leaves the frame unfinished
+	w.f.Close()
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(loaded) != 2 {
+		t.Errorf("Load() = %+v, want the 2 synced records", loaded)
+	}
+}
+
+func TestRead_LatestRecordWins(t *testing.T) {
+	input := `{"url":"https://example.com/a","status":0}
+{"url":"https://example.com/b","status":200}
+{"url":"https://example.com/a","status":200}
+`
+	records, err := Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("Read() returned %d records, want 2", len(records))
+	}
+	if records[0].URL != "https://example.com/a" || records[0].StatusCode != 200 {
+		t.Errorf("records[0] = %+v, want latest record for /a", records[0])
+	}
+}
+
+func TestRead_TruncatedFinalLine(t *testing.T) {
+	input := `{"url":"https://example.com/a","status":200}
+{"url":"https://exam`
+
+	records, err := Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(records) != 1 {
+		t.Errorf("Read() returned %d records, want 1", len(records))
+	}
+}
+
+func TestRead_CorruptMiddleLine(t *testing.T) {
+	input := `{"url":"https://example.com/a"}
+not json
+{"url":"https://example.com/b"}
+`
+	if _, err := Read(strings.NewReader(input)); err == nil {
+		t.Error("expected error for corrupt record in the middle of the file")
+	}
+}
+
+func TestLoad_MissingFile(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.db")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected not-exist error, got %v", err)
+	}
+}
+
+func TestRecord_Gone(t *testing.T) {
+	tests := []struct {
+		name string
+		rec  Record
+		want bool
+	}{
+		{"not found", Record{StatusCode: 404, Error: "client error"}, true},
+		{"gone", Record{StatusCode: 410, Error: "client error"}, true},
+		{"forbidden", Record{StatusCode: 403, Error: "client error"}, false},
+		{"server error", Record{StatusCode: 503, Error: "server error"}, false},
+		{"no response", Record{Error: "connection refused"}, false},
+		{"saved", Record{StatusCode: 200}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rec.Gone(); got != tt.want {
+				t.Errorf("Gone() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	records := []Record{
+		{URL: "https://a.com/1.html", Host: "a.com", StatusCode: 200, Type: "HTML", Bytes: 10, DurationMs: 100},
+		{URL: "https://a.com/2.png", Host: "a.com", StatusCode: 200, Type: "Image", Bytes: 500, DurationMs: 300},
+		{URL: "https://b.com/3.png", Host: "b.com", StatusCode: 200, Type: "Image", Bytes: 50, DurationMs: 1000, ConnectMs: 200, TTFBMs: 900},
+		{URL: "https://a.com/4.html", Host: "a.com", StatusCode: 404, Type: "HTML", DurationMs: 50, Error: "HTTP 404", Cause: CauseClientStatus, Referrer: "https://a.com/1.html"},
+	}
+
+	stats := Summarize(records, 2)
+
+	if len(stats.Mismatched) != 0 {
+		t.Errorf("Mismatched = %v, want none", stats.Mismatched)
+	}
+	records[1].Mismatch = true
+	records[2].Anomaly = "double gzip"
+	stats = Summarize(records, 2)
+	if len(stats.Mismatched) != 1 || stats.Mismatched[0].URL != "https://a.com/2.png" {
+		t.Errorf("Mismatched = %v, want a.com/2.png", stats.Mismatched)
+	}
+	if len(stats.Anomalies) != 1 || stats.Anomalies[0].URL != "https://b.com/3.png" {
+		t.Errorf("Anomalies = %v, want b.com/3.png", stats.Anomalies)
+	}
+
+	if stats.Total != 4 {
+		t.Errorf("Total = %d, want 4", stats.Total)
+	}
+	if stats.TotalBytes != 560 {
+		t.Errorf("TotalBytes = %d, want 560", stats.TotalBytes)
+	}
+	if stats.ByStatus[200] != 3 || stats.ByStatus[404] != 1 {
+		t.Errorf("ByStatus = %v", stats.ByStatus)
+	}
+	if stats.ByType["HTML"] != 2 || stats.ByType["Image"] != 2 {
+		t.Errorf("ByType = %v", stats.ByType)
+	}
+	if len(stats.ByCause) != 1 || stats.ByCause[CauseClientStatus] != 1 {
+		t.Errorf("ByCause = %v, want one client_status", stats.ByCause)
+	}
+	if len(stats.Rates) != 0 {
+		t.Errorf("Rates = %v, want none for records without times", stats.Rates)
+	}
+	if len(stats.Failures) != 1 || stats.Failures[0].Referrer != "https://a.com/1.html" {
+		t.Errorf("Failures = %v, want a.com/4.html linked from a.com/1.html", stats.Failures)
+	}
+
+	if len(stats.Largest) != 2 {
+		t.Fatalf("Largest has %d entries, want 2", len(stats.Largest))
+	}
+	if stats.Largest[0].URL != "https://a.com/2.png" || stats.Largest[1].URL != "https://b.com/3.png" {
+		t.Errorf("Largest = %v", stats.Largest)
+	}
+
+	if len(stats.Slowest) != 2 {
+		t.Fatalf("Slowest has %d entries, want 2", len(stats.Slowest))
+	}
+	if stats.Slowest[0].Host != "b.com" || stats.Slowest[0].Average != time.Second {
+		t.Errorf("Slowest[0] = %+v, want b.com at 1s", stats.Slowest[0])
+	}
+	if stats.Slowest[0].TTFB != 900*time.Millisecond || stats.Slowest[0].Connect != 200*time.Millisecond {
+		t.Errorf("Slowest[0] breakdown = %+v, want 200ms connect and 900ms TTFB", stats.Slowest[0])
+	}
+	if stats.Slowest[1].Host != "a.com" || stats.Slowest[1].Requests != 3 || stats.Slowest[1].Average != 150*time.Millisecond {
+		t.Errorf("Slowest[1] = %+v, want a.com with 3 requests at 150ms", stats.Slowest[1])
+	}
+}
+
+func TestSummarize_Rates(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	records := []Record{
+		{Host: "a.com", Bytes: 10, Time: start.Add(time.Minute)},
+		{Host: "a.com", Bytes: 20, Time: start.Add(9 * time.Minute)},
+		{Host: "b.com", Bytes: 5, Time: start.Add(2 * time.Minute)},
+		{Host: "a.com", Bytes: 40, Time: start.Add(25 * time.Minute)},
+	}
+
+	got := Summarize(records, 10).Rates
+	want := []HostRate{
+		{Host: "a.com", Start: start, Requests: 2, Bytes: 30},
+		{Host: "a.com", Start: start.Add(20 * time.Minute), Requests: 1, Bytes: 40},
+		{Host: "b.com", Start: start, Requests: 1, Bytes: 5},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Rates = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Rates[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
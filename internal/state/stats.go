@@ -0,0 +1,159 @@
+package state
+
+import (
+	"sort"
+	"time"
+)
+
+// HostTiming summarizes fetch latency for a single host
+type HostTiming struct {
+	Host     string
+	Requests int
+	Average  time.Duration // Average total fetch time
+	DNS      time.Duration // Average DNS lookup time
+	Connect  time.Duration // Average TCP connect time
+	TLS      time.Duration // Average TLS handshake time
+	TTFB     time.Duration // Average time to first byte
+}
+
+// RateWindow is the length of the windows crawl rates are reported over
+const RateWindow = 10 * time.Minute
+
+// HostRate counts the requests made to a host within one RateWindow
+type HostRate struct {
+	Host     string
+	Start    time.Time // Start of the window, in UTC
+	Requests int
+	Bytes    int64
+}
+
+// Stats holds aggregate statistics over a set of crawl records
+type Stats struct {
+	Total      int
+	TotalBytes int64
+	ByStatus   map[int]int
+	ByType     map[string]int
+	ByCause    map[string]int // Failed records by Cause
+	Failures   []Record       // Failed records, by URL
+	Largest    []Record       // Largest resources, biggest first
+	Slowest    []HostTiming   // Hosts by average fetch time, slowest first
+	Rates      []HostRate     // Requests per host per RateWindow, by host then time
+	Mismatched []Record       // Resources whose Content-Type contradicted their extension
+	Anomalies  []Record       // Resources whose broken Content-Encoding was worked around
+}
+
+// Summarize computes aggregate statistics from crawl records, keeping the
+// top n entries for the largest resources and slowest hosts
+func Summarize(records []Record, n int) Stats {
+	stats := Stats{
+		Total:    len(records),
+		ByStatus: make(map[int]int),
+		ByType:   make(map[string]int),
+		ByCause:  make(map[string]int),
+	}
+
+	type hostTotals struct {
+		requests int
+		duration time.Duration
+		dns      time.Duration
+		connect  time.Duration
+		tls      time.Duration
+		ttfb     time.Duration
+	}
+	hosts := make(map[string]*hostTotals)
+
+	type rateKey struct {
+		host  string
+		start time.Time
+	}
+	rates := make(map[rateKey]*HostRate)
+
+	for _, rec := range records {
+		stats.TotalBytes += rec.Bytes
+		stats.ByStatus[rec.StatusCode]++
+		stats.ByType[rec.Type]++
+		if rec.Cause != "" {
+			stats.ByCause[rec.Cause]++
+		}
+		if rec.Error != "" {
+			stats.Failures = append(stats.Failures, rec)
+		}
+		if rec.Mismatch {
+			stats.Mismatched = append(stats.Mismatched, rec)
+		}
+		if rec.Anomaly != "" {
+			stats.Anomalies = append(stats.Anomalies, rec)
+		}
+
+		h, ok := hosts[rec.Host]
+		if !ok {
+			h = &hostTotals{}
+			hosts[rec.Host] = h
+		}
+		h.requests++
+		h.duration += time.Duration(rec.DurationMs) * time.Millisecond
+		h.dns += time.Duration(rec.DNSMs) * time.Millisecond
+		h.connect += time.Duration(rec.ConnectMs) * time.Millisecond
+		h.tls += time.Duration(rec.TLSMs) * time.Millisecond
+		h.ttfb += time.Duration(rec.TTFBMs) * time.Millisecond
+
+		if !rec.Time.IsZero() {
+			key := rateKey{rec.Host, rec.Time.UTC().Truncate(RateWindow)}
+			r, ok := rates[key]
+			if !ok {
+				r = &HostRate{Host: key.host, Start: key.start}
+				rates[key] = r
+			}
+			r.Requests++
+			r.Bytes += rec.Bytes
+		}
+	}
+
+	sort.SliceStable(stats.Failures, func(i, j int) bool {
+		return stats.Failures[i].URL < stats.Failures[j].URL
+	})
+
+	largest := make([]Record, len(records))
+	copy(largest, records)
+	sort.SliceStable(largest, func(i, j int) bool {
+		return largest[i].Bytes > largest[j].Bytes
+	})
+	if len(largest) > n {
+		largest = largest[:n]
+	}
+	stats.Largest = largest
+
+	for host, h := range hosts {
+		requests := time.Duration(h.requests)
+		stats.Slowest = append(stats.Slowest, HostTiming{
+			Host:     host,
+			Requests: h.requests,
+			Average:  h.duration / requests,
+			DNS:      h.dns / requests,
+			Connect:  h.connect / requests,
+			TLS:      h.tls / requests,
+			TTFB:     h.ttfb / requests,
+		})
+	}
+	sort.Slice(stats.Slowest, func(i, j int) bool {
+		if stats.Slowest[i].Average != stats.Slowest[j].Average {
+			return stats.Slowest[i].Average > stats.Slowest[j].Average
+		}
+		return stats.Slowest[i].Host < stats.Slowest[j].Host
+	})
+	if len(stats.Slowest) > n {
+		stats.Slowest = stats.Slowest[:n]
+	}
+
+	for _, r := range rates {
+		stats.Rates = append(stats.Rates, *r)
+	}
+	sort.Slice(stats.Rates, func(i, j int) bool {
+		if stats.Rates[i].Host != stats.Rates[j].Host {
+			return stats.Rates[i].Host < stats.Rates[j].Host
+		}
+		return stats.Rates[i].Start.Before(stats.Rates[j].Start)
+	})
+
+	return stats
+}
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aldehir/ue2-docs/internal/s3"
+)
+
+// Backend holds the files of a mirror. Storage writes and hashes every
+// file as a local temporary file first, then hands the complete file to
+// the backend, so a backend never holds a partial file
+type Backend interface {
+	// Put stores the complete local file tmp at relPath, replacing any
+	// previous file there. tmp may be moved; the caller removes it if not
+	Put(ctx context.Context, relPath, tmp string, size int64) error
+	// Open opens the file at relPath; a missing file is reported with an
+	// error wrapping os.ErrNotExist
+	Open(ctx context.Context, relPath string) (io.ReadCloser, error)
+	// Remove deletes the file at relPath; a missing file is not an error
+	Remove(ctx context.Context, relPath string) error
+	// String describes where the files are kept
+	String() string
+}
+
+// Dir returns the Backend keeping files under a local directory
+func Dir(root string) Backend {
+	return dirBackend(root)
+}
+
+// dirBackend keeps files under a local directory
+type dirBackend string
+
+// path returns the on-disk path of relPath, failing for paths leading out
+// of the directory
+func (d dirBackend) path(relPath string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(relPath)) {
+		return "", fmt.Errorf("unsafe path %q: outside %s", relPath, string(d))
+	}
+	return filepath.Join(string(d), filepath.FromSlash(relPath)), nil
+}
+
+func (d dirBackend) Put(ctx context.Context, relPath, tmp string, size int64) error {
+	full, err := d.path(relPath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return fmt.Errorf("creating directory for %s: %w", relPath, err)
+	}
+	if err := os.Rename(tmp, full); err != nil {
+		return fmt.Errorf("renaming %s: %w", relPath, err)
+	}
+	return nil
+}
+
+func (d dirBackend) Open(ctx context.Context, relPath string) (io.ReadCloser, error) {
+	full, err := d.path(relPath)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(full)
+}
+
+func (d dirBackend) Remove(ctx context.Context, relPath string) error {
+	full, err := d.path(relPath)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(full)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing %s: %w", relPath, err)
+	}
+	return nil
+}
+
+func (d dirBackend) String() string {
+	return string(d)
+}
+
+// IsS3 reports whether an output location is an s3://bucket/prefix URL
+func IsS3(location string) bool {
+	return strings.HasPrefix(location, "s3://")
+}
+
+// S3 returns the Backend keeping files as objects in an S3 bucket, under
+// the prefix of an s3://bucket/prefix location. Objects are given the
+// content type of their extension, so the bucket can be served as a
+// static website
+func S3(client *s3.Client, location string) (Backend, error) {
+	rest, ok := strings.CutPrefix(location, "s3://")
+	if !ok {
+		return nil, fmt.Errorf("%q is not an s3:// location", location)
+	}
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("%q names no bucket", location)
+	}
+	return &s3Backend{client: client, bucket: bucket, prefix: strings.Trim(prefix, "/")}, nil
+}
+
+// s3Backend keeps files as objects in an S3 bucket
+type s3Backend struct {
+	client *s3.Client
+	bucket string
+	prefix string // Without slashes at either end; "" = bucket root
+}
+
+func (b *s3Backend) key(relPath string) string {
+	if b.prefix == "" {
+		return relPath
+	}
+	return b.prefix + "/" + relPath
+}
+
+func (b *s3Backend) Put(ctx context.Context, relPath, tmp string, size int64) error {
+	f, err := os.Open(tmp)
+	if err != nil {
+		return fmt.Errorf("uploading %s: %w", relPath, err)
+	}
+	defer f.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(relPath))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return b.client.Put(ctx, b.bucket, b.key(relPath), f, size, contentType)
+}
+
+func (b *s3Backend) Open(ctx context.Context, relPath string) (io.ReadCloser, error) {
+	return b.client.Get(ctx, b.bucket, b.key(relPath))
+}
+
+func (b *s3Backend) Remove(ctx context.Context, relPath string) error {
+	return b.client.Delete(ctx, b.bucket, b.key(relPath))
+}
+
+func (b *s3Backend) String() string {
+	if b.prefix == "" {
+		return "s3://" + b.bucket
+	}
+	return "s3://" + b.bucket + "/" + b.prefix
+}
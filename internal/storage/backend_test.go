@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aldehir/ue2-docs/internal/manifest"
+	"github.com/aldehir/ue2-docs/internal/minisign"
+)
+
+// memBackend keeps files in memory, standing in for an object store
+type memBackend struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func newMemBackend() *memBackend {
+	return &memBackend{files: make(map[string][]byte)}
+}
+
+func (m *memBackend) Put(ctx context.Context, relPath, tmp string, size int64) error {
+	data, err := os.ReadFile(tmp)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != size {
+		return fmt.Errorf("put %s: %d bytes, want %d", relPath, len(data), size)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[relPath] = data
+	return nil
+}
+
+func (m *memBackend) Open(ctx context.Context, relPath string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.files[relPath]
+	if !ok {
+		return nil, fmt.Errorf("opening %s: %w", relPath, os.ErrNotExist)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *memBackend) Remove(ctx context.Context, relPath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.files, relPath)
+	return nil
+}
+
+func (m *memBackend) String() string {
+	return "mem://"
+}
+
+func TestStorage_Backend(t *testing.T) {
+	backend := newMemBackend()
+	s := NewWith(backend)
+	if s.Local() {
+		t.Error("Local() = true for an object store")
+	}
+	ctx := context.Background()
+
+	if _, err := s.Save(ctx, manifest.Entry{Path: "example.com/page.html"}, strings.NewReader("page")); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := s.SaveOriginal(ctx, "example.com/page.html", strings.NewReader("original")); err != nil {
+		t.Fatalf("SaveOriginal() error = %v", err)
+	}
+	pending, err := s.Create()
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	pending.Write([]byte("streamed"))
+	if _, err := pending.Commit(manifest.Entry{Path: "example.com/big.zip"}); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	pending.Discard()
+
+	_, secret, _ := ed25519.GenerateKey(nil)
+	s.SignWith(&minisign.SecretKey{Key: secret})
+	if err := s.WriteManifest(); err != nil {
+		t.Fatalf("WriteManifest() error = %v", err)
+	}
+
+	for relPath, want := range map[string]string{
+		"example.com/page.html":                "page",
+		OriginalDir + "/example.com/page.html": "original",
+		"example.com/big.zip":                  "streamed",
+	} {
+		if got := string(backend.files[relPath]); got != want {
+			t.Errorf("%s = %q, want %q", relPath, got, want)
+		}
+	}
+	if _, ok := backend.files[manifest.Filename+minisign.Extension]; !ok {
+		t.Error("manifest signature not stored")
+	}
+
+	reopened, err := OpenWith(backend)
+	if err != nil {
+		t.Fatalf("OpenWith() error = %v", err)
+	}
+	if reopened.Manifest().Len() != 2 {
+		t.Errorf("reopened manifest has %d entries, want 2", reopened.Manifest().Len())
+	}
+
+	if err := reopened.Remove("example.com/page.html"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, ok := backend.files["example.com/page.html"]; ok {
+		t.Error("removed file still stored")
+	}
+}
+
+func TestOpenWith_NoManifest(t *testing.T) {
+	s, err := OpenWith(newMemBackend())
+	if err != nil {
+		t.Fatalf("OpenWith() error = %v", err)
+	}
+	if s.Manifest().Len() != 0 {
+		t.Errorf("manifest has %d entries, want 0", s.Manifest().Len())
+	}
+}
+
+func TestDir_UnsafePath(t *testing.T) {
+	root := t.TempDir()
+	d := Dir(root + "/mirror")
+	tmp := root + "/tmp"
+	os.WriteFile(tmp, []byte("payload"), 0644)
+
+	if err := d.Put(context.Background(), "../payload.txt", tmp, 7); err == nil {
+		t.Error("Put() outside the directory succeeded")
+	}
+	if _, err := os.Stat(root + "/payload.txt"); !os.IsNotExist(err) {
+		t.Error("Put() wrote outside the directory")
+	}
+	if _, err := d.Open(context.Background(), "../tmp"); err == nil {
+		t.Error("Open() outside the directory succeeded")
+	}
+	if err := d.Remove(context.Background(), "../tmp"); err == nil {
+		t.Error("Remove() outside the directory succeeded")
+	}
+}
+
+func TestS3(t *testing.T) {
+	tests := []struct {
+		location string
+		want     string
+		wantErr  bool
+	}{
+		{"s3://docs", "s3://docs", false},
+		{"s3://docs/", "s3://docs", false},
+		{"s3://docs/mirror/two/", "s3://docs/mirror/two", false},
+		{"s3:///prefix", "", true},
+		{"./output", "", true},
+	}
+	for _, tt := range tests {
+		backend, err := S3(nil, tt.location)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("S3(%q) error = %v, wantErr %v", tt.location, err, tt.wantErr)
+			continue
+		}
+		if err == nil && backend.String() != tt.want {
+			t.Errorf("S3(%q) = %s, want %s", tt.location, backend, tt.want)
+		}
+	}
+
+	backend, _ := S3(nil, "s3://docs/mirror")
+	if key := backend.(*s3Backend).key("example.com/index.html"); key != "mirror/example.com/index.html" {
+		t.Errorf("key = %q", key)
+	}
+}
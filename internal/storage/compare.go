@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/aldehir/ue2-docs/internal/manifest"
+)
+
+// Change is a file a save would have changed, in compare-only mode
+type Change struct {
+	Path string
+	URL  string
+	New  bool // The mirror has no file at Path
+}
+
+// CompareOnly makes saves compare the content of each file with the
+// mirror's copy instead of writing it, recording the files that would
+// change; see Changes. Nothing is written to the mirror meanwhile, not
+// even the manifest
+func (s *Storage) CompareOnly(on bool) {
+	s.compare = on
+}
+
+// Changes returns the files saves found different from, or missing in,
+// the mirror in compare-only mode, sorted by path
+func (s *Storage) Changes() []Change {
+	s.changesMu.Lock()
+	defer s.changesMu.Unlock()
+	changes := append([]Change(nil), s.changes...)
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// compareSave hashes the contents of r as a save of entry would, comparing
+// it with the mirror's copy instead of writing it
+func (s *Storage) compareSave(ctx context.Context, entry manifest.Entry, r io.Reader) (manifest.Entry, error) {
+	hash := sha256.New()
+	n, err := io.Copy(hash, ctxReader{ctx, r})
+	if err != nil {
+		return entry, fmt.Errorf("reading %s: %w", entry.Path, err)
+	}
+	return s.compareEntry(ctx, entry, n, hex.EncodeToString(hash.Sum(nil)))
+}
+
+// compareEntry records entry as a change unless the mirror's copy of it
+// has the given content size and SHA-256 hash, filling both in on the
+// returned entry
+func (s *Storage) compareEntry(ctx context.Context, entry manifest.Entry, size int64, sum string) (manifest.Entry, error) {
+	entry.Size = size
+	entry.SHA256 = sum
+
+	// The mirror's copy may be stored with another encoding than this save
+	existing := entry
+	existing.Encoding = ""
+	if prev, ok := s.manifest.Get(entry.Path); ok {
+		existing = prev
+	}
+	prevSize, prevSum, err := s.contentHash(ctx, existing)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		s.addChange(Change{Path: entry.Path, URL: entry.URL, New: true})
+	case err != nil:
+		return entry, err
+	case prevSize != size || prevSum != sum:
+		s.addChange(Change{Path: entry.Path, URL: entry.URL})
+	default:
+		s.unchanged.Add(1)
+	}
+	return entry, nil
+}
+
+// contentHash returns the size and SHA-256 hash of the content of the
+// mirror's file of entry
+func (s *Storage) contentHash(ctx context.Context, entry manifest.Entry) (int64, string, error) {
+	r, err := s.OpenEntry(ctx, entry)
+	if err != nil {
+		return 0, "", err
+	}
+	defer r.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(hash, r)
+	if err != nil {
+		return 0, "", fmt.Errorf("reading %s: %w", entry.File(), err)
+	}
+	return n, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (s *Storage) addChange(c Change) {
+	s.changesMu.Lock()
+	defer s.changesMu.Unlock()
+	s.changes = append(s.changes, c)
+}
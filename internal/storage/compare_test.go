@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aldehir/ue2-docs/internal/manifest"
+)
+
+func TestStorage_CompareOnly(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	mirror := New(dir)
+	for path, content := range map[string]string{
+		"example.com/same.html":    "same",
+		"example.com/changed.html": "before",
+	} {
+		if _, err := mirror.Save(ctx, manifest.Entry{Path: path, Encoding: manifest.EncodingGzip}, strings.NewReader(content)); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+	if err := mirror.WriteManifest(); err != nil {
+		t.Fatalf("WriteManifest() error = %v", err)
+	}
+
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	s.CompareOnly(true)
+	// Encodings may differ from the mirror's; content is compared
+	for path, content := range map[string]string{
+		"example.com/same.html":    "same",
+		"example.com/changed.html": "after",
+		"example.com/new.html":     "new",
+	} {
+		if _, err := s.Save(ctx, manifest.Entry{Path: path, URL: "https://" + path}, strings.NewReader(content)); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+	pending, err := s.Create()
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	pending.Write([]byte("image"))
+	if _, err := pending.Commit(manifest.Entry{Path: "example.com/image.png"}); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if err := s.WriteManifest(); err != nil {
+		t.Fatalf("WriteManifest() error = %v", err)
+	}
+
+	want := []Change{
+		{Path: "example.com/changed.html", URL: "https://example.com/changed.html"},
+		{Path: "example.com/image.png", New: true},
+		{Path: "example.com/new.html", URL: "https://example.com/new.html", New: true},
+	}
+	if got := s.Changes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Changes() = %+v, want %+v", got, want)
+	}
+	if s.Unchanged() != 1 {
+		t.Errorf("Unchanged() = %d, want 1", s.Unchanged())
+	}
+
+	// Nothing was written to the mirror
+	for _, path := range []string{"example.com/new.html", "example.com/image.png", "example.com/changed.html"} {
+		if _, err := os.Stat(s.FullPath(path)); !os.IsNotExist(err) {
+			t.Errorf("%s written in compare-only mode", path)
+		}
+	}
+	if data, err := s.ReadEntry(ctx, manifest.Entry{Path: "example.com/changed.html", Encoding: manifest.EncodingGzip}); err != nil || string(data) != "before" {
+		t.Errorf("mirror copy = %q, %v, want %q", data, err, "before")
+	}
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if reopened.Manifest().Len() != 2 {
+		t.Errorf("manifest has %d entries, want the mirror's 2", reopened.Manifest().Len())
+	}
+}
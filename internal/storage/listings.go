@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ListingMarker starts every directory listing written by
+// WriteDirectoryListings, telling them apart from fetched pages
+const ListingMarker = "<!-- ue2-docs directory listing -->"
+
+// IsListing reports whether the file at full is a generated directory
+// listing
+func IsListing(full string) bool {
+	f, err := os.Open(full)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, len(ListingMarker))
+	if _, err := io.ReadFull(f, head); err != nil {
+		return false
+	}
+	return bytes.Equal(head, []byte(ListingMarker))
+}
+
+// WriteDirectoryListings writes an index.html listing the subdirectories
+// and files of every directory of the mirror without an index page of its
+// own, such as asset folders, so a web server serving the mirror answers
+// directory URLs with a page rather than 403 or 404. Listings are not
+// recorded in the manifest, and the storage root is left to the version
+// index in mirrors with versions. It returns the number of listings
+// written
+func (s *Storage) WriteDirectoryListings() (int, error) {
+	if s.compare {
+		return 0, nil
+	}
+
+	type dirEntries struct {
+		dirs  map[string]bool
+		files map[string]int64
+	}
+	dirs := map[string]*dirEntries{}
+	dirOf := func(dir string) *dirEntries {
+		d := dirs[dir]
+		if d == nil {
+			d = &dirEntries{dirs: map[string]bool{}, files: map[string]int64{}}
+			dirs[dir] = d
+		}
+		return d
+	}
+	for _, e := range s.manifest.Entries() {
+		dir, name := path.Split(e.Path)
+		dir = strings.TrimSuffix(dir, "/")
+		if dir == "" {
+			dir = "."
+		}
+		dirOf(dir).files[name] = e.Size
+		for dir != "." {
+			parent, name := path.Split(dir)
+			parent = strings.TrimSuffix(parent, "/")
+			if parent == "" {
+				parent = "."
+			}
+			dirOf(parent).dirs[name] = true
+			dir = parent
+		}
+	}
+	if len(s.manifest.VersionRoots()) > 0 {
+		delete(dirs, ".")
+	}
+
+	names := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		names = append(names, dir)
+	}
+	sort.Strings(names)
+
+	written := 0
+	for _, dir := range names {
+		d := dirs[dir]
+		if _, ok := d.files["index.html"]; ok {
+			continue
+		}
+		relPath := path.Join(dir, "index.html")
+		page := listingPage(dir, sortedKeys(d.dirs), d.files)
+		if _, _, err := s.write(context.Background(), relPath, "", strings.NewReader(page), time.Time{}); err != nil {
+			return written, fmt.Errorf("writing directory listing: %w", err)
+		}
+		written++
+	}
+	return written, nil
+}
+
+// listingPage renders the listing of dir, linking each subdirectory's
+// index page so the listing works when browsed from disk too
+func listingPage(dir string, subdirs []string, files map[string]int64) string {
+	title := "/"
+	if dir != "." {
+		title = "/" + dir + "/"
+	}
+
+	var sb strings.Builder
+	sb.WriteString(ListingMarker + "\n")
+	sb.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<meta name=\"robots\" content=\"noindex\">\n")
+	fmt.Fprintf(&sb, "<title>Index of %s</title>\n</head>\n<body>\n", html.EscapeString(title))
+	fmt.Fprintf(&sb, "<h1>Index of %s</h1>\n<ul>\n", html.EscapeString(title))
+	if dir != "." {
+		sb.WriteString("<li><a href=\"../index.html\">../</a></li>\n")
+	}
+	for _, sub := range subdirs {
+		fmt.Fprintf(&sb, "<li><a href=\"%s\">%s/</a></li>\n",
+			html.EscapeString(escapePath(sub)+"/index.html"), html.EscapeString(sub))
+	}
+	for _, name := range sortedKeys(files) {
+		fmt.Fprintf(&sb, "<li><a href=\"%s\">%s</a> (%d bytes)</li>\n",
+			html.EscapeString(escapePath(name)), html.EscapeString(name), files[name])
+	}
+	sb.WriteString("</ul>\n</body>\n</html>\n")
+	return sb.String()
+}
+
+// escapePath escapes a file name for use in a relative link
+func escapePath(name string) string {
+	return strings.NewReplacer("%", "%25", "#", "%23", "?", "%3F", " ", "%20").Replace(name)
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aldehir/ue2-docs/internal/manifest"
+)
+
+func TestStorage_WriteDirectoryListings(t *testing.T) {
+	s := New(t.TempDir())
+	for _, path := range []string{
+		"udn.epicgames.com/Two/index.html",
+		"udn.epicgames.com/Two/WebHome.html",
+		"udn.epicgames.com/Two/images/logo.png",
+		"udn.epicgames.com/Two/images/icons/warning.gif",
+	} {
+		if _, err := s.Save(context.Background(), manifest.Entry{Path: path}, strings.NewReader("content")); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	written, err := s.WriteDirectoryListings()
+	if err != nil {
+		t.Fatalf("WriteDirectoryListings() error = %v", err)
+	}
+	// The root, udn.epicgames.com, images and icons; Two has its own index
+	if written != 4 {
+		t.Errorf("WriteDirectoryListings() = %d, want 4", written)
+	}
+	if data, _ := os.ReadFile(s.FullPath("udn.epicgames.com/Two/index.html")); string(data) != "content" {
+		t.Errorf("existing index page replaced with %q", data)
+	}
+
+	listing := s.FullPath("udn.epicgames.com/Two/images/index.html")
+	data, err := os.ReadFile(listing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := string(data)
+	for _, link := range []string{
+		`<a href="../index.html">../</a>`,
+		`<a href="icons/index.html">icons/</a>`,
+		`<a href="logo.png">logo.png</a>`,
+	} {
+		if !strings.Contains(page, link) {
+			t.Errorf("listing missing %s:\n%s", link, page)
+		}
+	}
+	if !IsListing(listing) || IsListing(s.FullPath("udn.epicgames.com/Two/index.html")) {
+		t.Error("IsListing() does not tell listings from pages")
+	}
+	if _, ok := s.Manifest().Get("udn.epicgames.com/Two/images/index.html"); ok {
+		t.Error("listing recorded in the manifest")
+	}
+
+	// The root is left to the version index of versioned mirrors
+	s.Manifest().SetVersionRoot("two", "udn.epicgames.com/Two/index.html")
+	os.Remove(s.FullPath("index.html"))
+	if _, err := s.WriteDirectoryListings(); err != nil {
+		t.Fatalf("WriteDirectoryListings() error = %v", err)
+	}
+	if _, err := os.Stat(s.FullPath("index.html")); !os.IsNotExist(err) {
+		t.Error("root listing written for a versioned mirror")
+	}
+}
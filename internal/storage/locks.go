@@ -0,0 +1,46 @@
+package storage
+
+import "sync"
+
+// pathLocks hands out a mutex per path, dropping it once no one holds it so
+// the map doesn't grow with every file in the mirror
+type pathLocks struct {
+	mu    sync.Mutex
+	locks map[string]*pathLock
+}
+
+type pathLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+func newPathLocks() *pathLocks {
+	return &pathLocks{
+		locks: make(map[string]*pathLock),
+	}
+}
+
+// lock acquires the lock for a path and returns the function releasing it
+func (pl *pathLocks) lock(path string) func() {
+	pl.mu.Lock()
+	l, ok := pl.locks[path]
+	if !ok {
+		l = &pathLock{}
+		pl.locks[path] = l
+	}
+	l.refs++
+	pl.mu.Unlock()
+
+	l.mu.Lock()
+
+	return func() {
+		l.mu.Unlock()
+
+		pl.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(pl.locks, path)
+		}
+		pl.mu.Unlock()
+	}
+}
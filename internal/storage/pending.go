@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aldehir/ue2-docs/internal/manifest"
+)
+
+// PendingFile is a resource being streamed to disk before its final path
+// is known, hashed as it is written. It appears in the mirror only once
+// committed
+type PendingFile struct {
+	s    *Storage
+	f    *os.File
+	hash hash.Hash
+	size int64
+	err  error // First write error, reported by every later call
+	done bool
+}
+
+// Create starts a pending file, so a large body can be saved without
+// holding it in memory. It must be committed or discarded
+func (s *Storage) Create() (*PendingFile, error) {
+	dir, err := s.tempDir(".")
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(dir, ".tmp-stream-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating pending file: %w", err)
+	}
+	return &PendingFile{s: s, f: f, hash: sha256.New()}, nil
+}
+
+func (p *PendingFile) Write(b []byte) (int, error) {
+	if p.err != nil {
+		return 0, p.err
+	}
+	n, err := p.f.Write(b)
+	p.hash.Write(b[:n])
+	p.size += int64(n)
+	if err != nil {
+		p.err = fmt.Errorf("writing pending file: %w", err)
+	}
+	return n, p.err
+}
+
+// Reset discards everything written so far, for retrying a download
+func (p *PendingFile) Reset() {
+	if p.err != nil {
+		return
+	}
+	if err := p.f.Truncate(0); err != nil {
+		p.err = fmt.Errorf("truncating pending file: %w", err)
+		return
+	}
+	if _, err := p.f.Seek(0, io.SeekStart); err != nil {
+		p.err = fmt.Errorf("truncating pending file: %w", err)
+		return
+	}
+	p.hash.Reset()
+	p.size = 0
+}
+
+// ReadAll returns the contents written so far
+func (p *PendingFile) ReadAll() ([]byte, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	data, err := os.ReadFile(p.f.Name())
+	if err != nil {
+		return nil, fmt.Errorf("reading pending file: %w", err)
+	}
+	return data, nil
+}
+
+// Commit moves the file into place at entry.Path and records it in the
+// manifest, like Save
+func (p *PendingFile) Commit(entry manifest.Entry) (manifest.Entry, error) {
+	if p.err != nil {
+		return entry, p.err
+	}
+
+	unlock := p.s.locks.lock(entry.Path)
+	defer unlock()
+
+	if err := p.f.Chmod(0644); err != nil {
+		return entry, fmt.Errorf("writing %s: %w", entry.Path, err)
+	}
+	if err := p.f.Close(); err != nil {
+		return entry, fmt.Errorf("writing %s: %w", entry.Path, err)
+	}
+	sum := hex.EncodeToString(p.hash.Sum(nil))
+	defer os.Remove(p.f.Name()) // No-op once renamed
+	if p.s.compare {
+		p.done = true
+		return p.s.compareEntry(context.Background(), entry, p.size, sum)
+	}
+	if err := p.s.place(context.Background(), p.f.Name(), entry.Path, p.size, sum, entry.LastModified); err != nil {
+		return entry, err
+	}
+	p.done = true
+
+	entry.Size = p.size
+	entry.SHA256 = sum
+	entry.SavedAt = time.Now().UTC()
+	p.s.manifest.Add(entry)
+
+	return entry, nil
+}
+
+// Discard removes the file unless it was committed
+func (p *PendingFile) Discard() {
+	if p.done {
+		return
+	}
+	p.done = true
+	p.f.Close()
+	os.Remove(p.f.Name())
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aldehir/ue2-docs/internal/manifest"
+)
+
+func TestPendingFile_Commit(t *testing.T) {
+	s := New(t.TempDir())
+
+	p, err := s.Create()
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	defer p.Discard()
+
+	// A retried download starts over
+	p.Write([]byte("partial"))
+	p.Reset()
+	p.Write([]byte("hel"))
+	p.Write([]byte("lo"))
+
+	if data, err := p.ReadAll(); err != nil || string(data) != "hello" {
+		t.Errorf("ReadAll() = %q, %v", data, err)
+	}
+
+	entry, err := p.Commit(manifest.Entry{URL: "https://example.com/a.png", Path: "example.com/a.png"})
+	if err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	// sha256("hello")
+	if entry.Size != 5 || entry.SHA256 != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("Commit() = %+v", entry)
+	}
+	if _, ok := s.Manifest().Get("example.com/a.png"); !ok {
+		t.Error("committed file missing from the manifest")
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.Root(), "example.com", "a.png"))
+	if err != nil || string(data) != "hello" {
+		t.Errorf("committed content = %q, %v", data, err)
+	}
+
+	// Discarding after a commit keeps the file
+	p.Discard()
+	if _, err := os.Stat(filepath.Join(s.Root(), "example.com", "a.png")); err != nil {
+		t.Errorf("Discard() after Commit() removed the file: %v", err)
+	}
+}
+
+func TestPendingFile_Discard(t *testing.T) {
+	s := New(t.TempDir())
+
+	p, err := s.Create()
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	p.Write([]byte("error page"))
+	p.Discard()
+
+	entries, err := os.ReadDir(s.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("found %d files after Discard(), want none", len(entries))
+	}
+	if s.Manifest().Len() != 0 {
+		t.Error("discarded file recorded in the manifest")
+	}
+}
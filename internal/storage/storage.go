@@ -0,0 +1,560 @@
+package storage
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aldehir/ue2-docs/internal/manifest"
+	"github.com/aldehir/ue2-docs/internal/minisign"
+)
+
+// OriginalDir is the directory, relative to the storage root, holding the
+// unmodified copies of pages saved with SaveOriginal
+const OriginalDir = ".original"
+
+// Storage saves fetched resources under a root directory, mirroring the
+// host and path structure of their URLs, and records every saved file in a
+// manifest
+//
+// Saves to the same path are serialized, and each file is written to a
+// temporary name and renamed into place, so concurrent workers never
+// interleave writes and the manifest only ever describes complete files.
+// Files may be kept elsewhere than on disk, such as in an S3 bucket, by
+// another Backend.
+type Storage struct {
+	root     string
+	backend  Backend
+	local    bool // The backend is a directory, at root
+	locks    *pathLocks
+	manifest *manifest.Manifest
+	signKey  *minisign.SecretKey
+
+	compressManifest bool // Write manifest.json.zst instead of manifest.json
+
+	rsync     bool         // Keep unchanged files and date files by LastModified
+	unchanged atomic.Int64 // Saves that left an identical file in place
+
+	compare   bool // Compare saves with the mirror instead of writing them
+	changesMu sync.Mutex
+	changes   []Change
+}
+
+// New creates a new Storage rooted at the given directory
+func New(root string) *Storage {
+	return NewWith(Dir(root))
+}
+
+// NewWith creates a new Storage keeping files in backend
+func NewWith(backend Backend) *Storage {
+	_, local := backend.(dirBackend)
+	return &Storage{
+		root:     backend.String(),
+		backend:  backend,
+		local:    local,
+		locks:    newPathLocks(),
+		manifest: manifest.New(),
+	}
+}
+
+// Open opens an existing mirror, loading its manifest if it has one
+func Open(root string) (*Storage, error) {
+	return OpenWith(Dir(root))
+}
+
+// OpenWith opens an existing mirror kept in backend, loading its manifest
+// if it has one
+func OpenWith(backend Backend) (*Storage, error) {
+	s := NewWith(backend)
+
+	m, err := s.loadManifest()
+	switch {
+	case err == nil:
+		s.manifest = m
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// loadManifest reads the manifest from the backend, the compressed one if
+// there is one
+func (s *Storage) loadManifest() (*manifest.Manifest, error) {
+	if s.local {
+		return manifest.LoadDir(s.root)
+	}
+
+	r, err := s.backend.Open(context.Background(), manifest.CompressedFilename)
+	if errors.Is(err, os.ErrNotExist) {
+		r, err = s.backend.Open(context.Background(), manifest.Filename)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	defer r.Close()
+	return manifest.Read(r)
+}
+
+// Root returns the root directory of the storage, or the location of its
+// files for other backends
+func (s *Storage) Root() string {
+	return s.root
+}
+
+// Local reports whether files are kept on disk, under Root
+func (s *Storage) Local() bool {
+	return s.local
+}
+
+// Manifest returns the manifest of files saved so far
+func (s *Storage) Manifest() *manifest.Manifest {
+	return s.manifest
+}
+
+// SignWith makes WriteManifest sign the manifest with key; nil disables
+// signing
+func (s *Storage) SignWith(key *minisign.SecretKey) {
+	s.signKey = key
+}
+
+// RsyncFriendly makes saves leave a file whose content is unchanged in
+// place, and set the modification time of saved files to their entry's
+// LastModified, so rsync only transfers what changed between crawls. It
+// only applies to files kept on disk
+func (s *Storage) RsyncFriendly(on bool) {
+	s.rsync = on && s.local
+}
+
+// CompressManifest makes WriteManifest write the manifest
+// Zstandard-compressed, as manifest.CompressedFilename
+func (s *Storage) CompressManifest(on bool) {
+	s.compressManifest = on
+}
+
+// Unchanged returns the number of saves that found the file already saved
+// with the same content, in rsync-friendly and compare-only mode
+func (s *Storage) Unchanged() int {
+	return int(s.unchanged.Load())
+}
+
+// WriteManifest saves the manifest to manifest.json in the storage root,
+// with a manifest.json.minisig signature if a signing key is set. A
+// compressed manifest is saved as manifest.json.zst instead, and a manifest
+// left under the other name removed
+func (s *Storage) WriteManifest() error {
+	if s.compare {
+		return nil
+	}
+	name, stale := manifest.Filename, manifest.CompressedFilename
+	if s.compressManifest {
+		name, stale = stale, name
+	}
+
+	dir := s.root
+	if !s.local {
+		// Written locally first, then uploaded
+		tmp, err := os.MkdirTemp("", "ue2-docs-manifest-*")
+		if err != nil {
+			return fmt.Errorf("creating manifest: %w", err)
+		}
+		defer os.RemoveAll(tmp)
+		dir = tmp
+	} else if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating output directory: %w", err)
+	}
+
+	path := filepath.Join(dir, name)
+	if err := s.manifest.Save(path); err != nil {
+		return err
+	}
+	if s.signKey != nil {
+		if err := s.signKey.SignFile(path); err != nil {
+			return fmt.Errorf("signing manifest: %w", err)
+		}
+	}
+
+	if !s.local {
+		names := []string{name}
+		if s.signKey != nil {
+			names = append(names, name+minisign.Extension)
+		}
+		for _, name := range names {
+			if err := s.upload(filepath.Join(dir, name), name); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, name := range []string{stale, stale + minisign.Extension} {
+		if err := s.backend.Remove(context.Background(), name); err != nil {
+			return fmt.Errorf("removing stale manifest: %w", err)
+		}
+	}
+	return nil
+}
+
+// upload puts the local file src to relPath in the backend
+func (s *Storage) upload(src, relPath string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	return s.backend.Put(context.Background(), relPath, src, info.Size())
+}
+
+// PathFor maps a URL to a slash-separated path relative to the storage root
+//
+// The mapping is <host>/<path>. Paths without an extension (including the
+// site root) are treated as directories and mapped to an index.html inside
+// them, so that "/docs" and "/docs/page.html" can coexist on disk. URLs
+// with a query, such as the pages of a paginated listing, get it added to
+// the file name: "/search?start=20" maps to search/index_start=20.html.
+func PathFor(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse URL %q: %w", rawURL, err)
+	}
+
+	if u.Host == "" {
+		return "", fmt.Errorf("URL %q has no host", rawURL)
+	}
+	// The host names a directory, which must not lead out of the root
+	if u.Host == "." || u.Host == ".." || strings.ContainsAny(u.Host, `/\`) {
+		return "", fmt.Errorf("URL %q has an invalid host", rawURL)
+	}
+
+	p := path.Clean("/" + u.Path)
+	if path.Ext(p) == "" {
+		p = path.Join(p, "index.html")
+	}
+	if u.RawQuery != "" {
+		ext := path.Ext(p)
+		p = strings.TrimSuffix(p, ext) + "_" + queryName(u.RawQuery) + ext
+	}
+
+	return sanitize(u.Host) + p, nil
+}
+
+// LocalPathFor maps a file:// URL to a path relative to the storage root by
+// taking its path relative to baseDir, so a local mirror already laid out
+// as <host>/<path> (by HTTrack or by this tool) keeps its layout
+func LocalPathFor(rawURL, baseDir string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse URL %q: %w", rawURL, err)
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("URL %q is not a file URL", rawURL)
+	}
+
+	p := path.Clean("/" + u.Path)
+	if path.Ext(p) == "" {
+		p = path.Join(p, "index.html")
+	}
+
+	base := path.Clean("/" + filepath.ToSlash(baseDir))
+	rel := strings.TrimPrefix(p, base)
+	if base != "/" && (rel == p || !strings.HasPrefix(rel, "/")) {
+		return "", fmt.Errorf("%s is outside %s", u.Path, baseDir)
+	}
+
+	return strings.TrimPrefix(rel, "/"), nil
+}
+
+// RelativePath returns the path of target relative to the directory
+// containing from; both are paths as returned by PathFor. The result is
+// used as a link, so the "%" of an escaped query name is escaped again
+func RelativePath(from, target string) string {
+	rel, err := filepath.Rel(path.Dir(from), target)
+	if err != nil {
+		rel = target
+	}
+	return strings.ReplaceAll(filepath.ToSlash(rel), "%", "%25")
+}
+
+// Save writes the contents of r to entry.Path, compressed as its Encoding
+// says, creating parent directories as needed, and records the entry in the
+// manifest once the file is complete. The size and SHA-256 of the data read
+// from r are filled in on the returned entry. Cancelling ctx abandons the
+// write, leaving any previous copy of the file in place
+func (s *Storage) Save(ctx context.Context, entry manifest.Entry, r io.Reader) (manifest.Entry, error) {
+	unlock := s.locks.lock(entry.Path)
+	defer unlock()
+
+	if s.compare {
+		return s.compareSave(ctx, entry, r)
+	}
+
+	n, sum, err := s.write(ctx, entry.File(), entry.Encoding, r, entry.LastModified)
+	if err != nil {
+		return entry, err
+	}
+	// A copy saved with another encoding would be left behind under its
+	// own name
+	if prev, ok := s.manifest.Get(entry.Path); ok && prev.File() != entry.File() {
+		if err := s.backend.Remove(ctx, prev.File()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return entry, err
+		}
+	}
+
+	entry.Size = n
+	entry.SHA256 = sum
+	entry.SavedAt = time.Now().UTC()
+	s.manifest.Add(entry)
+
+	return entry, nil
+}
+
+// SaveOriginal saves the unmodified copy of a resource under OriginalDir,
+// mirroring relPath. Originals are not recorded in the manifest
+func (s *Storage) SaveOriginal(ctx context.Context, relPath string, r io.Reader) error {
+	if s.compare {
+		return nil
+	}
+	relPath = OriginalDir + "/" + relPath
+	unlock := s.locks.lock(relPath)
+	defer unlock()
+
+	_, _, err := s.write(ctx, relPath, "", r, time.Time{})
+	return err
+}
+
+// write atomically writes a file, compressed with encoding unless it is
+// "", returning the size and SHA-256 hash of the data read from r
+func (s *Storage) write(ctx context.Context, relPath, encoding string, r io.Reader, modified time.Time) (int64, string, error) {
+	dir, err := s.tempDir(path.Dir(relPath))
+	if err != nil {
+		return 0, "", err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".tmp-"+path.Base(relPath)+"-*")
+	if err != nil {
+		return 0, "", fmt.Errorf("creating %s: %w", relPath, err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	// The file's own size and hash differ from the content's when it is
+	// compressed; rsync-friendly saves compare files by them
+	hash, stored := sha256.New(), sha256.New()
+	file := &countingWriter{w: io.MultiWriter(tmp, stored)}
+	var out io.Writer = file
+	var gz *gzip.Writer
+	if encoding == manifest.EncodingGzip {
+		gz, _ = gzip.NewWriterLevel(file, gzip.BestCompression)
+		out = gz
+	}
+	n, err := io.Copy(io.MultiWriter(out, hash), ctxReader{ctx, r})
+	if err == nil && gz != nil {
+		err = gz.Close()
+	}
+	if err != nil {
+		tmp.Close()
+		return 0, "", fmt.Errorf("writing %s: %w", relPath, err)
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return 0, "", fmt.Errorf("writing %s: %w", relPath, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, "", fmt.Errorf("writing %s: %w", relPath, err)
+	}
+
+	if err := s.place(ctx, tmp.Name(), relPath, file.n, hex.EncodeToString(stored.Sum(nil)), modified); err != nil {
+		return 0, "", err
+	}
+	return n, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// tempDir returns the directory for the temporary files of saves under
+// dir: dir itself on disk, so files are renamed into place within a
+// directory, and the system's temporary directory otherwise, or when
+// comparing, which leaves the mirror untouched
+func (s *Storage) tempDir(dir string) (string, error) {
+	if !s.local || s.compare {
+		return os.TempDir(), nil
+	}
+	full := s.FullPath(dir)
+	if err := os.MkdirAll(full, 0755); err != nil {
+		return "", fmt.Errorf("creating directory for %s: %w", dir, err)
+	}
+	return full, nil
+}
+
+// place moves the complete temporary file tmp to relPath in the backend.
+// In rsync-friendly mode a file already there with the same content is
+// kept instead, and the file is dated by modified unless it is zero
+func (s *Storage) place(ctx context.Context, tmp, relPath string, size int64, sum string, modified time.Time) error {
+	full := s.FullPath(relPath)
+	if s.rsync && sameContent(full, size, sum) {
+		os.Remove(tmp)
+		s.unchanged.Add(1)
+	} else if err := s.backend.Put(ctx, relPath, tmp, size); err != nil {
+		return err
+	}
+
+	if s.rsync && !modified.IsZero() {
+		if err := os.Chtimes(full, modified, modified); err != nil {
+			return fmt.Errorf("dating %s: %w", relPath, err)
+		}
+	}
+	return nil
+}
+
+// sameContent reports whether the file at full has the given size and
+// SHA-256 hash
+func sameContent(full string, size int64, sum string) bool {
+	info, err := os.Stat(full)
+	if err != nil || !info.Mode().IsRegular() || info.Size() != size {
+		return false
+	}
+
+	f, err := os.Open(full)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return false
+	}
+	return hex.EncodeToString(hash.Sum(nil)) == sum
+}
+
+// Remove deletes a file and its manifest entry
+func (s *Storage) Remove(relPath string) error {
+	unlock := s.locks.lock(relPath)
+	defer unlock()
+
+	file := relPath
+	if entry, ok := s.manifest.Get(relPath); ok {
+		file = entry.File()
+	}
+	if err := s.backend.Remove(context.Background(), file); err != nil {
+		return err
+	}
+	s.manifest.Remove(relPath)
+	return nil
+}
+
+// OpenEntry opens the file of a manifest entry for reading its content,
+// decompressing it if it is stored compressed
+func (s *Storage) OpenEntry(ctx context.Context, entry manifest.Entry) (io.ReadCloser, error) {
+	f, err := s.backend.Open(ctx, entry.File())
+	if err != nil {
+		return nil, err
+	}
+	return Decode(f, entry.Encoding)
+}
+
+// ReadEntry reads the content of a manifest entry's file, decompressing
+// it if it is stored compressed
+func (s *Storage) ReadEntry(ctx context.Context, entry manifest.Entry) ([]byte, error) {
+	r, err := s.OpenEntry(ctx, entry)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", entry.File(), err)
+	}
+	return data, nil
+}
+
+// Decode wraps a file stored with encoding in a reader of its content,
+// closing the file when closed. Files with no encoding are returned as is
+func Decode(f io.ReadCloser, encoding string) (io.ReadCloser, error) {
+	switch encoding {
+	case "":
+		return f, nil
+	case manifest.EncodingGzip:
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("decompressing: %w", err)
+		}
+		return decoder{gz, f}, nil
+	default:
+		f.Close()
+		return nil, fmt.Errorf("unknown encoding %q", encoding)
+	}
+}
+
+// decoder reads a decompressed file, closing the file underneath
+type decoder struct {
+	io.Reader
+	file io.Closer
+}
+
+func (d decoder) Close() error {
+	return d.file.Close()
+}
+
+// FullPath returns the on-disk path for a relative storage path, for
+// files kept on disk
+func (s *Storage) FullPath(relPath string) string {
+	return filepath.Join(s.root, filepath.FromSlash(relPath))
+}
+
+// ctxReader stops reading once its context is cancelled
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// queryName turns a query into part of a file name. Letters, digits and
+// "=-." are kept, "&" becomes an underscore and every other byte is
+// percent-escaped, so distinct queries always get distinct names
+func queryName(query string) string {
+	var b strings.Builder
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '=', c == '-', c == '.':
+			b.WriteByte(c)
+		case c == '&':
+			b.WriteByte('_')
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// sanitize replaces characters in a host that are problematic in file names
+func sanitize(host string) string {
+	return strings.ReplaceAll(host, ":", "_")
+}
@@ -0,0 +1,530 @@
+package storage
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aldehir/ue2-docs/internal/manifest"
+	"github.com/aldehir/ue2-docs/internal/minisign"
+)
+
+func TestPathFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "html page",
+			url:  "https://docs.unrealengine.com/udk/Two/SiteMap.html",
+			want: "docs.unrealengine.com/udk/Two/SiteMap.html",
+		},
+		{
+			name: "image asset",
+			url:  "https://docs.unrealengine.com/udk/Two/rsrc/Two/shot.png",
+			want: "docs.unrealengine.com/udk/Two/rsrc/Two/shot.png",
+		},
+		{
+			name: "extensionless path becomes directory index",
+			url:  "https://docs.unrealengine.com/udk/Two",
+			want: "docs.unrealengine.com/udk/Two/index.html",
+		},
+		{
+			name: "site root",
+			url:  "https://example.com/",
+			want: "example.com/index.html",
+		},
+		{
+			name: "query names a page of its own",
+			url:  "https://udn.epicgames.com/bin/search/Two?limit=10&start=20",
+			want: "udn.epicgames.com/bin/search/Two/index_limit=10_start=20.html",
+		},
+		{
+			name: "query characters that would collide are escaped",
+			url:  "https://example.com/search?q=a_b%20c&x=y/z",
+			want: "example.com/search/index_q=a%5Fb%2520c_x=y%2Fz.html",
+		},
+		{
+			name:    "dot host",
+			url:     "http://../x.html",
+			wantErr: true,
+		},
+		{
+			name: "port in host is sanitized",
+			url:  "http://localhost:8080/page.html",
+			want: "localhost_8080/page.html",
+		},
+		{
+			name: "dot segments cannot escape host directory",
+			url:  "https://example.com/../../etc/passwd.txt",
+			want: "example.com/etc/passwd.txt",
+		},
+		{
+			name:    "relative URL",
+			url:     "/page.html",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PathFor(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PathFor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("PathFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func FuzzPathFor(f *testing.F) {
+	f.Add("https://udn.epicgames.com/Two/WebHome.html", "")
+	f.Add("https://udn.epicgames.com/Two/../../etc/passwd", "a=1")
+	f.Add("https://udn.epicgames.com:8080/Two/", "")
+	f.Add("http://../x.html", "")
+	f.Add("https://example.com/a%2Fb/%2e%2e/c", "")
+	f.Add("https://example.com/search?q=a_b", "q=a&b")
+	f.Add("https://example.com/search?q=a%20b", "q=a b")
+
+	f.Fuzz(func(t *testing.T, rawURL, query string) {
+		got, err := PathFor(rawURL)
+		if err != nil {
+			return
+		}
+
+		// Saved files must stay inside the storage root
+		if got != path.Clean(got) || path.IsAbs(got) || got == ".." || strings.HasPrefix(got, "../") {
+			t.Fatalf("PathFor(%q) = %q, not a clean relative path", rawURL, got)
+		}
+		if path.Ext(got) == "" {
+			t.Errorf("PathFor(%q) = %q has no extension", rawURL, got)
+		}
+
+		// The first element names the host, so URLs of different hosts
+		// never share a file
+		u, _ := url.Parse(rawURL)
+		host, _, _ := strings.Cut(got, "/")
+		if host != sanitize(u.Host) {
+			t.Errorf("PathFor(%q) = %q, want it under host directory %q", rawURL, got, sanitize(u.Host))
+		}
+
+		// The same page with a different query, such as the next page of
+		// a listing, never overwrites it
+		next := *u
+		next.RawQuery = query
+		other, err := url.Parse(next.String())
+		if err != nil || other.RawQuery == u.RawQuery {
+			return
+		}
+		if otherPath, err := PathFor(other.String()); err == nil && otherPath == got {
+			t.Errorf("PathFor(%q) = PathFor(%q) = %q", rawURL, other.String(), got)
+		}
+	})
+}
+
+func TestLocalPathFor(t *testing.T) {
+	tests := []struct {
+		url     string
+		base    string
+		want    string
+		wantErr bool
+	}{
+		{"file:///mnt/dump/udn.example.com/Two/SiteMap.html", "/mnt/dump", "udn.example.com/Two/SiteMap.html", false},
+		{"file:///mnt/dump/udn.example.com/Two", "/mnt/dump/", "udn.example.com/Two/index.html", false},
+		{"file:///mnt/dump/Page.html", "/", "mnt/dump/Page.html", false},
+		{"file:///mnt/dumpster/Page.html", "/mnt/dump", "", true},
+		{"file:///etc/passwd", "/mnt/dump", "", true},
+		{"https://example.com/Page.html", "/mnt/dump", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			got, err := LocalPathFor(tt.url, tt.base)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LocalPathFor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("LocalPathFor() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRelativePath(t *testing.T) {
+	tests := []struct {
+		from   string
+		target string
+		want   string
+	}{
+		{"example.com/a/page.html", "example.com/a/other.html", "other.html"},
+		{"example.com/a/page.html", "example.com/a/b/img.png", "b/img.png"},
+		{"example.com/a/b/page.html", "example.com/a/style.css", "../style.css"},
+		{"example.com/page.html", "cdn.example.com/img.png", "../cdn.example.com/img.png"},
+		{"example.com/search/index.html", "example.com/search/index_q=a%5Fb.html", "index_q=a%255Fb.html"},
+	}
+
+	for _, tt := range tests {
+		if got := RelativePath(tt.from, tt.target); got != tt.want {
+			t.Errorf("RelativePath(%q, %q) = %v, want %v", tt.from, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestStorage_Save(t *testing.T) {
+	s := New(t.TempDir())
+
+	entry, err := s.Save(context.Background(), manifest.Entry{
+		URL:  "https://example.com/a/b/page.html",
+		Path: "example.com/a/b/page.html",
+	}, strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if entry.Size != 5 {
+		t.Errorf("Save() wrote %d bytes, want 5", entry.Size)
+	}
+	// sha256("hello")
+	if entry.SHA256 != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("SHA256 = %s", entry.SHA256)
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.Root(), "example.com", "a", "b", "page.html"))
+	if err != nil {
+		t.Fatalf("reading saved file: %v", err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("saved content = %q, want %q", data, "hello")
+	}
+
+	recorded, ok := s.Manifest().Get("example.com/a/b/page.html")
+	if !ok {
+		t.Fatal("expected saved file in manifest")
+	}
+	if recorded.URL != "https://example.com/a/b/page.html" || recorded.SHA256 != entry.SHA256 {
+		t.Errorf("manifest entry = %+v, want %+v", recorded, entry)
+	}
+}
+
+func TestStorage_Save_Compressed(t *testing.T) {
+	s := New(t.TempDir())
+	ctx := context.Background()
+
+	entry, err := s.Save(ctx, manifest.Entry{
+		Path:     "example.com/page.html",
+		Encoding: manifest.EncodingGzip,
+	}, strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if entry.Size != 5 || entry.SHA256 != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("Save() = %+v, want size and hash of the uncompressed content", entry)
+	}
+	if _, err := os.Stat(s.FullPath("example.com/page.html.gz")); err != nil {
+		t.Errorf("compressed file not saved: %v", err)
+	}
+	if _, err := os.Stat(s.FullPath("example.com/page.html")); !os.IsNotExist(err) {
+		t.Error("uncompressed file saved too")
+	}
+
+	data, err := s.ReadEntry(ctx, entry)
+	if err != nil {
+		t.Fatalf("ReadEntry() error = %v", err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("ReadEntry() = %q, want %q", data, "hello")
+	}
+
+	// Saving uncompressed replaces the compressed copy
+	entry.Encoding = ""
+	if _, err := s.Save(ctx, entry, strings.NewReader("hello")); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if _, err := os.Stat(s.FullPath("example.com/page.html.gz")); !os.IsNotExist(err) {
+		t.Error("compressed copy left behind")
+	}
+}
+
+func TestStorage_SaveOriginal(t *testing.T) {
+	s := New(t.TempDir())
+
+	if err := s.SaveOriginal(context.Background(), "example.com/a/page.html", strings.NewReader("<a href=/b>")); err != nil {
+		t.Fatalf("SaveOriginal() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.Root(), OriginalDir, "example.com", "a", "page.html"))
+	if err != nil {
+		t.Fatalf("reading original: %v", err)
+	}
+	if string(data) != "<a href=/b>" {
+		t.Errorf("original content = %q", data)
+	}
+	if s.Manifest().Len() != 0 {
+		t.Errorf("original recorded in manifest: %v", s.Manifest().Entries())
+	}
+}
+
+func TestStorage_Save_RsyncFriendly(t *testing.T) {
+	s := New(t.TempDir())
+	s.RsyncFriendly(true)
+	modified := time.Date(2004, 3, 15, 12, 0, 0, 0, time.UTC)
+	entry := manifest.Entry{Path: "example.com/page.html", LastModified: modified}
+	full := s.FullPath(entry.Path)
+
+	save := func(content string) os.FileInfo {
+		t.Helper()
+		if _, err := s.Save(context.Background(), entry, strings.NewReader(content)); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		info, err := os.Stat(full)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.ModTime().Equal(modified) {
+			t.Errorf("mtime = %v, want %v", info.ModTime(), modified)
+		}
+		return info
+	}
+
+	first := save("hello")
+	if again := save("hello"); !os.SameFile(first, again) {
+		t.Error("unchanged file was rewritten")
+	}
+	if s.Unchanged() != 1 {
+		t.Errorf("Unchanged() = %d, want 1", s.Unchanged())
+	}
+
+	if changed := save("hello, world"); os.SameFile(first, changed) {
+		t.Error("changed file was not replaced")
+	}
+	if data, _ := os.ReadFile(full); string(data) != "hello, world" {
+		t.Errorf("content = %q, want %q", data, "hello, world")
+	}
+	if s.Unchanged() != 1 {
+		t.Errorf("Unchanged() = %d, want 1", s.Unchanged())
+	}
+}
+
+// failingReader returns some data and then an error, simulating a body
+// that is cut off mid-transfer
+type failingReader struct {
+	sent bool
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if !r.sent {
+		r.sent = true
+		return copy(p, "partial"), nil
+	}
+	return 0, errors.New("connection reset")
+}
+
+func TestStorage_Save_FailedWriteLeavesNoTrace(t *testing.T) {
+	s := New(t.TempDir())
+
+	if _, err := s.Save(context.Background(), manifest.Entry{Path: "example.com/big.png"}, &failingReader{}); err == nil {
+		t.Fatal("expected error from failing reader")
+	}
+
+	if _, ok := s.Manifest().Get("example.com/big.png"); ok {
+		t.Error("half-written file should not be recorded in the manifest")
+	}
+
+	entries, err := os.ReadDir(filepath.Join(s.Root(), "example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected no files after failed save, found %d", len(entries))
+	}
+}
+
+func TestStorage_Save_Concurrent(t *testing.T) {
+	s := New(t.TempDir())
+
+	// Many workers racing to save the same asset must leave one complete,
+	// consistent copy that matches the manifest
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := strings.Repeat(fmt.Sprintf("%02d", i), 50000)
+			if _, err := s.Save(context.Background(), manifest.Entry{Path: "example.com/shared.bin"}, strings.NewReader(body)); err != nil {
+				t.Errorf("Save() error = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(filepath.Join(s.Root(), "example.com", "shared.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 100000 || strings.Count(string(data), string(data[:2])) != 50000 {
+		t.Error("saved file was interleaved from multiple writers")
+	}
+
+	entry, _ := s.Manifest().Get("example.com/shared.bin")
+	sum := sha256.Sum256(data)
+	if entry.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Error("manifest hash does not match the file on disk")
+	}
+
+	if len(s.locks.locks) != 0 {
+		t.Errorf("expected path locks to be released, %d remain", len(s.locks.locks))
+	}
+}
+
+func TestStorage_WriteManifest(t *testing.T) {
+	s := New(filepath.Join(t.TempDir(), "out"))
+
+	s.Save(context.Background(), manifest.Entry{Path: "example.com/a.html"}, strings.NewReader("a"))
+	if err := s.WriteManifest(); err != nil {
+		t.Fatalf("WriteManifest() error = %v", err)
+	}
+
+	m, err := manifest.Load(filepath.Join(s.Root(), manifest.Filename))
+	if err != nil {
+		t.Fatalf("manifest.Load() error = %v", err)
+	}
+	if m.Len() != 1 {
+		t.Errorf("loaded manifest has %d entries, want 1", m.Len())
+	}
+}
+
+func TestOpen(t *testing.T) {
+	root := t.TempDir()
+
+	s, err := Open(root)
+	if err != nil {
+		t.Fatalf("Open() on empty directory error = %v", err)
+	}
+	if s.Manifest().Len() != 0 {
+		t.Errorf("new mirror manifest has %d entries", s.Manifest().Len())
+	}
+
+	s.Save(context.Background(), manifest.Entry{Path: "example.com/a.html"}, strings.NewReader("a"))
+	if err := s.WriteManifest(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = Open(root)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if _, ok := s.Manifest().Get("example.com/a.html"); !ok {
+		t.Error("Open() did not load the existing manifest")
+	}
+}
+
+func TestStorage_Remove(t *testing.T) {
+	s := New(t.TempDir())
+	s.Save(context.Background(), manifest.Entry{Path: "example.com/a.png"}, strings.NewReader("a"))
+
+	if err := s.Remove("example.com/a.png"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, err := os.Stat(s.FullPath("example.com/a.png")); !os.IsNotExist(err) {
+		t.Error("file still exists after Remove()")
+	}
+	if s.Manifest().Len() != 0 {
+		t.Error("manifest entry still exists after Remove()")
+	}
+
+	if err := s.Remove("example.com/missing.png"); err != nil {
+		t.Errorf("Remove() of missing file error = %v", err)
+	}
+}
+
+func TestValidVersion(t *testing.T) {
+	for _, version := range []string{"two", "ut2004", "UDK-3"} {
+		if err := ValidVersion(version); err != nil {
+			t.Errorf("ValidVersion(%q) error = %v", version, err)
+		}
+	}
+	for _, version := range []string{"", ".", "..", ".original", "two/three", `a\b`} {
+		if err := ValidVersion(version); err == nil {
+			t.Errorf("ValidVersion(%q) should fail", version)
+		}
+	}
+}
+
+func TestStorage_WriteVersionIndex(t *testing.T) {
+	s := New(t.TempDir())
+
+	if err := s.WriteVersionIndex(); err != nil {
+		t.Fatalf("WriteVersionIndex() error = %v", err)
+	}
+	if _, err := os.Stat(s.FullPath(VersionIndex)); !os.IsNotExist(err) {
+		t.Error("version index written for a mirror without versions")
+	}
+
+	s.Manifest().SetVersionRoot("two", "two/udn.epicgames.com/Two/SiteMap.html")
+	s.Manifest().SetVersionRoot("three", "three/udn.epicgames.com/Three/SiteMap.html")
+	if err := s.WriteVersionIndex(); err != nil {
+		t.Fatalf("WriteVersionIndex() error = %v", err)
+	}
+
+	data, err := os.ReadFile(s.FullPath(VersionIndex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := string(data)
+	three := strings.Index(page, `<a href="three/udn.epicgames.com/Three/SiteMap.html">three</a>`)
+	two := strings.Index(page, `<a href="two/udn.epicgames.com/Two/SiteMap.html">two</a>`)
+	if three < 0 || two < 0 || three > two {
+		t.Errorf("version index should link each version in order:\n%s", page)
+	}
+}
+
+func TestStorage_WriteManifestSigned(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(nil)
+	key := &minisign.SecretKey{Key: priv}
+
+	s := New(t.TempDir())
+	s.SignWith(key)
+	s.Save(context.Background(), manifest.Entry{Path: "example.com/a.html"}, strings.NewReader("a"))
+	if err := s.WriteManifest(); err != nil {
+		t.Fatalf("WriteManifest() error = %v", err)
+	}
+
+	data, _ := os.ReadFile(s.FullPath(manifest.Filename))
+	sig, err := os.ReadFile(s.FullPath(manifest.Filename + minisign.Extension))
+	if err != nil {
+		t.Fatalf("reading signature: %v", err)
+	}
+	if _, err := key.Public().Verify(data, sig); err != nil {
+		t.Errorf("manifest signature does not verify: %v", err)
+	}
+}
+
+func TestStorage_SaveCancelled(t *testing.T) {
+	s := New(t.TempDir())
+	s.Save(context.Background(), manifest.Entry{Path: "example.com/a.html"}, strings.NewReader("old"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.Save(ctx, manifest.Entry{Path: "example.com/a.html"}, strings.NewReader("new")); !errors.Is(err, context.Canceled) {
+		t.Errorf("Save() error = %v, want context.Canceled", err)
+	}
+
+	data, _ := os.ReadFile(s.FullPath("example.com/a.html"))
+	if string(data) != "old" {
+		t.Errorf("cancelled Save() replaced the file with %q", data)
+	}
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+)
+
+// VersionIndex is the path of the page linking to every documentation
+// version in a mirror
+const VersionIndex = "index.html"
+
+// ValidVersion checks that a documentation version name can be used as the
+// top-level directory of its files
+func ValidVersion(version string) error {
+	if version == "" || strings.HasPrefix(version, ".") || strings.ContainsAny(version, `/\:`) {
+		return fmt.Errorf("invalid version name %q: must be a single directory name not starting with a dot", version)
+	}
+	return nil
+}
+
+// WriteVersionIndex writes a page to the storage root linking to the root
+// page of each documentation version recorded in the manifest. Nothing is
+// written for mirrors without versions
+func (s *Storage) WriteVersionIndex() error {
+	if s.compare {
+		return nil
+	}
+	roots := s.manifest.VersionRoots()
+	if len(roots) == 0 {
+		return nil
+	}
+
+	versions := make([]string, 0, len(roots))
+	for version := range roots {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+
+	var sb strings.Builder
+	sb.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>Documentation Versions</title>\n</head>\n<body>\n")
+	sb.WriteString("<h1>Documentation Versions</h1>\n<ul>\n")
+	for _, version := range versions {
+		fmt.Fprintf(&sb, "<li><a href=\"%s\">%s</a></li>\n",
+			html.EscapeString(RelativePath(VersionIndex, roots[version])), html.EscapeString(version))
+	}
+	sb.WriteString("</ul>\n</body>\n</html>\n")
+
+	if _, _, err := s.write(context.Background(), VersionIndex, "", strings.NewReader(sb.String()), time.Time{}); err != nil {
+		return fmt.Errorf("writing version index: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+// Package torrent creates BitTorrent metainfo files for packaged mirrors,
+// so the community can distribute multi-gigabyte archives peer to peer,
+// seeded from plain web servers where they are hosted
+package torrent
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Piece lengths chosen by PieceLength, a power of two in this range
+const (
+	MinPieceLength = 256 << 10
+	MaxPieceLength = 16 << 20
+)
+
+// targetPieces is the number of pieces PieceLength aims for, keeping
+// metainfo files small while letting peers share early
+const targetPieces = 1500
+
+// Config holds metainfo configuration
+type Config struct {
+	Trackers []string // Announce URLs, the first being the primary
+	WebSeeds []string // URLs the file is served from over HTTP (BEP 19)
+	Comment  string
+
+	// PieceLength is the size of the pieces the file is hashed in;
+	// defaults to PieceLength of the file's size
+	PieceLength int64
+}
+
+// Torrent is the metainfo of a single file
+type Torrent struct {
+	data     []byte
+	infoHash [sha1.Size]byte
+	name     string
+	trackers []string
+}
+
+// PieceLength returns the piece length for a file of size bytes: the
+// smallest power of two from MinPieceLength to MaxPieceLength giving at
+// most targetPieces pieces
+func PieceLength(size int64) int64 {
+	length := int64(MinPieceLength)
+	for length < MaxPieceLength && size/length > targetPieces {
+		length *= 2
+	}
+	return length
+}
+
+// Create hashes the file at path into a torrent. The metainfo carries no
+// creation date, so the same file and configuration give the same bytes
+func Create(path string, config Config) (*Torrent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	pieceLength := config.PieceLength
+	if pieceLength <= 0 {
+		pieceLength = PieceLength(info.Size())
+	}
+
+	var pieces []byte
+	buf := make([]byte, pieceLength)
+	for {
+		n, err := io.ReadFull(f, buf)
+		if n > 0 {
+			sum := sha1.Sum(buf[:n])
+			pieces = append(pieces, sum[:]...)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("hashing %s: %w", path, err)
+		}
+	}
+
+	t := &Torrent{name: filepath.Base(path), trackers: config.Trackers}
+	infoDict := map[string]any{
+		"length":       info.Size(),
+		"name":         t.name,
+		"piece length": pieceLength,
+		"pieces":       pieces,
+	}
+	encodedInfo := encode(nil, infoDict)
+	t.infoHash = sha1.Sum(encodedInfo)
+
+	metainfo := map[string]any{
+		"info":       rawValue(encodedInfo),
+		"created by": "ue2-docs",
+	}
+	if len(config.Trackers) > 0 {
+		metainfo["announce"] = config.Trackers[0]
+		// Each tracker gets its own tier, tried in order
+		var tiers []any
+		for _, tracker := range config.Trackers {
+			tiers = append(tiers, []any{tracker})
+		}
+		metainfo["announce-list"] = tiers
+	}
+	if len(config.WebSeeds) > 0 {
+		var seeds []any
+		for _, seed := range config.WebSeeds {
+			seeds = append(seeds, seed)
+		}
+		metainfo["url-list"] = seeds
+	}
+	if config.Comment != "" {
+		metainfo["comment"] = config.Comment
+	}
+	t.data = encode(nil, metainfo)
+	return t, nil
+}
+
+// Bytes returns the encoded metainfo, the contents of a .torrent file
+func (t *Torrent) Bytes() []byte {
+	return t.data
+}
+
+// InfoHash returns the hex-encoded SHA-1 hash identifying the torrent
+func (t *Torrent) InfoHash() string {
+	return hex.EncodeToString(t.infoHash[:])
+}
+
+// Magnet returns a magnet link to the torrent
+func (t *Torrent) Magnet() string {
+	values := url.Values{}
+	values.Set("dn", t.name)
+	for _, tracker := range t.trackers {
+		values.Add("tr", tracker)
+	}
+	return "magnet:?xt=urn:btih:" + t.InfoHash() + "&" + values.Encode()
+}
+
+// WriteFile writes the metainfo to a .torrent file
+func (t *Torrent) WriteFile(path string) error {
+	if err := os.WriteFile(path, t.data, 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
+// rawValue is an already bencoded value
+type rawValue []byte
+
+// encode appends the bencoding of v, which is a string, byte slice,
+// integer, list or dictionary of those
+func encode(b []byte, v any) []byte {
+	switch v := v.(type) {
+	case rawValue:
+		return append(b, v...)
+	case string:
+		return encode(b, []byte(v))
+	case []byte:
+		b = fmt.Appendf(b, "%d:", len(v))
+		return append(b, v...)
+	case int:
+		return fmt.Appendf(b, "i%de", v)
+	case int64:
+		return fmt.Appendf(b, "i%de", v)
+	case []any:
+		b = append(b, 'l')
+		for _, item := range v {
+			b = encode(b, item)
+		}
+		return append(b, 'e')
+	case map[string]any:
+		// Keys are sorted as raw byte strings, as Go compares strings
+		b = append(b, 'd')
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			b = encode(b, key)
+			b = encode(b, v[key])
+		}
+		return append(b, 'e')
+	}
+	panic(fmt.Sprintf("torrent: cannot bencode %T", v))
+}
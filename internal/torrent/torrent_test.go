@@ -0,0 +1,88 @@
+package torrent
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{"spam", "4:spam"},
+		{[]byte{}, "0:"},
+		{42, "i42e"},
+		{int64(-3), "i-3e"},
+		{[]any{"spam", "eggs"}, "l4:spam4:eggse"},
+		{map[string]any{"spam": []any{"a", "b"}, "cow": "moo"}, "d3:cow3:moo4:spaml1:a1:bee"},
+	}
+	for _, tt := range tests {
+		if got := string(encode(nil, tt.value)); got != tt.want {
+			t.Errorf("encode(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestPieceLength(t *testing.T) {
+	tests := []struct {
+		size int64
+		want int64
+	}{
+		{0, MinPieceLength},
+		{100 << 20, MinPieceLength},
+		{1 << 30, 1 << 20},
+		{1 << 40, MaxPieceLength},
+	}
+	for _, tt := range tests {
+		if got := PieceLength(tt.size); got != tt.want {
+			t.Errorf("PieceLength(%d) = %d, want %d", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestCreate(t *testing.T) {
+	data := bytes.Repeat([]byte("ue2-docs "), 100)
+	path := filepath.Join(t.TempDir(), "mirror.tar.gz")
+	os.WriteFile(path, data, 0644)
+
+	config := Config{
+		Trackers:    []string{"udp://tracker.example:1337/announce", "https://tracker.example/announce"},
+		WebSeeds:    []string{"https://mirror.example/mirror.tar.gz"},
+		PieceLength: 512,
+	}
+	tor, err := Create(path, config)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	first, second := sha1.Sum(data[:512]), sha1.Sum(data[512:])
+	info := "d6:lengthi900e4:name13:mirror.tar.gz12:piece lengthi512e6:pieces40:" +
+		string(first[:]) + string(second[:]) + "e"
+	want := "d8:announce35:udp://tracker.example:1337/announce" +
+		"13:announce-listll35:udp://tracker.example:1337/announceel32:https://tracker.example/announceee" +
+		"10:created by8:ue2-docs" +
+		"4:info" + info +
+		"8:url-listl36:https://mirror.example/mirror.tar.gzee"
+	if got := string(tor.Bytes()); got != want {
+		t.Errorf("Bytes() = %q, want %q", got, want)
+	}
+
+	infoHash := sha1.Sum([]byte(info))
+	if got, want := tor.InfoHash(), hex.EncodeToString(infoHash[:]); got != want {
+		t.Errorf("InfoHash() = %s, want %s", got, want)
+	}
+	if magnet := tor.Magnet(); !strings.HasPrefix(magnet, "magnet:?xt=urn:btih:"+tor.InfoHash()+"&dn=mirror.tar.gz&tr=") {
+		t.Errorf("Magnet() = %s", magnet)
+	}
+
+	again, _ := Create(path, config)
+	if !bytes.Equal(again.Bytes(), tor.Bytes()) {
+		t.Error("Create() is not reproducible")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/url"
 	"path"
 	"strings"
+	"sync"
 )
 
 // ResourceType represents the type of a web resource
@@ -40,14 +41,36 @@ func (rt ResourceType) String() string {
 	}
 }
 
+// ParseResourceType parses a resource type by its String name, or by its
+// short name: html, css, js, image, font, other or unknown. Case is ignored
+func ParseResourceType(name string) (ResourceType, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "js" {
+		return ResourceJS, nil
+	}
+	for rt := ResourceUnknown; rt <= ResourceOther; rt++ {
+		if strings.ToLower(rt.String()) == name {
+			return rt, nil
+		}
+	}
+	return ResourceUnknown, fmt.Errorf("unknown resource type %q", name)
+}
+
 // Filter handles URL filtering and resource type detection
 type Filter struct {
 	rootDomain string
 	rootPath   string
-	whitelist  map[string]bool
+
+	mu        sync.RWMutex // Guards whitelist, which Allow extends mid-crawl
+	whitelist map[string]bool
+	dirs      []string        // Whitelisted directories of file URLs
+	schemes   map[string]bool // Asset schemes allowed by AllowSchemes
+	policy    SchemePolicy
 }
 
-// NewFilter creates a new URL filter with the given root URL and domain whitelist
+// NewFilter creates a new URL filter with the given root URL and domain
+// whitelist. Whitelist entries starting with a slash are directories, which
+// file URLs may be fetched from once the file scheme is allowed
 func NewFilter(rootURL string, whitelistDomains []string) *Filter {
 	u, err := url.Parse(rootURL)
 	if err != nil {
@@ -67,7 +90,12 @@ func NewFilter(rootURL string, whitelistDomains []string) *Filter {
 
 	// Create whitelist map
 	whitelist := make(map[string]bool)
+	var dirs []string
 	for _, domain := range whitelistDomains {
+		if strings.HasPrefix(domain, "/") {
+			dirs = append(dirs, path.Clean(domain))
+			continue
+		}
 		whitelist[strings.ToLower(domain)] = true
 	}
 
@@ -75,6 +103,7 @@ func NewFilter(rootURL string, whitelistDomains []string) *Filter {
 		rootDomain: strings.ToLower(u.Host),
 		rootPath:   rootPath,
 		whitelist:  whitelist,
+		dirs:       dirs,
 	}
 }
 
@@ -91,44 +120,151 @@ func (f *Filter) IsAllowed(rawURL string) (bool, error) {
 
 	domain := strings.ToLower(u.Host)
 
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if !f.policy.Allows(u.Scheme) {
+		return false, nil
+	}
+
+	// FTP servers only ever provide assets, from whitelisted hosts
+	if u.Scheme == "ftp" {
+		return f.schemes["ftp"] && f.whitelist[domain], nil
+	}
+
 	// Check if it's the root domain
 	if domain == f.rootDomain {
-		// Check if the path has the same prefix as root path
-		return strings.HasPrefix(u.Path, f.rootPath), nil
+		// Check if the path, as the server resolves its dot segments, lies
+		// within the root path's directory
+		p := path.Clean("/" + u.Path)
+		if within(p, f.rootPath) {
+			return true, nil
+		}
+		return u.Scheme == "file" && f.schemes["file"] && f.fileWhitelisted(p), nil
 	}
 
 	// Check if it's in the whitelist
-	if f.whitelist[domain] {
-		return true, nil
+	return f.whitelist[domain], nil
+}
+
+// fileWhitelisted reports whether a file path lies within a whitelisted
+// directory
+func (f *Filter) fileWhitelisted(p string) bool {
+	for _, dir := range f.dirs {
+		if within(p, dir) {
+			return true
+		}
 	}
+	return false
+}
 
-	return false, nil
+// within reports whether the clean path p is dir or lies beneath it
+func within(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// Allow adds a domain to the whitelist
+func (f *Filter) Allow(domain string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.whitelist[strings.ToLower(domain)] = true
+}
+
+// SetSchemePolicy sets which of http and https URLs are allowed
+func (f *Filter) SetSchemePolicy(p SchemePolicy) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.policy = p
+}
+
+// AssetSchemes are the schemes AllowSchemes accepts, fetched for the assets
+// of pages besides http and https
+var AssetSchemes = []string{"ftp", "file"}
+
+// AllowSchemes lets URLs of the given asset schemes through: ftp URLs of
+// whitelisted hosts, and file URLs in whitelisted directories when
+// crawling from a file:// root
+func (f *Filter) AllowSchemes(schemes ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, scheme := range schemes {
+		scheme = strings.ToLower(scheme)
+		ok := false
+		for _, s := range AssetSchemes {
+			ok = ok || s == scheme
+		}
+		if !ok {
+			return fmt.Errorf("unsupported scheme %q (want %s)", scheme, strings.Join(AssetSchemes, " or "))
+		}
+		if f.schemes == nil {
+			f.schemes = make(map[string]bool)
+		}
+		f.schemes[scheme] = true
+	}
+	return nil
 }
 
 // DetectResourceType determines the resource type based on URL and content type
 // This is a standalone function that can be used without a Filter instance
 func DetectResourceType(rawURL, contentType string) ResourceType {
 	// First try to determine by Content-Type header if provided
-	if contentType != "" {
-		ct := strings.ToLower(strings.Split(contentType, ";")[0])
-		ct = strings.TrimSpace(ct)
-
-		switch {
-		case strings.Contains(ct, "text/html"):
-			return ResourceHTML
-		case strings.Contains(ct, "text/css"):
-			return ResourceCSS
-		case strings.Contains(ct, "javascript"), strings.Contains(ct, "application/javascript"),
-			strings.Contains(ct, "application/x-javascript"), strings.Contains(ct, "text/javascript"):
-			return ResourceJS
-		case strings.HasPrefix(ct, "image/"):
-			return ResourceImage
-		case strings.Contains(ct, "font"), strings.Contains(ct, "woff"), strings.Contains(ct, "ttf"):
-			return ResourceFont
-		}
+	if rt, ok := contentTypeResource(contentType); ok {
+		return rt
 	}
 
 	// Fall back to extension-based detection
+	return extensionResource(rawURL)
+}
+
+// ContentTypeMismatch reports whether a Content-Type contradicts the
+// extension of a URL, such as a .gif served as a text/html error page.
+// Extensionless URLs and unrecognized types never mismatch
+func ContentTypeMismatch(rawURL, contentType string) bool {
+	byHeader, ok := contentTypeResource(contentType)
+	if !ok {
+		return false
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || path.Ext(u.Path) == "" {
+		return false
+	}
+
+	switch byExt := extensionResource(rawURL); byExt {
+	case ResourceOther, ResourceUnknown:
+		return false
+	default:
+		return byExt != byHeader
+	}
+}
+
+// contentTypeResource maps a Content-Type header to a resource type
+// Returns false if the type is empty or not recognized
+func contentTypeResource(contentType string) (ResourceType, bool) {
+	ct := strings.ToLower(strings.Split(contentType, ";")[0])
+	ct = strings.TrimSpace(ct)
+
+	switch {
+	case ct == "":
+		return ResourceUnknown, false
+	case strings.Contains(ct, "text/html"):
+		return ResourceHTML, true
+	case strings.Contains(ct, "text/css"):
+		return ResourceCSS, true
+	case strings.Contains(ct, "javascript"), strings.Contains(ct, "application/javascript"),
+		strings.Contains(ct, "application/x-javascript"), strings.Contains(ct, "text/javascript"):
+		return ResourceJS, true
+	case strings.HasPrefix(ct, "image/"):
+		return ResourceImage, true
+	case strings.Contains(ct, "font"), strings.Contains(ct, "woff"), strings.Contains(ct, "ttf"):
+		return ResourceFont, true
+	}
+
+	return ResourceUnknown, false
+}
+
+// extensionResource determines a resource type from the extension of a URL path
+func extensionResource(rawURL string) ResourceType {
 	u, err := url.Parse(rawURL)
 	if err != nil {
 		return ResourceOther
@@ -1,6 +1,9 @@
 package urlutil
 
 import (
+	"net/url"
+	"path"
+	"strings"
 	"testing"
 )
 
@@ -27,6 +30,16 @@ func TestFilter_IsAllowed(t *testing.T) {
 			url:  "https://docs.unrealengine.com/udk/Three/index.html",
 			want: false,
 		},
+		{
+			name: "dot segments leading out of the root path",
+			url:  "https://docs.unrealengine.com/udk/Two/../Three/index.html",
+			want: false,
+		},
+		{
+			name: "sibling directory sharing the root path's prefix",
+			url:  "https://docs.unrealengine.com/udk/TwoExtra/index.html",
+			want: false,
+		},
 		{
 			name: "whitelisted CDN domain",
 			url:  "https://cdn.example.com/assets/image.png",
@@ -79,6 +92,39 @@ func TestFilter_IsAllowed(t *testing.T) {
 	}
 }
 
+func TestFilter_AllowSchemes(t *testing.T) {
+	filter := NewFilter("file:///mnt/dump/udn.example.com/Two/SiteMap.html", []string{"ftp.example.com", "/mnt/dump/files"})
+
+	tests := []struct {
+		url           string
+		before, after bool
+	}{
+		{"file:///mnt/dump/udn.example.com/Two/Page.html", true, true},
+		{"file:///mnt/dump/files/Tools.zip", false, true},
+		{"file:///mnt/dump/files/../secret.txt", false, false},
+		{"file:///etc/passwd", false, false},
+		{"ftp://ftp.example.com/pub/Tools.zip", false, true},
+		{"ftp://other.example.com/pub/Tools.zip", false, false},
+	}
+	check := func(allowed bool, which func(before, after bool) bool) {
+		for _, tt := range tests {
+			if got, _ := filter.IsAllowed(tt.url); got != which(tt.before, tt.after) {
+				t.Errorf("IsAllowed(%q) = %v with schemes allowed %v", tt.url, got, allowed)
+			}
+		}
+	}
+
+	check(false, func(before, _ bool) bool { return before })
+	if err := filter.AllowSchemes("ftp", "file"); err != nil {
+		t.Fatalf("AllowSchemes() error = %v", err)
+	}
+	check(true, func(_, after bool) bool { return after })
+
+	if err := filter.AllowSchemes("gopher"); err == nil {
+		t.Error("AllowSchemes(\"gopher\") expected error")
+	}
+}
+
 func TestFilter_GetResourceType(t *testing.T) {
 	filter := NewFilter("https://example.com/", nil)
 
@@ -478,3 +524,84 @@ func TestResourceType_String(t *testing.T) {
 		})
 	}
 }
+
+func TestContentTypeMismatch(t *testing.T) {
+	tests := []struct {
+		url         string
+		contentType string
+		want        bool
+	}{
+		{"https://example.com/logo.gif", "text/html; charset=utf-8", true},
+		{"https://example.com/style.css", "text/html", true},
+		{"https://example.com/Page.html", "image/png", true},
+		{"https://example.com/logo.gif", "image/gif", false},
+		{"https://example.com/logo.GIF?v=2", "image/gif", false},
+		{"https://example.com/style.css", "text/plain", false},
+		{"https://example.com/style.css", "", false},
+		{"https://example.com/docs/", "text/html", false},
+		{"https://example.com/docs/Page", "image/png", false},
+		{"https://example.com/manual.pdf", "text/html", false},
+		{"https://example.com/data.xyz", "text/html", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url+" "+tt.contentType, func(t *testing.T) {
+			if got := ContentTypeMismatch(tt.url, tt.contentType); got != tt.want {
+				t.Errorf("ContentTypeMismatch(%q, %q) = %v, want %v", tt.url, tt.contentType, got, tt.want)
+			}
+		})
+	}
+}
+
+func FuzzFilter_IsAllowed(f *testing.F) {
+	f.Add("https://udn.epicgames.com/Two/WebHome.html")
+	f.Add("https://udn.epicgames.com/Two/../Three/WebHome.html")
+	f.Add("https://UDN.epicgames.com/TwoExtra/Page.html")
+	f.Add("https://cdn.example.com/image.png")
+	f.Add("mailto:someone@example.com")
+
+	filter := NewFilter("https://udn.epicgames.com/Two/SiteMap.html", []string{"cdn.example.com"})
+	f.Fuzz(func(t *testing.T, rawURL string) {
+		allowed, err := filter.IsAllowed(rawURL)
+		if err != nil || !allowed {
+			return
+		}
+
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatalf("IsAllowed(%q) allowed an unparseable URL", rawURL)
+		}
+		if strings.ToLower(u.Host) == "cdn.example.com" {
+			return
+		}
+		// Everything else allowed lies within the root's directory once
+		// resolved, as the server will see it
+		if p := path.Clean("/" + u.Path); p != "/Two" && !strings.HasPrefix(p, "/Two/") {
+			t.Errorf("IsAllowed(%q) allowed path %q outside /Two", rawURL, p)
+		}
+	})
+}
+
+func TestParseResourceType(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    ResourceType
+		wantErr bool
+	}{
+		{"html", ResourceHTML, false},
+		{"CSS", ResourceCSS, false},
+		{"js", ResourceJS, false},
+		{"JavaScript", ResourceJS, false},
+		{" image ", ResourceImage, false},
+		{"font", ResourceFont, false},
+		{"other", ResourceOther, false},
+		{"unknown", ResourceUnknown, false},
+		{"video", ResourceUnknown, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseResourceType(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseResourceType(%q) = %v, %v; want %v, error %t", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -58,10 +58,28 @@ func Normalize(rawURL, baseURL string) (string, error) {
 	u.RawQuery = ""
 	u.ForceQuery = false
 
-	// Remove trailing slash from path (but not for root)
+	// Remove trailing slashes from path (but not for root)
 	if u.Path != "/" && strings.HasSuffix(u.Path, "/") {
-		u.Path = strings.TrimSuffix(u.Path, "/")
+		u.Path = strings.TrimRight(u.Path, "/")
+		if u.Path == "" {
+			u.Path = "/"
+		}
+	}
+
+	// Hosts Go parses leniently, like "http://:A:80", may not survive
+	// having their port removed
+	normalized := u.String()
+	if _, err := url.Parse(normalized); err != nil {
+		return "", fmt.Errorf("URL %q has an invalid host: %w", rawURL, err)
 	}
 
-	return u.String(), nil
+	return normalized, nil
+}
+
+// StripFragment removes the fragment (#anchor) from a URL, if present
+func StripFragment(rawURL string) string {
+	if i := strings.Index(rawURL, "#"); i >= 0 {
+		return rawURL[:i]
+	}
+	return rawURL
 }
@@ -1,6 +1,7 @@
 package urlutil
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -132,3 +133,46 @@ func TestNormalizeWithoutBase(t *testing.T) {
 		})
 	}
 }
+
+func BenchmarkNormalize(b *testing.B) {
+	inputs := []struct{ input, base string }{
+		{"https://UDN.EpicGames.com:443/Two/WebHome?skin=print#Top", ""},
+		{"../Engine/Actor.html", "https://udn.epicgames.com/Two/Script/UnrealScriptReference.html"},
+		{"/Two/a%2fb/./c/../Page.html", "https://udn.epicgames.com/Two/WebHome.html"},
+		{"#Functions", "https://udn.epicgames.com/Two/UnrealScriptReference.html"},
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		in := inputs[i%len(inputs)]
+		Normalize(in.input, in.base)
+	}
+}
+
+func FuzzNormalize(f *testing.F) {
+	f.Add("https://UDN.EpicGames.com:443/Two/WebHome?skin=print#Top", "")
+	f.Add("../Engine/Actor.html", "https://udn.epicgames.com/Two/Script/Page.html")
+	f.Add("//example.com/a/./b/../c/", "http://example.com/")
+	f.Add("?q=1#frag", "http://example.com/dir/")
+	f.Add("http://[::1]:80/%2e%2e/x", "")
+
+	f.Fuzz(func(t *testing.T, input, base string) {
+		got, err := Normalize(input, base)
+		if err != nil {
+			return
+		}
+		if strings.Contains(StripFragment(got), "?") {
+			t.Errorf("Normalize(%q, %q) = %q keeps a query", input, base, got)
+		}
+
+		// Normalized URLs are the dedup keys of the crawl, so normalizing
+		// one again must not change it
+		again, err := Normalize(got, "")
+		if err != nil {
+			t.Fatalf("Normalize(%q) of normalized URL error = %v", got, err)
+		}
+		if again != got {
+			t.Errorf("Normalize(%q, %q) = %q, but normalizing that gives %q", input, base, got, again)
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package urlutil
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+)
+
+// DefaultTrackingParams lists the query parameters Pagination drops by
+// default: analytics tags and session IDs, which make one page look like
+// many
+var DefaultTrackingParams = []string{"utm_*", "sessionid"}
+
+// Pagination keeps the query parameters that page through listings, such
+// as TWiki's search results and topic indexes, on the URLs of those
+// listings. Crawls drop query strings from every other URL, which would
+// leave only the first page of each listing
+type Pagination struct {
+	rules    []paginationRule
+	tracking []string // Patterns of parameter names never kept
+}
+
+// paginationRule keeps params on the URLs matcher matches, or every
+// parameter if params holds "*"
+type paginationRule struct {
+	matcher *Matcher
+	params  []string
+}
+
+// NewPagination parses pagination rules. Each is a URL or glob pattern, as
+// NewMatcher takes them, followed by '?' and the parameters to keep,
+// separated by '&', as in "https://example.com/bin/search/*?start&limit".
+// A parameter of "*" keeps all of them but the tracking parameters
+func NewPagination(rules []string) (*Pagination, error) {
+	p := &Pagination{tracking: DefaultTrackingParams}
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+
+		i := strings.LastIndex(rule, "?")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid pagination rule %q: want pattern?param[&param...]", rule)
+		}
+		var params []string
+		for _, param := range strings.Split(rule[i+1:], "&") {
+			if param = strings.TrimSpace(param); param != "" {
+				params = append(params, param)
+			}
+		}
+		if len(params) == 0 {
+			return nil, fmt.Errorf("invalid pagination rule %q: no parameters", rule)
+		}
+
+		matcher, err := NewMatcher([]string{rule[:i]})
+		if err != nil {
+			return nil, fmt.Errorf("invalid pagination rule %q: %w", rule, err)
+		}
+		p.rules = append(p.rules, paginationRule{matcher: matcher, params: params})
+	}
+	return p, nil
+}
+
+// SetTrackingParams replaces DefaultTrackingParams as the parameters
+// dropped even by rules keeping every parameter, given by name or as glob
+// patterns such as "utm_*", matched regardless of case
+func (p *Pagination) SetTrackingParams(patterns []string) {
+	p.tracking = patterns
+}
+
+// Apply returns rawURL, a normalized URL without a query, with the
+// parameters of query that the first rule matching it keeps, in canonical
+// order. URLs no rule matches are returned as they are
+func (p *Pagination) Apply(rawURL, query string) string {
+	if p == nil || query == "" {
+		return rawURL
+	}
+	for _, rule := range p.rules {
+		if !rule.matcher.Match(rawURL) {
+			continue
+		}
+		return CanonicalQuery(rawURL, query, func(param string) bool {
+			if slices.Contains(rule.params, param) {
+				return true
+			}
+			return slices.Contains(rule.params, "*") && !p.isTracking(param)
+		})
+	}
+	return rawURL
+}
+
+// isTracking reports whether a parameter is a tracking parameter
+func (p *Pagination) isTracking(param string) bool {
+	param = strings.ToLower(param)
+	for _, pattern := range p.tracking {
+		if ok, _ := path.Match(strings.ToLower(pattern), param); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of rules
+func (p *Pagination) Len() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.rules)
+}
+
+// CanonicalQuery returns rawURL, a URL without a query, with the
+// parameters of query that keep accepts, sorted by name and encoded alike
+// so that equivalent queries give the same URL however a page writes them.
+// Returns rawURL unchanged if keep accepts none of them
+func CanonicalQuery(rawURL, query string, keep func(param string) bool) string {
+	values, _ := url.ParseQuery(query)
+	kept := make(url.Values)
+	for param, v := range values {
+		if keep(param) {
+			kept[param] = v
+		}
+	}
+	if len(kept) == 0 {
+		return rawURL
+	}
+	return rawURL + "?" + kept.Encode()
+}
@@ -0,0 +1,67 @@
+package urlutil
+
+import "testing"
+
+func TestPagination_Apply(t *testing.T) {
+	p, err := NewPagination([]string{
+		"https://udn.epicgames.com/bin/search/*?start&limit",
+		"https://udn.epicgames.com/Two/WebIndex.html?page",
+		"",
+	})
+	if err != nil {
+		t.Fatalf("NewPagination() error = %v", err)
+	}
+	if p.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", p.Len())
+	}
+
+	tests := []struct {
+		name  string
+		url   string
+		query string
+		want  string
+	}{
+		{"kept parameters sorted", "https://udn.epicgames.com/bin/search/Two", "scope=text&start=20&limit=10", "https://udn.epicgames.com/bin/search/Two?limit=10&start=20"},
+		{"exact rule", "https://udn.epicgames.com/Two/WebIndex.html", "page=3&skin=print", "https://udn.epicgames.com/Two/WebIndex.html?page=3"},
+		{"no kept parameter", "https://udn.epicgames.com/bin/search/Two", "scope=text", "https://udn.epicgames.com/bin/search/Two"},
+		{"no matching rule", "https://udn.epicgames.com/Two/WebHome.html", "start=20", "https://udn.epicgames.com/Two/WebHome.html"},
+		{"no query", "https://udn.epicgames.com/bin/search/Two", "", "https://udn.epicgames.com/bin/search/Two"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Apply(tt.url, tt.query); got != tt.want {
+				t.Errorf("Apply(%q, %q) = %q, want %q", tt.url, tt.query, got, tt.want)
+			}
+		})
+	}
+
+	for _, rule := range []string{"https://udn.epicgames.com/bin/search/*", "https://udn.epicgames.com/bin/search/*?"} {
+		if _, err := NewPagination([]string{rule}); err == nil {
+			t.Errorf("NewPagination(%q) expected error", rule)
+		}
+	}
+}
+
+func TestPagination_KeepAll(t *testing.T) {
+	p, err := NewPagination([]string{"https://udn.epicgames.com/bin/search/*?*"})
+	if err != nil {
+		t.Fatalf("NewPagination() error = %v", err)
+	}
+
+	const base = "https://udn.epicgames.com/bin/search/Two"
+	want := base + "?scope=text&start=20"
+	for _, query := range []string{
+		"start=20&scope=text",
+		"scope=text&utm_source=forum&start=20",
+		"SessionID=abc&start=20&scope=text&UTM_Medium=email",
+	} {
+		if got := p.Apply(base, query); got != want {
+			t.Errorf("Apply(%q) = %q, want %q", query, got, want)
+		}
+	}
+
+	p.SetTrackingParams([]string{"scope"})
+	if got := p.Apply(base, "start=20&scope=text&utm_source=forum"); got != base+"?start=20&utm_source=forum" {
+		t.Errorf("Apply() with custom tracking parameters = %q", got)
+	}
+}
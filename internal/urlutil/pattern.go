@@ -0,0 +1,102 @@
+package urlutil
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Matcher matches URLs against a list of exact URLs and glob patterns
+// A '*' in a pattern matches any sequence of characters, including '/'
+type Matcher struct {
+	exact    map[string]bool
+	patterns []*regexp.Regexp
+}
+
+// NewMatcher creates a matcher from the given patterns
+// Patterns without a '*' are normalized and matched exactly
+func NewMatcher(patterns []string) (*Matcher, error) {
+	m := &Matcher{
+		exact: make(map[string]bool),
+	}
+
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+
+		if !strings.Contains(p, "*") {
+			normalized, err := Normalize(p, "")
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+			}
+			m.exact[normalized] = true
+			continue
+		}
+
+		expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(p), `\*`, ".*") + "$"
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+		m.patterns = append(m.patterns, re)
+	}
+
+	return m, nil
+}
+
+// Match reports whether the URL matches any pattern in the matcher
+func (m *Matcher) Match(rawURL string) bool {
+	if m == nil {
+		return false
+	}
+
+	if m.exact[rawURL] {
+		return true
+	}
+
+	for _, re := range m.patterns {
+		if re.MatchString(rawURL) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Len returns the number of patterns in the matcher
+func (m *Matcher) Len() int {
+	if m == nil {
+		return 0
+	}
+	return len(m.exact) + len(m.patterns)
+}
+
+// LoadPatterns reads patterns from a file, one per line
+// Blank lines and lines starting with '#' are ignored
+func LoadPatterns(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("opening pattern file: %w", err)
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading pattern file: %w", err)
+	}
+
+	return patterns, nil
+}
@@ -0,0 +1,113 @@
+package urlutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatcher_Match(t *testing.T) {
+	m, err := NewMatcher([]string{
+		"https://docs.unrealengine.com/udk/Two/SiteMap.html",
+		"https://docs.unrealengine.com/udk/Two/Unreal*Reference.html",
+		"*/rsrc/Two/*.png",
+		"",
+	})
+	if err != nil {
+		t.Fatalf("NewMatcher() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		want bool
+	}{
+		{
+			name: "exact match",
+			url:  "https://docs.unrealengine.com/udk/Two/SiteMap.html",
+			want: true,
+		},
+		{
+			name: "glob within filename",
+			url:  "https://docs.unrealengine.com/udk/Two/UnrealScriptReference.html",
+			want: true,
+		},
+		{
+			name: "glob spanning directories",
+			url:  "https://docs.unrealengine.com/udk/Two/rsrc/Two/Editor/shot.png",
+			want: true,
+		},
+		{
+			name: "no match",
+			url:  "https://docs.unrealengine.com/udk/Two/WebHome.html",
+			want: false,
+		},
+		{
+			name: "glob requires full match",
+			url:  "https://docs.unrealengine.com/udk/Two/rsrc/Two/shot.png.bak",
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.Match(tt.url); got != tt.want {
+				t.Errorf("Match(%q) = %v, want %v", tt.url, got, tt.want)
+			}
+		})
+	}
+
+	if m.Len() != 3 {
+		t.Errorf("Len() = %v, want 3", m.Len())
+	}
+}
+
+func TestMatcher_NormalizesExactURLs(t *testing.T) {
+	m, err := NewMatcher([]string{"HTTPS://Example.com:443/page.html?x=1"})
+	if err != nil {
+		t.Fatalf("NewMatcher() error = %v", err)
+	}
+
+	if !m.Match("https://example.com/page.html") {
+		t.Error("expected normalized exact pattern to match")
+	}
+}
+
+func TestMatcher_Nil(t *testing.T) {
+	var m *Matcher
+	if m.Match("https://example.com/") {
+		t.Error("nil matcher should not match")
+	}
+	if m.Len() != 0 {
+		t.Errorf("nil matcher Len() = %v, want 0", m.Len())
+	}
+}
+
+func TestLoadPatterns(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "urls.txt")
+	content := "# pages needed first\nhttps://example.com/a.html\n\n  https://example.com/b/*  \n"
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	patterns, err := LoadPatterns(filename)
+	if err != nil {
+		t.Fatalf("LoadPatterns() error = %v", err)
+	}
+
+	want := []string{"https://example.com/a.html", "https://example.com/b/*"}
+	if len(patterns) != len(want) {
+		t.Fatalf("LoadPatterns() = %v, want %v", patterns, want)
+	}
+	for i := range want {
+		if patterns[i] != want[i] {
+			t.Errorf("patterns[%d] = %q, want %q", i, patterns[i], want[i])
+		}
+	}
+}
+
+func TestLoadPatterns_MissingFile(t *testing.T) {
+	if _, err := LoadPatterns(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
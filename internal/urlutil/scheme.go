@@ -0,0 +1,62 @@
+package urlutil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SchemePolicy controls which of http and https a crawl accepts, as sites
+// linking to their pages by both otherwise have each mirrored twice
+type SchemePolicy int
+
+const (
+	// SchemeAny accepts http and https URLs alike
+	SchemeAny SchemePolicy = iota
+	// SchemeHTTPSOnly rejects http URLs
+	SchemeHTTPSOnly
+	// SchemeUpgrade turns http URLs into https ones, which the fetcher
+	// falls back to plain HTTP for on hosts without working HTTPS
+	SchemeUpgrade
+)
+
+// String returns the policy name as accepted by ParseSchemePolicy
+func (p SchemePolicy) String() string {
+	switch p {
+	case SchemeAny:
+		return "any"
+	case SchemeHTTPSOnly:
+		return "https-only"
+	case SchemeUpgrade:
+		return "upgrade"
+	default:
+		return fmt.Sprintf("SchemePolicy(%d)", int(p))
+	}
+}
+
+// ParseSchemePolicy parses "any", "https-only" or "upgrade"
+func ParseSchemePolicy(s string) (SchemePolicy, error) {
+	switch s {
+	case "any":
+		return SchemeAny, nil
+	case "https-only":
+		return SchemeHTTPSOnly, nil
+	case "upgrade":
+		return SchemeUpgrade, nil
+	default:
+		return 0, fmt.Errorf("unknown scheme policy %q (want any, https-only or upgrade)", s)
+	}
+}
+
+// Apply returns rawURL as the policy has it crawled: upgraded to https with
+// SchemeUpgrade, and unchanged otherwise
+func (p SchemePolicy) Apply(rawURL string) string {
+	if p != SchemeUpgrade || !strings.HasPrefix(rawURL, "http://") {
+		return rawURL
+	}
+	return "https://" + strings.TrimPrefix(rawURL, "http://")
+}
+
+// Allows reports whether the policy accepts a URL of the given scheme
+func (p SchemePolicy) Allows(scheme string) bool {
+	return p != SchemeHTTPSOnly || scheme != "http"
+}
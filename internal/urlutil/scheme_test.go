@@ -0,0 +1,32 @@
+package urlutil
+
+import "testing"
+
+func TestSchemePolicy(t *testing.T) {
+	for _, p := range []SchemePolicy{SchemeAny, SchemeHTTPSOnly, SchemeUpgrade} {
+		got, err := ParseSchemePolicy(p.String())
+		if err != nil || got != p {
+			t.Errorf("ParseSchemePolicy(%q) = %v, %v", p.String(), got, err)
+		}
+	}
+	if _, err := ParseSchemePolicy("http"); err == nil {
+		t.Error("ParseSchemePolicy(\"http\") expected error")
+	}
+
+	const plain = "http://udn.epicgames.com/Two/WebHome.html"
+	if got := SchemeUpgrade.Apply(plain); got != "https://udn.epicgames.com/Two/WebHome.html" {
+		t.Errorf("SchemeUpgrade.Apply() = %q", got)
+	}
+	if got := SchemeAny.Apply(plain); got != plain {
+		t.Errorf("SchemeAny.Apply() = %q, want unchanged", got)
+	}
+
+	filter := NewFilter("https://udn.epicgames.com/Two/WebHome.html", nil)
+	filter.SetSchemePolicy(SchemeHTTPSOnly)
+	if allowed, _ := filter.IsAllowed(plain); allowed {
+		t.Error("https-only filter allowed an http URL")
+	}
+	if allowed, _ := filter.IsAllowed(SchemeUpgrade.Apply(plain)); !allowed {
+		t.Error("https-only filter rejected an https URL")
+	}
+}
@@ -0,0 +1,130 @@
+package urlutil
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Section is a named part of a site: the pages whose paths match any of
+// its patterns. A pattern without a '*' is a path prefix; a '*' in one
+// matches any sequence of characters, including '/'
+type Section struct {
+	Name     string
+	Patterns []string
+}
+
+// LoadSections reads sections from a file, each a "[Name]" line followed
+// by its patterns, one per line, starting with '/'
+// Blank lines and lines starting with '#' are ignored
+func LoadSections(filename string) ([]Section, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("opening sections file: %w", err)
+	}
+	defer f.Close()
+
+	var sections []Section
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			sections = append(sections, Section{Name: strings.TrimSpace(line[1 : len(line)-1])})
+		case len(sections) == 0:
+			return nil, fmt.Errorf("%s:%d: pattern outside a [section]", filename, n)
+		case !strings.HasPrefix(line, "/"):
+			return nil, fmt.Errorf("%s:%d: pattern %q does not start with /", filename, n, line)
+		default:
+			last := &sections[len(sections)-1]
+			last.Patterns = append(last.Patterns, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading sections file: %w", err)
+	}
+
+	return sections, nil
+}
+
+// PathMatcher matches URLs by their path against prefixes and glob patterns
+type PathMatcher struct {
+	prefixes []string
+	patterns []*regexp.Regexp
+}
+
+// SelectSections returns a matcher of the pages of the named sections,
+// whose names are matched case-insensitively
+func SelectSections(sections []Section, names []string) (*PathMatcher, error) {
+	byName := make(map[string]Section)
+	for _, s := range sections {
+		byName[strings.ToLower(s.Name)] = s
+	}
+
+	m := &PathMatcher{}
+	for _, name := range names {
+		s, ok := byName[strings.ToLower(name)]
+		if !ok {
+			known := make([]string, 0, len(sections))
+			for _, s := range sections {
+				known = append(known, s.Name)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown section %q (have %s)", name, strings.Join(known, ", "))
+		}
+		for _, p := range s.Patterns {
+			if !strings.Contains(p, "*") {
+				m.prefixes = append(m.prefixes, p)
+				continue
+			}
+			re, err := regexp.Compile("^" + strings.ReplaceAll(regexp.QuoteMeta(p), `\*`, ".*") + "$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+			}
+			m.patterns = append(m.patterns, re)
+		}
+	}
+	return m, nil
+}
+
+// Match reports whether the path of the URL matches any prefix or pattern
+// in the matcher
+func (m *PathMatcher) Match(rawURL string) bool {
+	if m == nil {
+		return false
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	p := u.Path
+	if p == "" {
+		p = "/"
+	}
+
+	for _, prefix := range m.prefixes {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	for _, re := range m.patterns {
+		if re.MatchString(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of prefixes and patterns in the matcher
+func (m *PathMatcher) Len() int {
+	if m == nil {
+		return 0
+	}
+	return len(m.prefixes) + len(m.patterns)
+}
@@ -0,0 +1,80 @@
+package urlutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadSections(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sections.txt")
+	content := `# Sections of the UE2 docs
+[API]
+/udk/Two/UnrealScript
+/udk/Two/*Reference.html
+
+[Networking]
+/udk/Two/Network
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sections, err := LoadSections(path)
+	if err != nil {
+		t.Fatalf("LoadSections() error = %v", err)
+	}
+	if len(sections) != 2 || sections[0].Name != "API" || len(sections[0].Patterns) != 2 || sections[1].Name != "Networking" {
+		t.Fatalf("LoadSections() = %+v", sections)
+	}
+
+	m, err := SelectSections(sections, []string{"api"})
+	if err != nil {
+		t.Fatalf("SelectSections() error = %v", err)
+	}
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://docs.unrealengine.com/udk/Two/UnrealScriptReference.html", true},
+		{"https://docs.unrealengine.com/udk/Two/UnrealScriptTutorial.html", true},
+		{"https://docs.unrealengine.com/udk/Two/ActorReference.html", true},
+		{"https://docs.unrealengine.com/udk/Two/NetworkingTome.html", false},
+		{"https://docs.unrealengine.com/udk/Two/SiteMap.html", false},
+	}
+	for _, tt := range tests {
+		if got := m.Match(tt.url); got != tt.want {
+			t.Errorf("Match(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+
+	if _, err := SelectSections(sections, []string{"Editor"}); err == nil || !strings.Contains(err.Error(), "API, Networking") {
+		t.Errorf("SelectSections() of unknown section error = %v, want one listing the sections", err)
+	}
+}
+
+func TestLoadSections_Invalid(t *testing.T) {
+	for _, content := range []string{
+		"/udk/Two/UnrealScript\n",
+		"[API]\nUnrealScript*\n",
+	} {
+		path := filepath.Join(t.TempDir(), "sections.txt")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadSections(path); err == nil {
+			t.Errorf("LoadSections(%q) succeeded, want error", content)
+		}
+	}
+}
+
+func TestPathMatcher_Nil(t *testing.T) {
+	var m *PathMatcher
+	if m.Match("https://example.com/") {
+		t.Error("nil matcher matched")
+	}
+	if m.Len() != 0 {
+		t.Errorf("nil matcher Len() = %d, want 0", m.Len())
+	}
+}
@@ -0,0 +1,67 @@
+package urlutil
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// waybackHost serves Wayback Machine snapshots
+const waybackHost = "web.archive.org"
+
+// waybackTimestamp matches the timestamp segment of a snapshot URL: up to 14
+// digits, optionally a "*" wildcard and a modifier such as id_ or im_
+var waybackTimestamp = regexp.MustCompile(`^(\d{1,14})\*?(?:[a-z]{2}_)?$`)
+
+// ParseWayback splits a Wayback Machine snapshot URL such as
+// https://web.archive.org/web/2012*/http://udn.epicgames.com/Two/WebHome.html
+// into its timestamp ("2012") and the original URL
+// Returns false if the URL is not a snapshot URL
+func ParseWayback(rawURL string) (timestamp, original string, ok bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || !strings.EqualFold(u.Host, waybackHost) || !strings.HasPrefix(u.Path, "/web/") {
+		return "", "", false
+	}
+
+	ts, original, found := strings.Cut(strings.TrimPrefix(u.Path, "/web/"), "/")
+	if !found {
+		return "", "", false
+	}
+	m := waybackTimestamp.FindStringSubmatch(ts)
+	if m == nil {
+		return "", "", false
+	}
+
+	// Clients and servers sometimes collapse the "//" of the embedded URL
+	switch {
+	case strings.HasPrefix(original, "http://"), strings.HasPrefix(original, "https://"):
+	case strings.HasPrefix(original, "http:/"), strings.HasPrefix(original, "https:/"):
+		original = strings.Replace(original, ":/", "://", 1)
+	default:
+		original = "http://" + original
+	}
+	if u.RawQuery != "" {
+		original += "?" + u.RawQuery
+	}
+	if _, err := url.Parse(original); err != nil || original == "http://" {
+		return "", "", false
+	}
+
+	return m[1], original, true
+}
+
+// UnwrapWayback returns the original URL of a Wayback Machine snapshot URL,
+// or rawURL unchanged if it is not one
+func UnwrapWayback(rawURL string) string {
+	if _, original, ok := ParseWayback(rawURL); ok {
+		return original
+	}
+	return rawURL
+}
+
+// WaybackURL returns the URL serving the raw archived content of original
+// from the snapshot closest to timestamp, without the Wayback Machine's
+// toolbar or link rewriting
+func WaybackURL(timestamp, original string) string {
+	return "https://" + waybackHost + "/web/" + timestamp + "id_/" + original
+}
@@ -0,0 +1,45 @@
+package urlutil
+
+import "testing"
+
+func TestParseWayback(t *testing.T) {
+	tests := []struct {
+		url          string
+		wantOK       bool
+		wantTS       string
+		wantOriginal string
+	}{
+		{"https://web.archive.org/web/2012*/http://udn.epicgames.com/Two/WebHome.html", true, "2012", "http://udn.epicgames.com/Two/WebHome.html"},
+		{"https://web.archive.org/web/20120315123456/http://udn.epicgames.com/Two/WebHome.html", true, "20120315123456", "http://udn.epicgames.com/Two/WebHome.html"},
+		{"https://web.archive.org/web/20120315123456im_/http://udn.epicgames.com/pub/logo.gif", true, "20120315123456", "http://udn.epicgames.com/pub/logo.gif"},
+		{"http://web.archive.org/web/2012/http:/udn.epicgames.com/Two/", true, "2012", "http://udn.epicgames.com/Two/"},
+		{"https://web.archive.org/web/2012/udn.epicgames.com/Two/Page.html?skin=print", true, "2012", "http://udn.epicgames.com/Two/Page.html?skin=print"},
+		{"https://web.archive.org/web/*/http://udn.epicgames.com/", false, "", ""},
+		{"https://web.archive.org/about/", false, "", ""},
+		{"https://udn.epicgames.com/web/2012/http://x.com/", false, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			ts, original, ok := ParseWayback(tt.url)
+			if ok != tt.wantOK || ts != tt.wantTS || original != tt.wantOriginal {
+				t.Errorf("ParseWayback() = %q, %q, %v; want %q, %q, %v", ts, original, ok, tt.wantTS, tt.wantOriginal, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestWaybackURL(t *testing.T) {
+	got := WaybackURL("2012", "http://udn.epicgames.com/Two/WebHome.html")
+	want := "https://web.archive.org/web/2012id_/http://udn.epicgames.com/Two/WebHome.html"
+	if got != want {
+		t.Errorf("WaybackURL() = %q, want %q", got, want)
+	}
+
+	if UnwrapWayback(got) != "http://udn.epicgames.com/Two/WebHome.html" {
+		t.Errorf("UnwrapWayback(WaybackURL()) = %q", UnwrapWayback(got))
+	}
+	if UnwrapWayback("http://udn.epicgames.com/") != "http://udn.epicgames.com/" {
+		t.Error("UnwrapWayback() changed a non-snapshot URL")
+	}
+}
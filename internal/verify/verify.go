@@ -0,0 +1,188 @@
+// Package verify checks the files of a mirror against its manifest,
+// hashing them in parallel so mirrors of hundreds of thousands of files
+// verify in reasonable time
+package verify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aldehir/ue2-docs/internal/manifest"
+	"github.com/aldehir/ue2-docs/internal/storage"
+)
+
+// Config holds verification configuration
+type Config struct {
+	Root    string // Mirror directory containing manifest.json
+	Workers int    // Files hashed at once (0 = one per CPU)
+
+	// Quick only checks that every file exists with the size in the
+	// manifest, without reading it. Compressed files are only checked to
+	// exist, since the manifest records the size of their content
+	Quick bool
+}
+
+// Problem is a file that failed verification
+type Problem struct {
+	Entry manifest.Entry
+	Err   error // Wraps os.ErrNotExist for missing files
+}
+
+// Missing reports whether the file is absent from disk
+func (p Problem) Missing() bool {
+	return errors.Is(p.Err, os.ErrNotExist)
+}
+
+// Result summarizes a verification pass
+type Result struct {
+	Checked  int
+	Missing  int // Listed in the manifest but absent from disk
+	Damaged  int // Present with the wrong size or checksum
+	Bytes    int64
+	Problems []Problem // Sorted by path
+}
+
+// Progress is a snapshot of a running verification's counters
+type Progress struct {
+	Total   int // Files in the manifest
+	Checked int
+	Bytes   int64 // Bytes hashed, or the sizes checked in quick mode
+}
+
+// Verifier checks a mirror against its manifest
+type Verifier struct {
+	config Config
+
+	total   atomic.Int64
+	checked atomic.Int64
+	bytes   atomic.Int64
+}
+
+// New creates a new Verifier with the given configuration
+func New(config Config) *Verifier {
+	if config.Workers < 1 {
+		config.Workers = runtime.NumCPU()
+	}
+	return &Verifier{config: config}
+}
+
+// Progress returns the verification's current counters; it is safe to
+// call while Run is in progress
+func (v *Verifier) Progress() Progress {
+	return Progress{
+		Total:   int(v.total.Load()),
+		Checked: int(v.checked.Load()),
+		Bytes:   v.bytes.Load(),
+	}
+}
+
+// Run checks every file in the manifest. Cancelling ctx stops handing out
+// files, returning the result so far along with ctx's error
+func (v *Verifier) Run(ctx context.Context) (*Result, error) {
+	st, err := storage.Open(v.config.Root)
+	if err != nil {
+		return nil, err
+	}
+	all := st.Manifest().Entries()
+	if len(all) == 0 {
+		return nil, fmt.Errorf("no manifest entries in %s", v.config.Root)
+	}
+	v.total.Store(int64(len(all)))
+
+	result := &Result{}
+	var mu sync.Mutex
+
+	entries := make(chan manifest.Entry)
+	var wg sync.WaitGroup
+	for i := 0; i < v.config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range entries {
+				err := File(st, entry, v.config.Quick)
+				v.checked.Add(1)
+				v.bytes.Add(entry.Size)
+
+				mu.Lock()
+				result.Checked++
+				result.Bytes += entry.Size
+				if err != nil {
+					problem := Problem{Entry: entry, Err: err}
+					if problem.Missing() {
+						result.Missing++
+					} else {
+						result.Damaged++
+					}
+					result.Problems = append(result.Problems, problem)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, entry := range all {
+		select {
+		case entries <- entry:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(entries)
+	wg.Wait()
+
+	sort.Slice(result.Problems, func(i, j int) bool {
+		return result.Problems[i].Entry.Path < result.Problems[j].Entry.Path
+	})
+	return result, ctx.Err()
+}
+
+// File compares the file of a manifest entry with the entry, decompressing
+// files stored compressed; quick only checks its existence and size.
+// Returns an error wrapping os.ErrNotExist for missing files
+func File(st *storage.Storage, entry manifest.Entry, quick bool) error {
+	full := st.FullPath(entry.File())
+	if quick {
+		info, err := os.Stat(full)
+		if err != nil {
+			return err
+		}
+		if entry.Encoding == "" && info.Size() != entry.Size {
+			return fmt.Errorf("size %d, want %d", info.Size(), entry.Size)
+		}
+		return nil
+	}
+
+	f, err := os.Open(full)
+	if err != nil {
+		return err
+	}
+	r, err := storage.Decode(f, entry.Encoding)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, r)
+	if err != nil {
+		return err
+	}
+	if size != entry.Size {
+		return fmt.Errorf("size %d, want %d", size, entry.Size)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); entry.SHA256 != "" && sum != entry.SHA256 {
+		return fmt.Errorf("checksum %s, want %s", sum, entry.SHA256)
+	}
+	return nil
+}
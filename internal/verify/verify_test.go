@@ -0,0 +1,71 @@
+package verify
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aldehir/ue2-docs/internal/manifest"
+	"github.com/aldehir/ue2-docs/internal/storage"
+)
+
+func TestVerifier_Run(t *testing.T) {
+	root := t.TempDir()
+	st := storage.New(root)
+	for _, entry := range []manifest.Entry{
+		{Path: "example.com/intact.png"},
+		{Path: "example.com/missing.png"},
+		{Path: "example.com/truncated.css"},
+		{Path: "example.com/corrupt.css"},
+		{Path: "example.com/page.html", Encoding: manifest.EncodingGzip},
+	} {
+		if _, err := st.Save(context.Background(), entry, strings.NewReader("body { color: black; }")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.WriteManifest(); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Remove(st.FullPath("example.com/missing.png"))
+	os.WriteFile(st.FullPath("example.com/truncated.css"), []byte("body {"), 0644)
+	// Same size, different content: only hashing notices
+	os.WriteFile(st.FullPath("example.com/corrupt.css"), []byte("body { color: white; }"), 0644)
+
+	tests := []struct {
+		name    string
+		quick   bool
+		damaged []string
+	}{
+		{"full", false, []string{"example.com/corrupt.css", "example.com/truncated.css"}},
+		{"quick", true, []string{"example.com/truncated.css"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New(Config{Root: root, Workers: 3, Quick: tt.quick})
+			result, err := v.Run(context.Background())
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if result.Checked != 5 || result.Missing != 1 || result.Damaged != len(tt.damaged) {
+				t.Errorf("Run() = %+v, want 5 checked, 1 missing, %d damaged", result, len(tt.damaged))
+			}
+
+			var damaged []string
+			for _, p := range result.Problems {
+				if !p.Missing() {
+					damaged = append(damaged, p.Entry.Path)
+				} else if p.Entry.Path != "example.com/missing.png" {
+					t.Errorf("reported missing: %s", p.Entry.Path)
+				}
+			}
+			if strings.Join(damaged, " ") != strings.Join(tt.damaged, " ") {
+				t.Errorf("damaged = %v, want %v", damaged, tt.damaged)
+			}
+			if p := v.Progress(); p.Checked != 5 || p.Total != 5 {
+				t.Errorf("Progress() = %+v, want all 5 checked", p)
+			}
+		})
+	}
+}
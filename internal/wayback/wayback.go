@@ -0,0 +1,231 @@
+// Package wayback submits pages to the Internet Archive's Save Page Now
+// service
+package wayback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aldehir/ue2-docs/internal/fetcher"
+)
+
+// Config holds Save Page Now configuration
+type Config struct {
+	Endpoint  string // Save Page Now endpoint
+	AccessKey string // archive.org API keys; anonymous submission if empty
+	SecretKey string
+	Interval  time.Duration // Minimum time between submissions
+	Timeout   time.Duration
+	UserAgent string
+
+	// RateLimitRetries is how many times a URL is retried after Save Page
+	// Now answers that it is being called too often, waiting longer each
+	// time, before the URL is given up on as failed
+	RateLimitRetries int
+}
+
+// DefaultConfig returns a configuration that stays well inside the
+// anonymous Save Page Now rate limits
+func DefaultConfig() Config {
+	return Config{
+		Endpoint:  "https://web.archive.org/save",
+		Interval:  15 * time.Second,
+		Timeout:   2 * time.Minute,
+		UserAgent: fetcher.ArchivalUserAgent(""),
+
+		RateLimitRetries: 5,
+	}
+}
+
+// rateLimitBackoff multiplies the interval after the service first reports
+// that it is being called too often; the wait doubles on each retry after
+const rateLimitBackoff = 4
+
+// errRateLimited is returned when Save Page Now answers 429
+var errRateLimited = errors.New("rate limited by Save Page Now")
+
+// Submitter submits URLs to Save Page Now one at a time from a background
+// queue, so crawl workers never wait on the archive
+type Submitter struct {
+	client *http.Client
+	config Config
+
+	mu      sync.Mutex
+	pending []string
+	closed  bool
+	wake    chan struct{}
+
+	ctx    context.Context // Cancelled when Close gives up waiting
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	submitted atomic.Int64
+	failed    atomic.Int64
+}
+
+// NewSubmitter creates a submitter and starts its background queue
+func NewSubmitter(config Config) *Submitter {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Submitter{
+		client: &http.Client{Timeout: config.Timeout},
+		config: config,
+		wake:   make(chan struct{}, 1),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Enqueue adds a URL to the submission queue
+// Safe to call on a nil Submitter, which discards the URL
+func (s *Submitter) Enqueue(pageURL string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if !s.closed {
+		s.pending = append(s.pending, pageURL)
+	}
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Pending returns the number of URLs waiting to be submitted
+func (s *Submitter) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+// Submitted returns the number of URLs accepted by Save Page Now
+func (s *Submitter) Submitted() int {
+	return int(s.submitted.Load())
+}
+
+// Failed returns the number of URLs that could not be submitted
+func (s *Submitter) Failed() int {
+	return int(s.failed.Load())
+}
+
+// Close stops accepting URLs and waits for the queue to drain, or for ctx
+// to be cancelled. Returns the number of URLs left unsubmitted
+func (s *Submitter) Close(ctx context.Context) int {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		s.cancel()
+		<-s.done
+	}
+	s.cancel()
+
+	return s.Pending()
+}
+
+// run submits queued URLs, pausing between submissions
+func (s *Submitter) run() {
+	defer close(s.done)
+
+	limited := 0 // Times the URL at the head of the queue was rate limited
+	for {
+		s.mu.Lock()
+		if len(s.pending) == 0 {
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return
+			}
+			select {
+			case <-s.wake:
+				continue
+			case <-s.ctx.Done():
+				return
+			}
+		}
+		pageURL := s.pending[0]
+		s.mu.Unlock()
+
+		wait := s.config.Interval
+		err := s.Submit(s.ctx, pageURL)
+		switch {
+		case s.ctx.Err() != nil:
+			return
+		case errors.Is(err, errRateLimited) && limited < s.config.RateLimitRetries:
+			// Keep the URL queued and back off
+			wait *= rateLimitBackoff << limited
+			limited++
+		default:
+			s.mu.Lock()
+			s.pending = s.pending[1:]
+			s.mu.Unlock()
+			limited = 0
+
+			if err != nil {
+				log.Printf("wayback submission failed for %s: %v", pageURL, err)
+				s.failed.Add(1)
+			} else {
+				s.submitted.Add(1)
+			}
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// Submit asks Save Page Now to capture a single URL
+func (s *Submitter) Submit(ctx context.Context, pageURL string) error {
+	form := url.Values{"url": {pageURL}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", s.config.UserAgent)
+	if s.config.AccessKey != "" {
+		req.Header.Set("Authorization", "LOW "+s.config.AccessKey+":"+s.config.SecretKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("submitting %s: %w", pageURL, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return errRateLimited
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return fmt.Errorf("submitting %s: HTTP %d", pageURL, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package wayback
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type saveServer struct {
+	mu       sync.Mutex
+	urls     []string
+	auth     string
+	limitFor int // Answer 429 to this many requests first
+}
+
+func (s *saveServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.limitFor > 0 {
+		s.limitFor--
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	s.urls = append(s.urls, r.FormValue("url"))
+	s.auth = r.Header.Get("Authorization")
+	w.Write([]byte(`{"job_id":"x"}`))
+}
+
+func (s *saveServer) submitted() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.urls...)
+}
+
+func testConfig(endpoint string) Config {
+	config := DefaultConfig()
+	config.Endpoint = endpoint
+	config.Interval = time.Millisecond
+	return config
+}
+
+func TestSubmitter_DrainsQueue(t *testing.T) {
+	srv := &saveServer{limitFor: 1}
+	server := httptest.NewServer(srv)
+	defer server.Close()
+
+	config := testConfig(server.URL)
+	config.AccessKey = "key"
+	config.SecretKey = "secret"
+	s := NewSubmitter(config)
+
+	s.Enqueue("https://example.com/a.html")
+	s.Enqueue("https://example.com/b.html")
+
+	if remaining := s.Close(context.Background()); remaining != 0 {
+		t.Errorf("Close() left %d unsubmitted", remaining)
+	}
+
+	got := srv.submitted()
+	if len(got) != 2 || got[0] != "https://example.com/a.html" || got[1] != "https://example.com/b.html" {
+		t.Errorf("submitted %v, want both URLs in order after the rate limit", got)
+	}
+	if s.Submitted() != 2 || s.Failed() != 0 {
+		t.Errorf("Submitted/Failed = %d/%d, want 2/0", s.Submitted(), s.Failed())
+	}
+	if srv.auth != "LOW key:secret" {
+		t.Errorf("Authorization = %q", srv.auth)
+	}
+
+	// Closed submitters ignore new URLs
+	s.Enqueue("https://example.com/c.html")
+	if s.Pending() != 0 {
+		t.Error("Enqueue after Close should be ignored")
+	}
+}
+
+func TestSubmitter_RateLimitRetries(t *testing.T) {
+	srv := &saveServer{limitFor: 1000}
+	server := httptest.NewServer(srv)
+	defer server.Close()
+
+	config := testConfig(server.URL)
+	config.RateLimitRetries = 3
+	s := NewSubmitter(config)
+
+	start := time.Now()
+	s.Enqueue("https://example.com/a.html")
+	if remaining := s.Close(context.Background()); remaining != 0 {
+		t.Errorf("Close() left %d unsubmitted, want the URL given up on", remaining)
+	}
+	if s.Failed() != 1 {
+		t.Errorf("Failed = %d, want 1", s.Failed())
+	}
+	srv.mu.Lock()
+	requests := 1000 - srv.limitFor
+	srv.mu.Unlock()
+	if requests != 4 {
+		t.Errorf("Save Page Now called %d times, want 1 and 3 retries", requests)
+	}
+	// The waits after each 429 grow: 4, 8 and 16 intervals
+	if elapsed := time.Since(start); elapsed < 28*config.Interval {
+		t.Errorf("retries took %v, want at least %v", elapsed, 28*config.Interval)
+	}
+}
+
+func TestSubmitter_CloseGivesUp(t *testing.T) {
+	srv := &saveServer{}
+	server := httptest.NewServer(srv)
+	defer server.Close()
+
+	config := testConfig(server.URL)
+	config.Interval = time.Hour
+	s := NewSubmitter(config)
+
+	s.Enqueue("https://example.com/a.html")
+	s.Enqueue("https://example.com/b.html")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if remaining := s.Close(ctx); remaining != 1 {
+		t.Errorf("Close() left %d unsubmitted, want 1", remaining)
+	}
+}
+
+func TestSubmitter_Nil(t *testing.T) {
+	var s *Submitter
+	s.Enqueue("https://example.com/") // Must not panic
+}
+
+func TestSubmit_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	s := NewSubmitter(testConfig(server.URL))
+	defer s.Close(context.Background())
+
+	if err := s.Submit(context.Background(), "https://example.com/"); err == nil {
+		t.Error("Submit() expected error for HTTP 502")
+	}
+}
@@ -0,0 +1,75 @@
+// Package zst reads and writes the Zstandard-compressed variants of the
+// crawl state file and manifest, which grow to gigabytes of JSON on
+// archive-scale crawls
+package zst
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Extension marks Zstandard-compressed files
+const Extension = ".zst"
+
+// magic starts every Zstandard frame
+var magic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// ErrTruncated is returned when the data ends partway through a frame, as
+// left by a crash while the frame was written
+var ErrTruncated = errors.New("zstd frame cut short")
+
+// Compressed reports whether a file name has the Zstandard extension
+func Compressed(name string) bool {
+	return strings.HasSuffix(name, Extension)
+}
+
+// NewWriter returns an encoder compressing to w. Compression runs in the
+// background, so writes return before their data is compressed; Flush
+// completes what was written so far and Close the frame, leaving w open
+func NewWriter(w io.Writer) (*zstd.Encoder, error) {
+	enc, err := zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	if err != nil {
+		return nil, fmt.Errorf("creating zstd encoder: %w", err)
+	}
+	return enc, nil
+}
+
+// NewReader returns a reader of r's content, decompressing it if it starts
+// with a Zstandard frame and passing it through otherwise, so callers need
+// not know how a file was written. Reading a frame cut short fails with
+// ErrTruncated after returning the data decoded before the cut
+func NewReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	if head, _ := br.Peek(len(magic)); !bytes.Equal(head, magic) {
+		return io.NopCloser(br), nil
+	}
+	dec, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, fmt.Errorf("creating zstd decoder: %w", err)
+	}
+	return reader{dec}, nil
+}
+
+// reader decompresses a stream, releasing the decoder when closed
+type reader struct {
+	dec *zstd.Decoder
+}
+
+func (r reader) Read(p []byte) (int, error) {
+	n, err := r.dec.Read(p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = fmt.Errorf("%w: %w", ErrTruncated, err)
+	}
+	return n, err
+}
+
+func (r reader) Close() error {
+	r.dec.Close()
+	return nil
+}
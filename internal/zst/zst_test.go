@@ -0,0 +1,81 @@
+package zst
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// compress returns data as one Zstandard frame
+func compress(t *testing.T, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	enc, err := NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := enc.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCompressed(t *testing.T) {
+	if !Compressed("crawl.jsonl.zst") || Compressed("crawl.jsonl") {
+		t.Error("Compressed() should report only names ending in .zst")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	data := strings.Repeat(`{"url":"https://example.com/a","status":200}`+"\n", 1000)
+	frames := append(compress(t, data), compress(t, "second frame\n")...)
+
+	tests := []struct {
+		name  string
+		input []byte
+		want  string
+	}{
+		{"frame", compress(t, data), data},
+		{"appended frames", frames, data + "second frame\n"},
+		{"empty frame", compress(t, ""), ""},
+		{"uncompressed", []byte(data), data},
+		{"empty", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReader(bytes.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("NewReader() error = %v", err)
+			}
+			defer r.Close()
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("read %d bytes, want %d", len(got), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestReader_Truncated(t *testing.T) {
+	frame := compress(t, strings.Repeat("line of the crawl state\n", 1000))
+
+	for _, size := range []int{len(magic) + 2, len(frame) / 2, len(frame) - 1} {
+		r, err := NewReader(bytes.NewReader(frame[:size]))
+		if err != nil {
+			t.Fatalf("NewReader() error = %v", err)
+		}
+		_, err = io.ReadAll(r)
+		r.Close()
+		if !errors.Is(err, ErrTruncated) {
+			t.Errorf("ReadAll() of %d of %d bytes error = %v, want ErrTruncated", size, len(frame), err)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Package convert turns a mirror saved by package crawl into Markdown,
+// copying images and other assets so links keep working.
+//
+// It is the library form of the "ue2-docs convert" command:
+//
+//	config := convert.DefaultConfig()
+//	config.InputDir = "./docs"
+//	config.OutputDir = "./markdown"
+//	result, err := convert.Run(ctx, config)
+package convert
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+
+	"github.com/aldehir/ue2-docs/internal/converter"
+	"github.com/aldehir/ue2-docs/internal/search"
+)
+
+// Config holds conversion configuration
+type Config struct {
+	InputDir          string // Mirror directory
+	OutputDir         string // Directory the Markdown is written to
+	PreserveStructure bool   // Keep the mirror's directory layout; otherwise flatten
+	RawHTML           bool   // Keep applets, objects, forms and the like as raw HTML
+
+	// TOC adds a table of contents of the headings from TOCMinLevel to
+	// TOCMaxLevel (0 = 2 to 3) to the top of each page
+	TOC         bool
+	TOCMinLevel int
+	TOCMaxLevel int
+
+	// EntityMap holds extra text replacements applied to every page
+	EntityMap map[string]string
+
+	// QuickReference and SearchIndex are paths, relative to OutputDir, of a
+	// generated quick reference appendix and search index ("" = disabled)
+	QuickReference string
+	SearchIndex    string
+
+	// Analyzer names the search index analyzer: "unrealscript",
+	// "standard" or "simple"
+	Analyzer string
+
+	// RepairReport is the path, relative to OutputDir, of a report of the
+	// broken markup repaired in each page before conversion ("" =
+	// disabled)
+	RepairReport string
+
+	// FrontMatter starts each page with YAML front matter holding its
+	// title, last_modified date and TWiki author, revision and parent.
+	// DatePatterns are regular expressions whose first group captures that
+	// date in the page text (nil = the dates in TWiki footers)
+	FrontMatter  bool
+	DatePatterns []string
+
+	// Backlinks ends every page other pages link to with a "Referenced by"
+	// section listing them, also listed in the front matter
+	Backlinks bool
+
+	// Update converts over an earlier conversion in OutputDir, keeping the
+	// previous version of changed pages. Changes is the path, relative to
+	// OutputDir, of a changelog of their diffs ("" = disabled)
+	Update  bool
+	Changes string
+
+	// Logger receives the files that failed to convert; nil =
+	// slog.Default()
+	Logger *slog.Logger
+}
+
+// DefaultConfig returns a sensible default configuration; InputDir and
+// OutputDir must still be set
+func DefaultConfig() Config {
+	return Config{
+		PreserveStructure: true,
+		QuickReference:    "QuickReference.md",
+		SearchIndex:       "search-index.json",
+		Analyzer:          search.DefaultAnalyzer().Name,
+	}
+}
+
+// Result summarizes a conversion
+type Result struct {
+	Converted  int
+	Copied     int // Assets copied alongside the Markdown
+	Failed     int
+	Repaired   int // Pages whose HTML needed repairs before conversion
+	Linked     int // Pages given a "Referenced by" section, with Backlinks
+	References int // Entries in the quick reference appendix
+	Indexed    int // Pages in the search index
+	Changed    int // Pages changed since the earlier conversion, with Update
+}
+
+// Run converts every page under config.InputDir. Cancelling ctx stops the
+// conversion early
+func Run(ctx context.Context, config Config) (*Result, error) {
+	if config.InputDir == "" || config.OutputDir == "" {
+		return nil, fmt.Errorf("convert: InputDir and OutputDir are required")
+	}
+
+	var analyzer search.Analyzer
+	if config.Analyzer != "" {
+		var err error
+		if analyzer, err = search.ParseAnalyzer(config.Analyzer); err != nil {
+			return nil, fmt.Errorf("convert: %w", err)
+		}
+	}
+
+	var datePatterns []*regexp.Regexp
+	for _, pattern := range config.DatePatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("convert: date pattern: %w", err)
+		}
+		datePatterns = append(datePatterns, re)
+	}
+
+	r, err := converter.New(converter.Config{
+		InputDir:          config.InputDir,
+		OutputDir:         config.OutputDir,
+		PreserveStructure: config.PreserveStructure,
+		RawHTML:           config.RawHTML,
+		TOC:               config.TOC,
+		TOCMinLevel:       config.TOCMinLevel,
+		TOCMaxLevel:       config.TOCMaxLevel,
+		EntityMap:         config.EntityMap,
+		QuickReference:    config.QuickReference,
+		SearchIndex:       config.SearchIndex,
+		Analyzer:          analyzer,
+		RepairReport:      config.RepairReport,
+		FrontMatter:       config.FrontMatter,
+		DatePatterns:      datePatterns,
+		Backlinks:         config.Backlinks,
+		Update:            config.Update,
+		Changes:           config.Changes,
+		Logger:            config.Logger,
+	}).Run(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("convert: %w", err)
+	}
+
+	return &Result{
+		Converted:  r.Converted,
+		Copied:     r.Copied,
+		Failed:     r.Failed,
+		Repaired:   r.Repaired,
+		Linked:     r.Linked,
+		References: r.References,
+		Indexed:    r.Indexed,
+		Changed:    r.Changed,
+	}, nil
+}
+
+// HTML converts a single HTML document to Markdown
+func HTML(r io.Reader) (string, error) {
+	return converter.New(converter.Config{}).Convert(r)
+}
@@ -0,0 +1,48 @@
+package convert
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	input := t.TempDir()
+	output := t.TempDir()
+	os.WriteFile(filepath.Join(input, "Page.html"), []byte("<title>Page</title><h1>Actors</h1>"), 0644)
+
+	config := DefaultConfig()
+	config.InputDir = input
+	config.OutputDir = output
+
+	result, err := Run(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Converted != 1 || result.Indexed != 1 {
+		t.Errorf("Run() = %+v, want 1 converted and indexed", result)
+	}
+	if _, err := os.Stat(filepath.Join(output, config.SearchIndex)); err != nil {
+		t.Errorf("search index not written: %v", err)
+	}
+
+	config.Analyzer = "porter"
+	if _, err := Run(context.Background(), config); err == nil {
+		t.Error("expected error for unknown analyzer")
+	}
+}
+
+func ExampleHTML() {
+	md, err := HTML(strings.NewReader(`<h1>Actors</h1><p>Call <code>Spawn</code> to create one.</p>`))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Print(md)
+	// Output:
+	// # Actors
+	//
+	// Call `Spawn` to create one.
+}
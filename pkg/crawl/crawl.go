@@ -0,0 +1,132 @@
+// Package crawl mirrors a documentation site to a local directory, saving
+// every in-scope page and asset with links rewritten to the local copies
+// and a manifest.json describing the saved files.
+//
+// It is the library form of the "ue2-docs scrape" command:
+//
+//	config := crawl.DefaultConfig()
+//	config.RootURL = "https://docs.unrealengine.com/udk/Two/SiteMap.html"
+//	config.OutputDir = "./docs"
+//	result, err := crawl.Run(ctx, config)
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aldehir/ue2-docs/internal/fetcher"
+	"github.com/aldehir/ue2-docs/internal/scraper"
+)
+
+// Config holds crawl configuration
+type Config struct {
+	RootURL   string   // Starting page; its directory bounds the crawl
+	OutputDir string   // Directory the mirror is written to
+	Workers   int      // Concurrent downloads
+	Whitelist []string // Additional hosts whose resources are saved
+	MaxDepth  int      // Maximum links followed from the root (0 = unlimited)
+	Sitemap   bool     // Also queue the in-scope URLs listed in the site's sitemaps
+
+	// Version saves the crawl under OutputDir/<Version>/ alongside other
+	// documentation versions ("" = unversioned)
+	Version string
+
+	UserAgent         string
+	Timeout           time.Duration // Per request
+	MaxRetries        int
+	RequestsPerSecond int // 0 = unlimited
+
+	// RobotsCrawlDelay slows each host to the Crawl-delay its robots.txt
+	// asks for, capped at MaxCrawlDelay (0 = uncapped)
+	RobotsCrawlDelay bool
+	MaxCrawlDelay    time.Duration
+
+	// RobotsTxt skips the URLs robots.txt disallows for the crawler
+	RobotsTxt bool
+
+	// StatePath records every fetch to a crawl state file ("" = disabled)
+	StatePath string
+}
+
+// DefaultConfig returns a sensible default configuration; RootURL and
+// OutputDir must still be set
+func DefaultConfig() Config {
+	fetcherConfig := fetcher.DefaultConfig()
+	return Config{
+		Workers:    10,
+		Sitemap:    true,
+		UserAgent:  fetcherConfig.UserAgent,
+		Timeout:    fetcherConfig.Timeout,
+		MaxRetries: fetcherConfig.MaxRetries,
+
+		RobotsCrawlDelay: true,
+		MaxCrawlDelay:    time.Minute,
+		RobotsTxt:        true,
+	}
+}
+
+// Result summarizes a finished crawl
+type Result struct {
+	Visited  int
+	Saved    int
+	Failed   int
+	Bytes    int64
+	Duration time.Duration
+
+	// Completeness is the estimated fraction of the site mirrored (0-1),
+	// from the URLs queued, listed in sitemaps, and linked from the root
+	Completeness float64
+}
+
+// Run crawls the site described by config. Cancelling ctx stops the crawl
+// early; the partial result is returned along with ctx's error
+func Run(ctx context.Context, config Config) (*Result, error) {
+	if config.RootURL == "" || config.OutputDir == "" {
+		return nil, fmt.Errorf("crawl: RootURL and OutputDir are required")
+	}
+
+	fetcherConfig := fetcher.DefaultConfig()
+	fetcherConfig.UserAgent = config.UserAgent
+	fetcherConfig.Timeout = config.Timeout
+	fetcherConfig.MaxRetries = config.MaxRetries
+	if config.RequestsPerSecond > 0 {
+		limiter := fetcher.NewSimpleRateLimiter(config.RequestsPerSecond, time.Second)
+		defer limiter.Stop()
+		fetcherConfig.RateLimiter = limiter
+	}
+
+	s, err := scraper.New(scraper.Config{
+		RootURL:   config.RootURL,
+		OutputDir: config.OutputDir,
+		Workers:   config.Workers,
+		Whitelist: config.Whitelist,
+		MaxDepth:  config.MaxDepth,
+		Sitemap:   config.Sitemap,
+		Fetcher:   fetcherConfig,
+		StatePath: config.StatePath,
+		Version:   config.Version,
+
+		RobotsCrawlDelay: config.RobotsCrawlDelay,
+		MaxCrawlDelay:    config.MaxCrawlDelay,
+		RobotsTxt:        config.RobotsTxt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("crawl: %w", err)
+	}
+
+	r, err := s.Run(ctx)
+	if r == nil {
+		return nil, fmt.Errorf("crawl: %w", err)
+	}
+
+	return &Result{
+		Visited:  r.Visited,
+		Saved:    r.Saved,
+		Failed:   r.Failed,
+		Bytes:    r.Bytes,
+		Duration: r.Duration,
+
+		Completeness: r.Completeness.Estimate(),
+	}, err
+}
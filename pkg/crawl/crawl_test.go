@@ -0,0 +1,48 @@
+package crawl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/docs/Index.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><a href="Page.html">Page</a></body></html>`))
+		case "/docs/Page.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body>Page</body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.RootURL = server.URL + "/docs/Index.html"
+	config.OutputDir = t.TempDir()
+	config.Version = "two"
+
+	result, err := Run(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Saved != 2 || result.Failed != 0 {
+		t.Errorf("Run() = %+v, want 2 saved", result)
+	}
+	if _, err := os.Stat(filepath.Join(config.OutputDir, "manifest.json")); err != nil {
+		t.Errorf("manifest not written: %v", err)
+	}
+}
+
+func TestRun_MissingConfig(t *testing.T) {
+	if _, err := Run(context.Background(), DefaultConfig()); err == nil {
+		t.Error("expected error without RootURL and OutputDir")
+	}
+}